@@ -14,6 +14,7 @@ import (
 	"github.com/spf13/viper"
 	"pipegen/internal/dashboard"
 	"pipegen/internal/pipeline"
+	"pipegen/internal/types"
 )
 
 // dashboardCmd represents the dashboard command
@@ -87,6 +88,12 @@ func runDashboard(cmd *cobra.Command, args []string) error {
 		Cleanup:           true,
 	}
 
+	variables, err := loadVariables()
+	if err != nil {
+		return fmt.Errorf("invalid SQL variables: %w", err)
+	}
+	config.Variables = variables
+
 	// Override with config file if provided
 	if configFile != "" {
 		// TODO: Load config from file when pipeline.LoadConfig is available
@@ -137,7 +144,7 @@ func runDashboard(cmd *cobra.Command, args []string) error {
 		fmt.Println("📊 Dashboard running in standalone mode")
 
 		// Load SQL statements from project directory for display
-		err := loadSQLStatementsForDashboard(projectDir, dashboardServer)
+		err := loadSQLStatementsForDashboard(projectDir, dashboardServer, variables)
 		if err != nil {
 			fmt.Printf("⚠️  Warning: Could not load SQL statements: %v\n", err)
 		}
@@ -364,7 +371,7 @@ func detectPipelineInfo(projectDir string) (string, string) {
 }
 
 // loadSQLStatementsForDashboard loads SQL statements from project directory for standalone dashboard display
-func loadSQLStatementsForDashboard(projectDir string, dashboardServer *dashboard.DashboardServer) error {
+func loadSQLStatementsForDashboard(projectDir string, dashboardServer *dashboard.DashboardServer, variables *pipeline.Variables) error {
 	sqlDir := filepath.Join(projectDir, "sql")
 
 	// Check if SQL directory exists
@@ -392,8 +399,8 @@ func loadSQLStatementsForDashboard(projectDir string, dashboardServer *dashboard
 		CheckpointStats:  &dashboard.CheckpointStats{},
 	}
 
-	// Process each SQL file
-	for i, sqlFile := range sqlFiles {
+	var statements []*types.SQLStatement
+	for _, sqlFile := range sqlFiles {
 		content, err := os.ReadFile(sqlFile)
 		if err != nil {
 			fmt.Printf("⚠️  Warning: Could not read SQL file %s: %v\n", sqlFile, err)
@@ -402,25 +409,48 @@ func loadSQLStatementsForDashboard(projectDir string, dashboardServer *dashboard
 
 		// Extract name from filename (remove extension and path)
 		baseName := filepath.Base(sqlFile)
-		name := baseName[:len(baseName)-4] // Remove .sql extension
+		statements = append(statements, &types.SQLStatement{
+			Name:     baseName[:len(baseName)-4], // Remove .sql extension
+			Content:  string(content),
+			FilePath: sqlFile,
+		})
+	}
+
+	// Show the statements in deployment order with their dependencies
+	dependencies := make(map[string][]string)
+	if graph, err := pipeline.BuildStatementGraph(statements); err != nil {
+		fmt.Printf("⚠️  Warning: %v\n", err)
+	} else {
+		statements, dependencies = graph.Statements, graph.Dependencies
+	}
+
+	// Process each SQL file
+	used := variables.Used(statements)
+	for i, sqlStmt := range statements {
+		deps := dependencies[sqlStmt.Name]
+		if deps == nil {
+			deps = []string{}
+		}
+		processed, _ := pipeline.SubstituteVariables(sqlStmt.Content, used)
 
 		// Create FlinkStatement for display
 		stmt := &dashboard.FlinkStatement{
 			ID:               fmt.Sprintf("stmt-%d", i+1),
-			Name:             name,
+			Name:             sqlStmt.Name,
 			Order:            i + 1,
 			Status:           "PENDING",
 			Phase:            "READY",
-			Content:          string(content),
-			ProcessedContent: string(content),
-			FilePath:         sqlFile,
+			Content:          sqlStmt.Content,
+			ProcessedContent: processed,
+			FilePath:         sqlStmt.FilePath,
 			DeploymentID:     "",
 			RecordsProcessed: 0,
 			RecordsPerSec:    0,
 			Parallelism:      1,
-			Dependencies:     []string{},
-			Variables:        make(map[string]string),
+			Dependencies:     deps,
+			Variables:        used,
 		}
+		stmt.DescribeSQL()
 
 		flinkMetrics.SQLStatements[stmt.ID] = stmt
 	}
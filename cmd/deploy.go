@@ -99,6 +99,11 @@ func runDeploy(cmd *cobra.Command, args []string) error {
 	// Create topics and register schemas
 	fmt.Println("📝 Setting up topics and schemas...")
 	deployer := docker.NewStackDeployer(projectDir)
+	variables, err := loadVariables()
+	if err != nil {
+		return fmt.Errorf("invalid SQL variables: %w", err)
+	}
+	deployer.SetVariables(variables)
 	if err := deployer.SetupTopicsAndSchemas(ctx, withSchemaRegistry); err != nil {
 		return fmt.Errorf("failed to setup topics and schemas: %w", err)
 	}
@@ -20,12 +20,13 @@ var initCmd = &cobra.Command{
 	Short: "Initialize a new streaming pipeline project",
 	Long: `Initialize creates a new streaming pipeline project with the following structure:
 - sql/ directory with sample SQL statements
-- schemas/ directory for AVRO schemas
+- schemas/ directory for AVRO, Protobuf or JSON schemas
 - config/ directory for pipeline configuration
 - Example producer and consumer configurations
 
-You can provide your own input AVRO schema using the --input-schema flag.
-The tool will copy your schema and generate the project structure accordingly.
+You can provide your own input schema using the --input-schema flag: an AVRO schema (.avsc),
+a Protobuf definition (.proto) or a JSON Schema (.json). The tool will copy your schema and
+generate the project structure, including source and output tables in the matching format.
 
 For AI-powered generation, use --describe to let LLM generate optimized pipeline components:
   pipegen init my-pipeline --describe "Process user events and calculate hourly metrics"`,
@@ -36,7 +37,7 @@ For AI-powered generation, use --describe to let LLM generate optimized pipeline
 func init() {
 	rootCmd.AddCommand(initCmd)
 	initCmd.Flags().Bool("force", false, "Overwrite existing project directory")
-	initCmd.Flags().String("input-schema", "", "Path to existing AVRO schema file to use as input schema")
+	initCmd.Flags().String("input-schema", "", "Path to an existing AVRO (.avsc), Protobuf (.proto) or JSON Schema (.json) file to use as input schema")
 	initCmd.Flags().String("input-csv", "", "Path to CSV file to use as source dataset (generates filesystem CSV source table)")
 	initCmd.Flags().String("describe", "", "Natural language description of your streaming pipeline (requires PIPEGEN_OLLAMA_MODEL or PIPEGEN_OPENAI_API_KEY)")
 	initCmd.Flags().String("domain", "", "Business domain for better AI context (e.g., ecommerce, fintech, iot)")
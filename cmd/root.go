@@ -10,6 +10,12 @@ import (
 
 var cfgFile string
 
+// SQL variable flags shared by every command that reads the SQL
+var (
+	sqlProfile   string
+	sqlVariables []string
+)
+
 // rootCmd represents the base command when called without any subcommands
 var rootCmd = &cobra.Command{
 	Use:   "pipegen",
@@ -39,6 +45,8 @@ func init() {
 	rootCmd.PersistentFlags().String("flink-url", "http://localhost:8081", "Flink Job Manager URL")
 	rootCmd.PersistentFlags().String("schema-registry-url", "http://localhost:8082", "Schema Registry URL")
 	rootCmd.PersistentFlags().Bool("local-mode", true, "Use local Docker stack (no authentication)")
+	rootCmd.PersistentFlags().StringVar(&sqlProfile, "profile", "", "Profile whose SQL variables override the variables section (default: local in local mode, cloud otherwise)")
+	rootCmd.PersistentFlags().StringArrayVar(&sqlVariables, "set", nil, "Set a SQL variable, overriding the config and environment (KEY=VALUE, repeatable)")
 
 	_ = viper.BindPFlag("bootstrap_servers", rootCmd.PersistentFlags().Lookup("bootstrap-servers"))
 	_ = viper.BindPFlag("flink_url", rootCmd.PersistentFlags().Lookup("flink-url"))
@@ -7,11 +7,13 @@ import (
 	"os/exec"
 	"os/signal"
 	"path/filepath"
+	"sort"
 	"strings"
 	"syscall"
 	"time"
 
 	"pipegen/internal/dashboard"
+	"pipegen/internal/flinksql"
 	"pipegen/internal/pipeline"
 
 	"github.com/spf13/cobra"
@@ -53,8 +55,35 @@ func init() {
 	runCmd.Flags().Int("dashboard-port", 3000, "Dashboard server port")
 	runCmd.Flags().Bool("generate-report", true, "Generate HTML execution report")
 	runCmd.Flags().String("reports-dir", "", "Directory to save execution reports (default: project-dir/reports)")
-	runCmd.Flags().String("traffic-pattern", "", "Define traffic peaks: 'start-end:rate%,start-end:rate%' (e.g., '30s-60s:300%,90s-120s:200%')")
+	runCmd.Flags().String("traffic-pattern", "", "Define traffic peaks: 'start-end:rate%,start-end:rate%' (e.g., '30s-60s:300%,90s-120s:200%'), ramps ('0s-2m:100%->500%'), waves ('wave(50%,150%,1m)') or bursts ('burst(400%,5s,30s)')")
+	runCmd.Flags().String("traffic-profile", "", "YAML file describing the rate over a full day (or period), compressed into --duration")
 	runCmd.Flags().Bool("global-tables", false, "Use global table creation mode (reuse session across pipeline runs)")
+	runCmd.Flags().Bool("parallel-deploy", false, "Deploy SQL statements that do not depend on each other at the same time")
+	runCmd.Flags().String("replay", "", "Replay recorded records from a .jsonl, .csv or .avro file instead of generating random data")
+	runCmd.Flags().String("replay-pace", pipeline.ReplayPaceRate, "Replay pacing: 'max' (as fast as possible), 'rate' (--message-rate) or 'original' (recorded spacing)")
+	runCmd.Flags().String("replay-timestamp-field", "", "Field holding the event time, used by --replay-pace=original")
+	runCmd.Flags().Float64("replay-speed", 1.0, "Speed factor for --replay-pace=original (2 = twice as fast)")
+	runCmd.Flags().StringSlice("key-fields", nil, "Schema fields that make up the message key (e.g. 'user_id,region')")
+	runCmd.Flags().String("key-format", "", "Message key encoding: 'avro' (default with --key-fields) or 'string'")
+	runCmd.Flags().Int("key-cardinality", 0, "Maximum number of distinct message keys (0 = unbounded)")
+	runCmd.Flags().String("key-skew", "", "Key popularity skew: 'none', 'zipf' or 'hotkey'")
+	runCmd.Flags().Float64("hot-key-percent", 0, "Percentage of messages sent to the hot key(s) with --key-skew=hotkey")
+	runCmd.Flags().String("event-time-field", "", "Event time field to disorder (default: first timestamp field of the input schema)")
+	runCmd.Flags().Float64("delay-percent", 0, "Percentage of events stamped up to --max-delay in the past (out of order)")
+	runCmd.Flags().Duration("max-delay", 0, "Maximum delay of out-of-order events (e.g. 5s)")
+	runCmd.Flags().Duration("jitter", 0, "Move every other event time by up to plus or minus this bound")
+	runCmd.Flags().Float64("late-percent", 0, "Percentage of events stamped later than --allowed-lateness")
+	runCmd.Flags().Duration("allowed-lateness", 0, "Watermark delay plus allowed lateness of the pipeline; late events fall behind it")
+	runCmd.Flags().Bool("assert", false, "Compare the output topic with the expected records in the expectations directory and fail on mismatches")
+	runCmd.Flags().String("expectations-dir", pipeline.DefaultExpectationsDir, "Directory with the fixture input and expected output for --assert (relative to --project-dir)")
+	runCmd.Flags().String("latency-field", "", "Input field stamped with the produce time; measures end-to-end latency when the SQL passes it to the output")
+	runCmd.Flags().Bool("reconcile", false, "Account for every input record in the output: report lost, duplicated and unexpected records")
+	runCmd.Flags().String("reconcile-id-field", "", "Input field that identifies a record for --reconcile (default: event_id, id or first *_id field)")
+	runCmd.Flags().String("dlq-topic", "", "Also forward output records that fail processing to this Kafka topic (they are always written to reports/<execution-id>/dlq.jsonl)")
+	runCmd.Flags().Int64("seed", 0, "Random seed for data generation; the same seed and message count produce identical messages")
+	runCmd.Flags().Int("producer-workers", pipeline.DefaultProducerWorkers, "Concurrent Kafka writers (1 keeps strict send order)")
+	runCmd.Flags().Int("producer-batch-size", pipeline.DefaultProducerBatchSize, "Maximum messages per Kafka write")
+	runCmd.Flags().String("inject-faults", "", "Send poison messages: 'bad-magic:1%,unknown-schema:1%,truncated:1%,null-field:1%,oversized:1%,duplicate:1%' or a total like '5%'")
 }
 
 func runPipeline(cmd *cobra.Command, args []string) error {
@@ -70,7 +99,26 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 	generateReport, _ := cmd.Flags().GetBool("generate-report")
 	reportsDir, _ := cmd.Flags().GetString("reports-dir")
 	trafficPatternStr, _ := cmd.Flags().GetString("traffic-pattern")
+	trafficProfilePath, _ := cmd.Flags().GetString("traffic-profile")
 	globalTables, _ := cmd.Flags().GetBool("global-tables")
+	parallelDeploy, _ := cmd.Flags().GetBool("parallel-deploy")
+	replayPath, _ := cmd.Flags().GetString("replay")
+	replayPace, _ := cmd.Flags().GetString("replay-pace")
+	replayTimestampField, _ := cmd.Flags().GetString("replay-timestamp-field")
+	replaySpeed, _ := cmd.Flags().GetFloat64("replay-speed")
+	producerWorkers, _ := cmd.Flags().GetInt("producer-workers")
+	producerBatchSize, _ := cmd.Flags().GetInt("producer-batch-size")
+	seed, _ := cmd.Flags().GetInt64("seed")
+	latencyField, _ := cmd.Flags().GetString("latency-field")
+	dlqTopic, _ := cmd.Flags().GetString("dlq-topic")
+	assertOutput, _ := cmd.Flags().GetBool("assert")
+	expectationsDir, _ := cmd.Flags().GetString("expectations-dir")
+	if producerWorkers < 1 || producerBatchSize < 1 {
+		return fmt.Errorf("--producer-workers and --producer-batch-size must be at least 1")
+	}
+	if latencyField != "" && cmd.Flags().Changed("seed") {
+		return fmt.Errorf("--latency-field stamps messages with the wall clock, so it cannot be combined with --seed")
+	}
 
 	// Validate configuration
 	if err := validateConfig(); err != nil {
@@ -80,7 +128,20 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 	// Parse traffic patterns if provided
 	var trafficPatterns *pipeline.TrafficPatterns
 	var err error
-	if trafficPatternStr != "" {
+	if trafficPatternStr != "" && trafficProfilePath != "" {
+		return fmt.Errorf("use either --traffic-pattern or --traffic-profile, not both")
+	}
+	if trafficProfilePath != "" {
+		if !filepath.IsAbs(trafficProfilePath) {
+			if _, err := os.Stat(trafficProfilePath); os.IsNotExist(err) {
+				trafficProfilePath = filepath.Join(projectDir, trafficProfilePath)
+			}
+		}
+		trafficPatterns, err = pipeline.LoadTrafficProfile(trafficProfilePath, duration, messageRate)
+		if err != nil {
+			return fmt.Errorf("invalid traffic profile: %w", err)
+		}
+	} else if trafficPatternStr != "" {
 		trafficPatterns, err = pipeline.ParseTrafficPattern(trafficPatternStr, messageRate)
 		if err != nil {
 			return fmt.Errorf("invalid traffic pattern: %w", err)
@@ -92,6 +153,97 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 		}
 	}
 
+	// Configure the message key strategy (key_strategy section, overridden by flags)
+	keyStrategy, err := loadKeyStrategy(cmd)
+	if err != nil {
+		return fmt.Errorf("invalid key strategy: %w", err)
+	}
+
+	// Per-source overrides for pipelines that read from several Kafka topics
+	sources, err := loadSources(duration)
+	if err != nil {
+		return fmt.Errorf("invalid sources configuration: %w", err)
+	}
+
+	// Per-sink expected message counts for pipelines that write several Kafka topics
+	sinks, err := loadSinks()
+	if err != nil {
+		return fmt.Errorf("invalid sinks configuration: %w", err)
+	}
+
+	// Rules output records must satisfy (validation section)
+	validation, err := loadValidation()
+	if err != nil {
+		return fmt.Errorf("invalid validation configuration: %w", err)
+	}
+
+	// ${VAR} placeholders of the SQL (variables and profiles sections, environment, --set)
+	variables, err := loadVariables()
+	if err != nil {
+		return fmt.Errorf("invalid SQL variables: %w", err)
+	}
+
+	// Out-of-order and late events (disorder section, overridden by flags)
+	disorder, err := loadDisorder(cmd)
+	if err != nil {
+		return fmt.Errorf("invalid event-time disorder: %w", err)
+	}
+	if disorder != nil && replayPath != "" {
+		return fmt.Errorf("--replay keeps the recorded event times; event-time disorder only applies to generated data")
+	}
+
+	// Lost and duplicated record accounting (reconciliation section, overridden by flags)
+	reconciliation, err := loadReconciliation(cmd)
+	if err != nil {
+		return fmt.Errorf("invalid reconciliation: %w", err)
+	}
+
+	// Poison messages (faults section, overridden by --inject-faults)
+	faults, err := loadFaults(cmd)
+	if err != nil {
+		return fmt.Errorf("invalid fault injection: %w", err)
+	}
+
+	// Configure replay of recorded data if requested
+	var replay *pipeline.ReplayConfig
+	if replayPath != "" {
+		if !filepath.IsAbs(replayPath) {
+			if _, err := os.Stat(replayPath); os.IsNotExist(err) {
+				replayPath = filepath.Join(projectDir, replayPath)
+			}
+		}
+		replay = &pipeline.ReplayConfig{
+			Path:           replayPath,
+			Pace:           replayPace,
+			Rate:           messageRate,
+			TimestampField: replayTimestampField,
+			Speed:          replaySpeed,
+		}
+		if err := replay.Validate(); err != nil {
+			return fmt.Errorf("invalid replay configuration: %w", err)
+		}
+	}
+
+	// Golden-output assertions: produce the fixture input and compare the output with the expected records
+	var expectations *pipeline.Expectations
+	if assertOutput {
+		if !filepath.IsAbs(expectationsDir) {
+			expectationsDir = filepath.Join(projectDir, expectationsDir)
+		}
+		if expectations, err = pipeline.LoadExpectations(expectationsDir); err != nil {
+			return fmt.Errorf("invalid expectations: %w", err)
+		}
+		if input := expectations.InputPath(); input != "" && replay == nil {
+			if disorder != nil {
+				return fmt.Errorf("the fixture input keeps its event times; event-time disorder only applies to generated data")
+			}
+			replay = &pipeline.ReplayConfig{Path: input, Pace: pipeline.ReplayPaceMax, Speed: 1}
+			if err := replay.Validate(); err != nil {
+				return fmt.Errorf("invalid fixture input: %w", err)
+			}
+		}
+	}
+
 	config := &pipeline.Config{
 		ProjectDir:        projectDir,
 		MessageRate:       messageRate,
@@ -108,6 +260,23 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 		ReportsDir:        reportsDir,
 		TrafficPatterns:   trafficPatterns,
 		GlobalTables:      globalTables,
+		ParallelDeploy:    parallelDeploy,
+		Variables:         variables,
+		Replay:            replay,
+		KeyStrategy:       keyStrategy,
+		Sources:           sources,
+		Sinks:             sinks,
+		Disorder:          disorder,
+		Faults:            faults,
+		ProducerWorkers:   producerWorkers,
+		ProducerBatchSize: producerBatchSize,
+		Seed:              seed,
+		Reproducible:      cmd.Flags().Changed("seed"),
+		Expectations:      expectations,
+		LatencyField:      latencyField,
+		Validation:        validation,
+		Reconciliation:    reconciliation,
+		DLQTopic:          dlqTopic,
 		KafkaConfig: pipeline.KafkaConfig{
 			Partitions:        viper.GetInt("kafka_config.partitions"),
 			ReplicationFactor: viper.GetInt("kafka_config.replication_factor"),
@@ -118,28 +287,18 @@ func runPipeline(cmd *cobra.Command, args []string) error {
 	// Detect CSV mode (filesystem connector CSV) by inspecting 01_create_source_table.sql if present
 	createSourcePath := filepath.Join(projectDir, "sql", "01_create_source_table.sql")
 	if b, err := os.ReadFile(createSourcePath); err == nil {
-		contentUpper := strings.ToUpper(string(b))
-		if strings.Contains(contentUpper, "'CONNECTOR' = 'FILESYSTEM'") && strings.Contains(contentUpper, "'FORMAT' = 'CSV'") {
+		if isFilesystemCSVSource(string(b)) {
+			if replay != nil {
+				return fmt.Errorf("--replay sends records through Kafka, but %s reads from the filesystem; switch the source table to the Kafka connector", filepath.Base(createSourcePath))
+			}
 			config.CSVMode = true
 			fmt.Println("🧪 Detected filesystem CSV source table: enabling CSV mode (skip producer & consumer).")
 		}
 	}
 
-	// --- NEW LOGIC: Check if stack is running, deploy if needed ---
-	if !isDockerStackRunning(projectDir) {
-		fmt.Println("🧹 Docker stack not running. Deploying stack...")
-		deployCmd, _, _ := cmd.Root().Find([]string{"deploy"})
-		if deployCmd == nil {
-			return fmt.Errorf("deploy command not found")
-		}
-		deployArgs := []string{"--project-dir", projectDir}
-		if err := deployCmd.RunE(deployCmd, deployArgs); err != nil {
-			return fmt.Errorf("failed to deploy stack: %w", err)
-		}
-	} else {
-		fmt.Println("✅ Docker stack is already running. Skipping deploy.")
+	if err := ensureDockerStack(cmd, projectDir); err != nil {
+		return err
 	}
-	// --- END NEW LOGIC ---
 
 	if dryRun {
 		fmt.Println("🔍 Dry run mode - showing execution plan:")
@@ -222,15 +381,85 @@ func showExecutionPlan(config *pipeline.Config) error {
 	fmt.Printf("  Project Directory: %s\n", config.ProjectDir)
 
 	// Show traffic pattern information
-	if config.TrafficPatterns != nil && config.TrafficPatterns.HasPatterns() {
+	if config.Replay != nil {
+		fmt.Printf("  Replay File: %s\n", config.Replay.Path)
+		switch config.Replay.Pace {
+		case pipeline.ReplayPaceOriginal:
+			fmt.Printf("  Replay Pace: original spacing from %s (x%.2f)\n", config.Replay.TimestampField, config.Replay.Speed)
+		case pipeline.ReplayPaceRate:
+			fmt.Printf("  Replay Pace: %d msg/sec\n", config.MessageRate)
+		default:
+			fmt.Println("  Replay Pace: as fast as possible")
+		}
+	} else if config.TrafficPatterns != nil && config.TrafficPatterns.HasPatterns() {
 		fmt.Println("  Traffic Pattern:")
 		for _, line := range strings.Split(config.TrafficPatterns.GetPatternSummary(), "\n") {
 			fmt.Printf("    %s\n", line)
 		}
+		fmt.Printf("    Shape: %s\n", config.TrafficPatterns.Sparkline(config.Duration, 60))
 	} else {
 		fmt.Printf("  Message Rate: %d msg/sec (constant)\n", config.MessageRate)
 	}
 
+	if config.Reproducible {
+		fmt.Printf("  Seed: %d (reproducible data, virtual clock from 2024-01-01)\n", config.Seed)
+	}
+	if config.Replay == nil {
+		fmt.Printf("  Producer: %d worker(s), batches of up to %d messages\n", config.ProducerWorkers, config.ProducerBatchSize)
+	}
+	if config.KeyStrategy != nil {
+		fmt.Printf("  Message Keys: %s\n", config.KeyStrategy.Summary())
+	}
+	if config.Disorder != nil {
+		fmt.Printf("  Event-Time Disorder: %s\n", config.Disorder.Summary())
+	}
+	if config.Faults != nil {
+		fmt.Printf("  Fault Injection: %s\n", config.Faults.Summary())
+	}
+	if config.Expectations != nil {
+		fmt.Printf("  Assertions: %s\n", config.Expectations.Summary())
+	}
+	if config.Reconciliation != nil {
+		fmt.Printf("  Reconciliation: %s\n", config.Reconciliation.Summary())
+	}
+	if config.DLQTopic != "" {
+		fmt.Printf("  Dead-Letter Topic: %s\n", config.DLQTopic)
+	}
+	if config.LatencyField != "" {
+		fmt.Printf("  End-to-End Latency: produce time in field %s and header %s\n", config.LatencyField, pipeline.ProducedAtHeader)
+	}
+	if len(config.Validation) > 0 {
+		outputs := make([]string, 0, len(config.Validation))
+		rules := 0
+		for output := range config.Validation {
+			outputs = append(outputs, output)
+			rules += len(config.Validation[output])
+		}
+		sort.Strings(outputs)
+		fmt.Printf("  Validation: %d rule(s) for %s\n", rules, strings.Join(outputs, ", "))
+	}
+	if len(config.Sources) > 0 {
+		fmt.Println("  Source Overrides:")
+		names := make([]string, 0, len(config.Sources))
+		for name := range config.Sources {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("    %s: %s\n", name, config.Sources[name].Summary())
+		}
+	}
+	if len(config.Sinks) > 0 {
+		fmt.Println("  Sink Overrides:")
+		names := make([]string, 0, len(config.Sinks))
+		for name := range config.Sinks {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fmt.Printf("    %s: expecting %d messages\n", name, config.Sinks[name].ExpectedMessages)
+		}
+	}
 	fmt.Printf("  Producer Duration: %v\n", config.Duration)
 	fmt.Printf("  Pipeline Timeout: %v\n", config.PipelineTimeout)
 	fmt.Printf("  Bootstrap Servers: %s\n", config.BootstrapServers)
@@ -246,11 +475,14 @@ func showExecutionPlan(config *pipeline.Config) error {
 	fmt.Println("  5. Register AVRO schemas")
 	fmt.Println("  6. Deploy FlinkSQL statements")
 
-	if config.TrafficPatterns != nil && config.TrafficPatterns.HasPatterns() {
+	if config.Replay != nil {
+		fmt.Println("  7. Start Kafka producer replaying recorded records")
+	} else if config.TrafficPatterns != nil && config.TrafficPatterns.HasPatterns() {
 		fmt.Println("  7. Start Kafka producer with dynamic traffic patterns")
 	} else {
 		fmt.Println("  7. Start Kafka producer with constant rate")
 	}
+	fmt.Println("     (one producer per Kafka source table found in the SQL)")
 
 	fmt.Println("  8. Start Kafka consumer")
 	fmt.Println("  9. Monitor pipeline execution")
@@ -295,6 +527,12 @@ func runWithDashboard(config *pipeline.Config, dashboardPort int) error {
 		return fmt.Errorf("failed to create pipeline runner: %w", err)
 	}
 
+	// Show the SQL statements with their dependency graph
+	if graph, err := pipeline.NewSQLLoader(config.ProjectDir).LoadGraph(); err == nil {
+		dashboardServer.InitializeSQLStatements(graph.Statements, config.Variables.Used(graph.Statements))
+		dashboardServer.SetStatementDependencies(graph.Dependencies)
+	}
+
 	// Set dashboard server for SQL statement tracking
 	// runner.SetDashboardServer(dashboardServer) // Temporarily disabled
 
@@ -336,16 +574,43 @@ func runWithDashboard(config *pipeline.Config, dashboardPort int) error {
 		}
 		dashboardServer.UpdatePipelineStatus(status)
 
+		// Feed live producer statistics to the dashboard
+		go func() {
+			ticker := time.NewTicker(2 * time.Second)
+			defer ticker.Stop()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+					if stats := pipeline.LiveProducerStats(); stats != nil {
+						dashboardServer.UpdateProducerMetrics(dashboard.ProducerMetricsFromStats(stats))
+					}
+					if stats := pipeline.LiveConsumerStats(); stats != nil {
+						dashboardServer.UpdateConsumerMetrics(dashboard.ConsumerMetricsFromStats(stats))
+					}
+					if latency := pipeline.LiveEndToEndLatency(); latency != nil {
+						dashboardServer.UpdateEndToEndLatency(latency)
+					}
+					if validation := pipeline.LiveValidationReport(); validation != nil {
+						dashboardServer.UpdateDataQuality(dashboard.DataQualityFromValidation(validation))
+					}
+				}
+			}
+		}()
+
 		// Run the pipeline
 		pipelineDone <- runner.Run(ctx)
 	}()
 
 	// Wait for completion or shutdown
+	var pipelineErr error
 	select {
 	case <-sigChan:
 		fmt.Println("\n🛑 Received interrupt signal, shutting down...")
 		cancel()
 	case err := <-pipelineDone:
+		pipelineErr = err
 		if err != nil {
 			fmt.Printf("❌ Pipeline execution failed: %v\n", err)
 		} else {
@@ -365,9 +630,221 @@ func runWithDashboard(config *pipeline.Config, dashboardPort int) error {
 		fmt.Printf("⚠️  Error during dashboard shutdown: %v\n", err)
 	}
 
+	// Surface pipeline failures, such as failed assertions, in the exit code
+	if pipelineErr != nil {
+		return fmt.Errorf("pipeline execution failed: %w", pipelineErr)
+	}
 	return nil
 }
 
+// loadKeyStrategy reads the key_strategy config section and applies --key-* flag overrides
+func loadKeyStrategy(cmd *cobra.Command) (*pipeline.KeyStrategyConfig, error) {
+	keyStrategy := &pipeline.KeyStrategyConfig{}
+	if err := viper.UnmarshalKey("key_strategy", keyStrategy); err != nil {
+		return nil, err
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("key-fields") {
+		keyStrategy.Fields, _ = flags.GetStringSlice("key-fields")
+	}
+	if flags.Changed("key-format") {
+		keyStrategy.Format, _ = flags.GetString("key-format")
+	}
+	if flags.Changed("key-cardinality") {
+		keyStrategy.Cardinality, _ = flags.GetInt("key-cardinality")
+	}
+	if flags.Changed("key-skew") {
+		keyStrategy.Skew, _ = flags.GetString("key-skew")
+	}
+	if flags.Changed("hot-key-percent") {
+		keyStrategy.HotKeyPercent, _ = flags.GetFloat64("hot-key-percent")
+	}
+
+	if keyStrategy.IsDefault() {
+		return nil, nil
+	}
+	if err := keyStrategy.Validate(); err != nil {
+		return nil, err
+	}
+	return keyStrategy, nil
+}
+
+// loadDisorder reads the disorder config section and applies the event-time flag overrides
+func loadDisorder(cmd *cobra.Command) (*pipeline.DisorderConfig, error) {
+	disorder := &pipeline.DisorderConfig{}
+	if err := viper.UnmarshalKey("disorder", disorder); err != nil {
+		return nil, err
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("event-time-field") {
+		disorder.TimestampField, _ = flags.GetString("event-time-field")
+	}
+	if flags.Changed("delay-percent") {
+		disorder.DelayPercent, _ = flags.GetFloat64("delay-percent")
+	}
+	if flags.Changed("max-delay") {
+		disorder.MaxDelay, _ = flags.GetDuration("max-delay")
+	}
+	if flags.Changed("jitter") {
+		disorder.Jitter, _ = flags.GetDuration("jitter")
+	}
+	if flags.Changed("late-percent") {
+		disorder.LatePercent, _ = flags.GetFloat64("late-percent")
+	}
+	if flags.Changed("allowed-lateness") {
+		disorder.AllowedLateness, _ = flags.GetDuration("allowed-lateness")
+	}
+
+	if !disorder.IsEnabled() {
+		return nil, nil
+	}
+	if err := disorder.Validate(); err != nil {
+		return nil, err
+	}
+	return disorder, nil
+}
+
+// loadReconciliation reads the reconciliation config section and applies the --reconcile flag overrides
+func loadReconciliation(cmd *cobra.Command) (*pipeline.ReconciliationConfig, error) {
+	reconciliation := &pipeline.ReconciliationConfig{}
+	if err := viper.UnmarshalKey("reconciliation", reconciliation); err != nil {
+		return nil, err
+	}
+
+	flags := cmd.Flags()
+	if flags.Changed("reconcile") {
+		reconciliation.Enabled, _ = flags.GetBool("reconcile")
+	}
+	if flags.Changed("reconcile-id-field") {
+		reconciliation.IDField, _ = flags.GetString("reconcile-id-field")
+		reconciliation.Enabled = true
+	}
+
+	if !reconciliation.IsEnabled() {
+		return nil, nil
+	}
+	if err := reconciliation.Validate(); err != nil {
+		return nil, err
+	}
+	return reconciliation, nil
+}
+
+// loadFaults reads the faults config section and applies the --inject-faults override
+func loadFaults(cmd *cobra.Command) (*pipeline.FaultConfig, error) {
+	faults := &pipeline.FaultConfig{}
+	if err := viper.UnmarshalKey("faults", faults); err != nil {
+		return nil, err
+	}
+
+	if cmd.Flags().Changed("inject-faults") {
+		spec, _ := cmd.Flags().GetString("inject-faults")
+		rates, err := pipeline.ParseFaultSpec(spec)
+		if err != nil {
+			return nil, err
+		}
+		faults.Rates = rates
+	}
+
+	if !faults.IsEnabled() {
+		return nil, nil
+	}
+	if err := faults.Validate(); err != nil {
+		return nil, err
+	}
+	return faults, nil
+}
+
+// loadSources reads the sources config section (per-source rate, traffic pattern, schema and references)
+func loadSources(duration time.Duration) (map[string]*pipeline.SourceConfig, error) {
+	sources := map[string]*pipeline.SourceConfig{}
+	if err := viper.UnmarshalKey("sources", &sources); err != nil {
+		return nil, err
+	}
+	for name, source := range sources {
+		if source == nil {
+			delete(sources, name)
+			continue
+		}
+		if err := source.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		if source.TrafficPattern != "" {
+			patterns, _ := pipeline.ParseTrafficPattern(source.TrafficPattern, 1)
+			if err := validateTrafficPatternDuration(patterns, duration); err != nil {
+				return nil, fmt.Errorf("%s: %w", name, err)
+			}
+		}
+	}
+	if len(sources) == 0 {
+		return nil, nil
+	}
+	return sources, nil
+}
+
+// loadSinks reads the sinks config section (per-sink expected message counts)
+func loadSinks() (map[string]*pipeline.SinkConfig, error) {
+	sinks := map[string]*pipeline.SinkConfig{}
+	if err := viper.UnmarshalKey("sinks", &sinks); err != nil {
+		return nil, err
+	}
+	for name, sink := range sinks {
+		if sink == nil {
+			delete(sinks, name)
+			continue
+		}
+		if err := sink.Validate(); err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	if len(sinks) == 0 {
+		return nil, nil
+	}
+	return sinks, nil
+}
+
+// loadValidation reads the validation section (rules per output table or topic)
+func loadValidation() (map[string][]pipeline.ValidationRule, error) {
+	validation := map[string][]pipeline.ValidationRule{}
+	if err := viper.UnmarshalKey("validation", &validation); err != nil {
+		return nil, err
+	}
+	for output, rules := range validation {
+		if len(rules) == 0 {
+			delete(validation, output)
+			continue
+		}
+		if err := pipeline.ValidateRules(rules); err != nil {
+			return nil, fmt.Errorf("%s: %w", output, err)
+		}
+	}
+	if len(validation) == 0 {
+		return nil, nil
+	}
+	return validation, nil
+}
+
+// loadVariables reads the SQL variables: the variables section, the variables of the active profile
+// and the --set flags. Without --profile the local profile applies in local mode, cloud otherwise.
+func loadVariables() (*pipeline.Variables, error) {
+	profile := sqlProfile
+	if profile == "" {
+		profile = "cloud"
+		if viper.GetBool("local_mode") {
+			profile = "local"
+		}
+	} else if !viper.IsSet("profiles." + profile) {
+		return nil, fmt.Errorf("profile %q is not defined in the profiles section", profile)
+	}
+	return pipeline.NewVariables(
+		viper.GetStringMapString("variables"),
+		viper.GetStringMapString("profiles."+profile+".variables"),
+		profile,
+		sqlVariables,
+	)
+}
+
 // getReportsDir returns the directory where reports should be saved
 func getReportsDir(config *pipeline.Config) string {
 	if config.ReportsDir != "" {
@@ -376,6 +853,24 @@ func getReportsDir(config *pipeline.Config) string {
 	return filepath.Join(config.ProjectDir, "reports")
 }
 
+// isFilesystemCSVSource reports whether the SQL declares a table reading CSV files with the filesystem connector
+func isFilesystemCSVSource(sql string) bool {
+	statements, err := flinksql.Parse(sql)
+	if err != nil {
+		return false
+	}
+	for _, stmt := range statements {
+		if stmt.Table == nil {
+			continue
+		}
+		format, _ := stmt.Table.Option("format")
+		if strings.EqualFold(stmt.Table.Connector(), "filesystem") && strings.EqualFold(format, "csv") {
+			return true
+		}
+	}
+	return false
+}
+
 // validateTrafficPatternDuration ensures all traffic patterns fit within the execution duration
 func validateTrafficPatternDuration(patterns *pipeline.TrafficPatterns, duration time.Duration) error {
 	if patterns == nil || !patterns.HasPatterns() {
@@ -396,6 +891,24 @@ func validateTrafficPatternDuration(patterns *pipeline.TrafficPatterns, duration
 	return nil
 }
 
+// ensureDockerStack deploys the local stack of the project unless it is already running
+func ensureDockerStack(cmd *cobra.Command, projectDir string) error {
+	if isDockerStackRunning(projectDir) {
+		fmt.Println("✅ Docker stack is already running. Skipping deploy.")
+		return nil
+	}
+	fmt.Println("🧹 Docker stack not running. Deploying stack...")
+	deployCmd, _, _ := cmd.Root().Find([]string{"deploy"})
+	if deployCmd == nil {
+		return fmt.Errorf("deploy command not found")
+	}
+	deployArgs := []string{"--project-dir", projectDir}
+	if err := deployCmd.RunE(deployCmd, deployArgs); err != nil {
+		return fmt.Errorf("failed to deploy stack: %w", err)
+	}
+	return nil
+}
+
 // isDockerStackRunning checks if the main containers are up
 func isDockerStackRunning(projectDir string) bool {
 	// This checks for running containers with docker compose ps
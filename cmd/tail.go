@@ -0,0 +1,96 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"pipegen/internal/pipeline"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var tailCmd = &cobra.Command{
+	Use:   "tail <topic|dlq-file>",
+	Short: "Print the decoded records of a Kafka topic or dead-letter file",
+	Long: `Tail reads a Kafka topic and prints its records as they arrive.
+
+Given the path of a dead-letter file written by pipegen run (reports/<execution-id>/dlq.jsonl),
+it prints the records that failed processing instead, with the error that made them fail.
+
+AVRO, Protobuf and JSON Schema records in the Confluent wire format are decoded with
+their writer schema from the project's Schema Registry; other records are printed as
+JSON or text.
+
+Examples:
+  pipegen tail orders                                  # New records, pretty JSON
+  pipegen tail orders --from-beginning --limit 10      # The first 10 records
+  pipegen tail orders --offset -5 --partition 2        # The last 5 records of partition 2
+  pipegen tail orders --filter "amount > 100" -o table # Matching records as a table
+  pipegen tail orders -o jsonl | jq .value             # One JSON object per line
+  pipegen tail reports/<execution-id>/dlq.jsonl        # Records that failed processing`,
+	Args: cobra.ExactArgs(1),
+	RunE: runTail,
+}
+
+func init() {
+	rootCmd.AddCommand(tailCmd)
+	tailCmd.Flags().Bool("from-beginning", false, "Start at the oldest record instead of waiting for new ones")
+	tailCmd.Flags().Int64("offset", 0, "Start offset in each partition; negative values count back from the end")
+	tailCmd.Flags().Int("partition", -1, "Partition to read (default: every partition)")
+	tailCmd.Flags().Int("limit", 0, "Stop after printing this many records (0 = until interrupted)")
+	tailCmd.Flags().String("filter", "", "Print only records matching an expression (e.g. \"status == 'PAID' && amount > 100\")")
+	tailCmd.Flags().StringP("output", "o", pipeline.TailFormatJSON, "Output format: 'json', 'jsonl' or 'table'")
+}
+
+func runTail(cmd *cobra.Command, args []string) error {
+	fromBeginning, _ := cmd.Flags().GetBool("from-beginning")
+	partition, _ := cmd.Flags().GetInt("partition")
+	limit, _ := cmd.Flags().GetInt("limit")
+	filter, _ := cmd.Flags().GetString("filter")
+	format, _ := cmd.Flags().GetString("output")
+
+	opts := pipeline.TailOptions{
+		BootstrapServers:  viper.GetString("bootstrap_servers"),
+		SchemaRegistryURL: viper.GetString("schema_registry_url"),
+		Partition:         partition,
+		FromBeginning:     fromBeginning,
+		Limit:             limit,
+		Filter:            filter,
+	}
+	if cmd.Flags().Changed("offset") {
+		offset, _ := cmd.Flags().GetInt64("offset")
+		opts.Offset = &offset
+	}
+	// An existing file is a dead-letter file, anything else a topic
+	if info, err := os.Stat(args[0]); err == nil && !info.IsDir() {
+		opts.File = args[0]
+	} else {
+		opts.Topic = args[0]
+	}
+	if opts.File == "" && opts.BootstrapServers == "" {
+		return fmt.Errorf("missing required configuration: bootstrap_servers")
+	}
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+
+	printer, err := pipeline.NewTailPrinter(os.Stdout, format)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	// Progress goes to stderr so the records can be piped
+	if opts.File != "" {
+		fmt.Fprintf(os.Stderr, "☠️  Reading dead letters from %s\n", opts.File)
+	} else {
+		fmt.Fprintf(os.Stderr, "👀 Tailing %s on %s (Ctrl+C to stop)\n", opts.Topic, opts.BootstrapServers)
+	}
+	return pipeline.Tail(ctx, opts, printer.Print)
+}
@@ -0,0 +1,170 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"regexp"
+	"syscall"
+	"time"
+
+	"pipegen/internal/pipeline"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var testCmd = &cobra.Command{
+	Use:   "test",
+	Short: "Run the SQL test cases in tests/ against fixtures",
+	Long: `Test runs every test case in the project's tests/ directory against the local stack.
+
+A test case is a directory with fixture records per source table and the rows each
+sink table must receive:
+
+  tests/high_value_orders/
+    input/orders.jsonl        # records of the source table orders (.jsonl, .csv or .avro)
+    expected/alerts.jsonl     # rows of the sink table alerts (.jsonl or .yaml)
+    test.yaml                 # optional: description, timeout, key and tolerances per sink
+
+For each case the fixtures are loaded into uniquely named temporary topics, the
+project's SQL is deployed with its topics swapped for them, and the sinks are read
+until they got the expected number of rows. The rows are compared, then the
+statements, topics and subjects of the case are removed.
+
+Examples:
+  pipegen test                                  # Every case in ./tests
+  pipegen test --run high_value                 # Cases whose name matches a regular expression
+  pipegen test --junit reports/sql-tests.xml    # Also write a JUnit XML report for CI
+  pipegen test --timeout 5m                     # Wait longer for the expected rows`,
+	RunE: runTests,
+}
+
+func init() {
+	rootCmd.AddCommand(testCmd)
+	testCmd.Flags().String("project-dir", ".", "Project directory path")
+	testCmd.Flags().String("tests-dir", pipeline.DefaultSQLTestsDir, "Directory with the test cases (relative to --project-dir)")
+	testCmd.Flags().String("run", "", "Only run test cases whose name matches this regular expression")
+	testCmd.Flags().Duration("timeout", pipeline.DefaultSQLTestTimeout, "Time a test case has to produce its expected rows, unless its test.yaml sets one")
+	testCmd.Flags().String("junit", "", "Write a JUnit XML report of the results to this file")
+}
+
+func runTests(cmd *cobra.Command, args []string) error {
+	projectDir, _ := cmd.Flags().GetString("project-dir")
+	testsDir, _ := cmd.Flags().GetString("tests-dir")
+	pattern, _ := cmd.Flags().GetString("run")
+	timeout, _ := cmd.Flags().GetDuration("timeout")
+	junitPath, _ := cmd.Flags().GetString("junit")
+	if timeout <= 0 {
+		return fmt.Errorf("--timeout must be positive")
+	}
+
+	if err := validateConfig(); err != nil {
+		return fmt.Errorf("configuration validation failed: %w", err)
+	}
+
+	if !filepath.IsAbs(testsDir) {
+		testsDir = filepath.Join(projectDir, testsDir)
+	}
+	cases, err := pipeline.LoadSQLTests(testsDir)
+	if err != nil {
+		return err
+	}
+	if pattern != "" {
+		filter, err := regexp.Compile(pattern)
+		if err != nil {
+			return fmt.Errorf("invalid --run pattern: %w", err)
+		}
+		var selected []*pipeline.SQLTestCase
+		for _, tc := range cases {
+			if filter.MatchString(tc.Name) {
+				selected = append(selected, tc)
+			}
+		}
+		if len(selected) == 0 {
+			return fmt.Errorf("no test case matches %q", pattern)
+		}
+		cases = selected
+	}
+
+	sources, err := loadSources(0)
+	if err != nil {
+		return fmt.Errorf("invalid sources configuration: %w", err)
+	}
+	variables, err := loadVariables()
+	if err != nil {
+		return fmt.Errorf("invalid SQL variables: %w", err)
+	}
+	config := &pipeline.Config{
+		ProjectDir:        projectDir,
+		MessageRate:       100,
+		BootstrapServers:  viper.GetString("bootstrap_servers"),
+		FlinkURL:          viper.GetString("flink_url"),
+		SchemaRegistryURL: viper.GetString("schema_registry_url"),
+		LocalMode:         viper.GetBool("local_mode"),
+		Sources:           sources,
+		Variables:         variables,
+		ProducerWorkers:   1,
+		ProducerBatchSize: pipeline.DefaultProducerBatchSize,
+		KafkaConfig: pipeline.KafkaConfig{
+			Partitions:        viper.GetInt("kafka_config.partitions"),
+			ReplicationFactor: viper.GetInt("kafka_config.replication_factor"),
+			RetentionMs:       viper.GetInt64("kafka_config.retention_ms"),
+		},
+	}
+	runner, err := pipeline.NewSQLTestRunner(config, timeout)
+	if err != nil {
+		return err
+	}
+
+	if err := ensureDockerStack(cmd, projectDir); err != nil {
+		return err
+	}
+
+	ctx, cancel := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer cancel()
+
+	fmt.Printf("🧪 Running %d test case(s) from %s\n", len(cases), testsDir)
+	started := time.Now()
+	results := runner.Run(ctx, cases)
+
+	if junitPath != "" {
+		if err := writeJUnitReport(junitPath, filepath.Base(filepath.Clean(projectDir)), started, results); err != nil {
+			return err
+		}
+		fmt.Printf("📄 JUnit report: %s\n", junitPath)
+	}
+
+	failed := 0
+	for _, result := range results {
+		if !result.Passed() {
+			failed++
+		}
+	}
+	fmt.Printf("\n🧪 %d passed, %d failed (%v)\n", len(results)-failed, failed, time.Since(started).Round(time.Second))
+	if ctx.Err() != nil {
+		return fmt.Errorf("interrupted after %d of %d test case(s)", len(results), len(cases))
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d test case(s) failed", failed, len(results))
+	}
+	return nil
+}
+
+// writeJUnitReport writes the test results to a JUnit XML file, creating its directory
+func writeJUnitReport(path, suite string, started time.Time, results []*pipeline.SQLTestResult) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return fmt.Errorf("failed to create JUnit report directory: %w", err)
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create JUnit report: %w", err)
+	}
+	if err := pipeline.WriteJUnitReport(file, suite, started, results); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
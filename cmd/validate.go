@@ -6,6 +6,10 @@ import (
 	"path/filepath"
 	"strings"
 
+	"pipegen/internal/flinksql"
+	"pipegen/internal/pipeline"
+	"pipegen/internal/types"
+
 	"github.com/spf13/cobra"
 )
 
@@ -14,7 +18,7 @@ var validateCmd = &cobra.Command{
 	Short: "Validate project structure and configuration",
 	Long: `Validate checks the project structure and configuration:
 - Validates SQL statements syntax
-- Validates AVRO schemas
+- Validates AVRO, Protobuf and JSON schemas
 - Checks configuration completeness
 - Verifies connectivity to Confluent Cloud`,
 	RunE: runValidate,
@@ -42,9 +46,14 @@ func runValidate(cmd *cobra.Command, args []string) error {
 		return fmt.Errorf("SQL validation failed: %w", err)
 	}
 
-	// Validate AVRO schemas
-	if err := validateAVROSchemas(projectDir); err != nil {
-		return fmt.Errorf("AVRO schema validation failed: %w", err)
+	// Validate schemas
+	if err := validateSchemas(projectDir); err != nil {
+		return fmt.Errorf("schema validation failed: %w", err)
+	}
+
+	// Validate declarative field generators
+	if err := validateGenerators(projectDir); err != nil {
+		return fmt.Errorf("generator validation failed: %w", err)
 	}
 
 	// Validate configuration
@@ -93,23 +102,65 @@ func validateSQLFiles(projectDir string) error {
 		return fmt.Errorf("failed to read sql directory: %w", err)
 	}
 
-	sqlCount := 0
+	var sqlFiles []*types.SQLStatement
 	for _, entry := range entries {
 		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".sql") {
-			sqlCount++
-			fmt.Printf("✓ SQL file found: %s\n", entry.Name())
+			content, err := os.ReadFile(filepath.Join(sqlDir, entry.Name()))
+			if err != nil {
+				return fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+			}
+			statements, err := flinksql.Parse(string(content))
+			if err != nil {
+				return fmt.Errorf("%s: %w", entry.Name(), err)
+			}
+			sqlFiles = append(sqlFiles, &types.SQLStatement{
+				Name:     strings.TrimSuffix(entry.Name(), ".sql"),
+				Content:  flinksql.StripComments(string(content)),
+				FilePath: filepath.Join(sqlDir, entry.Name()),
+			})
+			fmt.Printf("✓ SQL file parsed: %s (%d statement(s))\n", entry.Name(), len(statements))
 		}
 	}
 
-	if sqlCount == 0 {
+	if len(sqlFiles) == 0 {
 		return fmt.Errorf("no SQL files found in sql/ directory")
 	}
 
-	fmt.Printf("✓ Found %d SQL files\n", sqlCount)
+	// Every table a statement uses must be declared by a statement that can deploy before it
+	graph, err := pipeline.BuildStatementGraph(sqlFiles)
+	if err != nil {
+		return err
+	}
+	if len(graph.Dangling) > 0 {
+		var problems []string
+		for _, dangling := range graph.Dangling {
+			problems = append(problems, dangling.String())
+		}
+		return fmt.Errorf("undeclared tables:\n  %s", strings.Join(problems, "\n  "))
+	}
+	var order []string
+	for _, stmt := range graph.Statements {
+		order = append(order, stmt.Name)
+	}
+	fmt.Printf("✓ Deployment order: %s\n", strings.Join(order, " → "))
+
+	// Every ${VAR} placeholder needs a value in the active profile
+	variables, err := loadVariables()
+	if err != nil {
+		return err
+	}
+	if err := variables.CheckDefined(graph.Statements); err != nil {
+		return err
+	}
+	if used := variables.Used(graph.Statements); len(used) > 0 {
+		fmt.Printf("✓ SQL variables resolved (profile %s): %d\n", variables.Profile, len(used))
+	}
+
+	fmt.Printf("✓ Found %d SQL files\n", len(sqlFiles))
 	return nil
 }
 
-func validateAVROSchemas(projectDir string) error {
+func validateSchemas(projectDir string) error {
 	schemaDir := filepath.Join(projectDir, "schemas")
 
 	entries, err := os.ReadDir(schemaDir)
@@ -119,16 +170,49 @@ func validateAVROSchemas(projectDir string) error {
 
 	schemaCount := 0
 	for _, entry := range entries {
-		if !entry.IsDir() && (strings.HasSuffix(entry.Name(), ".avsc") || strings.HasSuffix(entry.Name(), ".json")) {
+		if !entry.IsDir() && pipeline.IsSchemaFile(entry.Name()) {
 			schemaCount++
-			fmt.Printf("✓ AVRO schema found: %s\n", entry.Name())
+			fmt.Printf("✓ Schema found: %s\n", entry.Name())
 		}
 	}
 
 	if schemaCount == 0 {
-		return fmt.Errorf("no AVRO schema files found in schemas/ directory")
+		return fmt.Errorf("no schema files (.avsc, .json or .proto) found in schemas/ directory")
 	}
 
-	fmt.Printf("✓ Found %d AVRO schema files\n", schemaCount)
+	fmt.Printf("✓ Found %d schema files\n", schemaCount)
 	return nil
 }
+
+func validateGenerators(projectDir string) error {
+	generatorsFile, err := pipeline.LoadGeneratorsFile(projectDir)
+	if err != nil {
+		return err
+	}
+
+	schemas, err := pipeline.NewSchemaLoader(projectDir).LoadSchemas()
+	if err != nil {
+		return fmt.Errorf("failed to load schemas: %w", err)
+	}
+
+	if err := generatorsFile.Validate(schemas); err != nil {
+		return err
+	}
+	for key, schema := range schemas {
+		if count := countGenerators(schema, generatorsFile.SpecsFor(key, schema)); count > 0 {
+			fmt.Printf("✓ Field generators valid for schema %s (%d field(s))\n", key, count)
+		}
+	}
+
+	return nil
+}
+
+func countGenerators(schema *pipeline.Schema, specs map[string]*pipeline.GeneratorSpec) int {
+	count := len(specs)
+	for _, field := range schema.Fields {
+		if _, overridden := specs[field.Name]; field.Generator != nil && !overridden {
+			count++
+		}
+	}
+	return count
+}
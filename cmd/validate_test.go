@@ -124,6 +124,36 @@ func TestValidateSQLFiles(t *testing.T) {
 			wantErr: false, // Changed: validation doesn't check SQL syntax
 			cleanup: func(dir string) { _ = os.RemoveAll(dir) },
 		},
+		{
+			name: "undefined SQL variable",
+			setupFunc: func() string {
+				tmpDir, _ := os.MkdirTemp("", "pipegen-test-*")
+				sqlDir := filepath.Join(tmpDir, "sql")
+				_ = os.MkdirAll(sqlDir, 0755)
+				sql := `CREATE TABLE events (id BIGINT) WITH ('connector' = 'kafka', 'topic' = '${PIPEGEN_TEST_ENV}_events');`
+				_ = os.WriteFile(filepath.Join(sqlDir, "01_events.sql"), []byte(sql), 0644)
+				return tmpDir
+			},
+			wantErr: true,
+			cleanup: func(dir string) { _ = os.RemoveAll(dir) },
+		},
+		{
+			name: "SQL variable set with --set",
+			setupFunc: func() string {
+				tmpDir, _ := os.MkdirTemp("", "pipegen-test-*")
+				sqlDir := filepath.Join(tmpDir, "sql")
+				_ = os.MkdirAll(sqlDir, 0755)
+				sql := `CREATE TABLE events (id BIGINT) WITH ('connector' = 'kafka', 'topic' = '${PIPEGEN_TEST_ENV}_events');`
+				_ = os.WriteFile(filepath.Join(sqlDir, "01_events.sql"), []byte(sql), 0644)
+				sqlVariables = []string{"PIPEGEN_TEST_ENV=staging"}
+				return tmpDir
+			},
+			wantErr: false,
+			cleanup: func(dir string) {
+				sqlVariables = nil
+				_ = os.RemoveAll(dir)
+			},
+		},
 	}
 
 	for _, tt := range tests {
@@ -141,7 +171,7 @@ func TestValidateSQLFiles(t *testing.T) {
 	}
 }
 
-func TestValidateAVROSchemas(t *testing.T) {
+func TestValidateSchemas(t *testing.T) {
 	tests := []struct {
 		name      string
 		setupFunc func() string
@@ -187,6 +217,18 @@ func TestValidateAVROSchemas(t *testing.T) {
 			wantErr: false, // Changed: validation doesn't check JSON syntax
 			cleanup: func(dir string) { _ = os.RemoveAll(dir) },
 		},
+		{
+			name: "Protobuf schema",
+			setupFunc: func() string {
+				tmpDir, _ := os.MkdirTemp("", "pipegen-test-*")
+				schemasDir := filepath.Join(tmpDir, "schemas")
+				_ = os.MkdirAll(schemasDir, 0755)
+				_ = os.WriteFile(filepath.Join(schemasDir, "input.proto"), []byte(`syntax = "proto3"; message UserEvent { int64 id = 1; }`), 0644)
+				return tmpDir
+			},
+			wantErr: false,
+			cleanup: func(dir string) { _ = os.RemoveAll(dir) },
+		},
 	}
 
 	for _, tt := range tests {
@@ -194,7 +236,7 @@ func TestValidateAVROSchemas(t *testing.T) {
 			projectDir := tt.setupFunc()
 			defer tt.cleanup(projectDir)
 
-			err := validateAVROSchemas(projectDir)
+			err := validateSchemas(projectDir)
 			if tt.wantErr {
 				assert.Error(t, err)
 			} else {
@@ -63,7 +63,9 @@ export default defineConfig({
           { text: 'pipegen init', link: '/commands/init' },
           { text: 'pipegen run', link: '/commands/run' },
           { text: 'pipegen deploy', link: '/commands/deploy' },
-          { text: 'pipegen validate', link: '/commands/validate' }
+          { text: 'pipegen validate', link: '/commands/validate' },
+          { text: 'pipegen tail', link: '/commands/tail' },
+          { text: 'pipegen test', link: '/commands/test' }
         ]
       },
       {
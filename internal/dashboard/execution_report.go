@@ -68,6 +68,7 @@ type ExecutionParameters struct {
 	LocalMode         bool          `json:"local_mode"`
 	ProjectDir        string        `json:"project_dir"`
 	Cleanup           bool          `json:"cleanup"`
+	Seed              int64         `json:"seed"`
 }
 
 // ExecutionMetrics holds detailed metrics from the execution
@@ -11,6 +11,7 @@ import (
 	"time"
 
 	"github.com/segmentio/kafka-go"
+	"pipegen/internal/pipeline"
 	"pipegen/internal/types"
 )
 
@@ -445,6 +446,8 @@ func (mc *MetricsCollector) InitializeSQLStatements(statements []*types.SQLState
 	defer mc.metricsLock.Unlock()
 
 	for _, stmt := range statements {
+		// Built-in variables are only known once the run deploys the statement
+		processed, _ := pipeline.SubstituteVariables(stmt.Content, variables)
 		flinkStmt := &FlinkStatement{
 			ID:               generateStatementID(stmt.Name),
 			Name:             stmt.Name,
@@ -452,7 +455,7 @@ func (mc *MetricsCollector) InitializeSQLStatements(statements []*types.SQLState
 			Status:           "PENDING",
 			Phase:            "PREPARING",
 			Content:          stmt.Content,
-			ProcessedContent: "", // Will be set when processed
+			ProcessedContent: processed,
 			FilePath:         stmt.FilePath,
 			Variables:        make(map[string]string),
 		}
@@ -461,6 +464,7 @@ func (mc *MetricsCollector) InitializeSQLStatements(statements []*types.SQLState
 		for k, v := range variables {
 			flinkStmt.Variables[k] = v
 		}
+		flinkStmt.DescribeSQL()
 
 		mc.flinkMetrics.SQLStatements[stmt.Name] = flinkStmt
 	}
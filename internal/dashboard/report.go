@@ -514,6 +514,16 @@ func GenerateHTMLReport(status *PipelineStatus) (string, error) {
                                 <div class="metric-label">Avg Latency</div>
                                 <div class="metric-value">{{.ProducerMetrics.AverageLatency}}</div>
                             </div>
+                            {{if .ProducerMetrics.TargetRate}}
+                            <div class="metric-card">
+                                <div class="metric-label">Target Rate</div>
+                                <div class="metric-value">{{.ProducerMetrics.TargetRate | printf "%.1f"}} <span class="metric-unit">msgs/sec</span></div>
+                            </div>
+                            <div class="metric-card">
+                                <div class="metric-label">Latency p50 / p95 / p99</div>
+                                <div class="metric-value">{{.ProducerMetrics.P50Latency}} / {{.ProducerMetrics.P95Latency}} / {{.ProducerMetrics.P99Latency}}</div>
+                            </div>
+                            {{end}}
                         </div>
                     </div>
                     {{end}}
@@ -539,6 +549,34 @@ func GenerateHTMLReport(status *PipelineStatus) (string, error) {
                                 <div class="metric-value">{{.ConsumerMetrics.ProcessingTime}}</div>
                             </div>
                         </div>
+                        {{if .ConsumerMetrics.Sinks}}
+                        <table class="topic-table" style="margin-top: 1rem;">
+                            <thead>
+                                <tr>
+                                    <th>Sink</th>
+                                    <th>Topic</th>
+                                    <th>Messages</th>
+                                    <th>Expected</th>
+                                    <th>Size</th>
+                                    <th>Rate</th>
+                                    <th>Errors</th>
+                                </tr>
+                            </thead>
+                            <tbody>
+                                {{range .ConsumerMetrics.Sinks}}
+                                <tr>
+                                    <td>{{.Table}}</td>
+                                    <td>{{.Topic}}</td>
+                                    <td>{{.Messages | formatNumber}}</td>
+                                    <td>{{if .Expected}}{{.Expected | formatNumber}}{{else}}-{{end}}</td>
+                                    <td>{{.Bytes | formatBytes}}</td>
+                                    <td>{{.MessagesPerSec | printf "%.1f"}} msgs/sec</td>
+                                    <td>{{.Errors | formatNumber}}</td>
+                                </tr>
+                                {{end}}
+                            </tbody>
+                        </table>
+                        {{end}}
                     </div>
                     {{end}}
                 </div>
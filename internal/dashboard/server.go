@@ -14,6 +14,7 @@ import (
 
 	"github.com/gorilla/mux"
 	"github.com/gorilla/websocket"
+	"pipegen/internal/flinksql"
 	"pipegen/internal/pipeline"
 	"pipegen/internal/types"
 )
@@ -40,6 +41,8 @@ type DashboardServer struct {
 
 	// Pipeline state
 	pipelineStatus *PipelineStatus
+	endToEnd       *pipeline.LatencyStats // Measured produce-to-output latency, nil until output records were timed
+	dataQuality    *DataQualityMetrics    // Output validation results, nil without validation rules
 	statusMutex    sync.RWMutex
 }
 
@@ -125,6 +128,27 @@ type FlinkStatement struct {
 	ErrorMessage     string            `json:"error_message,omitempty"`
 	Dependencies     []string          `json:"dependencies"` // Names of statements this depends on
 	Variables        map[string]string `json:"variables"`
+	Type             string            `json:"type"`     // CREATE_TABLE, INSERT, CREATE_VIEW, QUERY or OTHER
+	Declares         []string          `json:"declares"` // Tables and views the statement creates
+	Reads            []string          `json:"reads"`    // Tables and views the statement reads
+	Writes           []string          `json:"writes"`   // Tables the statement writes to
+}
+
+// DescribeSQL sets the type of the statement and the tables it declares, reads and writes from its SQL
+func (s *FlinkStatement) DescribeSQL() {
+	s.Type = pipeline.StatementType(s.Content)
+	s.Declares, s.Reads, s.Writes = nil, nil, nil
+	statements, err := flinksql.Parse(s.Content)
+	if err != nil {
+		return
+	}
+	for _, stmt := range statements {
+		if name := stmt.Declares(); name != "" {
+			s.Declares = append(s.Declares, name)
+		}
+		s.Reads = append(s.Reads, stmt.Reads()...)
+		s.Writes = append(s.Writes, stmt.Writes()...)
+	}
 }
 
 // FlinkJob holds individual job metrics
@@ -173,20 +197,70 @@ type ProducerMetrics struct {
 	AverageLatency   time.Duration `json:"average_latency"`
 	BatchSize        int           `json:"batch_size"`
 	CompressionRatio float64       `json:"compression_ratio"`
+	TargetRate       float64       `json:"target_rate"`
+	P50Latency       time.Duration `json:"p50_latency"`
+	P95Latency       time.Duration `json:"p95_latency"`
+	P99Latency       time.Duration `json:"p99_latency"`
+}
+
+// ProducerMetricsFromStats converts live producer statistics into dashboard metrics
+func ProducerMetricsFromStats(stats *pipeline.ProducerStats) *ProducerMetrics {
+	metrics := &ProducerMetrics{
+		Status:         "RUNNING",
+		MessagesSent:   stats.MessagesSent,
+		BytesSent:      stats.BytesSent,
+		MessagesPerSec: stats.AchievedRate,
+		ErrorCount:     stats.ErrorCount,
+		SuccessRate:    100,
+		AverageLatency: stats.AverageLatency,
+		TargetRate:     stats.TargetRate,
+		P50Latency:     stats.LatencyP50,
+		P95Latency:     stats.LatencyP95,
+		P99Latency:     stats.LatencyP99,
+	}
+	if total := stats.MessagesSent + stats.ErrorCount; total > 0 {
+		metrics.SuccessRate = float64(stats.MessagesSent) / float64(total) * 100
+	}
+	if stats.MessagesSent > 0 {
+		metrics.BytesPerSec = stats.AchievedRate * float64(stats.BytesSent) / float64(stats.MessagesSent)
+	}
+	return metrics
 }
 
 // ConsumerMetrics holds consumer performance metrics
 type ConsumerMetrics struct {
-	Status           string        `json:"status"`
-	MessagesConsumed int64         `json:"messages_consumed"`
-	BytesConsumed    int64         `json:"bytes_consumed"`
-	MessagesPerSec   float64       `json:"messages_per_sec"`
-	BytesPerSec      float64       `json:"bytes_per_sec"`
-	ErrorCount       int64         `json:"error_count"`
-	CurrentOffset    int64         `json:"current_offset"`
-	Lag              int64         `json:"lag"`
-	CommitRate       float64       `json:"commit_rate"`
-	ProcessingTime   time.Duration `json:"processing_time"`
+	Status           string               `json:"status"`
+	MessagesConsumed int64                `json:"messages_consumed"`
+	BytesConsumed    int64                `json:"bytes_consumed"`
+	MessagesPerSec   float64              `json:"messages_per_sec"`
+	BytesPerSec      float64              `json:"bytes_per_sec"`
+	ErrorCount       int64                `json:"error_count"`
+	CurrentOffset    int64                `json:"current_offset"`
+	Lag              int64                `json:"lag"`
+	CommitRate       float64              `json:"commit_rate"`
+	ProcessingTime   time.Duration        `json:"processing_time"`
+	Sinks            []pipeline.SinkStats `json:"sinks,omitempty"` // Per output topic, primary sink first
+}
+
+// ConsumerMetricsFromStats converts live consumer statistics into dashboard metrics
+func ConsumerMetricsFromStats(stats *pipeline.ConsumerStats) *ConsumerMetrics {
+	metrics := &ConsumerMetrics{
+		Status:           "RUNNING",
+		MessagesConsumed: stats.MessagesConsumed,
+		BytesConsumed:    stats.BytesConsumed,
+		MessagesPerSec:   stats.MessagesPerSec,
+		ErrorCount:       stats.ErrorCount,
+		CurrentOffset:    stats.CurrentOffset,
+		Lag:              stats.LagMessages,
+		Sinks:            stats.Sinks,
+	}
+	if stats.MessagesConsumed > 0 {
+		metrics.BytesPerSec = stats.MessagesPerSec * float64(stats.BytesConsumed) / float64(stats.MessagesConsumed)
+	}
+	if stats.EndToEnd != nil {
+		metrics.ProcessingTime = stats.EndToEnd.Mean
+	}
+	return metrics
 }
 
 // ExecutionSummary holds pipeline execution summary
@@ -204,11 +278,31 @@ type ExecutionSummary struct {
 
 // DataQualityMetrics holds data validation metrics
 type DataQualityMetrics struct {
-	ValidRecords     int64   `json:"valid_records"`
-	InvalidRecords   int64   `json:"invalid_records"`
-	SchemaViolations int64   `json:"schema_violations"`
-	DuplicateRecords int64   `json:"duplicate_records"`
-	QualityScore     float64 `json:"quality_score"`
+	ValidRecords     int64                       `json:"valid_records"`
+	InvalidRecords   int64                       `json:"invalid_records"`
+	SchemaViolations int64                       `json:"schema_violations"`
+	DuplicateRecords int64                       `json:"duplicate_records"`
+	QualityScore     float64                     `json:"quality_score"`
+	RuleViolations   map[string]int64            `json:"rule_violations,omitempty"` // Violations per validation rule
+	Samples          []pipeline.ValidationSample `json:"samples,omitempty"`         // Offending output records
+}
+
+// DataQualityFromValidation converts output validation results into data quality metrics. Missing
+// fields and wrong types count as schema violations, unique checks as duplicates.
+func DataQualityFromValidation(report *pipeline.ValidationReport) *DataQualityMetrics {
+	metrics := &DataQualityMetrics{
+		ValidRecords:     report.Valid,
+		InvalidRecords:   report.Invalid,
+		SchemaViolations: report.KindViolations[pipeline.CheckRequired] + report.KindViolations[pipeline.CheckType],
+		DuplicateRecords: report.KindViolations[pipeline.CheckUnique],
+		QualityScore:     report.QualityScore(),
+		RuleViolations:   make(map[string]int64, len(report.Rules)),
+		Samples:          report.Samples,
+	}
+	for _, rule := range report.Rules {
+		metrics.RuleViolations[rule.Rule] = rule.Violations
+	}
+	return metrics
 }
 
 // PerformanceMetrics holds performance insights
@@ -362,6 +456,64 @@ func (ds *DashboardServer) SetStatementDependencies(dependencies map[string][]st
 	ds.metricsCollector.SetStatementDependencies(dependencies)
 }
 
+// UpdateProducerMetrics replaces the producer metrics of the current pipeline status
+func (ds *DashboardServer) UpdateProducerMetrics(metrics *ProducerMetrics) {
+	ds.statusMutex.Lock()
+	defer ds.statusMutex.Unlock()
+	if ds.pipelineStatus == nil {
+		return
+	}
+	ds.pipelineStatus.ProducerMetrics = metrics
+	ds.pipelineStatus.LastUpdated = time.Now()
+}
+
+// UpdateConsumerMetrics replaces the consumer metrics of the current pipeline status
+func (ds *DashboardServer) UpdateConsumerMetrics(metrics *ConsumerMetrics) {
+	ds.statusMutex.Lock()
+	defer ds.statusMutex.Unlock()
+	if ds.pipelineStatus == nil {
+		return
+	}
+	ds.pipelineStatus.ConsumerMetrics = metrics
+	ds.pipelineStatus.LastUpdated = time.Now()
+}
+
+// UpdateEndToEndLatency reports the measured produce-to-output latency; it replaces estimates in the execution summary
+func (ds *DashboardServer) UpdateEndToEndLatency(stats *pipeline.LatencyStats) {
+	ds.statusMutex.Lock()
+	defer ds.statusMutex.Unlock()
+	ds.endToEnd = stats
+	if ds.pipelineStatus != nil && ds.pipelineStatus.ExecutionSummary != nil {
+		ds.applyEndToEndLatency(ds.pipelineStatus.ExecutionSummary)
+		ds.pipelineStatus.LastUpdated = time.Now()
+	}
+}
+
+// UpdateDataQuality reports the output validation results; they replace the data quality of the execution summary
+func (ds *DashboardServer) UpdateDataQuality(metrics *DataQualityMetrics) {
+	ds.statusMutex.Lock()
+	defer ds.statusMutex.Unlock()
+	ds.dataQuality = metrics
+	if ds.pipelineStatus != nil && ds.pipelineStatus.ExecutionSummary != nil {
+		ds.pipelineStatus.ExecutionSummary.DataQuality = metrics
+		ds.pipelineStatus.LastUpdated = time.Now()
+	}
+}
+
+// applyEndToEndLatency copies the measured latency into a summary; the caller holds statusMutex
+func (ds *DashboardServer) applyEndToEndLatency(summary *ExecutionSummary) {
+	if ds.endToEnd == nil {
+		return
+	}
+	summary.AverageLatency = ds.endToEnd.Mean
+	if summary.Performance == nil {
+		summary.Performance = &PerformanceMetrics{}
+	}
+	summary.Performance.P50Latency = ds.endToEnd.P50
+	summary.Performance.P95Latency = ds.endToEnd.P95
+	summary.Performance.P99Latency = ds.endToEnd.P99
+}
+
 // UpdatePipelineStatus updates the pipeline status with thread safety
 func (ds *DashboardServer) UpdatePipelineStatus(status *PipelineStatus) {
 	ds.statusMutex.Lock()
@@ -445,6 +597,10 @@ func (ds *DashboardServer) calculateExecutionSummary(status *PipelineStatus) {
 		summary.ErrorRate = 1.5
 		summary.AverageLatency = 15 * time.Millisecond
 	}
+	ds.applyEndToEndLatency(summary)
+	if ds.dataQuality != nil {
+		summary.DataQuality = ds.dataQuality
+	}
 }
 
 // handleStatus returns current pipeline status as JSON
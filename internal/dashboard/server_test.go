@@ -0,0 +1,72 @@
+package dashboard
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"pipegen/internal/pipeline"
+)
+
+func TestDataQualityFromValidation(t *testing.T) {
+	report := &pipeline.ValidationReport{
+		Topic:   "run-paid-orders",
+		Checked: 100,
+		Valid:   90,
+		Invalid: 10,
+		Rules: []pipeline.RuleViolations{
+			{Rule: "order_id required", Kind: pipeline.CheckRequired, Violations: 3},
+			{Rule: "amount is number", Kind: pipeline.CheckType, Violations: 2},
+			{Rule: "order_id unique", Kind: pipeline.CheckUnique, Violations: 4},
+			{Rule: "window_end > window_start", Kind: pipeline.CheckExpr, Violations: 1},
+		},
+		Samples: []pipeline.ValidationSample{{Rule: "order_id required", Reason: "missing or null", Offset: 7}},
+		KindViolations: map[string]int64{
+			pipeline.CheckRequired: 3,
+			pipeline.CheckType:     2,
+			pipeline.CheckUnique:   4,
+			pipeline.CheckExpr:     1,
+		},
+	}
+
+	metrics := DataQualityFromValidation(report)
+	assert.Equal(t, int64(90), metrics.ValidRecords)
+	assert.Equal(t, int64(10), metrics.InvalidRecords)
+	assert.Equal(t, int64(5), metrics.SchemaViolations)
+	assert.Equal(t, int64(4), metrics.DuplicateRecords)
+	assert.Equal(t, 90.0, metrics.QualityScore)
+	assert.Equal(t, int64(1), metrics.RuleViolations["window_end > window_start"])
+	assert.Len(t, metrics.Samples, 1)
+}
+
+func TestConsumerMetricsFromStats(t *testing.T) {
+	stats := &pipeline.ConsumerStats{
+		MessagesConsumed: 300,
+		MessagesPerSec:   10,
+		BytesConsumed:    30000,
+		ErrorCount:       2,
+		Sinks: []pipeline.SinkStats{
+			{Table: "totals", Topic: "run-totals", Expected: 200, Messages: 200, Bytes: 20000},
+			{Table: "alerts", Topic: "run-alerts", Messages: 100, Bytes: 10000, Errors: 2},
+		},
+	}
+
+	metrics := ConsumerMetricsFromStats(stats)
+	assert.Equal(t, "RUNNING", metrics.Status)
+	assert.Equal(t, int64(300), metrics.MessagesConsumed)
+	assert.Equal(t, 1000.0, metrics.BytesPerSec)
+	assert.Equal(t, int64(2), metrics.ErrorCount)
+	assert.Len(t, metrics.Sinks, 2)
+	assert.Equal(t, "alerts", metrics.Sinks[1].Table)
+}
+
+func TestFlinkStatementDescribeSQL(t *testing.T) {
+	stmt := &FlinkStatement{Content: `CREATE TABLE paid_orders (order_id STRING) WITH ('connector' = 'kafka', 'topic' = 'paid');
+INSERT INTO paid_orders SELECT o.order_id FROM orders o JOIN payments p ON o.order_id = p.order_id`}
+	stmt.DescribeSQL()
+
+	assert.Equal(t, "CREATE_TABLE", stmt.Type)
+	assert.Equal(t, []string{"paid_orders"}, stmt.Declares)
+	assert.Equal(t, []string{"orders", "payments"}, stmt.Reads)
+	assert.Equal(t, []string{"paid_orders"}, stmt.Writes)
+}
@@ -4,7 +4,6 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"io"
 	"net/http"
 	"os"
 	"os/exec"
@@ -12,6 +11,8 @@ import (
 	"strings"
 	"time"
 
+	"pipegen/internal/flinkgateway"
+	"pipegen/internal/flinksql"
 	"pipegen/internal/pipeline"
 	"pipegen/internal/types"
 
@@ -26,6 +27,7 @@ type StackDeployer struct {
 	flinkAddr          string
 	schemaRegistryAddr string
 	sqlGatewayAddr     string
+	variables          *pipeline.Variables
 }
 
 // NewStackDeployer creates a new stack deployer
@@ -69,6 +71,11 @@ func NewStackDeployer(projectDir string) *StackDeployer {
 	}
 }
 
+// SetVariables sets the user-defined SQL variables resolved in the statements
+func (d *StackDeployer) SetVariables(variables *pipeline.Variables) {
+	d.variables = variables
+}
+
 // SetupTopicsAndSchemas creates topics and registers schemas
 func (d *StackDeployer) SetupTopicsAndSchemas(ctx context.Context, withSchemaRegistry bool) error {
 	// Load project configuration
@@ -78,6 +85,12 @@ func (d *StackDeployer) SetupTopicsAndSchemas(ctx context.Context, withSchemaReg
 		return fmt.Errorf("failed to load SQL statements: %w", err)
 	}
 
+	// Topics may be named by variables
+	statements, err = d.processStatementsForLocal(statements)
+	if err != nil {
+		return err
+	}
+
 	schemaLoader := pipeline.NewSchemaLoader(d.projectDir)
 	schemas, err := schemaLoader.LoadSchemas()
 	if err != nil {
@@ -111,13 +124,28 @@ func (d *StackDeployer) DeployFlinkJobs(ctx context.Context) error {
 	}
 
 	// Process SQL statements for local deployment
-	processedStatements := d.processStatementsForLocal(statements)
+	processedStatements, err := d.processStatementsForLocal(statements)
+	if err != nil {
+		return err
+	}
+
+	// One session for all statements, so SET, USE and CREATE FUNCTION carry over to the statements after them
+	gateway := flinkgateway.NewClient(d.sqlGatewayAddr)
+	sessionID, err := gateway.OpenSession(ctx, "pipegen-deploy-session", nil)
+	if err != nil {
+		fmt.Printf("  ⚠️  Failed to create Flink session, trying fallback method: %v\n", err)
+	}
 
 	// Deploy each statement via Flink SQL Gateway
 	for _, stmt := range processedStatements {
 		fmt.Printf("📝 Deploying FlinkSQL job: %s\n", stmt.Name)
 
-		if err := d.deployFlinkStatement(ctx, stmt); err != nil {
+		if sessionID == "" {
+			err = d.deployViaRESTAPI(stmt)
+		} else {
+			err = d.deployFlinkStatement(ctx, gateway, sessionID, stmt)
+		}
+		if err != nil {
 			return fmt.Errorf("failed to deploy statement %s: %w", stmt.Name, err)
 		}
 
@@ -135,27 +163,10 @@ func (d *StackDeployer) extractTopicNames(statements []*types.SQLStatement) []st
 	topics["input-events"] = true
 	topics["output-results"] = true
 
-	// Extract topics from SQL statements
-	for _, stmt := range statements {
-		content := strings.ToUpper(stmt.Content)
-
-		// Look for topic references in WITH clauses
-		if strings.Contains(content, "'TOPIC'") {
-			// This is a simplified extraction - in production, you'd want
-			// more robust SQL parsing
-			lines := strings.Split(stmt.Content, "\n")
-			for _, line := range lines {
-				if strings.Contains(strings.ToUpper(line), "'TOPIC'") &&
-					strings.Contains(line, "=") {
-					parts := strings.Split(line, "=")
-					if len(parts) > 1 {
-						topic := strings.Trim(parts[1], " '\"(),")
-						if topic != "" && !strings.Contains(topic, "$") {
-							topics[topic] = true
-						}
-					}
-				}
-			}
+	// Topics of the tables declared in the SQL, with their variables resolved
+	for _, topic := range pipeline.ExtractTopics(statements) {
+		if !strings.Contains(topic, "$") {
+			topics[topic] = true
 		}
 	}
 
@@ -235,7 +246,7 @@ func (d *StackDeployer) createKafkaTopics(ctx context.Context, topics []string)
 	return fmt.Errorf("failed to create topics after 5 attempts")
 }
 
-// registerSchemas registers AVRO schemas in Schema Registry
+// registerSchemas registers the project's schemas in Schema Registry
 func (d *StackDeployer) registerSchemas(ctx context.Context, schemas map[string]*pipeline.Schema, topics []string) error {
 	client := &http.Client{Timeout: 10 * time.Second}
 
@@ -250,8 +261,9 @@ func (d *StackDeployer) registerSchemas(ctx context.Context, schemas map[string]
 
 		fmt.Printf("  ✅ Value schema registered: %s\n", valueSubject)
 
-		// For output schema, also register key schema for upsert operations
-		if name == "output" {
+		// For output schema, also register key schema for upsert operations.
+		// The input schema gets one too when the producer is configured to send keyed messages.
+		if name == "output" || (name == "input" && len(viper.GetStringSlice("key_strategy.fields")) > 0) {
 			keySubject := d.getKeySchemaSubject(name, topics)
 			keySchema := d.createKeySchema(schema)
 			fmt.Printf("📋 Registering key schema: %s -> %s\n", name, keySubject)
@@ -293,6 +305,14 @@ func (d *StackDeployer) getKeySchemaSubject(schemaName string, topics []string)
 
 // createKeySchema creates a key schema from a value schema
 func (d *StackDeployer) createKeySchema(valueSchema *pipeline.Schema) *pipeline.Schema {
+	// Derive the key from the configured key fields when the value schema has them,
+	// so it matches the key schema the producer encodes with
+	if fields := viper.GetStringSlice("key_strategy.fields"); len(fields) > 0 {
+		if keySchema, err := pipeline.NewKeySchema(valueSchema, fields); err == nil {
+			return keySchema
+		}
+	}
+
 	// For simplicity, create a basic key schema with just the name field
 	// In a production environment, you'd want to parse the original schema
 	// and extract only the key fields
@@ -318,7 +338,10 @@ func (d *StackDeployer) createKeySchema(valueSchema *pipeline.Schema) *pipeline.
 func (d *StackDeployer) registerSchema(client *http.Client, subject string, schema *pipeline.Schema) error {
 	// Create registration payload
 	payload := map[string]interface{}{
-		"schema": schema.Content,
+		"schema": schema.RegistryDefinition(),
+	}
+	if schemaType := string(schema.RegistryType()); schemaType != "AVRO" {
+		payload["schemaType"] = schemaType
 	}
 
 	payloadBytes, err := json.Marshal(payload)
@@ -341,138 +364,83 @@ func (d *StackDeployer) registerSchema(client *http.Client, subject string, sche
 	return nil
 }
 
-// processStatementsForLocal processes SQL statements for local deployment
-func (d *StackDeployer) processStatementsForLocal(statements []*types.SQLStatement) []*types.SQLStatement {
+// processStatementsForLocal resolves the variables of SQL statements with local values and drops authentication options
+func (d *StackDeployer) processStatementsForLocal(statements []*types.SQLStatement) ([]*types.SQLStatement, error) {
 	processed := make([]*types.SQLStatement, len(statements))
 
 	for i, stmt := range statements {
-		content := stmt.Content
-
 		// Replace variables with local values
-		replacements := map[string]string{
-			"${INPUT_TOPIC}":         "input-events",
-			"${OUTPUT_TOPIC}":        "output-results",
-			"${BOOTSTRAP_SERVERS}":   "kafka:29092",
-			"${SCHEMA_REGISTRY_URL}": "http://schema-registry:8082",
+		builtins := map[string]string{
+			"INPUT_TOPIC":         "input-events",
+			"OUTPUT_TOPIC":        "output-results",
+			"BOOTSTRAP_SERVERS":   "kafka:29092",
+			"SCHEMA_REGISTRY_URL": "http://schema-registry:8082",
 		}
-
-		for placeholder, value := range replacements {
-			content = strings.ReplaceAll(content, placeholder, value)
-		}
-
-		// Remove authentication settings for local deployment
-		lines := strings.Split(content, "\n")
-		var cleanLines []string
-
-		for _, line := range lines {
-			if strings.Contains(strings.ToUpper(line), "SASL") ||
-				strings.Contains(strings.ToUpper(line), "SECURITY.PROTOCOL") ||
-				strings.Contains(strings.ToUpper(line), "BASIC-AUTH") {
-				continue
-			}
-			cleanLines = append(cleanLines, line)
+		content, missing := d.variables.Resolve(stmt.Content, builtins)
+		if len(missing) > 0 {
+			return nil, fmt.Errorf("%s: undefined variables: %s", stmt.Name, strings.Join(missing, ", "))
 		}
 
 		processed[i] = &types.SQLStatement{
 			Name:     stmt.Name,
-			Content:  strings.Join(cleanLines, "\n"),
+			Content:  removeAuthOptions(content),
 			FilePath: stmt.FilePath,
 			Order:    stmt.Order,
+			Lines:    stmt.Lines,
 		}
 	}
 
-	return processed
+	return processed, nil
 }
 
-// deployFlinkStatement deploys a single FlinkSQL statement
-func (d *StackDeployer) deployFlinkStatement(ctx context.Context, stmt *types.SQLStatement) error {
-	client := &http.Client{Timeout: 30 * time.Second}
-
-	// Create a session first
-	sessionID, err := d.createFlinkSession(ctx, client)
-	if err != nil {
-		fmt.Printf("  ⚠️  Failed to create Flink session, trying fallback method: %v\n", err)
-		return d.deployViaRESTAPI(client, stmt)
-	}
-
-	// Create SQL job submission payload
-	payload := map[string]interface{}{
-		"statement": stmt.Content,
-	}
-
-	payloadBytes, err := json.Marshal(payload)
+// removeAuthOptions removes the authentication options of the tables in sql, which the local stack does not use
+func removeAuthOptions(sql string) string {
+	statements, err := flinksql.Parse(sql)
 	if err != nil {
-		return fmt.Errorf("failed to marshal SQL statement: %w", err)
+		return sql
 	}
-
-	// Submit job via Flink SQL Gateway using the created session
-	url := fmt.Sprintf("%s/v1/sessions/%s/statements", d.sqlGatewayAddr, sessionID)
-	resp, err := client.Post(url, "application/json", strings.NewReader(string(payloadBytes)))
-	if err != nil {
-		// Fallback: try REST API if SQL Gateway is not available
-		return d.deployViaRESTAPI(client, stmt)
-	}
-	defer func() { _ = resp.Body.Close() }()
-
-	if resp.StatusCode >= 400 {
-		return fmt.Errorf("FlinkSQL deployment failed with status %d", resp.StatusCode)
-	}
-
-	return nil
-}
-
-// createFlinkSession creates a new Flink SQL Gateway session
-func (d *StackDeployer) createFlinkSession(ctx context.Context, client *http.Client) (string, error) {
-	sessionEndpoint := fmt.Sprintf("%s/v1/sessions", d.sqlGatewayAddr)
-	sessionReqBody := `{"sessionName": "pipegen-deploy-session", "properties": {}}`
-
-	sessionReq, err := http.NewRequestWithContext(ctx, "POST", sessionEndpoint, strings.NewReader(sessionReqBody))
-	if err != nil {
-		return "", fmt.Errorf("failed to create session request: %w", err)
-	}
-	sessionReq.Header.Set("Content-Type", "application/json")
-
-	sessionResp, err := client.Do(sessionReq)
-	if err != nil {
-		return "", fmt.Errorf("failed to create session: %w", err)
-	}
-	defer func() { _ = sessionResp.Body.Close() }()
-
-	sessionBody, err := io.ReadAll(sessionResp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read session response: %w", err)
-	}
-
-	if sessionResp.StatusCode != 200 {
-		return "", fmt.Errorf("session creation failed with status %d: %s", sessionResp.StatusCode, string(sessionBody))
-	}
-
-	sessionID := d.extractSessionID(string(sessionBody))
-	if sessionID == "" {
-		return "", fmt.Errorf("could not extract session ID from response: %s", string(sessionBody))
+	rewriter := flinksql.NewRewriter(sql)
+	for _, stmt := range statements {
+		if stmt.Table == nil {
+			continue
+		}
+		for _, option := range stmt.Table.Options {
+			key := strings.ToLower(option.Key)
+			if strings.Contains(key, "sasl") || strings.Contains(key, "security.protocol") || strings.Contains(key, "basic-auth") {
+				rewriter.RemoveOption(stmt.Table, option.Key)
+			}
+		}
 	}
-
-	fmt.Printf("[Flink SQL Gateway] Session creation response: %s\n", string(sessionBody))
-	return sessionID, nil
+	return rewriter.String()
 }
 
-// extractSessionID extracts session ID from Flink SQL Gateway response
-func (d *StackDeployer) extractSessionID(resp string) string {
-	// Look for "sessionHandle":"..."
-	idx := strings.Index(resp, "\"sessionHandle\":\"")
-	if idx == -1 {
-		return ""
-	}
-	start := idx + len("\"sessionHandle\":\"")
-	end := strings.Index(resp[start:], "\"")
-	if end == -1 {
-		return ""
+// deployFlinkStatement runs the statements of a statement file in a Flink SQL Gateway session in order,
+// each once the one before it has finished. Session statements like SET and USE configure the session.
+// If the gateway cannot be reached even after the client's retries, the file is saved for manual
+// execution instead, as when no session could be opened.
+func (d *StackDeployer) deployFlinkStatement(ctx context.Context, gateway *flinkgateway.Client, sessionID string, stmt *types.SQLStatement) error {
+	for _, part := range pipeline.SplitStatement(stmt) {
+		execCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		var err error
+		if part.Session {
+			err = gateway.ConfigureSession(execCtx, sessionID, part.SQL)
+		} else {
+			_, err = gateway.Execute(execCtx, sessionID, part.SQL)
+		}
+		cancel()
+		if flinkgateway.IsConnectionError(err) {
+			// Fallback: save the statement if the SQL Gateway has become unreachable
+			return d.deployViaRESTAPI(stmt)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", part.Location, err)
+		}
 	}
-	return resp[start : start+end]
+	return nil
 }
 
 // deployViaRESTAPI deploys via Flink's REST API (fallback method)
-func (d *StackDeployer) deployViaRESTAPI(client *http.Client, stmt *types.SQLStatement) error {
+func (d *StackDeployer) deployViaRESTAPI(stmt *types.SQLStatement) error {
 	// For now, we'll create a simple JAR file that contains the SQL statement
 	// In a production environment, you'd want to create a proper Flink job
 
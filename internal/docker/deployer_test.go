@@ -1,7 +1,15 @@
 package docker
 
 import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"pipegen/internal/flinkgateway"
+	"pipegen/internal/types"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -86,3 +94,38 @@ func TestDeployer_CheckDockerAvailability(t *testing.T) {
 		assert.IsType(t, true, available)
 	})
 }
+
+func TestRemoveAuthOptions(t *testing.T) {
+	sql := `CREATE TABLE orders (id STRING) WITH (
+  'connector' = 'kafka',
+  'properties.security.protocol' = 'SASL_SSL',
+  'properties.sasl.jaas.config' = 'org.apache.kafka.common.security.plain.PlainLoginModule required;',
+  'topic' = 'orders',
+  'avro-confluent.basic-auth.user-info' = 'key:secret'
+)`
+	want := `CREATE TABLE orders (id STRING) WITH (
+  'connector' = 'kafka',
+  'topic' = 'orders'
+)`
+	assert.Equal(t, want, removeAuthOptions(sql))
+
+	unparsable := "CREATE TABLE orders (id STRING"
+	assert.Equal(t, unparsable, removeAuthOptions(unparsable))
+}
+
+func TestDeployFlinkStatementFallback(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	gatewayURL := server.URL
+	server.Close()
+
+	gateway := flinkgateway.NewClient(gatewayURL)
+	gateway.Backoff = flinkgateway.Backoff{Initial: time.Millisecond, Max: time.Millisecond, Multiplier: 1}
+	d := &StackDeployer{projectDir: t.TempDir()}
+	stmt := &types.SQLStatement{Name: "01_orders", Content: "CREATE TABLE orders (id STRING);"}
+
+	// An unreachable gateway saves the statement instead of failing the deployment
+	assert.NoError(t, d.deployFlinkStatement(context.Background(), gateway, "session-1", stmt))
+	saved, err := os.ReadFile(filepath.Join(d.projectDir, "deployed-sql", "01_orders.sql"))
+	assert.NoError(t, err)
+	assert.Equal(t, stmt.Content, string(saved))
+}
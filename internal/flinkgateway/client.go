@@ -0,0 +1,157 @@
+// Package flinkgateway is a client for the REST API of the Flink SQL Gateway: sessions, statements,
+// the operations that run them and their paged results. It also lists and cancels the jobs the
+// statements submit, through the REST API of the JobManager.
+//
+// Requests that fail on a connection error or an unavailable gateway are retried with backoff, as
+// long as repeating them cannot run a statement twice. Errors the gateway reports come back as an
+// *Error carrying the server-side stack trace.
+package flinkgateway
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+)
+
+// Backoff spaces the attempts of retries and status polling: the first wait is Initial, and every
+// following one is Multiplier times longer, up to Max
+type Backoff struct {
+	Initial    time.Duration
+	Max        time.Duration
+	Multiplier float64
+}
+
+// DefaultBackoff polls quickly while short statements finish and settles at two seconds for long ones
+var DefaultBackoff = Backoff{Initial: 100 * time.Millisecond, Max: 2 * time.Second, Multiplier: 2}
+
+// Delay returns the wait before the attempt following attempt (0 for the first retry)
+func (b Backoff) Delay(attempt int) time.Duration {
+	delay := float64(b.Initial)
+	for i := 0; i < attempt && delay < float64(b.Max); i++ {
+		delay *= b.Multiplier
+	}
+	if delay > float64(b.Max) {
+		return b.Max
+	}
+	return time.Duration(delay)
+}
+
+// Client talks to one SQL Gateway, or to one JobManager for the jobs calls
+type Client struct {
+	BaseURL    string       // e.g. http://localhost:8083
+	HTTPClient *http.Client // Timeout of each request
+	Backoff    Backoff      // Between retries and status polls
+	MaxRetries int          // Retries of a request failing on a transient error
+}
+
+// NewClient creates a client for the gateway at baseURL
+func NewClient(baseURL string) *Client {
+	return &Client{
+		BaseURL:    strings.TrimRight(baseURL, "/"),
+		HTTPClient: &http.Client{Timeout: 30 * time.Second},
+		Backoff:    DefaultBackoff,
+		MaxRetries: 3,
+	}
+}
+
+// do sends a request with an optional JSON body and decodes the JSON response into out, if not nil.
+// Idempotent requests are retried on connection errors and on 502, 503 and 504 responses; the others
+// only when the connection was refused, so the gateway never saw them.
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = json.Marshal(body); err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+	}
+	idempotent := method == http.MethodGet || method == http.MethodDelete
+
+	for attempt := 0; ; attempt++ {
+		err := c.send(ctx, method, path, payload, out)
+		if err == nil || attempt >= c.MaxRetries || ctx.Err() != nil {
+			return err
+		}
+		if !errors.Is(err, syscall.ECONNREFUSED) && !(idempotent && isTransient(err)) {
+			return err
+		}
+		if err := sleep(ctx, c.Backoff.Delay(attempt)); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Client) send(ctx context.Context, method, path string, payload []byte, out interface{}) error {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.BaseURL+path, body)
+	if err != nil {
+		return err
+	}
+	if payload != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s %s: %w", method, path, err)
+	}
+	defer func() { _ = resp.Body.Close() }()
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return fmt.Errorf("%s %s: failed to read response: %w", method, path, err)
+	}
+	if resp.StatusCode >= 300 {
+		return newError(method, path, resp.StatusCode, data)
+	}
+	if out == nil || len(bytes.TrimSpace(data)) == 0 {
+		return nil
+	}
+	if err := json.Unmarshal(data, out); err != nil {
+		return fmt.Errorf("%s %s: unexpected response %q: %w", method, path, string(data), err)
+	}
+	return nil
+}
+
+// isTransient reports whether a failed request may succeed when repeated
+func isTransient(err error) bool {
+	var gatewayErr *Error
+	if errors.As(err, &gatewayErr) {
+		switch gatewayErr.StatusCode {
+		case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+			return true
+		}
+		return false
+	}
+	return !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// IsConnectionError reports whether a request failed because the server could not be reached, even
+// after the retries, rather than because it answered with an error or ctx ended
+func IsConnectionError(err error) bool {
+	var urlErr *url.Error
+	return errors.As(err, &urlErr) && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded)
+}
+
+// sleep waits for d, or until ctx is done
+func sleep(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
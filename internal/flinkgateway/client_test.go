@@ -0,0 +1,393 @@
+package flinkgateway
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const stackTrace = `<Exception on server side:
+org.apache.flink.table.gateway.api.utils.SqlGatewayException: Failed to fetchResults.
+	at org.apache.flink.table.gateway.rest.handler.statement.FetchResultsHandler.handleRequest(FetchResultsHandler.java:85)
+Caused by: org.apache.flink.table.gateway.service.utils.SqlExecutionException: Failed to execute the operation op-1.
+	at org.apache.flink.table.gateway.service.operation.OperationManager$Operation.processThrowable(OperationManager.java:414)
+Caused by: org.apache.flink.table.api.ValidationException: Object 'missing' not found
+	at org.apache.flink.table.planner.calcite.FlinkPlannerImpl.validate(FlinkPlannerImpl.scala:187)
+
+End of exception on server side>`
+
+// fakeGateway stands in for the SQL Gateway. Operations go through their statuses one poll at a
+// time; failed operations report the stack trace when their result is fetched.
+type fakeGateway struct {
+	mu         sync.Mutex
+	sessions   map[string]map[string]string
+	statements []string
+	statuses   map[string][]string // Operation → statuses still to report; the last one stays
+	pages      map[string][]string // Result path → JSON of the page
+	unready    int                 // Responses of 503 before the gateway is ready
+	requests   []string
+}
+
+func newFakeGateway(t *testing.T) (*fakeGateway, *Client) {
+	g := &fakeGateway{
+		sessions: make(map[string]map[string]string),
+		statuses: make(map[string][]string),
+		pages:    make(map[string][]string),
+	}
+	server := httptest.NewServer(g)
+	t.Cleanup(server.Close)
+
+	client := NewClient(server.URL + "/")
+	client.Backoff = Backoff{Initial: time.Millisecond, Max: 5 * time.Millisecond, Multiplier: 2}
+	return g, client
+}
+
+func (g *fakeGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	g.requests = append(g.requests, r.Method+" "+r.URL.Path)
+
+	if g.unready > 0 {
+		g.unready--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+
+	var body map[string]interface{}
+	_ = json.NewDecoder(r.Body).Decode(&body)
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	reply := func(v interface{}) { _ = json.NewEncoder(w).Encode(v) }
+
+	switch {
+	case r.Method == "POST" && r.URL.Path == "/v1/sessions":
+		handle := fmt.Sprintf("session-%d", len(g.sessions)+1)
+		properties := make(map[string]string)
+		requested, _ := body["properties"].(map[string]interface{})
+		for key, value := range requested {
+			properties[key] = value.(string)
+		}
+		properties["name"] = body["sessionName"].(string)
+		g.sessions[handle] = properties
+		reply(map[string]string{"sessionHandle": handle})
+	case len(parts) >= 3 && g.sessions[parts[2]] == nil:
+		w.WriteHeader(http.StatusNotFound)
+		reply(map[string][]string{"errors": {"Session '" + parts[2] + "' does not exist."}})
+	case r.Method == "GET" && len(parts) == 3:
+		reply(map[string]interface{}{"properties": g.sessions[parts[2]]})
+	case r.Method == "POST" && parts[len(parts)-1] == "configure-session":
+		key, value, _ := strings.Cut(strings.TrimPrefix(body["statement"].(string), "SET "), " = ")
+		g.sessions[parts[2]][strings.Trim(key, "'")] = strings.Trim(value, "'")
+		reply(struct{}{})
+	case r.Method == "POST" && parts[len(parts)-1] == "heartbeat":
+		reply(struct{}{})
+	case r.Method == "DELETE" && len(parts) == 3:
+		delete(g.sessions, parts[2])
+		reply(map[string]string{"status": "CLOSED"})
+	case r.Method == "POST" && parts[len(parts)-1] == "statements":
+		g.statements = append(g.statements, body["statement"].(string))
+		reply(map[string]string{"operationHandle": fmt.Sprintf("op-%d", len(g.statements))})
+	case r.Method == "GET" && parts[len(parts)-1] == "status":
+		statuses := g.statuses[parts[4]]
+		if len(statuses) > 1 {
+			g.statuses[parts[4]] = statuses[1:]
+		}
+		reply(map[string]string{"status": statuses[0]})
+	case r.Method == "POST" && parts[len(parts)-1] == "cancel":
+		g.statuses[parts[4]] = []string{StatusCanceled}
+		reply(map[string]string{"status": StatusCanceled})
+	case r.Method == "GET" && parts[len(parts)-2] == "result":
+		if statuses := g.statuses[parts[4]]; statuses[len(statuses)-1] == StatusError {
+			w.WriteHeader(http.StatusInternalServerError)
+			reply(map[string][]string{"errors": {"Internal server error.", stackTrace}})
+			return
+		}
+		pages := g.pages[r.URL.Path]
+		if len(pages) > 1 {
+			g.pages[r.URL.Path] = pages[1:]
+		}
+		_, _ = w.Write([]byte(pages[0]))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (g *fakeGateway) requested(prefix string) int {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	count := 0
+	for _, request := range g.requests {
+		if strings.HasPrefix(request, prefix) {
+			count++
+		}
+	}
+	return count
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	_, client := newFakeGateway(t)
+	ctx := context.Background()
+
+	session, err := client.OpenSession(ctx, "pipegen", map[string]string{"execution.runtime-mode": "streaming"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := client.ConfigureSession(ctx, session, "SET 'pipeline.name' = 'orders'"); err != nil {
+		t.Fatal(err)
+	}
+	if err := client.Heartbeat(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+	config, err := client.SessionConfig(ctx, session)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]string{"name": "pipegen", "execution.runtime-mode": "streaming", "pipeline.name": "orders"}
+	if !reflect.DeepEqual(config, want) {
+		t.Errorf("session config = %v", config)
+	}
+
+	if err := client.CloseSession(ctx, session); err != nil {
+		t.Fatal(err)
+	}
+	err = client.Heartbeat(ctx, session)
+	var gatewayErr *Error
+	if !errors.As(err, &gatewayErr) || gatewayErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("heartbeat of a closed session: %v", err)
+	}
+	if want := "POST /v1/sessions/session-1/heartbeat returned 404: Session 'session-1' does not exist."; err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+}
+
+func TestExecute(t *testing.T) {
+	g, client := newFakeGateway(t)
+	ctx := context.Background()
+	session, err := client.OpenSession(ctx, "pipegen", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g.statuses["op-1"] = []string{StatusPending, StatusRunning, StatusRunning, StatusFinished}
+	operation, err := client.Execute(ctx, session, "CREATE TABLE orders (id STRING)")
+	if err != nil || operation != "op-1" {
+		t.Fatalf("Execute() = %q, %v", operation, err)
+	}
+	if polls := g.requested("GET /v1/sessions/session-1/operations/op-1/status"); polls != 4 {
+		t.Errorf("polled the status %d times, want 4", polls)
+	}
+
+	g.statuses["op-2"] = []string{StatusRunning, StatusError}
+	_, err = client.Execute(ctx, session, "INSERT INTO totals SELECT * FROM missing")
+	var opErr *OperationError
+	if !errors.As(err, &opErr) || opErr.Status != StatusError || opErr.Cause == nil {
+		t.Fatalf("expected an operation error with a cause, got %v", err)
+	}
+	if want := "operation op-2 failed: org.apache.flink.table.api.ValidationException: Object 'missing' not found"; err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+	trace := opErr.Cause.StackTrace()
+	if !strings.HasPrefix(trace, "org.apache.flink.table.gateway.api.utils.SqlGatewayException") || !strings.Contains(trace, "\tat org.apache.flink.table.planner") {
+		t.Errorf("stack trace = %q", trace)
+	}
+
+	g.statuses["op-3"] = []string{StatusRunning, StatusCanceled}
+	if _, err = client.Execute(ctx, session, "SELECT 1"); !errors.As(err, &opErr) || err.Error() != "operation op-3 ended with status CANCELED" {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestExecuteCancel(t *testing.T) {
+	g, client := newFakeGateway(t)
+	session, err := client.OpenSession(context.Background(), "pipegen", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	g.statuses["op-1"] = []string{StatusRunning}
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := client.Execute(ctx, session, "SELECT * FROM orders"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected the deadline error, got %v", err)
+	}
+	if g.requested("POST /v1/sessions/session-1/operations/op-1/cancel") != 1 {
+		t.Error("the operation was not canceled")
+	}
+}
+
+func TestFetchAllResults(t *testing.T) {
+	g, client := newFakeGateway(t)
+	ctx := context.Background()
+	session, err := client.OpenSession(ctx, "pipegen", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.statuses["op-1"] = []string{StatusFinished}
+	operation, err := client.Execute(ctx, session, "SELECT id, amount FROM orders")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result := "/v1/sessions/session-1/operations/op-1/result/"
+	g.pages[result+"0"] = []string{
+		`{"resultType":"NOT_READY","nextResultUri":"` + result + `0?rowFormat=JSON"}`,
+		`{"resultType":"PAYLOAD","isQueryResult":true,"jobID":"job-1","resultKind":"SUCCESS_WITH_CONTENT",
+		  "results":{"columns":[{"name":"id","logicalType":{"type":"VARCHAR","nullable":true}},{"name":"amount","logicalType":{"type":"INTEGER","nullable":false}}],
+		  "data":[{"kind":"INSERT","fields":["a",1]},{"kind":"INSERT","fields":["b",2]}]},"nextResultUri":"` + result + `1?rowFormat=JSON"}`,
+	}
+	g.pages[result+"1"] = []string{
+		`{"resultType":"PAYLOAD","isQueryResult":true,"jobID":"job-1","resultKind":"SUCCESS_WITH_CONTENT",
+		  "results":{"columns":[],"data":[{"kind":"UPDATE_AFTER","fields":["a",3]}]},"nextResultUri":"` + result + `2"}`,
+	}
+	g.pages[result+"2"] = []string{`{"resultType":"EOS","isQueryResult":true,"jobID":"job-1","resultKind":"SUCCESS_WITH_CONTENT","results":{"columns":[],"data":[]}}`}
+
+	page, err := client.FetchAllResults(ctx, session, operation)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if page.JobID != "job-1" || len(page.Columns) != 2 || page.Columns[1].LogicalType.Type != "INTEGER" {
+		t.Errorf("unexpected result: %+v", page)
+	}
+	var rows []string
+	for _, row := range page.Rows {
+		fields := make([]string, len(row.Fields))
+		for i, field := range row.Fields {
+			fields[i] = string(field)
+		}
+		rows = append(rows, row.Kind+" "+strings.Join(fields, ","))
+	}
+	if want := []string{`INSERT "a",1`, `INSERT "b",2`, `UPDATE_AFTER "a",3`}; !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %v", rows)
+	}
+	if fetches := g.requested("GET " + result + "0"); fetches != 2 {
+		t.Errorf("fetched the first page %d times, want 2", fetches)
+	}
+}
+
+func TestRetries(t *testing.T) {
+	g, client := newFakeGateway(t)
+	ctx := context.Background()
+	session, err := client.OpenSession(ctx, "pipegen", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.statuses["op-1"] = []string{StatusFinished}
+	operation, err := client.ExecuteStatement(ctx, session, "SELECT 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Polling the status is idempotent, so an unavailable gateway is retried
+	g.unready = 2
+	if status, err := client.OperationStatus(ctx, session, operation); err != nil || status != StatusFinished {
+		t.Fatalf("OperationStatus() = %q, %v", status, err)
+	}
+
+	// Submitting a statement could run it twice
+	g.unready = 1
+	var gatewayErr *Error
+	if _, err := client.ExecuteStatement(ctx, session, "SELECT 2"); !errors.As(err, &gatewayErr) || gatewayErr.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("expected the 503 error, got %v", err)
+	}
+
+	g.unready = client.MaxRetries + 1
+	if _, err := client.OperationStatus(ctx, session, operation); !errors.As(err, &gatewayErr) {
+		t.Errorf("expected the 503 error after the retries, got %v", err)
+	}
+}
+
+func TestBackoffDelay(t *testing.T) {
+	b := Backoff{Initial: 100 * time.Millisecond, Max: time.Second, Multiplier: 3}
+	var delays []time.Duration
+	for attempt := 0; attempt < 5; attempt++ {
+		delays = append(delays, b.Delay(attempt))
+	}
+	want := []time.Duration{100 * time.Millisecond, 300 * time.Millisecond, 900 * time.Millisecond, time.Second, time.Second}
+	if !reflect.DeepEqual(delays, want) {
+		t.Errorf("delays = %v", delays)
+	}
+}
+
+func TestConnectionRefused(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL
+	server.Close()
+
+	client := NewClient(url)
+	client.Backoff = Backoff{Initial: time.Millisecond, Max: time.Millisecond, Multiplier: 1}
+	_, err := client.OpenSession(context.Background(), "pipegen", nil)
+	if err == nil || !strings.HasPrefix(err.Error(), "failed to open session: POST /v1/sessions") {
+		t.Errorf("error = %v", err)
+	}
+	if !IsConnectionError(err) {
+		t.Errorf("expected a connection error, got %v", err)
+	}
+	if IsConnectionError(&Error{Method: "POST", Path: "/v1/sessions", StatusCode: 500}) {
+		t.Errorf("an error response is not a connection error")
+	}
+}
+
+func TestCancelJobs(t *testing.T) {
+	var mu sync.Mutex
+	var requests []string
+	polls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch {
+		case r.Method == "GET" && r.URL.Path == "/jobs":
+			_, _ = w.Write([]byte(`{"jobs":[{"id":"j1","status":"RUNNING"},{"id":"j2","status":"FINISHED"}]}`))
+		case r.Method == "PATCH" && r.URL.Path == "/jobs/j1":
+			w.WriteHeader(http.StatusAccepted)
+		case r.Method == "GET" && r.URL.Path == "/jobs/j1":
+			// The job takes two polls to stop
+			polls++
+			state := JobCanceled
+			if polls < 3 {
+				state = "CANCELLING"
+			}
+			_, _ = fmt.Fprintf(w, `{"jid":"j1","state":%q}`, state)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	client := NewClient(server.URL)
+	client.Backoff = Backoff{Initial: time.Millisecond, Max: 5 * time.Millisecond, Multiplier: 2}
+	ctx := context.Background()
+
+	jobs, err := client.Jobs(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []Job{{ID: "j1", Status: JobRunning}, {ID: "j2", Status: JobFinished}}; !reflect.DeepEqual(jobs, want) {
+		t.Errorf("jobs = %v", jobs)
+	}
+	if !IsJobActive(jobs[0].Status) || IsJobActive(jobs[1].Status) {
+		t.Errorf("only j1 should be active")
+	}
+
+	if err := client.CancelJob(ctx, "j1"); err != nil {
+		t.Fatal(err)
+	}
+	status, err := client.WaitForJob(ctx, "j1")
+	if err != nil || status != JobCanceled {
+		t.Errorf("WaitForJob = %q, %v", status, err)
+	}
+	if polls != 3 {
+		t.Errorf("polls = %d, want 3", polls)
+	}
+
+	if err := client.CancelJob(ctx, "unknown"); err == nil {
+		t.Errorf("expected an error canceling an unknown job")
+	}
+}
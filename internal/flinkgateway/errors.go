@@ -0,0 +1,94 @@
+package flinkgateway
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Error is an error response of the gateway. Errors holds the messages of the response; for failures
+// inside Flink, the last one is the Java stack trace of the server-side exception.
+type Error struct {
+	Method     string
+	Path       string
+	StatusCode int
+	Errors     []string
+}
+
+func newError(method, path string, statusCode int, body []byte) *Error {
+	e := &Error{Method: method, Path: path, StatusCode: statusCode}
+	var response struct {
+		Errors []string `json:"errors"`
+	}
+	if json.Unmarshal(body, &response) == nil && len(response.Errors) > 0 {
+		e.Errors = response.Errors
+	} else if text := strings.TrimSpace(string(body)); text != "" {
+		e.Errors = []string{text}
+	}
+	return e
+}
+
+func (e *Error) Error() string {
+	if cause := e.RootCause(); cause != "" {
+		return fmt.Sprintf("%s %s returned %d: %s", e.Method, e.Path, e.StatusCode, cause)
+	}
+	return fmt.Sprintf("%s %s returned %d", e.Method, e.Path, e.StatusCode)
+}
+
+// StackTrace returns the server-side stack trace of the error, or "" if the gateway sent none
+func (e *Error) StackTrace() string {
+	for i := len(e.Errors) - 1; i >= 0; i-- {
+		if strings.Contains(e.Errors[i], "\tat ") || strings.Contains(e.Errors[i], "Exception") {
+			trace := strings.TrimPrefix(strings.TrimSpace(e.Errors[i]), "<Exception on server side:")
+			trace = strings.TrimSuffix(trace, "End of exception on server side>")
+			return strings.TrimSpace(trace)
+		}
+	}
+	return ""
+}
+
+// RootCause returns the innermost exception of the stack trace, such as
+// "org.apache.flink.table.api.ValidationException: Object 'orders' not found". Without a stack
+// trace, it is the last message of the response.
+func (e *Error) RootCause() string {
+	trace := e.StackTrace()
+	if trace == "" {
+		if len(e.Errors) == 0 {
+			return ""
+		}
+		return e.Errors[len(e.Errors)-1]
+	}
+
+	var cause string
+	for _, line := range strings.Split(trace, "\n") {
+		line = strings.TrimSpace(line)
+		switch {
+		case cause == "" && line != "" && !strings.HasPrefix(line, "at "):
+			cause = line
+		case strings.HasPrefix(line, "Caused by: "):
+			cause = strings.TrimPrefix(line, "Caused by: ")
+		}
+	}
+	return cause
+}
+
+// OperationError tells that the operation running a statement ended without finishing
+type OperationError struct {
+	Operation string
+	Status    string // ERROR, CANCELED, CLOSED or TIMEOUT
+	Cause     *Error // The error the gateway reports for the operation, if any
+}
+
+func (e *OperationError) Error() string {
+	if e.Cause != nil {
+		return fmt.Sprintf("operation %s failed: %s", e.Operation, e.Cause.RootCause())
+	}
+	return fmt.Sprintf("operation %s ended with status %s", e.Operation, e.Status)
+}
+
+func (e *OperationError) Unwrap() error {
+	if e.Cause == nil {
+		return nil
+	}
+	return e.Cause
+}
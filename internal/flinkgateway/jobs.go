@@ -0,0 +1,90 @@
+package flinkgateway
+
+import (
+	"context"
+	"net/url"
+)
+
+// Job statuses of the Flink REST API
+const (
+	JobCreated    = "CREATED"
+	JobRunning    = "RUNNING"
+	JobRestarting = "RESTARTING"
+	JobFinished   = "FINISHED"
+	JobCanceled   = "CANCELED"
+	JobFailed     = "FAILED"
+)
+
+// Job is a job of the cluster
+type Job struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+}
+
+// IsJobActive reports whether a job with the status runs or is about to
+func IsJobActive(status string) bool {
+	switch status {
+	case JobCreated, JobRunning, JobRestarting:
+		return true
+	}
+	return false
+}
+
+// IsJobTerminal reports whether a job with the status has stopped for good
+func IsJobTerminal(status string) bool {
+	switch status {
+	case JobFinished, JobCanceled, JobFailed:
+		return true
+	}
+	return false
+}
+
+// Jobs lists the jobs of the cluster. The jobs calls go to the REST API of the JobManager, not to
+// the gateway, so the client must be created for its URL, e.g. http://localhost:8081.
+func (c *Client) Jobs(ctx context.Context) ([]Job, error) {
+	var response struct {
+		Jobs []Job `json:"jobs"`
+	}
+	if err := c.do(ctx, "GET", "/jobs", nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Jobs, nil
+}
+
+// JobStatus returns the status of a job
+func (c *Client) JobStatus(ctx context.Context, job string) (string, error) {
+	var response struct {
+		State string `json:"state"`
+	}
+	if err := c.do(ctx, "GET", jobPath(job), nil, &response); err != nil {
+		return "", err
+	}
+	return response.State, nil
+}
+
+// CancelJob asks the cluster to cancel a job. It returns once the cancellation is accepted, before
+// the job has stopped.
+func (c *Client) CancelJob(ctx context.Context, job string) error {
+	return c.do(ctx, "PATCH", jobPath(job), nil, nil)
+}
+
+// WaitForJob polls the status of a job, with backoff, until the job has stopped, and returns the
+// final status. When ctx is done first, it returns the error of ctx.
+func (c *Client) WaitForJob(ctx context.Context, job string) (string, error) {
+	for attempt := 0; ; attempt++ {
+		status, err := c.JobStatus(ctx, job)
+		if err != nil {
+			return "", err
+		}
+		if IsJobTerminal(status) {
+			return status, nil
+		}
+		if err := sleep(ctx, c.Backoff.Delay(attempt)); err != nil {
+			return status, err
+		}
+	}
+}
+
+func jobPath(job string) string {
+	return "/jobs/" + url.PathEscape(job)
+}
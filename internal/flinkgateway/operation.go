@@ -0,0 +1,122 @@
+package flinkgateway
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// Operation statuses
+const (
+	StatusInitialized = "INITIALIZED"
+	StatusPending     = "PENDING"
+	StatusRunning     = "RUNNING"
+	StatusFinished    = "FINISHED"
+	StatusCanceled    = "CANCELED"
+	StatusClosed      = "CLOSED"
+	StatusError       = "ERROR"
+	StatusTimeout     = "TIMEOUT"
+)
+
+// IsTerminal reports whether an operation with the status has ended
+func IsTerminal(status string) bool {
+	switch status {
+	case StatusFinished, StatusCanceled, StatusClosed, StatusError, StatusTimeout:
+		return true
+	}
+	return false
+}
+
+// ExecuteStatement submits a statement to a session and returns the handle of the operation running it
+func (c *Client) ExecuteStatement(ctx context.Context, session, statement string) (string, error) {
+	request := struct {
+		Statement string `json:"statement"`
+	}{statement}
+	var response struct {
+		OperationHandle string `json:"operationHandle"`
+	}
+	if err := c.do(ctx, "POST", sessionPath(session)+"/statements", request, &response); err != nil {
+		return "", fmt.Errorf("failed to submit statement: %w", err)
+	}
+	if response.OperationHandle == "" {
+		return "", fmt.Errorf("failed to submit statement: the gateway returned no operation handle")
+	}
+	return response.OperationHandle, nil
+}
+
+// OperationStatus returns the status of an operation
+func (c *Client) OperationStatus(ctx context.Context, session, operation string) (string, error) {
+	var response struct {
+		Status string `json:"status"`
+	}
+	if err := c.do(ctx, "GET", operationPath(session, operation)+"/status", nil, &response); err != nil {
+		return "", err
+	}
+	return response.Status, nil
+}
+
+// WaitForOperation polls the status of an operation, with backoff, until the operation ends, and
+// returns the final status. When ctx is done first, it returns the error of ctx.
+func (c *Client) WaitForOperation(ctx context.Context, session, operation string) (string, error) {
+	for attempt := 0; ; attempt++ {
+		status, err := c.OperationStatus(ctx, session, operation)
+		if err != nil {
+			return "", err
+		}
+		if IsTerminal(status) {
+			return status, nil
+		}
+		if err := sleep(ctx, c.Backoff.Delay(attempt)); err != nil {
+			return status, err
+		}
+	}
+}
+
+// CancelOperation cancels a running operation
+func (c *Client) CancelOperation(ctx context.Context, session, operation string) error {
+	return c.do(ctx, "POST", operationPath(session, operation)+"/cancel", nil, nil)
+}
+
+// CloseOperation closes an operation, canceling it if it still runs, and releases its results
+func (c *Client) CloseOperation(ctx context.Context, session, operation string) error {
+	return c.do(ctx, "DELETE", operationPath(session, operation)+"/close", nil, nil)
+}
+
+// Execute submits a statement and waits until its operation ends. It returns the operation handle,
+// whose results can then be fetched. A statement that does not finish fails with an
+// *OperationError; one that fails in Flink carries the gateway's stack trace. When ctx is done
+// before the operation ends, the operation is canceled.
+func (c *Client) Execute(ctx context.Context, session, statement string) (string, error) {
+	operation, err := c.ExecuteStatement(ctx, session, statement)
+	if err != nil {
+		return "", err
+	}
+
+	status, err := c.WaitForOperation(ctx, session, operation)
+	if err != nil {
+		if ctx.Err() != nil {
+			cancelCtx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			_ = c.CancelOperation(cancelCtx, session, operation)
+		}
+		return operation, err
+	}
+	if status == StatusFinished {
+		return operation, nil
+	}
+
+	opErr := &OperationError{Operation: operation, Status: status}
+	if status == StatusError {
+		// The gateway reports why the operation failed when its result is fetched
+		if _, err := c.FetchResults(ctx, session, operation, 0); err != nil {
+			errors.As(err, &opErr.Cause)
+		}
+	}
+	return operation, opErr
+}
+
+func operationPath(session, operation string) string {
+	return sessionPath(session) + "/operations/" + url.PathEscape(operation)
+}
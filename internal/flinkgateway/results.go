@@ -0,0 +1,132 @@
+package flinkgateway
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// Result types of a page
+const (
+	ResultNotReady = "NOT_READY" // The operation has produced no new rows yet; fetch the same token again
+	ResultPayload  = "PAYLOAD"
+	ResultEOS      = "EOS" // Last page
+)
+
+// Column is a column of a result
+type Column struct {
+	Name        string      `json:"name"`
+	LogicalType LogicalType `json:"logicalType"`
+	Comment     string      `json:"comment,omitempty"`
+}
+
+// LogicalType is the Flink type of a column
+type LogicalType struct {
+	Type     string `json:"type"`
+	Nullable bool   `json:"nullable"`
+}
+
+// Row is a row of a result. Kind is the change it makes in a changelog: INSERT, UPDATE_BEFORE,
+// UPDATE_AFTER or DELETE.
+type Row struct {
+	Kind   string            `json:"kind"`
+	Fields []json.RawMessage `json:"fields"`
+}
+
+// ResultPage is one page of the result of an operation
+type ResultPage struct {
+	ResultType    string
+	ResultKind    string // SUCCESS for statements without rows, SUCCESS_WITH_CONTENT otherwise
+	IsQueryResult bool
+	JobID         string // Job the statement submitted, if any
+	Columns       []Column
+	Rows          []Row
+	NextToken     int64 // Token of the next page; only valid when HasNext
+	HasNext       bool
+}
+
+type resultResponse struct {
+	ResultType    string `json:"resultType"`
+	ResultKind    string `json:"resultKind"`
+	IsQueryResult bool   `json:"isQueryResult"`
+	JobID         string `json:"jobID"`
+	Results       struct {
+		Columns []Column `json:"columns"`
+		Data    []Row    `json:"data"`
+	} `json:"results"`
+	NextResultURI string `json:"nextResultUri"`
+}
+
+// FetchResults fetches the page of the result of an operation identified by token; the first page
+// has token 0
+func (c *Client) FetchResults(ctx context.Context, session, operation string, token int64) (*ResultPage, error) {
+	var response resultResponse
+	path := fmt.Sprintf("%s/result/%d", operationPath(session, operation), token)
+	if err := c.do(ctx, "GET", path, nil, &response); err != nil {
+		return nil, err
+	}
+
+	page := &ResultPage{
+		ResultType:    response.ResultType,
+		ResultKind:    response.ResultKind,
+		IsQueryResult: response.IsQueryResult,
+		JobID:         response.JobID,
+		Columns:       response.Results.Columns,
+		Rows:          response.Results.Data,
+	}
+	if response.NextResultURI != "" {
+		next, err := parseResultToken(response.NextResultURI)
+		if err != nil {
+			return nil, err
+		}
+		page.NextToken, page.HasNext = next, true
+	}
+	return page, nil
+}
+
+// FetchAllResults fetches the pages of the result of an operation until the last one, waiting with
+// backoff while the operation has no new rows yet. It suits bounded results: the result of an
+// unbounded query ends only when ctx does.
+func (c *Client) FetchAllResults(ctx context.Context, session, operation string) (*ResultPage, error) {
+	all := &ResultPage{ResultType: ResultEOS}
+	token, waits := int64(0), 0
+	for {
+		page, err := c.FetchResults(ctx, session, operation, token)
+		if err != nil {
+			return nil, err
+		}
+		if len(page.Columns) > 0 {
+			all.Columns = page.Columns
+		}
+		if page.JobID != "" {
+			all.JobID = page.JobID
+		}
+		all.ResultKind, all.IsQueryResult = page.ResultKind, page.IsQueryResult
+		all.Rows = append(all.Rows, page.Rows...)
+
+		if page.ResultType == ResultEOS || !page.HasNext {
+			return all, nil
+		}
+		if page.ResultType == ResultNotReady {
+			if err := sleep(ctx, c.Backoff.Delay(waits)); err != nil {
+				return nil, err
+			}
+			waits++
+		} else {
+			waits = 0
+		}
+		token = page.NextToken
+	}
+}
+
+// parseResultToken takes the token out of a next result URI such as
+// /v1/sessions/s/operations/o/result/1?rowFormat=JSON
+func parseResultToken(uri string) (int64, error) {
+	path, _, _ := strings.Cut(uri, "?")
+	var token int64
+	if _, err := fmt.Sscanf(path[strings.LastIndex(path, "/")+1:], "%d", &token); err != nil {
+		return 0, fmt.Errorf("unexpected next result URI %q", uri)
+	}
+	return token, nil
+}
@@ -0,0 +1,78 @@
+package flinkgateway
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"time"
+)
+
+// OpenSession opens a session with the given name and configuration properties and returns its handle
+func (c *Client) OpenSession(ctx context.Context, name string, properties map[string]string) (string, error) {
+	request := struct {
+		SessionName string            `json:"sessionName,omitempty"`
+		Properties  map[string]string `json:"properties,omitempty"`
+	}{name, properties}
+	var response struct {
+		SessionHandle string `json:"sessionHandle"`
+	}
+	if err := c.do(ctx, "POST", "/v1/sessions", request, &response); err != nil {
+		return "", fmt.Errorf("failed to open session: %w", err)
+	}
+	if response.SessionHandle == "" {
+		return "", fmt.Errorf("failed to open session: the gateway returned no session handle")
+	}
+	return response.SessionHandle, nil
+}
+
+// SessionConfig returns the configuration properties of a session
+func (c *Client) SessionConfig(ctx context.Context, session string) (map[string]string, error) {
+	var response struct {
+		Properties map[string]string `json:"properties"`
+	}
+	if err := c.do(ctx, "GET", sessionPath(session), nil, &response); err != nil {
+		return nil, err
+	}
+	return response.Properties, nil
+}
+
+// ConfigureSession runs a statement changing the session, such as SET, USE or CREATE FUNCTION,
+// synchronously
+func (c *Client) ConfigureSession(ctx context.Context, session, statement string) error {
+	request := struct {
+		Statement string `json:"statement"`
+	}{statement}
+	if err := c.do(ctx, "POST", "/v2/sessions/"+url.PathEscape(session)+"/configure-session", request, nil); err != nil {
+		return fmt.Errorf("failed to configure session: %w", err)
+	}
+	return nil
+}
+
+// Heartbeat keeps an idle session from expiring
+func (c *Client) Heartbeat(ctx context.Context, session string) error {
+	return c.do(ctx, "POST", sessionPath(session)+"/heartbeat", nil, nil)
+}
+
+// KeepAlive sends a heartbeat for the session every interval until ctx is done. Failed heartbeats
+// are retried at the next tick.
+func (c *Client) KeepAlive(ctx context.Context, session string, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			_ = c.Heartbeat(ctx, session)
+		}
+	}
+}
+
+// CloseSession closes a session and the operations still running in it
+func (c *Client) CloseSession(ctx context.Context, session string) error {
+	return c.do(ctx, "DELETE", sessionPath(session), nil, nil)
+}
+
+func sessionPath(session string) string {
+	return "/v1/sessions/" + url.PathEscape(session)
+}
@@ -0,0 +1,228 @@
+package flinksql
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// tokenKind tells the lexical class of a token
+type tokenKind int
+
+const (
+	tokWord   tokenKind = iota // Keyword or bare identifier; ${VAR} placeholders lex as words too
+	tokQuoted                  // `Quoted identifier`
+	tokString                  // 'String literal' (or "double-quoted")
+	tokNumber                  // Numeric literal
+	tokPunct                   // Any other single character: ( ) , ; . = < > and operators
+)
+
+// token is a lexeme of a SQL text with its byte offsets
+type token struct {
+	kind  tokenKind
+	text  string // Source text
+	value string // Identifier or literal without its quotes
+	pos   int    // Offset of the first byte
+	end   int    // Offset after the last byte
+}
+
+// is reports whether the token is the given keyword or punctuation, ignoring case
+func (t token) is(s string) bool {
+	switch t.kind {
+	case tokWord:
+		return strings.EqualFold(t.text, s)
+	case tokPunct:
+		return t.text == s
+	}
+	return false
+}
+
+// isName reports whether the token can name a table or column
+func (t token) isName() bool {
+	return t.kind == tokWord || t.kind == tokQuoted
+}
+
+// span is the byte range of a comment
+type span struct {
+	pos, end int
+	hint     bool // /*+ ... */ query hint, which Flink reads and must be kept
+}
+
+// Error is a syntax error at a line and column of the parsed SQL (both 1-based)
+type Error struct {
+	Line   int
+	Column int
+	Msg    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("line %d, column %d: %s", e.Line, e.Column, e.Msg)
+}
+
+// errorAt builds an Error for a byte offset of sql
+func errorAt(sql string, pos int, format string, args ...interface{}) *Error {
+	line, col := position(sql, pos)
+	return &Error{Line: line, Column: col, Msg: fmt.Sprintf(format, args...)}
+}
+
+// position converts a byte offset of sql to its line and column
+func position(sql string, pos int) (int, int) {
+	if pos > len(sql) {
+		pos = len(sql)
+	}
+	before := sql[:pos]
+	line := strings.Count(before, "\n") + 1
+	col := utf8.RuneCountInString(before[strings.LastIndex(before, "\n")+1:]) + 1
+	return line, col
+}
+
+// lex splits sql into tokens and returns the comments it skipped. Literals and quoted identifiers
+// are read whole, so comment markers and semicolons inside them are not mistaken for syntax.
+func lex(sql string) ([]token, []span, error) {
+	var tokens []token
+	var comments []span
+	for i := 0; i < len(sql); {
+		c := sql[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f':
+			i++
+
+		case strings.HasPrefix(sql[i:], "--"):
+			end := strings.IndexByte(sql[i:], '\n')
+			if end == -1 {
+				end = len(sql) - i
+			}
+			comments = append(comments, span{pos: i, end: i + end})
+			i += end
+
+		case strings.HasPrefix(sql[i:], "/*"):
+			end := strings.Index(sql[i+2:], "*/")
+			if end == -1 {
+				return nil, nil, errorAt(sql, i, "unterminated comment")
+			}
+			comments = append(comments, span{pos: i, end: i + 2 + end + 2, hint: strings.HasPrefix(sql[i:], "/*+")})
+			i += 2 + end + 2
+
+		case c == '\'' || c == '"' || c == '`':
+			value, end, ok := readQuoted(sql, i)
+			if !ok {
+				what := "string literal"
+				if c == '`' {
+					what = "quoted identifier"
+				}
+				return nil, nil, errorAt(sql, i, "unterminated %s", what)
+			}
+			kind := tokString
+			if c == '`' {
+				kind = tokQuoted
+			}
+			tokens = append(tokens, token{kind: kind, text: sql[i:end], value: value, pos: i, end: end})
+			i = end
+
+		case c >= '0' && c <= '9':
+			end := i
+			for end < len(sql) && (sql[end] >= '0' && sql[end] <= '9' || sql[end] == '.') {
+				end++
+			}
+			tokens = append(tokens, token{kind: tokNumber, text: sql[i:end], value: sql[i:end], pos: i, end: end})
+			i = end
+
+		case isWordByte(sql, i):
+			end, ok := readWord(sql, i)
+			if !ok {
+				return nil, nil, errorAt(sql, end, "unterminated variable")
+			}
+			tokens = append(tokens, token{kind: tokWord, text: sql[i:end], value: sql[i:end], pos: i, end: end})
+			i = end
+
+		default:
+			_, size := utf8.DecodeRuneInString(sql[i:])
+			tokens = append(tokens, token{kind: tokPunct, text: sql[i : i+size], value: sql[i : i+size], pos: i, end: i + size})
+			i += size
+		}
+	}
+	return tokens, comments, nil
+}
+
+// readQuoted reads the quoted text starting at sql[start], where a doubled quote stands for itself.
+// It returns the unquoted value and the offset after the closing quote.
+func readQuoted(sql string, start int) (string, int, bool) {
+	quote := sql[start]
+	var value strings.Builder
+	for i := start + 1; i < len(sql); i++ {
+		if sql[i] != quote {
+			value.WriteByte(sql[i])
+			continue
+		}
+		if i+1 < len(sql) && sql[i+1] == quote {
+			value.WriteByte(quote)
+			i++
+			continue
+		}
+		return value.String(), i + 1, true
+	}
+	return "", 0, false
+}
+
+// readWord reads the word starting at sql[start] and returns the offset after it. ${VAR} placeholders
+// are part of a word, like ${ENV}_orders; false means one is not closed, at the returned offset.
+func readWord(sql string, start int) (int, bool) {
+	end := start
+	for end < len(sql) && isWordByte(sql, end) {
+		if strings.HasPrefix(sql[end:], "${") {
+			closing := strings.IndexByte(sql[end:], '}')
+			if closing == -1 {
+				return end, false
+			}
+			end += closing + 1
+			continue
+		}
+		end += wordRuneLen(sql, end)
+	}
+	return end, true
+}
+
+// isWordByte reports whether the rune at sql[i] continues a word: letters, digits, _ and $
+func isWordByte(sql string, i int) bool {
+	r, _ := utf8.DecodeRuneInString(sql[i:])
+	return r == '_' || r == '$' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func wordRuneLen(sql string, i int) int {
+	_, size := utf8.DecodeRuneInString(sql[i:])
+	return size
+}
+
+// QuoteString renders s as a SQL string literal
+func QuoteString(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", "''") + "'"
+}
+
+// StripComments removes the comments of sql, leaving string literals, quoted identifiers and
+// /*+ ... */ query hints untouched. SQL that does not lex is returned unchanged.
+func StripComments(sql string) string {
+	_, comments, err := lex(sql)
+	if err != nil || len(comments) == 0 {
+		return sql
+	}
+	var out strings.Builder
+	last := 0
+	for _, c := range comments {
+		if c.hint {
+			continue
+		}
+		out.WriteString(sql[last:c.pos])
+		if strings.HasPrefix(sql[c.pos:], "/*") {
+			// Keep the tokens around a block comment apart, and its line breaks
+			if n := strings.Count(sql[c.pos:c.end], "\n"); n > 0 {
+				out.WriteString(strings.Repeat("\n", n))
+			} else {
+				out.WriteByte(' ')
+			}
+		}
+		last = c.end
+	}
+	out.WriteString(sql[last:])
+	return out.String()
+}
@@ -0,0 +1,926 @@
+// Package flinksql parses the Flink SQL scripts of a pipeline into a typed model of their statements:
+// the tables they declare with columns, watermarks, primary keys and WITH options, and the tables
+// their queries read and write.
+//
+// The parser covers the DDL and DML pipelines are built from, not the whole Flink SQL grammar.
+// Queries are not parsed into expressions; only the tables they read are extracted.
+package flinksql
+
+import (
+	"strings"
+)
+
+// Kind is the type of a statement
+type Kind string
+
+// Statement kinds
+const (
+	KindCreateTable  Kind = "CREATE_TABLE"
+	KindCreateView   Kind = "CREATE_VIEW"
+	KindInsert       Kind = "INSERT"
+	KindStatementSet Kind = "STATEMENT_SET" // EXECUTE STATEMENT SET BEGIN ... END
+	KindQuery        Kind = "QUERY"
+	KindSet          Kind = "SET"
+	KindOther        Kind = "OTHER" // Statements without a model, like USE, DROP or CREATE FUNCTION
+)
+
+// Statement is a parsed statement of a SQL script
+type Statement struct {
+	Kind  Kind
+	Text  string // SQL of the statement without its closing semicolon
+	Start int    // Byte offset of Text in the parsed SQL
+	End   int    // Byte offset after Text
+	Line  int    // Line of the parsed SQL the statement starts on
+
+	Table   *CreateTable // KindCreateTable
+	View    *CreateView  // KindCreateView
+	Inserts []*Insert    // KindInsert (one) and KindStatementSet (one per INSERT of the set)
+	Query   *Query       // KindQuery
+	Set     *Set         // KindSet
+
+	words []string // Leading keywords, upper-cased
+}
+
+// CreateTable is a CREATE TABLE statement
+type CreateTable struct {
+	Name          string // As declared, without backticks; qualified names keep their dots
+	Temporary     bool
+	IfNotExists   bool
+	OrReplace     bool
+	Columns       []Column
+	Watermark     *Watermark
+	PrimaryKey    []string
+	PartitionedBy []string
+	Comment       string
+	Options       []Option // WITH options in declaration order
+	Like          string   // Table of a LIKE clause
+	Query         *Query   // CREATE TABLE ... AS SELECT
+
+	withEnd int // Offset of the parenthesis closing the WITH options, -1 without them
+}
+
+// Column is a column of a CREATE TABLE statement
+type Column struct {
+	Name        string
+	Type        string // Data type as written, like DECIMAL(10, 2) NOT NULL; empty for computed columns
+	Expr        string // Expression of a computed column
+	Metadata    bool   // Metadata column, like `ts TIMESTAMP_LTZ(3) METADATA FROM 'timestamp'`
+	MetadataKey string // Key of a metadata column named with FROM
+	Virtual     bool   // Metadata column that is not written
+	Comment     string
+}
+
+// Watermark is the WATERMARK clause of a table
+type Watermark struct {
+	Column string
+	Expr   string // Watermark strategy, like ts - INTERVAL '5' SECOND
+}
+
+// Option is a WITH option of a table
+type Option struct {
+	Key   string
+	Value string
+
+	start, end int // Byte offsets of the option in the parsed SQL
+	valueStart int // Byte offsets of the value
+	valueEnd   int
+}
+
+// CreateView is a CREATE VIEW statement
+type CreateView struct {
+	Name        string
+	Temporary   bool
+	IfNotExists bool
+	Columns     []string
+	Query       *Query
+}
+
+// Insert is an INSERT statement
+type Insert struct {
+	Target    string
+	Overwrite bool
+	Columns   []string
+	Query     *Query
+}
+
+// Query is a query of a statement. Only the tables it reads are extracted.
+type Query struct {
+	Text    string   // SQL of the query
+	Sources []string // Tables and views read, in order of appearance; CTE names are left out
+}
+
+// Set is a SET statement; SET without a key lists the session configuration
+type Set struct {
+	Key   string
+	Value string
+}
+
+// Parse splits a SQL script into statements and parses them. Semicolons, comment markers and
+// keywords inside literals, quoted identifiers and comments are not mistaken for syntax.
+func Parse(sql string) ([]*Statement, error) {
+	tokens, _, err := lex(sql)
+	if err != nil {
+		return nil, err
+	}
+
+	var statements []*Statement
+	for len(tokens) > 0 {
+		n := statementLength(tokens)
+		toks := tokens[:n]
+		tokens = tokens[n:]
+		if toks[len(toks)-1].is(";") {
+			toks = toks[:len(toks)-1]
+		}
+		if len(toks) == 0 {
+			continue
+		}
+		stmt, err := parseStatement(sql, toks)
+		if err != nil {
+			return nil, err
+		}
+		statements = append(statements, stmt)
+	}
+	return statements, nil
+}
+
+// statementLength returns the number of tokens of the first statement, including its semicolon.
+// A statement set runs to the semicolon after its END.
+func statementLength(tokens []token) int {
+	set := len(tokens) >= 4 && tokens[0].is("EXECUTE") && tokens[1].is("STATEMENT") && tokens[2].is("SET") && tokens[3].is("BEGIN")
+	for i, t := range tokens {
+		if t.is(";") && (!set || tokens[i-1].is("END")) {
+			return i + 1
+		}
+	}
+	return len(tokens)
+}
+
+// parseStatement parses the tokens of a statement without its semicolon
+func parseStatement(sql string, toks []token) (*Statement, error) {
+	first, last := toks[0], toks[len(toks)-1]
+	line, _ := position(sql, first.pos)
+	stmt := &Statement{Kind: KindOther, Text: sql[first.pos:last.end], Start: first.pos, End: last.end, Line: line}
+	for _, t := range toks {
+		if t.kind != tokWord || len(stmt.words) == 4 {
+			break
+		}
+		stmt.words = append(stmt.words, strings.ToUpper(t.text))
+	}
+	if err := checkParens(sql, toks); err != nil {
+		return nil, err
+	}
+
+	p := &parser{sql: sql, toks: toks}
+	switch {
+	case p.at("CREATE"):
+		return stmt, p.parseCreate(stmt)
+	case p.at("INSERT"), p.at("EXECUTE", "INSERT"):
+		p.accept("EXECUTE")
+		insert, err := p.parseInsert()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Kind, stmt.Inserts = KindInsert, []*Insert{insert}
+	case p.accept("EXECUTE", "STATEMENT", "SET", "BEGIN"):
+		inserts, err := p.parseStatementSet()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Kind, stmt.Inserts = KindStatementSet, inserts
+	case p.at("SELECT"), p.at("WITH"), p.at("VALUES"), p.at("("):
+		stmt.Kind, stmt.Query = KindQuery, newQuery(sql, toks)
+	case p.accept("SET"):
+		set, err := p.parseSet()
+		if err != nil {
+			return nil, err
+		}
+		stmt.Kind, stmt.Set = KindSet, set
+	}
+	return stmt, nil
+}
+
+// checkParens reports the first unbalanced parenthesis of a statement
+func checkParens(sql string, toks []token) error {
+	var open []int
+	for _, t := range toks {
+		switch {
+		case t.is("("):
+			open = append(open, t.pos)
+		case t.is(")"):
+			if len(open) == 0 {
+				return errorAt(sql, t.pos, "unexpected ')'")
+			}
+			open = open[:len(open)-1]
+		}
+	}
+	if len(open) > 0 {
+		return errorAt(sql, open[len(open)-1], "unclosed '('")
+	}
+	return nil
+}
+
+// Keyword returns up to n leading keywords of the statement, upper-cased and separated by a space,
+// like "CREATE CATALOG" or "USE"
+func (s *Statement) Keyword(n int) string {
+	if n > len(s.words) {
+		n = len(s.words)
+	}
+	return strings.Join(s.words[:n], " ")
+}
+
+// sessionStatements are the leading keywords of the statements that change the session
+var sessionStatements = []string{
+	"SET", "RESET", "USE", "ADD JAR", "LOAD MODULE", "UNLOAD MODULE",
+	"CREATE CATALOG", "CREATE DATABASE", "CREATE FUNCTION", "CREATE TEMPORARY FUNCTION", "CREATE TEMPORARY SYSTEM FUNCTION",
+}
+
+// IsSession reports whether the statement changes the session the statements after it run in, like
+// SET, USE or CREATE FUNCTION, rather than declaring a table or running a job
+func (s *Statement) IsSession() bool {
+	for _, keyword := range sessionStatements {
+		if s.Keyword(len(strings.Fields(keyword))) == keyword {
+			return true
+		}
+	}
+	return false
+}
+
+// Declares returns the table or view a CREATE TABLE or CREATE VIEW statement declares, or ""
+func (s *Statement) Declares() string {
+	switch {
+	case s.Table != nil:
+		return s.Table.Name
+	case s.View != nil:
+		return s.View.Name
+	}
+	return ""
+}
+
+// Reads returns the tables and views the queries of the statement read, in order of appearance
+func (s *Statement) Reads() []string {
+	var queries []*Query
+	switch {
+	case s.Table != nil:
+		queries = append(queries, s.Table.Query)
+	case s.View != nil:
+		queries = append(queries, s.View.Query)
+	case s.Query != nil:
+		queries = append(queries, s.Query)
+	}
+	for _, insert := range s.Inserts {
+		queries = append(queries, insert.Query)
+	}
+
+	var reads []string
+	seen := make(map[string]bool)
+	for _, query := range queries {
+		if query == nil {
+			continue
+		}
+		for _, source := range query.Sources {
+			if !seen[NormalizeName(source)] {
+				seen[NormalizeName(source)] = true
+				reads = append(reads, source)
+			}
+		}
+	}
+	return reads
+}
+
+// Writes returns the tables the statement writes to: INSERT targets and CREATE TABLE AS SELECT
+func (s *Statement) Writes() []string {
+	var writes []string
+	if s.Table != nil && s.Table.Query != nil {
+		writes = append(writes, s.Table.Name)
+	}
+	for _, insert := range s.Inserts {
+		writes = append(writes, insert.Target)
+	}
+	return writes
+}
+
+// Option returns the value of a WITH option; keys are compared case-insensitively
+func (t *CreateTable) Option(key string) (string, bool) {
+	if i := t.optionIndex(key); i != -1 {
+		return t.Options[i].Value, true
+	}
+	return "", false
+}
+
+func (t *CreateTable) optionIndex(key string) int {
+	for i, option := range t.Options {
+		if strings.EqualFold(option.Key, key) {
+			return i
+		}
+	}
+	return -1
+}
+
+// Connector returns the 'connector' option of the table
+func (t *CreateTable) Connector() string {
+	connector, _ := t.Option("connector")
+	return connector
+}
+
+// Topic returns the 'topic' option of the table
+func (t *CreateTable) Topic() string {
+	topic, _ := t.Option("topic")
+	return topic
+}
+
+// IsKafka reports whether the table uses a Kafka connector, like kafka or upsert-kafka
+func (t *CreateTable) IsKafka() bool {
+	return strings.Contains(strings.ToLower(t.Connector()), "kafka")
+}
+
+// NormalizeName compares table names case-insensitively, ignoring quoting and catalog/database qualifiers
+func NormalizeName(name string) string {
+	name = strings.ReplaceAll(name, "`", "")
+	if i := strings.LastIndex(name, "."); i != -1 {
+		name = name[i+1:]
+	}
+	return strings.ToLower(name)
+}
+
+// parser reads the tokens of one statement
+type parser struct {
+	sql  string
+	toks []token
+	i    int
+}
+
+// peek returns the token n places after the current one; past the end it is a punctuation token without text
+func (p *parser) peek(n int) token {
+	if p.i+n >= len(p.toks) {
+		return token{kind: tokPunct, pos: len(p.sql), end: len(p.sql)}
+	}
+	return p.toks[p.i+n]
+}
+
+// at reports whether the next tokens are the given keywords or punctuation
+func (p *parser) at(words ...string) bool {
+	for n, word := range words {
+		if !p.peek(n).is(word) {
+			return false
+		}
+	}
+	return true
+}
+
+// accept skips the next tokens if they are the given keywords or punctuation
+func (p *parser) accept(words ...string) bool {
+	if !p.at(words...) {
+		return false
+	}
+	p.i += len(words)
+	return true
+}
+
+func (p *parser) done() bool {
+	return p.i >= len(p.toks)
+}
+
+// errorf reports a syntax error at the current token, or at the end of the statement
+func (p *parser) errorf(format string, args ...interface{}) error {
+	pos := p.peek(0).pos
+	if p.done() && len(p.toks) > 0 {
+		pos = p.toks[len(p.toks)-1].end
+	}
+	return errorAt(p.sql, pos, format, args...)
+}
+
+// name reads a possibly qualified name, like `catalog`.db.orders, and returns it without backticks
+func (p *parser) name() (string, bool) {
+	var parts []string
+	for p.peek(0).isName() {
+		parts = append(parts, p.peek(0).value)
+		p.i++
+		if !p.at(".") || !p.peek(1).isName() {
+			break
+		}
+		p.i++
+	}
+	return strings.Join(parts, "."), len(parts) > 0
+}
+
+// group returns the tokens between the parenthesis at the current token and the one closing it,
+// and moves past both. Parentheses are balanced, see checkParens.
+func (p *parser) group() []token {
+	depth := 0
+	for j := p.i; j < len(p.toks); j++ {
+		switch {
+		case p.toks[j].is("("):
+			depth++
+		case p.toks[j].is(")"):
+			depth--
+			if depth == 0 {
+				inner := p.toks[p.i+1 : j]
+				p.i = j + 1
+				return inner
+			}
+		}
+	}
+	inner := p.toks[p.i+1:]
+	p.i = len(p.toks)
+	return inner
+}
+
+// stringLiteral reads a string literal if it is the next token
+func (p *parser) stringLiteral() (string, bool) {
+	if t := p.peek(0); t.kind == tokString {
+		p.i++
+		return t.value, true
+	}
+	return "", false
+}
+
+// text returns the SQL the tokens were read from
+func (p *parser) text(toks []token) string {
+	if len(toks) == 0 {
+		return ""
+	}
+	return p.sql[toks[0].pos:toks[len(toks)-1].end]
+}
+
+// nesting follows parentheses and the angle brackets of ROW<...>, ARRAY<...>, MAP<...> and MULTISET<...>
+// types, whose commas do not separate list elements
+type nesting struct {
+	parens, angles int
+	prev           token
+}
+
+func (n *nesting) step(t token) {
+	switch {
+	case t.is("("):
+		n.parens++
+	case t.is(")"):
+		n.parens--
+	case t.is("<") && (n.prev.is("ROW") || n.prev.is("ARRAY") || n.prev.is("MAP") || n.prev.is("MULTISET")):
+		n.angles++
+	case t.is(">") && n.angles > 0:
+		n.angles--
+	}
+	n.prev = t
+}
+
+func (n *nesting) top() bool {
+	return n.parens == 0 && n.angles == 0
+}
+
+// splitList splits tokens on the commas that are not nested in parentheses or type brackets
+func splitList(toks []token) [][]token {
+	var items [][]token
+	var n nesting
+	start := 0
+	for i, t := range toks {
+		if t.is(",") && n.top() {
+			items = append(items, toks[start:i])
+			start = i + 1
+			continue
+		}
+		n.step(t)
+	}
+	return append(items, toks[start:])
+}
+
+// scanTo returns the index of the first of the keywords at the top nesting level, from the current token on
+func (p *parser) scanTo(keywords ...string) int {
+	var n nesting
+	for j := p.i; j < len(p.toks); j++ {
+		if n.top() {
+			for _, keyword := range keywords {
+				if p.toks[j].is(keyword) {
+					return j
+				}
+			}
+		}
+		n.step(p.toks[j])
+	}
+	return len(p.toks)
+}
+
+// names returns the names of a parenthesized list, like the columns of PRIMARY KEY (a, b)
+func names(toks []token) []string {
+	var list []string
+	for _, t := range toks {
+		if t.isName() {
+			list = append(list, t.value)
+		}
+	}
+	return list
+}
+
+// parseCreate parses CREATE TABLE and CREATE VIEW; other CREATE statements have no model
+func (p *parser) parseCreate(stmt *Statement) error {
+	p.accept("CREATE")
+	orReplace := p.accept("OR", "REPLACE")
+	temporary := p.accept("TEMPORARY")
+	switch {
+	case p.accept("TABLE"):
+		table, err := p.parseCreateTable()
+		if err != nil {
+			return err
+		}
+		table.Temporary, table.OrReplace = temporary, orReplace
+		stmt.Kind, stmt.Table = KindCreateTable, table
+	case p.accept("VIEW"):
+		view, err := p.parseCreateView()
+		if err != nil {
+			return err
+		}
+		view.Temporary = temporary
+		stmt.Kind, stmt.View = KindCreateView, view
+	}
+	return nil
+}
+
+func (p *parser) parseCreateTable() (*CreateTable, error) {
+	table := &CreateTable{IfNotExists: p.accept("IF", "NOT", "EXISTS"), withEnd: -1}
+	name, ok := p.name()
+	if !ok {
+		return nil, p.errorf("expected a table name")
+	}
+	table.Name = name
+
+	if p.at("(") {
+		if err := p.parseSchema(table, p.group()); err != nil {
+			return nil, err
+		}
+	}
+	for !p.done() {
+		switch {
+		case p.accept("COMMENT"):
+			table.Comment, _ = p.stringLiteral()
+		case p.accept("PARTITIONED", "BY"):
+			if p.at("(") {
+				table.PartitionedBy = names(p.group())
+			}
+		case p.accept("WITH"):
+			if !p.at("(") {
+				return nil, p.errorf("expected '(' after WITH")
+			}
+			options, err := p.parseOptions(p.group())
+			if err != nil {
+				return nil, err
+			}
+			table.Options, table.withEnd = options, p.toks[p.i-1].pos
+		case p.accept("LIKE"):
+			table.Like, _ = p.name()
+			if p.at("(") {
+				p.group()
+			}
+		case p.accept("AS"):
+			if p.done() {
+				return nil, p.errorf("expected a query after AS")
+			}
+			table.Query = newQuery(p.sql, p.toks[p.i:])
+			p.i = len(p.toks)
+		case p.at("("):
+			p.group()
+		default:
+			// Clauses the model leaves out, like DISTRIBUTED BY
+			p.i++
+		}
+	}
+	return table, nil
+}
+
+// parseSchema parses the columns, watermark and primary key between the parentheses of CREATE TABLE
+func (p *parser) parseSchema(table *CreateTable, toks []token) error {
+	for _, item := range splitList(toks) {
+		element := &parser{sql: p.sql, toks: item}
+		if len(item) == 0 {
+			return p.errorf("expected a column")
+		}
+		switch {
+		case element.accept("WATERMARK"):
+			watermark, err := element.parseWatermark()
+			if err != nil {
+				return err
+			}
+			table.Watermark = watermark
+		case element.at("PRIMARY"), element.at("CONSTRAINT"):
+			if element.accept("CONSTRAINT") {
+				element.name()
+			}
+			if !element.accept("PRIMARY", "KEY") || !element.at("(") {
+				return element.errorf("expected PRIMARY KEY (<columns>)")
+			}
+			table.PrimaryKey = names(element.group())
+		default:
+			column, primary, err := element.parseColumn()
+			if err != nil {
+				return err
+			}
+			if primary {
+				table.PrimaryKey = []string{column.Name}
+			}
+			table.Columns = append(table.Columns, column)
+		}
+	}
+	return nil
+}
+
+// parseWatermark parses FOR <column> AS <expression> after WATERMARK
+func (p *parser) parseWatermark() (*Watermark, error) {
+	if !p.accept("FOR") {
+		return nil, p.errorf("expected WATERMARK FOR <column> AS <expression>")
+	}
+	column, ok := p.name()
+	if !ok || !p.accept("AS") || p.done() {
+		return nil, p.errorf("expected WATERMARK FOR <column> AS <expression>")
+	}
+	return &Watermark{Column: column, Expr: p.text(p.toks[p.i:])}, nil
+}
+
+// parseColumn parses a physical, metadata or computed column; primary tells whether it is declared
+// the primary key inline
+func (p *parser) parseColumn() (Column, bool, error) {
+	var column Column
+	if !p.peek(0).isName() {
+		return column, false, p.errorf("expected a column name")
+	}
+	column.Name = p.peek(0).value
+	p.i++
+
+	primary := false
+	if p.accept("AS") {
+		end := p.scanTo("COMMENT")
+		if end == p.i {
+			return column, false, p.errorf("expected an expression for computed column %s", column.Name)
+		}
+		column.Expr = p.text(p.toks[p.i:end])
+		p.i = end
+	} else {
+		end := p.scanTo("METADATA", "PRIMARY", "COMMENT")
+		if end == p.i {
+			return column, false, p.errorf("expected a type for column %s", column.Name)
+		}
+		column.Type = p.text(p.toks[p.i:end])
+		p.i = end
+		if p.accept("METADATA") {
+			column.Metadata = true
+			if p.accept("FROM") {
+				column.MetadataKey, _ = p.stringLiteral()
+			}
+			column.Virtual = p.accept("VIRTUAL")
+		}
+		if p.accept("PRIMARY", "KEY") {
+			primary = true
+			p.accept("NOT", "ENFORCED")
+		}
+	}
+	if p.accept("COMMENT") {
+		column.Comment, _ = p.stringLiteral()
+	}
+	if !p.done() {
+		return column, false, p.errorf("unexpected %q in column %s", p.peek(0).text, column.Name)
+	}
+	return column, primary, nil
+}
+
+// parseOptions parses the 'key' = 'value' pairs between the parentheses of WITH
+func (p *parser) parseOptions(toks []token) ([]Option, error) {
+	if len(toks) == 0 {
+		return nil, nil
+	}
+	var options []Option
+	for _, item := range splitList(toks) {
+		option := &parser{sql: p.sql, toks: item}
+		if len(item) == 0 {
+			return nil, p.errorf("expected an option")
+		}
+		eq := option.scanTo("=")
+		if eq == 0 {
+			return nil, option.errorf("expected an option key")
+		}
+		if eq == len(item) {
+			option.i = 1
+			return nil, option.errorf("expected '=' after option %s", item[0].value)
+		}
+		key := option.text(item[:eq])
+		if eq == 1 && item[0].kind == tokString {
+			key = item[0].value
+		}
+		value := item[eq+1:]
+		if len(value) == 0 {
+			option.i = len(item)
+			return nil, option.errorf("expected a value for option %s", key)
+		}
+		parsed := Option{
+			Key:        key,
+			Value:      option.text(value),
+			start:      item[0].pos,
+			end:        item[len(item)-1].end,
+			valueStart: value[0].pos,
+			valueEnd:   value[len(value)-1].end,
+		}
+		if len(value) == 1 && value[0].kind == tokString {
+			parsed.Value = value[0].value
+		}
+		options = append(options, parsed)
+	}
+	return options, nil
+}
+
+func (p *parser) parseCreateView() (*CreateView, error) {
+	view := &CreateView{IfNotExists: p.accept("IF", "NOT", "EXISTS")}
+	name, ok := p.name()
+	if !ok {
+		return nil, p.errorf("expected a view name")
+	}
+	view.Name = name
+	if p.at("(") {
+		view.Columns = names(p.group())
+	}
+	if p.accept("COMMENT") {
+		p.stringLiteral()
+	}
+	if !p.accept("AS") || p.done() {
+		return nil, p.errorf("expected AS <query> after view %s", name)
+	}
+	view.Query = newQuery(p.sql, p.toks[p.i:])
+	return view, nil
+}
+
+// parseInsert parses INSERT INTO|OVERWRITE <table> [PARTITION (...)] [(<columns>)] <query>
+func (p *parser) parseInsert() (*Insert, error) {
+	p.accept("INSERT")
+	insert := &Insert{}
+	switch {
+	case p.accept("INTO"):
+	case p.accept("OVERWRITE"):
+		insert.Overwrite = true
+	default:
+		return nil, p.errorf("expected INTO or OVERWRITE after INSERT")
+	}
+	p.accept("TABLE")
+	target, ok := p.name()
+	if !ok {
+		return nil, p.errorf("expected a table name")
+	}
+	insert.Target = target
+
+	if p.accept("PARTITION") && p.at("(") {
+		p.group()
+	}
+	if p.at("(") && !p.at("(", "SELECT") && !p.at("(", "WITH") && !p.at("(", "VALUES") && !p.at("(", "(") {
+		insert.Columns = names(p.group())
+	}
+	if p.done() {
+		return nil, p.errorf("expected a query after INSERT INTO %s", target)
+	}
+	insert.Query = newQuery(p.sql, p.toks[p.i:])
+	return insert, nil
+}
+
+// parseStatementSet parses the INSERT statements of EXECUTE STATEMENT SET BEGIN ... END
+func (p *parser) parseStatementSet() ([]*Insert, error) {
+	if !p.toks[len(p.toks)-1].is("END") {
+		p.i = len(p.toks)
+		return nil, p.errorf("expected END closing the statement set")
+	}
+	var inserts []*Insert
+	start := p.i
+	for j := p.i; j < len(p.toks); j++ {
+		if !p.toks[j].is(";") && j < len(p.toks)-1 {
+			continue
+		}
+		if j > start {
+			member := &parser{sql: p.sql, toks: p.toks[start:j]}
+			if !member.at("INSERT") {
+				return nil, member.errorf("a statement set can only hold INSERT statements")
+			}
+			insert, err := member.parseInsert()
+			if err != nil {
+				return nil, err
+			}
+			inserts = append(inserts, insert)
+		}
+		start = j + 1
+	}
+	if len(inserts) == 0 {
+		return nil, p.errorf("empty statement set")
+	}
+	return inserts, nil
+}
+
+// parseSet parses SET ['key' = 'value'] after SET
+func (p *parser) parseSet() (*Set, error) {
+	set := &Set{}
+	if p.done() {
+		return set, nil
+	}
+	eq := p.scanTo("=")
+	if eq == p.i || eq == len(p.toks) || eq == len(p.toks)-1 {
+		return nil, p.errorf("expected SET '<key>' = '<value>'")
+	}
+	set.Key = p.text(p.toks[p.i:eq])
+	if eq == p.i+1 && p.toks[p.i].kind == tokString {
+		set.Key = p.toks[p.i].value
+	}
+	value := p.toks[eq+1:]
+	set.Value = p.text(value)
+	if len(value) == 1 && value[0].kind == tokString {
+		set.Value = value[0].value
+	}
+	return set, nil
+}
+
+// Keywords that end a table reference of FROM, JOIN or a comma join, so they are not read as its alias
+var clauseKeywords = map[string]bool{
+	"WHERE": true, "GROUP": true, "ORDER": true, "HAVING": true, "LIMIT": true, "OFFSET": true, "FETCH": true,
+	"JOIN": true, "INNER": true, "LEFT": true, "RIGHT": true, "FULL": true, "CROSS": true, "OUTER": true,
+	"NATURAL": true, "ON": true, "USING": true, "UNION": true, "EXCEPT": true, "INTERSECT": true, "MINUS": true,
+	"WINDOW": true, "FOR": true, "MATCH_RECOGNIZE": true, "QUALIFY": true, "AS": true,
+}
+
+// newQuery extracts the tables a query reads: the references of FROM and JOIN, comma joins and
+// TABLE arguments of window functions, like TUMBLE(TABLE orders, ...). FROM counts only within a
+// SELECT, so EXTRACT(HOUR FROM ts) is not a table reference.
+func newQuery(sql string, toks []token) *Query {
+	query := &Query{Text: sql[toks[0].pos:toks[len(toks)-1].end]}
+	seen := make(map[string]bool)
+	ctes := make(map[string]bool)
+	add := func(name string) {
+		if key := NormalizeName(name); !seen[key] {
+			seen[key] = true
+			query.Sources = append(query.Sources, name)
+		}
+	}
+
+	selects := []bool{false} // Whether each open parenthesis level has seen a SELECT
+	for i, t := range toks {
+		switch {
+		case t.is("("):
+			selects = append(selects, false)
+		case t.is(")"):
+			if len(selects) > 1 {
+				selects = selects[:len(selects)-1]
+			}
+		case t.is("SELECT"):
+			selects[len(selects)-1] = true
+		case t.is("FROM") && selects[len(selects)-1], t.is("JOIN"):
+			for j := i + 1; ; {
+				name, next := tableReference(toks, j)
+				if name == "" {
+					break
+				}
+				add(name)
+				next = skipAlias(toks, next)
+				if next >= len(toks) || !toks[next].is(",") {
+					break
+				}
+				j = next + 1
+			}
+		case t.is("TABLE"):
+			if name, _ := tableReference(toks, i+1); name != "" {
+				add(name)
+			}
+		case t.isName() && i > 0 && (toks[i-1].is("WITH") || toks[i-1].is(",")) &&
+			i+2 < len(toks) && toks[i+1].is("AS") && toks[i+2].is("("):
+			ctes[NormalizeName(t.value)] = true
+		}
+	}
+
+	sources := query.Sources[:0]
+	for _, source := range query.Sources {
+		if !ctes[NormalizeName(source)] {
+			sources = append(sources, source)
+		}
+	}
+	query.Sources = sources
+	return query
+}
+
+// tableReference reads the table name at toks[j] and returns the index after it. Subqueries, table
+// functions and LATERAL or TABLE(...) references return "".
+func tableReference(toks []token, j int) (string, int) {
+	var parts []string
+	for j < len(toks) && toks[j].isName() {
+		if len(parts) == 0 && (toks[j].is("LATERAL") || toks[j].is("TABLE") || toks[j].is("UNNEST")) {
+			break
+		}
+		parts = append(parts, toks[j].value)
+		j++
+		if j+1 >= len(toks) || !toks[j].is(".") || !toks[j+1].isName() {
+			break
+		}
+		j++
+	}
+	if len(parts) == 0 || (j < len(toks) && toks[j].is("(")) {
+		return "", j
+	}
+	return strings.Join(parts, "."), j
+}
+
+// skipAlias returns the index after the alias of a table reference, if it has one
+func skipAlias(toks []token, j int) int {
+	if j < len(toks) && toks[j].is("AS") {
+		j++
+	}
+	if j < len(toks) && (toks[j].kind == tokQuoted || toks[j].kind == tokWord && !clauseKeywords[strings.ToUpper(toks[j].text)]) {
+		j++
+	}
+	return j
+}
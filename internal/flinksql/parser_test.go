@@ -0,0 +1,210 @@
+package flinksql
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const pipelineSQL = `-- Orders from the shop; one per checkout
+CREATE TABLE IF NOT EXISTS ` + "`shop`.orders" + ` (
+  order_id STRING NOT NULL,
+  amount DECIMAL(10, 2) COMMENT 'in EUR; gross',
+  items ARRAY<ROW<sku STRING, qty INT>>,
+  ts TIMESTAMP_LTZ(3) METADATA FROM 'timestamp' VIRTUAL,
+  total AS amount * 1.21,
+  WATERMARK FOR ts AS ts - INTERVAL '5' SECOND,
+  PRIMARY KEY (order_id) NOT ENFORCED
+) WITH (
+  'connector' = 'kafka',
+  'topic' = 'orders--v1', -- not a comment inside the literal
+  'format' = 'avro-confluent'
+);
+
+CREATE TEMPORARY VIEW big_orders AS
+WITH recent AS (SELECT * FROM orders WHERE ts > CURRENT_TIMESTAMP - INTERVAL '1' HOUR)
+SELECT r.order_id, c.name FROM recent r, customers AS c WHERE EXTRACT(HOUR FROM r.ts) > 8;
+
+/* Hourly totals */
+INSERT INTO totals
+SELECT window_start, SUM(amount)
+FROM TABLE(TUMBLE(TABLE orders, DESCRIPTOR(ts), INTERVAL '1' HOUR))
+LEFT JOIN lookup.rates FOR SYSTEM_TIME AS OF orders.ts ON TRUE
+CROSS JOIN UNNEST(items) AS t (sku, qty)
+GROUP BY window_start, window_end;
+
+SET 'pipeline.name' = 'orders';
+USE CATALOG shop`
+
+func TestParse(t *testing.T) {
+	statements, err := Parse(pipelineSQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var kinds []Kind
+	for _, stmt := range statements {
+		kinds = append(kinds, stmt.Kind)
+	}
+	if want := []Kind{KindCreateTable, KindCreateView, KindInsert, KindSet, KindOther}; !reflect.DeepEqual(kinds, want) {
+		t.Fatalf("kinds = %v, want %v", kinds, want)
+	}
+
+	table := statements[0].Table
+	if table.Name != "shop.orders" || !table.IfNotExists || statements[0].Line != 2 {
+		t.Errorf("unexpected table: %+v (line %d)", table, statements[0].Line)
+	}
+	wantColumns := []Column{
+		{Name: "order_id", Type: "STRING NOT NULL"},
+		{Name: "amount", Type: "DECIMAL(10, 2)", Comment: "in EUR; gross"},
+		{Name: "items", Type: "ARRAY<ROW<sku STRING, qty INT>>"},
+		{Name: "ts", Type: "TIMESTAMP_LTZ(3)", Metadata: true, MetadataKey: "timestamp", Virtual: true},
+		{Name: "total", Expr: "amount * 1.21"},
+	}
+	if !reflect.DeepEqual(table.Columns, wantColumns) {
+		t.Errorf("columns = %+v", table.Columns)
+	}
+	if table.Watermark == nil || table.Watermark.Column != "ts" || table.Watermark.Expr != "ts - INTERVAL '5' SECOND" {
+		t.Errorf("watermark = %+v", table.Watermark)
+	}
+	if !reflect.DeepEqual(table.PrimaryKey, []string{"order_id"}) {
+		t.Errorf("primary key = %v", table.PrimaryKey)
+	}
+	if table.Topic() != "orders--v1" || !table.IsKafka() || len(table.Options) != 3 {
+		t.Errorf("options = %+v", table.Options)
+	}
+	if format, ok := table.Option("FORMAT"); !ok || format != "avro-confluent" {
+		t.Errorf("format = %q", format)
+	}
+
+	view := statements[1]
+	if view.Declares() != "big_orders" || !view.View.Temporary {
+		t.Errorf("unexpected view: %+v", view.View)
+	}
+	if reads := view.Reads(); !reflect.DeepEqual(reads, []string{"orders", "customers"}) {
+		t.Errorf("view reads %v", reads)
+	}
+
+	insert := statements[2]
+	if !reflect.DeepEqual(insert.Writes(), []string{"totals"}) {
+		t.Errorf("insert writes %v", insert.Writes())
+	}
+	if reads := insert.Reads(); !reflect.DeepEqual(reads, []string{"orders", "lookup.rates"}) {
+		t.Errorf("insert reads %v", reads)
+	}
+
+	if set := statements[3].Set; set.Key != "pipeline.name" || set.Value != "orders" {
+		t.Errorf("set = %+v", set)
+	}
+	if keyword := statements[4].Keyword(2); keyword != "USE CATALOG" {
+		t.Errorf("keyword = %q", keyword)
+	}
+	for i, stmt := range statements {
+		if session := i >= 3; stmt.IsSession() != session {
+			t.Errorf("statement %d: IsSession() = %v", i, stmt.IsSession())
+		}
+	}
+}
+
+func TestParseStatementSetAndCTAS(t *testing.T) {
+	sql := `CREATE TABLE totals WITH ('connector' = 'upsert-kafka', 'topic' = 'totals') AS SELECT id, COUNT(*) FROM orders GROUP BY id;
+EXECUTE STATEMENT SET BEGIN
+  INSERT INTO a SELECT * FROM orders;
+  INSERT OVERWRITE b (id) SELECT id FROM payments;
+END;`
+	statements, err := Parse(sql)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(statements) != 2 {
+		t.Fatalf("got %d statements", len(statements))
+	}
+	ctas := statements[0]
+	if !reflect.DeepEqual(ctas.Writes(), []string{"totals"}) || !reflect.DeepEqual(ctas.Reads(), []string{"orders"}) {
+		t.Errorf("CTAS writes %v and reads %v", ctas.Writes(), ctas.Reads())
+	}
+	set := statements[1]
+	if set.Kind != KindStatementSet || !reflect.DeepEqual(set.Writes(), []string{"a", "b"}) || !reflect.DeepEqual(set.Reads(), []string{"orders", "payments"}) {
+		t.Errorf("statement set writes %v and reads %v", set.Writes(), set.Reads())
+	}
+	if !set.Inserts[1].Overwrite || !reflect.DeepEqual(set.Inserts[1].Columns, []string{"id"}) {
+		t.Errorf("unexpected insert: %+v", set.Inserts[1])
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		sql  string
+		want string
+	}{
+		{"CREATE TABLE t (id STRING) WITH ('connector' = 'kafka", "line 1, column 48: unterminated string literal"},
+		{"SELECT 1;\n/* open", "line 2, column 1: unterminated comment"},
+		{"CREATE TABLE t (id STRING WITH ('a' = 'b')", "line 1, column 16: unclosed '('"},
+		{"CREATE TABLE t (id STRING) WITH ('connector' 'kafka')", "line 1, column 46: expected '=' after option connector"},
+		{"CREATE TABLE (id STRING)", "line 1, column 14: expected a table name"},
+		{"CREATE TABLE t (id) WITH ()", "line 1, column 19: expected a type for column id"},
+		{"INSERT orders SELECT 1", "line 1, column 8: expected INTO or OVERWRITE after INSERT"},
+	}
+	for _, tt := range tests {
+		_, err := Parse(tt.sql)
+		var syntaxErr *Error
+		if !errors.As(err, &syntaxErr) || err.Error() != tt.want {
+			t.Errorf("Parse(%q) error = %v, want %s", tt.sql, err, tt.want)
+		}
+	}
+}
+
+func TestStripComments(t *testing.T) {
+	sql := "SELECT '--not' AS a, /*+ OPTIONS('k'='v') */ b -- trailing\nFROM /* inline */ t /* multi\nline */"
+	want := "SELECT '--not' AS a, /*+ OPTIONS('k'='v') */ b \nFROM   t \n"
+	if got := StripComments(sql); got != want {
+		t.Errorf("StripComments() = %q, want %q", got, want)
+	}
+	if got := StripComments("SELECT 'open"); got != "SELECT 'open" {
+		t.Errorf("unlexable SQL should be kept, got %q", got)
+	}
+}
+
+func TestRewriter(t *testing.T) {
+	sql := `CREATE TABLE orders (id STRING) WITH (
+  'connector' = 'kafka',
+  'topic' = 'orders',
+  'properties.sasl.mechanism' = 'PLAIN',
+  'properties.security.protocol' = 'SASL_SSL'
+);
+CREATE TABLE other (id STRING) WITH ('connector' = 'kafka', 'topic' = 'it''s')`
+	statements, err := Parse(sql)
+	if err != nil {
+		t.Fatal(err)
+	}
+	orders, other := statements[0].Table, statements[1].Table
+
+	r := NewRewriter(sql)
+	r.SetOption(orders, "topic", "run-orders")
+	r.SetOptionAfter(orders, "connector", "scan.startup.mode", "earliest-offset")
+	r.RemoveOption(orders, "properties.sasl.mechanism")
+	r.RemoveOption(orders, "properties.security.protocol")
+	r.SetOption(other, "format", "json")
+	r.RemoveOption(other, "missing")
+
+	want := `CREATE TABLE orders (id STRING) WITH (
+  'connector' = 'kafka',
+  'scan.startup.mode' = 'earliest-offset',
+  'topic' = 'run-orders'
+);
+CREATE TABLE other (id STRING) WITH ('connector' = 'kafka', 'topic' = 'it''s', 'format' = 'json')`
+	if got := r.String(); got != want {
+		t.Errorf("rewritten SQL:\n%s\nwant:\n%s", got, want)
+	}
+
+	rewritten, err := Parse(r.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if topic := rewritten[1].Table.Topic(); topic != "it's" {
+		t.Errorf("topic = %q", topic)
+	}
+	if !strings.Contains(NewRewriter(sql).String(), "SASL_SSL") {
+		t.Error("a rewriter without edits should keep the SQL")
+	}
+}
@@ -0,0 +1,125 @@
+package flinksql
+
+import (
+	"sort"
+	"strings"
+)
+
+// Rewriter edits the WITH options of parsed tables in the SQL they were parsed from. Everything it
+// does not edit, comments and formatting included, is kept as written.
+type Rewriter struct {
+	sql     string
+	edits   []edit
+	removed map[*CreateTable]map[int]bool
+}
+
+// edit replaces sql[start:end] with text
+type edit struct {
+	start, end int
+	text       string
+}
+
+// NewRewriter edits sql, which must be the text the edited statements were parsed from
+func NewRewriter(sql string) *Rewriter {
+	return &Rewriter{sql: sql, removed: make(map[*CreateTable]map[int]bool)}
+}
+
+// SetOption sets a WITH option of a table. A new option is added at the end of the options.
+// Tables without a WITH clause are left alone.
+func (r *Rewriter) SetOption(table *CreateTable, key, value string) {
+	r.SetOptionAfter(table, "", key, value)
+}
+
+// SetOptionAfter sets a WITH option of a table like SetOption, but adds a new option right after
+// the option after, or at the end when the table does not have that one.
+func (r *Rewriter) SetOptionAfter(table *CreateTable, after, key, value string) {
+	if i := table.optionIndex(key); i != -1 {
+		option := table.Options[i]
+		r.edits = append(r.edits, edit{start: option.valueStart, end: option.valueEnd, text: QuoteString(value)})
+		return
+	}
+	if table.withEnd == -1 {
+		return
+	}
+	text := QuoteString(key) + " = " + QuoteString(value)
+	if len(table.Options) == 0 {
+		r.edits = append(r.edits, edit{start: table.withEnd, end: table.withEnd, text: text})
+		return
+	}
+	anchor := table.Options[len(table.Options)-1]
+	if i := table.optionIndex(after); after != "" && i != -1 {
+		anchor = table.Options[i]
+	}
+	r.edits = append(r.edits, edit{start: anchor.end, end: anchor.end, text: "," + r.separator(anchor) + text})
+}
+
+// separator returns what separates an option from the one before it: a line break with the
+// option's indentation when it starts its own line, a space otherwise
+func (r *Rewriter) separator(option Option) string {
+	lineStart := strings.LastIndex(r.sql[:option.start], "\n") + 1
+	if indent := r.sql[lineStart:option.start]; strings.TrimSpace(indent) == "" {
+		return "\n" + indent
+	}
+	return " "
+}
+
+// RemoveOption removes a WITH option of a table, with the comma that separated it
+func (r *Rewriter) RemoveOption(table *CreateTable, key string) {
+	i := table.optionIndex(key)
+	if i == -1 {
+		return
+	}
+	if r.removed[table] == nil {
+		r.removed[table] = make(map[int]bool)
+	}
+	r.removed[table][i] = true
+}
+
+// String returns the SQL with the edits applied
+func (r *Rewriter) String() string {
+	edits := append([]edit(nil), r.edits...)
+	for table, removed := range r.removed {
+		edits = append(edits, removals(table.Options, removed)...)
+	}
+	sort.SliceStable(edits, func(i, j int) bool { return edits[i].start < edits[j].start })
+
+	var out strings.Builder
+	last := 0
+	for _, e := range edits {
+		if e.start < last {
+			// Overlaps an earlier edit, like setting an option after one that is removed
+			continue
+		}
+		out.WriteString(r.sql[last:e.start])
+		out.WriteString(e.text)
+		last = e.end
+	}
+	out.WriteString(r.sql[last:])
+	return out.String()
+}
+
+// removals returns the edits that remove options, taking each run of removed options out with
+// the commas around it so the remaining list stays well-formed
+func removals(options []Option, removed map[int]bool) []edit {
+	var edits []edit
+	for i := 0; i < len(options); i++ {
+		if !removed[i] {
+			continue
+		}
+		first := i
+		for i+1 < len(options) && removed[i+1] {
+			i++
+		}
+		switch {
+		case i+1 < len(options):
+			// Up to the option after the run
+			edits = append(edits, edit{start: options[first].start, end: options[i+1].start})
+		case first > 0:
+			// The run closes the list: from the end of the option before it
+			edits = append(edits, edit{start: options[first-1].end, end: options[i].end})
+		default:
+			edits = append(edits, edit{start: options[first].start, end: options[i].end})
+		}
+	}
+	return edits
+}
@@ -14,6 +14,7 @@ import (
 	"strconv"
 	"strings"
 
+	"pipegen/internal/flinksql"
 	"pipegen/internal/pipeline"
 	"pipegen/internal/templates"
 )
@@ -283,32 +284,26 @@ func (g *ProjectGenerator) generateAVROSchemas() error {
 
 func (g *ProjectGenerator) copyInputSchema(schemasDir string) error {
 	// Read the user-provided schema
-	inputFile, err := os.Open(g.InputSchemaPath)
+	content, err := os.ReadFile(g.InputSchemaPath)
 	if err != nil {
 		return fmt.Errorf("failed to open input schema: %w", err)
 	}
-	defer func() { _ = inputFile.Close() }()
 
-	// Copy to input.avsc for consistency
-	outputPath := filepath.Join(schemasDir, "input.avsc")
-	outputFile, err := os.Create(outputPath)
-	if err != nil {
-		return fmt.Errorf("failed to create input schema file: %w", err)
-	}
-	defer func() { _ = outputFile.Close() }()
-
-	_, err = io.Copy(outputFile, inputFile)
-	if err != nil {
+	// Copy to input.avsc, input.proto or input.json for consistency
+	format := pipeline.DetectSchemaFormat(g.InputSchemaPath, content)
+	outputPath := filepath.Join(schemasDir, "input"+pipeline.SchemaFileExtension(format))
+	if err := os.WriteFile(outputPath, content, 0644); err != nil {
 		return fmt.Errorf("failed to copy schema file: %w", err)
 	}
 
-	fmt.Printf("📋 Using provided input schema: %s\n", g.InputSchemaPath)
+	fmt.Printf("📋 Using provided %s input schema: %s\n", format, g.InputSchemaPath)
 	return nil
 }
 
-// writeInputSchemaContent writes raw schema JSON content to schemas/input.avsc
+// writeInputSchemaContent writes raw schema content to schemas/input.avsc, input.proto or input.json
 func (g *ProjectGenerator) writeInputSchemaContent(schemasDir string, content string) error {
-	outputPath := filepath.Join(schemasDir, "input.avsc")
+	format := pipeline.DetectSchemaFormat("", []byte(content))
+	outputPath := filepath.Join(schemasDir, "input"+pipeline.SchemaFileExtension(format))
 	if err := os.WriteFile(outputPath, []byte(content), 0644); err != nil {
 		return fmt.Errorf("failed to write input schema content: %w", err)
 	}
@@ -496,7 +491,8 @@ func (g *ProjectGenerator) generateSourceTableFromSchema() error {
 	tableName := fmt.Sprintf("%s_input", strings.ToLower(sanitized))
 	topicName := fmt.Sprintf("%s-input", strings.ToLower(sanitized))
 
-	ddl := fmt.Sprintf(`-- Auto-generated from AVRO schema
+	format := schema.SerializationFormat()
+	ddl := fmt.Sprintf(`-- Auto-generated from %s schema
 CREATE TABLE %s (
 %s
 ) WITH (
@@ -504,10 +500,9 @@ CREATE TABLE %s (
   'topic' = '%s',
   'properties.bootstrap.servers' = 'broker:29092',
   'scan.startup.mode' = 'earliest-offset',
-  'format' = 'avro-confluent',
-  'avro-confluent.url' = 'http://schema-registry:8082'
+%s
 );
-`, tableName, strings.Join(cols, ",\n"), topicName)
+`, schemaFormatNames[format], tableName, strings.Join(cols, ",\n"), topicName, flinkFormatOptions(format))
 
 	// Write to sql/01_create_source_table.sql (override existing template)
 	sqlDir := filepath.Join(g.ProjectPath, "sql")
@@ -520,9 +515,78 @@ CREATE TABLE %s (
 	}
 
 	fmt.Printf("🧩 Generated source table DDL at %s\n", target)
+
+	// The output table writes the same format as the input
+	if format != pipeline.SchemaFormatAvro {
+		outputTable := filepath.Join(sqlDir, "02_create_output_table.sql")
+		if content, err := os.ReadFile(outputTable); err == nil {
+			replaced := setTableFormat(string(content), format)
+			replaced = strings.Replace(replaced, "with AVRO format", "with "+schemaFormatNames[format]+" format", 1)
+			if err := os.WriteFile(outputTable, []byte(replaced), 0644); err != nil {
+				return fmt.Errorf("failed to write output table DDL: %w", err)
+			}
+		}
+	}
 	return nil
 }
 
+// schemaFormatNames are the display names of the schema formats in generated SQL comments
+var schemaFormatNames = map[string]string{
+	pipeline.SchemaFormatAvro:     "AVRO",
+	pipeline.SchemaFormatProtobuf: "Protobuf",
+	pipeline.SchemaFormatJSON:     "JSON",
+}
+
+// formatOptions returns the WITH options of a Kafka table reading or writing records of a schema format, in order
+func formatOptions(format string) [][2]string {
+	switch format {
+	case pipeline.SchemaFormatProtobuf:
+		return [][2]string{{"format", "protobuf-confluent"}, {"protobuf-confluent.url", "http://schema-registry:8082"}}
+	case pipeline.SchemaFormatJSON:
+		return [][2]string{{"format", "json"}}
+	}
+	return [][2]string{{"format", "avro-confluent"}, {"avro-confluent.url", "http://schema-registry:8082"}}
+}
+
+// flinkFormatOptions renders the format options of a schema format as lines of a WITH clause
+func flinkFormatOptions(format string) string {
+	var lines []string
+	for _, option := range formatOptions(format) {
+		lines = append(lines, "  "+flinksql.QuoteString(option[0])+" = "+flinksql.QuoteString(option[1]))
+	}
+	return strings.Join(lines, ",\n")
+}
+
+// setTableFormat switches the Kafka tables declared in sql to a schema format. The options of the other
+// formats are removed; the rest of the SQL is kept as written.
+func setTableFormat(sql, format string) string {
+	statements, err := flinksql.Parse(sql)
+	if err != nil {
+		return sql
+	}
+	wanted := formatOptions(format)
+	rewriter := flinksql.NewRewriter(sql)
+	for _, stmt := range statements {
+		table := stmt.Table
+		if table == nil || !table.IsKafka() {
+			continue
+		}
+		for other := range schemaFormatNames {
+			for _, option := range formatOptions(other)[1:] {
+				if other != format {
+					rewriter.RemoveOption(table, option[0])
+				}
+			}
+		}
+		previous := ""
+		for _, option := range wanted {
+			rewriter.SetOptionAfter(table, previous, option[0], option[1])
+			previous = option[0]
+		}
+	}
+	return rewriter.String()
+}
+
 // flinkTypeFromAvroType maps AVRO field types to Flink SQL types (best-effort)
 func (g *ProjectGenerator) flinkTypeFromAvroType(t interface{}) string {
 	switch v := t.(type) {
@@ -0,0 +1,94 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateWithProtobufInputSchema(t *testing.T) {
+	dir := t.TempDir()
+	protoPath := filepath.Join(dir, "order.proto")
+	proto := `syntax = "proto3";
+package shop;
+message Order {
+  string order_id = 1;
+  int64 amount_cents = 2;
+}`
+	if err := os.WriteFile(protoPath, []byte(proto), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	projectPath := filepath.Join(dir, "orders")
+	g, err := NewProjectGenerator("orders", projectPath, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g.LocalMode = true
+	g.SetInputSchemaPath(protoPath)
+	if err := g.generateAVROSchemas(); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.generateSQLStatements(); err != nil {
+		t.Fatal(err)
+	}
+	if err := g.generateSourceTableFromSchema(); err != nil {
+		t.Fatal(err)
+	}
+
+	if copied, err := os.ReadFile(filepath.Join(projectPath, "schemas", "input.proto")); err != nil || string(copied) != proto {
+		t.Fatalf("input schema should be copied to schemas/input.proto: %v", err)
+	}
+	for _, file := range []string{"01_create_source_table.sql", "02_create_output_table.sql"} {
+		sql, err := os.ReadFile(filepath.Join(projectPath, "sql", file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(sql), "'format' = 'protobuf-confluent'") || strings.Contains(string(sql), "avro-confluent") {
+			t.Errorf("%s should use the protobuf-confluent format:\n%s", file, sql)
+		}
+	}
+	source, _ := os.ReadFile(filepath.Join(projectPath, "sql", "01_create_source_table.sql"))
+	if !strings.Contains(string(source), "`amount_cents` BIGINT") {
+		t.Errorf("unexpected source table:\n%s", source)
+	}
+}
+
+func TestFlinkFormatOptions(t *testing.T) {
+	for format, want := range map[string]string{
+		"avro":     "'format' = 'avro-confluent'",
+		"protobuf": "'format' = 'protobuf-confluent'",
+		"json":     "'format' = 'json'",
+	} {
+		if got := flinkFormatOptions(format); !strings.Contains(got, want) {
+			t.Errorf("flinkFormatOptions(%s) = %s, want %s", format, got, want)
+		}
+	}
+}
+
+func TestSetTableFormat(t *testing.T) {
+	sql := `-- Create output table for results with AVRO format
+CREATE TABLE revenue (
+  ` + "`name`" + ` STRING
+) WITH (
+  'connector' = 'kafka',
+  'topic' = 'output-results',
+  'format' = 'avro-confluent',
+  'avro-confluent.url' = 'http://schema-registry:8082'
+);`
+	want := `-- Create output table for results with AVRO format
+CREATE TABLE revenue (
+  ` + "`name`" + ` STRING
+) WITH (
+  'connector' = 'kafka',
+  'topic' = 'output-results',
+  'format' = 'json'
+);`
+	if got := setTableFormat(sql, "json"); got != want {
+		t.Errorf("setTableFormat(json) =\n%s\nwant:\n%s", got, want)
+	}
+	if got := setTableFormat(sql, "avro"); got != sql {
+		t.Errorf("setTableFormat(avro) should keep the table:\n%s", got)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"strings"
 
+	"pipegen/internal/pipeline"
 	"pipegen/internal/templates"
 )
 
@@ -114,10 +115,18 @@ func (g *LLMProjectGenerator) generateLLMSchemas() error {
 		return fmt.Errorf("output schema content is empty - cannot generate schema file")
 	}
 
-	// Write input schema from LLM (standardized to input.avsc)
-	inputPath := filepath.Join(schemasDir, "input.avsc")
-	if err := g.writeLLMSchema(inputPath, inputSchemaContent); err != nil {
-		return fmt.Errorf("failed to write LLM input schema: %w", err)
+	// A provided Protobuf or JSON Schema input schema is written as is
+	if format := pipeline.DetectSchemaFormat("", []byte(inputSchemaContent)); format != pipeline.SchemaFormatAvro {
+		inputPath := filepath.Join(schemasDir, "input"+pipeline.SchemaFileExtension(format))
+		if err := os.WriteFile(inputPath, []byte(inputSchemaContent), 0644); err != nil {
+			return fmt.Errorf("failed to write input schema: %w", err)
+		}
+	} else {
+		// Write input schema from LLM (standardized to input.avsc)
+		inputPath := filepath.Join(schemasDir, "input.avsc")
+		if err := g.writeLLMSchema(inputPath, inputSchemaContent); err != nil {
+			return fmt.Errorf("failed to write LLM input schema: %w", err)
+		}
 	}
 
 	// Write output schema from LLM (keep existing naming to avoid breaking downstream)
@@ -0,0 +1,466 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"math/rand"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/linkedin/goavro/v2"
+)
+
+// maxAVRODepth bounds recursion through self-referencing record types
+const maxAVRODepth = 6
+
+// avroPrimitives are the AVRO type names that never refer to a named type
+var avroPrimitives = map[string]bool{
+	"null": true, "boolean": true, "int": true, "long": true,
+	"float": true, "double": true, "bytes": true, "string": true,
+}
+
+// avroNamedType is a named type definition together with the namespace it was declared in
+type avroNamedType struct {
+	def       map[string]interface{}
+	enclosing string
+}
+
+// avroValueGenerator walks an AVRO type tree and produces values in goavro's native form
+type avroValueGenerator struct {
+	named     map[string]avroNamedType // Named type definitions keyed by full name
+	namespace string                   // Namespace of the top-level record
+	root      map[string]interface{}   // Parsed top-level schema, when the raw content is available
+	rng       *rand.Rand
+	stringFn  func(fieldName string, messageID int) string // Field-name aware string values
+	clock     *eventClock                                  // Virtual clock of reproducible runs (nil reads the wall clock)
+}
+
+// newAVROValueGenerator indexes every named type (record, enum, fixed) declared in the schema
+func newAVROValueGenerator(schema *Schema, rng *rand.Rand, stringFn func(string, int) string) *avroValueGenerator {
+	g := &avroValueGenerator{
+		named:    make(map[string]avroNamedType),
+		rng:      rng,
+		stringFn: stringFn,
+	}
+	if schema == nil {
+		return g
+	}
+	g.namespace = schema.Namespace
+
+	var root map[string]interface{}
+	if schema.Content != "" && json.Unmarshal([]byte(schema.Content), &root) == nil {
+		g.namespace = definitionNamespace(root, "")
+		g.root = root
+		g.collectNamedTypes(root, "")
+		return g
+	}
+	for _, field := range schema.Fields {
+		g.collectNamedTypes(field.Type, schema.Namespace)
+	}
+	return g
+}
+
+// avroFullName resolves a type name against its enclosing namespace
+func avroFullName(name, namespace string) string {
+	if strings.Contains(name, ".") || namespace == "" {
+		return name
+	}
+	return namespace + "." + name
+}
+
+// definitionNamespace returns the namespace of a named definition, which also applies to its children
+func definitionNamespace(def map[string]interface{}, enclosing string) string {
+	name, _ := def["name"].(string)
+	if idx := strings.LastIndex(name, "."); idx >= 0 {
+		return name[:idx]
+	}
+	if ns, ok := def["namespace"].(string); ok {
+		return ns
+	}
+	return enclosing
+}
+
+func (g *avroValueGenerator) collectNamedTypes(avroType interface{}, namespace string) {
+	switch t := avroType.(type) {
+	case []interface{}:
+		for _, branch := range t {
+			g.collectNamedTypes(branch, namespace)
+		}
+	case map[string]interface{}:
+		typeName, _ := t["type"].(string)
+		switch typeName {
+		case "record", "error", "enum", "fixed":
+			ns := definitionNamespace(t, namespace)
+			if name := definitionFullName(t, namespace); name != "" {
+				g.named[name] = avroNamedType{def: t, enclosing: namespace}
+			}
+			if fields, ok := t["fields"].([]interface{}); ok {
+				for _, f := range fields {
+					if fieldMap, ok := f.(map[string]interface{}); ok {
+						g.collectNamedTypes(fieldMap["type"], ns)
+					}
+				}
+			}
+		case "array":
+			g.collectNamedTypes(t["items"], namespace)
+		case "map":
+			g.collectNamedTypes(t["values"], namespace)
+		default:
+			if nested, ok := t["type"].(map[string]interface{}); ok {
+				g.collectNamedTypes(nested, namespace)
+			}
+		}
+	}
+}
+
+// definitionFullName returns the full name of a named definition
+func definitionFullName(def map[string]interface{}, enclosing string) string {
+	name, _ := def["name"].(string)
+	if name == "" {
+		return ""
+	}
+	return avroFullName(name[strings.LastIndex(name, ".")+1:], definitionNamespace(def, enclosing))
+}
+
+// lookup resolves a named type reference, trying the enclosing namespace first
+func (g *avroValueGenerator) lookup(name, namespace string) (avroNamedType, bool) {
+	if named, ok := g.named[avroFullName(name, namespace)]; ok {
+		return named, true
+	}
+	named, ok := g.named[name]
+	return named, ok
+}
+
+// FieldValue generates a value for a top-level field of the schema
+func (g *avroValueGenerator) FieldValue(field SchemaField, messageID int) (interface{}, error) {
+	return g.value(field.Name, field.Type, g.namespace, messageID, 0)
+}
+
+func (g *avroValueGenerator) value(fieldName string, avroType interface{}, namespace string, messageID, depth int) (interface{}, error) {
+	switch t := avroType.(type) {
+	case string:
+		if avroPrimitives[t] {
+			return g.primitive(fieldName, t, messageID), nil
+		}
+		named, ok := g.lookup(t, namespace)
+		if !ok {
+			return nil, fmt.Errorf("unknown AVRO type reference %q", t)
+		}
+		return g.value(fieldName, named.def, named.enclosing, messageID, depth)
+
+	case []interface{}:
+		return g.union(fieldName, t, namespace, messageID, depth)
+
+	case map[string]interface{}:
+		if logical, ok := t["logicalType"].(string); ok {
+			if v, handled := g.logical(fieldName, t, logical, messageID); handled {
+				return v, nil
+			}
+		}
+
+		switch typeValue := t["type"].(type) {
+		case string:
+			switch typeValue {
+			case "record", "error":
+				return g.record(t, definitionNamespace(t, namespace), messageID, depth)
+			case "enum":
+				symbols := stringList(t["symbols"])
+				if len(symbols) == 0 {
+					return nil, fmt.Errorf("enum %v has no symbols", t["name"])
+				}
+				return symbols[g.rng.Intn(len(symbols))], nil
+			case "fixed":
+				size, _ := t["size"].(float64)
+				buf := make([]byte, int(size))
+				_, _ = g.rng.Read(buf)
+				return buf, nil
+			case "array":
+				count := 0
+				if depth < maxAVRODepth {
+					count = 1 + g.rng.Intn(3)
+				}
+				items := make([]interface{}, 0, count)
+				for i := 0; i < count; i++ {
+					item, err := g.value(fieldName, t["items"], namespace, messageID, depth+1)
+					if err != nil {
+						return nil, fmt.Errorf("array item: %w", err)
+					}
+					items = append(items, item)
+				}
+				return items, nil
+			case "map":
+				count := 0
+				if depth < maxAVRODepth {
+					count = 1 + g.rng.Intn(3)
+				}
+				// goavro writes map entries in Go's random iteration order; a single entry keeps
+				// the payloads of reproducible runs byte-identical
+				if g.clock != nil && count > 1 {
+					count = 1
+				}
+				entries := make(map[string]interface{}, count)
+				for i := 0; i < count; i++ {
+					v, err := g.value(fieldName, t["values"], namespace, messageID, depth+1)
+					if err != nil {
+						return nil, fmt.Errorf("map value: %w", err)
+					}
+					entries[fmt.Sprintf("key-%d", i+1)] = v
+				}
+				return entries, nil
+			default:
+				// {"type": "string"} or a reference such as {"type": "com.example.Address"}
+				return g.value(fieldName, typeValue, namespace, messageID, depth)
+			}
+		case nil:
+			return nil, fmt.Errorf("AVRO type definition is missing \"type\"")
+		default:
+			// {"type": {...}} or {"type": [...]}
+			return g.value(fieldName, typeValue, namespace, messageID, depth)
+		}
+	}
+	return nil, fmt.Errorf("unsupported AVRO type %v", avroType)
+}
+
+func (g *avroValueGenerator) record(def map[string]interface{}, namespace string, messageID, depth int) (interface{}, error) {
+	fields, _ := def["fields"].([]interface{})
+	record := make(map[string]interface{}, len(fields))
+	for _, f := range fields {
+		fieldMap, ok := f.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		name, _ := fieldMap["name"].(string)
+		v, err := g.value(name, fieldMap["type"], namespace, messageID, depth+1)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", name, err)
+		}
+		record[name] = v
+	}
+	return record, nil
+}
+
+// union picks a non-null branch at random and wraps the value in goavro's union form.
+// Once the recursion limit is reached the null branch is preferred so recursive types terminate.
+func (g *avroValueGenerator) union(fieldName string, branches []interface{}, namespace string, messageID, depth int) (interface{}, error) {
+	var candidates []interface{}
+	hasNull := false
+	for _, branch := range branches {
+		if s, ok := branch.(string); ok && s == "null" {
+			hasNull = true
+			continue
+		}
+		candidates = append(candidates, branch)
+	}
+	if len(candidates) == 0 || (hasNull && depth >= maxAVRODepth) {
+		return nil, nil
+	}
+
+	branch := candidates[g.rng.Intn(len(candidates))]
+	v, err := g.value(fieldName, branch, namespace, messageID, depth+1)
+	if err != nil {
+		return nil, err
+	}
+	return g.wrapUnion(branch, namespace, v), nil
+}
+
+// wrapUnion wraps a value destined for a union branch using the branch's goavro type name
+func (g *avroValueGenerator) wrapUnion(branch interface{}, namespace string, v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	return goavro.Union(g.branchName(branch, namespace, v), v)
+}
+
+// WrapFieldValue wraps a value for a top-level field whose type is a union, targeting the first non-null branch
+func (g *avroValueGenerator) WrapFieldValue(field SchemaField, v interface{}) interface{} {
+	branches, ok := field.Type.([]interface{})
+	if !ok || v == nil {
+		return v
+	}
+	for _, branch := range branches {
+		if s, ok := branch.(string); ok && s == "null" {
+			continue
+		}
+		return g.wrapUnion(branch, g.namespace, v)
+	}
+	return v
+}
+
+// branchName returns the name goavro uses to identify a union member
+func (g *avroValueGenerator) branchName(branch interface{}, namespace string, v interface{}) string {
+	switch t := branch.(type) {
+	case string:
+		if avroPrimitives[t] {
+			return t
+		}
+		if named, ok := g.lookup(t, namespace); ok {
+			return definitionFullName(named.def, named.enclosing)
+		}
+		return avroFullName(t, namespace)
+	case map[string]interface{}:
+		typeName, _ := t["type"].(string)
+		switch typeName {
+		case "record", "error", "enum", "fixed":
+			return definitionFullName(t, namespace)
+		case "array", "map":
+			return typeName
+		}
+		if logical, ok := t["logicalType"].(string); ok && avroPrimitives[typeName] {
+			return logicalBranchName(t, typeName, logical, v)
+		}
+		if typeName != "" {
+			return g.branchName(typeName, namespace, v)
+		}
+		return g.branchName(t["type"], namespace, v)
+	}
+	return fmt.Sprintf("%v", branch)
+}
+
+var (
+	logicalBranchNamesMu sync.Mutex
+	logicalBranchNames   = map[string]string{}
+)
+
+// logicalBranchName determines whether goavro names a logical-type union member "<type>.<logicalType>"
+// (for the logical types it implements) or just "<type>" (for those it ignores). The answer depends on
+// the goavro version, so it is probed once per logical type and cached.
+func logicalBranchName(def map[string]interface{}, base, logical string, sample interface{}) string {
+	key := base + "." + logical
+	logicalBranchNamesMu.Lock()
+	defer logicalBranchNamesMu.Unlock()
+	if name, ok := logicalBranchNames[key]; ok {
+		return name
+	}
+
+	name := base
+	if branchJSON, err := json.Marshal([]interface{}{"null", def}); err == nil {
+		if codec, err := goavro.NewCodec(string(branchJSON)); err == nil {
+			if _, err := codec.BinaryFromNative(nil, goavro.Union(key, sample)); err == nil {
+				name = key
+			}
+		}
+	}
+	logicalBranchNames[key] = name
+	return name
+}
+
+// primitive generates a value for a primitive AVRO type
+func (g *avroValueGenerator) primitive(fieldName, typeName string, messageID int) interface{} {
+	switch typeName {
+	case "null":
+		return nil
+	case "string":
+		return g.stringFn(fieldName, messageID)
+	case "int":
+		return g.rng.Intn(10000)
+	case "long":
+		return g.clock.Now(messageID).UnixMilli()
+	case "float":
+		return g.rng.Float32() * 1000
+	case "double":
+		return g.rng.Float64() * 1000
+	case "boolean":
+		return g.rng.Intn(2) == 1
+	case "bytes":
+		return []byte(fmt.Sprintf("data-%d", messageID))
+	}
+	return nil
+}
+
+// logical generates values for AVRO logical types; unknown logical types fall back to the underlying type
+func (g *avroValueGenerator) logical(fieldName string, def map[string]interface{}, logical string, messageID int) (interface{}, bool) {
+	base, _ := def["type"].(string)
+	now := g.clock.Now(messageID)
+
+	switch logical {
+	case "timestamp-millis", "timestamp-micros":
+		if base == "long" {
+			return now, true
+		}
+	case "local-timestamp-millis":
+		if base == "long" {
+			return now.UnixMilli(), true
+		}
+	case "local-timestamp-micros":
+		if base == "long" {
+			return now.UnixMicro(), true
+		}
+	case "date":
+		if base == "int" {
+			return now.UTC().Truncate(24 * time.Hour), true
+		}
+	case "time-millis":
+		if base == "int" {
+			return sinceMidnight(now).Truncate(time.Millisecond), true
+		}
+	case "time-micros":
+		if base == "long" {
+			return sinceMidnight(now).Truncate(time.Microsecond), true
+		}
+	case "uuid":
+		if base == "string" {
+			id, err := uuid.NewRandomFromReader(g.rng)
+			if err != nil {
+				return uuid.NewString(), true
+			}
+			return id.String(), true
+		}
+	case "decimal":
+		if base == "bytes" || base == "fixed" {
+			return g.decimal(def), true
+		}
+	case "duration":
+		if base == "fixed" {
+			// months, days and milliseconds as little-endian unsigned ints
+			buf := make([]byte, 12)
+			buf[0] = byte(g.rng.Intn(12))
+			buf[4] = byte(g.rng.Intn(28))
+			buf[8] = byte(g.rng.Intn(256))
+			return buf, true
+		}
+	}
+	return nil, false
+}
+
+// decimal produces a *big.Rat that fits the declared precision, scale and (for fixed) size
+func (g *avroValueGenerator) decimal(def map[string]interface{}) *big.Rat {
+	precision, _ := def["precision"].(float64)
+	scale, _ := def["scale"].(float64)
+
+	digits := int(precision)
+	if size, ok := def["size"].(float64); ok {
+		if maxDigits := int(math.Floor((8*size - 1) * math.Log10(2))); maxDigits < digits {
+			digits = maxDigits
+		}
+	}
+	if digits > 18 {
+		digits = 18
+	}
+	if digits < 1 {
+		return big.NewRat(0, 1)
+	}
+
+	unscaled := g.rng.Int63n(int64(math.Pow10(digits)))
+	denominator := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(scale)), nil)
+	return new(big.Rat).SetFrac(big.NewInt(unscaled), denominator)
+}
+
+func sinceMidnight(t time.Time) time.Duration {
+	t = t.UTC()
+	return t.Sub(time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC))
+}
+
+func stringList(raw interface{}) []string {
+	list, _ := raw.([]interface{})
+	out := make([]string, 0, len(list))
+	for _, item := range list {
+		if s, ok := item.(string); ok {
+			out = append(out, s)
+		}
+	}
+	return out
+}
@@ -0,0 +1,142 @@
+package pipeline
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+const complexTestSchema = `{
+  "type": "record",
+  "name": "Order",
+  "namespace": "com.example.orders",
+  "fields": [
+    {"name": "order_id", "type": {"type": "string", "logicalType": "uuid"}},
+    {"name": "created_at", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "updated_at", "type": {"type": "long", "logicalType": "timestamp-micros"}},
+    {"name": "order_date", "type": {"type": "int", "logicalType": "date"}},
+    {"name": "order_time", "type": {"type": "int", "logicalType": "time-millis"}},
+    {"name": "total", "type": {"type": "bytes", "logicalType": "decimal", "precision": 10, "scale": 2}},
+    {"name": "checksum", "type": {"type": "fixed", "name": "MD5", "size": 16}},
+    {"name": "price", "type": {"type": "fixed", "name": "Price", "size": 4, "logicalType": "decimal", "precision": 8, "scale": 2}},
+    {"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["NEW", "PAID", "SHIPPED"]}},
+    {"name": "shipping", "type": {
+      "type": "record", "name": "Address", "namespace": "com.example.common",
+      "fields": [
+        {"name": "street", "type": "string"},
+        {"name": "zip", "type": ["null", "string"]}
+      ]
+    }},
+    {"name": "billing", "type": ["null", "com.example.common.Address"]},
+    {"name": "items", "type": {"type": "array", "items": {
+      "type": "record", "name": "LineItem",
+      "fields": [
+        {"name": "sku", "type": "string"},
+        {"name": "quantity", "type": "int"},
+        {"name": "tags", "type": {"type": "map", "values": {"type": "array", "items": "long"}}}
+      ]
+    }}},
+    {"name": "attributes", "type": {"type": "map", "values": ["null", "double", "Status"]}},
+    {"name": "discount", "type": ["null", {"type": "long", "logicalType": "timestamp-millis"}, "string"]},
+    {"name": "parent", "type": ["null", {
+      "type": "record", "name": "Node",
+      "fields": [
+        {"name": "value", "type": "int"},
+        {"name": "next", "type": ["null", "Node"]}
+      ]
+    }]}
+  ]
+}`
+
+func loadTestSchema(t *testing.T, content string) *Schema {
+	t.Helper()
+	dir := t.TempDir()
+	path := filepath.Join(dir, "order.avsc")
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	schema, err := NewSchemaLoader(dir).loadSchema(path)
+	if err != nil {
+		t.Fatalf("failed to load schema: %v", err)
+	}
+	return schema
+}
+
+func TestGenerateDynamicMessageEncodesComplexSchema(t *testing.T) {
+	schema := loadTestSchema(t, complexTestSchema)
+	codec, err := goavro.NewCodec(schema.Content)
+	if err != nil {
+		t.Fatalf("failed to create codec: %v", err)
+	}
+
+	p := &Producer{schema: schema, codec: codec, rng: rand.New(rand.NewSource(11))}
+	p.avroValues = newAVROValueGenerator(schema, p.rng, p.generateStringValue)
+
+	for i := 0; i < 200; i++ {
+		message, err := p.generateDynamicMessage(i)
+		if err != nil {
+			t.Fatalf("message %d: failed to generate: %v", i, err)
+		}
+		binary, err := codec.BinaryFromNative(nil, message)
+		if err != nil {
+			t.Fatalf("message %d: failed to encode: %v", i, err)
+		}
+		if _, _, err := codec.NativeFromBinary(binary); err != nil {
+			t.Fatalf("message %d: failed to decode: %v", i, err)
+		}
+	}
+}
+
+func TestAVROValueGeneratorUnionEncoding(t *testing.T) {
+	schema := loadTestSchema(t, complexTestSchema)
+	g := newAVROValueGenerator(schema, rand.New(rand.NewSource(5)), func(name string, id int) string { return name })
+
+	fieldByName := func(name string) SchemaField {
+		for _, f := range schema.Fields {
+			if f.Name == name {
+				return f
+			}
+		}
+		t.Fatalf("field %s not found", name)
+		return SchemaField{}
+	}
+
+	v, err := g.FieldValue(fieldByName("billing"), 1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	wrapped, ok := v.(map[string]interface{})
+	if !ok {
+		t.Fatalf("expected union map, got %T", v)
+	}
+	if _, ok := wrapped["com.example.common.Address"]; !ok {
+		t.Errorf("expected union keyed by full name, got keys %v", wrapped)
+	}
+
+	// Declarative generator values are wrapped for union fields
+	if got := g.WrapFieldValue(SchemaField{Name: "zip", Type: []interface{}{"null", "string"}}, "12345"); !mapHasKey(got, "string") {
+		t.Errorf("expected string union wrapping, got %v", got)
+	}
+	if got := g.WrapFieldValue(SchemaField{Name: "zip", Type: "string"}, "12345"); got != "12345" {
+		t.Errorf("expected non-union value to be unchanged, got %v", got)
+	}
+}
+
+func TestAVROValueGeneratorUnknownReference(t *testing.T) {
+	g := newAVROValueGenerator(nil, rand.New(rand.NewSource(1)), func(string, int) string { return "" })
+	if _, err := g.FieldValue(SchemaField{Name: "x", Type: "com.example.Missing"}, 1); err == nil {
+		t.Error("expected error for unknown type reference")
+	}
+}
+
+func mapHasKey(v interface{}, key string) bool {
+	m, ok := v.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	_, ok = m[key]
+	return ok
+}
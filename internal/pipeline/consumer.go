@@ -1,8 +1,16 @@
 package pipeline
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/linkedin/goavro/v2"
@@ -12,39 +20,188 @@ import (
 
 // Consumer handles Kafka message consumption and validation
 type Consumer struct {
-	config    *Config
-	reader    *kafka.Reader
-	codec     *goavro.Codec
-	srClient  *srclient.SchemaRegistryClient
-	startTime time.Time
+	config         *Config
+	reader         *kafka.Reader
+	codec          *goavro.Codec // Reader schema for topics without their own: decoded records are resolved to it
+	srClient       *srclient.SchemaRegistryClient
+	startTime      time.Time
+	sinks          []*sinkState                              // Output topics in subscription order; the first is the primary sink
+	observers      []func(map[string]interface{})            // Called with every decoded record of the primary sink
+	topicObservers map[string][]func(map[string]interface{}) // Called with every decoded record of one sink topic
+	consumed       int64                                     // Messages processed successfully (atomic)
+	failed         int64                                     // Messages that failed processing (atomic)
+	latency        *latencyTracker                           // Produce-to-output latency of timed records
+	validators     map[string]*RecordValidator               // Validation rules per output topic (topics without rules are not validated)
+	dlq            *deadLetterQueue                          // Captures the messages that fail processing (nil only counts them)
+
+	fetchSchema  func(id int) (*registeredSchema, error) // Looks up writer schemas by ID (nil decodes with the reader schema)
+	writersMu    sync.Mutex
+	writers      map[writerKey]*writerSchema // Writer schemas by reader schema and Schema Registry ID
+	schemaCounts map[int]int64               // Messages decoded per writer schema ID
 }
 
-// NewConsumer creates a new Kafka consumer
+// writerKey identifies a cached writer schema; the resolution depends on the reader schema of the topic
+type writerKey struct {
+	reader *goavro.Codec
+	id     int
+}
+
+// registeredSchema is a schema fetched from Schema Registry
+type registeredSchema struct {
+	definition string
+	format     string // SchemaFormatAvro, SchemaFormatProtobuf or SchemaFormatJSON
+}
+
+// writerSchema is a cached writer schema with the resolver to the reader schema, if they differ
+type writerSchema struct {
+	codec    *goavro.Codec
+	resolver *schemaResolver
+	format   string     // Serialization format of the writer schema (empty for AVRO)
+	proto    *protoFile // Parsed Protobuf writer schema
+	err      error      // Lookup failure, cached so unknown IDs are not fetched again
+}
+
+// liveConsumerStats holds the latest statistics of the consumer for live displays
+var liveConsumerStats atomic.Pointer[ConsumerStats]
+
+// LiveConsumerStats returns the latest statistics of the running consumer, or nil before it reports
+func LiveConsumerStats() *ConsumerStats {
+	return liveConsumerStats.Load()
+}
+
+// NewConsumer creates a new Kafka consumer; it subscribes to its sinks when consumption starts
 func NewConsumer(config *Config) (*Consumer, error) {
-	// For demo purposes, the consumer reads from the output-results topic
-	// to demonstrate that the pipeline is processing messages correctly
-	topicName := "output-results"
-
-	fmt.Printf("[Consumer] Creating consumer with bootstrap servers: %s\n", config.BootstrapServers)
-	reader := kafka.NewReader(kafka.ReaderConfig{
-		Brokers:  []string{config.BootstrapServers},
-		Topic:    topicName,
+	return &Consumer{
+		config:  config,
+		latency: newLatencyTracker(),
+	}, nil
+}
+
+// AddSink subscribes the consumer to an output topic. With an expected count, the consumer waits for
+// that many messages of the topic; sinks without one are read for as long as the consumer runs.
+func (c *Consumer) AddSink(sink SourceTable, expected int64) {
+	if s := c.sink(sink.Topic); s != nil {
+		s.expected = expected
+		return
+	}
+	c.sinks = append(c.sinks, &sinkState{table: sink.Table, topic: sink.Topic, expected: expected})
+}
+
+// CaptureDeadLetters writes the messages that fail processing to a dead-letter file at path and, with a
+// topic, forwards them to that dead-letter topic
+func (c *Consumer) CaptureDeadLetters(path, topic string) {
+	c.dlq = newDeadLetterQueue(path, c.config.BootstrapServers, topic)
+}
+
+// CloseDeadLetters flushes the dead letters and reports them; it returns nil when nothing was captured
+func (c *Consumer) CloseDeadLetters() (*DeadLetterReport, error) {
+	if c.dlq == nil {
+		return nil, nil
+	}
+	err := c.dlq.Close()
+	if report := c.dlq.Report(); report.Count > 0 {
+		return report, err
+	}
+	return nil, err
+}
+
+// sink returns the state of an output topic, or nil if the consumer is not subscribed to it
+func (c *Consumer) sink(topic string) *sinkState {
+	for _, s := range c.sinks {
+		if s.topic == topic {
+			return s
+		}
+	}
+	return nil
+}
+
+// primary reports whether a message belongs to the primary sink (every message without sinks)
+func (c *Consumer) primary(topic string) bool {
+	return len(c.sinks) == 0 || c.sinks[0].topic == topic
+}
+
+// openReader joins one consumer group that reads every sink topic
+func (c *Consumer) openReader() {
+	if c.reader != nil {
+		return
+	}
+	topics := make([]string, len(c.sinks))
+	for i, s := range c.sinks {
+		topics[i] = s.topic
+	}
+
+	fmt.Printf("[Consumer] Creating consumer with bootstrap servers: %s\n", c.config.BootstrapServers)
+	readerConfig := kafka.ReaderConfig{
+		Brokers:  []string{c.config.BootstrapServers},
 		GroupID:  fmt.Sprintf("pipegen-consumer-%d", time.Now().Unix()),
 		MaxBytes: 10e6, // 10MB
-	})
+	}
+	if len(topics) == 1 {
+		readerConfig.Topic = topics[0]
+	} else {
+		readerConfig.GroupTopics = topics
+	}
+	c.reader = kafka.NewReader(readerConfig)
 
-	fmt.Printf("[Consumer] Reader configured with brokers: %v\n", config.BootstrapServers)
+	fmt.Printf("[Consumer] Reader subscribed to %s\n", strings.Join(topics, ", "))
+}
 
-	return &Consumer{
-		config: config,
-		reader: reader,
-	}, nil
+// describeSinks lists the sink topics with their expected counts
+func (c *Consumer) describeSinks() string {
+	parts := make([]string, len(c.sinks))
+	for i, s := range c.sinks {
+		parts[i] = s.topic
+		if s.expected > 0 {
+			parts[i] += fmt.Sprintf(" (expecting %d messages)", s.expected)
+		}
+	}
+	if len(parts) == 1 {
+		return "topic: " + parts[0]
+	}
+	return "topics: " + strings.Join(parts, ", ")
 }
 
-// StartWithExpectedCount begins consuming messages and stops after reaching expected count or context cancellation
+// sinksComplete reports whether every sink with an expected count got its messages; it is false
+// when no sink has an expected count
+func (c *Consumer) sinksComplete() bool {
+	awaited := false
+	for _, s := range c.sinks {
+		if s.expected <= 0 {
+			continue
+		}
+		awaited = true
+		if atomic.LoadInt64(&s.messages) < s.expected {
+			return false
+		}
+	}
+	return awaited
+}
+
+// StartWithExpectedCount begins consuming messages and stops once every sink got its expected messages,
+// after 30 seconds without a first message, or on context cancellation. Without sinks it reads topic
+// until expectedMessages were consumed.
 func (c *Consumer) StartWithExpectedCount(ctx context.Context, topic string, expectedMessages int64) error {
-	fmt.Printf("👂 Starting consumer for topic: %s (expecting %d messages)\n", topic, expectedMessages)
+	if len(c.sinks) == 0 {
+		c.AddSink(SourceTable{Table: "output", Topic: topic}, expectedMessages)
+	}
+	fmt.Printf("👂 Starting consumer for %s\n", c.describeSinks())
+	return c.consume(ctx, true)
+}
+
+// Start begins consuming messages from the specified topic (or the added sinks) until the context is cancelled
+func (c *Consumer) Start(ctx context.Context, topic string) error {
+	if len(c.sinks) == 0 {
+		c.AddSink(SourceTable{Table: "output", Topic: topic}, 0)
+	}
+	fmt.Printf("👂 Starting consumer for %s\n", c.describeSinks())
+	return c.consume(ctx, false)
+}
+
+// consume reads the sink topics; bounded consumers stop once the sinks are complete or no message arrives
+func (c *Consumer) consume(ctx context.Context, bounded bool) error {
+	c.openReader()
 	c.startTime = time.Now()
+	liveEndToEnd.Store(c.latency)
 
 	// Update global status to show consumer has started
 	globalPipelineStatus.Consumer.Active = true
@@ -65,18 +222,28 @@ func (c *Consumer) StartWithExpectedCount(ctx context.Context, topic string, exp
 	for {
 		select {
 		case <-ctx.Done():
-			fmt.Printf("🛑 Consumer stopping due to context cancellation. Consumed %d messages (%d errors)\n", messageCount, errorCount)
+			c.reportStatus(messageCount, errorCount)
+			if bounded {
+				fmt.Printf("🛑 Consumer stopping due to context cancellation. Consumed %d messages (%d errors)\n", messageCount, errorCount)
+			} else {
+				fmt.Printf("🛑 Consumer stopping. Consumed %d messages (%d errors)\n", messageCount, errorCount)
+			}
 			return c.reader.Close()
 
 		default:
-			// Check if we've reached the expected message count
-			if expectedMessages > 0 && messageCount >= expectedMessages {
-				fmt.Printf("✅ Consumer completed successfully! Consumed %d/%d expected messages\n", messageCount, expectedMessages)
+			// Check if every sink got its expected messages
+			if bounded && c.sinksComplete() {
+				c.reportStatus(messageCount, errorCount)
+				if len(c.sinks) == 1 {
+					fmt.Printf("✅ Consumer completed successfully! Consumed %d/%d expected messages\n", messageCount, c.sinks[0].expected)
+				} else {
+					fmt.Printf("✅ Consumer completed successfully! Every output topic got its expected messages (%s)\n", sinkProgress(c.SinkStats()))
+				}
 				return c.reader.Close()
 			}
 
 			// Check for timeout if no messages received recently
-			if time.Since(lastMessageTime) > noMessageTimeout && messageCount == 0 {
+			if bounded && time.Since(lastMessageTime) > noMessageTimeout && messageCount == 0 {
 				fmt.Printf("⏰ Consumer stopping - no messages received for %v\n", noMessageTimeout)
 				return c.reader.Close()
 			}
@@ -97,12 +264,23 @@ func (c *Consumer) StartWithExpectedCount(ctx context.Context, topic string, exp
 			}
 
 			// Process message
-			if err := c.processMessage(&message); err != nil {
+			err = c.processMessage(&message)
+			if sink := c.sink(message.Topic); sink != nil {
+				sink.record(len(message.Key)+len(message.Value), err)
+			}
+			if err != nil {
 				fmt.Printf("⚠️  Failed to process message: %v\n", err)
 				errorCount++
+				atomic.AddInt64(&c.failed, 1)
+				if c.dlq != nil {
+					if err := c.dlq.capture(&message, err); err != nil {
+						fmt.Printf("⚠️  %v\n", err)
+					}
+				}
 			} else {
 				messageCount++
 				lastMessageTime = time.Now()
+				atomic.AddInt64(&c.consumed, 1)
 			}
 
 			// Commit message
@@ -113,131 +291,53 @@ func (c *Consumer) StartWithExpectedCount(ctx context.Context, topic string, exp
 			// Log progress periodically (every 5 seconds)
 			now := time.Now()
 			if now.Sub(lastLogTime) >= 5*time.Second {
-				elapsed := now.Sub(c.startTime)
-				rate := float64(messageCount) / elapsed.Seconds()
-
-				// Update global pipeline status
-				globalPipelineStatus.Consumer.MessagesProcessed = messageCount
-				globalPipelineStatus.Consumer.Rate = rate
-				globalPipelineStatus.Consumer.Errors = errorCount
-				globalPipelineStatus.Consumer.Elapsed = elapsed
-				globalPipelineStatus.Consumer.Active = true
-
+				c.reportStatus(messageCount, errorCount)
 				lastLogTime = now
 
-				// Show progress towards expected count
-				if expectedMessages > 0 {
-					progress := float64(messageCount) / float64(expectedMessages) * 100
-					fmt.Printf("📊 Consumer progress: %d/%d messages (%.1f%% complete)\n", messageCount, expectedMessages, progress)
+				// Show progress towards the expected counts
+				if bounded && len(c.sinks) == 1 && c.sinks[0].expected > 0 {
+					progress := float64(messageCount) / float64(c.sinks[0].expected) * 100
+					fmt.Printf("📊 Consumer progress: %d/%d messages (%.1f%% complete)\n", messageCount, c.sinks[0].expected, progress)
+				} else if progress := sinkProgress(c.SinkStats()); bounded && progress != "" {
+					fmt.Printf("📊 Consumer progress: %s\n", progress)
 				}
 			}
 		}
 	}
 }
 
-// Start begins consuming messages from the specified topic
-func (c *Consumer) Start(ctx context.Context, topic string) error {
-	fmt.Printf("👂 Starting consumer for topic: %s\n", topic)
-	c.startTime = time.Now()
-
-	// Update global status to show consumer has started
+// reportStatus publishes the consumer progress to the global pipeline status and live displays
+func (c *Consumer) reportStatus(messageCount, errorCount int64) {
+	elapsed := time.Since(c.startTime)
+	globalPipelineStatus.Consumer.MessagesProcessed = messageCount
+	globalPipelineStatus.Consumer.Rate = float64(messageCount) / elapsed.Seconds()
+	globalPipelineStatus.Consumer.Errors = errorCount
+	globalPipelineStatus.Consumer.Elapsed = elapsed
 	globalPipelineStatus.Consumer.Active = true
-	globalPipelineStatus.Consumer.MessagesProcessed = 0
-	globalPipelineStatus.Consumer.Rate = 0
-	globalPipelineStatus.Consumer.Errors = 0
-	globalPipelineStatus.Consumer.Elapsed = 0
-
-	// Note: When using GroupID, Kafka manages offsets automatically
-	// Manual offset setting is not supported with consumer groups
-
-	messageCount := 0
-	errorCount := 0
-	lastLogTime := c.startTime
-
-	for {
-		select {
-		case <-ctx.Done():
-			fmt.Printf("🛑 Consumer stopping. Consumed %d messages (%d errors)\n", messageCount, errorCount)
-			return c.reader.Close()
-
-		default:
-			// Set deadline for read operation
-			readCtx, cancel := context.WithTimeout(ctx, time.Second)
-			message, err := c.reader.FetchMessage(readCtx)
-			cancel()
-
-			if err != nil {
-				// Check if it's a timeout (no messages available)
-				if err == context.DeadlineExceeded {
-					continue
-				}
-				fmt.Printf("❌ Consumer error: %v\n", err)
-				errorCount++
-				continue
-			}
-
-			// Process message
-			if err := c.processMessage(&message); err != nil {
-				fmt.Printf("⚠️  Failed to process message: %v\n", err)
-				errorCount++
-			} else {
-				messageCount++
-			}
-
-			// Commit message
-			if err := c.reader.CommitMessages(ctx, message); err != nil {
-				fmt.Printf("⚠️  Failed to commit message: %v\n", err)
-			}
-
-			// Log progress periodically (every 5 seconds instead of 10)
-			now := time.Now()
-			if now.Sub(lastLogTime) >= 5*time.Second {
-				elapsed := now.Sub(c.startTime)
-				rate := float64(messageCount) / elapsed.Seconds()
-
-				// Update global pipeline status
-				globalPipelineStatus.Consumer.MessagesProcessed = int64(messageCount)
-				globalPipelineStatus.Consumer.Rate = rate
-				globalPipelineStatus.Consumer.Errors = int64(errorCount)
-				globalPipelineStatus.Consumer.Elapsed = elapsed
-				globalPipelineStatus.Consumer.Active = true
-
-				lastLogTime = now
-			}
-		}
-	}
+	globalPipelineStatus.Consumer.Sinks = c.SinkStats()
+	liveConsumerStats.Store(c.GetStats())
 }
 
 // processMessage validates and processes a consumed message
 func (c *Consumer) processMessage(msg *kafka.Message) error {
+	outputAt := time.Now()
+
 	// Basic message validation
 	if msg.Value == nil {
 		return fmt.Errorf("received null message value")
 	}
 
-	// AVRO deserialization if codec is available
-	if c.codec != nil {
-		// Check for Confluent wire format (magic byte + schema ID + AVRO data)
-		if len(msg.Value) < 5 {
-			return fmt.Errorf("message too short for Confluent wire format: %d bytes", len(msg.Value))
-		}
-
-		// Validate magic byte
-		if msg.Value[0] != 0x00 {
-			return fmt.Errorf("invalid magic byte: expected 0x00, got 0x%02x", msg.Value[0])
-		}
-
-		// Extract schema ID (bytes 1-4, big-endian)
-		_ = int(msg.Value[1])<<24 | int(msg.Value[2])<<16 | int(msg.Value[3])<<8 | int(msg.Value[4])
-
-		// Extract AVRO data (skip magic byte + schema ID)
-		avroData := msg.Value[5:]
-
-		// Deserialize AVRO message
-		_, _, err := c.codec.NativeFromBinary(avroData)
+	// AVRO deserialization if a reader schema or Schema Registry is available
+	reader := c.readerCodec(msg.Topic)
+	if reader != nil || (c.fetchSchema != nil && len(msg.Value) > 0 && msg.Value[0] == 0x00) {
+		native, schemaID, err := c.decodeWireFormat(reader, msg.Value)
 		if err != nil {
-			return fmt.Errorf("failed to deserialize AVRO message: %w", err)
+			return err
 		}
+		c.countSchema(schemaID)
+		record, _ := native.(map[string]interface{})
+		c.timeRecord(msg, record, outputAt)
+		c.observe(msg, record)
 
 		// Message processed successfully (detailed logging removed for cleaner output)
 	} else {
@@ -246,6 +346,18 @@ func (c *Consumer) processMessage(msg *kafka.Message) error {
 			return fmt.Errorf("message validation failed: %w", err)
 		}
 
+		// JSON output records are observed and timed as well
+		var record map[string]interface{}
+		if len(c.observers) > 0 || len(c.topicObservers) > 0 || c.config.LatencyField != "" || len(c.validators) > 0 {
+			decoder := json.NewDecoder(bytes.NewReader(msg.Value))
+			decoder.UseNumber()
+			if decoder.Decode(&record) != nil {
+				record = nil
+			}
+		}
+		c.timeRecord(msg, record, outputAt)
+		c.observe(msg, record)
+
 		// Log message details (limited to avoid spam)
 		if len(msg.Value) > 0 {
 			fmt.Printf("✅ Processed message: topic=%s partition=%d offset=%d size=%d bytes\n",
@@ -259,6 +371,187 @@ func (c *Consumer) processMessage(msg *kafka.Message) error {
 	return nil
 }
 
+// decodeWireFormat decodes Confluent wire format (magic byte + schema ID + AVRO, Protobuf or JSON data)
+// with the writer schema and resolves AVRO records to the reader schema, if any. It returns the writer schema ID.
+func (c *Consumer) decodeWireFormat(reader *goavro.Codec, data []byte) (interface{}, int, error) {
+	if len(data) < 5 {
+		return nil, 0, fmt.Errorf("message too short for Confluent wire format: %d bytes", len(data))
+	}
+
+	// Validate magic byte
+	if data[0] != 0x00 {
+		return nil, 0, fmt.Errorf("invalid magic byte: expected 0x00, got 0x%02x", data[0])
+	}
+
+	// Extract schema ID (bytes 1-4, big-endian)
+	schemaID := int(binary.BigEndian.Uint32(data[1:5]))
+	writer, err := c.writerSchema(reader, schemaID)
+	if err != nil {
+		return nil, schemaID, err
+	}
+
+	switch writer.format {
+	case SchemaFormatProtobuf:
+		native, err := decodeProtobufPayload(writer.proto, data[5:])
+		if err != nil {
+			return nil, schemaID, fmt.Errorf("failed to deserialize Protobuf message with schema %d: %w", schemaID, err)
+		}
+		return native, schemaID, nil
+	case SchemaFormatJSON:
+		var native map[string]interface{}
+		decoder := json.NewDecoder(bytes.NewReader(data[5:]))
+		decoder.UseNumber()
+		if err := decoder.Decode(&native); err != nil {
+			return nil, schemaID, fmt.Errorf("failed to deserialize JSON message with schema %d: %w", schemaID, err)
+		}
+		return native, schemaID, nil
+	}
+
+	// Deserialize with the writer schema, then resolve to the reader schema
+	native, _, err := writer.codec.NativeFromBinary(data[5:])
+	if err != nil {
+		return nil, schemaID, fmt.Errorf("failed to deserialize AVRO message with schema %d: %w", schemaID, err)
+	}
+	if writer.resolver != nil {
+		if native, err = writer.resolver.resolveRecord(native); err != nil {
+			return nil, schemaID, fmt.Errorf("failed to resolve schema %d to the reader schema: %w", schemaID, err)
+		}
+	}
+	return native, schemaID, nil
+}
+
+// DecodeValue decodes a message key or value the way output records are decoded: Confluent wire format
+// with its writer schema once Schema Registry is set up, otherwise JSON, otherwise text.
+// It returns the writer schema ID, or 0 when the data is not in the wire format.
+func (c *Consumer) DecodeValue(topic string, data []byte) (interface{}, int, error) {
+	if data == nil {
+		return nil, 0, nil
+	}
+	reader := c.readerCodec(topic)
+	if reader != nil || (c.fetchSchema != nil && len(data) > 0 && data[0] == 0x00) {
+		native, schemaID, err := c.decodeWireFormat(reader, data)
+		if err == nil {
+			c.countSchema(schemaID)
+		}
+		return native, schemaID, err
+	}
+
+	var value interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	if err := decoder.Decode(&value); err != nil || decoder.More() {
+		return string(data), 0, nil
+	}
+	return value, 0, nil
+}
+
+// observe validates a decoded record against the rules of its topic and hands it to the observers of its topic
+// and, for the primary sink, to the record observers
+func (c *Consumer) observe(msg *kafka.Message, record map[string]interface{}) {
+	if record == nil {
+		return
+	}
+	if validator := c.validators[msg.Topic]; validator != nil {
+		validator.Check(record, msg.Partition, msg.Offset)
+	}
+	for _, observe := range c.topicObservers[msg.Topic] {
+		observe(record)
+	}
+	if !c.primary(msg.Topic) {
+		return
+	}
+	for _, observe := range c.observers {
+		observe(record)
+	}
+}
+
+// readerCodec returns the reader schema of a topic
+func (c *Consumer) readerCodec(topic string) *goavro.Codec {
+	if s := c.sink(topic); s != nil && s.codec != nil {
+		return s.codec
+	}
+	return c.codec
+}
+
+// SetValidator makes the consumer evaluate validation rules on every decoded record of the validator's topic
+func (c *Consumer) SetValidator(validator *RecordValidator) {
+	if c.validators == nil {
+		c.validators = make(map[string]*RecordValidator)
+	}
+	c.validators[validator.topic] = validator
+	validators := c.orderedValidators()
+	liveValidation.Store(&validators)
+}
+
+// ValidationReports returns the validation results so far per output topic, primary sink first
+func (c *Consumer) ValidationReports() []*ValidationReport {
+	var reports []*ValidationReport
+	for _, validator := range c.orderedValidators() {
+		reports = append(reports, validator.Report())
+	}
+	return reports
+}
+
+// orderedValidators returns the validators in sink order, followed by those of other topics
+func (c *Consumer) orderedValidators() []*RecordValidator {
+	var validators []*RecordValidator
+	for _, s := range c.sinks {
+		if validator := c.validators[s.topic]; validator != nil {
+			validators = append(validators, validator)
+		}
+	}
+	var others []string
+	for topic := range c.validators {
+		if c.sink(topic) == nil {
+			others = append(others, topic)
+		}
+	}
+	sort.Strings(others)
+	for _, topic := range others {
+		validators = append(validators, c.validators[topic])
+	}
+	return validators
+}
+
+// SinkStats returns the statistics of every output topic, primary sink first
+func (c *Consumer) SinkStats() []SinkStats {
+	var elapsed time.Duration
+	if !c.startTime.IsZero() {
+		elapsed = time.Since(c.startTime)
+	}
+	stats := make([]SinkStats, len(c.sinks))
+	for i, s := range c.sinks {
+		stats[i] = s.stats(elapsed)
+	}
+	return stats
+}
+
+// timeRecord measures the produce-to-output latency of a record that carries its produce time
+func (c *Consumer) timeRecord(msg *kafka.Message, record map[string]interface{}, outputAt time.Time) {
+	if c.latency == nil {
+		return
+	}
+	if at, ok := producedAt(msg, record, c.config.LatencyField); ok {
+		c.latency.observe(at, outputAt)
+	}
+}
+
+// EndToEndLatency returns the produce-to-output latency of the records timed so far, or nil if none carried a produce time
+func (c *Consumer) EndToEndLatency() *LatencyStats {
+	if c.latency == nil {
+		return nil
+	}
+	return c.latency.stats()
+}
+
+// LatencyChart returns the latency distribution and timeline for the execution report, starting at origin
+func (c *Consumer) LatencyChart(origin time.Time) *LatencyChart {
+	if c.latency == nil {
+		return nil
+	}
+	return c.latency.chart(origin)
+}
+
 // validateMessage performs basic validation on consumed messages
 func (c *Consumer) validateMessage(msg *kafka.Message) error {
 	// Check message size
@@ -288,6 +581,84 @@ func (c *Consumer) validateMessage(msg *kafka.Message) error {
 	return nil
 }
 
+// writerSchema returns the cached writer schema of an ID, fetching it from Schema Registry on first use
+func (c *Consumer) writerSchema(reader *goavro.Codec, id int) (*writerSchema, error) {
+	c.writersMu.Lock()
+	defer c.writersMu.Unlock()
+	key := writerKey{reader: reader, id: id}
+	if writer, ok := c.writers[key]; ok {
+		return writer, writer.err
+	}
+	if c.writers == nil {
+		c.writers = make(map[writerKey]*writerSchema)
+	}
+
+	writer := &writerSchema{codec: reader}
+	if c.fetchSchema != nil {
+		writer.err = c.loadWriterSchema(writer, reader, id)
+	}
+	c.writers[key] = writer
+	return writer, writer.err
+}
+
+// loadWriterSchema fetches a writer schema and prepares its resolution to the reader schema
+func (c *Consumer) loadWriterSchema(writer *writerSchema, reader *goavro.Codec, id int) error {
+	registered, err := c.fetchSchema(id)
+	if err != nil {
+		return fmt.Errorf("failed to fetch writer schema %d: %w", id, err)
+	}
+	switch registered.format {
+	case SchemaFormatProtobuf:
+		writer.codec, writer.format = nil, registered.format
+		if writer.proto, err = parseProtoSchema(registered.definition); err != nil {
+			return fmt.Errorf("failed to parse Protobuf writer schema %d: %w", id, err)
+		}
+		return nil
+	case SchemaFormatJSON:
+		writer.codec, writer.format = nil, registered.format
+		return nil
+	}
+
+	schema := registered.definition
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		return fmt.Errorf("failed to create codec for writer schema %d: %w", id, err)
+	}
+	writer.codec = codec
+
+	if reader == nil || codec.CanonicalSchema() == reader.CanonicalSchema() {
+		return nil
+	}
+	if writer.resolver, err = newSchemaResolver(schema, reader.Schema()); err != nil {
+		return fmt.Errorf("writer schema %d: %w", id, err)
+	}
+	fmt.Printf("🔀 Output written with schema %d; resolving it to the reader schema\n", id)
+	return nil
+}
+
+// countSchema counts a message decoded with a writer schema
+func (c *Consumer) countSchema(id int) {
+	c.writersMu.Lock()
+	defer c.writersMu.Unlock()
+	if c.schemaCounts == nil {
+		c.schemaCounts = make(map[int]int64)
+	}
+	c.schemaCounts[id]++
+}
+
+// AddRecordObserver registers a function that sees every decoded record of the primary sink
+func (c *Consumer) AddRecordObserver(observe func(map[string]interface{})) {
+	c.observers = append(c.observers, observe)
+}
+
+// AddTopicObserver registers a function that sees every decoded record of one sink topic
+func (c *Consumer) AddTopicObserver(topic string, observe func(map[string]interface{})) {
+	if c.topicObservers == nil {
+		c.topicObservers = make(map[string][]func(map[string]interface{}))
+	}
+	c.topicObservers[topic] = append(c.topicObservers[topic], observe)
+}
+
 // SetSchema configures the AVRO codec for message decoding
 func (c *Consumer) SetSchema(schemaContent string) error {
 	codec, err := goavro.NewCodec(schemaContent)
@@ -298,26 +669,76 @@ func (c *Consumer) SetSchema(schemaContent string) error {
 	return nil
 }
 
-// InitializeSchemaRegistry initializes the Schema Registry client and fetches the schema for the topic
-func (c *Consumer) InitializeSchemaRegistry(topic string) error {
+// InitializeSchemaRegistry initializes the Schema Registry client and fetches the latest schema of each
+// topic as its reader schema. Topics whose subject cannot be fetched are decoded with their writer schemas.
+func (c *Consumer) InitializeSchemaRegistry(topics ...string) error {
 	// Create Schema Registry client (silently)
 	c.srClient = srclient.CreateSchemaRegistryClient(c.config.SchemaRegistryURL)
 
-	// Get the latest schema for the topic
-	subject := fmt.Sprintf("%s-value", topic)
-	schemaObj, err := c.srClient.GetLatestSchema(subject)
-	if err != nil {
-		return fmt.Errorf("failed to get schema for subject %s: %w", subject, err)
+	// Decode every message with the schema it was written with
+	c.fetchSchema = func(id int) (*registeredSchema, error) {
+		schema, err := c.srClient.GetSchema(id)
+		if err != nil {
+			return nil, err
+		}
+		return &registeredSchema{definition: schema.Schema(), format: registryFormat(schema.SchemaType())}, nil
 	}
 
-	// Create AVRO codec from schema (only log on success)
-	codec, err := goavro.NewCodec(schemaObj.Schema())
-	if err != nil {
-		return fmt.Errorf("failed to create AVRO codec: %w", err)
+	var errs []error
+	for _, topic := range topics {
+		// Get the latest schema for the topic
+		subject := fmt.Sprintf("%s-value", topic)
+		schemaObj, err := c.srClient.GetLatestSchema(subject)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to get schema for subject %s: %w", subject, err))
+			continue
+		}
+
+		// Protobuf and JSON Schema topics are decoded with their writer schemas only
+		if registryFormat(schemaObj.SchemaType()) != SchemaFormatAvro {
+			continue
+		}
+
+		// Create AVRO codec from schema (only log on success)
+		codec, err := goavro.NewCodec(schemaObj.Schema())
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to create AVRO codec for subject %s: %w", subject, err))
+			continue
+		}
+		if s := c.sink(topic); s != nil {
+			s.codec = codec
+		} else {
+			c.codec = codec
+		}
 	}
-	c.codec = codec
+	return errors.Join(errs...)
+}
 
-	return nil
+// registryFormat maps a Schema Registry schema type to its serialization format; no type means AVRO
+func registryFormat(schemaType *srclient.SchemaType) string {
+	if schemaType == nil {
+		return SchemaFormatAvro
+	}
+	switch *schemaType {
+	case srclient.Protobuf:
+		return SchemaFormatProtobuf
+	case srclient.Json:
+		return SchemaFormatJSON
+	}
+	return SchemaFormatAvro
+}
+
+// decodeProtobufPayload decodes the message indexes and the message that follow the schema ID
+func decodeProtobufPayload(file *protoFile, data []byte) (map[string]interface{}, error) {
+	indexes, payload, err := readMessageIndexes(data)
+	if err != nil {
+		return nil, err
+	}
+	message, err := file.messageAt(indexes)
+	if err != nil {
+		return nil, err
+	}
+	return decodeProto(message, payload)
 }
 
 // Close gracefully shuts down the consumer
@@ -329,60 +750,75 @@ func (c *Consumer) Close() {
 
 // ConsumerStats holds consumer statistics
 type ConsumerStats struct {
-	MessagesConsumed int64     `json:"messages_consumed"`
-	MessagesPerSec   float64   `json:"messages_per_sec"`
-	BytesConsumed    int64     `json:"bytes_consumed"`
-	ErrorCount       int64     `json:"error_count"`
-	LastMessageTime  time.Time `json:"last_message_time"`
-	CurrentOffset    int64     `json:"current_offset"`
-	LagMessages      int64     `json:"lag_messages"`
+	MessagesConsumed int64         `json:"messages_consumed"`
+	MessagesPerSec   float64       `json:"messages_per_sec"`
+	BytesConsumed    int64         `json:"bytes_consumed"`
+	ErrorCount       int64         `json:"error_count"`
+	LastMessageTime  time.Time     `json:"last_message_time"`
+	CurrentOffset    int64         `json:"current_offset"`
+	LagMessages      int64         `json:"lag_messages"`
+	SchemaIDs        map[int]int64 `json:"schema_ids,omitempty"` // Messages decoded per writer schema ID
+	EndToEnd         *LatencyStats `json:"end_to_end,omitempty"` // Produce-to-output latency of timed records
+	Sinks            []SinkStats   `json:"sinks,omitempty"`      // Per output topic, primary sink first
 }
 
-// GetStats returns current consumer statistics
-func (c *Consumer) GetStats() *ConsumerStats {
-	// TODO: Implement actual statistics collection
-	return &ConsumerStats{
-		MessagesConsumed: 0,
-		MessagesPerSec:   0,
-		BytesConsumed:    0,
-		ErrorCount:       0,
-		LastMessageTime:  time.Now(),
-		CurrentOffset:    0,
-		LagMessages:      0,
+// SchemaIDSummary lists the writer schema IDs with their message counts, e.g. "12 (950), 13 (50)"
+func (s *ConsumerStats) SchemaIDSummary() string {
+	ids := make([]int, 0, len(s.SchemaIDs))
+	for id := range s.SchemaIDs {
+		ids = append(ids, id)
 	}
+	sort.Ints(ids)
+	parts := make([]string, len(ids))
+	for i, id := range ids {
+		parts[i] = fmt.Sprintf("%d (%d)", id, s.SchemaIDs[id])
+	}
+	return strings.Join(parts, ", ")
 }
 
-// MessageValidator interface for custom message validation
-type MessageValidator interface {
-	Validate(message map[string]interface{}) error
-}
-
-// DefaultValidator provides basic message validation
-type DefaultValidator struct{}
-
-// Validate performs default validation checks
-func (v *DefaultValidator) Validate(message map[string]interface{}) error {
-	// Check for required fields (example)
-	requiredFields := []string{"event_id", "user_id", "event_type", "timestamp_col"}
-
-	for _, field := range requiredFields {
-		if _, exists := message[field]; !exists {
-			return fmt.Errorf("missing required field: %s", field)
+// GetStats returns current consumer statistics
+func (c *Consumer) GetStats() *ConsumerStats {
+	consumed := atomic.LoadInt64(&c.consumed)
+	var messagesPerSec float64
+	if !c.startTime.IsZero() {
+		if elapsed := time.Since(c.startTime).Seconds(); elapsed > 0 {
+			messagesPerSec = float64(consumed) / elapsed
 		}
 	}
 
-	// Validate field types
-	if eventID, ok := message["event_id"].(string); !ok || eventID == "" {
-		return fmt.Errorf("invalid event_id field")
+	var schemaIDs map[int]int64
+	c.writersMu.Lock()
+	if len(c.schemaCounts) > 0 {
+		schemaIDs = make(map[int]int64, len(c.schemaCounts))
+		for id, count := range c.schemaCounts {
+			schemaIDs[id] = count
+		}
 	}
+	c.writersMu.Unlock()
 
-	if userID, ok := message["user_id"].(string); !ok || userID == "" {
-		return fmt.Errorf("invalid user_id field")
+	sinks := c.SinkStats()
+	var bytesConsumed int64
+	for _, s := range sinks {
+		bytesConsumed += s.Bytes
 	}
 
-	if eventType, ok := message["event_type"].(string); !ok || eventType == "" {
-		return fmt.Errorf("invalid event_type field")
+	// TODO: Track offsets and lag
+	return &ConsumerStats{
+		MessagesConsumed: consumed,
+		MessagesPerSec:   messagesPerSec,
+		BytesConsumed:    bytesConsumed,
+		ErrorCount:       atomic.LoadInt64(&c.failed),
+		LastMessageTime:  time.Now(),
+		CurrentOffset:    0,
+		LagMessages:      0,
+		SchemaIDs:        schemaIDs,
+		EndToEnd:         c.EndToEndLatency(),
+		Sinks:            sinks,
 	}
+}
 
-	return nil
+// MessageValidator interface for custom message validation; RecordValidator implements it with
+// the rules of the validation config section
+type MessageValidator interface {
+	Validate(message map[string]interface{}) error
 }
@@ -0,0 +1,198 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"pipegen/internal/flinksql"
+	"pipegen/internal/types"
+)
+
+// StatementGraph is the dependency graph of the SQL statements of a pipeline. A statement depends
+// on the statements that declare the tables and views it reads, writes or copies with LIKE.
+type StatementGraph struct {
+	Statements   []*types.SQLStatement // Deployment order: dependencies first, otherwise filename order
+	Dependencies map[string][]string   // Statement name → the statements it depends on, in deployment order
+	Dangling     []DanglingReference   // Tables used that no statement declares
+	levels       map[string]int        // Statement name → length of its longest dependency chain
+}
+
+// DanglingReference is a table or view a statement uses that no statement of the pipeline declares
+type DanglingReference struct {
+	Statement string
+	Table     string
+}
+
+func (d DanglingReference) String() string {
+	return fmt.Sprintf("%s uses %s, which no statement declares", d.Statement, d.Table)
+}
+
+// CycleError tells that statements depend on each other in a cycle, so none of them can deploy first
+type CycleError struct {
+	Cycle []string // Statement names; the first one is repeated at the end
+}
+
+func (e *CycleError) Error() string {
+	return "dependency cycle between statements: " + strings.Join(e.Cycle, " → ")
+}
+
+// BuildStatementGraph orders statements so every statement deploys after the statements declaring
+// the tables it uses. Statements that do not depend on each other keep their order. Unqualified
+// tables no statement declares are reported as dangling; qualified ones (catalog.db.table) are
+// taken to exist in an external catalog.
+func BuildStatementGraph(statements []*types.SQLStatement) (*StatementGraph, error) {
+	graph := &StatementGraph{
+		Dependencies: make(map[string][]string),
+		levels:       make(map[string]int),
+	}
+
+	// The first statement declaring a table owns it
+	declaredBy := make(map[string]int)
+	uses := make([][]string, len(statements))
+	for i, stmt := range statements {
+		var declares []string
+		declares, uses[i] = statementTables(stmt)
+		for _, table := range declares {
+			if _, exists := declaredBy[table]; !exists {
+				declaredBy[table] = i
+			}
+		}
+	}
+
+	dependsOn := make([]map[int]bool, len(statements))
+	for i, stmt := range statements {
+		dependsOn[i] = make(map[int]bool)
+		for _, table := range uses[i] {
+			owner, declared := declaredBy[flinksql.NormalizeName(table)]
+			switch {
+			case declared && owner != i:
+				dependsOn[i][owner] = true
+			case !declared && !strings.Contains(table, "."):
+				graph.Dangling = append(graph.Dangling, DanglingReference{Statement: stmt.Name, Table: table})
+			}
+		}
+	}
+
+	// Kahn's algorithm, always taking the earliest statement that is ready
+	placed := make([]bool, len(statements))
+	position := make(map[int]int, len(statements))
+	for len(graph.Statements) < len(statements) {
+		next := -1
+		for i := range statements {
+			if !placed[i] && allPlaced(dependsOn[i], placed) {
+				next = i
+				break
+			}
+		}
+		if next == -1 {
+			return nil, &CycleError{Cycle: findCycle(statements, dependsOn, placed)}
+		}
+
+		placed[next] = true
+		position[next] = len(graph.Statements)
+		graph.Statements = append(graph.Statements, statements[next])
+
+		var deps []int
+		level := 0
+		for dep := range dependsOn[next] {
+			deps = append(deps, dep)
+			if l := graph.levels[statements[dep].Name] + 1; l > level {
+				level = l
+			}
+		}
+		sort.Slice(deps, func(a, b int) bool { return position[deps[a]] < position[deps[b]] })
+		names := make([]string, len(deps))
+		for j, dep := range deps {
+			names[j] = statements[dep].Name
+		}
+		graph.Dependencies[statements[next].Name] = names
+		graph.levels[statements[next].Name] = level
+	}
+
+	return graph, nil
+}
+
+// Waves groups the statements for parallel deployment: each wave only depends on earlier waves,
+// so its statements can deploy at the same time once the waves before it are deployed.
+func (g *StatementGraph) Waves() [][]*types.SQLStatement {
+	var waves [][]*types.SQLStatement
+	for _, stmt := range g.Statements {
+		level := g.levels[stmt.Name]
+		for len(waves) <= level {
+			waves = append(waves, nil)
+		}
+		waves[level] = append(waves[level], stmt)
+	}
+	return waves
+}
+
+// statementTables returns the tables and views the SQL of a statement file declares, and the ones
+// it uses without declaring them itself. Statements that do not parse use nothing.
+func statementTables(stmt *types.SQLStatement) (declares, uses []string) {
+	parsed := parseStatements([]*types.SQLStatement{stmt})
+	own := make(map[string]bool)
+	for _, s := range parsed {
+		if name := s.Declares(); name != "" {
+			declares = append(declares, flinksql.NormalizeName(name))
+			own[flinksql.NormalizeName(name)] = true
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, s := range parsed {
+		tables := append(s.Reads(), s.Writes()...)
+		if s.Table != nil && s.Table.Like != "" {
+			tables = append(tables, s.Table.Like)
+		}
+		for _, table := range tables {
+			name := flinksql.NormalizeName(table)
+			if !own[name] && !seen[name] {
+				seen[name] = true
+				uses = append(uses, table)
+			}
+		}
+	}
+	return declares, uses
+}
+
+func allPlaced(deps map[int]bool, placed []bool) bool {
+	for dep := range deps {
+		if !placed[dep] {
+			return false
+		}
+	}
+	return true
+}
+
+// findCycle follows the dependencies of the statements not yet placed, which all wait on another
+// unplaced statement, until one repeats
+func findCycle(statements []*types.SQLStatement, dependsOn []map[int]bool, placed []bool) []string {
+	start := 0
+	for placed[start] {
+		start++
+	}
+
+	var path []int
+	visited := make(map[int]int)
+	for current := start; ; {
+		if at, seen := visited[current]; seen {
+			var cycle []string
+			for _, i := range path[at:] {
+				cycle = append(cycle, statements[i].Name)
+			}
+			return append(cycle, statements[current].Name)
+		}
+		visited[current] = len(path)
+		path = append(path, current)
+
+		// Take the earliest unplaced dependency so the reported cycle is stable
+		next := -1
+		for dep := range dependsOn[current] {
+			if !placed[dep] && (next == -1 || dep < next) {
+				next = dep
+			}
+		}
+		current = next
+	}
+}
@@ -0,0 +1,75 @@
+package pipeline
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"pipegen/internal/types"
+)
+
+func statementNames(statements []*types.SQLStatement) []string {
+	var names []string
+	for _, stmt := range statements {
+		names = append(names, stmt.Name)
+	}
+	return names
+}
+
+func TestBuildStatementGraph(t *testing.T) {
+	statements := []*types.SQLStatement{
+		{Name: "01_insert", Content: "INSERT INTO totals SELECT id, amount FROM big_orders JOIN hive.db.rates ON TRUE"},
+		{Name: "02_orders", Content: "CREATE TABLE orders (id STRING, amount INT) WITH ('connector' = 'kafka', 'topic' = 'orders')"},
+		{Name: "03_totals", Content: "CREATE TABLE `shop`.`totals` (id STRING, amount INT) WITH ('connector' = 'kafka', 'topic' = 'totals')"},
+		{Name: "04_view", Content: "CREATE VIEW big_orders AS SELECT * FROM orders WHERE amount > 100"},
+		{Name: "05_copy", Content: "CREATE TABLE orders_copy WITH ('topic' = 'copy') LIKE orders;\nINSERT INTO orders_copy SELECT * FROM customers"},
+	}
+
+	graph, err := BuildStatementGraph(statements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if order := statementNames(graph.Statements); !reflect.DeepEqual(order, []string{"02_orders", "03_totals", "04_view", "01_insert", "05_copy"}) {
+		t.Errorf("deployment order = %v", order)
+	}
+	wantDeps := map[string][]string{
+		"01_insert": {"03_totals", "04_view"},
+		"02_orders": {},
+		"03_totals": {},
+		"04_view":   {"02_orders"},
+		"05_copy":   {"02_orders"},
+	}
+	if !reflect.DeepEqual(graph.Dependencies, wantDeps) {
+		t.Errorf("dependencies = %v", graph.Dependencies)
+	}
+	// The qualified rates table lives in an external catalog
+	if want := []DanglingReference{{Statement: "05_copy", Table: "customers"}}; !reflect.DeepEqual(graph.Dangling, want) {
+		t.Errorf("dangling references = %v", graph.Dangling)
+	}
+
+	var waves [][]string
+	for _, wave := range graph.Waves() {
+		waves = append(waves, statementNames(wave))
+	}
+	if want := [][]string{{"02_orders", "03_totals"}, {"04_view", "05_copy"}, {"01_insert"}}; !reflect.DeepEqual(waves, want) {
+		t.Errorf("waves = %v", waves)
+	}
+}
+
+func TestBuildStatementGraphCycle(t *testing.T) {
+	statements := []*types.SQLStatement{
+		{Name: "00_source", Content: "CREATE TABLE orders (id STRING) WITH ('connector' = 'datagen')"},
+		{Name: "01_a", Content: "CREATE VIEW a AS SELECT * FROM b JOIN orders ON TRUE"},
+		{Name: "02_b", Content: "CREATE VIEW b AS SELECT * FROM c"},
+		{Name: "03_c", Content: "CREATE VIEW c AS SELECT * FROM a"},
+	}
+
+	_, err := BuildStatementGraph(statements)
+	var cycleErr *CycleError
+	if !errors.As(err, &cycleErr) {
+		t.Fatalf("expected a cycle error, got %v", err)
+	}
+	if want := "dependency cycle between statements: 01_a → 02_b → 03_c → 01_a"; err.Error() != want {
+		t.Errorf("error = %q, want %q", err, want)
+	}
+}
@@ -0,0 +1,307 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Kinds of event-time disorder applied to a message
+const (
+	DisorderDelayed  = "delayed"  // Stamped in the past, within the pipeline's out-of-orderness
+	DisorderJittered = "jittered" // Moved by up to ± the jitter bound
+	DisorderLate     = "late"     // Stamped behind the allowed lateness, so windows should drop it
+)
+
+// maxDisorderLog bounds how many disordered events are kept for the disorder log
+const maxDisorderLog = 100000
+
+// DisorderConfig injects out-of-order and late events to exercise event-time windows and watermarks.
+// Percentages are of all produced messages.
+type DisorderConfig struct {
+	TimestampField  string        `yaml:"timestamp_field" mapstructure:"timestamp_field"`   // Event time field (default: first timestamp field)
+	DelayPercent    float64       `yaml:"delay_percent" mapstructure:"delay_percent"`       // Share of events stamped up to MaxDelay in the past
+	MaxDelay        time.Duration `yaml:"max_delay" mapstructure:"max_delay"`               // Upper bound for delayed events
+	Jitter          time.Duration `yaml:"jitter" mapstructure:"jitter"`                     // Every other event moves by up to ± Jitter
+	LatePercent     float64       `yaml:"late_percent" mapstructure:"late_percent"`         // Share of events stamped later than AllowedLateness
+	AllowedLateness time.Duration `yaml:"allowed_lateness" mapstructure:"allowed_lateness"` // Watermark delay plus allowed lateness of the pipeline
+	IDField         string        `yaml:"id_field" mapstructure:"id_field"`                 // Field that identifies late events in the output (default: event_id, id or first *_id field)
+	OutputIDField   string        `yaml:"output_id_field" mapstructure:"output_id_field"`   // Output field carrying the input ID; nested fields use dots (default: IDField)
+}
+
+// IsEnabled reports whether any disorder is configured
+func (c *DisorderConfig) IsEnabled() bool {
+	return c != nil && (c.DelayPercent > 0 || c.Jitter > 0 || c.LatePercent > 0)
+}
+
+// Validate checks the disorder settings
+func (c *DisorderConfig) Validate() error {
+	if c.DelayPercent < 0 || c.LatePercent < 0 || c.DelayPercent+c.LatePercent > 100 {
+		return fmt.Errorf("delay and late percentages must be positive and add up to at most 100")
+	}
+	if c.DelayPercent > 0 && c.MaxDelay <= 0 {
+		return fmt.Errorf("max delay is required when delaying events")
+	}
+	if c.LatePercent > 0 && c.AllowedLateness <= 0 {
+		return fmt.Errorf("allowed lateness is required when sending late events")
+	}
+	if c.Jitter < 0 {
+		return fmt.Errorf("jitter must not be negative")
+	}
+	return nil
+}
+
+// Summary describes the disorder for the execution plan and report
+func (c *DisorderConfig) Summary() string {
+	if !c.IsEnabled() {
+		return "in order"
+	}
+	var parts []string
+	if c.DelayPercent > 0 {
+		parts = append(parts, fmt.Sprintf("%.1f%% delayed up to %v", c.DelayPercent, c.MaxDelay))
+	}
+	if c.Jitter > 0 {
+		parts = append(parts, fmt.Sprintf("jitter ±%v", c.Jitter))
+	}
+	if c.LatePercent > 0 {
+		parts = append(parts, fmt.Sprintf("%.1f%% later than %v", c.LatePercent, c.AllowedLateness))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// DisorderedEvent records one message whose event time was moved
+type DisorderedEvent struct {
+	MessageID int           `json:"message_id"`
+	ID        string        `json:"id,omitempty"`
+	Kind      string        `json:"kind"`
+	SentAt    time.Time     `json:"sent_at"`
+	EventTime time.Time     `json:"event_time"`
+	Offset    time.Duration `json:"offset_ns"`
+}
+
+// DisorderStats summarizes the injected disorder and how many late events reached the output
+type DisorderStats struct {
+	Summary        string
+	TimestampField string
+	IDField        string
+	Delayed        int64
+	Jittered       int64
+	Late           int64
+	LateInOutput   int64
+	LogPath        string
+}
+
+// LateDropped is the number of late events that never appeared in the output
+func (s DisorderStats) LateDropped() int64 {
+	return s.Late - s.LateInOutput
+}
+
+// eventDisorder stamps event times with configured disorder and tracks late events
+type eventDisorder struct {
+	config  *DisorderConfig
+	field   SchemaField
+	base    string
+	logical string
+	idField string
+	outID   []string // Path of the output field holding the ID
+	rng     *rand.Rand
+	clock   *eventClock // Virtual clock of reproducible runs (nil reads the wall clock)
+
+	newest time.Time // Newest event time stamped so far
+
+	mu      sync.Mutex
+	events  []DisorderedEvent
+	lateIDs map[string]bool // Late events not yet seen in the output
+	stats   DisorderStats
+}
+
+// newEventDisorder resolves the event time and ID fields of the schema
+func newEventDisorder(config *DisorderConfig, schema *Schema, rng *rand.Rand) (*eventDisorder, error) {
+	if schema == nil {
+		return nil, fmt.Errorf("a schema is required to inject event-time disorder")
+	}
+
+	field, ok := SchemaField{}, false
+	if config.TimestampField != "" {
+		if field, ok = schemaField(schema, config.TimestampField); !ok {
+			return nil, fmt.Errorf("timestamp field %s not found in schema %s", config.TimestampField, schema.Name)
+		}
+	} else {
+		for _, f := range schema.Fields {
+			if _, logical, _ := resolveGeneratorTarget(f.Type); strings.Contains(logical, "timestamp") {
+				field, ok = f, true
+				break
+			}
+		}
+		if !ok {
+			return nil, fmt.Errorf("schema %s has no timestamp field; set the event time field explicitly", schema.Name)
+		}
+	}
+
+	base, logical, _ := resolveGeneratorTarget(field.Type)
+	if base != "long" || (logical != "" && !strings.Contains(logical, "timestamp")) {
+		return nil, fmt.Errorf("event time field %s must be a long or timestamp, got %s", field.Name, base)
+	}
+
+	d := &eventDisorder{
+		config:  config,
+		field:   field,
+		base:    base,
+		logical: logical,
+		idField: disorderIDField(config, schema),
+		rng:     rng,
+		lateIDs: make(map[string]bool),
+	}
+	if outField := config.OutputIDField; outField != "" {
+		d.outID = strings.Split(outField, ".")
+	} else if d.idField != "" {
+		d.outID = []string{d.idField}
+	}
+	d.stats = DisorderStats{Summary: config.Summary(), TimestampField: field.Name, IDField: d.idField}
+	return d, nil
+}
+
+// disorderIDField picks the field used to recognize late events in the output
+func disorderIDField(config *DisorderConfig, schema *Schema) string {
+	if config.IDField != "" {
+		return config.IDField
+	}
+	return defaultIDField(schema)
+}
+
+// defaultIDField guesses the field that identifies a record: event_id, id or the first *_id field
+func defaultIDField(schema *Schema) string {
+	for _, name := range []string{"event_id", "id"} {
+		if _, ok := schemaField(schema, name); ok {
+			return name
+		}
+	}
+	for _, f := range schema.Fields {
+		if strings.HasSuffix(f.Name, "_id") {
+			return f.Name
+		}
+	}
+	return ""
+}
+
+// Apply stamps the event time of a message, moving it according to the configured disorder
+func (d *eventDisorder) Apply(message map[string]interface{}, messageID int, wrap func(SchemaField, interface{}) interface{}) {
+	now := d.clock.Now(messageID)
+	if now.After(d.newest) {
+		d.newest = now
+	}
+
+	kind := ""
+	eventTime := now
+	roll := d.rng.Float64() * 100
+	switch {
+	case roll < d.config.LatePercent:
+		kind = DisorderLate
+		behind := d.config.AllowedLateness + time.Duration(d.rng.Int63n(int64(d.config.AllowedLateness)+1))
+		eventTime = d.newest.Add(-behind)
+	case roll < d.config.LatePercent+d.config.DelayPercent:
+		kind = DisorderDelayed
+		eventTime = now.Add(-time.Duration(d.rng.Int63n(int64(d.config.MaxDelay) + 1)))
+	case d.config.Jitter > 0:
+		kind = DisorderJittered
+		eventTime = now.Add(time.Duration(d.rng.Int63n(2*int64(d.config.Jitter)+1)) - d.config.Jitter)
+	}
+	message[d.field.Name] = wrap(d.field, d.nativeTime(eventTime))
+
+	if kind == "" {
+		return
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	switch kind {
+	case DisorderJittered:
+		d.stats.Jittered++
+		return // Jitter touches every event; only delayed and late events are logged
+	case DisorderDelayed:
+		d.stats.Delayed++
+	case DisorderLate:
+		d.stats.Late++
+	}
+
+	id := ""
+	if d.idField != "" {
+		id = keyString(message[d.idField])
+	}
+	if kind == DisorderLate && id != "" {
+		d.lateIDs[id] = true
+	}
+	if len(d.events) < maxDisorderLog {
+		d.events = append(d.events, DisorderedEvent{
+			MessageID: messageID,
+			ID:        id,
+			Kind:      kind,
+			SentAt:    now,
+			EventTime: eventTime,
+			Offset:    now.Sub(eventTime),
+		})
+	}
+}
+
+// nativeTime converts an event time to the goavro native value of the event time field
+func (d *eventDisorder) nativeTime(t time.Time) interface{} {
+	switch d.logical {
+	case "timestamp-millis", "timestamp-micros":
+		return t
+	case "local-timestamp-micros":
+		return t.UnixMicro()
+	default:
+		return t.UnixMilli()
+	}
+}
+
+// ObserveOutput counts late events whose ID is the value of the output ID field of an output record
+func (d *eventDisorder) ObserveOutput(record map[string]interface{}) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	if len(d.lateIDs) == 0 || len(d.outID) == 0 {
+		return
+	}
+	v := fieldValue(record, d.outID)
+	if v == nil {
+		return
+	}
+	if id := keyString(v); d.lateIDs[id] {
+		delete(d.lateIDs, id)
+		d.stats.LateInOutput++
+	}
+}
+
+// Stats returns a snapshot of the disorder statistics
+func (d *eventDisorder) Stats() DisorderStats {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return d.stats
+}
+
+// WriteLog writes the delayed and late events as JSON lines
+func (d *eventDisorder) WriteLog(path string) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	file, err := os.Create(path)
+	if err != nil {
+		return fmt.Errorf("failed to create disorder log: %w", err)
+	}
+	encoder := json.NewEncoder(file)
+	for _, event := range d.events {
+		if err := encoder.Encode(event); err != nil {
+			_ = file.Close()
+			return fmt.Errorf("failed to write disorder log: %w", err)
+		}
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write disorder log: %w", err)
+	}
+	d.stats.LogPath = path
+	return nil
+}
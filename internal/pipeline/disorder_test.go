@@ -0,0 +1,194 @@
+package pipeline
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+const disorderTestSchema = `{
+  "type": "record",
+  "name": "Click",
+  "fields": [
+    {"name": "event_id", "type": "string"},
+    {"name": "page", "type": "string"},
+    {"name": "event_time", "type": {"type": "long", "logicalType": "timestamp-millis"}}
+  ]
+}`
+
+func TestDisorderConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  DisorderConfig
+		wantErr bool
+	}{
+		{name: "delay", config: DisorderConfig{DelayPercent: 10, MaxDelay: time.Second}},
+		{name: "late", config: DisorderConfig{LatePercent: 5, AllowedLateness: time.Minute}},
+		{name: "jitter only", config: DisorderConfig{Jitter: time.Second}},
+		{name: "delay without bound", config: DisorderConfig{DelayPercent: 10}, wantErr: true},
+		{name: "late without lateness", config: DisorderConfig{LatePercent: 10}, wantErr: true},
+		{name: "over 100 percent", config: DisorderConfig{DelayPercent: 60, MaxDelay: time.Second, LatePercent: 50, AllowedLateness: time.Second}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestNewEventDisorderResolvesFields(t *testing.T) {
+	schema := loadTestSchema(t, disorderTestSchema)
+
+	d, err := newEventDisorder(&DisorderConfig{Jitter: time.Second}, schema, nil)
+	if err != nil {
+		t.Fatalf("newEventDisorder() error = %v", err)
+	}
+	if d.field.Name != "event_time" || d.idField != "event_id" {
+		t.Errorf("resolved fields %s/%s, want event_time/event_id", d.field.Name, d.idField)
+	}
+
+	if _, err := newEventDisorder(&DisorderConfig{TimestampField: "page"}, schema, nil); err == nil {
+		t.Error("expected error for a string event time field")
+	}
+	if _, err := newEventDisorder(&DisorderConfig{TimestampField: "missing"}, schema, nil); err == nil {
+		t.Error("expected error for unknown event time field")
+	}
+}
+
+func TestEventDisorderApply(t *testing.T) {
+	schema := loadTestSchema(t, disorderTestSchema)
+	codec, err := goavro.NewCodec(schema.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	config := &DisorderConfig{DelayPercent: 20, MaxDelay: 5 * time.Second, LatePercent: 10, AllowedLateness: time.Minute}
+	d, err := newEventDisorder(config, schema, rand.New(rand.NewSource(3)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	wrap := func(_ SchemaField, v interface{}) interface{} { return v }
+
+	const total = 2000
+	for i := 0; i < total; i++ {
+		message := map[string]interface{}{"event_id": fmt.Sprintf("event_id-%d", i), "page": "/home"}
+		before := time.Now()
+		d.Apply(message, i, wrap)
+
+		eventTime := message["event_time"].(time.Time)
+		if behind := before.Sub(eventTime); behind > 2*time.Minute+time.Second {
+			t.Fatalf("event %d stamped %v behind, beyond twice the allowed lateness", i, behind)
+		}
+		if _, err := codec.BinaryFromNative(nil, message); err != nil {
+			t.Fatalf("event %d: encode error = %v", i, err)
+		}
+	}
+
+	stats := d.Stats()
+	if stats.Late < 150 || stats.Late > 250 {
+		t.Errorf("expected ~10%% late events, got %d/%d", stats.Late, total)
+	}
+	if stats.Delayed < 330 || stats.Delayed > 470 {
+		t.Errorf("expected ~20%% delayed events, got %d/%d", stats.Delayed, total)
+	}
+	for _, event := range d.events {
+		if event.Kind == DisorderLate && event.Offset < config.AllowedLateness {
+			t.Errorf("late event %d is only %v behind", event.MessageID, event.Offset)
+		}
+		if event.Kind == DisorderDelayed && event.Offset > config.MaxDelay+time.Second {
+			t.Errorf("delayed event %d is %v behind, beyond the max delay", event.MessageID, event.Offset)
+		}
+	}
+
+	// Late events found in output records are counted once
+	var lateID string
+	for id := range d.lateIDs {
+		lateID = id
+		break
+	}
+	d.ObserveOutput(map[string]interface{}{"event_id": lateID, "count": int64(1)})
+	d.ObserveOutput(map[string]interface{}{"event_id": map[string]interface{}{"string": lateID}})
+	if got := d.Stats(); got.LateInOutput != 1 || got.LateDropped() != got.Late-1 {
+		t.Errorf("expected one late event in the output, got %+v", got)
+	}
+}
+
+func TestEventDisorderObserveOutputIDField(t *testing.T) {
+	schema := loadTestSchema(t, disorderTestSchema)
+	d, err := newEventDisorder(&DisorderConfig{LatePercent: 100, AllowedLateness: time.Second}, schema, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.lateIDs["5"] = true
+
+	// A count or window value equal to a late ID is not the late event
+	d.ObserveOutput(map[string]interface{}{"event_id": "7", "count": int64(5), "window_bucket": "5"})
+	if got := d.Stats().LateInOutput; got != 0 {
+		t.Fatalf("expected no late event in the output, got %d", got)
+	}
+	d.ObserveOutput(map[string]interface{}{"event_id": "5", "count": int64(1)})
+	if got := d.Stats().LateInOutput; got != 1 {
+		t.Fatalf("expected one late event in the output, got %d", got)
+	}
+
+	// The output can carry the ID in a field of its own
+	d, err = newEventDisorder(&DisorderConfig{LatePercent: 100, AllowedLateness: time.Second, OutputIDField: "click.id"}, schema, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	d.lateIDs["5"] = true
+	d.ObserveOutput(map[string]interface{}{"event_id": "5"})
+	d.ObserveOutput(map[string]interface{}{"click": map[string]interface{}{"id": "5"}})
+	if got := d.Stats().LateInOutput; got != 1 {
+		t.Errorf("expected the late event to be found in click.id only, got %d", got)
+	}
+}
+
+func TestEventDisorderWriteLog(t *testing.T) {
+	schema := loadTestSchema(t, disorderTestSchema)
+	d, err := newEventDisorder(&DisorderConfig{LatePercent: 100, AllowedLateness: time.Second}, schema, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		d.Apply(map[string]interface{}{"event_id": "e"}, i, func(_ SchemaField, v interface{}) interface{} { return v })
+	}
+
+	path := filepath.Join(t.TempDir(), "disorder.jsonl")
+	if err := d.WriteLog(path); err != nil {
+		t.Fatalf("WriteLog() error = %v", err)
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		var event DisorderedEvent
+		if err := json.Unmarshal(scanner.Bytes(), &event); err != nil {
+			t.Fatalf("invalid log line %q: %v", scanner.Text(), err)
+		}
+		if event.Kind != DisorderLate {
+			t.Errorf("unexpected kind %s", event.Kind)
+		}
+		lines++
+	}
+	if lines != 3 {
+		t.Errorf("expected 3 logged events, got %d", lines)
+	}
+	if d.Stats().LogPath != path {
+		t.Errorf("expected log path to be recorded")
+	}
+}
@@ -0,0 +1,213 @@
+package pipeline
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// DeadLetterFile is the name of the dead-letter file in the report directory of an execution
+const DeadLetterFile = "dlq.jsonl"
+
+// Headers added to records forwarded to the dead-letter topic, next to the original headers
+const (
+	DeadLetterErrorHeader     = "pipegen-dlq-error"
+	DeadLetterTopicHeader     = "pipegen-dlq-topic"
+	DeadLetterPartitionHeader = "pipegen-dlq-partition"
+	DeadLetterOffsetHeader    = "pipegen-dlq-offset"
+)
+
+// dlqForwardTimeout bounds a write to the dead-letter topic, so a missing broker cannot stall the consumer
+const dlqForwardTimeout = 5 * time.Second
+
+// DeadLetter is an output record the consumer could not process, as written to the dead-letter file.
+// Key and value keep their raw bytes (base64 in JSON) so the record can be decoded again later.
+type DeadLetter struct {
+	Topic     string             `json:"topic"`
+	Partition int                `json:"partition"`
+	Offset    int64              `json:"offset"`
+	Timestamp time.Time          `json:"timestamp"`
+	Key       []byte             `json:"key"`
+	Headers   []DeadLetterHeader `json:"headers,omitempty"`
+	Value     []byte             `json:"value"`
+	Error     string             `json:"error"`
+}
+
+// DeadLetterHeader is a Kafka header of a dead letter; the value is base64 in JSON
+type DeadLetterHeader struct {
+	Key   string `json:"key"`
+	Value []byte `json:"value"`
+}
+
+// newDeadLetter captures a message with the error that made it fail
+func newDeadLetter(msg *kafka.Message, cause error) *DeadLetter {
+	letter := &DeadLetter{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Timestamp: msg.Time,
+		Key:       msg.Key,
+		Value:     msg.Value,
+		Error:     cause.Error(),
+	}
+	for _, header := range msg.Headers {
+		letter.Headers = append(letter.Headers, DeadLetterHeader{Key: header.Key, Value: header.Value})
+	}
+	return letter
+}
+
+// message rebuilds the Kafka message of a dead letter for the dead-letter topic, with headers that tell
+// where it came from and why it failed
+func (d *DeadLetter) message() kafka.Message {
+	headers := make([]kafka.Header, 0, len(d.Headers)+4)
+	for _, header := range d.Headers {
+		headers = append(headers, kafka.Header{Key: header.Key, Value: header.Value})
+	}
+	headers = append(headers,
+		kafka.Header{Key: DeadLetterErrorHeader, Value: []byte(d.Error)},
+		kafka.Header{Key: DeadLetterTopicHeader, Value: []byte(d.Topic)},
+		kafka.Header{Key: DeadLetterPartitionHeader, Value: []byte(strconv.Itoa(d.Partition))},
+		kafka.Header{Key: DeadLetterOffsetHeader, Value: []byte(strconv.FormatInt(d.Offset, 10))},
+	)
+	return kafka.Message{Key: d.Key, Value: d.Value, Headers: headers}
+}
+
+// DeadLetterReport tells how many output records failed processing and where they were captured
+type DeadLetterReport struct {
+	Path      string // Dead-letter file, empty when no record failed
+	Topic     string // Dead-letter topic the records were forwarded to, if any
+	Count     int64  // Records captured
+	Forwarded int64  // Records written to the dead-letter topic
+}
+
+// Summary describes where the failed records went, e.g. "3 records in reports/<id>/dlq.jsonl"
+func (r *DeadLetterReport) Summary() string {
+	summary := fmt.Sprintf("%d record(s) in %s", r.Count, r.Path)
+	if r.Topic != "" {
+		summary += fmt.Sprintf(", %d forwarded to %s", r.Forwarded, r.Topic)
+	}
+	return summary
+}
+
+// deadLetterQueue captures the records the consumer fails to process. The file is created with the first
+// dead letter, so runs without failures leave none behind.
+type deadLetterQueue struct {
+	mu      sync.Mutex
+	path    string
+	file    *os.File
+	encoder *json.Encoder
+	writer  *kafka.Writer // Forwards dead letters to the dead-letter topic (nil keeps them in the file only)
+	report  DeadLetterReport
+	closed  bool
+}
+
+// newDeadLetterQueue captures dead letters in path and, with a topic, forwards them to it
+func newDeadLetterQueue(path, bootstrapServers, topic string) *deadLetterQueue {
+	q := &deadLetterQueue{path: path, report: DeadLetterReport{Topic: topic}}
+	if topic != "" {
+		q.writer = &kafka.Writer{
+			Addr:                   kafka.TCP(bootstrapServers),
+			Topic:                  topic,
+			Balancer:               &kafka.Hash{},
+			BatchTimeout:           10 * time.Millisecond,
+			AllowAutoTopicCreation: true,
+		}
+	}
+	return q
+}
+
+// capture writes a failed message to the dead-letter file and forwards it to the dead-letter topic
+func (q *deadLetterQueue) capture(msg *kafka.Message, cause error) error {
+	letter := newDeadLetter(msg, cause)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return fmt.Errorf("dead-letter queue is closed")
+	}
+	if q.file == nil {
+		if err := os.MkdirAll(filepath.Dir(q.path), 0755); err != nil {
+			return fmt.Errorf("failed to create dead-letter directory: %w", err)
+		}
+		file, err := os.Create(q.path)
+		if err != nil {
+			return fmt.Errorf("failed to create dead-letter file: %w", err)
+		}
+		q.file, q.encoder = file, json.NewEncoder(file)
+		q.report.Path = q.path
+	}
+	if err := q.encoder.Encode(letter); err != nil {
+		return fmt.Errorf("failed to write dead letter: %w", err)
+	}
+	q.report.Count++
+
+	if q.writer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), dlqForwardTimeout)
+		defer cancel()
+		if err := q.writer.WriteMessages(ctx, letter.message()); err != nil {
+			return fmt.Errorf("failed to forward dead letter to %s: %w", q.report.Topic, err)
+		}
+		q.report.Forwarded++
+	}
+	return nil
+}
+
+// Report returns what the queue captured so far
+func (q *deadLetterQueue) Report() *DeadLetterReport {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	report := q.report
+	return &report
+}
+
+// Close flushes the dead-letter file and the dead-letter topic writer
+func (q *deadLetterQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.closed {
+		return nil
+	}
+	q.closed = true
+	var errs []error
+	if q.file != nil {
+		errs = append(errs, q.file.Close())
+	}
+	if q.writer != nil {
+		errs = append(errs, q.writer.Close())
+	}
+	if err := errors.Join(errs...); err != nil {
+		return fmt.Errorf("failed to close dead-letter queue: %w", err)
+	}
+	return nil
+}
+
+// ReadDeadLetters reads a dead-letter file and hands every dead letter to emit, in file order
+func ReadDeadLetters(r io.Reader, emit func(*DeadLetter) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	line := 0
+	for scanner.Scan() {
+		line++
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var letter DeadLetter
+		if err := json.Unmarshal(scanner.Bytes(), &letter); err != nil {
+			return fmt.Errorf("line %d is not a dead letter: %w", line, err)
+		}
+		if err := emit(&letter); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
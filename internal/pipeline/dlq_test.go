@@ -0,0 +1,145 @@
+package pipeline
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/segmentio/kafka-go"
+)
+
+func TestDeadLetterQueue(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "reports", "abc123", DeadLetterFile)
+	consumer := &Consumer{config: &Config{}}
+	consumer.CaptureDeadLetters(path, "")
+
+	// Nothing captured: no file and no report
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatalf("dead-letter file should not exist before the first failure: %v", err)
+	}
+
+	messages := []kafka.Message{
+		{Topic: "orders", Partition: 2, Offset: 41, Key: []byte("key-1"), Value: []byte{0x00, 0x01}, Time: time.UnixMilli(1700000000000).UTC(),
+			Headers: []kafka.Header{{Key: ProducedAtHeader, Value: []byte("1700000000000000")}}},
+		{Topic: "orders", Partition: 0, Offset: 7, Value: []byte(`{"order_id": "o-2"}`)},
+	}
+	for i := range messages {
+		if err := consumer.dlq.capture(&messages[i], errors.New("message too short for Confluent wire format: 2 bytes")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	report, err := consumer.CloseDeadLetters()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report == nil || report.Count != 2 || report.Path != path {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if err := consumer.dlq.capture(&messages[0], errors.New("late")); err == nil {
+		t.Error("expected an error capturing into a closed queue")
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = file.Close() }()
+	var letters []*DeadLetter
+	if err := ReadDeadLetters(file, func(l *DeadLetter) error { letters = append(letters, l); return nil }); err != nil {
+		t.Fatal(err)
+	}
+	if len(letters) != 2 {
+		t.Fatalf("got %d dead letters", len(letters))
+	}
+	first := letters[0]
+	if first.Topic != "orders" || first.Partition != 2 || first.Offset != 41 || string(first.Key) != "key-1" ||
+		!reflect.DeepEqual(first.Value, []byte{0x00, 0x01}) || !first.Timestamp.Equal(messages[0].Time) || !strings.Contains(first.Error, "too short") {
+		t.Errorf("unexpected dead letter: %+v", first)
+	}
+	if len(first.Headers) != 1 || first.Headers[0].Key != ProducedAtHeader {
+		t.Errorf("headers = %+v", first.Headers)
+	}
+
+	// Forwarded records keep their key and value and tell where they came from
+	forwarded := first.message()
+	headers := make(map[string]string)
+	for _, h := range forwarded.Headers {
+		headers[h.Key] = string(h.Value)
+	}
+	if string(forwarded.Key) != "key-1" || headers[DeadLetterTopicHeader] != "orders" || headers[DeadLetterPartitionHeader] != "2" ||
+		headers[DeadLetterOffsetHeader] != "41" || headers[DeadLetterErrorHeader] != first.Error || headers[ProducedAtHeader] == "" {
+		t.Errorf("unexpected forwarded message: %+v", forwarded)
+	}
+
+	if err := ReadDeadLetters(strings.NewReader("{\"topic\": \"a\"}\nnot json\n"), func(*DeadLetter) error { return nil }); err == nil || !strings.Contains(err.Error(), "line 2") {
+		t.Errorf("expected an error for line 2, got %v", err)
+	}
+}
+
+func TestTailDeadLetters(t *testing.T) {
+	schema := `{"type": "record", "name": "Order", "fields": [{"name": "order_id", "type": "string"}, {"name": "amount", "type": "double"}]}`
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	payload, err := codec.BinaryFromNative(nil, map[string]interface{}{"order_id": "o-1", "amount": 250.0})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := filepath.Join(t.TempDir(), DeadLetterFile)
+	queue := newDeadLetterQueue(path, "", "")
+	for i, value := range [][]byte{confluentWireFormat(3, payload), []byte(`{"order_id": "o-2", "amount": 5}`), {0x00, 0x01}} {
+		msg := &kafka.Message{Topic: "orders", Partition: i % 2, Offset: int64(i), Value: value}
+		if err := queue.capture(msg, errors.New("unknown schema ID 9")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := queue.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	decoder := &Consumer{config: &Config{}, fetchSchema: func(id int) (*registeredSchema, error) { return &registeredSchema{definition: schema}, nil }}
+	var records []*TailRecord
+	opts := TailOptions{File: path, Partition: -1}
+	emit := func(r *TailRecord) error { records = append(records, r); return nil }
+	if err := tailDeadLetters(context.Background(), opts, decoder, nil, emit); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 3 {
+		t.Fatalf("got %d records", len(records))
+	}
+	if value, _ := records[0].Value.(map[string]interface{}); records[0].SchemaID != 3 || value["order_id"] != "o-1" || records[0].Failure != "unknown schema ID 9" {
+		t.Errorf("unexpected record: %+v", records[0])
+	}
+	if records[2].Error == "" || records[2].Failure == "" {
+		t.Errorf("undecodable dead letter should keep both errors: %+v", records[2])
+	}
+
+	// Partition, filter and limit apply like they do to topics
+	records = nil
+	filter, _ := parseRuleExpr("amount > 100")
+	if err := tailDeadLetters(context.Background(), TailOptions{File: path, Partition: 0, Limit: 1}, decoder, filter, emit); err != nil {
+		t.Fatal(err)
+	}
+	if len(records) != 1 || records[0].Offset != 0 {
+		t.Errorf("unexpected filtered records: %+v", records)
+	}
+
+	var out strings.Builder
+	printer, _ := NewTailPrinter(&out, TailFormatTable)
+	_ = printer.Print(records[0])
+	if header := strings.SplitN(out.String(), "\n", 2)[0]; !strings.HasPrefix(header, "partition  offset  key  failure") {
+		t.Errorf("table should show the failure, got %q", header)
+	}
+
+	if err := (&TailOptions{File: path, FromBeginning: true}).Validate(); err == nil {
+		t.Error("expected an error for --from-beginning with a dead-letter file")
+	}
+}
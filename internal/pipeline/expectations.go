@@ -0,0 +1,595 @@
+package pipeline
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultExpectationsDir is the project directory holding golden-output fixtures for --assert
+const DefaultExpectationsDir = "expectations"
+
+// maxReportedMismatches bounds the mismatches kept for the console and the report
+const maxReportedMismatches = 100
+
+// Files looked up in the expectations directory when expectations.yaml does not name them
+var (
+	defaultFixtureInputs   = []string{"input.jsonl", "input.csv", "input.avro"}
+	defaultExpectedOutputs = []string{"expected.jsonl", "expected.yaml", "expected.yml"}
+)
+
+// Expectations mirrors expectations/expectations.yaml: fixture input records to produce and the
+// output records Flink must compute from them.
+//
+//	input: input.jsonl          # replayed instead of generated data
+//	expected: expected.jsonl    # expected output records (JSONL or a YAML list)
+//	key: [user_id]              # match output records by these fields
+//	tolerance: 0.001            # absolute tolerance of numeric fields
+//	tolerances: {avg_amount: 0.01}
+//	allow_extra: false          # fail on output records nobody expected
+type Expectations struct {
+	Input      string             `yaml:"input"`
+	Expected   string             `yaml:"expected"`
+	Key        []string           `yaml:"key"`
+	Tolerance  float64            `yaml:"tolerance"`
+	Tolerances map[string]float64 `yaml:"tolerances"`
+	AllowExtra bool               `yaml:"allow_extra"`
+
+	Dir     string                   `yaml:"-"` // Directory the expectations were loaded from
+	Records []map[string]interface{} `yaml:"-"` // Expected output records
+}
+
+// LoadExpectations reads the expectations directory: the optional expectations.yaml settings, the
+// fixture input and the expected output records
+func LoadExpectations(dir string) (*Expectations, error) {
+	if _, err := os.Stat(dir); err != nil {
+		return nil, fmt.Errorf("expectations directory not accessible: %w", err)
+	}
+
+	e := &Expectations{Dir: dir}
+	settings := filepath.Join(dir, "expectations.yaml")
+	if content, err := os.ReadFile(settings); err == nil {
+		if err := yaml.Unmarshal(content, e); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", settings, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", settings, err)
+	}
+
+	if e.Input == "" {
+		e.Input = firstExisting(dir, defaultFixtureInputs)
+	}
+	if e.Expected == "" {
+		e.Expected = firstExisting(dir, defaultExpectedOutputs)
+	}
+	if e.Expected == "" {
+		return nil, fmt.Errorf("no expected output in %s (add expected.jsonl or expected.yaml)", dir)
+	}
+	if err := e.loadRecords(); err != nil {
+		return nil, err
+	}
+	return e, nil
+}
+
+// loadRecords reads the expected output records and checks them against the matching settings
+func (e *Expectations) loadRecords() error {
+	if e.Tolerance < 0 {
+		return fmt.Errorf("tolerance must not be negative")
+	}
+	for field, tolerance := range e.Tolerances {
+		if tolerance < 0 {
+			return fmt.Errorf("tolerance of %s must not be negative", field)
+		}
+	}
+
+	records, err := readExpectedRecords(e.path(e.Expected))
+	if err != nil {
+		return err
+	}
+	if len(records) == 0 {
+		return fmt.Errorf("%s holds no expected records", e.Expected)
+	}
+	e.Records = records
+
+	if len(e.Key) > 0 {
+		seen := make(map[string]int, len(records))
+		for i, record := range records {
+			for _, field := range e.Key {
+				if _, ok := record[field]; !ok {
+					return fmt.Errorf("expected record %d has no key field %s", i+1, field)
+				}
+			}
+			key := e.keyOf(record)
+			if first, ok := seen[key]; ok {
+				return fmt.Errorf("expected records %d and %d share the key %s", first+1, i+1, key)
+			}
+			seen[key] = i
+		}
+	}
+	return nil
+}
+
+// firstExisting returns the first of names present in dir, or ""
+func firstExisting(dir string, names []string) string {
+	for _, name := range names {
+		if _, err := os.Stat(filepath.Join(dir, name)); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// path resolves a file named in the expectations relative to their directory
+func (e *Expectations) path(name string) string {
+	if filepath.IsAbs(name) {
+		return name
+	}
+	return filepath.Join(e.Dir, name)
+}
+
+// InputPath returns the fixture input to replay, or "" to produce generated data
+func (e *Expectations) InputPath() string {
+	if e == nil || e.Input == "" {
+		return ""
+	}
+	return e.path(e.Input)
+}
+
+// Summary describes the expectations for the execution plan
+func (e *Expectations) Summary() string {
+	summary := fmt.Sprintf("%d expected record(s) from %s", len(e.Records), e.Expected)
+	if len(e.Key) > 0 {
+		summary += fmt.Sprintf(", matched by %s", strings.Join(e.Key, ","))
+	} else {
+		summary += ", matched in any order"
+	}
+	if e.Input != "" {
+		summary += fmt.Sprintf(", input %s", e.Input)
+	}
+	return summary
+}
+
+// readExpectedRecords reads expected output records from a JSONL file or a YAML list
+func readExpectedRecords(path string) ([]map[string]interface{}, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read expected output: %w", err)
+	}
+
+	var records []map[string]interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".json":
+		scanner := bufio.NewScanner(bytes.NewReader(content))
+		scanner.Buffer(make([]byte, 0, 64*1024), 10*1024*1024)
+		for line := 1; scanner.Scan(); line++ {
+			text := strings.TrimSpace(scanner.Text())
+			if text == "" {
+				continue
+			}
+			decoder := json.NewDecoder(strings.NewReader(text))
+			decoder.UseNumber()
+			var record map[string]interface{}
+			if err := decoder.Decode(&record); err != nil {
+				return nil, fmt.Errorf("%s:%d: %w", filepath.Base(path), line, err)
+			}
+			records = append(records, record)
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+	case ".yaml", ".yml":
+		if err := yaml.Unmarshal(content, &records); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported expected output %s (expected .jsonl or .yaml)", filepath.Base(path))
+	}
+	return records, nil
+}
+
+// outputRecorder keeps the decoded output records for the assertions
+type outputRecorder struct {
+	mu      sync.Mutex
+	records []map[string]interface{}
+}
+
+// observe is a consumer record observer
+func (o *outputRecorder) observe(record map[string]interface{}) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.records = append(o.records, record)
+}
+
+func (o *outputRecorder) snapshot() []map[string]interface{} {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return append([]map[string]interface{}(nil), o.records...)
+}
+
+// Kinds of assertion mismatches
+const (
+	MismatchMissing    = "missing"    // Expected record not in the output
+	MismatchUnexpected = "unexpected" // Output record nobody expected
+	MismatchDifferent  = "different"  // Field of a matched record differs
+)
+
+// AssertionMismatch is one difference between the expected and the actual output
+type AssertionMismatch struct {
+	Kind     string
+	Record   string // Key of the record, or the record itself when matching without a key
+	Field    string
+	Expected string
+	Actual   string
+}
+
+// AssertionReport is the outcome of comparing the output topic with the expectations
+type AssertionReport struct {
+	Passed     bool
+	Expected   int // Expected records
+	Actual     int // Output records compared
+	Matched    int // Expected records found in the output with matching fields
+	Failures   int // All mismatches, including those beyond Mismatches
+	Mismatches []AssertionMismatch
+}
+
+// Check compares output records with the expected records. Only the fields of an expected record
+// are compared, so computed fields such as processing times can be left out. With a key, the last
+// output record of each key is compared, as later updates of an aggregate supersede earlier ones.
+func (e *Expectations) Check(actual []map[string]interface{}) *AssertionReport {
+	report := &AssertionReport{Expected: len(e.Records), Actual: len(actual)}
+	if len(e.Key) > 0 {
+		e.checkByKey(actual, report)
+	} else {
+		e.checkUnordered(actual, report)
+	}
+	report.Passed = report.Failures == 0
+	return report
+}
+
+func (e *Expectations) checkByKey(actual []map[string]interface{}, report *AssertionReport) {
+	latest := make(map[string]map[string]interface{}, len(actual))
+	var order []string
+	for _, record := range actual {
+		key := e.keyOf(record)
+		if _, ok := latest[key]; !ok {
+			order = append(order, key)
+		}
+		latest[key] = record
+	}
+	report.Actual = len(latest)
+
+	expectedKeys := make(map[string]bool, len(e.Records))
+	for _, expected := range e.Records {
+		key := e.keyOf(expected)
+		expectedKeys[key] = true
+		got, ok := latest[key]
+		if !ok {
+			report.add(AssertionMismatch{Kind: MismatchMissing, Record: key, Expected: formatRecord(expected)})
+			continue
+		}
+		differs := false
+		for _, field := range sortedFields(expected) {
+			if !valuesMatch(expected[field], got[field], e.tolerance(field)) {
+				differs = true
+				report.add(AssertionMismatch{
+					Kind: MismatchDifferent, Record: key, Field: field,
+					Expected: formatValue(expected[field]), Actual: formatValue(got[field]),
+				})
+			}
+		}
+		if !differs {
+			report.Matched++
+		}
+	}
+
+	if !e.AllowExtra {
+		for _, key := range order {
+			if !expectedKeys[key] {
+				report.add(AssertionMismatch{Kind: MismatchUnexpected, Record: key, Actual: formatRecord(latest[key])})
+			}
+		}
+	}
+}
+
+func (e *Expectations) checkUnordered(actual []map[string]interface{}, report *AssertionReport) {
+	used := make([]bool, len(actual))
+	for _, expected := range e.Records {
+		found := false
+		for i, got := range actual {
+			if !used[i] && e.recordMatches(expected, got) {
+				used[i], found = true, true
+				break
+			}
+		}
+		if found {
+			report.Matched++
+		} else {
+			report.add(AssertionMismatch{Kind: MismatchMissing, Record: formatRecord(expected)})
+		}
+	}
+
+	if !e.AllowExtra {
+		for i, got := range actual {
+			if !used[i] {
+				report.add(AssertionMismatch{Kind: MismatchUnexpected, Record: formatRecord(got)})
+			}
+		}
+	}
+}
+
+// recordMatches reports whether every field of expected matches the output record
+func (e *Expectations) recordMatches(expected, actual map[string]interface{}) bool {
+	for field, value := range expected {
+		if !valuesMatch(value, actual[field], e.tolerance(field)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e *Expectations) tolerance(field string) float64 {
+	if tolerance, ok := e.Tolerances[field]; ok {
+		return tolerance
+	}
+	return e.Tolerance
+}
+
+// keyOf renders the key fields of a record
+func (e *Expectations) keyOf(record map[string]interface{}) string {
+	parts := make([]string, len(e.Key))
+	for i, field := range e.Key {
+		parts[i] = fmt.Sprintf("%s=%s", field, keyValue(record[field]))
+	}
+	return strings.Join(parts, ",")
+}
+
+// keyValue renders a key field so expected and decoded values of the same key compare equal:
+// times in UTC RFC 3339 and numbers without trailing zeros
+func keyValue(v interface{}) string {
+	v = normalizeOutputValue(v)
+	switch t := v.(type) {
+	case time.Time:
+		return t.UTC().Format(time.RFC3339Nano)
+	case string:
+		if parsed, ok := toTime(t); ok {
+			return parsed.UTC().Format(time.RFC3339Nano)
+		}
+		return t
+	}
+	if f, ok := toFloat(v); ok {
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return formatValue(v)
+}
+
+func (r *AssertionReport) add(mismatch AssertionMismatch) {
+	r.Failures++
+	if len(r.Mismatches) < maxReportedMismatches {
+		r.Mismatches = append(r.Mismatches, mismatch)
+	}
+}
+
+// Summary is a one-line verdict
+func (r *AssertionReport) Summary() string {
+	verdict := "PASSED"
+	if !r.Passed {
+		verdict = "FAILED"
+	}
+	return fmt.Sprintf("%s: %d/%d expected records matched, %d output record(s), %d mismatch(es)",
+		verdict, r.Matched, r.Expected, r.Actual, r.Failures)
+}
+
+// Print writes the verdict and the first mismatches to the console
+func (r *AssertionReport) Print(limit int) {
+	if r.Passed {
+		fmt.Printf("✅ Output assertions %s\n", r.Summary())
+		return
+	}
+	fmt.Printf("❌ Output assertions %s\n", r.Summary())
+	for i, m := range r.Mismatches {
+		if i == limit {
+			fmt.Printf("   ... %d more mismatch(es) in the execution report\n", r.Failures-limit)
+			break
+		}
+		fmt.Printf("   %s\n", m)
+	}
+}
+
+// String describes a mismatch on one line
+func (m AssertionMismatch) String() string {
+	switch m.Kind {
+	case MismatchMissing:
+		if m.Expected != "" {
+			return fmt.Sprintf("missing %s: %s", m.Record, m.Expected)
+		}
+		return fmt.Sprintf("missing %s", m.Record)
+	case MismatchUnexpected:
+		if m.Actual != "" {
+			return fmt.Sprintf("unexpected %s: %s", m.Record, m.Actual)
+		}
+		return fmt.Sprintf("unexpected %s", m.Record)
+	default:
+		return fmt.Sprintf("%s: %s expected %s, got %s", m.Record, m.Field, m.Expected, m.Actual)
+	}
+}
+
+// valuesMatch compares an expected value (from JSON or YAML) with a decoded output value
+func valuesMatch(expected, actual interface{}, tolerance float64) bool {
+	if e, ok := expected.(map[string]interface{}); ok {
+		if a, ok := actual.(map[string]interface{}); ok {
+			if mapsMatch(e, a, tolerance) {
+				return true
+			}
+		}
+	}
+	actual = normalizeOutputValue(actual)
+
+	switch e := expected.(type) {
+	case nil:
+		return actual == nil
+	case map[string]interface{}:
+		a, ok := actual.(map[string]interface{})
+		return ok && mapsMatch(e, a, tolerance)
+	case []interface{}:
+		a, ok := actual.([]interface{})
+		if !ok || len(a) != len(e) {
+			return false
+		}
+		for i := range e {
+			if !valuesMatch(e[i], a[i], tolerance) {
+				return false
+			}
+		}
+		return true
+	}
+
+	if at, ok := actual.(time.Time); ok {
+		et, ok := toTime(expected)
+		return ok && et.Equal(at)
+	}
+	if et, ok := expected.(time.Time); ok {
+		at, ok := toTime(actual)
+		return ok && et.Equal(at)
+	}
+
+	ef, eok := toFloat(expected)
+	af, aok := toFloat(actual)
+	if eok && aok {
+		if tolerance == 0 {
+			ei, eint := toInt(expected)
+			ai, aint := toInt(actual)
+			if eint && aint {
+				return ei == ai
+			}
+		}
+		return math.Abs(ef-af) <= tolerance
+	}
+	return fmt.Sprint(expected) == fmt.Sprint(actual)
+}
+
+func mapsMatch(expected, actual map[string]interface{}, tolerance float64) bool {
+	for k, v := range expected {
+		if !valuesMatch(v, actual[k], tolerance) {
+			return false
+		}
+	}
+	return true
+}
+
+// normalizeOutputValue unwraps unions and turns decimals and bytes into comparable values
+func normalizeOutputValue(v interface{}) interface{} {
+	v = unwrapUnion(v)
+	switch t := v.(type) {
+	case *big.Rat:
+		f, _ := t.Float64()
+		return f
+	case []byte:
+		return string(t)
+	}
+	return v
+}
+
+func toFloat(v interface{}) (float64, bool) {
+	switch n := v.(type) {
+	case int:
+		return float64(n), true
+	case int32:
+		return float64(n), true
+	case int64:
+		return float64(n), true
+	case uint64:
+		return float64(n), true
+	case float32:
+		return float64(n), true
+	case float64:
+		return n, true
+	case json.Number:
+		f, err := n.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func toInt(v interface{}) (int64, bool) {
+	switch n := v.(type) {
+	case int:
+		return int64(n), true
+	case int32:
+		return int64(n), true
+	case int64:
+		return n, true
+	case json.Number:
+		i, err := strconv.ParseInt(string(n), 10, 64)
+		return i, err == nil
+	}
+	return 0, false
+}
+
+// Time layouts accepted in expected records
+var expectedTimeLayouts = []string{time.RFC3339Nano, "2006-01-02 15:04:05.999999999", "2006-01-02T15:04:05.999999999", "2006-01-02"}
+
+func toTime(v interface{}) (time.Time, bool) {
+	switch t := v.(type) {
+	case time.Time:
+		return t, true
+	case string:
+		for _, layout := range expectedTimeLayouts {
+			if parsed, err := time.Parse(layout, t); err == nil {
+				return parsed, true
+			}
+		}
+	}
+	if millis, ok := toInt(v); ok {
+		return time.UnixMilli(millis), true
+	}
+	return time.Time{}, false
+}
+
+// formatValue renders a value for mismatch messages
+func formatValue(v interface{}) string {
+	v = normalizeOutputValue(v)
+	switch t := v.(type) {
+	case nil:
+		return "null"
+	case string:
+		return strconv.Quote(t)
+	case time.Time:
+		return t.UTC().Format(time.RFC3339Nano)
+	case map[string]interface{}, []interface{}:
+		if encoded, err := json.Marshal(t); err == nil {
+			return string(encoded)
+		}
+	}
+	return fmt.Sprint(v)
+}
+
+// formatRecord renders a record with sorted fields
+func formatRecord(record map[string]interface{}) string {
+	fields := sortedFields(record)
+	parts := make([]string, len(fields))
+	for i, field := range fields {
+		parts[i] = fmt.Sprintf("%s: %s", field, formatValue(record[field]))
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
+
+func sortedFields(record map[string]interface{}) []string {
+	fields := make([]string, 0, len(record))
+	for field := range record {
+		fields = append(fields, field)
+	}
+	sort.Strings(fields)
+	return fields
+}
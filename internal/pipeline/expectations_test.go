@@ -0,0 +1,185 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"math/big"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeExpectations(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadExpectations(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     map[string]string
+		wantErr   string
+		wantInput string
+		wantCount int
+	}{
+		{
+			name:      "defaults",
+			files:     map[string]string{"input.jsonl": `{"id": 1}`, "expected.jsonl": "{\"id\": 1}\n\n{\"id\": 2}\n"},
+			wantInput: "input.jsonl",
+			wantCount: 2,
+		},
+		{
+			name: "settings and yaml output",
+			files: map[string]string{
+				"expectations.yaml": "expected: totals.yaml\nkey: [user]\ntolerances: {total: 0.5}\n",
+				"totals.yaml":       "- {user: a, total: 10.5}\n- {user: b, total: 3}\n",
+			},
+			wantCount: 2,
+		},
+		{name: "no expected output", files: map[string]string{"input.jsonl": `{"id": 1}`}, wantErr: "no expected output"},
+		{name: "bad json", files: map[string]string{"expected.jsonl": "{\"id\": 1}\n{oops\n"}, wantErr: "expected.jsonl:2"},
+		{
+			name:    "duplicate key",
+			files:   map[string]string{"expectations.yaml": "key: [id]", "expected.jsonl": "{\"id\": 1}\n{\"id\": 1.0}\n"},
+			wantErr: "share the key",
+		},
+		{
+			name:    "missing key field",
+			files:   map[string]string{"expectations.yaml": "key: [id]", "expected.jsonl": `{"name": "a"}`},
+			wantErr: "no key field id",
+		},
+		{
+			name:    "negative tolerance",
+			files:   map[string]string{"expectations.yaml": "tolerance: -1", "expected.jsonl": `{"id": 1}`},
+			wantErr: "must not be negative",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			e, err := LoadExpectations(writeExpectations(t, tt.files))
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("LoadExpectations() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("LoadExpectations() error = %v", err)
+			}
+			if len(e.Records) != tt.wantCount {
+				t.Errorf("loaded %d records, want %d", len(e.Records), tt.wantCount)
+			}
+			if e.Input != tt.wantInput {
+				t.Errorf("Input = %q, want %q", e.Input, tt.wantInput)
+			}
+		})
+	}
+}
+
+func TestExpectationsCheckByKey(t *testing.T) {
+	dir := writeExpectations(t, map[string]string{
+		"expectations.yaml": "key: [user, window_start]\ntolerance: 0.01\n",
+		"expected.jsonl": strings.Join([]string{
+			`{"user": "a", "window_start": "2024-01-01 00:00:00", "total": 10.5, "orders": 2}`,
+			`{"user": "b", "window_start": "2024-01-01 00:00:00", "total": 3, "orders": 1}`,
+			`{"user": "c", "window_start": "2024-01-01 00:00:00", "total": 1, "orders": 1}`,
+		}, "\n"),
+	})
+	e, err := LoadExpectations(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	window := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	actual := []map[string]interface{}{
+		// An early update of a's aggregate is superseded by the later record
+		{"user": "a", "window_start": window, "total": 4.0, "orders": int64(1)},
+		{"user": map[string]interface{}{"string": "b"}, "window_start": window, "total": 3.3, "orders": int64(1)},
+		{"user": "a", "window_start": window, "total": 10.504, "orders": int64(2), "processed_at": time.Now()},
+		{"user": "z", "window_start": window, "total": 1.0, "orders": int64(1)},
+	}
+	report := e.Check(actual)
+
+	if report.Passed || report.Matched != 1 || report.Actual != 3 {
+		t.Fatalf("unexpected report: %s", report.Summary())
+	}
+	kinds := map[string]int{}
+	for _, m := range report.Mismatches {
+		kinds[m.Kind]++
+	}
+	if kinds[MismatchDifferent] != 1 || kinds[MismatchMissing] != 1 || kinds[MismatchUnexpected] != 1 {
+		t.Errorf("mismatches = %v", report.Mismatches)
+	}
+	if m := report.Mismatches[0]; m.Field != "total" || m.Actual != "3.3" {
+		t.Errorf("first mismatch = %+v, want b's total", m)
+	}
+
+	e.AllowExtra = true
+	e.Tolerances = map[string]float64{"total": 0.5}
+	if report := e.Check(actual); report.Failures != 1 {
+		t.Errorf("expected only c to be missing, got %v", report.Mismatches)
+	}
+}
+
+func TestExpectationsCheckUnordered(t *testing.T) {
+	e := &Expectations{Records: []map[string]interface{}{
+		{"word": "a", "count": json.Number("2")},
+		{"word": "a", "count": json.Number("1")},
+	}}
+
+	actual := []map[string]interface{}{
+		{"word": "a", "count": int32(1)},
+		{"word": "a", "count": int32(2)},
+	}
+	if report := e.Check(actual); !report.Passed {
+		t.Errorf("expected the records to match in any order: %v", report.Mismatches)
+	}
+
+	actual = append(actual, map[string]interface{}{"word": "b", "count": int32(1)})
+	report := e.Check(actual)
+	if report.Passed || report.Failures != 1 || report.Mismatches[0].Kind != MismatchUnexpected {
+		t.Errorf("expected one unexpected record, got %v", report.Mismatches)
+	}
+}
+
+func TestValuesMatch(t *testing.T) {
+	ts := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name      string
+		expected  interface{}
+		actual    interface{}
+		tolerance float64
+		want      bool
+	}{
+		{name: "null", expected: nil, actual: nil, want: true},
+		{name: "null union", expected: nil, actual: map[string]interface{}{"string": "x"}, want: false},
+		{name: "exact int", expected: json.Number("9007199254740993"), actual: int64(9007199254740993), want: true},
+		{name: "int off by one", expected: json.Number("9007199254740993"), actual: int64(9007199254740992), want: false},
+		{name: "within tolerance", expected: 1.0, actual: float32(1.05), tolerance: 0.1, want: true},
+		{name: "beyond tolerance", expected: 1.0, actual: 1.2, tolerance: 0.1, want: false},
+		{name: "decimal", expected: json.Number("12.34"), actual: big.NewRat(1234, 100), want: true},
+		{name: "time string", expected: "2024-03-01T12:00:00Z", actual: ts, want: true},
+		{name: "time millis", expected: json.Number("1709294400000"), actual: ts, want: true},
+		{name: "string", expected: "abc", actual: map[string]interface{}{"string": "abc"}, want: true},
+		{name: "bytes", expected: "abc", actual: []byte("abc"), want: true},
+		{name: "nested record", expected: map[string]interface{}{"city": "Paris"}, actual: map[string]interface{}{"city": "Paris", "zip": "75001"}, want: true},
+		{name: "array", expected: []interface{}{1, 2}, actual: []interface{}{int32(1), int32(2)}, want: true},
+		{name: "array length", expected: []interface{}{1}, actual: []interface{}{int32(1), int32(2)}, want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := valuesMatch(tt.expected, tt.actual, tt.tolerance); got != tt.want {
+				t.Errorf("valuesMatch(%v, %v) = %v, want %v", tt.expected, tt.actual, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package pipeline
+
+import (
+	"fmt"
+	"math/rand"
+	"regexp/syntax"
+	"sort"
+	"strings"
+)
+
+// maxRegexRepeat bounds unbounded quantifiers (*, +, {n,}) when generating strings from a pattern
+const maxRegexRepeat = 8
+
+// regexGenerator produces strings that match a regular expression
+type regexGenerator struct {
+	re  *syntax.Regexp
+	rng *rand.Rand
+}
+
+func newRegexGenerator(pattern string, rng *rand.Rand) (*regexGenerator, error) {
+	if pattern == "" {
+		return nil, fmt.Errorf("regex generator requires a pattern")
+	}
+	re, err := syntax.Parse(pattern, syntax.Perl)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex pattern %q: %w", pattern, err)
+	}
+	return &regexGenerator{re: re.Simplify(), rng: rng}, nil
+}
+
+func (g *regexGenerator) Generate(messageID int) interface{} {
+	var sb strings.Builder
+	g.write(&sb, g.re)
+	return sb.String()
+}
+
+func (g *regexGenerator) write(sb *strings.Builder, re *syntax.Regexp) {
+	switch re.Op {
+	case syntax.OpLiteral:
+		for _, r := range re.Rune {
+			sb.WriteRune(r)
+		}
+	case syntax.OpCharClass:
+		sb.WriteRune(g.pickFromClass(re.Rune))
+	case syntax.OpAnyChar, syntax.OpAnyCharNotNL:
+		sb.WriteRune(rune(0x21 + g.rng.Intn(0x7e-0x21)))
+	case syntax.OpCapture:
+		g.write(sb, re.Sub[0])
+	case syntax.OpConcat:
+		for _, sub := range re.Sub {
+			g.write(sb, sub)
+		}
+	case syntax.OpAlternate:
+		g.write(sb, re.Sub[g.rng.Intn(len(re.Sub))])
+	case syntax.OpStar:
+		g.repeat(sb, re.Sub[0], 0, maxRegexRepeat)
+	case syntax.OpPlus:
+		g.repeat(sb, re.Sub[0], 1, maxRegexRepeat)
+	case syntax.OpQuest:
+		g.repeat(sb, re.Sub[0], 0, 1)
+	case syntax.OpRepeat:
+		max := re.Max
+		if max < 0 {
+			max = re.Min + maxRegexRepeat
+		}
+		g.repeat(sb, re.Sub[0], re.Min, max)
+	}
+	// Anchors, word boundaries and empty matches produce no output
+}
+
+func (g *regexGenerator) repeat(sb *strings.Builder, re *syntax.Regexp, min, max int) {
+	n := min
+	if max > min {
+		n += g.rng.Intn(max - min + 1)
+	}
+	for i := 0; i < n; i++ {
+		g.write(sb, re)
+	}
+}
+
+// pickFromClass picks a rune from a character class, preferring printable ASCII
+func (g *regexGenerator) pickFromClass(ranges []rune) rune {
+	type span struct{ lo, hi rune }
+	var printable, all []span
+	for i := 0; i+1 < len(ranges); i += 2 {
+		lo, hi := ranges[i], ranges[i+1]
+		all = append(all, span{lo, hi})
+		if lo < 0x20 {
+			lo = 0x20
+		}
+		if hi > 0x7e {
+			hi = 0x7e
+		}
+		if lo <= hi {
+			printable = append(printable, span{lo, hi})
+		}
+	}
+	candidates := printable
+	if len(candidates) == 0 {
+		candidates = all
+	}
+	if len(candidates) == 0 {
+		return '?'
+	}
+
+	total := 0
+	for _, s := range candidates {
+		total += int(s.hi-s.lo) + 1
+	}
+	n := g.rng.Intn(total)
+	for _, s := range candidates {
+		size := int(s.hi-s.lo) + 1
+		if n < size {
+			return s.lo + rune(n)
+		}
+		n -= size
+	}
+	return candidates[0].lo
+}
+
+// fakerGenerator produces realistic-looking values for a named category
+type fakerGenerator struct {
+	category string
+	rng      *rand.Rand
+}
+
+var (
+	fakerFirstNames = []string{"James", "Mary", "Robert", "Patricia", "John", "Jennifer", "Michael", "Linda", "David", "Elizabeth", "Wei", "Aisha", "Carlos", "Sofia", "Hiroshi", "Fatima", "Liam", "Emma", "Noah", "Olivia"}
+	fakerLastNames  = []string{"Smith", "Johnson", "Williams", "Brown", "Jones", "Garcia", "Miller", "Davis", "Rodriguez", "Martinez", "Chen", "Kim", "Singh", "Müller", "Rossi", "Dubois", "Silva", "Nguyen", "Kowalski", "Ivanov"}
+	fakerDomains    = []string{"example.com", "mail.com", "corp.io", "shop.net", "acme.org"}
+	fakerCountries  = []string{"United States", "Canada", "United Kingdom", "Germany", "France", "Spain", "Italy", "Japan", "Brazil", "India", "Australia", "Mexico"}
+	fakerCodes      = []string{"US", "CA", "GB", "DE", "FR", "ES", "IT", "JP", "BR", "IN", "AU", "MX"}
+	fakerCities     = []string{"New York", "Toronto", "London", "Berlin", "Paris", "Madrid", "Rome", "Tokyo", "São Paulo", "Mumbai", "Sydney", "Mexico City"}
+	fakerStreets    = []string{"Main St", "Oak Ave", "Pine Rd", "Maple Dr", "Cedar Ln", "Elm St", "Lakeview Blvd", "Park Ave"}
+	fakerCompanies  = []string{"Acme Corp", "Globex", "Initech", "Umbrella", "Stark Industries", "Wayne Enterprises", "Hooli", "Vandelay Industries"}
+	fakerJobs       = []string{"Engineer", "Designer", "Analyst", "Manager", "Consultant", "Developer", "Accountant", "Sales Associate"}
+	fakerProducts   = []string{"Laptop", "Headphones", "Coffee Maker", "Running Shoes", "Backpack", "Smartphone", "Desk Lamp", "Water Bottle"}
+	fakerColors     = []string{"red", "green", "blue", "black", "white", "yellow", "purple", "orange"}
+	fakerCurrencies = []string{"USD", "EUR", "GBP", "JPY", "CAD", "AUD", "BRL", "INR"}
+	fakerWords      = []string{"stream", "event", "window", "record", "signal", "metric", "batch", "flow", "queue", "shard"}
+	fakerUserAgents = []string{
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0 Safari/537.36",
+		"Mozilla/5.0 (Macintosh; Intel Mac OS X 14_0) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.0 Safari/605.1.15",
+		"Mozilla/5.0 (X11; Linux x86_64; rv:121.0) Gecko/20100101 Firefox/121.0",
+		"Mozilla/5.0 (iPhone; CPU iPhone OS 17_0 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Mobile/15E148",
+	}
+)
+
+// fakerCategories maps each supported category to its value builder
+var fakerCategories = map[string]func(r *rand.Rand) string{
+	"first_name": func(r *rand.Rand) string { return pick(r, fakerFirstNames) },
+	"last_name":  func(r *rand.Rand) string { return pick(r, fakerLastNames) },
+	"name": func(r *rand.Rand) string {
+		return pick(r, fakerFirstNames) + " " + pick(r, fakerLastNames)
+	},
+	"username": func(r *rand.Rand) string {
+		return strings.ToLower(pick(r, fakerFirstNames)) + fmt.Sprintf("%d", r.Intn(10000))
+	},
+	"email": func(r *rand.Rand) string {
+		return fmt.Sprintf("%s.%s%d@%s", strings.ToLower(pick(r, fakerFirstNames)), strings.ToLower(pick(r, fakerLastNames)), r.Intn(100), pick(r, fakerDomains))
+	},
+	"phone": func(r *rand.Rand) string {
+		return fmt.Sprintf("+1-%03d-%03d-%04d", 200+r.Intn(800), r.Intn(1000), r.Intn(10000))
+	},
+	"country":      func(r *rand.Rand) string { return pick(r, fakerCountries) },
+	"country_code": func(r *rand.Rand) string { return pick(r, fakerCodes) },
+	"city":         func(r *rand.Rand) string { return pick(r, fakerCities) },
+	"street_address": func(r *rand.Rand) string {
+		return fmt.Sprintf("%d %s", 1+r.Intn(9999), pick(r, fakerStreets))
+	},
+	"company":   func(r *rand.Rand) string { return pick(r, fakerCompanies) },
+	"job_title": func(r *rand.Rand) string { return pick(r, fakerJobs) },
+	"product":   func(r *rand.Rand) string { return pick(r, fakerProducts) },
+	"color":     func(r *rand.Rand) string { return pick(r, fakerColors) },
+	"currency":  func(r *rand.Rand) string { return pick(r, fakerCurrencies) },
+	"word":      func(r *rand.Rand) string { return pick(r, fakerWords) },
+	"sentence": func(r *rand.Rand) string {
+		words := make([]string, 4+r.Intn(5))
+		for i := range words {
+			words[i] = pick(r, fakerWords)
+		}
+		return strings.ToUpper(words[0][:1]) + strings.Join(words, " ")[1:] + "."
+	},
+	"ipv4": func(r *rand.Rand) string {
+		return fmt.Sprintf("%d.%d.%d.%d", 1+r.Intn(223), r.Intn(256), r.Intn(256), 1+r.Intn(254))
+	},
+	"url": func(r *rand.Rand) string {
+		return fmt.Sprintf("https://%s/%s/%d", pick(r, fakerDomains), pick(r, fakerWords), r.Intn(1000))
+	},
+	"user_agent": func(r *rand.Rand) string { return pick(r, fakerUserAgents) },
+}
+
+func newFakerGenerator(category string, rng *rand.Rand) (*fakerGenerator, error) {
+	category = strings.ToLower(category)
+	if _, ok := fakerCategories[category]; !ok {
+		return nil, fmt.Errorf("unknown faker category %q (supported: %s)", category, strings.Join(FakerCategories(), ", "))
+	}
+	return &fakerGenerator{category: category, rng: rng}, nil
+}
+
+func (g *fakerGenerator) Generate(messageID int) interface{} {
+	return fakerCategories[g.category](g.rng)
+}
+
+// FakerCategories lists the supported faker categories in alphabetical order
+func FakerCategories() []string {
+	categories := make([]string, 0, len(fakerCategories))
+	for name := range fakerCategories {
+		categories = append(categories, name)
+	}
+	sort.Strings(categories)
+	return categories
+}
+
+func pick(r *rand.Rand, values []string) string {
+	return values[r.Intn(len(values))]
+}
@@ -0,0 +1,403 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// Kinds of poison messages the producer can inject
+const (
+	FaultBadMagic      = "bad-magic"      // Magic byte other than 0x00
+	FaultUnknownSchema = "unknown-schema" // Schema ID that is not registered
+	FaultTruncated     = "truncated"      // AVRO payload cut short
+	FaultNullField     = "null-field"     // null written to a non-null field
+	FaultOversized     = "oversized"      // Valid record padded to OversizedBytes
+	FaultDuplicate     = "duplicate"      // Valid message sent twice
+)
+
+// FaultKinds lists every fault kind in report order
+var FaultKinds = []string{FaultBadMagic, FaultUnknownSchema, FaultTruncated, FaultNullField, FaultOversized, FaultDuplicate}
+
+// defaultOversizedBytes stays below Kafka's default 1 MB message limit so oversized records reach the pipeline
+const defaultOversizedBytes = 900 * 1024
+
+// FaultConfig sets the percentage of produced messages turned into each kind of fault
+type FaultConfig struct {
+	Rates          map[string]float64 `yaml:"rates" mapstructure:"rates"`                     // Percent of messages per fault kind
+	OversizedBytes int                `yaml:"oversized_bytes" mapstructure:"oversized_bytes"` // Size of oversized records (default 900 KiB)
+}
+
+// ParseFaultSpec parses an --inject-faults value: either "kind:percent,..." (e.g. "bad-magic:1%,duplicate:2%")
+// or a single percentage spread evenly over all fault kinds (e.g. "6%")
+func ParseFaultSpec(spec string) (map[string]float64, error) {
+	rates := make(map[string]float64)
+	spec = strings.TrimSpace(spec)
+	if spec == "" {
+		return rates, nil
+	}
+
+	if !strings.Contains(spec, ":") {
+		total, err := parsePercent(spec)
+		if err != nil {
+			return nil, err
+		}
+		for _, kind := range FaultKinds {
+			rates[kind] = total / float64(len(FaultKinds))
+		}
+		return rates, nil
+	}
+
+	for _, part := range strings.Split(spec, ",") {
+		kind, value, ok := strings.Cut(strings.TrimSpace(part), ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid fault %q: expected kind:percent", part)
+		}
+		rate, err := parsePercent(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid fault %q: %w", part, err)
+		}
+		rates[strings.TrimSpace(kind)] = rate
+	}
+	return rates, nil
+}
+
+func parsePercent(value string) (float64, error) {
+	value = strings.TrimSuffix(strings.TrimSpace(value), "%")
+	rate, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid percentage %q", value)
+	}
+	return rate, nil
+}
+
+// IsEnabled reports whether any fault is configured
+func (c *FaultConfig) IsEnabled() bool {
+	if c == nil {
+		return false
+	}
+	for _, rate := range c.Rates {
+		if rate > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// Validate checks fault kinds and rates and applies defaults
+func (c *FaultConfig) Validate() error {
+	total := 0.0
+	for kind, rate := range c.Rates {
+		if !isFaultKind(kind) {
+			return fmt.Errorf("unknown fault %q (expected %s)", kind, strings.Join(FaultKinds, ", "))
+		}
+		if rate < 0 {
+			return fmt.Errorf("fault %s: rate must not be negative", kind)
+		}
+		total += rate
+	}
+	if total > 100 {
+		return fmt.Errorf("fault rates add up to %.1f%%, more than 100%%", total)
+	}
+	if c.OversizedBytes < 0 {
+		return fmt.Errorf("oversized_bytes must not be negative")
+	}
+	if c.OversizedBytes == 0 {
+		c.OversizedBytes = defaultOversizedBytes
+	}
+	return nil
+}
+
+// Summary describes the configured faults for the execution plan and report
+func (c *FaultConfig) Summary() string {
+	if !c.IsEnabled() {
+		return "none"
+	}
+	var parts []string
+	for _, kind := range FaultKinds {
+		if rate := c.Rates[kind]; rate > 0 {
+			parts = append(parts, fmt.Sprintf("%s %.1f%%", kind, rate))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+func isFaultKind(kind string) bool {
+	for _, k := range FaultKinds {
+		if k == kind {
+			return true
+		}
+	}
+	return false
+}
+
+// FaultStat counts the faults of one kind
+type FaultStat struct {
+	Kind     string
+	Injected int64 // Written to Kafka
+	Rejected int64 // Refused by the Kafka client or broker
+}
+
+// faultInjector turns a share of produced messages into poison messages
+type faultInjector struct {
+	config   *FaultConfig
+	rng      *rand.Rand
+	kinds    []string // Configured kinds, in FaultKinds order
+	schemaID int
+
+	nullCodec *goavro.Codec // Writer schema with nullField made nullable
+	nullField string
+	padField  SchemaField // String field padded by oversized faults
+	hasPad    bool
+	encode    func(map[string]interface{}) ([]byte, error)
+	wrap      func(SchemaField, interface{}) interface{}
+
+	mu    sync.Mutex
+	stats map[string]*FaultStat
+}
+
+// newFaultInjector prepares the codecs needed by the configured faults
+func newFaultInjector(config *FaultConfig, schema *Schema, rng *rand.Rand) (*faultInjector, error) {
+	f := &faultInjector{config: config, rng: rng, stats: make(map[string]*FaultStat)}
+	for _, kind := range FaultKinds {
+		if config.Rates[kind] > 0 {
+			f.kinds = append(f.kinds, kind)
+			f.stats[kind] = &FaultStat{Kind: kind}
+		}
+	}
+
+	if config.Rates[FaultNullField] > 0 {
+		if schema == nil {
+			return nil, fmt.Errorf("%s faults need the input schema", FaultNullField)
+		}
+		if schema.SerializationFormat() == SchemaFormatProtobuf {
+			return nil, fmt.Errorf("%s faults are not supported for Protobuf schemas, which have no null values", FaultNullField)
+		}
+		codec, field, err := nullableWriterCodec(schema)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", FaultNullField, err)
+		}
+		f.nullField = field
+		// JSON Schema records are written with the regular encoder; null is just a JSON value there
+		if schema.SerializationFormat() == SchemaFormatAvro {
+			f.nullCodec = codec
+		}
+	}
+	if schema != nil {
+		for _, field := range schema.Fields {
+			if base, logical, _ := resolveGeneratorTarget(field.Type); base == "string" && logical == "" {
+				f.padField, f.hasPad = field, true
+				break
+			}
+		}
+	}
+	return f, nil
+}
+
+// nullableWriterCodec builds a writer codec in which the first non-null field accepts null.
+// Its payloads are what a buggy producer sends: null where the registered schema expects a value,
+// so readers of the registered schema either fail or misread the record.
+func nullableWriterCodec(schema *Schema) (*goavro.Codec, string, error) {
+	var definition map[string]interface{}
+	if err := json.Unmarshal([]byte(schema.Content), &definition); err != nil {
+		return nil, "", fmt.Errorf("failed to parse schema: %w", err)
+	}
+	fields, _ := definition["fields"].([]interface{})
+	for _, raw := range fields {
+		field, ok := raw.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		if branches, isUnion := field["type"].([]interface{}); isUnion && unionHasNull(branches) {
+			continue
+		}
+		field["type"] = []interface{}{"null", field["type"]}
+		delete(field, "default")
+
+		content, err := json.Marshal(definition)
+		if err != nil {
+			return nil, "", err
+		}
+		codec, err := goavro.NewCodec(string(content))
+		if err != nil {
+			return nil, "", fmt.Errorf("failed to build nullable writer schema: %w", err)
+		}
+		name, _ := field["name"].(string)
+		return codec, name, nil
+	}
+	return nil, "", fmt.Errorf("schema %s has no non-null field", schema.Name)
+}
+
+func unionHasNull(branches []interface{}) bool {
+	for _, b := range branches {
+		if s, ok := b.(string); ok && s == "null" {
+			return true
+		}
+	}
+	return false
+}
+
+// pick chooses the fault for the next message; "" means the message is sent unchanged
+func (f *faultInjector) pick() string {
+	if f == nil {
+		return ""
+	}
+	roll := f.rng.Float64() * 100
+	for _, kind := range f.kinds {
+		if roll < f.config.Rates[kind] {
+			return kind
+		}
+		roll -= f.config.Rates[kind]
+	}
+	return ""
+}
+
+// apply builds the value of a faulty message from the message and its valid encoding.
+// The boolean reports whether the resulting value is still a valid record.
+func (f *faultInjector) apply(kind string, message map[string]interface{}, value []byte) ([]byte, bool, error) {
+	switch kind {
+	case FaultBadMagic:
+		corrupted := append([]byte(nil), value...)
+		corrupted[0] = 0x01
+		return corrupted, false, nil
+	case FaultUnknownSchema:
+		return confluentWireFormat(math.MaxInt32, value[5:]), false, nil
+	case FaultTruncated:
+		return value[:5+len(value[5:])/2], false, nil
+	case FaultNullField:
+		withNull := make(map[string]interface{}, len(message))
+		for k, v := range message {
+			withNull[k] = v
+		}
+		withNull[f.nullField] = nil
+		if f.nullCodec == nil {
+			encoded, err := f.encode(withNull)
+			if err != nil {
+				return nil, false, fmt.Errorf("failed to encode %s fault: %w", kind, err)
+			}
+			return encoded, false, nil
+		}
+		payload, err := f.nullCodec.BinaryFromNative(nil, withNull)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to encode %s fault: %w", kind, err)
+		}
+		return confluentWireFormat(f.schemaID, payload), false, nil
+	case FaultOversized:
+		return f.oversized(message, value)
+	}
+	return value, true, nil
+}
+
+// oversized pads the first plain string field up to the configured size, or appends padding after the record
+func (f *faultInjector) oversized(message map[string]interface{}, value []byte) ([]byte, bool, error) {
+	missing := f.config.OversizedBytes - len(value)
+	if missing <= 0 {
+		return value, true, nil
+	}
+	if !f.hasPad || f.encode == nil {
+		return append(append([]byte(nil), value...), make([]byte, missing)...), true, nil
+	}
+
+	padded := make(map[string]interface{}, len(message))
+	for k, v := range message {
+		padded[k] = v
+	}
+	current, _ := unwrapUnion(message[f.padField.Name]).(string)
+	padded[f.padField.Name] = f.wrap(f.padField, current+strings.Repeat("x", missing))
+	encoded, err := f.encode(padded)
+	if err != nil {
+		return nil, false, fmt.Errorf("failed to encode %s fault: %w", FaultOversized, err)
+	}
+	return encoded, true, nil
+}
+
+// record counts a fault as written or rejected
+func (f *faultInjector) record(kind string, written bool) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if written {
+		f.stats[kind].Injected++
+	} else {
+		f.stats[kind].Rejected++
+	}
+}
+
+// Stats returns the per-kind fault counts in report order
+func (f *faultInjector) Stats() []FaultStat {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	stats := make([]FaultStat, 0, len(f.stats))
+	for _, s := range f.stats {
+		stats = append(stats, *s)
+	}
+	order := make(map[string]int, len(FaultKinds))
+	for i, kind := range FaultKinds {
+		order[kind] = i
+	}
+	sort.Slice(stats, func(i, j int) bool { return order[stats[i].Kind] < order[stats[j].Kind] })
+	return stats
+}
+
+// FaultReport summarizes the injected faults and what the pipeline did with them
+type FaultReport struct {
+	Summary       string
+	Stats         []FaultStat
+	PoisonSent    int64          // Faults that cannot be decoded with the registered schema
+	ValidSent     int64          // Messages that decode, including duplicates and oversized records
+	OutputRecords int64          // Records consumed from the output topic
+	JobStates     map[string]int // Flink job count per state at the end of the run
+	Outcome       string
+}
+
+// newFaultReport combines the fault counts with the observed pipeline behavior
+func newFaultReport(config *FaultConfig, stats []FaultStat, validSent, outputRecords int64, jobStates map[string]int) *FaultReport {
+	report := &FaultReport{
+		Summary:       config.Summary(),
+		Stats:         stats,
+		ValidSent:     validSent,
+		OutputRecords: outputRecords,
+		JobStates:     jobStates,
+	}
+	for _, s := range stats {
+		switch s.Kind {
+		case FaultBadMagic, FaultUnknownSchema, FaultTruncated, FaultNullField:
+			report.PoisonSent += s.Injected
+		}
+	}
+
+	switch {
+	case jobStates["FAILED"] > 0 || jobStates["FAILING"] > 0 || jobStates["RESTARTING"] > 0:
+		report.Outcome = "A Flink job failed or is restarting: the source tables do not tolerate bad records. Configure the format's error handling (e.g. 'avro-confluent.ignore-parse-errors') or route bad records to a DLQ."
+	case jobStates["RUNNING"] == 0:
+		report.Outcome = "No Flink job was running at the end of the run; check the job logs for deserialization errors."
+	case report.PoisonSent > 0:
+		report.Outcome = fmt.Sprintf("Flink jobs kept running after %d poison message(s); %d output record(s) were produced from %d valid message(s).", report.PoisonSent, outputRecords, validSent)
+	default:
+		report.Outcome = fmt.Sprintf("Flink jobs kept running; %d output record(s) were produced from %d valid message(s).", outputRecords, validSent)
+	}
+	return report
+}
+
+// JobStateSummary renders the job states as "RUNNING: 2, FAILED: 1"
+func (r *FaultReport) JobStateSummary() string {
+	states := make([]string, 0, len(r.JobStates))
+	for state := range r.JobStates {
+		states = append(states, state)
+	}
+	sort.Strings(states)
+	parts := make([]string, len(states))
+	for i, state := range states {
+		parts[i] = fmt.Sprintf("%s: %d", state, r.JobStates[state])
+	}
+	if len(parts) == 0 {
+		return "unknown"
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,220 @@
+package pipeline
+
+import (
+	"encoding/binary"
+	"math"
+	"math/rand"
+	"reflect"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+const faultTestSchema = `{
+  "type": "record",
+  "name": "Order",
+  "fields": [
+    {"name": "order_id", "type": "string"},
+    {"name": "note", "type": ["null", "string"], "default": null},
+    {"name": "amount", "type": "double"}
+  ]
+}`
+
+func TestParseFaultSpec(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		want    map[string]float64
+		wantErr bool
+	}{
+		{name: "empty", spec: "", want: map[string]float64{}},
+		{name: "per kind", spec: "bad-magic:1%, duplicate:2.5", want: map[string]float64{FaultBadMagic: 1, FaultDuplicate: 2.5}},
+		{name: "total", spec: "6%", want: map[string]float64{
+			FaultBadMagic: 1, FaultUnknownSchema: 1, FaultTruncated: 1, FaultNullField: 1, FaultOversized: 1, FaultDuplicate: 1,
+		}},
+		{name: "missing percent", spec: "bad-magic", wantErr: true},
+		{name: "bad percent", spec: "bad-magic:lots", wantErr: true},
+		{name: "mixed", spec: "bad-magic:1%,2%", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseFaultSpec(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseFaultSpec() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseFaultSpec() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestFaultConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  FaultConfig
+		wantErr bool
+	}{
+		{name: "valid", config: FaultConfig{Rates: map[string]float64{FaultTruncated: 5}}},
+		{name: "unknown kind", config: FaultConfig{Rates: map[string]float64{"garbage": 5}}, wantErr: true},
+		{name: "negative", config: FaultConfig{Rates: map[string]float64{FaultTruncated: -1}}, wantErr: true},
+		{name: "over 100 percent", config: FaultConfig{Rates: map[string]float64{FaultTruncated: 60, FaultDuplicate: 50}}, wantErr: true},
+		{name: "negative size", config: FaultConfig{Rates: map[string]float64{FaultOversized: 1}, OversizedBytes: -1}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	cfg := FaultConfig{Rates: map[string]float64{FaultOversized: 1}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.OversizedBytes != defaultOversizedBytes {
+		t.Errorf("expected default oversized size, got %d", cfg.OversizedBytes)
+	}
+}
+
+func TestFaultInjectorPick(t *testing.T) {
+	config := &FaultConfig{Rates: map[string]float64{FaultBadMagic: 10, FaultDuplicate: 5}}
+	f, err := newFaultInjector(config, nil, rand.New(rand.NewSource(5)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	const total = 10000
+	counts := make(map[string]int)
+	for i := 0; i < total; i++ {
+		counts[f.pick()]++
+	}
+	if n := counts[FaultBadMagic]; n < 900 || n > 1100 {
+		t.Errorf("expected ~10%% bad-magic faults, got %d/%d", n, total)
+	}
+	if n := counts[FaultDuplicate]; n < 420 || n > 580 {
+		t.Errorf("expected ~5%% duplicate faults, got %d/%d", n, total)
+	}
+	if len(counts) != 3 {
+		t.Errorf("expected only configured faults, got %v", counts)
+	}
+
+	var disabled *faultInjector
+	if kind := disabled.pick(); kind != "" {
+		t.Errorf("nil injector picked %q", kind)
+	}
+}
+
+func TestFaultInjectorApply(t *testing.T) {
+	schema := loadTestSchema(t, faultTestSchema)
+	codec, err := goavro.NewCodec(schema.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	const schemaID = 7
+	encode := func(message map[string]interface{}) ([]byte, error) {
+		payload, err := codec.BinaryFromNative(nil, message)
+		if err != nil {
+			return nil, err
+		}
+		return confluentWireFormat(schemaID, payload), nil
+	}
+
+	config := &FaultConfig{Rates: map[string]float64{}, OversizedBytes: 4096}
+	for _, kind := range FaultKinds {
+		config.Rates[kind] = 1
+	}
+	f, err := newFaultInjector(config, schema, rand.New(rand.NewSource(1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.schemaID = schemaID
+	f.encode = encode
+	f.wrap = func(_ SchemaField, v interface{}) interface{} { return v }
+	if f.nullField != "order_id" || f.padField.Name != "order_id" {
+		t.Fatalf("resolved fields %s/%s, want order_id/order_id", f.nullField, f.padField.Name)
+	}
+
+	message := map[string]interface{}{"order_id": "order-1", "note": nil, "amount": 12.5}
+	value, err := encode(message)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decodes := func(v []byte) bool {
+		if len(v) < 5 || v[0] != 0 || binary.BigEndian.Uint32(v[1:5]) != schemaID {
+			return false
+		}
+		_, rest, err := codec.NativeFromBinary(v[5:])
+		return err == nil && len(rest) == 0
+	}
+
+	for _, kind := range FaultKinds {
+		t.Run(kind, func(t *testing.T) {
+			got, valid, err := f.apply(kind, message, value)
+			if err != nil {
+				t.Fatalf("apply() error = %v", err)
+			}
+			if kind != FaultNullField && valid != decodes(got) {
+				t.Errorf("apply() valid = %v, but decodes = %v", valid, decodes(got))
+			}
+
+			switch kind {
+			case FaultBadMagic:
+				if got[0] == 0 {
+					t.Error("expected a non-zero magic byte")
+				}
+			case FaultUnknownSchema:
+				if id := binary.BigEndian.Uint32(got[1:5]); id != math.MaxInt32 {
+					t.Errorf("schema ID = %d, want an unregistered ID", id)
+				}
+			case FaultTruncated:
+				if len(got) >= len(value) {
+					t.Errorf("expected a truncated payload, got %d of %d bytes", len(got), len(value))
+				}
+			case FaultNullField:
+				native, _, err := f.nullCodec.NativeFromBinary(got[5:])
+				if err != nil {
+					t.Fatalf("null-field payload does not decode with the writer schema: %v", err)
+				}
+				if valid || native.(map[string]interface{})["order_id"] != nil {
+					t.Errorf("expected a poison record with a null order_id, got %v (valid %v)", native, valid)
+				}
+			case FaultOversized:
+				if len(got) < config.OversizedBytes || !valid {
+					t.Errorf("expected a valid record of at least %d bytes, got %d bytes (valid %v)", config.OversizedBytes, len(got), valid)
+				}
+			case FaultDuplicate:
+				if !valid || !reflect.DeepEqual(got, value) {
+					t.Error("expected the duplicate to be the unchanged message")
+				}
+			}
+		})
+	}
+
+	if value[0] != 0 {
+		t.Error("apply() modified the original value")
+	}
+}
+
+func TestNewFaultReport(t *testing.T) {
+	config := &FaultConfig{Rates: map[string]float64{FaultTruncated: 2, FaultDuplicate: 1}}
+	stats := []FaultStat{{Kind: FaultTruncated, Injected: 20}, {Kind: FaultDuplicate, Injected: 10, Rejected: 1}}
+
+	failed := newFaultReport(config, stats, 990, 0, map[string]int{"FAILED": 1})
+	if failed.PoisonSent != 20 {
+		t.Errorf("PoisonSent = %d, want 20", failed.PoisonSent)
+	}
+	running := newFaultReport(config, stats, 990, 985, map[string]int{"RUNNING": 2})
+	if failed.Outcome == running.Outcome {
+		t.Error("expected different outcomes for failed and running jobs")
+	}
+	if got := running.JobStateSummary(); got != "RUNNING: 2" {
+		t.Errorf("JobStateSummary() = %q", got)
+	}
+	if got := newFaultReport(config, stats, 0, 0, nil).JobStateSummary(); got != "unknown" {
+		t.Errorf("JobStateSummary() = %q, want unknown", got)
+	}
+}
@@ -2,15 +2,24 @@ package pipeline
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"pipegen/internal/flinkgateway"
 	"pipegen/internal/types"
+	"sort"
 	"strings"
+	"sync"
 	"time"
 )
 
+// statementTimeout bounds the wait for a statement to finish: DDL finishes right away, and an
+// INSERT once its job is submitted
+const statementTimeout = 30 * time.Second
+
+// sessionHeartbeatInterval keeps the session well within the gateway's idle timeout, ten minutes
+// by default, while the pipeline runs
+const sessionHeartbeatInterval = time.Minute
+
 // DeploymentStatus represents the status of a FlinkSQL deployment
 type DeploymentStatus struct {
 	ID     string `json:"id"`
@@ -28,7 +37,13 @@ type FlinkAPIClient struct {
 // FlinkDeployer handles FlinkSQL statement deployment via Confluent Cloud API
 type FlinkDeployer struct {
 	config         *Config
+	gateway        *flinkgateway.Client
+	jobs           *flinkgateway.Client // REST API of the JobManager
 	sessionID      string
+	stopKeepAlive  context.CancelFunc // Stops the heartbeats of the session
+	mu             sync.Mutex
+	operations     []string            // Handles of the operations that ran the deployed statements
+	jobIDs         map[string][]string // Flink jobs submitted by each deployed statement file
 	stopDeployment func(ctx context.Context, deploymentID string) error
 	globalMode     bool // New field to enable global table creation
 }
@@ -36,7 +51,9 @@ type FlinkDeployer struct {
 // NewFlinkDeployer creates a new FlinkSQL deployer
 func NewFlinkDeployer(config *Config) *FlinkDeployer {
 	fd := &FlinkDeployer{
-		config: config,
+		config:  config,
+		gateway: flinkgateway.NewClient(sqlGatewayURL(config)),
+		jobs:    flinkgateway.NewClient(config.FlinkURL),
 	}
 	fd.stopDeployment = fd.defaultStopDeployment
 	return fd
@@ -46,6 +63,8 @@ func NewFlinkDeployer(config *Config) *FlinkDeployer {
 func NewFlinkDeployerGlobal(config *Config) *FlinkDeployer {
 	fd := &FlinkDeployer{
 		config:     config,
+		gateway:    flinkgateway.NewClient(sqlGatewayURL(config)),
+		jobs:       flinkgateway.NewClient(config.FlinkURL),
 		globalMode: true,
 	}
 	fd.stopDeployment = fd.defaultStopDeployment
@@ -68,37 +87,27 @@ func (fd *FlinkDeployer) Deploy(ctx context.Context, statements []*types.SQLStat
 func (fd *FlinkDeployer) deployGlobal(ctx context.Context, statements []*types.SQLStatement, resources *Resources) ([]string, error) {
 	fmt.Printf("🌍 Using global session approach for table deployment...\n")
 
-	// Use a well-known session name that can be reused across pipeline runs
-	globalSessionName := "pipegen-global-session"
-
-	// Try to get or create the global session
-	sessionID, err := fd.getOrCreateGlobalSession(ctx, globalSessionName)
+	sessionID, err := fd.openGlobalSession(ctx, "pipegen-global-session")
 	if err != nil {
-		return nil, fmt.Errorf("failed to get or create global session: %w", err)
+		return nil, fmt.Errorf("failed to open global session: %w", err)
 	}
 
-	fd.sessionID = sessionID
+	fd.useSession(sessionID)
 	fmt.Printf("📍 Using global session ID: %s\n", sessionID)
 
-	var deploymentIDs []string
-
-	for i, stmt := range statements {
-		fmt.Printf("📝 Deploying statement %d globally: %s\n", i+1, stmt.Name)
+	deploymentIDs, err := fd.deployInOrder(statements, func(n int, stmt *types.SQLStatement) (string, error) {
+		fmt.Printf("📝 Deploying statement %d globally: %s\n", n, stmt.Name)
 
-		// Substitute variables in SQL statement
-		processedSQL := fd.substituteVariables(stmt.Content, resources)
-
-		// Deploy the statement using the global session
-		deploymentID, err := fd.deployStatement(ctx, stmt.Name, processedSQL)
+		// Deploy the statements of the file using the global session
+		deploymentID, err := fd.deployFile(ctx, stmt, resources)
 		if err != nil {
-			return deploymentIDs, fmt.Errorf("failed to deploy statement %s: %w", stmt.Name, err)
+			return "", fmt.Errorf("failed to deploy statement %s: %w", stmt.Name, err)
 		}
-
-		deploymentIDs = append(deploymentIDs, deploymentID)
 		fmt.Printf("  ✅ Deployed globally with ID: %s\n", deploymentID)
-
-		// Small delay between statements to avoid overwhelming the API
-		time.Sleep(500 * time.Millisecond)
+		return deploymentID, nil
+	})
+	if err != nil {
+		return deploymentIDs, err
 	}
 
 	fmt.Printf("🎉 All %d statements deployed successfully in global session!\n", len(statements))
@@ -107,146 +116,72 @@ func (fd *FlinkDeployer) deployGlobal(ctx context.Context, statements []*types.S
 
 // deploySessionBased uses the original session-per-pipeline approach
 func (fd *FlinkDeployer) deploySessionBased(ctx context.Context, statements []*types.SQLStatement, resources *Resources) ([]string, error) {
-	var deploymentIDs []string
-
-	// Create a session once for all statements
-	// Use SQL Gateway URL (typically FlinkURL with port 8083)
-	sqlGatewayURL := strings.Replace(fd.config.FlinkURL, "8081", "8083", 1)
-	sessionEndpoint := fmt.Sprintf("%s/v1/sessions", sqlGatewayURL)
-	sessionReqBody := `{"sessionName": "pipegen-session", "properties": {}}`
-	sessionReq, err := http.NewRequestWithContext(ctx, "POST", sessionEndpoint, strings.NewReader(sessionReqBody))
+	// Open a session once for all statements
+	sessionID, err := fd.gateway.OpenSession(ctx, "pipegen-session", nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create session request: %w", err)
-	}
-	sessionReq.Header.Set("Content-Type", "application/json")
-	sessionResp, err := http.DefaultClient.Do(sessionReq)
-	if err != nil {
-		fmt.Printf("⚠️  Session creation request failed: %v\n", err)
-		return nil, fmt.Errorf("failed to create session: %w", err)
-	}
-	defer func() {
-		if err := sessionResp.Body.Close(); err != nil {
-			fmt.Printf("failed to close sessionResp.Body: %v\n", err)
-		}
-	}()
-	sessionBody, err := io.ReadAll(sessionResp.Body)
-	if err != nil {
-		fmt.Printf("⚠️  Failed to read session response: %v\n", err)
-		return nil, fmt.Errorf("failed to read session response: %w", err)
-	}
-	if sessionResp.StatusCode != 200 {
-		fmt.Printf("⚠️  Session creation failed with status %d: %s\n", sessionResp.StatusCode, string(sessionBody))
-		return nil, fmt.Errorf("flink SQL Gateway session creation failed: %s", string(sessionBody))
-	}
-	fd.sessionID = extractSessionID(string(sessionBody))
-	if fd.sessionID == "" {
-		fmt.Printf("⚠️  Could not extract session ID from response: %s\n", string(sessionBody))
-		return nil, fmt.Errorf("could not extract session ID from response: %s", string(sessionBody))
+		fmt.Printf("⚠️  %v\n", err)
+		return nil, err
 	}
+	fd.useSession(sessionID)
 
-	for i, stmt := range statements {
-		fmt.Printf("📝 Deploying statement %d: %s\n", i+1, stmt.Name)
+	deploymentIDs, err := fd.deployInOrder(statements, func(n int, stmt *types.SQLStatement) (string, error) {
+		fmt.Printf("📝 Deploying statement %d: %s\n", n, stmt.Name)
 
-		// Substitute variables in SQL statement
-		processedSQL := fd.substituteVariables(stmt.Content, resources)
-
-		// Deploy the statement using the same session
-		deploymentID, err := fd.deployStatement(ctx, stmt.Name, processedSQL)
+		// Deploy the statements of the file using the same session
+		deploymentID, err := fd.deployFile(ctx, stmt, resources)
 		if err != nil {
-			return deploymentIDs, fmt.Errorf("failed to deploy statement %s: %w", stmt.Name, err)
+			return "", fmt.Errorf("failed to deploy statement %s: %w", stmt.Name, err)
 		}
-
-		deploymentIDs = append(deploymentIDs, deploymentID)
 		fmt.Printf("  ✅ Deployed with ID: %s\n", deploymentID)
-
-		// Small delay between statements to avoid overwhelming the API
-		time.Sleep(500 * time.Millisecond)
+		return deploymentID, nil
+	})
+	if err != nil {
+		return deploymentIDs, err
 	}
 
 	fmt.Printf("🎉 All %d statements deployed successfully!\n", len(statements))
 	return deploymentIDs, nil
 }
 
-// getOrCreateGlobalSession gets an existing global session or creates a new one
-func (fd *FlinkDeployer) getOrCreateGlobalSession(ctx context.Context, sessionName string) (string, error) {
-	sqlGatewayURL := strings.Replace(fd.config.FlinkURL, "8081", "8083", 1)
-
-	// First, try to list existing sessions to see if our global session exists
-	listSessionsEndpoint := fmt.Sprintf("%s/v1/sessions", sqlGatewayURL)
-	listReq, err := http.NewRequestWithContext(ctx, "GET", listSessionsEndpoint, nil)
-	if err != nil {
-		return "", fmt.Errorf("failed to create list sessions request: %w", err)
-	}
-
-	listResp, err := http.DefaultClient.Do(listReq)
-	if err != nil {
-		return "", fmt.Errorf("failed to list sessions: %w", err)
-	}
-	defer func() {
-		if err := listResp.Body.Close(); err != nil {
-			fmt.Printf("failed to close list response body: %v\n", err)
-		}
-	}()
-
-	if listResp.StatusCode == 200 {
-		listBody, err := io.ReadAll(listResp.Body)
-		if err == nil {
-			fmt.Printf("[Flink SQL Gateway] Current sessions: %s\n", string(listBody))
-
-			// Try to find existing global session
-			if existingSessionID := extractExistingSessionID(string(listBody), sessionName); existingSessionID != "" {
-				fmt.Printf("🔄 Reusing existing global session: %s\n", existingSessionID)
-				return existingSessionID, nil
-			}
-		}
-	}
-
-	// Create new global session
+// openGlobalSession opens the session of global mode. The gateway cannot look sessions up by name,
+// so every run opens a session of its own under the well-known name.
+func (fd *FlinkDeployer) openGlobalSession(ctx context.Context, sessionName string) (string, error) {
 	fmt.Printf("🆕 Creating new global session: %s\n", sessionName)
-	sessionEndpoint := fmt.Sprintf("%s/v1/sessions", sqlGatewayURL)
-	sessionReqBody := fmt.Sprintf(`{"sessionName": "%s", "properties": {}}`, sessionName)
-	sessionReq, err := http.NewRequestWithContext(ctx, "POST", sessionEndpoint, strings.NewReader(sessionReqBody))
-	if err != nil {
-		return "", fmt.Errorf("failed to create session request: %w", err)
-	}
-	sessionReq.Header.Set("Content-Type", "application/json")
-	sessionResp, err := http.DefaultClient.Do(sessionReq)
+	sessionID, err := fd.gateway.OpenSession(ctx, sessionName, nil)
 	if err != nil {
-		fmt.Printf("[Flink SQL Gateway] Global session creation request failed: %v\n", err)
-		return "", fmt.Errorf("failed to create global session: %w", err)
+		fmt.Printf("[Flink SQL Gateway] %v\n", err)
+		return "", err
 	}
-	defer func() {
-		if err := sessionResp.Body.Close(); err != nil {
-			fmt.Printf("failed to close session response body: %v\n", err)
-		}
-	}()
+	return sessionID, nil
+}
 
-	sessionBody, err := io.ReadAll(sessionResp.Body)
-	if err != nil {
-		fmt.Printf("[Flink SQL Gateway] Failed to read global session response: %v\n", err)
-		return "", fmt.Errorf("failed to read global session response: %w", err)
+// useSession makes the deployer deploy into the session and keeps the session from expiring until
+// the deployer is closed
+func (fd *FlinkDeployer) useSession(sessionID string) {
+	fd.sessionID = sessionID
+	keepAliveCtx, cancel := context.WithCancel(context.Background())
+	fd.stopKeepAlive = cancel
+	go fd.gateway.KeepAlive(keepAliveCtx, sessionID, sessionHeartbeatInterval)
+}
+
+// Close stops the heartbeats of the session and closes it. The jobs the statements submitted keep
+// running; Cleanup stops them. Closing a deployer without a session does nothing.
+func (fd *FlinkDeployer) Close(ctx context.Context) error {
+	if fd.stopKeepAlive != nil {
+		fd.stopKeepAlive()
+		fd.stopKeepAlive = nil
 	}
-	fmt.Printf("[Flink SQL Gateway] Global session creation response: %s\n", string(sessionBody))
-	if sessionResp.StatusCode != 200 {
-		fmt.Printf("[Flink SQL Gateway] Global session creation failed with status %d: %s\n", sessionResp.StatusCode, string(sessionBody))
-		return "", fmt.Errorf("flink SQL Gateway global session creation failed: %s", string(sessionBody))
+	if fd.sessionID == "" {
+		return nil
 	}
 
-	sessionID := extractSessionID(string(sessionBody))
-	if sessionID == "" {
-		fmt.Printf("[Flink SQL Gateway] Could not extract session ID from global session response: %s\n", string(sessionBody))
-		return "", fmt.Errorf("could not extract session ID from global session response: %s", string(sessionBody))
+	sessionID := fd.sessionID
+	fd.sessionID = ""
+	if err := fd.gateway.CloseSession(ctx, sessionID); err != nil {
+		return fmt.Errorf("failed to close session %s: %w", sessionID, err)
 	}
-
-	return sessionID, nil
-}
-
-// extractExistingSessionID tries to find an existing session with the given name
-func extractExistingSessionID(responseBody, sessionName string) string {
-	// This would need to parse the JSON response and look for sessions with the matching name
-	// For now, return empty string to always create new session
-	// TODO: Implement proper JSON parsing to find existing sessions
-	return ""
+	fmt.Printf("🔒 Closed session: %s\n", sessionID)
+	return nil
 }
 
 // StatusCallback is a function type for statement status updates
@@ -256,253 +191,216 @@ type StatusCallback func(statementName, status, phase, deploymentID, errorMsg st
 func (fd *FlinkDeployer) DeployWithStatusTracking(ctx context.Context, statements []*types.SQLStatement, resources *Resources, statusCallback StatusCallback) ([]string, error) {
 	fmt.Printf("⚡ Deploying %d FlinkSQL statements with status tracking...\n", len(statements))
 
-	var deploymentIDs []string
-
-	for i, stmt := range statements {
-		fmt.Printf("📝 Deploying statement %d: %s\n", i+1, stmt.Name)
+	deploymentIDs, err := fd.deployInOrder(statements, func(n int, stmt *types.SQLStatement) (string, error) {
+		fmt.Printf("📝 Deploying statement %d: %s\n", n, stmt.Name)
 
 		// Update status to deploying
 		if statusCallback != nil {
 			statusCallback(stmt.Name, "RUNNING", "DEPLOYING", "", "")
 		}
 
-		// Substitute variables in SQL statement
-		processedSQL := fd.substituteVariables(stmt.Content, resources)
-
-		// Deploy the statement
-		deploymentID, err := fd.deployStatement(ctx, stmt.Name, processedSQL)
+		// Deploy the statements of the file
+		deploymentID, err := fd.deployFile(ctx, stmt, resources)
 		if err != nil {
 			// Update status to failed
 			if statusCallback != nil {
 				statusCallback(stmt.Name, "FAILED", "ERROR", "", err.Error())
 			}
-			return deploymentIDs, fmt.Errorf("failed to deploy statement %s: %w", stmt.Name, err)
+			return "", fmt.Errorf("failed to deploy statement %s: %w", stmt.Name, err)
 		}
-
-		deploymentIDs = append(deploymentIDs, deploymentID)
 		fmt.Printf("  ✅ Deployed with ID: %s\n", deploymentID)
 
 		// Update status to running
 		if statusCallback != nil {
 			statusCallback(stmt.Name, "RUNNING", "ACTIVE", deploymentID, "")
 		}
+		return deploymentID, nil
+	})
+	if err != nil {
+		return deploymentIDs, err
 	}
 
 	fmt.Printf("✅ All %d statements deployed successfully\n", len(statements))
 	return deploymentIDs, nil
 }
 
-// deployStatement deploys a single FlinkSQL statement
-func (fd *FlinkDeployer) deployStatement(ctx context.Context, name, sql string) (string, error) {
-	mode := "session-based"
-	if fd.globalMode {
-		mode = "global"
-	}
-	fmt.Printf("    🚀 Deploying SQL statement '%s' to Flink SQL Gateway API (%s workflow)...\n", name, mode)
-
-	// 2. Submit statement to session (reuse sessionID)
-	if fd.sessionID == "" {
-		return "", fmt.Errorf("no sessionID available for statement deployment")
-	}
-	sqlGatewayURL := strings.Replace(fd.config.FlinkURL, "8081", "8083", 1)
-	statementEndpoint := fmt.Sprintf("%s/v1/sessions/%s/statements", sqlGatewayURL, fd.sessionID)
-	statementReqBody := fmt.Sprintf(`{"statement": "%s"}`, escapeJSONString(sql))
-	statementReq, err := http.NewRequestWithContext(ctx, "POST", statementEndpoint, strings.NewReader(statementReqBody))
-	if err != nil {
-		return "", fmt.Errorf("failed to create statement request: %w", err)
-	}
-	statementReq.Header.Set("Content-Type", "application/json")
-	statementResp, err := http.DefaultClient.Do(statementReq)
-	if err != nil {
-		return "", fmt.Errorf("failed to send SQL statement to Flink SQL Gateway: %w", err)
-	}
-	defer func() {
-		if err := statementResp.Body.Close(); err != nil {
-			fmt.Printf("failed to close statementResp.Body: %v\n", err)
+// deployInOrder deploys statements one after the other in their order. With ParallelDeploy it
+// deploys them wave by wave instead, the statements of a wave at the same time; a wave only starts
+// once the statements it depends on are deployed. Statements that change the session are barriers:
+// the statements before them in deployment order deploy first, then the statement on its own, so
+// its settings apply to exactly the statements after it, as in a sequential deployment. n numbers
+// a statement in the given order. The IDs of the deployed statements are returned even when one
+// fails, so they can be cleaned up.
+func (fd *FlinkDeployer) deployInOrder(statements []*types.SQLStatement, deploy func(n int, stmt *types.SQLStatement) (string, error)) ([]string, error) {
+	var waves [][]*types.SQLStatement
+	if fd.config != nil && fd.config.ParallelDeploy {
+		graph, err := BuildStatementGraph(statements)
+		if err != nil {
+			return nil, err
+		}
+		var segment []*types.SQLStatement
+		flush := func() error {
+			if len(segment) == 0 {
+				return nil
+			}
+			// The statements of a segment only depend on each other and on earlier segments
+			segmentGraph, err := BuildStatementGraph(segment)
+			if err != nil {
+				return err
+			}
+			waves = append(waves, segmentGraph.Waves()...)
+			segment = nil
+			return nil
+		}
+		for _, stmt := range graph.Statements {
+			if !changesSession(stmt) {
+				segment = append(segment, stmt)
+				continue
+			}
+			if err := flush(); err != nil {
+				return nil, err
+			}
+			waves = append(waves, []*types.SQLStatement{stmt})
+		}
+		if err := flush(); err != nil {
+			return nil, err
+		}
+		fmt.Printf("🔀 Deploying %d statements in %d waves of independent statements\n", len(statements), len(waves))
+	} else {
+		for _, stmt := range statements {
+			waves = append(waves, []*types.SQLStatement{stmt})
 		}
-	}()
-	statementBody, err := io.ReadAll(statementResp.Body)
-	if err != nil {
-		return "", fmt.Errorf("failed to read statement response: %w", err)
-	}
-	if statementResp.StatusCode != 200 {
-		fmt.Printf("⚠️  Statement submission failed with status %d: %s\n", statementResp.StatusCode, string(statementBody))
-		return "", fmt.Errorf("flink SQL Gateway returned status %d: %s", statementResp.StatusCode, string(statementBody))
 	}
 
-	operationHandle := extractOperationHandle(string(statementBody))
-	if operationHandle == "" {
-		return "", fmt.Errorf("could not extract operationHandle from response: %s", string(statementBody))
+	numbers := make(map[*types.SQLStatement]int, len(statements))
+	for i, stmt := range statements {
+		numbers[stmt] = i + 1
 	}
 
-	// 3. Poll operation status until finished
-	opStatusEndpoint := fmt.Sprintf("%s/v1/sessions/%s/operations/%s/status", sqlGatewayURL, fd.sessionID, operationHandle)
-	var opStatus string
-	var opError string
-	for i := 0; i < 30; i++ { // up to 30 attempts, 1s apart
-		select {
-		case <-ctx.Done():
-			fmt.Printf("[Flink SQL Gateway] Context cancelled while waiting for operation status for '%s'\n", name)
-			return "", fmt.Errorf("context cancelled while waiting for operation status")
-		default:
-		}
-		req, err := http.NewRequestWithContext(ctx, "GET", opStatusEndpoint, nil)
-		if err != nil {
-			fmt.Printf("[Flink SQL Gateway] Failed to create operation status request for '%s': %v\n", name, err)
-			return "", fmt.Errorf("failed to create operation status request: %w", err)
-		}
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			fmt.Printf("[Flink SQL Gateway] Failed to poll operation status for '%s': %v\n", name, err)
-			return "", fmt.Errorf("failed to poll operation status: %w", err)
+	var deploymentIDs []string
+	for _, wave := range waves {
+		ids := make([]string, len(wave))
+		errs := make([]error, len(wave))
+		var wg sync.WaitGroup
+		for i, stmt := range wave {
+			wg.Add(1)
+			go func(i int, stmt *types.SQLStatement) {
+				defer wg.Done()
+				ids[i], errs[i] = deploy(numbers[stmt], stmt)
+			}(i, stmt)
 		}
-		body, err := io.ReadAll(resp.Body)
-		if err := resp.Body.Close(); err != nil {
-			fmt.Printf("failed to close resp.Body: %v\n", err)
+		wg.Wait()
+
+		for i := range wave {
+			if errs[i] == nil {
+				deploymentIDs = append(deploymentIDs, ids[i])
+			}
 		}
-		if err != nil {
-			fmt.Printf("⚠️  Failed to read operation status response for '%s': %v\n", name, err)
-			return "", fmt.Errorf("failed to read operation status response: %w", err)
+		if err := errors.Join(errs...); err != nil {
+			return deploymentIDs, err
 		}
-		opStatus = extractOperationStatus(string(body))
-		opError = extractOperationError(string(body))
-		if opStatus == "FINISHED" {
-			fmt.Printf("    ✅ SQL statement '%s' executed successfully.\n", name)
-			return name, nil
+	}
+	return deploymentIDs, nil
+}
+
+// deployFile deploys the statements of a statement file one after the other. Session statements
+// like SET and USE go to the gateway's configure-session endpoint and apply to the session for the
+// statements after them, in this file and the files deployed after it. Errors tell the file and
+// line of the statement that failed.
+func (fd *FlinkDeployer) deployFile(ctx context.Context, stmt *types.SQLStatement, resources *Resources) (string, error) {
+	var deploymentID string
+	for _, part := range SplitStatement(stmt) {
+		// Substitute variables in SQL statement
+		processedSQL, missing := fd.resolveVariables(part.SQL, resources)
+		if len(missing) > 0 {
+			return "", fmt.Errorf("%s: undefined variables: %s", part.Location, strings.Join(missing, ", "))
 		}
-		if opStatus == "ERROR" || opError != "" {
-			// Attempt to retrieve detailed error via helper (handles /result/0 fallback)
-			if details, derr := fd.fetchOperationResult(ctx, sqlGatewayURL, operationHandle); derr == nil && details != "" {
-				fmt.Printf("⚠️  Error details from result endpoint: %s\n", details)
-			} else if derr != nil {
-				fmt.Printf("⚠️  Result detail retrieval failed: %v\n", derr)
+		if part.Session {
+			fmt.Printf("  ⚙️  Applying to session: %s\n", fd.truncateSQL(processedSQL))
+			if err := fd.configureSession(ctx, stmt.Name, processedSQL); err != nil {
+				return "", fmt.Errorf("%s: %w", part.Location, err)
 			}
-			fmt.Printf("⚠️  ERROR deploying statement '%s': %s\n", name, opError)
-			return "", fmt.Errorf("SQL statement '%s' failed: %s", name, opError)
+			deploymentID = stmt.Name
+			continue
 		}
-		time.Sleep(1 * time.Second)
-	}
-	fmt.Printf("[Flink SQL Gateway] Statement '%s' did not finish after polling. Last status: %s, error: %s\n", name, opStatus, opError)
-	return "", fmt.Errorf("SQL statement '%s' did not finish after polling. Last status: %s, error: %s", name, opStatus, opError)
-}
 
-// extractOperationHandle parses the operationHandle from the statement response
-func extractOperationHandle(resp string) string {
-	idx := strings.Index(resp, "\"operationHandle\":\"")
-	if idx == -1 {
-		return ""
-	}
-	start := idx + len("\"operationHandle\":\"")
-	end := strings.Index(resp[start:], "\"")
-	if end == -1 {
-		return ""
+		id, err := fd.deployStatement(ctx, stmt.Name, processedSQL)
+		if err != nil {
+			return "", fmt.Errorf("%s: %w", part.Location, err)
+		}
+		deploymentID = id
 	}
-	return resp[start : start+end]
+	return deploymentID, nil
 }
 
-// extractOperationStatus parses the status from the operation status response
-func extractOperationStatus(resp string) string {
-	idx := strings.Index(resp, "\"status\":\"")
-	if idx == -1 {
-		return ""
+// configureSession applies a session statement such as SET or USE to the session
+func (fd *FlinkDeployer) configureSession(ctx context.Context, name, sql string) error {
+	if fd.sessionID == "" {
+		return fmt.Errorf("no sessionID available for statement deployment")
 	}
-	start := idx + len("\"status\":\"")
-	end := strings.Index(resp[start:], "\"")
-	if end == -1 {
-		return ""
+
+	execCtx, cancel := context.WithTimeout(ctx, statementTimeout)
+	defer cancel()
+	if err := fd.gateway.ConfigureSession(execCtx, fd.sessionID, sql); err != nil {
+		fmt.Printf("⚠️  ERROR applying session statement of '%s': %v\n", name, err)
+		return fmt.Errorf("SQL statement '%s' failed: %w", name, err)
 	}
-	return resp[start : start+end]
+	return nil
 }
 
-// extractOperationError parses the error from the operation status response
-func extractOperationError(resp string) string {
-	idx := strings.Index(resp, "\"error\":\"")
-	if idx == -1 {
-		return ""
-	}
-	start := idx + len("\"error\":\"")
-	end := strings.Index(resp[start:], "\"")
-	if end == -1 {
-		return ""
+// deployStatement deploys a single FlinkSQL statement
+func (fd *FlinkDeployer) deployStatement(ctx context.Context, name, sql string) (string, error) {
+	mode := "session-based"
+	if fd.globalMode {
+		mode = "global"
 	}
-	return resp[start : start+end]
-}
+	fmt.Printf("    🚀 Deploying SQL statement '%s' to Flink SQL Gateway API (%s workflow)...\n", name, mode)
 
-// fetchOperationResult attempts to retrieve the operation result (for error details)
-// It first tries the 0-based result endpoint introduced in newer Flink Gateway APIs:
-//
-//	/v1/sessions/{session}/operations/{op}/result/0
-//
-// Falls back to legacy endpoint without the trailing /0 if the first returns 404.
-// Performs a couple of quick retries in case the result materializes slightly after ERROR status.
-func (fd *FlinkDeployer) fetchOperationResult(ctx context.Context, baseURL, operationHandle string) (string, error) {
 	if fd.sessionID == "" {
-		return "", fmt.Errorf("sessionID not set for operation result fetch")
+		return "", fmt.Errorf("no sessionID available for statement deployment")
 	}
-	primary := fmt.Sprintf("%s/v1/sessions/%s/operations/%s/result/0", baseURL, fd.sessionID, operationHandle)
-	legacy := fmt.Sprintf("%s/v1/sessions/%s/operations/%s/result", baseURL, fd.sessionID, operationHandle)
 
-	endpoints := []string{primary, legacy}
-	var lastErr error
-	for attempt := 0; attempt < 4; attempt++ { // 4 attempts total (approx <=2s)
-		for idx, ep := range endpoints {
-			req, err := http.NewRequestWithContext(ctx, "GET", ep, nil)
-			if err != nil {
-				lastErr = err
-				continue
-			}
-			resp, err := http.DefaultClient.Do(req)
-			if err != nil {
-				lastErr = err
-				continue
-			}
-			body, rerr := io.ReadAll(resp.Body)
-			if cerr := resp.Body.Close(); cerr != nil {
-				fmt.Printf("failed to close result body: %v\n", cerr)
-			}
-			if rerr != nil {
-				lastErr = rerr
-				continue
-			}
-			if resp.StatusCode == 200 {
-				return string(body), nil
-			}
-			// If first endpoint (primary) returned 404, try legacy within same attempt; others break
-			if resp.StatusCode == 404 && idx == 0 {
-				continue
-			}
-			lastErr = fmt.Errorf("result endpoint %s returned %d", ep, resp.StatusCode)
-		}
-		// brief sleep before next attempt
-		time.Sleep(500 * time.Millisecond)
+	execCtx, cancel := context.WithTimeout(ctx, statementTimeout)
+	defer cancel()
+	operation, err := fd.gateway.Execute(execCtx, fd.sessionID, sql)
+	if operation != "" {
+		fd.mu.Lock()
+		fd.operations = append(fd.operations, operation)
+		fd.mu.Unlock()
 	}
-	if lastErr != nil {
-		return "", lastErr
+	if err != nil {
+		var opErr *flinkgateway.OperationError
+		if errors.As(err, &opErr) && opErr.Cause != nil {
+			fmt.Printf("⚠️  Stack trace from the SQL Gateway:\n%s\n", opErr.Cause.StackTrace())
+		}
+		if ctx.Err() == nil && errors.Is(err, context.DeadlineExceeded) {
+			err = fmt.Errorf("did not finish within %v", statementTimeout)
+		}
+		fmt.Printf("⚠️  ERROR deploying statement '%s': %v\n", name, err)
+		return "", fmt.Errorf("SQL statement '%s' failed: %w", name, err)
 	}
-	return "", fmt.Errorf("no result body available")
-}
+	fmt.Printf("    ✅ SQL statement '%s' executed successfully.\n", name)
 
-// extractSessionID parses the session id from the session creation response
-func extractSessionID(resp string) string {
-	// Updated extraction: look for "sessionHandle":"..."
-	idx := strings.Index(resp, "\"sessionHandle\":\"")
-	if idx == -1 {
-		return ""
-	}
-	start := idx + len("\"sessionHandle\":\"")
-	end := strings.Index(resp[start:], "\"")
-	if end == -1 {
-		return ""
+	// The first page of the result of an INSERT names the job it submitted. Later pages are not
+	// needed, and those of an unbounded result never end.
+	page, err := fd.gateway.FetchResults(execCtx, fd.sessionID, operation, 0)
+	if err != nil {
+		fmt.Printf("    ⚠️  Could not read the result of '%s' for its job ID: %v\n", name, err)
+	} else if page.JobID != "" {
+		fmt.Printf("    🆔 Submitted Flink job: %s\n", page.JobID)
+		fd.mu.Lock()
+		if fd.jobIDs == nil {
+			fd.jobIDs = make(map[string][]string)
+		}
+		fd.jobIDs[name] = append(fd.jobIDs[name], page.JobID)
+		fd.mu.Unlock()
 	}
-	return resp[start : start+end]
+	return name, nil
 }
 
-// escapeJSONString escapes quotes and newlines for JSON string
-func escapeJSONString(s string) string {
-	s = strings.ReplaceAll(s, "\\", "\\\\")
-	s = strings.ReplaceAll(s, "\"", "\\\"")
-	s = strings.ReplaceAll(s, "\n", "\\n")
-	return s
+// sqlGatewayURL is the URL of the SQL Gateway, which listens next to the Flink REST API on port 8083
+func sqlGatewayURL(config *Config) string {
+	return strings.Replace(config.FlinkURL, "8081", "8083", 1)
 }
 
 // writeTempSQLFile writes the SQL content to a temporary file
@@ -511,19 +409,20 @@ func escapeJSONString(s string) string {
 
 // substituteVariables replaces placeholders in SQL statements with actual values
 func (fd *FlinkDeployer) substituteVariables(sql string, resources *Resources) string {
-	replacements := map[string]string{
-		"${INPUT_TOPIC}":         resources.InputTopic,
-		"${OUTPUT_TOPIC}":        resources.OutputTopic,
-		"${BOOTSTRAP_SERVERS}":   fd.config.BootstrapServers,
-		"${SCHEMA_REGISTRY_URL}": fd.config.SchemaRegistryURL,
-	}
+	processedSQL, _ := fd.resolveVariables(sql, resources)
+	return processedSQL
+}
 
-	processedSQL := sql
-	for placeholder, value := range replacements {
-		processedSQL = strings.ReplaceAll(processedSQL, placeholder, value)
+// resolveVariables replaces the built-in and user-defined variables in SQL statement and returns
+// the names of the variables left without a value
+func (fd *FlinkDeployer) resolveVariables(sql string, resources *Resources) (string, []string) {
+	builtins := map[string]string{
+		"INPUT_TOPIC":         resources.InputTopic,
+		"OUTPUT_TOPIC":        resources.OutputTopic,
+		"BOOTSTRAP_SERVERS":   fd.config.BootstrapServers,
+		"SCHEMA_REGISTRY_URL": fd.config.SchemaRegistryURL,
 	}
-
-	return processedSQL
+	return fd.config.Variables.Resolve(sql, builtins)
 }
 
 // truncateSQL truncates SQL for display purposes
@@ -536,11 +435,33 @@ func (fd *FlinkDeployer) truncateSQL(sql string) string {
 	return cleaned
 }
 
-// Cleanup stops and removes FlinkSQL deployments
+// Cleanup stops and removes FlinkSQL deployments: it cancels the operations of the statements that
+// still run, stops the jobs of the deployments and closes the session
 func (fd *FlinkDeployer) Cleanup(ctx context.Context, deploymentIDs []string) error {
 	fmt.Printf("🧹 Cleaning up %d FlinkSQL deployments...\n", len(deploymentIDs))
+	defer func() {
+		if err := fd.Close(ctx); err != nil {
+			fmt.Printf("  ⚠️  Warning: %v\n", err)
+		}
+	}()
+
+	fd.cancelOperations(ctx)
 
+	// A file that failed part way is not among the deployments, but the statements before the
+	// failing one may have submitted jobs
+	deployed := make(map[string]bool, len(deploymentIDs))
 	for _, deploymentID := range deploymentIDs {
+		deployed[deploymentID] = true
+	}
+	var partial []string
+	for deploymentID := range fd.JobIDs() {
+		if !deployed[deploymentID] {
+			partial = append(partial, deploymentID)
+		}
+	}
+	sort.Strings(partial)
+
+	for _, deploymentID := range append(deploymentIDs, partial...) {
 		if err := fd.stopDeployment(ctx, deploymentID); err != nil {
 			return fmt.Errorf("failed to stop deployment %s: %w", deploymentID, err)
 		}
@@ -551,83 +472,106 @@ func (fd *FlinkDeployer) Cleanup(ctx context.Context, deploymentIDs []string) er
 	return nil
 }
 
-// defaultStopDeployment is the actual implementation
+// JobIDs returns the IDs of the Flink jobs the deployed statements submitted, by deployment ID
+func (fd *FlinkDeployer) JobIDs() map[string][]string {
+	fd.mu.Lock()
+	defer fd.mu.Unlock()
+	jobIDs := make(map[string][]string, len(fd.jobIDs))
+	for name, ids := range fd.jobIDs {
+		jobIDs[name] = append([]string(nil), ids...)
+	}
+	return jobIDs
+}
+
+// cancelOperations cancels the operations of the deployed statements that have not ended, then
+// closes them all to release their results
+func (fd *FlinkDeployer) cancelOperations(ctx context.Context) {
+	fd.mu.Lock()
+	operations := fd.operations
+	fd.operations = nil
+	fd.mu.Unlock()
+
+	for _, operation := range operations {
+		status, err := fd.gateway.OperationStatus(ctx, fd.sessionID, operation)
+		if err == nil && !flinkgateway.IsTerminal(status) {
+			if err := fd.gateway.CancelOperation(ctx, fd.sessionID, operation); err != nil {
+				fmt.Printf("  ⚠️  Warning: Failed to cancel operation %s: %v\n", operation, err)
+			} else {
+				fmt.Printf("  ✅ Cancelled operation: %s\n", operation)
+			}
+		}
+		_ = fd.gateway.CloseOperation(ctx, fd.sessionID, operation)
+	}
+}
+
+// defaultStopDeployment is the actual implementation: it cancels the Flink jobs the statements of
+// the deployment submitted, and only those, as other pipelines may share the cluster
 func (fd *FlinkDeployer) defaultStopDeployment(ctx context.Context, deploymentID string) error {
 	fmt.Printf("    🛑 Stopping deployment: %s\n", deploymentID)
 
+	fd.mu.Lock()
+	jobIDs := fd.jobIDs[deploymentID]
+	delete(fd.jobIDs, deploymentID)
+	fd.mu.Unlock()
+	if len(jobIDs) == 0 {
+		return nil
+	}
+
 	// Create a new context with timeout for cleanup operations to avoid cancellation issues
 	cleanupCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	// Get all running jobs and cancel them (since deploymentID is just a statement name,
-	// we can't match by name easily, so we cancel all running jobs)
-	jobsEndpoint := fmt.Sprintf("%s/jobs", fd.config.FlinkURL)
-	jobsResp, err := http.Get(jobsEndpoint)
-	if err != nil {
-		fmt.Printf("    ⚠️  Warning: Failed to get job list for cleanup: %v\n", err)
-		return nil // Don't fail completely, just warn
-	}
-	defer func() { _ = jobsResp.Body.Close() }()
-
-	if jobsResp.StatusCode != http.StatusOK {
-		fmt.Printf("    ⚠️  Warning: Failed to get job list, status: %d\n", jobsResp.StatusCode)
-		return nil
+	// Jobs that have already finished or failed need no cancellation
+	var running []string
+	for _, id := range jobIDs {
+		if status, err := fd.jobs.JobStatus(cleanupCtx, id); err != nil || !flinkgateway.IsJobTerminal(status) {
+			running = append(running, id)
+		}
 	}
+	cancelJobs(cleanupCtx, fd.jobs, running, "    ")
+	return nil
+}
 
-	jobsBody, err := io.ReadAll(jobsResp.Body)
+// cancelRunningJobs cancels all the jobs running on the cluster and waits until they have stopped.
+// It returns the number of jobs it canceled.
+func cancelRunningJobs(ctx context.Context, client *flinkgateway.Client, indent string) (int, error) {
+	jobs, err := client.Jobs(ctx)
 	if err != nil {
-		fmt.Printf("    ⚠️  Warning: Failed to read jobs response: %v\n", err)
-		return nil
-	}
-
-	var jobsResponse struct {
-		Jobs []struct {
-			ID     string `json:"id"`
-			Status string `json:"status"`
-		} `json:"jobs"`
+		return 0, fmt.Errorf("failed to get job list: %w", err)
 	}
 
-	if err := json.Unmarshal(jobsBody, &jobsResponse); err != nil {
-		fmt.Printf("    ⚠️  Warning: Failed to parse jobs response: %v\n", err)
-		return nil
+	var active []string
+	for _, job := range jobs {
+		if flinkgateway.IsJobActive(job.Status) {
+			active = append(active, job.ID)
+		}
 	}
+	return cancelJobs(ctx, client, active, indent), nil
+}
 
-	// Cancel all running jobs
+// cancelJobs cancels jobs and waits, polling with backoff, until they have stopped. It returns the
+// number of jobs it canceled; jobs that fail to cancel or to stop are reported, as the others still
+// have to be canceled. indent prefixes the messages.
+func cancelJobs(ctx context.Context, client *flinkgateway.Client, jobIDs []string, indent string) int {
 	var cancelledJobs []string
-	for _, job := range jobsResponse.Jobs {
-		if job.Status == "RUNNING" || job.Status == "CREATED" || job.Status == "RESTARTING" {
-			// Cancel the job using the cleanup context
-			jobCancelEndpoint := fmt.Sprintf("%s/jobs/%s", fd.config.FlinkURL, job.ID)
-			cancelReq, err := http.NewRequestWithContext(cleanupCtx, "PATCH",
-				jobCancelEndpoint, nil)
-			if err != nil {
-				fmt.Printf("    ⚠️  Warning: Failed to create cancel request for job %s: %v\n", job.ID, err)
-				continue
-			}
-
-			cancelResp, err := http.DefaultClient.Do(cancelReq)
-			if err != nil {
-				fmt.Printf("    ⚠️  Warning: Failed to cancel job %s: %v\n", job.ID, err)
-				continue
-			}
-			_ = cancelResp.Body.Close()
-
-			if cancelResp.StatusCode == http.StatusAccepted {
-				cancelledJobs = append(cancelledJobs, job.ID)
-				fmt.Printf("    ✅ Cancelled job with ID: %s\n", job.ID)
-			} else {
-				fmt.Printf("    ⚠️  Warning: Failed to cancel job %s, status: %d\n", job.ID, cancelResp.StatusCode)
-			}
+	for _, id := range jobIDs {
+		if err := client.CancelJob(ctx, id); err != nil {
+			fmt.Printf("%s⚠️  Warning: Failed to cancel job %s: %v\n", indent, id, err)
+			continue
 		}
+		cancelledJobs = append(cancelledJobs, id)
+		fmt.Printf("%s✅ Cancelled job with ID: %s\n", indent, id)
 	}
 
-	// Wait a moment for jobs to be cancelled
 	if len(cancelledJobs) > 0 {
-		fmt.Printf("    ⏳ Waiting for %d job(s) to be cancelled...\n", len(cancelledJobs))
-		time.Sleep(2 * time.Second)
+		fmt.Printf("%s⏳ Waiting for %d job(s) to be cancelled...\n", indent, len(cancelledJobs))
 	}
-
-	return nil
+	for _, id := range cancelledJobs {
+		if _, err := client.WaitForJob(ctx, id); err != nil {
+			fmt.Printf("%s⚠️  Warning: Job %s has not stopped: %v\n", indent, id, err)
+		}
+	}
+	return len(cancelledJobs)
 }
 
 // GetDeploymentStatus checks the status of a FlinkSQL deployment
@@ -684,65 +628,12 @@ func CancelAllRunningJobs(ctx context.Context, flinkURL string) error {
 	cleanupCtx, cancel := context.WithTimeout(context.Background(), 15*time.Second)
 	defer cancel()
 
-	// Get all jobs
-	jobsEndpoint := fmt.Sprintf("%s/jobs", flinkURL)
-	jobsResp, err := http.Get(jobsEndpoint)
-	if err != nil {
-		return fmt.Errorf("failed to get job list: %w", err)
-	}
-	defer func() { _ = jobsResp.Body.Close() }()
-
-	if jobsResp.StatusCode != http.StatusOK {
-		return fmt.Errorf("failed to get job list, status: %d", jobsResp.StatusCode)
-	}
-
-	jobsBody, err := io.ReadAll(jobsResp.Body)
+	cancelledCount, err := cancelRunningJobs(cleanupCtx, flinkgateway.NewClient(flinkURL), "  ")
 	if err != nil {
-		return fmt.Errorf("failed to read jobs response: %w", err)
-	}
-
-	var jobsResponse struct {
-		Jobs []struct {
-			ID     string `json:"id"`
-			Status string `json:"status"`
-		} `json:"jobs"`
-	}
-
-	if err := json.Unmarshal(jobsBody, &jobsResponse); err != nil {
-		return fmt.Errorf("failed to parse jobs response: %w", err)
-	}
-
-	var cancelledCount int
-	for _, job := range jobsResponse.Jobs {
-		if job.Status == "RUNNING" || job.Status == "CREATED" || job.Status == "RESTARTING" {
-			// Cancel the job using the cleanup context
-			jobCancelEndpoint := fmt.Sprintf("%s/jobs/%s", flinkURL, job.ID)
-			cancelReq, err := http.NewRequestWithContext(cleanupCtx, "PATCH",
-				jobCancelEndpoint, nil)
-			if err != nil {
-				fmt.Printf("  ⚠️  Warning: Failed to create cancel request for job %s: %v\n", job.ID, err)
-				continue
-			}
-
-			cancelResp, err := http.DefaultClient.Do(cancelReq)
-			if err != nil {
-				fmt.Printf("  ⚠️  Warning: Failed to cancel job %s: %v\n", job.ID, err)
-				continue
-			}
-			_ = cancelResp.Body.Close()
-
-			if cancelResp.StatusCode == http.StatusAccepted {
-				cancelledCount++
-				fmt.Printf("  ✅ Cancelled job with ID: %s\n", job.ID)
-			} else {
-				fmt.Printf("  ⚠️  Warning: Failed to cancel job %s, status: %d\n", job.ID, cancelResp.StatusCode)
-			}
-		}
+		return err
 	}
 
 	if cancelledCount > 0 {
-		fmt.Printf("⏳ Waiting for %d job(s) to be cancelled...\n", cancelledCount)
-		time.Sleep(3 * time.Second)
 		fmt.Printf("✅ Cancelled %d Flink jobs\n", cancelledCount)
 	} else {
 		fmt.Println("ℹ️  No running jobs found to cancel")
@@ -3,10 +3,17 @@ package pipeline
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
+	"strings"
+	"sync"
 	"sync/atomic"
 	"testing"
+	"time"
+
+	"pipegen/internal/types"
 
 	"github.com/stretchr/testify/assert"
 )
@@ -30,68 +37,6 @@ func TestFlinkDeployer_SubstituteVariables(t *testing.T) {
 	assert.Equal(t, expected, result)
 }
 
-// Table-driven tests for extractSessionID, extractOperationHandle, extractOperationStatus, extractOperationError
-func TestExtractHelpers(t *testing.T) {
-	tests := []struct {
-		name     string
-		fn       func(string) string
-		input    string
-		expected string
-	}{
-		{
-			name:     "extractSessionID valid",
-			fn:       extractSessionID,
-			input:    `{"sessionHandle":"abc123"}`,
-			expected: "abc123",
-		},
-		{
-			name:     "extractSessionID missing",
-			fn:       extractSessionID,
-			input:    `{}`,
-			expected: "",
-		},
-		{
-			name:     "extractOperationHandle valid",
-			fn:       extractOperationHandle,
-			input:    `{"operationHandle":"op456"}`,
-			expected: "op456",
-		},
-		{
-			name:     "extractOperationStatus valid",
-			fn:       extractOperationStatus,
-			input:    `{"status":"FINISHED"}`,
-			expected: "FINISHED",
-		},
-		{
-			name:     "extractOperationError valid",
-			fn:       extractOperationError,
-			input:    `{"error":"failure"}`,
-			expected: "failure",
-		},
-		{
-			name:     "extractOperationError missing",
-			fn:       extractOperationError,
-			input:    `{}`,
-			expected: "",
-		},
-	}
-	for _, tt := range tests {
-		t.Run(tt.name, func(t *testing.T) {
-			got := tt.fn(tt.input)
-			assert.Equal(t, tt.expected, got)
-		})
-	}
-}
-
-// Test escapeJSONString escapes quotes, backslashes, and newlines
-func TestEscapeJSONString(t *testing.T) {
-	input := `foo"bar\baz
-qux`
-	expected := `foo\"bar\\baz\nqux`
-	got := escapeJSONString(input)
-	assert.Equal(t, expected, got)
-}
-
 // Test truncateSQL truncates long SQL and leaves short SQL unchanged
 func TestFlinkDeployer_TruncateSQL(t *testing.T) {
 	deployer := NewFlinkDeployer(&Config{})
@@ -164,74 +109,283 @@ func TestDeploymentStatusTags(t *testing.T) {
 	assert.Equal(t, "err", status.Error)
 }
 
-// Test fetchOperationResult helper (primary endpoint, fallback, transient success, and failure)
-func TestFlinkDeployer_FetchOperationResult(t *testing.T) {
-	deployer := NewFlinkDeployer(&Config{})
-	deployer.sessionID = "sess1"
-	operationHandle := "op123"
+// Test deployInOrder deploys independent statements together only with ParallelDeploy
+func TestFlinkDeployer_DeployInOrder(t *testing.T) {
+	statements := []*types.SQLStatement{
+		{Name: "01_orders", Content: "CREATE TABLE orders (id STRING) WITH ('connector' = 'kafka')"},
+		{Name: "02_totals", Content: "CREATE TABLE totals (id STRING) WITH ('connector' = 'kafka')"},
+		{Name: "03_insert", Content: "INSERT INTO totals SELECT id FROM orders"},
+	}
 
-	t.Run("primary endpoint success", func(t *testing.T) {
-		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.URL.Path == "/v1/sessions/sess1/operations/op123/result/0" {
-				w.WriteHeader(200)
-				_, _ = w.Write([]byte(`{"results":"primary"}`))
-				return
+	for _, parallel := range []bool{false, true} {
+		var running, maxRunning int32
+		var mu sync.Mutex
+		var deployed []string
+		deployer := NewFlinkDeployer(&Config{ParallelDeploy: parallel})
+		ids, err := deployer.deployInOrder(statements, func(n int, stmt *types.SQLStatement) (string, error) {
+			current := atomic.AddInt32(&running, 1)
+			defer atomic.AddInt32(&running, -1)
+			mu.Lock()
+			if current > maxRunning {
+				maxRunning = current
 			}
-			w.WriteHeader(404)
-		}))
-		defer srv.Close()
-		body, err := deployer.fetchOperationResult(context.Background(), srv.URL, operationHandle)
+			deployed = append(deployed, stmt.Name)
+			mu.Unlock()
+			time.Sleep(20 * time.Millisecond)
+			return fmt.Sprintf("id-%d", n), nil
+		})
 		assert.NoError(t, err)
-		assert.Contains(t, body, "primary")
+		assert.Equal(t, []string{"id-1", "id-2", "id-3"}, ids)
+		assert.Equal(t, "03_insert", deployed[2])
+		if parallel {
+			assert.Equal(t, int32(2), maxRunning)
+		} else {
+			assert.Equal(t, int32(1), maxRunning)
+		}
+	}
+
+	deployer := NewFlinkDeployer(&Config{ParallelDeploy: true})
+	ids, err := deployer.deployInOrder(statements, func(n int, stmt *types.SQLStatement) (string, error) {
+		if stmt.Name == "02_totals" {
+			return "", fmt.Errorf("failed to deploy statement %s", stmt.Name)
+		}
+		return stmt.Name, nil
 	})
+	assert.EqualError(t, err, "failed to deploy statement 02_totals")
+	assert.Equal(t, []string{"01_orders"}, ids, "the insert must not deploy after its sink failed")
+}
 
-	t.Run("fallback endpoint success after 404 primary", func(t *testing.T) {
-		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			switch r.URL.Path {
-			case "/v1/sessions/sess1/operations/op123/result/0":
-				w.WriteHeader(404)
-			case "/v1/sessions/sess1/operations/op123/result":
-				w.WriteHeader(200)
-				_, _ = w.Write([]byte(`{"results":"legacy"}`))
-			default:
-				w.WriteHeader(404)
-			}
-		}))
-		defer srv.Close()
-		body, err := deployer.fetchOperationResult(context.Background(), srv.URL, operationHandle)
-		assert.NoError(t, err)
-		assert.Contains(t, body, "legacy")
+// Test deployInOrder deploys a file that changes the session only after the files before it, and
+// before the files after it
+func TestFlinkDeployer_DeployInOrderSessionBarrier(t *testing.T) {
+	statements := []*types.SQLStatement{
+		{Name: "01_orders", Content: "CREATE TABLE orders (id STRING) WITH ('connector' = 'kafka')"},
+		{Name: "02_totals", Content: "CREATE TABLE totals (id STRING) WITH ('connector' = 'kafka')"},
+		{Name: "03_set", Content: "SET 'pipeline.name' = 'orders'"},
+		{Name: "04_payments", Content: "CREATE TABLE payments (id STRING) WITH ('connector' = 'kafka')"},
+		{Name: "05_insert", Content: "INSERT INTO totals SELECT id FROM orders"},
+	}
+
+	var mu sync.Mutex
+	var events []string
+	deployer := NewFlinkDeployer(&Config{ParallelDeploy: true})
+	_, err := deployer.deployInOrder(statements, func(n int, stmt *types.SQLStatement) (string, error) {
+		mu.Lock()
+		events = append(events, "start "+stmt.Name)
+		mu.Unlock()
+		time.Sleep(10 * time.Millisecond)
+		mu.Lock()
+		events = append(events, "end "+stmt.Name)
+		mu.Unlock()
+		return stmt.Name, nil
 	})
+	assert.NoError(t, err)
 
-	t.Run("transient primary 404 then success", func(t *testing.T) {
-		var hits int32
-		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			if r.URL.Path == "/v1/sessions/sess1/operations/op123/result/0" {
-				count := atomic.AddInt32(&hits, 1)
-				if count == 1 { // first attempt 404
-					w.WriteHeader(404)
-					return
-				}
-				w.WriteHeader(200)
-				_, _ = w.Write([]byte(`{"results":"eventual"}`))
+	at := func(event string) int {
+		for i, e := range events {
+			if e == event {
+				return i
+			}
+		}
+		t.Fatalf("no event %q in %v", event, events)
+		return -1
+	}
+	assert.Greater(t, at("start 03_set"), at("end 01_orders"))
+	assert.Greater(t, at("start 03_set"), at("end 02_totals"))
+	assert.Equal(t, at("start 03_set")+1, at("end 03_set"), "the session file deploys on its own")
+	assert.Greater(t, at("start 04_payments"), at("end 03_set"))
+	assert.Greater(t, at("start 05_insert"), at("end 03_set"))
+}
+
+// Test deployFile configures the session with session statements, submits the other statements one
+// by one, and names the line of a failing one
+func TestFlinkDeployer_DeployFile(t *testing.T) {
+	var requests []string
+	operations := 0
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body struct{ Statement string }
+		switch {
+		case r.URL.Path == "/v2/sessions/sess1/configure-session":
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			requests = append(requests, "configure "+body.Statement)
+			_, _ = w.Write([]byte(`{}`))
+		case r.URL.Path == "/v1/sessions/sess1/statements":
+			assert.NoError(t, json.NewDecoder(r.Body).Decode(&body))
+			requests = append(requests, "execute "+body.Statement)
+			operations++
+			_, _ = fmt.Fprintf(w, `{"operationHandle":"op%d"}`, operations)
+		case strings.HasSuffix(r.URL.Path, "/status"):
+			if strings.Contains(r.URL.Path, fmt.Sprintf("op%d", operations)) && strings.Contains(requests[len(requests)-1], "missing") {
+				_, _ = w.Write([]byte(`{"status":"ERROR"}`))
 				return
 			}
+			_, _ = w.Write([]byte(`{"status":"FINISHED"}`))
+		case strings.HasSuffix(r.URL.Path, "/result/0"):
+			w.WriteHeader(http.StatusInternalServerError)
+			_, _ = w.Write([]byte(`{"errors":["Internal server error.","<Exception on server side:\norg.apache.flink.table.gateway.service.utils.SqlExecutionException: Failed to execute the operation.\n\tat org.apache.flink.table.gateway.service.operation.OperationManager.run(OperationManager.java:414)\nCaused by: org.apache.flink.table.api.ValidationException: Object 'missing' not found\n\tat org.apache.flink.table.planner.calcite.FlinkPlannerImpl.validate(FlinkPlannerImpl.scala:187)\n\nEnd of exception on server side>"]}`))
+		default:
 			w.WriteHeader(404)
-		}))
-		defer srv.Close()
-		body, err := deployer.fetchOperationResult(context.Background(), srv.URL, operationHandle)
-		assert.NoError(t, err)
-		assert.Contains(t, body, "eventual")
-		// ensure at least 2 hits on primary
-		assert.GreaterOrEqual(t, atomic.LoadInt32(&hits), int32(2))
-	})
+		}
+	}))
+	defer srv.Close()
 
-	t.Run("all endpoints fail", func(t *testing.T) {
-		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-			w.WriteHeader(404)
-		}))
-		defer srv.Close()
-		_, err := deployer.fetchOperationResult(context.Background(), srv.URL, operationHandle)
-		assert.Error(t, err)
-	})
+	deployer := NewFlinkDeployer(&Config{FlinkURL: srv.URL})
+	deployer.sessionID = "sess1"
+	stmt := &types.SQLStatement{
+		Name:     "02_orders",
+		FilePath: "/project/sql/02_orders.sql",
+		Content:  "SET 'pipeline.name' = 'orders';\nCREATE TABLE orders (id STRING) WITH ('connector' = 'kafka', 'topic' = '${INPUT_TOPIC}');\nINSERT INTO orders SELECT id FROM missing;",
+		Lines:    []int{2, 4, 9},
+	}
+
+	_, err := deployer.deployFile(context.Background(), stmt, &Resources{InputTopic: "in"})
+	assert.EqualError(t, err, "02_orders.sql:9: SQL statement '02_orders' failed: operation op2 failed: org.apache.flink.table.api.ValidationException: Object 'missing' not found")
+	assert.Equal(t, []string{
+		"configure SET 'pipeline.name' = 'orders'",
+		"execute CREATE TABLE orders (id STRING) WITH ('connector' = 'kafka', 'topic' = 'in')",
+		"execute INSERT INTO orders SELECT id FROM missing",
+	}, requests)
+}
+
+// Test CancelAllRunningJobs cancels the active jobs only and waits until they have stopped
+func TestCancelAllRunningJobs(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /jobs":
+			_, _ = w.Write([]byte(`{"jobs":[{"id":"j1","status":"RUNNING"},{"id":"j2","status":"CANCELED"}]}`))
+		case "PATCH /jobs/j1":
+			w.WriteHeader(http.StatusAccepted)
+		case "GET /jobs/j1":
+			_, _ = w.Write([]byte(`{"state":"CANCELED"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	assert.NoError(t, CancelAllRunningJobs(context.Background(), srv.URL))
+	assert.Equal(t, []string{"GET /jobs", "PATCH /jobs/j1", "GET /jobs/j1"}, requests)
+}
+
+// Test Cleanup cancels the operations still running, closes them all and then closes the session
+func TestFlinkDeployer_CleanupSession(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v1/sessions/sess1/operations/op1/status":
+			_, _ = w.Write([]byte(`{"status":"RUNNING"}`))
+		case "GET /v1/sessions/sess1/operations/op2/status":
+			_, _ = w.Write([]byte(`{"status":"FINISHED"}`))
+		default:
+			_, _ = w.Write([]byte(`{}`))
+		}
+	}))
+	defer srv.Close()
+
+	deployer := NewFlinkDeployer(&Config{FlinkURL: srv.URL})
+	deployer.useSession("sess1")
+	deployer.operations = []string{"op1", "op2"}
+	var stopped []string
+	deployer.stopDeployment = func(ctx context.Context, deploymentID string) error {
+		stopped = append(stopped, deploymentID)
+		return nil
+	}
+
+	assert.NoError(t, deployer.Cleanup(context.Background(), []string{"01_orders"}))
+	assert.Equal(t, []string{"01_orders"}, stopped)
+	assert.Equal(t, []string{
+		"GET /v1/sessions/sess1/operations/op1/status",
+		"POST /v1/sessions/sess1/operations/op1/cancel",
+		"DELETE /v1/sessions/sess1/operations/op1/close",
+		"GET /v1/sessions/sess1/operations/op2/status",
+		"DELETE /v1/sessions/sess1/operations/op2/close",
+		"DELETE /v1/sessions/sess1",
+	}, requests)
+
+	// The session is closed once
+	assert.NoError(t, deployer.Close(context.Background()))
+	assert.Len(t, requests, 6)
+}
+
+// Test deployStatement reads the first page of the result of a finished statement for the job it
+// submitted, and records the job
+func TestFlinkDeployer_DeployStatementResult(t *testing.T) {
+	var requests []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.URL.Path {
+		case "/v1/sessions/sess1/statements":
+			_, _ = w.Write([]byte(`{"operationHandle":"op1"}`))
+		case "/v1/sessions/sess1/operations/op1/status":
+			_, _ = w.Write([]byte(`{"status":"FINISHED"}`))
+		case "/v1/sessions/sess1/operations/op1/result/0":
+			_, _ = w.Write([]byte(`{"resultType":"EOS","resultKind":"SUCCESS_WITH_CONTENT","jobID":"job1","results":{"columns":[{"name":"job id"}],"data":[{"kind":"INSERT","fields":["job1"]}]}}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	deployer := NewFlinkDeployer(&Config{FlinkURL: srv.URL})
+	deployer.sessionID = "sess1"
+	id, err := deployer.deployStatement(context.Background(), "02_totals", "INSERT INTO totals SELECT * FROM orders")
+	assert.NoError(t, err)
+	assert.Equal(t, "02_totals", id)
+	assert.Equal(t, []string{
+		"POST /v1/sessions/sess1/statements",
+		"GET /v1/sessions/sess1/operations/op1/status",
+		"GET /v1/sessions/sess1/operations/op1/result/0",
+	}, requests)
+	assert.Equal(t, []string{"op1"}, deployer.operations)
+	assert.Equal(t, map[string][]string{"02_totals": {"job1"}}, deployer.JobIDs())
+}
+
+// Test Cleanup cancels only the jobs the deployed statements submitted, leaving the other jobs of
+// the cluster running
+func TestFlinkDeployer_CleanupCancelsOwnJobs(t *testing.T) {
+	var requests []string
+	canceled := false
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /jobs/j1":
+			if canceled {
+				_, _ = w.Write([]byte(`{"state":"CANCELED"}`))
+			} else {
+				_, _ = w.Write([]byte(`{"state":"RUNNING"}`))
+			}
+		case "PATCH /jobs/j1":
+			canceled = true
+			w.WriteHeader(http.StatusAccepted)
+		case "GET /jobs/done":
+			_, _ = w.Write([]byte(`{"state":"FINISHED"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	deployer := NewFlinkDeployer(&Config{FlinkURL: srv.URL})
+	deployer.jobIDs = map[string][]string{"03_insert": {"j1", "done"}}
+
+	assert.NoError(t, deployer.Cleanup(context.Background(), []string{"01_source", "03_insert"}))
+	assert.Equal(t, []string{"GET /jobs/j1", "GET /jobs/done", "PATCH /jobs/j1", "GET /jobs/j1"}, requests)
+	assert.Empty(t, deployer.JobIDs())
+}
+
+// Test Cleanup also stops the jobs of a file that failed after some of its statements were deployed
+func TestFlinkDeployer_CleanupPartialFile(t *testing.T) {
+	deployer := NewFlinkDeployer(&Config{})
+	deployer.jobIDs = map[string][]string{"02_enrich": {"j2"}, "03_totals": {"j3"}}
+	var stopped []string
+	deployer.stopDeployment = func(ctx context.Context, deploymentID string) error {
+		stopped = append(stopped, deploymentID)
+		return nil
+	}
+
+	assert.NoError(t, deployer.Cleanup(context.Background(), []string{"01_orders", "02_enrich"}))
+	assert.Equal(t, []string{"01_orders", "02_enrich", "03_totals"}, stopped)
 }
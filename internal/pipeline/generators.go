@@ -0,0 +1,589 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gopkg.in/yaml.v3"
+)
+
+// GeneratorsFileName is the name of the generator spec file loaded from schemas/
+const GeneratorsFileName = "generators.yaml"
+
+// Supported generator kinds
+const (
+	GeneratorRange     = "range"
+	GeneratorRegex     = "regex"
+	GeneratorEnum      = "enum"
+	GeneratorSequence  = "sequence"
+	GeneratorUUID      = "uuid"
+	GeneratorTimestamp = "timestamp"
+	GeneratorFaker     = "faker"
+	GeneratorConstant  = "constant"
+)
+
+// GeneratorSpec declares how values for a single field are generated
+type GeneratorSpec struct {
+	Kind         string          `yaml:"kind" json:"kind"`
+	Min          *float64        `yaml:"min,omitempty" json:"min,omitempty"`
+	Max          *float64        `yaml:"max,omitempty" json:"max,omitempty"`
+	Distribution string          `yaml:"distribution,omitempty" json:"distribution,omitempty"` // uniform, normal, zipf
+	Mean         *float64        `yaml:"mean,omitempty" json:"mean,omitempty"`
+	StdDev       *float64        `yaml:"stddev,omitempty" json:"stddev,omitempty"`
+	Exponent     float64         `yaml:"exponent,omitempty" json:"exponent,omitempty"` // zipf s parameter (> 1)
+	Pattern      string          `yaml:"pattern,omitempty" json:"pattern,omitempty"`
+	Values       []WeightedValue `yaml:"values,omitempty" json:"values,omitempty"`
+	Start        int64           `yaml:"start,omitempty" json:"start,omitempty"`
+	Step         int64           `yaml:"step,omitempty" json:"step,omitempty"`
+	Prefix       string          `yaml:"prefix,omitempty" json:"prefix,omitempty"`
+	From         string          `yaml:"from,omitempty" json:"from,omitempty"` // offset relative to now, e.g. "-1h"
+	To           string          `yaml:"to,omitempty" json:"to,omitempty"`
+	Format       string          `yaml:"format,omitempty" json:"format,omitempty"` // Go time layout or rfc3339 for string timestamps
+	Category     string          `yaml:"category,omitempty" json:"category,omitempty"`
+	Value        interface{}     `yaml:"value,omitempty" json:"value,omitempty"`
+}
+
+// WeightedValue is an enumerated value with a relative weight
+type WeightedValue struct {
+	Value  interface{} `yaml:"value" json:"value"`
+	Weight float64     `yaml:"weight" json:"weight"`
+}
+
+// UnmarshalYAML accepts either a bare scalar or a {value, weight} mapping
+func (wv *WeightedValue) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind == yaml.MappingNode {
+		type plain WeightedValue
+		var p plain
+		if err := node.Decode(&p); err != nil {
+			return err
+		}
+		*wv = WeightedValue(p)
+		return nil
+	}
+	var v interface{}
+	if err := node.Decode(&v); err != nil {
+		return err
+	}
+	*wv = WeightedValue{Value: v, Weight: 1}
+	return nil
+}
+
+// UnmarshalJSON accepts either a bare scalar or a {value, weight} object
+func (wv *WeightedValue) UnmarshalJSON(data []byte) error {
+	trimmed := strings.TrimSpace(string(data))
+	if strings.HasPrefix(trimmed, "{") {
+		type plain WeightedValue
+		var p plain
+		if err := json.Unmarshal(data, &p); err != nil {
+			return err
+		}
+		*wv = WeightedValue(p)
+		return nil
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	*wv = WeightedValue{Value: v, Weight: 1}
+	return nil
+}
+
+// GeneratorsFile mirrors schemas/generators.yaml.
+// Specs are grouped by schema key (file name without extension, e.g. "input") or record name.
+//
+//	schemas:
+//	  input:
+//	    email: {kind: faker, category: email}
+//	    amount: {kind: range, min: 1, max: 500, distribution: normal}
+type GeneratorsFile struct {
+	Schemas map[string]map[string]*GeneratorSpec `yaml:"schemas"`
+}
+
+// LoadGeneratorsFile loads schemas/generators.yaml from the project; a missing file is not an error
+func LoadGeneratorsFile(projectDir string) (*GeneratorsFile, error) {
+	path := filepath.Join(projectDir, "schemas", GeneratorsFileName)
+	content, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return &GeneratorsFile{Schemas: map[string]map[string]*GeneratorSpec{}}, nil
+		}
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var file GeneratorsFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if file.Schemas == nil {
+		file.Schemas = map[string]map[string]*GeneratorSpec{}
+	}
+	return &file, nil
+}
+
+// SpecsFor returns the field specs declared for a schema, matched by key first and record name second
+func (f *GeneratorsFile) SpecsFor(key string, schema *Schema) map[string]*GeneratorSpec {
+	if f == nil {
+		return nil
+	}
+	if specs, ok := f.Schemas[key]; ok {
+		return specs
+	}
+	if schema != nil {
+		if specs, ok := f.Schemas[schema.Name]; ok {
+			return specs
+		}
+	}
+	return nil
+}
+
+// Validate checks the generators of every schema, from this file and inline, against the schema's
+// fields, so a bad spec for any source fails before producing starts. Specs keyed to a schema that
+// does not exist are an error too: they are almost always a typo.
+func (f *GeneratorsFile) Validate(schemas map[string]*Schema) error {
+	keys := make([]string, 0, len(schemas))
+	for key := range schemas {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		if err := ValidateFieldGenerators(schemas[key], f.SpecsFor(key, schemas[key])); err != nil {
+			return fmt.Errorf("schema %s: %w", key, err)
+		}
+	}
+
+	if f == nil {
+		return nil
+	}
+	for key, specs := range f.Schemas {
+		found := false
+		for schemaKey, schema := range schemas {
+			if key == schemaKey || (schema != nil && key == schema.Name) {
+				found = true
+				break
+			}
+		}
+		if !found && len(specs) > 0 {
+			return fmt.Errorf("%s references unknown schema %s", GeneratorsFileName, key)
+		}
+	}
+	return nil
+}
+
+// FieldGenerator produces values for a single field
+type FieldGenerator interface {
+	Generate(messageID int) interface{}
+}
+
+// BuildFieldGenerators compiles the generators for a schema. Specs from the generators file take
+// precedence over "generator" attributes declared on the AVRO fields themselves.
+func BuildFieldGenerators(schema *Schema, specs map[string]*GeneratorSpec, rng *rand.Rand) (map[string]FieldGenerator, error) {
+	generators := make(map[string]FieldGenerator)
+	if schema == nil {
+		if len(specs) > 0 {
+			return nil, fmt.Errorf("generators declared but no schema is available")
+		}
+		return generators, nil
+	}
+
+	fieldTypes := make(map[string]interface{}, len(schema.Fields))
+	for _, field := range schema.Fields {
+		fieldTypes[field.Name] = field.Type
+		if field.Generator != nil {
+			gen, err := CompileFieldGenerator(field.Generator, field.Type, rng)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", field.Name, err)
+			}
+			generators[field.Name] = gen
+		}
+	}
+
+	names := make([]string, 0, len(specs))
+	for name := range specs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fieldType, ok := fieldTypes[name]
+		if !ok {
+			return nil, fmt.Errorf("generator declared for unknown field %s in schema %s", name, schema.Name)
+		}
+		gen, err := CompileFieldGenerator(specs[name], fieldType, rng)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", name, err)
+		}
+		generators[name] = gen
+	}
+
+	return generators, nil
+}
+
+// ValidateFieldGenerators checks that every declared generator is compatible with its schema field
+func ValidateFieldGenerators(schema *Schema, specs map[string]*GeneratorSpec) error {
+	_, err := BuildFieldGenerators(schema, specs, rand.New(rand.NewSource(1)))
+	return err
+}
+
+// CompileFieldGenerator validates a spec against the field's AVRO type and builds its generator
+func CompileFieldGenerator(spec *GeneratorSpec, avroType interface{}, rng *rand.Rand) (FieldGenerator, error) {
+	if spec == nil {
+		return nil, fmt.Errorf("empty generator spec")
+	}
+	base, logical, symbols := resolveGeneratorTarget(avroType)
+	kind := strings.ToLower(spec.Kind)
+
+	if err := checkGeneratorCompatibility(kind, base, logical); err != nil {
+		return nil, err
+	}
+
+	switch kind {
+	case GeneratorRange:
+		return newRangeGenerator(spec, base, rng)
+	case GeneratorRegex:
+		return newRegexGenerator(spec.Pattern, rng)
+	case GeneratorEnum:
+		return newEnumGenerator(spec, base, symbols, rng)
+	case GeneratorSequence:
+		step := spec.Step
+		if step == 0 {
+			step = 1
+		}
+		return &sequenceGenerator{next: spec.Start, step: step, prefix: spec.Prefix, base: base}, nil
+	case GeneratorUUID:
+		return &uuidGenerator{rng: rng}, nil
+	case GeneratorTimestamp:
+		return newTimestampGenerator(spec, base, logical, rng)
+	case GeneratorFaker:
+		return newFakerGenerator(spec.Category, rng)
+	case GeneratorConstant:
+		if spec.Value == nil {
+			return nil, fmt.Errorf("constant generator requires a value")
+		}
+		return &constantGenerator{value: coerceNumeric(spec.Value, base)}, nil
+	default:
+		return nil, fmt.Errorf("unknown generator kind %q", spec.Kind)
+	}
+}
+
+// resolveGeneratorTarget returns the primitive AVRO type, logical type and enum symbols a generator writes to.
+// Unions resolve to their first non-null branch.
+func resolveGeneratorTarget(avroType interface{}) (string, string, []string) {
+	switch t := avroType.(type) {
+	case string:
+		return t, "", nil
+	case []interface{}:
+		for _, branch := range t {
+			if s, ok := branch.(string); ok && s == "null" {
+				continue
+			}
+			return resolveGeneratorTarget(branch)
+		}
+		return "null", "", nil
+	case map[string]interface{}:
+		base, _ := t["type"].(string)
+		logical, _ := t["logicalType"].(string)
+		var symbols []string
+		if raw, ok := t["symbols"].([]interface{}); ok {
+			for _, s := range raw {
+				if str, ok := s.(string); ok {
+					symbols = append(symbols, str)
+				}
+			}
+		}
+		return base, logical, symbols
+	}
+	return "", "", nil
+}
+
+// checkGeneratorCompatibility rejects generators that cannot produce values of the field's AVRO type
+func checkGeneratorCompatibility(kind, base, logical string) error {
+	numeric := base == "int" || base == "long" || base == "float" || base == "double"
+	ok := false
+	switch kind {
+	case GeneratorRange:
+		ok = numeric && logical == ""
+	case GeneratorRegex, GeneratorUUID, GeneratorFaker:
+		ok = base == "string" && (logical == "" || logical == "uuid")
+	case GeneratorEnum:
+		ok = base == "string" || base == "enum" || base == "boolean" || (numeric && logical == "")
+	case GeneratorSequence:
+		ok = base == "string" || ((base == "int" || base == "long") && logical == "")
+	case GeneratorTimestamp:
+		ok = base == "string" || base == "long" || (base == "int" && logical == "date")
+	case GeneratorConstant:
+		ok = base != "" && base != "record" && base != "array" && base != "map"
+	default:
+		return fmt.Errorf("unknown generator kind %q", kind)
+	}
+	if !ok {
+		target := base
+		if logical != "" {
+			target = fmt.Sprintf("%s (%s)", base, logical)
+		}
+		return fmt.Errorf("%s generator is incompatible with AVRO type %s", kind, target)
+	}
+	return nil
+}
+
+// coerceNumeric converts a numeric value to the Go type matching the AVRO primitive
+func coerceNumeric(value interface{}, base string) interface{} {
+	var f float64
+	switch v := value.(type) {
+	case int:
+		f = float64(v)
+	case int32:
+		f = float64(v)
+	case int64:
+		f = float64(v)
+	case float32:
+		f = float64(v)
+	case float64:
+		f = v
+	default:
+		return value
+	}
+	switch base {
+	case "int":
+		return int32(math.Round(f))
+	case "long":
+		return int64(math.Round(f))
+	case "float":
+		return float32(f)
+	case "double":
+		return f
+	case "string":
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	}
+	return value
+}
+
+// rangeGenerator draws numbers from a bounded distribution
+type rangeGenerator struct {
+	min, max     float64
+	mean, stddev float64
+	distribution string
+	zipf         *rand.Zipf
+	base         string
+	rng          *rand.Rand
+}
+
+func newRangeGenerator(spec *GeneratorSpec, base string, rng *rand.Rand) (*rangeGenerator, error) {
+	if spec.Min == nil || spec.Max == nil {
+		return nil, fmt.Errorf("range generator requires min and max")
+	}
+	if *spec.Max < *spec.Min {
+		return nil, fmt.Errorf("range generator max (%v) must be >= min (%v)", *spec.Max, *spec.Min)
+	}
+
+	g := &rangeGenerator{
+		min:          *spec.Min,
+		max:          *spec.Max,
+		distribution: strings.ToLower(spec.Distribution),
+		base:         base,
+		rng:          rng,
+	}
+	if g.distribution == "" {
+		g.distribution = "uniform"
+	}
+
+	switch g.distribution {
+	case "uniform":
+	case "normal":
+		g.mean = (g.min + g.max) / 2
+		if spec.Mean != nil {
+			g.mean = *spec.Mean
+		}
+		g.stddev = (g.max - g.min) / 6
+		if spec.StdDev != nil {
+			g.stddev = *spec.StdDev
+		}
+	case "zipf":
+		s := spec.Exponent
+		if s == 0 {
+			s = 1.1
+		}
+		if s <= 1 {
+			return nil, fmt.Errorf("zipf exponent must be > 1, got %v", s)
+		}
+		g.zipf = rand.NewZipf(rng, s, 1, uint64(g.max-g.min))
+	default:
+		return nil, fmt.Errorf("unknown distribution %q (expected uniform, normal or zipf)", spec.Distribution)
+	}
+	return g, nil
+}
+
+func (g *rangeGenerator) Generate(messageID int) interface{} {
+	var v float64
+	switch g.distribution {
+	case "normal":
+		v = g.rng.NormFloat64()*g.stddev + g.mean
+		v = math.Max(g.min, math.Min(g.max, v))
+	case "zipf":
+		v = g.min + float64(g.zipf.Uint64())
+	default:
+		v = g.min + g.rng.Float64()*(g.max-g.min)
+	}
+	return coerceNumeric(v, g.base)
+}
+
+// enumGenerator picks from weighted values
+type enumGenerator struct {
+	values     []interface{}
+	cumulative []float64
+	total      float64
+	rng        *rand.Rand
+}
+
+func newEnumGenerator(spec *GeneratorSpec, base string, symbols []string, rng *rand.Rand) (*enumGenerator, error) {
+	if len(spec.Values) == 0 {
+		return nil, fmt.Errorf("enum generator requires at least one value")
+	}
+	allowed := make(map[string]bool, len(symbols))
+	for _, s := range symbols {
+		allowed[s] = true
+	}
+
+	g := &enumGenerator{rng: rng}
+	for _, wv := range spec.Values {
+		if wv.Weight < 0 {
+			return nil, fmt.Errorf("enum weight must be non-negative, got %v", wv.Weight)
+		}
+		value := wv.Value
+		switch base {
+		case "string", "enum":
+			value = fmt.Sprintf("%v", value)
+			if base == "enum" && !allowed[value.(string)] {
+				return nil, fmt.Errorf("value %q is not a symbol of the enum", value)
+			}
+		case "boolean":
+			if _, ok := value.(bool); !ok {
+				return nil, fmt.Errorf("value %v is not a boolean", value)
+			}
+		default:
+			value = coerceNumeric(value, base)
+			if _, isString := value.(string); isString {
+				return nil, fmt.Errorf("value %v is not numeric", value)
+			}
+		}
+		g.total += wv.Weight
+		g.values = append(g.values, value)
+		g.cumulative = append(g.cumulative, g.total)
+	}
+	if g.total == 0 {
+		return nil, fmt.Errorf("enum weights must not all be zero")
+	}
+	return g, nil
+}
+
+func (g *enumGenerator) Generate(messageID int) interface{} {
+	target := g.rng.Float64() * g.total
+	idx := sort.SearchFloat64s(g.cumulative, target)
+	if idx >= len(g.values) {
+		idx = len(g.values) - 1
+	}
+	return g.values[idx]
+}
+
+// sequenceGenerator emits monotonically increasing values
+type sequenceGenerator struct {
+	next   int64
+	step   int64
+	prefix string
+	base   string
+}
+
+func (g *sequenceGenerator) Generate(messageID int) interface{} {
+	v := g.next
+	g.next += g.step
+	switch g.base {
+	case "string":
+		return fmt.Sprintf("%s%d", g.prefix, v)
+	case "int":
+		return int32(v)
+	}
+	return v
+}
+
+// uuidGenerator emits random (v4) UUID strings drawn from the generator's random source
+type uuidGenerator struct {
+	rng *rand.Rand
+}
+
+func (g *uuidGenerator) Generate(messageID int) interface{} {
+	id, err := uuid.NewRandomFromReader(g.rng)
+	if err != nil {
+		return uuid.NewString()
+	}
+	return id.String()
+}
+
+// timestampGenerator emits instants uniformly distributed in [now+from, now+to]
+type timestampGenerator struct {
+	from, to time.Duration
+	base     string
+	logical  string
+	layout   string
+	rng      *rand.Rand
+	clock    *eventClock // Virtual clock of reproducible runs (nil reads the wall clock)
+}
+
+func newTimestampGenerator(spec *GeneratorSpec, base, logical string, rng *rand.Rand) (*timestampGenerator, error) {
+	g := &timestampGenerator{base: base, logical: logical, rng: rng, layout: time.RFC3339Nano}
+	var err error
+	if spec.From != "" {
+		if g.from, err = time.ParseDuration(spec.From); err != nil {
+			return nil, fmt.Errorf("invalid timestamp from offset %q: %w", spec.From, err)
+		}
+	}
+	if spec.To != "" {
+		if g.to, err = time.ParseDuration(spec.To); err != nil {
+			return nil, fmt.Errorf("invalid timestamp to offset %q: %w", spec.To, err)
+		}
+	}
+	if g.to < g.from {
+		return nil, fmt.Errorf("timestamp to offset (%v) must not precede from offset (%v)", g.to, g.from)
+	}
+	switch strings.ToLower(spec.Format) {
+	case "", "rfc3339", "iso8601":
+	default:
+		g.layout = spec.Format
+	}
+	return g, nil
+}
+
+func (g *timestampGenerator) Generate(messageID int) interface{} {
+	offset := g.from
+	if span := g.to - g.from; span > 0 {
+		offset += time.Duration(g.rng.Int63n(int64(span)))
+	}
+	ts := g.clock.Now(messageID).Add(offset)
+
+	switch g.base {
+	case "string":
+		return ts.UTC().Format(g.layout)
+	case "int":
+		return int32(ts.Unix() / 86400)
+	}
+	if g.logical == "timestamp-micros" || g.logical == "local-timestamp-micros" {
+		return ts.UnixMicro()
+	}
+	return ts.UnixMilli()
+}
+
+// constantGenerator always emits the same value
+type constantGenerator struct {
+	value interface{}
+}
+
+func (g *constantGenerator) Generate(messageID int) interface{} {
+	return g.value
+}
@@ -0,0 +1,255 @@
+package pipeline
+
+import (
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+)
+
+func float64Ptr(v float64) *float64 { return &v }
+
+func TestCompileFieldGeneratorCompatibility(t *testing.T) {
+	tests := []struct {
+		name     string
+		spec     *GeneratorSpec
+		avroType interface{}
+		wantErr  bool
+	}{
+		{
+			name:     "range on int",
+			spec:     &GeneratorSpec{Kind: GeneratorRange, Min: float64Ptr(1), Max: float64Ptr(10)},
+			avroType: "int",
+		},
+		{
+			name:     "range on string",
+			spec:     &GeneratorSpec{Kind: GeneratorRange, Min: float64Ptr(1), Max: float64Ptr(10)},
+			avroType: "string",
+			wantErr:  true,
+		},
+		{
+			name:     "regex on nullable string",
+			spec:     &GeneratorSpec{Kind: GeneratorRegex, Pattern: "[A-Z]{3}"},
+			avroType: []interface{}{"null", "string"},
+		},
+		{
+			name:     "regex on long",
+			spec:     &GeneratorSpec{Kind: GeneratorRegex, Pattern: "[0-9]+"},
+			avroType: "long",
+			wantErr:  true,
+		},
+		{
+			name:     "timestamp on timestamp-millis",
+			spec:     &GeneratorSpec{Kind: GeneratorTimestamp, From: "-1h"},
+			avroType: map[string]interface{}{"type": "long", "logicalType": "timestamp-millis"},
+		},
+		{
+			name:     "timestamp on boolean",
+			spec:     &GeneratorSpec{Kind: GeneratorTimestamp},
+			avroType: "boolean",
+			wantErr:  true,
+		},
+		{
+			name:     "enum value outside symbols",
+			spec:     &GeneratorSpec{Kind: GeneratorEnum, Values: []WeightedValue{{Value: "PURPLE", Weight: 1}}},
+			avroType: map[string]interface{}{"type": "enum", "symbols": []interface{}{"RED", "GREEN"}},
+			wantErr:  true,
+		},
+		{
+			name:     "unknown faker category",
+			spec:     &GeneratorSpec{Kind: GeneratorFaker, Category: "spaceship"},
+			avroType: "string",
+			wantErr:  true,
+		},
+		{
+			name:     "unknown kind",
+			spec:     &GeneratorSpec{Kind: "lottery"},
+			avroType: "string",
+			wantErr:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := CompileFieldGenerator(tt.spec, tt.avroType, rand.New(rand.NewSource(1)))
+			if (err != nil) != tt.wantErr {
+				t.Errorf("CompileFieldGenerator() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRangeGeneratorBounds(t *testing.T) {
+	for _, distribution := range []string{"uniform", "normal", "zipf"} {
+		t.Run(distribution, func(t *testing.T) {
+			spec := &GeneratorSpec{Kind: GeneratorRange, Min: float64Ptr(5), Max: float64Ptr(50), Distribution: distribution}
+			gen, err := CompileFieldGenerator(spec, "long", rand.New(rand.NewSource(42)))
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for i := 0; i < 1000; i++ {
+				v, ok := gen.Generate(i).(int64)
+				if !ok {
+					t.Fatalf("expected int64, got %T", gen.Generate(i))
+				}
+				if v < 5 || v > 50 {
+					t.Fatalf("value %d out of range [5, 50]", v)
+				}
+			}
+		})
+	}
+}
+
+func TestRegexGeneratorMatchesPattern(t *testing.T) {
+	pattern := `^ORD-[A-Z]{3}-\d{4}$`
+	gen, err := CompileFieldGenerator(&GeneratorSpec{Kind: GeneratorRegex, Pattern: pattern}, "string", rand.New(rand.NewSource(7)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	re := regexp.MustCompile(pattern)
+	for i := 0; i < 100; i++ {
+		v := gen.Generate(i).(string)
+		if !re.MatchString(v) {
+			t.Fatalf("generated %q does not match %s", v, pattern)
+		}
+	}
+}
+
+func TestEnumGeneratorWeights(t *testing.T) {
+	spec := &GeneratorSpec{Kind: GeneratorEnum, Values: []WeightedValue{
+		{Value: "common", Weight: 9},
+		{Value: "rare", Weight: 1},
+		{Value: "never", Weight: 0},
+	}}
+	gen, err := CompileFieldGenerator(spec, "string", rand.New(rand.NewSource(3)))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	counts := make(map[interface{}]int)
+	for i := 0; i < 10000; i++ {
+		counts[gen.Generate(i)]++
+	}
+	if counts["never"] != 0 {
+		t.Errorf("zero-weight value generated %d times", counts["never"])
+	}
+	if counts["common"] < 8500 || counts["common"] > 9500 {
+		t.Errorf("expected ~9000 common values, got %d", counts["common"])
+	}
+}
+
+func TestSequenceGenerator(t *testing.T) {
+	gen, err := CompileFieldGenerator(&GeneratorSpec{Kind: GeneratorSequence, Start: 100, Step: 5, Prefix: "user-"}, "string", nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for i, want := range []string{"user-100", "user-105", "user-110"} {
+		if got := gen.Generate(i); got != want {
+			t.Errorf("Generate(%d) = %v, want %v", i, got, want)
+		}
+	}
+}
+
+func TestTimestampGeneratorTypes(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	spec := &GeneratorSpec{Kind: GeneratorTimestamp, From: "-1h", To: "0s"}
+
+	millis, err := CompileFieldGenerator(spec, map[string]interface{}{"type": "long", "logicalType": "timestamp-millis"}, rng)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	now := time.Now().UnixMilli()
+	v := millis.Generate(0).(int64)
+	if v > now || v < now-time.Hour.Milliseconds()-1000 {
+		t.Errorf("timestamp-millis %d outside the last hour", v)
+	}
+
+	str, err := CompileFieldGenerator(spec, "string", rng)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := time.Parse(time.RFC3339Nano, str.Generate(0).(string)); err != nil {
+		t.Errorf("string timestamp is not RFC3339: %v", err)
+	}
+}
+
+func TestLoadGeneratorsFile(t *testing.T) {
+	projectDir := t.TempDir()
+	schemasDir := filepath.Join(projectDir, "schemas")
+	if err := os.MkdirAll(schemasDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	// Missing file is not an error
+	file, err := LoadGeneratorsFile(projectDir)
+	if err != nil {
+		t.Fatalf("unexpected error for missing file: %v", err)
+	}
+	if len(file.Schemas) != 0 {
+		t.Errorf("expected no specs, got %d", len(file.Schemas))
+	}
+
+	content := `schemas:
+  input:
+    status:
+      kind: enum
+      values:
+        - active
+        - {value: inactive, weight: 3}
+    amount: {kind: range, min: 1, max: 500, distribution: normal}
+`
+	if err := os.WriteFile(filepath.Join(schemasDir, GeneratorsFileName), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	file, err = LoadGeneratorsFile(projectDir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	specs := file.SpecsFor("input", nil)
+	if len(specs) != 2 {
+		t.Fatalf("expected 2 field specs, got %d", len(specs))
+	}
+	values := specs["status"].Values
+	if len(values) != 2 || values[0].Value != "active" || values[0].Weight != 1 || values[1].Weight != 3 {
+		t.Errorf("unexpected enum values: %+v", values)
+	}
+	if specs["amount"].Max == nil || *specs["amount"].Max != 500 {
+		t.Errorf("unexpected range spec: %+v", specs["amount"])
+	}
+}
+
+func TestBuildFieldGeneratorsUnknownField(t *testing.T) {
+	schema := &Schema{Name: "Event", Fields: []SchemaField{{Name: "id", Type: "string"}}}
+	specs := map[string]*GeneratorSpec{"missing": {Kind: GeneratorUUID}}
+	if _, err := BuildFieldGenerators(schema, specs, rand.New(rand.NewSource(1))); err == nil {
+		t.Error("expected error for generator on unknown field")
+	}
+}
+
+func TestGeneratorsFileValidate(t *testing.T) {
+	schemas := map[string]*Schema{
+		"input":    {Name: "Order", Fields: []SchemaField{{Name: "id", Type: "string"}}},
+		"payments": {Name: "Payment", Fields: []SchemaField{{Name: "amount", Type: "double"}}},
+	}
+	file := &GeneratorsFile{Schemas: map[string]map[string]*GeneratorSpec{
+		"input":   {"id": {Kind: GeneratorUUID}},
+		"Payment": {"amount": {Kind: GeneratorUUID}},
+	}}
+	if err := file.Validate(schemas); err == nil || !strings.HasPrefix(err.Error(), "schema payments: ") {
+		t.Errorf("expected the spec of the second source to fail, got %v", err)
+	}
+
+	file.Schemas["Payment"] = map[string]*GeneratorSpec{"amount": {Kind: GeneratorRange, Min: float64Ptr(1), Max: float64Ptr(500)}}
+	if err := file.Validate(schemas); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+
+	file.Schemas["paymnets"] = file.Schemas["Payment"]
+	if err := file.Validate(schemas); err == nil || err.Error() != "generators.yaml references unknown schema paymnets" {
+		t.Errorf("expected an error for the misspelled schema, got %v", err)
+	}
+}
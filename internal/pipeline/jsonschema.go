@@ -0,0 +1,401 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxJSONSchemaRefDepth bounds $ref resolution; deeper nesting is treated as a recursive definition
+const maxJSONSchemaRefDepth = 16
+
+var avroNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// isJSONSchema tells a JSON Schema document from an AVRO schema saved with a .json extension
+func isJSONSchema(definition map[string]interface{}) bool {
+	if _, ok := definition["fields"]; ok {
+		return false
+	}
+	if _, ok := definition["$schema"]; ok {
+		return true
+	}
+	_, hasProperties := definition["properties"]
+	return hasProperties || definition["type"] == "object"
+}
+
+// jsonSchemaConverter converts a JSON Schema object to the equivalent AVRO record
+type jsonSchemaConverter struct {
+	root map[string]interface{}
+}
+
+// jsonSchemaRecord converts a JSON Schema document to an AVRO record named after its title, or name if it has none.
+// Properties outside "required" and types that include "null" become nullable unions.
+func jsonSchemaRecord(content []byte, name string) (map[string]interface{}, error) {
+	decoded, err := decodeOrderedJSON(json.NewDecoder(bytes.NewReader(content)))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse JSON Schema: %w", err)
+	}
+	definition, ok := decoded.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("a JSON Schema must be an object")
+	}
+	if title, ok := definition["title"].(string); ok && title != "" {
+		name = title
+	}
+	c := &jsonSchemaConverter{root: definition}
+	record, err := c.convert(definition, sanitizeAVROName(name), 0)
+	if err != nil {
+		return nil, err
+	}
+	if unionBranches, ok := record.([]interface{}); ok {
+		record = unionBranches[1]
+	}
+	def, ok := record.(map[string]interface{})
+	if !ok || def["type"] != "record" {
+		return nil, fmt.Errorf("a JSON Schema must describe an object with properties")
+	}
+	return def, nil
+}
+
+func sanitizeAVROName(name string) string {
+	name = regexp.MustCompile(`[^A-Za-z0-9_]`).ReplaceAllString(name, "_")
+	if !avroNamePattern.MatchString(name) {
+		name = "_" + name
+	}
+	return name
+}
+
+// convert returns the AVRO type of a JSON Schema node; name names the records and enums it defines
+func (c *jsonSchemaConverter) convert(node map[string]interface{}, name string, depth int) (interface{}, error) {
+	node, err := c.deref(node, depth)
+	if err != nil {
+		return nil, err
+	}
+
+	types, nullable := jsonSchemaTypes(node)
+	if len(types) == 0 {
+		switch {
+		case node["properties"] != nil:
+			types = []string{"object"}
+		case node["enum"] != nil:
+			types = []string{"string"}
+		case node["items"] != nil:
+			types = []string{"array"}
+		}
+	}
+	if len(types) != 1 {
+		return nil, fmt.Errorf("%s: expected a single non-null type, got %v", name, node["type"])
+	}
+
+	var avroType interface{}
+	switch types[0] {
+	case "string":
+		avroType = jsonSchemaString(node, name)
+	case "integer":
+		avroType = "long"
+	case "number":
+		avroType = "double"
+	case "boolean":
+		avroType = "boolean"
+	case "array":
+		items, _ := node["items"].(map[string]interface{})
+		if items == nil {
+			return nil, fmt.Errorf("%s: arrays need an \"items\" schema", name)
+		}
+		itemType, err := c.convert(items, name+"_item", depth+1)
+		if err != nil {
+			return nil, err
+		}
+		avroType = map[string]interface{}{"type": "array", "items": itemType}
+	case "object":
+		if avroType, err = c.object(node, name, depth); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("%s: unsupported type %q", name, types[0])
+	}
+
+	if nullable {
+		return []interface{}{"null", avroType}, nil
+	}
+	return avroType, nil
+}
+
+// object converts an object with properties to a record and an object with only additionalProperties to a map
+func (c *jsonSchemaConverter) object(node map[string]interface{}, name string, depth int) (interface{}, error) {
+	properties, _ := node["properties"].(map[string]interface{})
+	if len(jsonPropertyOrder(properties)) == 0 {
+		values := interface{}("string")
+		if additional, ok := node["additionalProperties"].(map[string]interface{}); ok {
+			var err error
+			if values, err = c.convert(additional, name+"_value", depth+1); err != nil {
+				return nil, err
+			}
+		}
+		return map[string]interface{}{"type": "map", "values": values}, nil
+	}
+
+	required := make(map[string]bool)
+	for _, r := range stringList(node["required"]) {
+		required[r] = true
+	}
+
+	order := jsonPropertyOrder(properties)
+	fields := make([]interface{}, 0, len(order))
+	for _, prop := range order {
+		propNode, ok := properties[prop].(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("%s.%s: property schema must be an object", name, prop)
+		}
+		if !avroNamePattern.MatchString(prop) {
+			return nil, fmt.Errorf("%s: property %q is not a valid field name", name, prop)
+		}
+		fieldType, err := c.convert(propNode, name+"_"+prop, depth+1)
+		if err != nil {
+			return nil, err
+		}
+		if _, isUnion := fieldType.([]interface{}); !required[prop] && !isUnion {
+			fieldType = []interface{}{"null", fieldType}
+		}
+		field := map[string]interface{}{"name": prop, "type": fieldType}
+		if doc, ok := propNode["description"].(string); ok {
+			field["doc"] = doc
+		}
+		if generator, ok := propNode["generator"]; ok {
+			field["generator"] = withoutKeyOrder(generator)
+		}
+		fields = append(fields, field)
+	}
+	return map[string]interface{}{"type": "record", "name": name, "fields": fields}, nil
+}
+
+// deref resolves local references ("#/definitions/..." and "#/$defs/...")
+func (c *jsonSchemaConverter) deref(node map[string]interface{}, depth int) (map[string]interface{}, error) {
+	for {
+		ref, ok := node["$ref"].(string)
+		if !ok {
+			return node, nil
+		}
+		if depth > maxJSONSchemaRefDepth {
+			return nil, fmt.Errorf("recursive $ref %s is not supported", ref)
+		}
+		if !strings.HasPrefix(ref, "#/") {
+			return nil, fmt.Errorf("only local $ref values are supported, got %s", ref)
+		}
+		var target interface{} = c.root
+		for _, part := range strings.Split(strings.TrimPrefix(ref, "#/"), "/") {
+			parent, _ := target.(map[string]interface{})
+			target = parent[part]
+		}
+		resolved, ok := target.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("unresolved $ref %s", ref)
+		}
+		node = resolved
+		depth++
+	}
+}
+
+// jsonSchemaTypes returns the non-null types of a node and whether null is allowed
+func jsonSchemaTypes(node map[string]interface{}) ([]string, bool) {
+	var types []string
+	nullable := false
+	switch t := node["type"].(type) {
+	case string:
+		types = []string{t}
+	case []interface{}:
+		types = stringList(t)
+	}
+	kept := types[:0]
+	for _, t := range types {
+		if t == "null" {
+			nullable = true
+			continue
+		}
+		kept = append(kept, t)
+	}
+	return kept, nullable
+}
+
+// jsonSchemaString maps string formats and enums to AVRO logical types and enums
+func jsonSchemaString(node map[string]interface{}, name string) interface{} {
+	if symbols := stringList(node["enum"]); len(symbols) > 0 {
+		valid := true
+		for _, s := range symbols {
+			valid = valid && avroNamePattern.MatchString(s)
+		}
+		if valid {
+			return map[string]interface{}{"type": "enum", "name": name, "symbols": symbols}
+		}
+	}
+	switch node["format"] {
+	case "date-time":
+		return map[string]interface{}{"type": "long", "logicalType": "timestamp-millis"}
+	case "date":
+		return map[string]interface{}{"type": "int", "logicalType": "date"}
+	case "uuid":
+		return map[string]interface{}{"type": "string", "logicalType": "uuid"}
+	}
+	return "string"
+}
+
+// jsonKeyOrder is the key under which decodeOrderedJSON keeps the declaration order of an object's keys
+const jsonKeyOrder = "\x00order"
+
+// decodeOrderedJSON decodes a JSON document like encoding/json, additionally recording the key order of
+// every object, so that records derived from a JSON Schema list their fields in declaration order
+func decodeOrderedJSON(decoder *json.Decoder) (interface{}, error) {
+	tok, err := decoder.Token()
+	if err != nil {
+		return nil, err
+	}
+	switch tok {
+	case json.Delim('{'):
+		object := make(map[string]interface{})
+		var order []string
+		for decoder.More() {
+			key, err := decoder.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrderedJSON(decoder)
+			if err != nil {
+				return nil, err
+			}
+			object[key.(string)] = value
+			order = append(order, key.(string))
+		}
+		object[jsonKeyOrder] = order
+		_, err := decoder.Token()
+		return object, err
+	case json.Delim('['):
+		list := []interface{}{}
+		for decoder.More() {
+			value, err := decodeOrderedJSON(decoder)
+			if err != nil {
+				return nil, err
+			}
+			list = append(list, value)
+		}
+		_, err := decoder.Token()
+		return list, err
+	}
+	return tok, nil
+}
+
+// withoutKeyOrder drops the recorded key order from a decoded value before it is copied into the AVRO schema
+func withoutKeyOrder(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, item := range t {
+			if k != jsonKeyOrder {
+				out[k] = withoutKeyOrder(item)
+			}
+		}
+		return out
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, item := range t {
+			out[i] = withoutKeyOrder(item)
+		}
+		return out
+	}
+	return v
+}
+
+// jsonPropertyOrder returns the property names of an object node in declaration order
+func jsonPropertyOrder(properties map[string]interface{}) []string {
+	if order, ok := properties[jsonKeyOrder].([]string); ok {
+		return order
+	}
+	order := make([]string, 0, len(properties))
+	for name := range properties {
+		order = append(order, name)
+	}
+	sort.Strings(order)
+	return order
+}
+
+// jsonValue converts a record in goavro's native form to plain JSON values, following the AVRO type
+// derived from the JSON Schema: unions are unwrapped and timestamps and dates become strings
+func jsonValue(avroType interface{}, v interface{}) interface{} {
+	if v == nil {
+		return nil
+	}
+	switch t := avroType.(type) {
+	case []interface{}:
+		for _, branch := range t {
+			if s, ok := branch.(string); ok && s == "null" {
+				continue
+			}
+			if def, ok := branch.(map[string]interface{}); ok && def["type"] == "record" {
+				if wrapped, ok := v.(map[string]interface{}); ok && len(wrapped) == 1 {
+					if inner, ok := wrapped[def["name"].(string)]; ok {
+						v = inner
+					}
+				}
+			} else {
+				v = unwrapUnion(v)
+			}
+			return jsonValue(branch, v)
+		}
+		return nil
+	case map[string]interface{}:
+		switch t["logicalType"] {
+		case "timestamp-millis":
+			if ts, ok := v.(time.Time); ok {
+				return ts.UTC().Format(time.RFC3339Nano)
+			}
+			if millis, err := toInt64(v); err == nil {
+				return time.UnixMilli(millis).UTC().Format(time.RFC3339Nano)
+			}
+		case "date":
+			if ts, ok := v.(time.Time); ok {
+				return ts.UTC().Format("2006-01-02")
+			}
+		}
+		switch t["type"] {
+		case "record":
+			record, ok := v.(map[string]interface{})
+			if !ok {
+				return v
+			}
+			fields, _ := t["fields"].([]interface{})
+			out := make(map[string]interface{}, len(fields))
+			for _, f := range fields {
+				field, _ := f.(map[string]interface{})
+				name, _ := field["name"].(string)
+				if value, ok := record[name]; ok {
+					out[name] = jsonValue(field["type"], value)
+				}
+			}
+			return out
+		case "array":
+			items, ok := v.([]interface{})
+			if !ok {
+				return v
+			}
+			out := make([]interface{}, len(items))
+			for i, item := range items {
+				out[i] = jsonValue(t["items"], item)
+			}
+			return out
+		case "map":
+			entries, ok := v.(map[string]interface{})
+			if !ok {
+				return v
+			}
+			out := make(map[string]interface{}, len(entries))
+			for k, item := range entries {
+				out[k] = jsonValue(t["values"], item)
+			}
+			return out
+		}
+	}
+	return v
+}
@@ -0,0 +1,154 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+const jsonSchemaTestSchema = `{
+  "$schema": "https://json-schema.org/draft/2020-12/schema",
+  "title": "Order",
+  "type": "object",
+  "properties": {
+    "order_id": {"type": "string", "format": "uuid"},
+    "status": {"type": "string", "enum": ["PAID", "SHIPPED"]},
+    "amount": {"type": "number", "description": "Order total"},
+    "quantity": {"type": "integer"},
+    "created_at": {"type": "string", "format": "date-time"},
+    "coupon": {"type": ["string", "null"]},
+    "customer": {"$ref": "#/$defs/customer"},
+    "tags": {"type": "array", "items": {"type": "string"}},
+    "attributes": {"type": "object", "additionalProperties": {"type": "integer"}}
+  },
+  "required": ["order_id", "status", "amount", "quantity", "created_at", "customer", "tags", "attributes"],
+  "$defs": {
+    "customer": {
+      "type": "object",
+      "properties": {"id": {"type": "integer"}, "email": {"type": "string"}},
+      "required": ["id"]
+    }
+  }
+}`
+
+func TestLoadJSONSchema(t *testing.T) {
+	dir := t.TempDir()
+	schemasDir := filepath.Join(dir, "schemas")
+	if err := os.MkdirAll(schemasDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	// An AVRO schema with a .json extension stays AVRO
+	files := map[string]string{"input.json": jsonSchemaTestSchema, "output_result.json": reconciliationTestSchema}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(schemasDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	schemas, err := NewSchemaLoader(dir).LoadSchemas()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schemas["output"].SerializationFormat() != SchemaFormatAvro || schemas["output"].RegistryDefinition() != reconciliationTestSchema {
+		t.Errorf("AVRO .json schema loaded as %s", schemas["output"].SerializationFormat())
+	}
+
+	schema := schemas["input"]
+	if schema.Name != "Order" || schema.RegistryType() != "JSON" || schema.RegistryDefinition() != jsonSchemaTestSchema {
+		t.Fatalf("unexpected schema %s (%s)", schema.Name, schema.RegistryType())
+	}
+	if _, err := goavro.NewCodec(schema.Content); err != nil {
+		t.Fatalf("invalid AVRO equivalent: %v\n%s", err, schema.Content)
+	}
+
+	var names []string
+	types := make(map[string]interface{})
+	for _, f := range schema.Fields {
+		names = append(names, f.Name)
+		types[f.Name] = f.Type
+	}
+	// Fields keep the declaration order of the properties
+	if want := []string{"order_id", "status", "amount", "quantity", "created_at", "coupon", "customer", "tags", "attributes"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("fields = %v, want %v", names, want)
+	}
+	if types["amount"] != "double" || types["quantity"] != "long" || schema.Fields[2].Doc != "Order total" {
+		t.Errorf("unexpected numeric fields: %v, %v", types["amount"], types["quantity"])
+	}
+	if coupon, _ := types["coupon"].([]interface{}); len(coupon) != 2 || coupon[0] != "null" {
+		t.Errorf("nullable type should be a union, got %v", types["coupon"])
+	}
+	customer, _ := types["customer"].(map[string]interface{})
+	if customer["type"] != "record" || len(customer["fields"].([]interface{})) != 2 {
+		t.Errorf("$ref should resolve to a record, got %v", types["customer"])
+	}
+
+	for _, src := range []string{
+		`{"type": "object", "properties": {"a": {"$ref": "#/$defs/missing"}}}`,
+		`{"type": "object", "properties": {"a": {"type": ["string", "integer"]}}}`,
+		`{"type": "object", "properties": {"a": {"$ref": "other.json#/a"}}}`,
+		`{"type": "object", "properties": {"bad-name": {"type": "string"}}}`,
+		`{"type": "array", "items": {"type": "string"}}`,
+	} {
+		if _, err := ParseSchema([]byte(src), SchemaFormatJSON, "input"); err == nil {
+			t.Errorf("expected an error for %s", src)
+		}
+	}
+}
+
+func TestJSONSchemaEncoding(t *testing.T) {
+	schema, err := ParseSchema([]byte(jsonSchemaTestSchema), SchemaFormatJSON, "input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec, err := goavro.NewCodec(schema.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	p := &Producer{schema: schema, codec: codec, rng: rand.New(rand.NewSource(3))}
+	p.avroValues = newAVROValueGenerator(schema, p.rng, p.generateStringValue)
+
+	for i := 0; i < 50; i++ {
+		message, err := p.generateDynamicMessage(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := p.encodeMessage(message)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Records are plain JSON: no unions, timestamps as RFC 3339 strings
+		var decoded map[string]interface{}
+		if err := json.Unmarshal(value, &decoded); err != nil {
+			t.Fatalf("message %d is not JSON: %v", i, err)
+		}
+		if _, err := time.Parse(time.RFC3339Nano, decoded["created_at"].(string)); err != nil {
+			t.Errorf("created_at = %v, want an RFC 3339 time", decoded["created_at"])
+		}
+		customer, _ := decoded["customer"].(map[string]interface{})
+		if _, ok := customer["id"].(float64); !ok {
+			t.Errorf("customer = %v, want the record without its union wrapper", decoded["customer"])
+		}
+		if coupon, ok := decoded["coupon"]; ok && coupon != nil {
+			if _, isString := coupon.(string); !isString {
+				t.Errorf("coupon = %v, want a string or null", coupon)
+			}
+		}
+	}
+
+	// JSON written in the Confluent wire format by other producers is decoded with its writer schema
+	consumer := &Consumer{config: &Config{}, fetchSchema: func(id int) (*registeredSchema, error) {
+		return &registeredSchema{definition: jsonSchemaTestSchema, format: SchemaFormatJSON}, nil
+	}}
+	native, schemaID, err := consumer.DecodeValue("orders", confluentWireFormat(5, []byte(`{"order_id": "o-1", "amount": 12.5}`)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if record, _ := native.(map[string]interface{}); schemaID != 5 || record["order_id"] != "o-1" || record["amount"] != json.Number("12.5") {
+		t.Errorf("unexpected record %v with schema %d", native, schemaID)
+	}
+}
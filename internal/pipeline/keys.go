@@ -0,0 +1,385 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"sort"
+	"strings"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// Key formats
+const (
+	KeyFormatString = "string"
+	KeyFormatAVRO   = "avro"
+)
+
+// Key popularity skews
+const (
+	KeySkewNone   = "none"
+	KeySkewZipf   = "zipf"
+	KeySkewHotKey = "hotkey"
+)
+
+// defaultSkewedCardinality caps the key space when a skew is requested without an explicit cardinality
+const defaultSkewedCardinality = 1000
+
+// KeyStrategyConfig controls how the producer builds message keys.
+// With no fields and no cardinality every message gets a unique key-<n> key (the original behavior).
+type KeyStrategyConfig struct {
+	Fields        []string `yaml:"fields" mapstructure:"fields"`                   // Value fields that make up the key
+	Format        string   `yaml:"format" mapstructure:"format"`                   // avro (default with fields) or string
+	Cardinality   int      `yaml:"cardinality" mapstructure:"cardinality"`         // Maximum number of distinct keys (0 = unbounded)
+	Skew          string   `yaml:"skew" mapstructure:"skew"`                       // none, zipf or hotkey
+	ZipfExponent  float64  `yaml:"zipf_exponent" mapstructure:"zipf_exponent"`     // Zipf s parameter (> 1, default 1.1)
+	HotKeyPercent float64  `yaml:"hot_key_percent" mapstructure:"hot_key_percent"` // Share of messages sent to the hot keys
+	HotKeys       int      `yaml:"hot_keys" mapstructure:"hot_keys"`               // Number of hot keys (default 1)
+}
+
+// IsDefault reports whether the strategy keeps the original unique key-<n> keys
+func (c *KeyStrategyConfig) IsDefault() bool {
+	return c == nil || (len(c.Fields) == 0 && c.Cardinality == 0 && (c.Skew == "" || c.Skew == KeySkewNone))
+}
+
+// Validate normalizes defaults and checks the strategy
+func (c *KeyStrategyConfig) Validate() error {
+	if c.Format == "" {
+		c.Format = KeyFormatString
+		if len(c.Fields) > 0 {
+			c.Format = KeyFormatAVRO
+		}
+	}
+	if c.Format != KeyFormatString && c.Format != KeyFormatAVRO {
+		return fmt.Errorf("unknown key format %q (expected avro or string)", c.Format)
+	}
+	if c.Format == KeyFormatAVRO && len(c.Fields) == 0 {
+		return fmt.Errorf("avro key format requires key fields")
+	}
+	if c.Cardinality < 0 {
+		return fmt.Errorf("key cardinality must not be negative, got %d", c.Cardinality)
+	}
+
+	if c.Skew == "" {
+		c.Skew = KeySkewNone
+	}
+	switch c.Skew {
+	case KeySkewNone:
+	case KeySkewZipf:
+		if c.ZipfExponent == 0 {
+			c.ZipfExponent = 1.1
+		}
+		if c.ZipfExponent <= 1 {
+			return fmt.Errorf("zipf exponent must be > 1, got %v", c.ZipfExponent)
+		}
+	case KeySkewHotKey:
+		if c.HotKeyPercent <= 0 || c.HotKeyPercent > 100 {
+			return fmt.Errorf("hot key percent must be in (0, 100], got %v", c.HotKeyPercent)
+		}
+		if c.HotKeys == 0 {
+			c.HotKeys = 1
+		}
+	default:
+		return fmt.Errorf("unknown key skew %q (expected none, zipf or hotkey)", c.Skew)
+	}
+	if c.Skew != KeySkewNone && c.Cardinality == 0 {
+		c.Cardinality = defaultSkewedCardinality
+	}
+	if c.Skew == KeySkewHotKey && c.HotKeys >= c.Cardinality {
+		return fmt.Errorf("hot keys (%d) must be fewer than the key cardinality (%d)", c.HotKeys, c.Cardinality)
+	}
+	return nil
+}
+
+// Summary describes the strategy for the execution plan and logs
+func (c *KeyStrategyConfig) Summary() string {
+	if c.IsDefault() {
+		return "unique key-<n> per message"
+	}
+	parts := []string{}
+	if len(c.Fields) > 0 {
+		parts = append(parts, fmt.Sprintf("fields=%s (%s)", strings.Join(c.Fields, ","), c.Format))
+	}
+	if c.Cardinality > 0 {
+		parts = append(parts, fmt.Sprintf("cardinality=%d", c.Cardinality))
+	}
+	switch c.Skew {
+	case KeySkewZipf:
+		parts = append(parts, fmt.Sprintf("zipf s=%.2f", c.ZipfExponent))
+	case KeySkewHotKey:
+		parts = append(parts, fmt.Sprintf("%d hot key(s) receive %.0f%% of messages", c.HotKeys, c.HotKeyPercent))
+	}
+	return strings.Join(parts, ", ")
+}
+
+// NewKeySchema derives an AVRO key schema named <Record>Key that holds the given fields of the value schema
+func NewKeySchema(valueSchema *Schema, fields []string) (*Schema, error) {
+	if valueSchema == nil {
+		return nil, fmt.Errorf("value schema is required to derive a key schema")
+	}
+	if len(fields) == 0 {
+		return nil, fmt.Errorf("at least one key field is required")
+	}
+
+	named := newAVROValueGenerator(valueSchema, nil, nil)
+	byName := make(map[string]SchemaField, len(valueSchema.Fields))
+	for _, f := range valueSchema.Fields {
+		byName[f.Name] = f
+	}
+
+	keyFields := make([]interface{}, 0, len(fields))
+	for _, name := range fields {
+		f, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("key field %s not found in schema %s", name, valueSchema.Name)
+		}
+		keyFields = append(keyFields, map[string]interface{}{
+			"name": f.Name,
+			"type": named.inlineNamedTypes(f.Type, named.namespace, map[string]bool{}),
+		})
+	}
+
+	definition := map[string]interface{}{
+		"type":   "record",
+		"name":   valueSchema.Name + "Key",
+		"fields": keyFields,
+	}
+	if named.namespace != "" {
+		definition["namespace"] = named.namespace
+	}
+	content, err := json.MarshalIndent(definition, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to marshal key schema: %w", err)
+	}
+
+	keySchema := &Schema{
+		Name:      valueSchema.Name + "Key",
+		Namespace: named.namespace,
+		Type:      "record",
+		Content:   string(content),
+	}
+	for _, kf := range keyFields {
+		m := kf.(map[string]interface{})
+		keySchema.Fields = append(keySchema.Fields, SchemaField{Name: m["name"].(string), Type: m["type"]})
+	}
+	return keySchema, nil
+}
+
+// inlineNamedTypes replaces references to named types with their definitions so a subset of
+// fields can stand alone in a new schema. Each named type is defined once; later uses stay references.
+func (g *avroValueGenerator) inlineNamedTypes(avroType interface{}, namespace string, defined map[string]bool) interface{} {
+	switch t := avroType.(type) {
+	case string:
+		if avroPrimitives[t] {
+			return t
+		}
+		named, ok := g.lookup(t, namespace)
+		if !ok {
+			return t
+		}
+		fullName := definitionFullName(named.def, named.enclosing)
+		if defined[fullName] {
+			return fullName
+		}
+		return g.inlineNamedTypes(named.def, named.enclosing, defined)
+	case []interface{}:
+		out := make([]interface{}, len(t))
+		for i, branch := range t {
+			out[i] = g.inlineNamedTypes(branch, namespace, defined)
+		}
+		return out
+	case map[string]interface{}:
+		out := make(map[string]interface{}, len(t))
+		for k, v := range t {
+			out[k] = v
+		}
+		typeName, _ := t["type"].(string)
+		switch typeName {
+		case "record", "error", "enum", "fixed":
+			fullName := definitionFullName(t, namespace)
+			defined[fullName] = true
+			out["name"] = fullName
+			delete(out, "namespace")
+			if fields, ok := t["fields"].([]interface{}); ok {
+				ns := definitionNamespace(t, namespace)
+				inlined := make([]interface{}, len(fields))
+				for i, f := range fields {
+					fieldMap, ok := f.(map[string]interface{})
+					if !ok {
+						inlined[i] = f
+						continue
+					}
+					copied := make(map[string]interface{}, len(fieldMap))
+					for k, v := range fieldMap {
+						copied[k] = v
+					}
+					copied["type"] = g.inlineNamedTypes(fieldMap["type"], ns, defined)
+					inlined[i] = copied
+				}
+				out["fields"] = inlined
+			}
+		case "array":
+			out["items"] = g.inlineNamedTypes(t["items"], namespace, defined)
+		case "map":
+			out["values"] = g.inlineNamedTypes(t["values"], namespace, defined)
+		default:
+			if _, isString := t["type"].(string); !isString {
+				out["type"] = g.inlineNamedTypes(t["type"], namespace, defined)
+			}
+		}
+		return out
+	}
+	return avroType
+}
+
+// keyStrategy builds message keys according to a KeyStrategyConfig
+type keyStrategy struct {
+	config   *KeyStrategyConfig
+	rng      *rand.Rand
+	zipf     *rand.Zipf
+	pool     []map[string]interface{} // Key field values per key slot, filled on first use
+	codec    *goavro.Codec            // Key codec for the avro format
+	schemaID int                      // Registered key schema ID
+}
+
+func newKeyStrategy(config *KeyStrategyConfig, rng *rand.Rand) *keyStrategy {
+	k := &keyStrategy{config: config, rng: rng}
+	if config.Cardinality > 0 {
+		k.pool = make([]map[string]interface{}, config.Cardinality)
+	}
+	if config.Skew == KeySkewZipf {
+		k.zipf = rand.NewZipf(rng, config.ZipfExponent, 1, uint64(config.Cardinality-1))
+	}
+	return k
+}
+
+// pickSlot chooses the key slot for the next message according to the configured skew
+func (k *keyStrategy) pickSlot() int {
+	n := k.config.Cardinality
+	switch k.config.Skew {
+	case KeySkewZipf:
+		return int(k.zipf.Uint64())
+	case KeySkewHotKey:
+		if k.rng.Float64()*100 < k.config.HotKeyPercent {
+			return k.rng.Intn(k.config.HotKeys)
+		}
+		return k.config.HotKeys + k.rng.Intn(n-k.config.HotKeys)
+	}
+	return k.rng.Intn(n)
+}
+
+// AssignKey picks the key for a generated message. With a capped cardinality the key fields of the
+// message are overwritten with the values of the chosen key slot so key and value stay consistent.
+func (k *keyStrategy) AssignKey(message map[string]interface{}, messageID int) ([]byte, error) {
+	if k.pool == nil {
+		return k.encode(message, fmt.Sprintf("key-%d", messageID))
+	}
+
+	slot := k.pickSlot()
+	if len(k.config.Fields) == 0 {
+		return []byte(fmt.Sprintf("key-%d", slot)), nil
+	}
+	if k.pool[slot] == nil {
+		values := make(map[string]interface{}, len(k.config.Fields))
+		for _, f := range k.config.Fields {
+			values[f] = message[f]
+		}
+		k.pool[slot] = values
+	}
+	for f, v := range k.pool[slot] {
+		message[f] = v
+	}
+	return k.encode(message, "")
+}
+
+// DeriveKey builds the key from a message as-is (used when replaying recorded data)
+func (k *keyStrategy) DeriveKey(message map[string]interface{}, messageID int) ([]byte, error) {
+	if len(k.config.Fields) == 0 {
+		if k.pool != nil {
+			return []byte(fmt.Sprintf("key-%d", k.pickSlot())), nil
+		}
+		return []byte(fmt.Sprintf("key-%d", messageID)), nil
+	}
+	return k.encode(message, "")
+}
+
+func (k *keyStrategy) encode(message map[string]interface{}, fallback string) ([]byte, error) {
+	if len(k.config.Fields) == 0 {
+		return []byte(fallback), nil
+	}
+
+	if k.config.Format == KeyFormatAVRO {
+		if k.codec == nil {
+			return nil, fmt.Errorf("key codec not initialized")
+		}
+		record := make(map[string]interface{}, len(k.config.Fields))
+		for _, f := range k.config.Fields {
+			record[f] = message[f]
+		}
+		binary, err := k.codec.BinaryFromNative(nil, record)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode AVRO key: %w", err)
+		}
+		return confluentWireFormat(k.schemaID, binary), nil
+	}
+
+	parts := make([]string, len(k.config.Fields))
+	for i, f := range k.config.Fields {
+		parts[i] = keyString(message[f])
+	}
+	return []byte(strings.Join(parts, "|")), nil
+}
+
+// keyString renders a field value for a string key, unwrapping goavro unions
+func keyString(v interface{}) string {
+	if wrapped, ok := v.(map[string]interface{}); ok && len(wrapped) == 1 {
+		for _, inner := range wrapped {
+			return keyString(inner)
+		}
+	}
+	if v == nil {
+		return ""
+	}
+	return fmt.Sprintf("%v", v)
+}
+
+// confluentWireFormat prefixes an AVRO payload with the magic byte and schema ID
+func confluentWireFormat(schemaID int, payload []byte) []byte {
+	wireFormat := make([]byte, 1+4+len(payload))
+	wireFormat[0] = 0x00 // Magic byte
+
+	// Schema ID in big-endian format
+	wireFormat[1] = byte(schemaID >> 24)
+	wireFormat[2] = byte(schemaID >> 16)
+	wireFormat[3] = byte(schemaID >> 8)
+	wireFormat[4] = byte(schemaID)
+
+	copy(wireFormat[5:], payload)
+	return wireFormat
+}
+
+// PartitionShare is the number of messages written to one partition
+type PartitionShare struct {
+	Partition int     `json:"partition"`
+	Messages  int64   `json:"messages"`
+	Percent   float64 `json:"percent"`
+}
+
+// PartitionDistribution turns per-partition counts into sorted shares
+func PartitionDistribution(counts map[int]int64) []PartitionShare {
+	var total int64
+	for _, c := range counts {
+		total += c
+	}
+	shares := make([]PartitionShare, 0, len(counts))
+	for p, c := range counts {
+		share := PartitionShare{Partition: p, Messages: c}
+		if total > 0 {
+			share.Percent = float64(c) * 100 / float64(total)
+		}
+		shares = append(shares, share)
+	}
+	sort.Slice(shares, func(i, j int) bool { return shares[i].Partition < shares[j].Partition })
+	return shares
+}
@@ -0,0 +1,231 @@
+package pipeline
+
+import (
+	"bytes"
+	"encoding/json"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/riferrei/srclient"
+)
+
+func TestKeyStrategyConfigValidate(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     KeyStrategyConfig
+		wantErr    bool
+		wantFormat string
+		wantCard   int
+	}{
+		{name: "fields default to avro", config: KeyStrategyConfig{Fields: []string{"id"}}, wantFormat: KeyFormatAVRO},
+		{name: "cardinality only uses string keys", config: KeyStrategyConfig{Cardinality: 10}, wantFormat: KeyFormatString, wantCard: 10},
+		{name: "skew defaults cardinality", config: KeyStrategyConfig{Skew: KeySkewZipf}, wantFormat: KeyFormatString, wantCard: defaultSkewedCardinality},
+		{name: "avro without fields", config: KeyStrategyConfig{Format: KeyFormatAVRO, Cardinality: 5}, wantErr: true},
+		{name: "unknown format", config: KeyStrategyConfig{Fields: []string{"id"}, Format: "protobuf"}, wantErr: true},
+		{name: "unknown skew", config: KeyStrategyConfig{Skew: "pareto"}, wantErr: true},
+		{name: "bad zipf exponent", config: KeyStrategyConfig{Skew: KeySkewZipf, ZipfExponent: 0.5}, wantErr: true},
+		{name: "hotkey without percent", config: KeyStrategyConfig{Skew: KeySkewHotKey, Cardinality: 10}, wantErr: true},
+		{name: "too many hot keys", config: KeyStrategyConfig{Skew: KeySkewHotKey, HotKeyPercent: 50, HotKeys: 10, Cardinality: 10}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if tt.config.Format != tt.wantFormat {
+				t.Errorf("Format = %s, want %s", tt.config.Format, tt.wantFormat)
+			}
+			if tt.config.Cardinality != tt.wantCard {
+				t.Errorf("Cardinality = %d, want %d", tt.config.Cardinality, tt.wantCard)
+			}
+		})
+	}
+}
+
+func TestNewKeySchema(t *testing.T) {
+	schema := loadTestSchema(t, complexTestSchema)
+
+	keySchema, err := NewKeySchema(schema, []string{"order_id", "status", "billing"})
+	if err != nil {
+		t.Fatalf("NewKeySchema() error = %v", err)
+	}
+	if keySchema.Name != "OrderKey" || keySchema.Namespace != "com.example.orders" {
+		t.Errorf("unexpected key schema name %s.%s", keySchema.Namespace, keySchema.Name)
+	}
+	if len(keySchema.Fields) != 3 {
+		t.Errorf("expected 3 key fields, got %d", len(keySchema.Fields))
+	}
+	// billing references Address, which must be inlined for the key schema to stand alone
+	if _, err := goavro.NewCodec(keySchema.Content); err != nil {
+		t.Fatalf("key schema is not valid AVRO: %v\n%s", err, keySchema.Content)
+	}
+
+	if _, err := NewKeySchema(schema, []string{"missing"}); err == nil {
+		t.Error("expected error for unknown key field")
+	}
+}
+
+func TestKeyStrategyHotKeySkew(t *testing.T) {
+	cfg := &KeyStrategyConfig{Cardinality: 100, Skew: KeySkewHotKey, HotKeyPercent: 80}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	k := newKeyStrategy(cfg, rand.New(rand.NewSource(9)))
+
+	hot := 0
+	distinct := make(map[string]bool)
+	for i := 0; i < 10000; i++ {
+		key, err := k.AssignKey(map[string]interface{}{}, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		distinct[string(key)] = true
+		if string(key) == "key-0" {
+			hot++
+		}
+	}
+	if len(distinct) > 100 {
+		t.Errorf("expected at most 100 distinct keys, got %d", len(distinct))
+	}
+	if hot < 7500 || hot > 8500 {
+		t.Errorf("expected ~80%% of messages on the hot key, got %d/10000", hot)
+	}
+}
+
+func TestKeyStrategyAlignsKeyFields(t *testing.T) {
+	cfg := &KeyStrategyConfig{Fields: []string{"user_id"}, Format: KeyFormatString, Cardinality: 3}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	k := newKeyStrategy(cfg, rand.New(rand.NewSource(1)))
+
+	for i := 0; i < 100; i++ {
+		message := map[string]interface{}{"user_id": goavro.Union("string", "user-"+string(rune('a'+i%26)))}
+		key, err := k.AssignKey(message, i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(key) != keyString(message["user_id"]) {
+			t.Fatalf("key %q does not match message field %v", key, message["user_id"])
+		}
+	}
+
+	values := make(map[string]bool)
+	for _, slot := range k.pool {
+		if slot != nil {
+			values[keyString(slot["user_id"])] = true
+		}
+	}
+	if len(values) > 3 {
+		t.Errorf("expected at most 3 key values, got %d", len(values))
+	}
+}
+
+func TestKeyStrategyAVROKey(t *testing.T) {
+	schema := loadTestSchema(t, replayTestSchema)
+	cfg := &KeyStrategyConfig{Fields: []string{"user_id", "count"}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	keySchema, err := NewKeySchema(schema, cfg.Fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	codec, err := goavro.NewCodec(keySchema.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	k := newKeyStrategy(cfg, rand.New(rand.NewSource(1)))
+	k.codec = codec
+	k.schemaID = 42
+
+	key, err := k.DeriveKey(map[string]interface{}{"user_id": "u1", "count": int32(3), "score": nil}, 0)
+	if err != nil {
+		t.Fatalf("DeriveKey() error = %v", err)
+	}
+	if !bytes.Equal(key[:5], []byte{0, 0, 0, 0, 42}) {
+		t.Errorf("unexpected wire format header %v", key[:5])
+	}
+	native, _, err := codec.NativeFromBinary(key[5:])
+	if err != nil {
+		t.Fatal(err)
+	}
+	record := native.(map[string]interface{})
+	if record["user_id"] != "u1" || record["count"] != int32(3) {
+		t.Errorf("unexpected decoded key %v", record)
+	}
+}
+
+func TestInitializeKeyStrategyRegistersDerivedSchema(t *testing.T) {
+	schema := loadTestSchema(t, replayTestSchema)
+	var registered []string
+	compatible := true
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == "POST" && r.URL.Path == "/subjects/orders-key/versions":
+			if !compatible {
+				w.WriteHeader(http.StatusConflict)
+				_, _ = w.Write([]byte(`{"error_code":409,"message":"Schema being registered is incompatible with an earlier schema"}`))
+				return
+			}
+			var body struct{ Schema string }
+			_ = json.NewDecoder(r.Body).Decode(&body)
+			registered = append(registered, body.Schema)
+			_, _ = w.Write([]byte(`{"id":7}`))
+		case r.Method == "GET" && r.URL.Path == "/schemas/ids/7":
+			_, _ = w.Write([]byte(`{"schema":"{\"type\":\"string\"}"}`))
+		default:
+			t.Errorf("unexpected request %s %s", r.Method, r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer srv.Close()
+
+	cfg := &KeyStrategyConfig{Format: KeyFormatAVRO, Fields: []string{"user_id"}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	p := &Producer{
+		config:   &Config{KeyStrategy: cfg},
+		rng:      rand.New(rand.NewSource(1)),
+		srClient: srclient.CreateSchemaRegistryClient(srv.URL),
+	}
+	if err := p.initializeKeyStrategy(schema, "orders-key"); err != nil {
+		t.Fatalf("initializeKeyStrategy() error = %v", err)
+	}
+	if p.keys.schemaID != 7 {
+		t.Errorf("schemaID = %d, want 7", p.keys.schemaID)
+	}
+	if len(registered) != 1 || !strings.Contains(registered[0], `"user_id"`) {
+		t.Errorf("expected the derived key schema to be registered, got %v", registered)
+	}
+
+	// Keys derived from other fields than the registered ones fail instead of being misdecoded
+	compatible = false
+	if err := p.initializeKeyStrategy(schema, "orders-key"); err == nil {
+		t.Error("expected an error for an incompatible key schema")
+	}
+}
+
+func TestPartitionDistribution(t *testing.T) {
+	shares := PartitionDistribution(map[int]int64{2: 25, 0: 50, 1: 25})
+	if len(shares) != 3 {
+		t.Fatalf("expected 3 shares, got %d", len(shares))
+	}
+	if shares[0].Partition != 0 || shares[0].Percent != 50 {
+		t.Errorf("unexpected first share %+v", shares[0])
+	}
+	if shares[2].Partition != 2 || shares[2].Messages != 25 {
+		t.Errorf("unexpected last share %+v", shares[2])
+	}
+}
@@ -0,0 +1,274 @@
+package pipeline
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// ProducedAtHeader is the Kafka header the producer stamps on every record with its produce time, in Unix microseconds
+const ProducedAtHeader = "pipegen-produced-at"
+
+// latencyBucketBounds are the upper bounds of the end-to-end latency histogram; slower records fall in a last, open bucket
+var latencyBucketBounds = []time.Duration{
+	time.Millisecond, 2 * time.Millisecond, 5 * time.Millisecond,
+	10 * time.Millisecond, 20 * time.Millisecond, 50 * time.Millisecond,
+	100 * time.Millisecond, 200 * time.Millisecond, 500 * time.Millisecond,
+	time.Second, 2 * time.Second, 5 * time.Second,
+	10 * time.Second, 30 * time.Second, time.Minute,
+}
+
+// liveEndToEnd is the latency tracker of the running consumer, for live displays
+var liveEndToEnd atomic.Pointer[latencyTracker]
+
+// LiveEndToEndLatency returns the produce-to-output latency measured so far, or nil before an output record was timed
+func LiveEndToEndLatency() *LatencyStats {
+	tracker := liveEndToEnd.Load()
+	if tracker == nil {
+		return nil
+	}
+	return tracker.stats()
+}
+
+// LatencyStats summarizes produce-to-output latencies
+type LatencyStats struct {
+	Samples int64         `json:"samples"`
+	P50     time.Duration `json:"p50"`
+	P95     time.Duration `json:"p95"`
+	P99     time.Duration `json:"p99"`
+	Max     time.Duration `json:"max"`
+	Mean    time.Duration `json:"mean"`
+}
+
+// Summary returns a one-line description, e.g. "p50 120ms, p95 340ms, p99 1.2s, max 2.1s (9800 records)"
+func (s *LatencyStats) Summary() string {
+	round := func(d time.Duration) time.Duration { return d.Round(time.Millisecond) }
+	return fmt.Sprintf("p50 %v, p95 %v, p99 %v, max %v (%d records)", round(s.P50), round(s.P95), round(s.P99), round(s.Max), s.Samples)
+}
+
+// LatencyPoint is the latency of the records produced in one second of the run
+type LatencyPoint struct {
+	At    time.Time     `json:"at"`
+	Count int64         `json:"count"`
+	Mean  time.Duration `json:"mean"`
+	Max   time.Duration `json:"max"`
+}
+
+// latencyTracker collects the produce-to-output latency of output records. It is safe for concurrent use.
+type latencyTracker struct {
+	samples *latencyRecorder
+
+	mu        sync.Mutex
+	max       time.Duration
+	histogram []int64                  // Counts per latencyBucketBounds entry, plus the open last bucket
+	seconds   map[int64]*latencySecond // Keyed by the Unix second the records were produced in
+}
+
+type latencySecond struct {
+	count int64
+	total time.Duration
+	max   time.Duration
+}
+
+func newLatencyTracker() *latencyTracker {
+	return &latencyTracker{
+		samples:   newLatencyRecorder(),
+		histogram: make([]int64, len(latencyBucketBounds)+1),
+		seconds:   make(map[int64]*latencySecond),
+	}
+}
+
+// observe records an output record produced at producedAt and read at outputAt
+func (t *latencyTracker) observe(producedAt, outputAt time.Time) {
+	latency := outputAt.Sub(producedAt)
+	if latency < 0 {
+		latency = 0
+	}
+	t.samples.observe(latency)
+
+	bucket := sort.Search(len(latencyBucketBounds), func(i int) bool { return latency <= latencyBucketBounds[i] })
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if latency > t.max {
+		t.max = latency
+	}
+	t.histogram[bucket]++
+	second := t.seconds[producedAt.Unix()]
+	if second == nil {
+		second = &latencySecond{}
+		t.seconds[producedAt.Unix()] = second
+	}
+	second.count++
+	second.total += latency
+	if latency > second.max {
+		second.max = latency
+	}
+}
+
+// stats returns the latency percentiles, or nil before a record was timed
+func (t *latencyTracker) stats() *LatencyStats {
+	p50, p95, p99, mean := t.samples.percentiles()
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	var samples int64
+	for _, count := range t.histogram {
+		samples += count
+	}
+	if samples == 0 {
+		return nil
+	}
+	return &LatencyStats{Samples: samples, P50: p50, P95: p95, P99: p99, Max: t.max, Mean: mean}
+}
+
+// timeline returns the latency per produce second, oldest first
+func (t *latencyTracker) timeline() []LatencyPoint {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	points := make([]LatencyPoint, 0, len(t.seconds))
+	for unix, second := range t.seconds {
+		points = append(points, LatencyPoint{
+			At:    time.Unix(unix, 0),
+			Count: second.count,
+			Mean:  second.total / time.Duration(second.count),
+			Max:   second.max,
+		})
+	}
+	sort.Slice(points, func(i, j int) bool { return points[i].At.Before(points[j].At) })
+	return points
+}
+
+// producedAt finds when an output record was produced: from the header the producer stamped,
+// which reaches the output when the SQL forwards headers, or from a designated record field
+func producedAt(msg *kafka.Message, record map[string]interface{}, field string) (time.Time, bool) {
+	for _, header := range msg.Headers {
+		if header.Key != ProducedAtHeader {
+			continue
+		}
+		if micros, err := strconv.ParseInt(string(header.Value), 10, 64); err == nil {
+			return time.UnixMicro(micros), true
+		}
+	}
+	if field == "" || record == nil {
+		return time.Time{}, false
+	}
+	return toTime(unwrapUnion(record[field]))
+}
+
+// latencyStamp writes the produce time into a designated input field, for pipelines that pass it through to the output
+type latencyStamp struct {
+	field   SchemaField
+	base    string
+	logical string
+}
+
+// newLatencyStamp checks that a field can carry the produce time: a long (epoch millis), a timestamp or a string
+func newLatencyStamp(schema *Schema, name string) (*latencyStamp, error) {
+	field, ok := schemaField(schema, name)
+	if !ok {
+		return nil, fmt.Errorf("latency field %s not found in schema %s", name, schema.Name)
+	}
+	base, logical, _ := resolveGeneratorTarget(field.Type)
+	switch {
+	case base == "string":
+	case base == "long" && (logical == "" || logical == "timestamp-millis" || logical == "timestamp-micros" || logical == "local-timestamp-millis"):
+	default:
+		return nil, fmt.Errorf("latency field %s must be a long, a timestamp or a string, got %s", name, base)
+	}
+	return &latencyStamp{field: field, base: base, logical: logical}, nil
+}
+
+// value converts a produce time to the goavro native value of the field
+func (s *latencyStamp) value(t time.Time) interface{} {
+	switch {
+	case s.base == "string":
+		return t.UTC().Format(time.RFC3339Nano)
+	case s.logical == "timestamp-millis" || s.logical == "timestamp-micros":
+		return t
+	}
+	return t.UnixMilli()
+}
+
+// LatencyBucket is one bar of the end-to-end latency histogram
+type LatencyBucket struct {
+	Label   string
+	Count   int64
+	Percent float64 // Share of all timed records
+}
+
+// LatencyChart holds the end-to-end latency distribution and latency over time for the execution report
+type LatencyChart struct {
+	Stats      *LatencyStats
+	Histogram  []LatencyBucket
+	Width      int
+	Height     int
+	MaxLatency time.Duration
+	Duration   time.Duration
+	MeanPoints string // Mean latency per produce second, as an SVG polyline
+	MaxPoints  string // Maximum latency per produce second
+}
+
+// chart renders the latency distribution; the timeline starts at origin (the producer's first send)
+// so it lines up with the producer rate chart. It is nil before a record was timed.
+func (t *latencyTracker) chart(origin time.Time) *LatencyChart {
+	stats := t.stats()
+	if stats == nil {
+		return nil
+	}
+	chart := &LatencyChart{Stats: stats, Width: rateChartWidth, Height: rateChartHeight}
+
+	t.mu.Lock()
+	last := len(t.histogram) - 1
+	for last > 0 && t.histogram[last] == 0 {
+		last--
+	}
+	for i := 0; i <= last; i++ {
+		label := fmt.Sprintf("> %v", latencyBucketBounds[len(latencyBucketBounds)-1])
+		if i < len(latencyBucketBounds) {
+			label = fmt.Sprintf("≤ %v", latencyBucketBounds[i])
+		}
+		chart.Histogram = append(chart.Histogram, LatencyBucket{
+			Label:   label,
+			Count:   t.histogram[i],
+			Percent: float64(t.histogram[i]) / float64(stats.Samples) * 100,
+		})
+	}
+	t.mu.Unlock()
+
+	points := t.timeline()
+	if origin.IsZero() || origin.After(points[0].At) {
+		origin = points[0].At
+	}
+	origin = origin.Truncate(time.Second)
+	chart.Duration = points[len(points)-1].At.Sub(origin)
+	if len(points) < 2 || chart.Duration <= 0 {
+		return chart
+	}
+	for _, p := range points {
+		if p.Max > chart.MaxLatency {
+			chart.MaxLatency = p.Max
+		}
+	}
+	if chart.MaxLatency <= 0 {
+		chart.MaxLatency = time.Millisecond
+	}
+
+	polyline := func(value func(LatencyPoint) time.Duration) string {
+		var b strings.Builder
+		for _, p := range points {
+			x := float64(p.At.Sub(origin)) * rateChartWidth / float64(chart.Duration)
+			y := rateChartHeight - float64(value(p))*rateChartHeight/float64(chart.MaxLatency)
+			fmt.Fprintf(&b, "%.1f,%.1f ", x, y)
+		}
+		return strings.TrimSpace(b.String())
+	}
+	chart.MeanPoints = polyline(func(p LatencyPoint) time.Duration { return p.Mean })
+	chart.MaxPoints = polyline(func(p LatencyPoint) time.Duration { return p.Max })
+	return chart
+}
@@ -0,0 +1,152 @@
+package pipeline
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestLatencyTracker(t *testing.T) {
+	tracker := newLatencyTracker()
+	if tracker.stats() != nil || tracker.chart(time.Time{}) != nil {
+		t.Fatal("expected no statistics before a record was timed")
+	}
+
+	start := time.Unix(1700000000, 0)
+	for i := 1; i <= 100; i++ {
+		produced := start.Add(time.Duration(i%3) * time.Second)
+		tracker.observe(produced, produced.Add(time.Duration(i)*time.Millisecond))
+	}
+
+	stats := tracker.stats()
+	if stats.Samples != 100 || stats.Max != 100*time.Millisecond {
+		t.Fatalf("stats = %+v, want 100 samples up to 100ms", stats)
+	}
+	if stats.P50 != 51*time.Millisecond || stats.P99 != 99*time.Millisecond {
+		t.Errorf("p50/p99 = %v/%v, want 51ms/99ms", stats.P50, stats.P99)
+	}
+
+	timeline := tracker.timeline()
+	if len(timeline) != 3 || !timeline[0].At.Equal(start) {
+		t.Fatalf("timeline = %+v, want 3 seconds from %v", timeline, start)
+	}
+	var total int64
+	for _, p := range timeline {
+		total += p.Count
+	}
+	if total != 100 {
+		t.Errorf("timeline counts %d records, want 100", total)
+	}
+
+	chart := tracker.chart(start.Add(-time.Second))
+	if chart.Duration != 3*time.Second || chart.MaxLatency != 100*time.Millisecond {
+		t.Errorf("chart covers %v up to %v, want 3s up to 100ms", chart.Duration, chart.MaxLatency)
+	}
+	if n := len(strings.Fields(chart.MeanPoints)); n != 3 {
+		t.Errorf("expected 3 mean points, got %d", n)
+	}
+	// 1ms, 2ms, 3-5ms, 6-10ms, 11-20ms, 21-50ms and 51-100ms; the empty slower buckets are left out
+	if len(chart.Histogram) != 7 || chart.Histogram[6].Count != 50 || chart.Histogram[6].Percent != 50 {
+		t.Errorf("unexpected histogram: %+v", chart.Histogram)
+	}
+}
+
+func TestProducedAt(t *testing.T) {
+	at := time.UnixMicro(1700000000123456)
+	header := []kafka.Header{{Key: ProducedAtHeader, Value: []byte(strconv.FormatInt(at.UnixMicro(), 10))}}
+
+	tests := []struct {
+		name    string
+		headers []kafka.Header
+		record  map[string]interface{}
+		field   string
+		want    time.Time
+		wantOK  bool
+	}{
+		{name: "header", headers: header, want: at, wantOK: true},
+		{name: "header wins over field", headers: header, record: map[string]interface{}{"produced_at": int64(1)}, field: "produced_at", want: at, wantOK: true},
+		{name: "epoch millis field", record: map[string]interface{}{"produced_at": int64(1700000000123)}, field: "produced_at", want: time.UnixMilli(1700000000123), wantOK: true},
+		{name: "timestamp in union", record: map[string]interface{}{"produced_at": map[string]interface{}{"long.timestamp-millis": at}}, field: "produced_at", want: at, wantOK: true},
+		{name: "string field", record: map[string]interface{}{"produced_at": "2024-01-01T00:00:00.5Z"}, field: "produced_at", want: time.Date(2024, 1, 1, 0, 0, 0, 5e8, time.UTC), wantOK: true},
+		{name: "no field configured", record: map[string]interface{}{"produced_at": int64(1)}},
+		{name: "bad header", headers: []kafka.Header{{Key: ProducedAtHeader, Value: []byte("soon")}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := producedAt(&kafka.Message{Headers: tt.headers}, tt.record, tt.field)
+			if ok != tt.wantOK || (ok && !got.Equal(tt.want)) {
+				t.Errorf("producedAt() = %v, %v, want %v, %v", got, ok, tt.want, tt.wantOK)
+			}
+		})
+	}
+}
+
+func TestNewLatencyStamp(t *testing.T) {
+	schema := loadTestSchema(t, `{
+  "type": "record",
+  "name": "Event",
+  "fields": [
+    {"name": "produced_at", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "sent", "type": ["null", "long"]},
+    {"name": "label", "type": "string"},
+    {"name": "count", "type": "int"}
+  ]
+}`)
+
+	tests := []struct {
+		field   string
+		want    interface{}
+		wantErr bool
+	}{
+		{field: "produced_at", want: time.UnixMilli(1700000000123)},
+		{field: "sent", want: int64(1700000000123)},
+		{field: "label", want: "2023-11-14T22:13:20.123Z"},
+		{field: "count", wantErr: true},
+		{field: "missing", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.field, func(t *testing.T) {
+			stamp, err := newLatencyStamp(schema, tt.field)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("newLatencyStamp() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			got := stamp.value(time.UnixMilli(1700000000123))
+			if ts, ok := got.(time.Time); ok {
+				if !ts.Equal(tt.want.(time.Time)) {
+					t.Errorf("value() = %v, want %v", got, tt.want)
+				}
+			} else if got != tt.want {
+				t.Errorf("value() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConsumerTimesOutputRecords(t *testing.T) {
+	consumer := &Consumer{config: &Config{}, latency: newLatencyTracker()}
+	produced := time.Now().Add(-250 * time.Millisecond)
+	msg := &kafka.Message{
+		Value:   []byte(`{"user_id": "a"}`),
+		Headers: []kafka.Header{{Key: ProducedAtHeader, Value: []byte(strconv.FormatInt(produced.UnixMicro(), 10))}},
+	}
+	if err := consumer.processMessage(msg); err != nil {
+		t.Fatal(err)
+	}
+	if err := consumer.processMessage(&kafka.Message{Value: []byte(`{"user_id": "b"}`)}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := consumer.GetStats().EndToEnd
+	if stats == nil || stats.Samples != 1 {
+		t.Fatalf("EndToEnd = %+v, want one timed record", stats)
+	}
+	if stats.P50 < 250*time.Millisecond || stats.P50 > 5*time.Second {
+		t.Errorf("latency = %v, want about 250ms", stats.P50)
+	}
+}
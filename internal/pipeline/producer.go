@@ -3,8 +3,14 @@ package pipeline
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/linkedin/goavro/v2"
@@ -20,8 +26,35 @@ type Producer struct {
 	srClient     *srclient.SchemaRegistryClient
 	schemaID     int
 	schema       *Schema   // Store schema for dynamic message generation
-	messageCount int64     // Track actual messages sent
+	messageCount int64     // Track actual messages sent (atomic)
 	startTime    time.Time // Track when producer started
+
+	rng            *rand.Rand                // Random source for synthetic message generation, seeded from Config.Seed
+	clock          *eventClock               // Virtual clock of reproducible runs (nil reads the wall clock)
+	generatorSpecs map[string]*GeneratorSpec // Per-field generator specs from schemas/generators.yaml
+	generators     map[string]FieldGenerator // Compiled generators keyed by field name
+	avroValues     *avroValueGenerator       // Walks the full AVRO type tree for fields without a generator
+	keys           *keyStrategy              // Message key strategy (nil keeps unique key-<n> keys)
+	references     []fieldReference          // Fields filled from values produced to other sources
+	published      map[string]*referencePool // Produced values of fields other sources reference
+	secondary      bool                      // Additional source producer; leaves the live pipeline status to the primary
+	disorder       *eventDisorder            // Out-of-order and late event injection (nil keeps in-order event times)
+	faults         *faultInjector            // Poison message injection (nil sends every message intact)
+	rates          *rateTimeline             // Messages sent per second next to the expected rate
+	latency        *latencyRecorder          // Produce latency samples for the p50/p95/p99 percentiles
+	latencyStamp   *latencyStamp             // Input field stamped with the produce time (nil stamps the header only)
+	ledger         *recordLedger             // IDs of delivered records for reconciliation with the output (nil keeps none)
+
+	// Updated atomically by the writer pool
+	bytesSent         int64
+	errorCount        int64
+	backpressureWaits int64
+	targetRate        int64 // Rate the pacer currently aims for
+	expectedMilli     int64 // Messages requested since sending started, in thousandths
+	sendStartNanos    int64 // Unix nanos when pacing started (0 before)
+
+	partitionMu     sync.Mutex
+	partitionCounts map[int]int64 // Delivered messages per partition
 }
 
 // NewProducer creates a new Kafka producer
@@ -31,26 +64,97 @@ func NewProducer(config *Config) (*Producer, error) {
 		Addr:         kafka.TCP(config.BootstrapServers),
 		Balancer:     &kafka.LeastBytes{},
 		BatchTimeout: 10 * time.Millisecond,
-		BatchSize:    100,
+		BatchSize:    DefaultProducerBatchSize,
+	}
+	if config.ProducerBatchSize > 0 {
+		writer.BatchSize = config.ProducerBatchSize
+	}
+
+	// Keyed messages must be partitioned by key, the same way the Java client does
+	if !config.KeyStrategy.IsDefault() {
+		writer.Balancer = kafka.Murmur2Balancer{}
 	}
 
 	fmt.Printf("  ✅ Writer configured with address: %s\n", config.BootstrapServers)
 
-	return &Producer{
-		config:       config,
-		writer:       writer,
-		srClient:     nil, // Will be initialized when schema is provided
-		messageCount: 0,
-		startTime:    time.Now(),
-	}, nil
+	p := &Producer{
+		config:          config,
+		writer:          writer,
+		srClient:        nil, // Will be initialized when schema is provided
+		messageCount:    0,
+		startTime:       time.Now(),
+		rng:             rand.New(rand.NewSource(config.Seed)),
+		clock:           newEventClock(config),
+		rates:           &rateTimeline{},
+		latency:         newLatencyRecorder(),
+		partitionCounts: make(map[int]int64),
+	}
+	writer.Completion = p.recordDelivery
+	return p, nil
+}
+
+// recordDelivery tracks how many messages land on each partition
+func (p *Producer) recordDelivery(messages []kafka.Message, err error) {
+	if err != nil {
+		return
+	}
+	p.partitionMu.Lock()
+	defer p.partitionMu.Unlock()
+	for _, m := range messages {
+		p.partitionCounts[m.Partition]++
+	}
+}
+
+// SetGeneratorSpecs configures declarative per-field generators; they are compiled against the schema on start
+func (p *Producer) SetGeneratorSpecs(specs map[string]*GeneratorSpec) {
+	p.generatorSpecs = specs
+}
+
+// publish returns the pool that collects produced values of a field for other sources to reference
+func (p *Producer) publish(field string) *referencePool {
+	if p.published == nil {
+		p.published = make(map[string]*referencePool)
+	}
+	pool, ok := p.published[field]
+	if !ok {
+		pool = &referencePool{}
+		p.published[field] = pool
+	}
+	return pool
+}
+
+// addReference fills a field from another source's produced values at the given match ratio
+func (p *Producer) addReference(field SchemaField, pool *referencePool, ratio float64) {
+	p.references = append(p.references, fieldReference{field: field, pool: pool, ratio: ratio})
+}
+
+// applyReferences replaces referencing fields with referenced values; non-matching messages get
+// values that cannot join, so the configured match ratio is what the pipeline actually sees
+func (p *Producer) applyReferences(message map[string]interface{}) {
+	for _, ref := range p.references {
+		if p.rng.Float64() < ref.ratio {
+			if v, ok := ref.pool.pick(p.rng); ok {
+				message[ref.field.Name] = p.avroValues.WrapFieldValue(ref.field, v)
+				continue
+			}
+		}
+		// Random UUIDs never collide, and rewriting them would break the logical type
+		if _, logical, _ := resolveGeneratorTarget(ref.field.Type); logical == "uuid" {
+			continue
+		}
+		if v := unwrapUnion(message[ref.field.Name]); v != nil {
+			message[ref.field.Name] = p.avroValues.WrapFieldValue(ref.field, orphanValue(v))
+		}
+	}
 }
 
 // InitializeSchemaRegistry initializes the Schema Registry client and gets the existing schema
 func (p *Producer) InitializeSchemaRegistry(schema *Schema, subject string) error {
 	fmt.Printf("🔗 Initializing Schema Registry client for subject: %s\n", subject)
 
-	// Store schema for dynamic message generation
-	p.schema = schema
+	if err := p.prepareGeneration(schema); err != nil {
+		return err
+	}
 
 	// Create Schema Registry client
 	p.srClient = srclient.CreateSchemaRegistryClient(p.config.SchemaRegistryURL)
@@ -60,7 +164,7 @@ func (p *Producer) InitializeSchemaRegistry(schema *Schema, subject string) erro
 	if err != nil {
 		// If schema doesn't exist, register it manually
 		fmt.Printf("  📋 Schema not found for subject %s, registering new schema\n", subject)
-		schemaObj, err = p.srClient.CreateSchema(subject, schema.Content, srclient.Avro)
+		schemaObj, err = p.srClient.CreateSchema(subject, schema.RegistryDefinition(), schema.RegistryType())
 		if err != nil {
 			return fmt.Errorf("failed to register schema: %w", err)
 		}
@@ -71,13 +175,107 @@ func (p *Producer) InitializeSchemaRegistry(schema *Schema, subject string) erro
 
 	p.schemaID = schemaObj.ID()
 
-	// Create AVRO codec from schema
+	// Create AVRO codec from schema; Protobuf and JSON Schema records are generated with their AVRO equivalent
 	codec, err := goavro.NewCodec(schema.Content)
 	if err != nil {
 		return fmt.Errorf("failed to create AVRO codec: %w", err)
 	}
 	p.codec = codec
 
+	if p.config.Faults.IsEnabled() {
+		faults, err := newFaultInjector(p.config.Faults, schema, p.rng)
+		if err != nil {
+			return fmt.Errorf("invalid fault injection: %w", err)
+		}
+		faults.schemaID = p.schemaID
+		faults.encode = p.encodeMessageRegistered
+		faults.wrap = p.avroValues.WrapFieldValue
+		p.faults = faults
+		fmt.Printf("  💥 Injecting faults: %s\n", p.config.Faults.Summary())
+	}
+
+	if !p.config.KeyStrategy.IsDefault() {
+		if err := p.initializeKeyStrategy(schema, strings.TrimSuffix(subject, "-value")+"-key"); err != nil {
+			return fmt.Errorf("failed to initialize key strategy: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// prepareGeneration sets up message generation for a schema: value generators, declarative field
+// generators and event-time disorder, all drawing from the producer's seeded random source
+func (p *Producer) prepareGeneration(schema *Schema) error {
+	// Store schema for dynamic message generation
+	p.schema = schema
+	p.avroValues = newAVROValueGenerator(schema, p.rng, p.generateStringValue)
+	p.avroValues.clock = p.clock
+
+	// Compile declarative field generators before touching Schema Registry so bad specs fail fast
+	generators, err := BuildFieldGenerators(schema, p.generatorSpecs, p.rng)
+	if err != nil {
+		return fmt.Errorf("invalid field generators: %w", err)
+	}
+	for _, gen := range generators {
+		if ts, ok := gen.(*timestampGenerator); ok {
+			ts.clock = p.clock
+		}
+	}
+	p.generators = generators
+	if len(generators) > 0 {
+		fmt.Printf("  🎲 Using declarative generators for %d field(s)\n", len(generators))
+	}
+
+	if p.config.Disorder.IsEnabled() {
+		disorder, err := newEventDisorder(p.config.Disorder, schema, p.rng)
+		if err != nil {
+			return fmt.Errorf("invalid event-time disorder: %w", err)
+		}
+		disorder.clock = p.clock
+		p.disorder = disorder
+		fmt.Printf("  🔀 Event-time disorder on %s: %s\n", disorder.field.Name, p.config.Disorder.Summary())
+	}
+
+	if p.config.LatencyField != "" {
+		stamp, err := newLatencyStamp(schema, p.config.LatencyField)
+		if err != nil {
+			return err
+		}
+		p.latencyStamp = stamp
+		fmt.Printf("  ⏱️  Stamping the produce time into %s\n", stamp.field.Name)
+	}
+	return nil
+}
+
+// initializeKeyStrategy sets up message keys and, for AVRO keys, registers the key schema
+func (p *Producer) initializeKeyStrategy(schema *Schema, keySubject string) error {
+	cfg := p.config.KeyStrategy
+	p.keys = newKeyStrategy(cfg, p.rng)
+	fmt.Printf("  🔑 Key strategy: %s\n", cfg.Summary())
+
+	if cfg.Format != KeyFormatAVRO {
+		return nil
+	}
+
+	keySchema, err := NewKeySchema(schema, cfg.Fields)
+	if err != nil {
+		return err
+	}
+	// Register the key schema the keys are encoded with, rather than reusing the latest one of the
+	// subject: the registry returns the existing ID for an identical schema, and rejects one that
+	// is incompatible with the registered key fields
+	schemaObj, err := p.srClient.CreateSchema(keySubject, keySchema.Content, srclient.Avro)
+	if err != nil {
+		return fmt.Errorf("failed to register key schema for subject %s: %w", keySubject, err)
+	}
+	fmt.Printf("  ✅ Using key schema with ID: %d\n", schemaObj.ID())
+
+	codec, err := goavro.NewCodec(keySchema.Content)
+	if err != nil {
+		return fmt.Errorf("failed to create key codec: %w", err)
+	}
+	p.keys.codec = codec
+	p.keys.schemaID = schemaObj.ID()
 	return nil
 }
 
@@ -100,6 +298,13 @@ func (p *Producer) Start(ctx context.Context, topic string, schema *Schema) erro
 		return fmt.Errorf("failed to initialize schema registry: %w", err)
 	}
 
+	atomic.StoreInt64(&p.sendStartNanos, time.Now().UnixNano())
+
+	// Replay recorded data instead of generating synthetic messages
+	if p.config.Replay != nil {
+		return p.startReplay(ctx, schema)
+	}
+
 	// Start the dynamic rate producer
 	if p.config.TrafficPatterns != nil && p.config.TrafficPatterns.HasPatterns() {
 		return p.startWithTrafficPatterns(ctx, schema)
@@ -111,156 +316,289 @@ func (p *Producer) Start(ctx context.Context, topic string, schema *Schema) erro
 
 // startWithTrafficPatterns starts the producer with dynamic traffic patterns
 func (p *Producer) startWithTrafficPatterns(ctx context.Context, schema *Schema) error {
-	startTime := time.Now()
-	messageCount := 0
-	currentRate := p.config.TrafficPatterns.GetRateAt(0)
-	lastLogTime := startTime
+	fmt.Printf("📈 Starting with rate: %d msg/sec\n", p.config.TrafficPatterns.GetRateAt(0))
+	return p.startPaced(ctx, schema, p.config.TrafficPatterns.GetRateAt)
+}
 
-	// Create a ticker that we'll reset when rate changes
-	interval := time.Second / time.Duration(currentRate)
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
+// rateChangeWorthLogging keeps ramps and waves from logging every small rate adjustment
+func rateChangeWorthLogging(last, current int) bool {
+	diff := current - last
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff*10 >= last
+}
 
-	// Track rate changes for logging
-	lastLoggedRate := currentRate
-	nextRateCheck := time.Now().Add(100 * time.Millisecond) // Check rate every 100ms
+// startWithConstantRate starts the producer with a constant message rate
+func (p *Producer) startWithConstantRate(ctx context.Context, schema *Schema) error {
+	return p.startPaced(ctx, schema, func(time.Duration) int { return p.config.MessageRate })
+}
 
-	fmt.Printf("📈 Starting with rate: %d msg/sec\n", currentRate)
+// startReplay reads records from the replay file and sends them until the file is exhausted
+func (p *Producer) startReplay(ctx context.Context, schema *Schema) error {
+	replay := p.config.Replay
+	source, err := OpenReplaySource(replay.Path)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if cerr := source.Close(); cerr != nil {
+			fmt.Printf("⚠️  failed to close replay file: %v\n", cerr)
+		}
+	}()
 
-	for {
-		select {
-		case <-ctx.Done():
-			fmt.Printf("🛑 Producer stopping. Sent %d messages\n", messageCount)
-			return ctx.Err()
-
-		case <-ticker.C:
-			// Check if we need to update the rate
-			elapsed := time.Since(startTime)
-			newRate := p.config.TrafficPatterns.GetRateAt(elapsed)
-
-			if newRate != currentRate {
-				currentRate = newRate
-				interval = time.Second / time.Duration(currentRate)
-				ticker.Reset(interval)
-				fmt.Printf("📊 Rate changed to: %d msg/sec (elapsed: %v)\n", currentRate, elapsed.Truncate(time.Second))
-				lastLoggedRate = currentRate
-			}
+	fmt.Printf("⏯️  Replaying %s (pace: %s)\n", replay.Path, replay.Pace)
 
-			// Generate and send message
-			if err := p.sendMessage(ctx, schema.Name, messageCount); err != nil {
-				fmt.Printf("⚠️  Failed to send message: %v\n", err)
-				continue
-			}
+	var ticker *time.Ticker
+	if replay.Pace == ReplayPaceRate {
+		ticker = time.NewTicker(time.Second / time.Duration(replay.Rate))
+		defer ticker.Stop()
+	}
 
-			messageCount++
-			// Log progress every 5 seconds instead of every 1000 messages
-			now := time.Now()
-			if now.Sub(lastLogTime) >= 5*time.Second {
-				elapsed := now.Sub(startTime)
-				actualRate := float64(messageCount) / elapsed.Seconds()
+	messageCount := 0
+	skipped := 0
+	startTime := time.Now()
+	lastLogTime := startTime
+	var firstEventTime time.Time
 
-				// Update global pipeline status
-				globalPipelineStatus.Producer.MessagesSent = int64(messageCount)
-				globalPipelineStatus.Producer.Rate = actualRate
-				globalPipelineStatus.Producer.Target = currentRate
-				globalPipelineStatus.Producer.Elapsed = elapsed
+	for {
+		record, err := source.Next()
+		if err == io.EOF {
+			fmt.Printf("✅ Replay finished. Sent %d messages (%d skipped)\n", messageCount, skipped)
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read replay file: %w", err)
+		}
 
-				lastLogTime = now
+		// Pace the send
+		switch replay.Pace {
+		case ReplayPaceRate:
+			select {
+			case <-ctx.Done():
+				fmt.Printf("🛑 Producer stopping. Sent %d messages\n", messageCount)
+				return ctx.Err()
+			case <-ticker.C:
 			}
-
-		default:
-			// Periodic rate check (non-blocking)
-			if time.Now().After(nextRateCheck) {
-				elapsed := time.Since(startTime)
-				newRate := p.config.TrafficPatterns.GetRateAt(elapsed)
-
-				if newRate != currentRate {
-					currentRate = newRate
-					interval = time.Second / time.Duration(currentRate)
-					ticker.Reset(interval)
-
-					if newRate != lastLoggedRate {
-						fmt.Printf("📊 Rate changed to: %d msg/sec (elapsed: %v)\n", currentRate, elapsed.Truncate(time.Second))
-						lastLoggedRate = currentRate
-					}
+		case ReplayPaceOriginal:
+			eventTime, err := ReplayEventTime(record, replay.TimestampField)
+			if err != nil {
+				fmt.Printf("⚠️  Skipping record %d: %v\n", messageCount+skipped+1, err)
+				skipped++
+				continue
+			}
+			if firstEventTime.IsZero() {
+				firstEventTime = eventTime
+			}
+			offset := time.Duration(float64(eventTime.Sub(firstEventTime)) / replay.Speed)
+			if wait := time.Until(startTime.Add(offset)); wait > 0 {
+				select {
+				case <-ctx.Done():
+					fmt.Printf("🛑 Producer stopping. Sent %d messages\n", messageCount)
+					return ctx.Err()
+				case <-time.After(wait):
 				}
-
-				nextRateCheck = time.Now().Add(100 * time.Millisecond)
+			}
+		default:
+			if ctx.Err() != nil {
+				fmt.Printf("🛑 Producer stopping. Sent %d messages\n", messageCount)
+				return ctx.Err()
 			}
 		}
-	}
-}
-
-// startWithConstantRate starts the producer with a constant message rate (original behavior)
-func (p *Producer) startWithConstantRate(ctx context.Context, schema *Schema) error {
-	// Calculate message interval
-	interval := time.Second / time.Duration(p.config.MessageRate)
-	ticker := time.NewTicker(interval)
-	defer ticker.Stop()
-
-	messageCount := 0
-	startTime := time.Now()
-	lastLogTime := startTime
 
-	for {
-		select {
-		case <-ctx.Done():
-			fmt.Printf("🛑 Producer stopping. Sent %d messages\n", messageCount)
-			return ctx.Err()
-
-		case <-ticker.C:
-			// Generate and send message
-			if err := p.sendMessage(ctx, schema.Name, messageCount); err != nil {
-				fmt.Printf("⚠️  Failed to send message: %v\n", err)
+		message, err := p.avroValues.ReplayRecord(record, schema)
+		if err != nil {
+			fmt.Printf("⚠️  Skipping record %d: %v\n", messageCount+skipped+1, err)
+			skipped++
+			continue
+		}
+		key := []byte(fmt.Sprintf("key-%d", messageCount))
+		if p.keys != nil {
+			if key, err = p.keys.DeriveKey(message, messageCount); err != nil {
+				fmt.Printf("⚠️  Skipping record %d: %v\n", messageCount+skipped+1, err)
+				skipped++
 				continue
 			}
+		}
+		if err := p.produceMessage(ctx, key, message); err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			fmt.Printf("⚠️  Failed to send message: %v\n", err)
+			continue
+		}
 
-			messageCount++
-			// Log progress every 5 seconds instead of every 1000 messages
-			now := time.Now()
-			if now.Sub(lastLogTime) >= 5*time.Second {
-				elapsed := now.Sub(startTime)
-				actualRate := float64(messageCount) / elapsed.Seconds()
+		messageCount++
+		now := time.Now()
+		if now.Sub(lastLogTime) >= 5*time.Second {
+			elapsed := now.Sub(startTime)
 
-				// Update global pipeline status
-				globalPipelineStatus.Producer.MessagesSent = int64(messageCount)
-				globalPipelineStatus.Producer.Rate = actualRate
-				globalPipelineStatus.Producer.Target = p.config.MessageRate
-				globalPipelineStatus.Producer.Elapsed = elapsed
+			p.updateStatus(messageCount, float64(messageCount)/elapsed.Seconds(), p.config.MessageRate, elapsed)
 
-				lastLogTime = now
-			}
+			lastLogTime = now
 		}
 	}
 }
 
-// sendMessage generates and sends a single message (extracted for reuse)
-func (p *Producer) sendMessage(ctx context.Context, schemaName string, messageCount int) error {
+// updateStatus publishes producer progress to the global pipeline status
+func (p *Producer) updateStatus(messageCount int, rate float64, target int, elapsed time.Duration) {
+	if p.secondary {
+		return
+	}
+	globalPipelineStatus.Producer.MessagesSent = int64(messageCount)
+	globalPipelineStatus.Producer.Rate = rate
+	globalPipelineStatus.Producer.Target = target
+	globalPipelineStatus.Producer.Elapsed = elapsed
+
+	stats := p.GetStats()
+	globalPipelineStatus.Producer.LatencyP50 = stats.LatencyP50
+	globalPipelineStatus.Producer.LatencyP95 = stats.LatencyP95
+	globalPipelineStatus.Producer.LatencyP99 = stats.LatencyP99
+	liveProducerStats.Store(stats)
+}
+
+// nextMessage generates the next message and prepares it for sending
+func (p *Producer) nextMessage(schemaName string, messageCount int) (*outgoingMessage, error) {
 	// Generate message
 	message, err := p.generateMessage(schemaName, messageCount)
 	if err != nil {
-		return fmt.Errorf("failed to generate message: %w", err)
+		return nil, fmt.Errorf("failed to generate message: %w", err)
+	}
+
+	p.applyReferences(message)
+	if p.disorder != nil {
+		p.disorder.Apply(message, messageCount, p.avroValues.WrapFieldValue)
+	}
+	if p.latencyStamp != nil {
+		message[p.latencyStamp.field.Name] = p.avroValues.WrapFieldValue(p.latencyStamp.field, p.latencyStamp.value(time.Now()))
 	}
 
+	// Build the key (may align the message's key fields with the chosen key)
+	key := []byte(fmt.Sprintf("key-%d", messageCount))
+	if p.keys != nil {
+		if key, err = p.keys.AssignKey(message, messageCount); err != nil {
+			return nil, err
+		}
+	}
+
+	return p.prepareMessage(key, message)
+}
+
+// outgoingMessage is an encoded message waiting to be written, with what is needed to account for it
+type outgoingMessage struct {
+	records []kafka.Message        // One record, or two for an injected duplicate
+	message map[string]interface{} // Source values, published to referencing sources once delivered
+	fault   string                 // Injected fault, "" for an intact message
+	valid   bool                   // Still decodes with the registered schema
+	queued  time.Time              // When the message was handed to the writers
+}
+
+// produceMessage encodes a message and writes it to Kafka right away
+func (p *Producer) produceMessage(ctx context.Context, key []byte, message map[string]interface{}) error {
+	out, err := p.prepareMessage(key, message)
+	if err != nil {
+		return err
+	}
+	return p.deliver(ctx, []*outgoingMessage{out})
+}
+
+// prepareMessage encodes a message, turning it into a poison message when a fault is injected
+func (p *Producer) prepareMessage(key []byte, message map[string]interface{}) (*outgoingMessage, error) {
 	// Encode message with AVRO
 	avroData, err := p.encodeMessage(message)
 	if err != nil {
-		return fmt.Errorf("failed to encode message: %w", err)
+		return nil, fmt.Errorf("failed to encode message: %w", err)
 	}
 
-	// Send to Kafka
-	kafkaMsg := kafka.Message{
-		Key:   []byte(fmt.Sprintf("key-%d", messageCount)),
+	out := &outgoingMessage{message: message, valid: true, queued: time.Now()}
+	out.fault = p.faults.pick()
+	if out.fault != "" {
+		if avroData, out.valid, err = p.faults.apply(out.fault, message, avroData); err != nil {
+			return nil, err
+		}
+	}
+
+	out.records = []kafka.Message{{
+		Key:   key,
 		Value: avroData,
+	}}
+	if out.fault == FaultDuplicate {
+		out.records = append(out.records, kafka.Message{Key: key, Value: avroData})
 	}
+	return out, nil
+}
 
-	if err := p.writer.WriteMessages(ctx, kafkaMsg); err != nil {
-		return fmt.Errorf("failed to produce message: %w", err)
+// deliver writes a batch of prepared messages in one call and accounts for each of them.
+// It is safe for concurrent use by the writer pool.
+func (p *Producer) deliver(ctx context.Context, batch []*outgoingMessage) error {
+	var records []kafka.Message
+	for _, out := range batch {
+		records = append(records, out.records...)
+	}
+
+	// Stamp the produce time for end-to-end latency
+	producedAt := []byte(strconv.FormatInt(time.Now().UnixMicro(), 10))
+	for i := range records {
+		records[i].Headers = append(records[i].Headers, kafka.Header{Key: ProducedAtHeader, Value: producedAt})
 	}
 
-	// Increment the message counter
-	p.messageCount++
+	err := p.writer.WriteMessages(ctx, records...)
+	acked := time.Now()
+
+	// A batch can fail partially; kafka.WriteErrors holds one entry per record
+	var writeErrs kafka.WriteErrors
+	partial := errors.As(err, &writeErrs) && len(writeErrs) == len(records)
+
+	index := 0
+	for _, out := range batch {
+		written := err == nil
+		if partial {
+			written = true
+			for i := range out.records {
+				if writeErrs[index+i] != nil {
+					written = false
+				}
+			}
+		}
+		index += len(out.records)
+
+		if out.fault != "" {
+			p.faults.record(out.fault, written)
+		}
+		if !written {
+			atomic.AddInt64(&p.errorCount, int64(len(out.records)))
+			continue
+		}
+		p.latency.observe(acked.Sub(out.queued))
+		if !out.valid {
+			// Poison messages are not expected downstream
+			continue
+		}
+
+		// Increment the message counters
+		atomic.AddInt64(&p.messageCount, int64(len(out.records)))
+		for _, record := range out.records {
+			atomic.AddInt64(&p.bytesSent, int64(len(record.Key)+len(record.Value)))
+		}
+		if start := p.sendStart(); !start.IsZero() {
+			p.rates.record(acked.Sub(start), int(atomic.LoadInt64(&p.targetRate)))
+		}
+
+		if p.ledger != nil {
+			p.ledger.RecordInput(out.message, len(out.records))
+		}
+
+		// Make the sent values available to sources that reference them
+		for field, pool := range p.published {
+			if v := unwrapUnion(out.message[field]); v != nil {
+				pool.add(v)
+			}
+		}
+	}
 
+	if err != nil {
+		return fmt.Errorf("failed to produce message: %w", err)
+	}
 	return nil
 }
 
@@ -284,6 +622,10 @@ func (p *Producer) generateDynamicMessage(messageID int) (map[string]interface{}
 	if p.schema != nil && len(p.schema.Fields) > 0 {
 		// Generate values for each field based on the schema definition
 		for _, field := range p.schema.Fields {
+			if gen, ok := p.generators[field.Name]; ok {
+				message[field.Name] = p.avroValues.WrapFieldValue(field, gen.Generate(messageID))
+				continue
+			}
 			value, err := p.generateValueForField(field, messageID)
 			if err != nil {
 				return nil, fmt.Errorf("failed to generate value for field %s: %w", field.Name, err)
@@ -292,84 +634,21 @@ func (p *Producer) generateDynamicMessage(messageID int) (map[string]interface{}
 		}
 	} else {
 		// Fallback: generate common fields if schema fields are not available
-		message["name"] = fmt.Sprintf("user-%d", rand.Intn(1000))
-		message["amount"] = rand.Intn(10000)
+		message["name"] = fmt.Sprintf("user-%d", p.rng.Intn(1000))
+		message["amount"] = p.rng.Intn(10000)
 	}
 
 	return message, nil
 }
 
-// generateValueForField generates sample data for a specific AVRO field based on its type
+// generateValueForField generates sample data for a specific AVRO field by walking its full type tree:
+// nested and named records, arrays, maps, enums, fixed, unions (in goavro's union encoding) and logical types
 func (p *Producer) generateValueForField(field SchemaField, messageID int) (interface{}, error) {
-	now := time.Now().UnixMilli()
-
-	// Handle different AVRO field types
-	switch fieldType := field.Type.(type) {
-	case string:
-		// Simple types
-		switch fieldType {
-		case "string":
-			return p.generateStringValue(field.Name, messageID), nil
-		case "int":
-			return rand.Intn(10000), nil
-		case "long":
-			return int64(now), nil
-		case "float":
-			return rand.Float32() * 1000, nil
-		case "double":
-			return rand.Float64() * 1000, nil
-		case "boolean":
-			return rand.Intn(2) == 1, nil
-		case "bytes":
-			return []byte(fmt.Sprintf("data-%d", messageID)), nil
-		default:
-			return fmt.Sprintf("value-%d", messageID), nil
-		}
-
-	case []interface{}:
-		// Union types (e.g., ["null", "string"])
-		for _, unionType := range fieldType {
-			if unionTypeStr, ok := unionType.(string); ok && unionTypeStr != "null" {
-				// Use the first non-null type
-				mockField := SchemaField{Name: field.Name, Type: unionTypeStr}
-				return p.generateValueForField(mockField, messageID)
-			}
-		}
-		return nil, nil // Return null for union types we can't handle
-
-	case map[string]interface{}:
-		// Complex types (records, maps, arrays, enums)
-		if typeValue, exists := fieldType["type"]; exists {
-			switch typeValue {
-			case "map":
-				// Generate a simple map
-				return map[string]interface{}{
-					"key1": "value1",
-					"key2": fmt.Sprintf("value-%d", messageID),
-				}, nil
-			case "array":
-				// Generate a simple array
-				return []interface{}{"item1", fmt.Sprintf("item-%d", messageID)}, nil
-			case "enum":
-				// Use first symbol from enum
-				if symbols, exists := fieldType["symbols"]; exists {
-					if symbolList, ok := symbols.([]interface{}); ok && len(symbolList) > 0 {
-						return symbolList[rand.Intn(len(symbolList))], nil
-					}
-				}
-				return "UNKNOWN", nil
-			case "record":
-				// For nested records, return a simple map
-				return map[string]interface{}{
-					"nested_field": fmt.Sprintf("nested-value-%d", messageID),
-				}, nil
-			}
-		}
-		return fmt.Sprintf("complex-value-%d", messageID), nil
-
-	default:
-		return fmt.Sprintf("default-value-%d", messageID), nil
+	if p.avroValues == nil {
+		p.avroValues = newAVROValueGenerator(p.schema, p.rng, p.generateStringValue)
+		p.avroValues.clock = p.clock
 	}
+	return p.avroValues.FieldValue(field, messageID)
 }
 
 // generateStringValue creates appropriate string values based on field name patterns
@@ -378,40 +657,62 @@ func (p *Producer) generateStringValue(fieldName string, messageID int) string {
 	case "id", "event_id", "user_id", "session_id":
 		return fmt.Sprintf("%s-%d", fieldName, messageID)
 	case "name", "username", "user_name":
-		return fmt.Sprintf("user-%d", rand.Intn(1000))
+		return fmt.Sprintf("user-%d", p.rng.Intn(1000))
 	case "email":
-		return fmt.Sprintf("user%d@example.com", rand.Intn(1000))
+		return fmt.Sprintf("user%d@example.com", p.rng.Intn(1000))
 	case "event_type", "type":
 		events := []string{"click", "view", "purchase", "signup", "login"}
-		return events[rand.Intn(len(events))]
+		return events[p.rng.Intn(len(events))]
 	case "url", "page_url":
 		pages := []string{"/home", "/product", "/checkout", "/profile", "/search"}
-		return pages[rand.Intn(len(pages))]
+		return pages[p.rng.Intn(len(pages))]
 	case "status":
 		statuses := []string{"active", "pending", "completed", "failed"}
-		return statuses[rand.Intn(len(statuses))]
+		return statuses[p.rng.Intn(len(statuses))]
 	case "category":
 		categories := []string{"electronics", "clothing", "books", "food", "sports"}
-		return categories[rand.Intn(len(categories))]
+		return categories[p.rng.Intn(len(categories))]
 	case "country", "region":
 		countries := []string{"US", "CA", "GB", "DE", "FR"}
-		return countries[rand.Intn(len(countries))]
+		return countries[p.rng.Intn(len(countries))]
 	default:
 		return fmt.Sprintf("%s-%d", fieldName, messageID)
 	}
 }
 
-// encodeMessage encodes a message using AVRO with Confluent Schema Registry wire format
+// encodeMessage encodes a message in the format of its schema with Confluent Schema Registry wire format
 // or JSON format based on format detection
 func (p *Producer) encodeMessage(message map[string]interface{}) ([]byte, error) {
-	// Use AVRO format if codec is available (Schema Registry initialized)
+	// Use the schema's format if codec is available (Schema Registry initialized)
 	if p.codec != nil {
-		return p.encodeMessageAVRO(message)
+		return p.encodeMessageRegistered(message)
 	}
 	// Fall back to JSON format if no codec available
 	return p.encodeMessageJSON(message)
 }
 
+// encodeMessageRegistered encodes a message in the format of the registered schema
+func (p *Producer) encodeMessageRegistered(message map[string]interface{}) ([]byte, error) {
+	switch p.schema.SerializationFormat() {
+	case SchemaFormatProtobuf:
+		return p.encodeMessageProtobuf(message)
+	case SchemaFormatJSON:
+		// Plain JSON, so the 'json' format of Flink reads it; the schema is registered for the subject
+		return p.encodeMessageJSON(jsonValue(p.schema.jsonRecord, message).(map[string]interface{}))
+	}
+	return p.encodeMessageAVRO(message)
+}
+
+// encodeMessageProtobuf encodes a message as Protobuf in Confluent wire format:
+// magic byte, schema ID, the message indexes of the record message ([0], the first message) and the payload
+func (p *Producer) encodeMessageProtobuf(message map[string]interface{}) ([]byte, error) {
+	payload, err := encodeProto(p.schema.proto, message)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode Protobuf message: %w", err)
+	}
+	return confluentWireFormat(p.schemaID, append(appendMessageIndexes(nil, []int{0}), payload...)), nil
+}
+
 // encodeMessageJSON encodes a message as JSON
 func (p *Producer) encodeMessageJSON(message map[string]interface{}) ([]byte, error) {
 	jsonData, err := json.Marshal(message)
@@ -431,19 +732,7 @@ func (p *Producer) encodeMessageAVRO(message map[string]interface{}) ([]byte, er
 
 	// Create Confluent wire format:
 	// Magic byte (0x00) + Schema ID (4 bytes, big-endian) + Avro data
-	wireFormat := make([]byte, 1+4+len(avroData))
-	wireFormat[0] = 0x00 // Magic byte
-
-	// Schema ID in big-endian format
-	wireFormat[1] = byte(p.schemaID >> 24)
-	wireFormat[2] = byte(p.schemaID >> 16)
-	wireFormat[3] = byte(p.schemaID >> 8)
-	wireFormat[4] = byte(p.schemaID)
-
-	// Copy Avro data
-	copy(wireFormat[5:], avroData)
-
-	return wireFormat, nil
+	return confluentWireFormat(p.schemaID, avroData), nil
 }
 
 // Close gracefully shuts down the producer
@@ -455,26 +744,57 @@ func (p *Producer) Close() {
 
 // ProducerStats holds producer statistics
 type ProducerStats struct {
-	MessagesSent    int64     `json:"messages_sent"`
-	MessagesPerSec  float64   `json:"messages_per_sec"`
-	BytesSent       int64     `json:"bytes_sent"`
-	ErrorCount      int64     `json:"error_count"`
-	LastMessageTime time.Time `json:"last_message_time"`
+	MessagesSent      int64         `json:"messages_sent"`
+	MessagesPerSec    float64       `json:"messages_per_sec"`
+	BytesSent         int64         `json:"bytes_sent"`
+	ErrorCount        int64         `json:"error_count"`
+	LastMessageTime   time.Time     `json:"last_message_time"`
+	PartitionCounts   map[int]int64 `json:"partition_counts,omitempty"` // Delivered messages per partition
+	TargetRate        float64       `json:"target_rate"`                // Average requested msg/sec since sending started
+	AchievedRate      float64       `json:"achieved_rate"`              // Delivered msg/sec since sending started
+	LatencyP50        time.Duration `json:"latency_p50"`                // Produce latency: handed to the writers until acknowledged
+	LatencyP95        time.Duration `json:"latency_p95"`
+	LatencyP99        time.Duration `json:"latency_p99"`
+	AverageLatency    time.Duration `json:"average_latency"`
+	BackpressureWaits int64         `json:"backpressure_waits"` // Batches that waited for a free writer
+}
+
+// RateChart returns the expected and actual send rate of the run, or nil before two full seconds were sent
+func (p *Producer) RateChart() *RateChart {
+	return p.rates.chart()
 }
 
 // GetStats returns current producer statistics
 func (p *Producer) GetStats() *ProducerStats {
+	sent := atomic.LoadInt64(&p.messageCount)
 	elapsed := time.Since(p.startTime)
 	var messagesPerSec float64
 	if elapsed.Seconds() > 0 {
-		messagesPerSec = float64(p.messageCount) / elapsed.Seconds()
+		messagesPerSec = float64(sent) / elapsed.Seconds()
 	}
 
-	return &ProducerStats{
-		MessagesSent:    p.messageCount,
-		MessagesPerSec:  messagesPerSec,
-		BytesSent:       p.messageCount * 1024, // Estimate 1KB per message
-		ErrorCount:      0,                     // TODO: Track actual errors
-		LastMessageTime: time.Now(),
+	p.partitionMu.Lock()
+	partitionCounts := make(map[int]int64, len(p.partitionCounts))
+	for partition, count := range p.partitionCounts {
+		partitionCounts[partition] = count
+	}
+	p.partitionMu.Unlock()
+
+	stats := &ProducerStats{
+		MessagesSent:      sent,
+		MessagesPerSec:    messagesPerSec,
+		BytesSent:         atomic.LoadInt64(&p.bytesSent),
+		ErrorCount:        atomic.LoadInt64(&p.errorCount),
+		LastMessageTime:   time.Now(),
+		PartitionCounts:   partitionCounts,
+		BackpressureWaits: atomic.LoadInt64(&p.backpressureWaits),
+	}
+	if start := p.sendStart(); !start.IsZero() {
+		if sending := time.Since(start).Seconds(); sending > 0 {
+			stats.AchievedRate = float64(sent) / sending
+			stats.TargetRate = float64(atomic.LoadInt64(&p.expectedMilli)) / 1000 / sending
+		}
 	}
+	stats.LatencyP50, stats.LatencyP95, stats.LatencyP99, stats.AverageLatency = p.latency.percentiles()
+	return stats
 }
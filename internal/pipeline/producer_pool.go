@@ -0,0 +1,239 @@
+package pipeline
+
+import (
+	"context"
+	"fmt"
+	"math/rand"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Producer concurrency defaults
+const (
+	DefaultProducerWorkers   = 4   // Concurrent Kafka writers
+	DefaultProducerBatchSize = 500 // Maximum messages per write
+)
+
+// latencyReservoirSize bounds how many produce latencies are kept for percentiles
+const latencyReservoirSize = 10000
+
+// liveProducerStats holds the latest statistics of the primary producer for live displays
+var liveProducerStats atomic.Pointer[ProducerStats]
+
+// LiveProducerStats returns the latest statistics of the running primary producer, or nil before it reports
+func LiveProducerStats() *ProducerStats {
+	return liveProducerStats.Load()
+}
+
+// startPaced generates messages at rateAt(elapsed) msg/sec and hands them in batches to a pool of
+// concurrent writers. A token bucket keeps the long-run rate exact; when every writer is busy the
+// generator waits (back-pressure) instead of queueing without bound.
+func (p *Producer) startPaced(ctx context.Context, schema *Schema, rateAt func(time.Duration) int) error {
+	workers := p.config.ProducerWorkers
+	if workers <= 0 {
+		workers = DefaultProducerWorkers
+	}
+	maxBatch := p.config.ProducerBatchSize
+	if maxBatch <= 0 {
+		maxBatch = DefaultProducerBatchSize
+	}
+
+	batches := make(chan []*outgoingMessage, workers)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for batch := range batches {
+				if err := p.deliver(ctx, batch); err != nil && ctx.Err() == nil {
+					fmt.Printf("⚠️  Failed to send %d message(s): %v\n", len(batch), err)
+				}
+			}
+		}()
+	}
+	stop := func() error {
+		close(batches)
+		wg.Wait()
+		fmt.Printf("🛑 Producer stopping. Sent %d messages\n", atomic.LoadInt64(&p.messageCount))
+		return ctx.Err()
+	}
+
+	startTime := p.sendStart()
+	if startTime.IsZero() {
+		startTime = time.Now()
+	}
+	currentRate := rateAt(0)
+	lastLoggedRate := currentRate
+	bucket := newTokenBucket(float64(currentRate), maxBatch)
+	atomic.StoreInt64(&p.targetRate, int64(currentRate))
+
+	messageID := 0
+	lastPace := startTime
+	lastLogTime := startTime
+	for {
+		now := time.Now()
+		elapsed := now.Sub(startTime)
+
+		// Account for the rate requested since the last batch, then follow the pattern
+		atomic.AddInt64(&p.expectedMilli, int64(float64(currentRate)*now.Sub(lastPace).Seconds()*1000))
+		lastPace = now
+		if rate := rateAt(elapsed); rate != currentRate {
+			currentRate = rate
+			bucket.SetRate(float64(rate))
+			atomic.StoreInt64(&p.targetRate, int64(rate))
+			if rateChangeWorthLogging(lastLoggedRate, rate) {
+				fmt.Printf("📊 Rate changed to: %d msg/sec (elapsed: %v)\n", rate, elapsed.Truncate(time.Second))
+				lastLoggedRate = rate
+			}
+		}
+
+		n, err := bucket.Take(ctx, maxBatch)
+		if err != nil {
+			return stop()
+		}
+
+		batch := make([]*outgoingMessage, 0, n)
+		for i := 0; i < n; i++ {
+			out, err := p.nextMessage(schema.Name, messageID)
+			messageID++
+			if err != nil {
+				fmt.Printf("⚠️  Failed to send message: %v\n", err)
+				continue
+			}
+			batch = append(batch, out)
+		}
+
+		if len(batch) > 0 {
+			select {
+			case batches <- batch:
+			default:
+				// Every writer is busy: wait for one rather than generating ahead of Kafka
+				atomic.AddInt64(&p.backpressureWaits, 1)
+				select {
+				case batches <- batch:
+				case <-ctx.Done():
+					return stop()
+				}
+			}
+		}
+
+		// Log progress every 5 seconds
+		if now.Sub(lastLogTime) >= 5*time.Second {
+			sent := atomic.LoadInt64(&p.messageCount)
+			p.updateStatus(int(sent), float64(sent)/elapsed.Seconds(), currentRate, elapsed)
+			lastLogTime = now
+		}
+	}
+}
+
+// sendStart returns when the producer started sending, or the zero time before that
+func (p *Producer) sendStart() time.Time {
+	nanos := atomic.LoadInt64(&p.sendStartNanos)
+	if nanos == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, nanos)
+}
+
+// tokenBucket paces the generator: tokens accrue at rate per second up to capacity.
+// It is used by a single goroutine.
+type tokenBucket struct {
+	rate     float64
+	capacity float64
+	tokens   float64
+	last     time.Time
+}
+
+func newTokenBucket(rate float64, capacity int) *tokenBucket {
+	if capacity < 1 {
+		capacity = 1
+	}
+	return &tokenBucket{rate: rate, capacity: float64(capacity), last: time.Now()}
+}
+
+func (b *tokenBucket) refill(now time.Time) {
+	b.tokens += b.rate * now.Sub(b.last).Seconds()
+	if b.tokens > b.capacity {
+		b.tokens = b.capacity
+	}
+	b.last = now
+}
+
+// SetRate changes the rate, keeping the tokens accrued at the old rate
+func (b *tokenBucket) SetRate(rate float64) {
+	b.refill(time.Now())
+	b.rate = rate
+}
+
+// Take waits for at least one token and takes up to max whole tokens
+func (b *tokenBucket) Take(ctx context.Context, max int) (int, error) {
+	for {
+		b.refill(time.Now())
+		if b.tokens >= 1 {
+			n := int(b.tokens)
+			if n > max {
+				n = max
+			}
+			b.tokens -= float64(n)
+			return n, nil
+		}
+
+		wait := 100 * time.Millisecond
+		if b.rate > 0 {
+			wait = time.Duration((1 - b.tokens) / b.rate * float64(time.Second))
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return 0, ctx.Err()
+		case <-timer.C:
+		}
+	}
+}
+
+// latencyRecorder keeps a uniform sample of produce latencies (reservoir sampling) for percentiles
+type latencyRecorder struct {
+	mu      sync.Mutex
+	rng     *rand.Rand
+	samples []time.Duration
+	seen    int64
+	total   time.Duration
+}
+
+func newLatencyRecorder() *latencyRecorder {
+	return &latencyRecorder{rng: rand.New(rand.NewSource(time.Now().UnixNano()))}
+}
+
+func (r *latencyRecorder) observe(d time.Duration) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.seen++
+	r.total += d
+	if len(r.samples) < latencyReservoirSize {
+		r.samples = append(r.samples, d)
+		return
+	}
+	if i := r.rng.Int63n(r.seen); i < latencyReservoirSize {
+		r.samples[i] = d
+	}
+}
+
+// percentiles returns p50, p95, p99 and the mean of the observed latencies
+func (r *latencyRecorder) percentiles() (p50, p95, p99, mean time.Duration) {
+	r.mu.Lock()
+	sorted := append([]time.Duration(nil), r.samples...)
+	seen, total := r.seen, r.total
+	r.mu.Unlock()
+
+	if len(sorted) == 0 {
+		return 0, 0, 0, 0
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	at := func(q float64) time.Duration {
+		return sorted[int(q*float64(len(sorted)-1)+0.5)]
+	}
+	return at(0.50), at(0.95), at(0.99), total / time.Duration(seen)
+}
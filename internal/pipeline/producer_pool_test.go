@@ -0,0 +1,95 @@
+package pipeline
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTokenBucketTake(t *testing.T) {
+	bucket := newTokenBucket(1000, 50)
+	bucket.tokens = 120 // Above capacity: refill clamps it
+
+	n, err := bucket.Take(context.Background(), 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n != 10 {
+		t.Errorf("Take(10) = %d, want 10", n)
+	}
+	n, _ = bucket.Take(context.Background(), 100)
+	if n < 39 || n > 41 {
+		t.Errorf("Take(100) = %d, want the remaining ~40 tokens", n)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	idle := newTokenBucket(0, 10)
+	if _, err := idle.Take(ctx, 1); err == nil {
+		t.Error("expected Take to stop when the context is cancelled")
+	}
+}
+
+func TestTokenBucketRate(t *testing.T) {
+	const rate = 2000
+	bucket := newTokenBucket(rate, 100)
+
+	start := time.Now()
+	taken := 0
+	for time.Since(start) < 250*time.Millisecond {
+		n, err := bucket.Take(context.Background(), 100)
+		if err != nil {
+			t.Fatal(err)
+		}
+		taken += n
+	}
+	want := rate * time.Since(start).Seconds()
+	if float64(taken) < want*0.9 || float64(taken) > want*1.1 {
+		t.Errorf("took %d tokens in %v, want ~%.0f", taken, time.Since(start), want)
+	}
+
+	bucket.SetRate(rate / 2)
+	if bucket.rate != rate/2 {
+		t.Errorf("SetRate() left rate at %v", bucket.rate)
+	}
+}
+
+func TestLatencyRecorderPercentiles(t *testing.T) {
+	recorder := newLatencyRecorder()
+	if p50, _, _, _ := recorder.percentiles(); p50 != 0 {
+		t.Errorf("expected no percentiles without samples, got p50 %v", p50)
+	}
+
+	for i := 100; i >= 1; i-- {
+		recorder.observe(time.Duration(i) * time.Millisecond)
+	}
+	p50, p95, p99, mean := recorder.percentiles()
+	tests := []struct {
+		name string
+		got  time.Duration
+		want time.Duration
+	}{
+		{name: "p50", got: p50, want: 51 * time.Millisecond},
+		{name: "p95", got: p95, want: 95 * time.Millisecond},
+		{name: "p99", got: p99, want: 99 * time.Millisecond},
+		{name: "mean", got: mean, want: 50500 * time.Microsecond},
+	}
+	for _, tt := range tests {
+		if tt.got != tt.want {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestLatencyRecorderReservoir(t *testing.T) {
+	recorder := newLatencyRecorder()
+	for i := 0; i < 3*latencyReservoirSize; i++ {
+		recorder.observe(time.Millisecond)
+	}
+	if len(recorder.samples) != latencyReservoirSize {
+		t.Errorf("kept %d samples, want %d", len(recorder.samples), latencyReservoirSize)
+	}
+	if _, _, p99, _ := recorder.percentiles(); p99 != time.Millisecond {
+		t.Errorf("p99 = %v, want 1ms", p99)
+	}
+}
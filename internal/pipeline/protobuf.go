@@ -0,0 +1,1040 @@
+package pipeline
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// protoTimestamp is the well-known type mapped to AVRO's timestamp-millis
+const protoTimestamp = "google.protobuf.Timestamp"
+
+// protoScalars maps the Protobuf scalar types to the AVRO types their values are generated and decoded as
+var protoScalars = map[string]string{
+	"double": "double", "float": "float",
+	"int32": "int", "sint32": "int", "sfixed32": "int",
+	"uint32": "long", "fixed32": "long",
+	"int64": "long", "sint64": "long", "sfixed64": "long", "uint64": "long", "fixed64": "long",
+	"bool": "boolean", "string": "string", "bytes": "bytes",
+}
+
+// protoFile is a parsed .proto definition. Only what pipegen needs to encode and decode records is kept:
+// messages, enums and their fields. Services, options and extensions are skipped.
+type protoFile struct {
+	pkg      string
+	messages []*protoMessage       // Top-level messages in declaration order, as Confluent message indexes count them
+	types    map[string]protoNamed // Messages and enums by full name
+}
+
+// protoNamed is a message or an enum
+type protoNamed struct {
+	message *protoMessage
+	enum    *protoEnum
+}
+
+type protoMessage struct {
+	name     string
+	fullName string
+	fields   []*protoField
+	nested   []*protoMessage
+}
+
+type protoField struct {
+	name     string
+	number   int
+	label    string // "", "optional", "required" or "repeated"
+	typeName string // Type as written; the value type of map fields
+	mapKey   string // Key type of map fields
+	scope    string // Full name of the declaring message, for type resolution
+	message  *protoMessage
+	enum     *protoEnum
+}
+
+type protoEnum struct {
+	name     string
+	fullName string
+	values   []protoEnumValue
+}
+
+type protoEnumValue struct {
+	name   string
+	number int32
+}
+
+// nullable reports whether the field has explicit presence: proto3 optional fields and singular messages
+func (f *protoField) nullable() bool {
+	return f.label == "optional" || (f.label == "" && f.mapKey == "" && (f.message != nil || f.typeName == protoTimestamp))
+}
+
+// parseProtoSchema parses a .proto definition and resolves the types of its fields
+func parseProtoSchema(src string) (*protoFile, error) {
+	tokens, err := tokenizeProto(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &protoParser{tokens: tokens, file: &protoFile{types: make(map[string]protoNamed)}}
+	if err := p.parseFile(); err != nil {
+		return nil, err
+	}
+	if len(p.file.messages) == 0 {
+		return nil, fmt.Errorf("no message definition found")
+	}
+	for _, m := range p.file.messages {
+		if err := p.file.resolve(m); err != nil {
+			return nil, err
+		}
+	}
+	return p.file, nil
+}
+
+// messageAt returns the message a Confluent message index path points to
+func (f *protoFile) messageAt(indexes []int) (*protoMessage, error) {
+	candidates := f.messages
+	var m *protoMessage
+	for _, i := range indexes {
+		if i < 0 || i >= len(candidates) {
+			return nil, fmt.Errorf("message index %v not found in schema", indexes)
+		}
+		m = candidates[i]
+		candidates = m.nested
+	}
+	if m == nil {
+		return nil, fmt.Errorf("empty message index")
+	}
+	return m, nil
+}
+
+// resolve links the fields of a message (and its nested messages) to the messages and enums they use
+func (f *protoFile) resolve(m *protoMessage) error {
+	for _, field := range m.fields {
+		if field.mapKey != "" && field.mapKey != "string" {
+			return fmt.Errorf("field %s.%s: map<%s, ...> is not supported, map keys must be strings", m.name, field.name, field.mapKey)
+		}
+		if _, ok := protoScalars[field.typeName]; ok {
+			continue
+		}
+		named, ok := f.lookup(field.typeName, field.scope)
+		switch {
+		case ok:
+			field.message, field.enum = named.message, named.enum
+		case strings.TrimPrefix(field.typeName, ".") == protoTimestamp:
+			field.typeName = protoTimestamp
+		default:
+			return fmt.Errorf("field %s.%s: unknown type %s", m.name, field.name, field.typeName)
+		}
+	}
+	for _, nested := range m.nested {
+		if err := f.resolve(nested); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lookup resolves a type name the way protoc does: from the innermost scope outwards
+func (f *protoFile) lookup(name, scope string) (protoNamed, bool) {
+	if strings.HasPrefix(name, ".") {
+		named, ok := f.types[name[1:]]
+		return named, ok
+	}
+	for {
+		candidate := name
+		if scope != "" {
+			candidate = scope + "." + name
+		}
+		if named, ok := f.types[candidate]; ok {
+			return named, true
+		}
+		if scope == "" {
+			return protoNamed{}, false
+		}
+		if idx := strings.LastIndex(scope, "."); idx >= 0 {
+			scope = scope[:idx]
+		} else {
+			scope = ""
+		}
+	}
+}
+
+// tokenizeProto splits a .proto definition into identifiers, numbers, strings and symbols, dropping comments
+func tokenizeProto(src string) ([]string, error) {
+	var tokens []string
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated comment")
+			}
+			i += end + 4
+		case c == '"' || c == '\'':
+			j := i + 1
+			for j < len(src) && src[j] != c {
+				if src[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("unterminated string")
+			}
+			tokens = append(tokens, src[i:j+1])
+			i = j + 1
+		case c == '_' || c == '.' || c == '-' || c == '+' || unicode.IsLetter(rune(c)) || unicode.IsDigit(rune(c)):
+			j := i + 1
+			for j < len(src) && (src[j] == '_' || src[j] == '.' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			tokens = append(tokens, src[i:j])
+			i = j
+		default:
+			tokens = append(tokens, string(c))
+			i++
+		}
+	}
+	return tokens, nil
+}
+
+type protoParser struct {
+	tokens []string
+	pos    int
+	file   *protoFile
+}
+
+func (p *protoParser) peek() string {
+	if p.pos < len(p.tokens) {
+		return p.tokens[p.pos]
+	}
+	return ""
+}
+
+func (p *protoParser) next() string {
+	tok := p.peek()
+	p.pos++
+	return tok
+}
+
+func (p *protoParser) expect(want string) error {
+	if tok := p.next(); tok != want {
+		if tok == "" {
+			return fmt.Errorf("expected %q, got end of file", want)
+		}
+		return fmt.Errorf("expected %q, got %q", want, tok)
+	}
+	return nil
+}
+
+// skipStatement skips up to the end of a statement, including any aggregate option values in braces
+func (p *protoParser) skipStatement() error {
+	depth := 0
+	for {
+		switch p.next() {
+		case "":
+			return fmt.Errorf("unexpected end of file")
+		case "{":
+			depth++
+		case "}":
+			depth--
+			if depth == 0 {
+				return nil
+			}
+		case ";":
+			if depth == 0 {
+				return nil
+			}
+		}
+	}
+}
+
+func (p *protoParser) parseFile() error {
+	for p.peek() != "" {
+		switch tok := p.next(); tok {
+		case "syntax":
+			if err := p.expect("="); err != nil {
+				return err
+			}
+			if syntax := strings.Trim(p.next(), `"'`); syntax != "proto3" && syntax != "proto2" {
+				return fmt.Errorf("unsupported syntax %q", syntax)
+			}
+			if err := p.expect(";"); err != nil {
+				return err
+			}
+		case "package":
+			p.file.pkg = p.next()
+			if err := p.expect(";"); err != nil {
+				return err
+			}
+		case "message":
+			m, err := p.parseMessage(p.file.pkg)
+			if err != nil {
+				return err
+			}
+			p.file.messages = append(p.file.messages, m)
+		case "enum":
+			if _, err := p.parseEnum(p.file.pkg); err != nil {
+				return err
+			}
+		case "import", "option", "service", "extend":
+			if err := p.skipStatement(); err != nil {
+				return err
+			}
+		case ";":
+		default:
+			return fmt.Errorf("unexpected %q", tok)
+		}
+	}
+	return nil
+}
+
+func qualify(scope, name string) string {
+	if scope == "" {
+		return name
+	}
+	return scope + "." + name
+}
+
+func (p *protoParser) parseMessage(scope string) (*protoMessage, error) {
+	name := p.next()
+	m := &protoMessage{name: name, fullName: qualify(scope, name)}
+	p.file.types[m.fullName] = protoNamed{message: m}
+	if err := p.expect("{"); err != nil {
+		return nil, fmt.Errorf("message %s: %w", name, err)
+	}
+	for {
+		switch tok := p.peek(); tok {
+		case "}":
+			p.next()
+			return m, nil
+		case "":
+			return nil, fmt.Errorf("message %s: unexpected end of file", name)
+		case ";":
+			p.next()
+		case "message":
+			p.next()
+			nested, err := p.parseMessage(m.fullName)
+			if err != nil {
+				return nil, err
+			}
+			m.nested = append(m.nested, nested)
+		case "enum":
+			p.next()
+			if _, err := p.parseEnum(m.fullName); err != nil {
+				return nil, err
+			}
+		case "option", "reserved", "extensions", "extend":
+			if err := p.skipStatement(); err != nil {
+				return nil, err
+			}
+		case "oneof", "group":
+			return nil, fmt.Errorf("message %s: %s is not supported", name, tok)
+		default:
+			field, err := p.parseField(m.fullName)
+			if err != nil {
+				return nil, fmt.Errorf("message %s: %w", name, err)
+			}
+			m.fields = append(m.fields, field)
+		}
+	}
+}
+
+func (p *protoParser) parseField(scope string) (*protoField, error) {
+	field := &protoField{scope: scope}
+	switch p.peek() {
+	case "optional", "required", "repeated":
+		field.label = p.next()
+	}
+	if p.peek() == "map" {
+		p.next()
+		if err := p.expect("<"); err != nil {
+			return nil, err
+		}
+		field.mapKey = p.next()
+		if err := p.expect(","); err != nil {
+			return nil, err
+		}
+		field.typeName = p.next()
+		if err := p.expect(">"); err != nil {
+			return nil, err
+		}
+	} else {
+		field.typeName = p.next()
+	}
+	field.name = p.next()
+	if err := p.expect("="); err != nil {
+		return nil, fmt.Errorf("field %s: %w", field.name, err)
+	}
+	number, err := strconv.Atoi(p.next())
+	if err != nil || number <= 0 {
+		return nil, fmt.Errorf("field %s: invalid field number", field.name)
+	}
+	field.number = number
+	if p.peek() == "[" {
+		for p.peek() != "]" && p.peek() != "" {
+			p.next()
+		}
+		p.next()
+	}
+	if err := p.expect(";"); err != nil {
+		return nil, fmt.Errorf("field %s: %w", field.name, err)
+	}
+	return field, nil
+}
+
+func (p *protoParser) parseEnum(scope string) (*protoEnum, error) {
+	name := p.next()
+	e := &protoEnum{name: name, fullName: qualify(scope, name)}
+	p.file.types[e.fullName] = protoNamed{enum: e}
+	if err := p.expect("{"); err != nil {
+		return nil, fmt.Errorf("enum %s: %w", name, err)
+	}
+	for {
+		switch tok := p.next(); tok {
+		case "}":
+			if len(e.values) == 0 {
+				return nil, fmt.Errorf("enum %s has no values", name)
+			}
+			return e, nil
+		case "":
+			return nil, fmt.Errorf("enum %s: unexpected end of file", name)
+		case ";":
+		case "option", "reserved":
+			p.pos--
+			if err := p.skipStatement(); err != nil {
+				return nil, err
+			}
+		default:
+			if err := p.expect("="); err != nil {
+				return nil, fmt.Errorf("enum %s: %w", name, err)
+			}
+			number, err := strconv.ParseInt(p.next(), 0, 32)
+			if err != nil {
+				return nil, fmt.Errorf("enum %s: invalid number for %s", name, tok)
+			}
+			if p.peek() == "[" {
+				for p.peek() != "]" && p.peek() != "" {
+					p.next()
+				}
+				p.next()
+			}
+			if err := p.expect(";"); err != nil {
+				return nil, fmt.Errorf("enum %s: %w", name, err)
+			}
+			e.values = append(e.values, protoEnumValue{name: tok, number: int32(number)})
+		}
+	}
+}
+
+// avroRecord converts a message to the equivalent AVRO record. Messages already defined are referenced
+// by full name, which also ends recursion; singular messages and optional fields become nullable unions.
+func (m *protoMessage) avroRecord(defined map[string]bool) interface{} {
+	if defined[m.fullName] {
+		return m.fullName
+	}
+	defined[m.fullName] = true
+	fields := make([]interface{}, 0, len(m.fields))
+	for _, f := range m.fields {
+		fieldType := f.avroValueType(defined)
+		switch {
+		case f.mapKey != "":
+			fieldType = map[string]interface{}{"type": "map", "values": fieldType}
+		case f.label == "repeated":
+			fieldType = map[string]interface{}{"type": "array", "items": fieldType}
+		case f.nullable():
+			fieldType = []interface{}{"null", fieldType}
+		}
+		fields = append(fields, map[string]interface{}{"name": f.name, "type": fieldType})
+	}
+	return map[string]interface{}{"type": "record", "name": m.fullName, "fields": fields}
+}
+
+// avroValueType is the AVRO type of a single value of the field
+func (f *protoField) avroValueType(defined map[string]bool) interface{} {
+	switch {
+	case f.message != nil:
+		return f.message.avroRecord(defined)
+	case f.enum != nil:
+		if defined[f.enum.fullName] {
+			return f.enum.fullName
+		}
+		defined[f.enum.fullName] = true
+		symbols := make([]string, len(f.enum.values))
+		for i, v := range f.enum.values {
+			symbols[i] = v.name
+		}
+		return map[string]interface{}{"type": "enum", "name": f.enum.fullName, "symbols": symbols}
+	case f.typeName == protoTimestamp:
+		return map[string]interface{}{"type": "long", "logicalType": "timestamp-millis"}
+	}
+	return protoScalars[f.typeName]
+}
+
+// appendMessageIndexes writes the Confluent message index path that follows the schema ID:
+// a zigzag varint count and the zigzag varint indexes, with the common [0] written as a single 0
+func appendMessageIndexes(buf []byte, indexes []int) []byte {
+	if len(indexes) == 1 && indexes[0] == 0 {
+		return append(buf, 0)
+	}
+	buf = binary.AppendVarint(buf, int64(len(indexes)))
+	for _, i := range indexes {
+		buf = binary.AppendVarint(buf, int64(i))
+	}
+	return buf
+}
+
+// readMessageIndexes reads the message index path of a Confluent Protobuf payload and returns the rest
+func readMessageIndexes(data []byte) ([]int, []byte, error) {
+	count, n := binary.Varint(data)
+	if n <= 0 || count < 0 || count > 64 {
+		return nil, nil, fmt.Errorf("invalid Protobuf message indexes")
+	}
+	data = data[n:]
+	if count == 0 {
+		return []int{0}, data, nil
+	}
+	indexes := make([]int, count)
+	for i := range indexes {
+		index, n := binary.Varint(data)
+		if n <= 0 {
+			return nil, nil, fmt.Errorf("invalid Protobuf message indexes")
+		}
+		indexes[i] = int(index)
+		data = data[n:]
+	}
+	return indexes, data, nil
+}
+
+// encodeProto encodes a record in goavro's native form as a Protobuf message. Zero values of fields
+// without explicit presence are left out, as proto3 encoders do.
+func encodeProto(m *protoMessage, record map[string]interface{}) ([]byte, error) {
+	var buf []byte
+	for _, f := range m.fields {
+		v, ok := record[f.name]
+		if !ok || v == nil {
+			continue
+		}
+		var err error
+		switch {
+		case f.mapKey != "":
+			buf, err = f.appendMap(buf, v)
+		case f.label == "repeated":
+			buf, err = f.appendRepeated(buf, v)
+		default:
+			if f.nullable() {
+				if v = f.unwrap(v); v == nil {
+					continue
+				}
+			}
+			buf, err = f.appendValue(buf, v, !f.nullable() && f.label != "required")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", f.name, err)
+		}
+	}
+	return buf, nil
+}
+
+// unwrap takes a value out of its goavro union. Messages are only unwrapped from a union named after them,
+// so a message with a single field is not mistaken for a union.
+func (f *protoField) unwrap(v interface{}) interface{} {
+	wrapped, ok := v.(map[string]interface{})
+	if !ok || len(wrapped) != 1 {
+		return v
+	}
+	for branch, inner := range wrapped {
+		if f.message == nil || branch == f.message.fullName {
+			return inner
+		}
+	}
+	return v
+}
+
+func (f *protoField) appendRepeated(buf []byte, v interface{}) ([]byte, error) {
+	items, ok := v.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a list, got %T", v)
+	}
+	if f.packable() && len(items) > 0 {
+		var packed []byte
+		for _, item := range items {
+			var err error
+			if packed, err = f.appendScalar(packed, item); err != nil {
+				return nil, err
+			}
+		}
+		buf = protowireTag(buf, f.number, 2)
+		buf = binary.AppendUvarint(buf, uint64(len(packed)))
+		return append(buf, packed...), nil
+	}
+	for _, item := range items {
+		var err error
+		if buf, err = f.appendValue(buf, item, false); err != nil {
+			return nil, err
+		}
+	}
+	return buf, nil
+}
+
+func (f *protoField) appendMap(buf []byte, v interface{}) ([]byte, error) {
+	entries, ok := v.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("expected a map, got %T", v)
+	}
+	keys := make([]string, 0, len(entries))
+	for k := range entries {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	valueField := &protoField{number: 2, typeName: f.typeName, message: f.message, enum: f.enum}
+	for _, k := range keys {
+		entry := protowireTag(nil, 1, 2)
+		entry = binary.AppendUvarint(entry, uint64(len(k)))
+		entry = append(entry, k...)
+		entry, err := valueField.appendValue(entry, entries[k], false)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %w", k, err)
+		}
+		buf = protowireTag(buf, f.number, 2)
+		buf = binary.AppendUvarint(buf, uint64(len(entry)))
+		buf = append(buf, entry...)
+	}
+	return buf, nil
+}
+
+// packable reports whether repeated values of the field are written packed: every numeric scalar and enum
+func (f *protoField) packable() bool {
+	if f.enum != nil {
+		return true
+	}
+	switch f.typeName {
+	case "string", "bytes", protoTimestamp:
+		return false
+	}
+	return f.message == nil
+}
+
+// appendValue writes one tagged value; skipZero leaves out zero scalars of fields without presence
+func (f *protoField) appendValue(buf []byte, v interface{}, skipZero bool) ([]byte, error) {
+	switch {
+	case f.message != nil:
+		record, ok := v.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("expected a record, got %T", v)
+		}
+		payload, err := encodeProto(f.message, record)
+		if err != nil {
+			return nil, err
+		}
+		buf = protowireTag(buf, f.number, 2)
+		buf = binary.AppendUvarint(buf, uint64(len(payload)))
+		return append(buf, payload...), nil
+	case f.typeName == protoTimestamp:
+		payload, err := appendProtoTimestamp(nil, v)
+		if err != nil {
+			return nil, err
+		}
+		buf = protowireTag(buf, f.number, 2)
+		buf = binary.AppendUvarint(buf, uint64(len(payload)))
+		return append(buf, payload...), nil
+	}
+
+	scalar, err := f.appendScalar(nil, v)
+	if err != nil {
+		return nil, err
+	}
+	if skipZero && isProtoZero(f, scalar) {
+		return buf, nil
+	}
+	buf = protowireTag(buf, f.number, f.wireType())
+	return append(buf, scalar...), nil
+}
+
+// isProtoZero reports whether an encoded scalar is the type's zero value, which proto3 does not write
+func isProtoZero(f *protoField, scalar []byte) bool {
+	switch f.wireType() {
+	case 2:
+		return len(scalar) == 1 && scalar[0] == 0 // Empty string or bytes: a zero length prefix
+	case 0:
+		return len(scalar) == 1 && scalar[0] == 0
+	}
+	for _, b := range scalar {
+		if b != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// wireType is the Protobuf wire type of a single value of the field
+func (f *protoField) wireType() int {
+	if f.message != nil || f.typeName == protoTimestamp {
+		return 2
+	}
+	if f.enum != nil {
+		return 0
+	}
+	switch f.typeName {
+	case "double", "fixed64", "sfixed64":
+		return 1
+	case "float", "fixed32", "sfixed32":
+		return 5
+	case "string", "bytes":
+		return 2
+	}
+	return 0
+}
+
+// appendScalar writes a scalar or enum value without its tag; strings and bytes get their length prefix
+func (f *protoField) appendScalar(buf []byte, v interface{}) ([]byte, error) {
+	if f.enum != nil {
+		if symbol, ok := v.(string); ok {
+			for _, value := range f.enum.values {
+				if value.name == symbol {
+					return binary.AppendUvarint(buf, uint64(int64(value.number))), nil
+				}
+			}
+			return nil, fmt.Errorf("unknown %s value %q", f.enum.name, symbol)
+		}
+		n, err := toInt64(v)
+		if err != nil {
+			return nil, err
+		}
+		return binary.AppendUvarint(buf, uint64(n)), nil
+	}
+
+	switch f.typeName {
+	case "string", "bytes":
+		var raw []byte
+		switch t := v.(type) {
+		case string:
+			raw = []byte(t)
+		case []byte:
+			raw = t
+		default:
+			return nil, fmt.Errorf("expected %s, got %T", f.typeName, v)
+		}
+		buf = binary.AppendUvarint(buf, uint64(len(raw)))
+		return append(buf, raw...), nil
+	case "bool":
+		b, ok := v.(bool)
+		if !ok {
+			return nil, fmt.Errorf("expected a boolean, got %T", v)
+		}
+		if b {
+			return append(buf, 1), nil
+		}
+		return append(buf, 0), nil
+	case "float", "double":
+		x, err := toFloat64(v)
+		if err != nil {
+			return nil, err
+		}
+		if f.typeName == "float" {
+			return binary.LittleEndian.AppendUint32(buf, math.Float32bits(float32(x))), nil
+		}
+		return binary.LittleEndian.AppendUint64(buf, math.Float64bits(x)), nil
+	}
+
+	n, err := toInt64(v)
+	if err != nil {
+		return nil, err
+	}
+	switch f.typeName {
+	case "sint32", "sint64":
+		return binary.AppendVarint(buf, n), nil
+	case "fixed32", "sfixed32":
+		return binary.LittleEndian.AppendUint32(buf, uint32(n)), nil
+	case "fixed64", "sfixed64":
+		return binary.LittleEndian.AppendUint64(buf, uint64(n)), nil
+	}
+	// int32, int64, uint32 and uint64; negative int32 values are sign-extended to ten bytes
+	return binary.AppendUvarint(buf, uint64(n)), nil
+}
+
+// appendProtoTimestamp encodes a time (or epoch milliseconds) as a google.protobuf.Timestamp message
+func appendProtoTimestamp(buf []byte, v interface{}) ([]byte, error) {
+	t, ok := v.(time.Time)
+	if !ok {
+		millis, err := toInt64(v)
+		if err != nil {
+			return nil, err
+		}
+		t = time.UnixMilli(millis)
+	}
+	if seconds := t.Unix(); seconds != 0 {
+		buf = protowireTag(buf, 1, 0)
+		buf = binary.AppendUvarint(buf, uint64(seconds))
+	}
+	if nanos := t.Nanosecond(); nanos != 0 {
+		buf = protowireTag(buf, 2, 0)
+		buf = binary.AppendUvarint(buf, uint64(nanos))
+	}
+	return buf, nil
+}
+
+func protowireTag(buf []byte, number, wireType int) []byte {
+	return binary.AppendUvarint(buf, uint64(number)<<3|uint64(wireType))
+}
+
+// decodeProto decodes a Protobuf message into a record shaped like its AVRO equivalent: every field is
+// present, with proto3 defaults for unset scalars and nil for unset optional fields and messages
+func decodeProto(m *protoMessage, data []byte) (map[string]interface{}, error) {
+	byNumber := make(map[int]*protoField, len(m.fields))
+	record := make(map[string]interface{}, len(m.fields))
+	for _, f := range m.fields {
+		byNumber[f.number] = f
+		switch {
+		case f.mapKey != "":
+			record[f.name] = map[string]interface{}{}
+		case f.label == "repeated":
+			record[f.name] = []interface{}{}
+		case f.nullable():
+			record[f.name] = nil
+		default:
+			record[f.name] = f.zero()
+		}
+	}
+
+	for len(data) > 0 {
+		tag, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, fmt.Errorf("invalid field tag")
+		}
+		data = data[n:]
+		number, wireType := int(tag>>3), int(tag&7)
+
+		raw, rest, err := readProtoValue(data, wireType)
+		if err != nil {
+			return nil, fmt.Errorf("field %d: %w", number, err)
+		}
+		data = rest
+		f := byNumber[number]
+		if f == nil {
+			continue // Unknown fields are skipped
+		}
+
+		switch {
+		case f.mapKey != "":
+			key, value, err := f.decodeMapEntry(raw)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", f.name, err)
+			}
+			record[f.name].(map[string]interface{})[key] = value
+		case f.label == "repeated":
+			items := record[f.name].([]interface{})
+			if wireType == 2 && f.packable() {
+				for len(raw) > 0 {
+					item, n, err := f.decodeScalar(raw, f.wireType())
+					if err != nil {
+						return nil, fmt.Errorf("field %s: %w", f.name, err)
+					}
+					items = append(items, item)
+					raw = raw[n:]
+				}
+			} else {
+				item, err := f.decodeValue(raw, wireType)
+				if err != nil {
+					return nil, fmt.Errorf("field %s: %w", f.name, err)
+				}
+				items = append(items, item)
+			}
+			record[f.name] = items
+		default:
+			value, err := f.decodeValue(raw, wireType)
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", f.name, err)
+			}
+			record[f.name] = value
+		}
+	}
+	return record, nil
+}
+
+// readProtoValue splits the raw bytes of one value of the given wire type from the rest of the message
+func readProtoValue(data []byte, wireType int) ([]byte, []byte, error) {
+	switch wireType {
+	case 0:
+		_, n := binary.Uvarint(data)
+		if n <= 0 {
+			return nil, nil, fmt.Errorf("invalid varint")
+		}
+		return data[:n], data[n:], nil
+	case 1:
+		if len(data) < 8 {
+			return nil, nil, fmt.Errorf("truncated 64-bit value")
+		}
+		return data[:8], data[8:], nil
+	case 2:
+		length, n := binary.Uvarint(data)
+		if n <= 0 || uint64(len(data)-n) < length {
+			return nil, nil, fmt.Errorf("truncated length-delimited value")
+		}
+		end := n + int(length)
+		return data[n:end], data[end:], nil
+	case 5:
+		if len(data) < 4 {
+			return nil, nil, fmt.Errorf("truncated 32-bit value")
+		}
+		return data[:4], data[4:], nil
+	}
+	return nil, nil, fmt.Errorf("unsupported wire type %d", wireType)
+}
+
+// decodeValue decodes one value of the field from its raw bytes
+func (f *protoField) decodeValue(raw []byte, wireType int) (interface{}, error) {
+	switch {
+	case f.message != nil:
+		return decodeProto(f.message, raw)
+	case f.typeName == protoTimestamp:
+		return decodeProtoTimestamp(raw)
+	case f.typeName == "string":
+		return string(raw), nil
+	case f.typeName == "bytes":
+		return append([]byte(nil), raw...), nil
+	}
+	v, _, err := f.decodeScalar(raw, wireType)
+	return v, err
+}
+
+// decodeScalar decodes a numeric, boolean or enum value and returns the number of bytes read
+func (f *protoField) decodeScalar(raw []byte, wireType int) (interface{}, int, error) {
+	var bits uint64
+	var n int
+	switch wireType {
+	case 0:
+		if bits, n = binary.Uvarint(raw); n <= 0 {
+			return nil, 0, fmt.Errorf("invalid varint")
+		}
+	case 1:
+		if len(raw) < 8 {
+			return nil, 0, fmt.Errorf("truncated 64-bit value")
+		}
+		bits, n = binary.LittleEndian.Uint64(raw), 8
+	case 5:
+		if len(raw) < 4 {
+			return nil, 0, fmt.Errorf("truncated 32-bit value")
+		}
+		bits, n = uint64(binary.LittleEndian.Uint32(raw)), 4
+	default:
+		return nil, 0, fmt.Errorf("unexpected wire type %d", wireType)
+	}
+
+	if f.enum != nil {
+		for _, value := range f.enum.values {
+			if int64(value.number) == int64(int32(bits)) {
+				return value.name, n, nil
+			}
+		}
+		return strconv.FormatInt(int64(int32(bits)), 10), n, nil
+	}
+	switch f.typeName {
+	case "bool":
+		return bits != 0, n, nil
+	case "float":
+		return math.Float32frombits(uint32(bits)), n, nil
+	case "double":
+		return math.Float64frombits(bits), n, nil
+	case "int32", "sfixed32":
+		return int32(bits), n, nil
+	case "sint32":
+		return int32(int64(bits>>1) ^ -int64(bits&1)), n, nil
+	case "sint64":
+		return int64(bits>>1) ^ -int64(bits&1), n, nil
+	}
+	// uint32, fixed32, int64, uint64, fixed64 and sfixed64 are all longs in AVRO
+	return int64(bits), n, nil
+}
+
+func (f *protoField) decodeMapEntry(raw []byte) (string, interface{}, error) {
+	valueField := &protoField{typeName: f.typeName, message: f.message, enum: f.enum}
+	key := ""
+	value := valueField.zero()
+	for len(raw) > 0 {
+		tag, n := binary.Uvarint(raw)
+		if n <= 0 {
+			return "", nil, fmt.Errorf("invalid map entry")
+		}
+		item, rest, err := readProtoValue(raw[n:], int(tag&7))
+		if err != nil {
+			return "", nil, err
+		}
+		raw = rest
+		switch tag >> 3 {
+		case 1:
+			key = string(item)
+		case 2:
+			if value, err = valueField.decodeValue(item, int(tag&7)); err != nil {
+				return "", nil, err
+			}
+		}
+	}
+	return key, value, nil
+}
+
+// zero is the proto3 default of a value of the field
+func (f *protoField) zero() interface{} {
+	switch {
+	case f.message != nil:
+		record, _ := decodeProto(f.message, nil)
+		return record
+	case f.enum != nil:
+		for _, value := range f.enum.values {
+			if value.number == 0 {
+				return value.name
+			}
+		}
+		return f.enum.values[0].name
+	case f.typeName == protoTimestamp:
+		return time.Unix(0, 0).UTC()
+	}
+	switch protoScalars[f.typeName] {
+	case "string":
+		return ""
+	case "bytes":
+		return []byte{}
+	case "boolean":
+		return false
+	case "int":
+		return int32(0)
+	case "float":
+		return float32(0)
+	case "double":
+		return float64(0)
+	}
+	return int64(0)
+}
+
+func decodeProtoTimestamp(raw []byte) (time.Time, error) {
+	var seconds, nanos int64
+	for len(raw) > 0 {
+		tag, n := binary.Uvarint(raw)
+		if n <= 0 {
+			return time.Time{}, fmt.Errorf("invalid timestamp")
+		}
+		value, rest, err := readProtoValue(raw[n:], int(tag&7))
+		if err != nil {
+			return time.Time{}, err
+		}
+		raw = rest
+		v, _ := binary.Uvarint(value)
+		switch tag >> 3 {
+		case 1:
+			seconds = int64(v)
+		case 2:
+			nanos = int64(int32(v))
+		}
+	}
+	return time.Unix(seconds, nanos).UTC(), nil
+}
@@ -0,0 +1,221 @@
+package pipeline
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+const protobufTestSchema = `syntax = "proto3";
+
+package shop;
+
+import "google/protobuf/timestamp.proto";
+
+option java_package = "com.example.shop";
+
+// An order placed in the shop
+message Order {
+  string order_id = 1;
+  int64 amount_cents = 2 [deprecated = true];
+  Status status = 3;
+  repeated Item items = 4;
+  map<string, string> tags = 5;
+  optional string note = 6;
+  google.protobuf.Timestamp created_at = 7;
+  repeated int32 scores = 8;
+  sint32 delta = 9;
+  double ratio = 10;
+
+  message Item {
+    string sku = 1;
+    int32 quantity = 2;
+  }
+
+  /* Declared after use, like protoc allows */
+  enum Status {
+    STATUS_UNKNOWN = 0;
+    PAID = 1;
+    SHIPPED = 2;
+  }
+}
+
+message Refund {
+  string order_id = 1;
+}
+
+service Orders {
+  rpc Get (Order) returns (Order);
+}
+`
+
+func TestParseProtobufSchema(t *testing.T) {
+	schema, err := ParseSchema([]byte(protobufTestSchema), SchemaFormatProtobuf, "input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if schema.Name != "Order" || schema.Namespace != "shop" || schema.RegistryType() != "PROTOBUF" || schema.RegistryDefinition() != protobufTestSchema {
+		t.Fatalf("unexpected schema: %s.%s (%s)", schema.Namespace, schema.Name, schema.RegistryType())
+	}
+	// The AVRO equivalent must be a valid AVRO schema for record generation
+	if _, err := goavro.NewCodec(schema.Content); err != nil {
+		t.Fatalf("invalid AVRO equivalent: %v\n%s", err, schema.Content)
+	}
+
+	types := make(map[string]interface{})
+	for _, f := range schema.Fields {
+		types[f.Name] = f.Type
+	}
+	if len(schema.Fields) != 10 || types["order_id"] != "string" || types["amount_cents"] != "long" {
+		t.Errorf("unexpected fields: %v", types)
+	}
+	if note, _ := types["note"].([]interface{}); len(note) != 2 || note[0] != "null" || note[1] != "string" {
+		t.Errorf("optional field should be nullable, got %v", types["note"])
+	}
+	if status, _ := types["status"].(map[string]interface{}); status["type"] != "enum" || !reflect.DeepEqual(status["symbols"], []interface{}{"STATUS_UNKNOWN", "PAID", "SHIPPED"}) {
+		t.Errorf("unexpected enum: %v", types["status"])
+	}
+	if created, _ := types["created_at"].([]interface{}); len(created) != 2 || !strings.Contains(schema.Content, `"logicalType":"timestamp-millis"`) {
+		t.Errorf("timestamp should be a nullable timestamp-millis, got %v", types["created_at"])
+	}
+
+	for _, src := range []string{
+		`syntax = "proto3"; message A { oneof kind { string x = 1; } }`,
+		`syntax = "proto3"; message A { Missing m = 1; }`,
+		`syntax = "proto3"; message A { map<int32, string> m = 1; }`,
+		`syntax = "proto3"; enum E { X = 0; }`,
+		`syntax = "proto3"; message A { string x = 1 }`,
+	} {
+		if _, err := ParseSchema([]byte(src), SchemaFormatProtobuf, "input"); err == nil {
+			t.Errorf("expected an error for %s", src)
+		}
+	}
+}
+
+func TestProtobufRoundTrip(t *testing.T) {
+	schema, err := ParseSchema([]byte(protobufTestSchema), SchemaFormatProtobuf, "input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.UnixMilli(1700000000123).UTC()
+	record := map[string]interface{}{
+		"order_id":     "o-1",
+		"amount_cents": int64(1250),
+		"status":       "SHIPPED",
+		"items":        []interface{}{map[string]interface{}{"sku": "A-1", "quantity": 2}, map[string]interface{}{"sku": "B-2", "quantity": -1}},
+		"tags":         map[string]interface{}{"channel": "web", "region": "eu"},
+		"note":         goavro.Union("string", "leave at door"),
+		"created_at":   goavro.Union("long.timestamp-millis", created),
+		"scores":       []interface{}{1, 300, -2},
+		"delta":        -7,
+		"ratio":        0.25,
+	}
+	payload, err := encodeProto(schema.proto, record)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := decodeProto(schema.proto, payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := map[string]interface{}{
+		"order_id":     "o-1",
+		"amount_cents": int64(1250),
+		"status":       "SHIPPED",
+		"items":        []interface{}{map[string]interface{}{"sku": "A-1", "quantity": int32(2)}, map[string]interface{}{"sku": "B-2", "quantity": int32(-1)}},
+		"tags":         map[string]interface{}{"channel": "web", "region": "eu"},
+		"note":         "leave at door",
+		"created_at":   created,
+		"scores":       []interface{}{int32(1), int32(300), int32(-2)},
+		"delta":        int32(-7),
+		"ratio":        0.25,
+	}
+	if !reflect.DeepEqual(decoded, want) {
+		t.Errorf("decoded record:\n got %v\nwant %v", decoded, want)
+	}
+
+	// proto3 leaves zero values out; decoding fills in the defaults
+	empty, err := encodeProto(schema.proto, map[string]interface{}{"order_id": "", "amount_cents": int64(0), "status": "STATUS_UNKNOWN", "note": nil})
+	if err != nil || len(empty) != 0 {
+		t.Fatalf("zero values should encode to nothing, got %v (%v)", empty, err)
+	}
+	defaults, _ := decodeProto(schema.proto, nil)
+	if defaults["order_id"] != "" || defaults["status"] != "STATUS_UNKNOWN" || defaults["note"] != nil || defaults["delta"] != int32(0) {
+		t.Errorf("unexpected defaults: %v", defaults)
+	}
+
+	if _, err := encodeProto(schema.proto, map[string]interface{}{"status": "LOST"}); err == nil {
+		t.Error("expected an error for an unknown enum symbol")
+	}
+}
+
+func TestProtobufWireFormat(t *testing.T) {
+	for _, indexes := range [][]int{{0}, {1}, {0, 2}} {
+		data := appendMessageIndexes(nil, indexes)
+		got, rest, err := readMessageIndexes(append(data, 0xAA))
+		if err != nil || !reflect.DeepEqual(got, indexes) || len(rest) != 1 {
+			t.Errorf("indexes %v: got %v, rest %v, err %v", indexes, got, rest, err)
+		}
+	}
+	if data := appendMessageIndexes(nil, []int{0}); len(data) != 1 || data[0] != 0 {
+		t.Errorf("[0] should be written as a single 0 byte, got %v", data)
+	}
+
+	schema, err := ParseSchema([]byte(protobufTestSchema), SchemaFormatProtobuf, "input")
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer := &Producer{schema: schema, schemaID: 21}
+	value, err := producer.encodeMessageProtobuf(map[string]interface{}{"order_id": "o-9", "status": "PAID"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	consumer := &Consumer{config: &Config{}, fetchSchema: func(id int) (*registeredSchema, error) {
+		return &registeredSchema{definition: protobufTestSchema, format: SchemaFormatProtobuf}, nil
+	}}
+	native, schemaID, err := consumer.DecodeValue("orders", value)
+	if err != nil {
+		t.Fatal(err)
+	}
+	record, _ := native.(map[string]interface{})
+	if schemaID != 21 || record["order_id"] != "o-9" || record["status"] != "PAID" {
+		t.Errorf("unexpected record %v with schema %d", native, schemaID)
+	}
+
+	// Generated records, with their unions and logical types, survive the round trip
+	codec, err := goavro.NewCodec(schema.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	producer.codec, producer.rng = codec, rand.New(rand.NewSource(7))
+	producer.avroValues = newAVROValueGenerator(schema, producer.rng, producer.generateStringValue)
+	for i := 0; i < 50; i++ {
+		message, err := producer.generateDynamicMessage(i)
+		if err != nil {
+			t.Fatal(err)
+		}
+		value, err := producer.encodeMessage(message)
+		if err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		native, _, err := consumer.DecodeValue("orders", value)
+		if err != nil {
+			t.Fatalf("message %d: %v", i, err)
+		}
+		if record := native.(map[string]interface{}); record["order_id"] != message["order_id"] || record["status"] != message["status"] {
+			t.Fatalf("message %d: decoded %v from %v", i, record, message)
+		}
+	}
+
+	// Index [1] points at the second top-level message
+	refund := confluentWireFormat(21, append(appendMessageIndexes(nil, []int{1}), 0x0A, 0x03, 'o', '-', '1'))
+	if native, _, err = consumer.DecodeValue("orders", refund); err != nil || native.(map[string]interface{})["order_id"] != "o-1" {
+		t.Errorf("unexpected refund %v (%v)", native, err)
+	}
+}
@@ -0,0 +1,98 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxRateTimelineSeconds bounds how much of a run the rate timeline keeps
+const maxRateTimelineSeconds = 7 * 24 * 60 * 60
+
+// Size of the expected-vs-actual rate chart in the execution report
+const (
+	rateChartWidth  = 900
+	rateChartHeight = 240
+)
+
+// rateTimeline counts the messages sent in each second of the run next to the rate the producer aimed for
+type rateTimeline struct {
+	mu       sync.Mutex
+	sent     []int
+	expected []int
+}
+
+// record counts one message sent at elapsed while aiming for the expected rate
+func (t *rateTimeline) record(elapsed time.Duration, expected int) {
+	second := int(elapsed / time.Second)
+	if second < 0 || second >= maxRateTimelineSeconds {
+		return
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for len(t.sent) <= second {
+		t.sent = append(t.sent, 0)
+		t.expected = append(t.expected, expected)
+	}
+	t.sent[second]++
+	t.expected[second] = expected
+}
+
+// RateChart holds the expected and actual producer rate as SVG polylines for the execution report
+type RateChart struct {
+	Width          int
+	Height         int
+	MaxRate        int
+	Duration       time.Duration
+	ExpectedPoints string
+	ActualPoints   string
+	Deviation      float64 // Mean absolute difference between actual and expected rate, in percent of expected
+}
+
+// chart renders the complete seconds of the timeline; nil when there is not enough data to draw
+func (t *rateTimeline) chart() *RateChart {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	// The last second is still being filled when the producer stops
+	seconds := len(t.sent) - 1
+	if seconds < 2 {
+		return nil
+	}
+
+	maxRate := 1
+	var deviation float64
+	for i := 0; i < seconds; i++ {
+		if t.sent[i] > maxRate {
+			maxRate = t.sent[i]
+		}
+		if t.expected[i] > maxRate {
+			maxRate = t.expected[i]
+		}
+		if t.expected[i] > 0 {
+			deviation += math.Abs(float64(t.sent[i]-t.expected[i])) / float64(t.expected[i])
+		}
+	}
+
+	points := func(values []int) string {
+		var b strings.Builder
+		for i := 0; i < seconds; i++ {
+			x := float64(i) * rateChartWidth / float64(seconds-1)
+			y := rateChartHeight - float64(values[i])*rateChartHeight/float64(maxRate)
+			fmt.Fprintf(&b, "%.1f,%.1f ", x, y)
+		}
+		return strings.TrimSpace(b.String())
+	}
+
+	return &RateChart{
+		Width:          rateChartWidth,
+		Height:         rateChartHeight,
+		MaxRate:        maxRate,
+		Duration:       time.Duration(seconds) * time.Second,
+		ExpectedPoints: points(t.expected),
+		ActualPoints:   points(t.sent),
+		Deviation:      deviation / float64(seconds) * 100,
+	}
+}
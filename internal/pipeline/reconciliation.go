@@ -0,0 +1,415 @@
+package pipeline
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Reconciliation modes
+const (
+	ReconcileExact = "exact" // Every record ID is kept; counts and samples are exact
+	ReconcileBloom = "bloom" // IDs went into bloom filters past the exact limit; counts are approximate
+)
+
+// Reconciliation defaults
+const (
+	DefaultReconcileExactLimit        = 1000000 // IDs kept exactly before switching to bloom filters
+	DefaultReconcileFalsePositiveRate = 0.001
+	maxReconcileSamples               = 10 // IDs kept per kind of discrepancy
+)
+
+// ReconciliationConfig accounts for every input record in the output (reconciliation section).
+// It suits pass-through and filter pipelines, where each input record shows up downstream at most once.
+type ReconciliationConfig struct {
+	Enabled           bool    `yaml:"enabled" mapstructure:"enabled"`
+	IDField           string  `yaml:"id_field" mapstructure:"id_field"`                       // Input field that identifies a record (default: event_id, id or first *_id field)
+	OutputIDField     string  `yaml:"output_id_field" mapstructure:"output_id_field"`         // Output field carrying the input ID; nested fields use dots (default: IDField)
+	Filter            string  `yaml:"filter" mapstructure:"filter"`                           // Input records that do not match are not expected downstream (validation expr syntax)
+	ExactLimit        int     `yaml:"exact_limit" mapstructure:"exact_limit"`                 // IDs kept exactly before switching to bloom filters (default 1,000,000)
+	FalsePositiveRate float64 `yaml:"false_positive_rate" mapstructure:"false_positive_rate"` // Of the bloom filters (default 0.001)
+}
+
+// IsEnabled reports whether input and output should be reconciled
+func (c *ReconciliationConfig) IsEnabled() bool {
+	return c != nil && c.Enabled
+}
+
+// Validate checks the reconciliation settings
+func (c *ReconciliationConfig) Validate() error {
+	if c.ExactLimit < 0 {
+		return fmt.Errorf("exact_limit must not be negative")
+	}
+	if c.FalsePositiveRate < 0 || c.FalsePositiveRate >= 1 {
+		return fmt.Errorf("false_positive_rate must be between 0 and 1")
+	}
+	if c.Filter != "" {
+		if _, err := parseRuleExpr(c.Filter); err != nil {
+			return fmt.Errorf("invalid filter %q: %w", c.Filter, err)
+		}
+	}
+	return nil
+}
+
+// Summary describes the reconciliation for the execution plan
+func (c *ReconciliationConfig) Summary() string {
+	id := c.IDField
+	if id == "" {
+		id = "auto-detected ID field"
+	}
+	if c.OutputIDField != "" && c.OutputIDField != c.IDField {
+		id = fmt.Sprintf("%s -> %s", id, c.OutputIDField)
+	}
+	if c.Filter != "" {
+		return fmt.Sprintf("%s, expecting records where %s", id, c.Filter)
+	}
+	return id
+}
+
+// ReconciliationReport tells which input records never showed up downstream and which showed up more than once
+type ReconciliationReport struct {
+	IDField       string
+	OutputIDField string
+	Mode          string
+	Produced      int64 // Input records expected downstream
+	Filtered      int64 // Input records the filter excludes
+	Output        int64 // Output records read
+	MissingID     int64 // Output records without an ID
+	Matched       int64 // Distinct input IDs found in the output
+	Lost          int64 // Input IDs never seen in the output
+	Duplicated    int64 // Extra copies of input records in the output
+	Unexpected    int64 // Output records whose ID was never produced
+
+	LostSamples       []string
+	DuplicatedSamples []string
+	UnexpectedSamples []string
+}
+
+// Clean reports whether every expected input record showed up exactly once
+func (r *ReconciliationReport) Clean() bool {
+	return r.Lost == 0 && r.Duplicated == 0 && r.Unexpected == 0
+}
+
+// Summary returns a one-line result, e.g. "1000 produced, 998 matched, 2 lost, 0 duplicated, 0 unexpected"
+func (r *ReconciliationReport) Summary() string {
+	summary := fmt.Sprintf("%d produced, %d matched, %d lost, %d duplicated, %d unexpected", r.Produced, r.Matched, r.Lost, r.Duplicated, r.Unexpected)
+	if r.Mode == ReconcileBloom {
+		summary += " (approximate)"
+	}
+	return summary
+}
+
+// Print writes the result and the sample IDs to stdout
+func (r *ReconciliationReport) Print(limit int) {
+	icon := "✅"
+	if !r.Clean() {
+		icon = "❌"
+	}
+	fmt.Printf("%s Reconciliation on %s: %s\n", icon, r.IDField, r.Summary())
+	if r.Filtered > 0 {
+		fmt.Printf("   %d input record(s) excluded by the filter\n", r.Filtered)
+	}
+	if r.MissingID > 0 {
+		fmt.Printf("   ⚠️  %d output record(s) without %s\n", r.MissingID, r.OutputIDField)
+	}
+	for _, samples := range []struct {
+		kind string
+		ids  []string
+	}{{"lost", r.LostSamples}, {"duplicated", r.DuplicatedSamples}, {"unexpected", r.UnexpectedSamples}} {
+		if len(samples.ids) == 0 {
+			continue
+		}
+		ids := samples.ids
+		if len(ids) > limit {
+			ids = ids[:limit]
+		}
+		fmt.Printf("   %s: %s\n", samples.kind, strings.Join(ids, ", "))
+	}
+	if r.Mode == ReconcileBloom && r.Lost > 0 {
+		fmt.Println("   Lost IDs are not listed once the ledger switched to bloom filters")
+	}
+}
+
+// recordLedger keeps the IDs of the produced records and reconciles them with the output. It keeps every
+// ID up to the exact limit and then moves to a pair of bloom filters, which bound memory for large runs.
+type recordLedger struct {
+	idField  []string
+	outField []string
+	filter   ruleExpr
+	limit    int
+	fpRate   float64
+	capacity int // Expected distinct IDs, used to size the bloom filters
+
+	mu       sync.Mutex
+	produced map[string]int64 // Copies produced per ID (exact mode)
+	seen     map[string]int64 // Copies in the output per ID (exact mode)
+	inputs   *bloomFilter     // Produced IDs (bloom mode)
+	outputs  *bloomFilter     // Output IDs (bloom mode)
+	distinct int64            // Distinct produced IDs (bloom mode)
+	report   ReconciliationReport
+}
+
+// newRecordLedger resolves the ID field of the input schema; expected is the number of input records
+// the run is likely to produce
+func newRecordLedger(config *ReconciliationConfig, schema *Schema, expected int64) (*recordLedger, error) {
+	if schema == nil {
+		return nil, fmt.Errorf("a schema is required to reconcile input and output")
+	}
+
+	idField := config.IDField
+	if idField == "" {
+		if idField = defaultIDField(schema); idField == "" {
+			return nil, fmt.Errorf("schema %s has no ID field; set the reconciliation id_field", schema.Name)
+		}
+	} else if _, ok := schemaField(schema, idField); !ok {
+		return nil, fmt.Errorf("ID field %s not found in schema %s", idField, schema.Name)
+	}
+	outField := config.OutputIDField
+	if outField == "" {
+		outField = idField
+	}
+
+	l := &recordLedger{
+		idField:  []string{idField},
+		outField: strings.Split(outField, "."),
+		limit:    config.ExactLimit,
+		fpRate:   config.FalsePositiveRate,
+		produced: make(map[string]int64),
+		seen:     make(map[string]int64),
+	}
+	if l.limit == 0 {
+		l.limit = DefaultReconcileExactLimit
+	}
+	if l.fpRate == 0 {
+		l.fpRate = DefaultReconcileFalsePositiveRate
+	}
+	l.capacity = 4 * l.limit
+	if expected > int64(l.capacity) {
+		l.capacity = int(expected)
+	}
+	if config.Filter != "" {
+		filter, err := parseRuleExpr(config.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("invalid filter %q: %w", config.Filter, err)
+		}
+		l.filter = filter
+	}
+	l.report = ReconciliationReport{IDField: idField, OutputIDField: outField, Mode: ReconcileExact}
+	return l, nil
+}
+
+// ledgerID turns an ID value into its ledger key; numbers compare equal whatever their decoded type
+func ledgerID(v interface{}) string {
+	switch v := exprValue(v).(type) {
+	case nil:
+		return ""
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		return fmt.Sprintf("%v", v)
+	}
+}
+
+// RecordInput notes a delivered input record; copies is how many times it was written
+func (l *recordLedger) RecordInput(message map[string]interface{}, copies int) {
+	if l.filter != nil {
+		if matched, err := l.filter.eval(message); err == nil && matched != true {
+			l.mu.Lock()
+			l.report.Filtered += int64(copies)
+			l.mu.Unlock()
+			return
+		}
+	}
+	id := ledgerID(fieldValue(message, l.idField))
+	if id == "" {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.report.Produced += int64(copies)
+	if l.inputs == nil {
+		l.produced[id] += int64(copies)
+		if len(l.produced) > l.limit {
+			l.switchToBloom()
+		}
+		return
+	}
+	if !l.inputs.contains(id) {
+		l.distinct++
+		l.inputs.add(id)
+	}
+}
+
+// ObserveOutput is a consumer record observer
+func (l *recordLedger) ObserveOutput(record map[string]interface{}) {
+	id := ledgerID(fieldValue(record, l.outField))
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.report.Output++
+	if id == "" {
+		l.report.MissingID++
+		return
+	}
+	if l.inputs == nil {
+		l.seen[id]++
+		return
+	}
+
+	// Bloom mode reconciles as records arrive; the producer has delivered a record before Flink outputs it
+	switch {
+	case !l.inputs.contains(id):
+		l.report.Unexpected++
+		l.report.UnexpectedSamples = appendSample(l.report.UnexpectedSamples, id)
+	case l.outputs.contains(id):
+		l.report.Duplicated++
+		l.report.DuplicatedSamples = appendSample(l.report.DuplicatedSamples, id)
+	default:
+		l.report.Matched++
+		l.outputs.add(id)
+	}
+}
+
+// switchToBloom moves the exact ledger into bloom filters, reconciling the output seen so far
+func (l *recordLedger) switchToBloom() {
+	fmt.Printf("📒 Reconciliation ledger passed %d IDs; switching to bloom filters (counts become approximate)\n", l.limit)
+	l.inputs = newBloomFilter(l.capacity, l.fpRate)
+	l.outputs = newBloomFilter(l.capacity, l.fpRate)
+	for id := range l.produced {
+		l.inputs.add(id)
+	}
+	l.distinct = int64(len(l.produced))
+
+	for _, id := range sortedIDs(l.seen) {
+		copies, produced := l.seen[id], l.produced[id]
+		if produced == 0 {
+			l.report.Unexpected += copies
+			l.report.UnexpectedSamples = appendSample(l.report.UnexpectedSamples, id)
+			continue
+		}
+		l.report.Matched++
+		l.outputs.add(id)
+		if extra := copies - produced; extra > 0 {
+			l.report.Duplicated += extra
+			l.report.DuplicatedSamples = appendSample(l.report.DuplicatedSamples, id)
+		}
+	}
+	l.produced, l.seen = nil, nil
+	l.report.Mode = ReconcileBloom
+}
+
+// Report reconciles the produced IDs with the output seen so far
+func (l *recordLedger) Report() *ReconciliationReport {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	report := l.report
+	report.DuplicatedSamples = append([]string(nil), l.report.DuplicatedSamples...)
+	report.UnexpectedSamples = append([]string(nil), l.report.UnexpectedSamples...)
+	if l.inputs != nil {
+		if report.Lost = l.distinct - report.Matched; report.Lost < 0 {
+			report.Lost = 0
+		}
+		return &report
+	}
+
+	// An ID produced more than once (e.g. an injected duplicate) may show up as often as it was produced
+	for _, id := range sortedIDs(l.produced) {
+		copies := l.seen[id]
+		if copies == 0 {
+			report.Lost++
+			report.LostSamples = appendSample(report.LostSamples, id)
+			continue
+		}
+		report.Matched++
+		if extra := copies - l.produced[id]; extra > 0 {
+			report.Duplicated += extra
+			report.DuplicatedSamples = appendSample(report.DuplicatedSamples, id)
+		}
+	}
+	for _, id := range sortedIDs(l.seen) {
+		if l.produced[id] == 0 {
+			report.Unexpected += l.seen[id]
+			report.UnexpectedSamples = appendSample(report.UnexpectedSamples, id)
+		}
+	}
+	return &report
+}
+
+// appendSample keeps the first maxReconcileSamples IDs
+func appendSample(samples []string, id string) []string {
+	if len(samples) >= maxReconcileSamples {
+		return samples
+	}
+	return append(samples, id)
+}
+
+// sortedIDs returns the IDs of a ledger map in order, so samples are stable between runs
+func sortedIDs(ids map[string]int64) []string {
+	sorted := make([]string, 0, len(ids))
+	for id := range ids {
+		sorted = append(sorted, id)
+	}
+	sort.Strings(sorted)
+	return sorted
+}
+
+// bloomFilter is a fixed-size set of strings with false positives but no false negatives
+type bloomFilter struct {
+	bits   []uint64
+	size   uint64 // Number of bits
+	hashes uint64
+}
+
+// newBloomFilter sizes a filter for n items at the given false positive rate
+func newBloomFilter(n int, fpRate float64) *bloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	size := uint64(math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	if size < 64 {
+		size = 64
+	}
+	hashes := uint64(math.Round(float64(size) / float64(n) * math.Ln2))
+	if hashes < 1 {
+		hashes = 1
+	}
+	return &bloomFilter{bits: make([]uint64, (size+63)/64), size: size, hashes: hashes}
+}
+
+// locations derives the bit positions of an item by double hashing
+func (b *bloomFilter) locations(item string) (uint64, uint64) {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(item))
+	h1 := h.Sum64()
+
+	// FNV spreads similar IDs poorly on its own; a splitmix64 finalizer gives the second hash
+	h2 := h1 + 0x9e3779b97f4a7c15
+	h2 = (h2 ^ h2>>30) * 0xbf58476d1ce4e5b9
+	h2 = (h2 ^ h2>>27) * 0x94d049bb133111eb
+	return h1, (h2 ^ h2>>31) | 1
+}
+
+func (b *bloomFilter) add(item string) {
+	h1, h2 := b.locations(item)
+	for i := uint64(0); i < b.hashes; i++ {
+		bit := (h1 + i*h2) % b.size
+		b.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+func (b *bloomFilter) contains(item string) bool {
+	h1, h2 := b.locations(item)
+	for i := uint64(0); i < b.hashes; i++ {
+		bit := (h1 + i*h2) % b.size
+		if b.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,139 @@
+package pipeline
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+const reconciliationTestSchema = `{
+  "type": "record",
+  "name": "Order",
+  "fields": [
+    {"name": "order_id", "type": "long"},
+    {"name": "status", "type": "string"}
+  ]
+}`
+
+func TestReconciliationConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  ReconciliationConfig
+		wantErr bool
+	}{
+		{name: "defaults", config: ReconciliationConfig{Enabled: true}},
+		{name: "filter", config: ReconciliationConfig{Enabled: true, Filter: "status == 'PAID'"}},
+		{name: "negative limit", config: ReconciliationConfig{ExactLimit: -1}, wantErr: true},
+		{name: "false positive rate of one", config: ReconciliationConfig{FalsePositiveRate: 1}, wantErr: true},
+		{name: "bad filter", config: ReconciliationConfig{Filter: "status =="}, wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRecordLedgerExact(t *testing.T) {
+	schema := loadTestSchema(t, reconciliationTestSchema)
+	if _, err := newRecordLedger(&ReconciliationConfig{IDField: "missing"}, schema, 0); err == nil {
+		t.Error("expected an error for an ID field outside the schema")
+	}
+
+	ledger, err := newRecordLedger(&ReconciliationConfig{Filter: "status != 'CANCELLED'"}, schema, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for id := int64(1); id <= 5; id++ {
+		ledger.RecordInput(map[string]interface{}{"order_id": id, "status": "PAID"}, 1)
+	}
+	ledger.RecordInput(map[string]interface{}{"order_id": int64(6), "status": "PAID"}, 2) // Injected duplicate
+	ledger.RecordInput(map[string]interface{}{"order_id": int64(7), "status": "CANCELLED"}, 1)
+
+	// JSON output decodes numbers as float64; they still match the long IDs
+	for _, id := range []interface{}{1.0, 2.0, 2.0, 4.0, 6.0, 6.0, 9.0} {
+		ledger.ObserveOutput(map[string]interface{}{"order_id": id})
+	}
+	ledger.ObserveOutput(map[string]interface{}{"status": "PAID"})
+
+	report := ledger.Report()
+	if report.Mode != ReconcileExact || report.IDField != "order_id" || report.OutputIDField != "order_id" {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+	if report.Produced != 7 || report.Filtered != 1 || report.Output != 8 || report.MissingID != 1 {
+		t.Errorf("got %d produced, %d filtered, %d output, %d without ID, want 7, 1, 8, 1", report.Produced, report.Filtered, report.Output, report.MissingID)
+	}
+	if report.Matched != 4 || report.Lost != 2 || report.Duplicated != 1 || report.Unexpected != 1 {
+		t.Errorf("got %d matched, %d lost, %d duplicated, %d unexpected, want 4, 2, 1, 1", report.Matched, report.Lost, report.Duplicated, report.Unexpected)
+	}
+	if !reflect.DeepEqual(report.LostSamples, []string{"3", "5"}) || !reflect.DeepEqual(report.DuplicatedSamples, []string{"2"}) || !reflect.DeepEqual(report.UnexpectedSamples, []string{"9"}) {
+		t.Errorf("unexpected samples: lost %v, duplicated %v, unexpected %v", report.LostSamples, report.DuplicatedSamples, report.UnexpectedSamples)
+	}
+	if report.Clean() {
+		t.Error("expected the report to show discrepancies")
+	}
+}
+
+func TestRecordLedgerSwitchesToBloom(t *testing.T) {
+	schema := loadTestSchema(t, reconciliationTestSchema)
+	// A tiny false positive rate keeps the approximate counts exact at this size
+	ledger, err := newRecordLedger(&ReconciliationConfig{ExactLimit: 100, FalsePositiveRate: 0.000001}, schema, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	input := func(id int) map[string]interface{} {
+		return map[string]interface{}{"order_id": int64(id), "status": "PAID"}
+	}
+	for id := 0; id < 50; id++ {
+		ledger.RecordInput(input(id), 1)
+	}
+	// Output seen before the switch is reconciled into the filters
+	for id := 0; id < 50; id++ {
+		ledger.ObserveOutput(map[string]interface{}{"order_id": id})
+	}
+	ledger.ObserveOutput(map[string]interface{}{"order_id": 10})
+
+	for id := 50; id < 1000; id++ {
+		ledger.RecordInput(input(id), 1)
+	}
+	for id := 50; id < 990; id++ {
+		ledger.ObserveOutput(map[string]interface{}{"order_id": id})
+	}
+	ledger.ObserveOutput(map[string]interface{}{"order_id": 500})
+	ledger.ObserveOutput(map[string]interface{}{"order_id": "unknown"})
+
+	report := ledger.Report()
+	if report.Mode != ReconcileBloom {
+		t.Fatalf("mode = %s, want %s past the exact limit", report.Mode, ReconcileBloom)
+	}
+	if report.Produced != 1000 || report.Lost != 10 || report.Duplicated != 2 || report.Unexpected != 1 {
+		t.Errorf("got %s, want 1000 produced, 10 lost, 2 duplicated, 1 unexpected", report.Summary())
+	}
+	if len(report.LostSamples) != 0 || !reflect.DeepEqual(report.DuplicatedSamples, []string{"10", "500"}) {
+		t.Errorf("unexpected samples: lost %v, duplicated %v", report.LostSamples, report.DuplicatedSamples)
+	}
+}
+
+func TestBloomFilter(t *testing.T) {
+	filter := newBloomFilter(10000, 0.01)
+	for i := 0; i < 10000; i++ {
+		filter.add(fmt.Sprintf("id-%d", i))
+	}
+	for i := 0; i < 10000; i++ {
+		if !filter.contains(fmt.Sprintf("id-%d", i)) {
+			t.Fatalf("id-%d missing from the filter", i)
+		}
+	}
+	falsePositives := 0
+	for i := 10000; i < 20000; i++ {
+		if filter.contains(fmt.Sprintf("id-%d", i)) {
+			falsePositives++
+		}
+	}
+	if falsePositives > 300 {
+		t.Errorf("%d false positives in 10000 lookups, want about 100", falsePositives)
+	}
+}
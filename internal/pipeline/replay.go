@@ -0,0 +1,615 @@
+package pipeline
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"math/big"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// Replay pacing modes
+const (
+	ReplayPaceMax      = "max"      // Send records as fast as possible
+	ReplayPaceRate     = "rate"     // Send records at --message-rate
+	ReplayPaceOriginal = "original" // Reproduce the original inter-event spacing from a timestamp field
+)
+
+// ReplayConfig configures the replay producer that feeds topics from recorded files
+type ReplayConfig struct {
+	Path           string  // JSONL, CSV or AVRO container file
+	Pace           string  // max, rate or original
+	Rate           int     // Messages per second for rate pacing
+	TimestampField string  // Field holding the event time (required for original pacing)
+	Speed          float64 // Speed factor for original pacing (2 = twice as fast)
+}
+
+// Validate checks the replay configuration
+func (c *ReplayConfig) Validate() error {
+	if c.Path == "" {
+		return fmt.Errorf("replay file path is required")
+	}
+	if _, err := os.Stat(c.Path); err != nil {
+		return fmt.Errorf("replay file not accessible: %w", err)
+	}
+	if _, err := replayFormat(c.Path); err != nil {
+		return err
+	}
+	switch c.Pace {
+	case ReplayPaceMax:
+	case ReplayPaceRate:
+		// The pace is a ticker interval of a second divided by the rate, at least a nanosecond
+		if c.Rate <= 0 || c.Rate > int(time.Second) {
+			return fmt.Errorf("rate replay pacing requires a message rate between 1 and %d, got %d", int(time.Second), c.Rate)
+		}
+	case ReplayPaceOriginal:
+		if c.TimestampField == "" {
+			return fmt.Errorf("original replay pacing requires a timestamp field")
+		}
+		if c.Speed <= 0 {
+			return fmt.Errorf("replay speed must be positive, got %v", c.Speed)
+		}
+	default:
+		return fmt.Errorf("unknown replay pace %q (expected max, rate or original)", c.Pace)
+	}
+	return nil
+}
+
+// ReplaySource yields recorded records one at a time; Next returns io.EOF when exhausted
+type ReplaySource interface {
+	Next() (map[string]interface{}, error)
+	Close() error
+}
+
+// replayFormat detects the file format from its extension
+func replayFormat(path string) (string, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl", ".ndjson", ".json":
+		return "jsonl", nil
+	case ".csv":
+		return "csv", nil
+	case ".avro":
+		return "avro", nil
+	}
+	return "", fmt.Errorf("unsupported replay file %s (expected .jsonl, .csv or .avro)", path)
+}
+
+// OpenReplaySource opens a recorded file as a ReplaySource
+func OpenReplaySource(path string) (ReplaySource, error) {
+	format, err := replayFormat(path)
+	if err != nil {
+		return nil, err
+	}
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open replay file: %w", err)
+	}
+
+	switch format {
+	case "jsonl":
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+		return &jsonlReplaySource{file: file, scanner: scanner}, nil
+	case "csv":
+		reader := csv.NewReader(bufio.NewReader(file))
+		reader.FieldsPerRecord = -1
+		header, err := reader.Read()
+		if err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("failed to read CSV header: %w", err)
+		}
+		columns := make([]string, len(header))
+		for i, h := range header {
+			columns[i] = strings.TrimSpace(h)
+		}
+		return &csvReplaySource{file: file, reader: reader, columns: columns}, nil
+	default:
+		ocf, err := goavro.NewOCFReader(bufio.NewReader(file))
+		if err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("failed to open AVRO container file: %w", err)
+		}
+		return &avroReplaySource{file: file, ocf: ocf}, nil
+	}
+}
+
+// jsonlReplaySource reads one JSON object per line
+type jsonlReplaySource struct {
+	file    *os.File
+	scanner *bufio.Scanner
+	line    int
+}
+
+func (s *jsonlReplaySource) Next() (map[string]interface{}, error) {
+	for s.scanner.Scan() {
+		s.line++
+		line := strings.TrimSpace(s.scanner.Text())
+		if line == "" {
+			continue
+		}
+		decoder := json.NewDecoder(strings.NewReader(line))
+		decoder.UseNumber()
+		var record map[string]interface{}
+		if err := decoder.Decode(&record); err != nil {
+			return nil, fmt.Errorf("line %d: invalid JSON: %w", s.line, err)
+		}
+		return record, nil
+	}
+	if err := s.scanner.Err(); err != nil {
+		return nil, err
+	}
+	return nil, io.EOF
+}
+
+func (s *jsonlReplaySource) Close() error { return s.file.Close() }
+
+// csvReplaySource reads CSV rows keyed by header column
+type csvReplaySource struct {
+	file    *os.File
+	reader  *csv.Reader
+	columns []string
+}
+
+func (s *csvReplaySource) Next() (map[string]interface{}, error) {
+	row, err := s.reader.Read()
+	if err != nil {
+		return nil, err
+	}
+	record := make(map[string]interface{}, len(s.columns))
+	for i, col := range s.columns {
+		if i >= len(row) {
+			break
+		}
+		value := strings.TrimSpace(row[i])
+		if value == "" {
+			record[col] = nil
+			continue
+		}
+		record[col] = value
+	}
+	return record, nil
+}
+
+func (s *csvReplaySource) Close() error { return s.file.Close() }
+
+// avroReplaySource reads records from an AVRO object container file
+type avroReplaySource struct {
+	file *os.File
+	ocf  *goavro.OCFReader
+}
+
+func (s *avroReplaySource) Next() (map[string]interface{}, error) {
+	if !s.ocf.Scan() {
+		if err := s.ocf.Err(); err != nil {
+			return nil, err
+		}
+		return nil, io.EOF
+	}
+	datum, err := s.ocf.Read()
+	if err != nil {
+		return nil, err
+	}
+	record, ok := datum.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("AVRO container holds %T values, expected records", datum)
+	}
+	return record, nil
+}
+
+func (s *avroReplaySource) Close() error { return s.file.Close() }
+
+var (
+	replayDateLayouts      = []string{"2006-01-02", "02/01/2006", "01/02/2006"}
+	replayTimestampLayouts = []string{
+		time.RFC3339Nano,
+		"2006-01-02 15:04:05",
+		"2006-01-02 15:04:05.000",
+		"2006-01-02T15:04:05",
+		"2006-01-02T15:04:05.000",
+	}
+	nonIdentifierChars = regexp.MustCompile(`[^A-Za-z0-9_]`)
+)
+
+// lookupReplayField finds a field's value in a recorded record. CSV headers are matched the same way
+// `pipegen init --input-csv` derives field names (lowercased, non-identifier characters replaced by "_").
+func lookupReplayField(record map[string]interface{}, name string) (interface{}, bool) {
+	if v, ok := record[name]; ok {
+		return v, true
+	}
+	for key, v := range record {
+		if nonIdentifierChars.ReplaceAllString(strings.ToLower(key), "_") == name {
+			return v, true
+		}
+	}
+	return nil, false
+}
+
+// ReplayRecord converts a recorded record into goavro's native form for the producer's schema
+func (g *avroValueGenerator) ReplayRecord(record map[string]interface{}, schema *Schema) (map[string]interface{}, error) {
+	// Prefer the full definition so field defaults apply to columns missing from the recording
+	if g.root != nil {
+		v, err := g.coerce(record, g.root, "")
+		if err != nil {
+			return nil, err
+		}
+		return v.(map[string]interface{}), nil
+	}
+
+	message := make(map[string]interface{}, len(schema.Fields))
+	for _, field := range schema.Fields {
+		raw, _ := lookupReplayField(record, field.Name)
+		v, err := g.coerce(raw, field.Type, g.namespace)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %w", field.Name, err)
+		}
+		message[field.Name] = v
+	}
+	return message, nil
+}
+
+// coerce converts a decoded JSON, CSV or AVRO value to the native form goavro expects for avroType
+func (g *avroValueGenerator) coerce(value interface{}, avroType interface{}, namespace string) (interface{}, error) {
+	switch t := avroType.(type) {
+	case string:
+		if avroPrimitives[t] {
+			return coercePrimitive(value, t)
+		}
+		named, ok := g.lookup(t, namespace)
+		if !ok {
+			return nil, fmt.Errorf("unknown AVRO type reference %q", t)
+		}
+		return g.coerce(value, named.def, named.enclosing)
+
+	case []interface{}:
+		return g.coerceUnion(value, t, namespace)
+
+	case map[string]interface{}:
+		if value == nil {
+			return nil, fmt.Errorf("value is required")
+		}
+		if logical, ok := t["logicalType"].(string); ok {
+			if v, handled, err := coerceLogical(value, t, logical); handled {
+				return v, err
+			}
+		}
+
+		typeName, isString := t["type"].(string)
+		if !isString {
+			return g.coerce(value, t["type"], namespace)
+		}
+		switch typeName {
+		case "record", "error":
+			record, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("expected an object for record %v, got %T", t["name"], value)
+			}
+			ns := definitionNamespace(t, namespace)
+			fields, _ := t["fields"].([]interface{})
+			out := make(map[string]interface{}, len(fields))
+			for _, f := range fields {
+				fieldMap, ok := f.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				name, _ := fieldMap["name"].(string)
+				raw, present := lookupReplayField(record, name)
+				if !present {
+					if def, hasDefault := fieldMap["default"]; hasDefault {
+						raw = def
+					}
+				}
+				v, err := g.coerce(raw, fieldMap["type"], ns)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", name, err)
+				}
+				out[name] = v
+			}
+			return out, nil
+		case "enum":
+			symbol := fmt.Sprintf("%v", value)
+			for _, s := range stringList(t["symbols"]) {
+				if s == symbol {
+					return symbol, nil
+				}
+			}
+			return nil, fmt.Errorf("%q is not a symbol of enum %v", symbol, t["name"])
+		case "fixed":
+			return coerceBytes(value)
+		case "array":
+			items, ok := value.([]interface{})
+			if !ok {
+				return nil, fmt.Errorf("expected an array, got %T", value)
+			}
+			out := make([]interface{}, len(items))
+			for i, item := range items {
+				v, err := g.coerce(item, t["items"], namespace)
+				if err != nil {
+					return nil, fmt.Errorf("[%d]: %w", i, err)
+				}
+				out[i] = v
+			}
+			return out, nil
+		case "map":
+			entries, ok := value.(map[string]interface{})
+			if !ok {
+				return nil, fmt.Errorf("expected an object, got %T", value)
+			}
+			out := make(map[string]interface{}, len(entries))
+			for k, entry := range entries {
+				v, err := g.coerce(entry, t["values"], namespace)
+				if err != nil {
+					return nil, fmt.Errorf("%s: %w", k, err)
+				}
+				out[k] = v
+			}
+			return out, nil
+		default:
+			return g.coerce(value, typeName, namespace)
+		}
+	}
+	return nil, fmt.Errorf("unsupported AVRO type %v", avroType)
+}
+
+// coerceUnion accepts values already in goavro's union form as well as bare values,
+// in which case the first branch that accepts the value wins
+func (g *avroValueGenerator) coerceUnion(value interface{}, branches []interface{}, namespace string) (interface{}, error) {
+	if value == nil {
+		for _, branch := range branches {
+			if s, ok := branch.(string); ok && s == "null" {
+				return nil, nil
+			}
+		}
+		return nil, fmt.Errorf("value is required")
+	}
+
+	if wrapped, ok := value.(map[string]interface{}); ok && len(wrapped) == 1 {
+		for name, inner := range wrapped {
+			for _, branch := range branches {
+				if g.branchName(branch, namespace, inner) == name {
+					v, err := g.coerce(inner, branch, namespace)
+					if err != nil {
+						return nil, err
+					}
+					return goavro.Union(name, v), nil
+				}
+			}
+		}
+	}
+
+	var lastErr error
+	for _, branch := range branches {
+		if s, ok := branch.(string); ok && s == "null" {
+			continue
+		}
+		v, err := g.coerce(value, branch, namespace)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		return g.wrapUnion(branch, namespace, v), nil
+	}
+	return nil, fmt.Errorf("value %v matches no union branch: %w", value, lastErr)
+}
+
+func coercePrimitive(value interface{}, typeName string) (interface{}, error) {
+	if typeName == "null" {
+		return nil, nil
+	}
+	if value == nil {
+		return nil, fmt.Errorf("value is required")
+	}
+
+	switch typeName {
+	case "string":
+		switch v := value.(type) {
+		case string:
+			return v, nil
+		case time.Time:
+			return v.Format(time.RFC3339Nano), nil
+		}
+		return fmt.Sprintf("%v", value), nil
+	case "int", "long":
+		n, err := toInt64(value)
+		if err != nil {
+			return nil, err
+		}
+		if typeName == "int" {
+			if n < math.MinInt32 || n > math.MaxInt32 {
+				return nil, fmt.Errorf("%d overflows int", n)
+			}
+			return int32(n), nil
+		}
+		return n, nil
+	case "float", "double":
+		f, err := toFloat64(value)
+		if err != nil {
+			return nil, err
+		}
+		if typeName == "float" {
+			return float32(f), nil
+		}
+		return f, nil
+	case "boolean":
+		switch v := value.(type) {
+		case bool:
+			return v, nil
+		case string:
+			switch strings.ToLower(v) {
+			case "true", "1", "yes":
+				return true, nil
+			case "false", "0", "no":
+				return false, nil
+			}
+		}
+		return nil, fmt.Errorf("cannot convert %v to boolean", value)
+	case "bytes":
+		return coerceBytes(value)
+	}
+	return nil, fmt.Errorf("unsupported primitive type %s", typeName)
+}
+
+// coerceLogical handles logical types whose native form differs from the underlying type
+func coerceLogical(value interface{}, def map[string]interface{}, logical string) (interface{}, bool, error) {
+	base, _ := def["type"].(string)
+	switch logical {
+	case "timestamp-millis", "timestamp-micros", "local-timestamp-millis", "local-timestamp-micros":
+		if base != "long" {
+			return nil, false, nil
+		}
+		if s, ok := value.(string); ok {
+			if _, err := strconv.ParseInt(s, 10, 64); err != nil {
+				ts, err := parseReplayTimestamp(s)
+				if err != nil {
+					return nil, true, err
+				}
+				if strings.HasSuffix(logical, "micros") {
+					return ts.UnixMicro(), true, nil
+				}
+				return ts.UnixMilli(), true, nil
+			}
+		}
+		if ts, ok := value.(time.Time); ok {
+			if strings.HasSuffix(logical, "micros") {
+				return ts.UnixMicro(), true, nil
+			}
+			return ts.UnixMilli(), true, nil
+		}
+	case "date":
+		if base != "int" {
+			return nil, false, nil
+		}
+		if s, ok := value.(string); ok {
+			if _, err := strconv.ParseInt(s, 10, 32); err != nil {
+				for _, layout := range replayDateLayouts {
+					if d, err := time.Parse(layout, s); err == nil {
+						return int32(d.Unix() / 86400), true, nil
+					}
+				}
+				return nil, true, fmt.Errorf("cannot parse date %q", s)
+			}
+		}
+		if d, ok := value.(time.Time); ok {
+			return int32(d.Unix() / 86400), true, nil
+		}
+	case "decimal":
+		if base != "bytes" && base != "fixed" {
+			return nil, false, nil
+		}
+		if r, ok := value.(*big.Rat); ok {
+			return r, true, nil
+		}
+		r, ok := new(big.Rat).SetString(fmt.Sprintf("%v", value))
+		if !ok {
+			return nil, true, fmt.Errorf("cannot convert %v to decimal", value)
+		}
+		return r, true, nil
+	}
+	return nil, false, nil
+}
+
+func coerceBytes(value interface{}) (interface{}, error) {
+	switch v := value.(type) {
+	case []byte:
+		return v, nil
+	case string:
+		return []byte(v), nil
+	}
+	return nil, fmt.Errorf("cannot convert %T to bytes", value)
+}
+
+func toInt64(value interface{}) (int64, error) {
+	switch v := value.(type) {
+	case int:
+		return int64(v), nil
+	case int32:
+		return int64(v), nil
+	case int64:
+		return v, nil
+	case float32:
+		return int64(v), nil
+	case float64:
+		if v != math.Trunc(v) {
+			return 0, fmt.Errorf("%v is not an integer", v)
+		}
+		return int64(v), nil
+	case json.Number:
+		return v.Int64()
+	case string:
+		return strconv.ParseInt(v, 10, 64)
+	case time.Time:
+		return v.UnixMilli(), nil
+	}
+	return 0, fmt.Errorf("cannot convert %T to integer", value)
+}
+
+func toFloat64(value interface{}) (float64, error) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), nil
+	case int32:
+		return float64(v), nil
+	case int64:
+		return float64(v), nil
+	case float32:
+		return float64(v), nil
+	case float64:
+		return v, nil
+	case json.Number:
+		return v.Float64()
+	case string:
+		return strconv.ParseFloat(v, 64)
+	}
+	return 0, fmt.Errorf("cannot convert %T to number", value)
+}
+
+func parseReplayTimestamp(s string) (time.Time, error) {
+	for _, layout := range replayTimestampLayouts {
+		if ts, err := time.Parse(layout, s); err == nil {
+			return ts, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("cannot parse timestamp %q", s)
+}
+
+// ReplayEventTime extracts the event time of a recorded record. Numeric values are interpreted as
+// epoch seconds, milliseconds or microseconds depending on their magnitude.
+func ReplayEventTime(record map[string]interface{}, field string) (time.Time, error) {
+	raw, ok := lookupReplayField(record, field)
+	if !ok || raw == nil {
+		return time.Time{}, fmt.Errorf("timestamp field %s missing", field)
+	}
+	if wrapped, ok := raw.(map[string]interface{}); ok && len(wrapped) == 1 {
+		for _, inner := range wrapped {
+			raw = inner
+		}
+	}
+	if ts, ok := raw.(time.Time); ok {
+		return ts, nil
+	}
+	if s, ok := raw.(string); ok {
+		if ts, err := parseReplayTimestamp(s); err == nil {
+			return ts, nil
+		}
+	}
+	n, err := toInt64(raw)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("timestamp field %s: %w", field, err)
+	}
+	switch {
+	case n > 1e14:
+		return time.UnixMicro(n), nil
+	case n > 1e11:
+		return time.UnixMilli(n), nil
+	}
+	return time.Unix(n, 0), nil
+}
@@ -0,0 +1,193 @@
+package pipeline
+
+import (
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+const replayTestSchema = `{
+  "type": "record",
+  "name": "PageView",
+  "namespace": "com.example",
+  "fields": [
+    {"name": "user_id", "type": "string"},
+    {"name": "count", "type": "int"},
+    {"name": "score", "type": ["null", "double"]},
+    {"name": "event_time", "type": {"type": "long", "logicalType": "timestamp-millis"}},
+    {"name": "tags", "type": {"type": "array", "items": "string"}, "default": []}
+  ]
+}`
+
+func readAll(t *testing.T, path string) []map[string]interface{} {
+	t.Helper()
+	source, err := OpenReplaySource(path)
+	if err != nil {
+		t.Fatalf("OpenReplaySource() error = %v", err)
+	}
+	defer func() { _ = source.Close() }()
+
+	var records []map[string]interface{}
+	for {
+		record, err := source.Next()
+		if err == io.EOF {
+			return records
+		}
+		if err != nil {
+			t.Fatalf("Next() error = %v", err)
+		}
+		records = append(records, record)
+	}
+}
+
+func encodeReplayRecords(t *testing.T, schema *Schema, records []map[string]interface{}) {
+	t.Helper()
+	codec, err := goavro.NewCodec(schema.Content)
+	if err != nil {
+		t.Fatal(err)
+	}
+	g := newAVROValueGenerator(schema, rand.New(rand.NewSource(1)), func(string, int) string { return "" })
+	for i, record := range records {
+		message, err := g.ReplayRecord(record, schema)
+		if err != nil {
+			t.Fatalf("record %d: ReplayRecord() error = %v", i, err)
+		}
+		if _, err := codec.BinaryFromNative(nil, message); err != nil {
+			t.Fatalf("record %d: encode error = %v", i, err)
+		}
+	}
+}
+
+func TestReplayJSONL(t *testing.T) {
+	schema := loadTestSchema(t, replayTestSchema)
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	content := `{"user_id": "u1", "count": 3, "score": 1.5, "event_time": "2024-01-01T10:00:00Z", "tags": ["a", "b"]}
+
+{"user_id": "u2", "count": 7, "score": null, "event_time": 1704103205000}
+`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	records := readAll(t, path)
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	encodeReplayRecords(t, schema, records)
+}
+
+func TestReplayCSV(t *testing.T) {
+	schema := loadTestSchema(t, replayTestSchema)
+	path := filepath.Join(t.TempDir(), "events.csv")
+	content := "User-ID,count,score,event_time\nu1,3,1.5,2024-01-01 10:00:00\nu2,4,,2024-01-01 10:00:05\n"
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	records := readAll(t, path)
+	if len(records) != 2 {
+		t.Fatalf("expected 2 records, got %d", len(records))
+	}
+	encodeReplayRecords(t, schema, records)
+}
+
+func TestReplayAVROContainer(t *testing.T) {
+	schema := loadTestSchema(t, replayTestSchema)
+	path := filepath.Join(t.TempDir(), "events.avro")
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	writer, err := goavro.NewOCFWriter(goavro.OCFConfig{W: file, Schema: schema.Content})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = writer.Append([]interface{}{
+		map[string]interface{}{"user_id": "u1", "count": 1, "score": goavro.Union("double", 2.5), "event_time": time.Now(), "tags": []interface{}{"x"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_ = file.Close()
+
+	records := readAll(t, path)
+	if len(records) != 1 {
+		t.Fatalf("expected 1 record, got %d", len(records))
+	}
+	encodeReplayRecords(t, schema, records)
+}
+
+func TestReplayRecordRejectsBadValues(t *testing.T) {
+	schema := loadTestSchema(t, replayTestSchema)
+	g := newAVROValueGenerator(schema, rand.New(rand.NewSource(1)), func(string, int) string { return "" })
+
+	if _, err := g.ReplayRecord(map[string]interface{}{"user_id": "u1", "count": "many", "event_time": "2024-01-01T10:00:00Z"}, schema); err == nil {
+		t.Error("expected error for non-numeric int")
+	}
+	if _, err := g.ReplayRecord(map[string]interface{}{"count": "1", "event_time": "2024-01-01T10:00:00Z"}, schema); err == nil {
+		t.Error("expected error for missing required field")
+	}
+}
+
+func TestReplayEventTime(t *testing.T) {
+	want := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name  string
+		value interface{}
+	}{
+		{"rfc3339", "2024-01-01T10:00:00Z"},
+		{"sql timestamp", "2024-01-01 10:00:00"},
+		{"epoch seconds", float64(want.Unix())},
+		{"epoch millis", want.UnixMilli()},
+		{"epoch micros", "1704103200000000"},
+		{"time", want},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ReplayEventTime(map[string]interface{}{"ts": tt.value}, "ts")
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if !got.Equal(want) {
+				t.Errorf("got %v, want %v", got, want)
+			}
+		})
+	}
+}
+
+func TestReplayConfigValidate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "events.jsonl")
+	if err := os.WriteFile(path, []byte("{}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		config  ReplayConfig
+		wantErr bool
+	}{
+		{"rate", ReplayConfig{Path: path, Pace: ReplayPaceRate, Rate: 100}, false},
+		{"zero rate", ReplayConfig{Path: path, Pace: ReplayPaceRate}, true},
+		{"negative rate", ReplayConfig{Path: path, Pace: ReplayPaceRate, Rate: -5}, true},
+		{"rate above a message per nanosecond", ReplayConfig{Path: path, Pace: ReplayPaceRate, Rate: int(time.Second) + 1}, true},
+		{"max ignores the rate", ReplayConfig{Path: path, Pace: ReplayPaceMax}, false},
+		{"original", ReplayConfig{Path: path, Pace: ReplayPaceOriginal, TimestampField: "ts", Speed: 2}, false},
+		{"original without field", ReplayConfig{Path: path, Pace: ReplayPaceOriginal, Speed: 1}, true},
+		{"bad speed", ReplayConfig{Path: path, Pace: ReplayPaceOriginal, TimestampField: "ts"}, true},
+		{"unknown pace", ReplayConfig{Path: path, Pace: "warp"}, true},
+		{"missing file", ReplayConfig{Path: path + ".missing", Pace: ReplayPaceMax}, true},
+		{"unsupported extension", ReplayConfig{Path: filepath.Join(filepath.Dir(path)), Pace: ReplayPaceMax}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -3,8 +3,6 @@ package pipeline
 import (
 	"context"
 	"fmt"
-	"regexp"
-	"strings"
 	"time"
 
 	"pipegen/internal/types"
@@ -18,6 +16,8 @@ type Resources struct {
 	InputTopic  string
 	OutputTopic string
 	Topics      []string
+	Sources     []SourceTable // Source tables with their topics for this run; the primary (InputTopic) comes first
+	Sinks       []SourceTable // Kafka tables the pipeline writes to, with their topics for this run; the primary (OutputTopic) comes first
 }
 
 // ResourceManager handles creation and cleanup of pipeline resources
@@ -48,10 +48,23 @@ func NewResourceManager(config *Config) *ResourceManager {
 	}
 }
 
-// GenerateResources creates resource names based on mode and SQL statements
+// GenerateResources creates resource names based on mode and SQL statements, and resolves the
+// topics of every source table the pipeline reads from and every sink table it writes to
 func (rm *ResourceManager) GenerateResources(statements []*types.SQLStatement) (*Resources, error) {
+	resources, err := rm.generateTopicNames(statements)
+	if err != nil {
+		return nil, err
+	}
+	sqlTopics := ExtractTopics(statements)
+	resources.Sources = resolveSourceTopics(ExtractSourceTables(statements), sqlTopics, resources)
+	resources.Sinks = resolveSinkTopics(ExtractSinkTables(statements), sqlTopics, resources)
+	return resources, nil
+}
+
+// generateTopicNames creates topic names based on mode and SQL statements
+func (rm *ResourceManager) generateTopicNames(statements []*types.SQLStatement) (*Resources, error) {
 	// First, try to extract topics from SQL statements
-	sqlTopics := rm.extractTopicsFromSQL(statements)
+	sqlTopics := ExtractTopics(statements)
 
 	if rm.config.LocalMode {
 		if len(sqlTopics) > 0 {
@@ -125,64 +138,6 @@ func (rm *ResourceManager) GenerateResources(statements []*types.SQLStatement) (
 	return resources, nil
 }
 
-// extractTopicsFromSQL extracts topic names from CREATE TABLE statements
-func (rm *ResourceManager) extractTopicsFromSQL(statements []*types.SQLStatement) []string {
-	var topics []string
-	topicSet := make(map[string]bool) // Use map to avoid duplicates
-
-	for _, stmt := range statements {
-		// Look for CREATE TABLE statements
-		if strings.Contains(strings.ToUpper(stmt.Content), "CREATE TABLE") {
-			topic := rm.extractTopicFromCreateTable(stmt.Content)
-			if topic != "" && !topicSet[topic] {
-				topics = append(topics, topic)
-				topicSet[topic] = true
-			}
-		}
-	}
-
-	return topics
-}
-
-// extractTopicFromCreateTable extracts topic name from a CREATE TABLE statement
-func (rm *ResourceManager) extractTopicFromCreateTable(sql string) string {
-	// Look for 'topic' = 'value' pattern
-	lines := strings.Split(sql, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.Contains(line, "'topic'") && strings.Contains(line, "=") {
-			// Use regex to extract the topic value more reliably
-			re := regexp.MustCompile(`'topic'\s*=\s*'([^']+)'`)
-			matches := re.FindStringSubmatch(line)
-			if len(matches) > 1 {
-				return matches[1]
-			}
-
-			// Fallback to the old method if regex fails
-			parts := strings.Split(line, "'topic'")
-			if len(parts) > 1 {
-				afterTopic := parts[1]
-				if strings.Contains(afterTopic, "=") {
-					valueParts := strings.Split(afterTopic, "=")
-					if len(valueParts) > 1 {
-						value := strings.TrimSpace(valueParts[1])
-						// Find the content between single quotes
-						start := strings.Index(value, "'")
-						if start != -1 {
-							end := strings.Index(value[start+1:], "'")
-							if end != -1 {
-								return value[start+1 : start+1+end]
-							}
-						}
-					}
-				}
-			}
-		}
-	}
-
-	return ""
-}
-
 // CreateTopics creates the required Kafka topics
 func (rm *ResourceManager) CreateTopics(ctx context.Context, resources *Resources) error {
 	fmt.Printf("🔧 Creating topics with prefix: %s\n", resources.Prefix)
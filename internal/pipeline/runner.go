@@ -37,19 +37,37 @@ type Config struct {
 	FlinkURL          string
 	SchemaRegistryURL string
 	LocalMode         bool
-	GenerateReport    bool             // New field to enable report generation
-	ReportsDir        string           // Directory to save reports
-	TrafficPatterns   *TrafficPatterns // Traffic patterns for dynamic rate changes
-	KafkaConfig       KafkaConfig      // Kafka topic configuration
-	GlobalTables      bool             // New field to enable global table creation mode
-	CSVMode           bool             // When true, skip Kafka producer ONLY (filesystem CSV source table); consumer still runs
+	GenerateReport    bool                        // New field to enable report generation
+	ReportsDir        string                      // Directory to save reports
+	TrafficPatterns   *TrafficPatterns            // Traffic patterns for dynamic rate changes
+	KafkaConfig       KafkaConfig                 // Kafka topic configuration
+	GlobalTables      bool                        // New field to enable global table creation mode
+	ParallelDeploy    bool                        // Deploy statements that do not depend on each other at the same time
+	Variables         *Variables                  // User-defined SQL variables (nil resolves the built-in ones only)
+	CSVMode           bool                        // When true, skip Kafka producer ONLY (filesystem CSV source table); consumer still runs
+	Replay            *ReplayConfig               // When set, the producer replays recorded records instead of generating them
+	KeyStrategy       *KeyStrategyConfig          // Message key strategy (nil keeps unique key-<n> keys)
+	Sources           map[string]*SourceConfig    // Per-source overrides keyed by table or topic name
+	Sinks             map[string]*SinkConfig      // Per-sink overrides keyed by table or topic name
+	Disorder          *DisorderConfig             // Out-of-order and late event injection for the primary source
+	Faults            *FaultConfig                // Poison message injection for the primary source
+	ProducerWorkers   int                         // Concurrent Kafka writers per producer (default 4)
+	ProducerBatchSize int                         // Maximum messages per write (default 500)
+	Seed              int64                       // Random seed of data generation (picked at random unless Reproducible)
+	Reproducible      bool                        // Seed given explicitly: generated timestamps come from a virtual clock
+	Expectations      *Expectations               // Golden output the run is asserted against (--assert)
+	LatencyField      string                      // Input field stamped with the produce time for end-to-end latency ("" uses the header only)
+	Validation        map[string][]ValidationRule // Output validation rules keyed by output table or topic
+	Reconciliation    *ReconciliationConfig       // Accounting of lost and duplicated input records in the primary output
+	DLQTopic          string                      // Kafka topic the output records that fail processing are forwarded to ("" keeps them in the dead-letter file only)
 }
 
 // Runner orchestrates the complete pipeline execution
 type Runner struct {
 	config        *Config
 	resourceMgr   *ResourceManager
-	producer      *Producer
+	producer      *Producer         // Producer of the primary source (InputTopic)
+	sources       []*sourceProducer // One producer per source topic, primary first
 	consumer      *Consumer
 	flinkDeployer *FlinkDeployer
 	sqlLoader     *SQLLoader
@@ -123,6 +141,9 @@ type ConsumerStatistics struct {
 
 // NewRunner creates a new pipeline runner
 func NewRunner(config *Config) (*Runner, error) {
+	if !config.Reproducible && config.Seed == 0 {
+		config.Seed = NewRunSeed()
+	}
 	resourceMgr := NewResourceManager(config)
 
 	producer, err := NewProducer(config)
@@ -180,13 +201,18 @@ func (r *Runner) Run(ctx context.Context) error {
 
 	// Track pipeline start time for reporting
 	pipelineStartTime := time.Now()
+	if r.config.Reproducible {
+		fmt.Printf("🎲 Seed: %d (reproducible data, virtual clock from %s)\n", r.config.Seed, seededEpoch.Format("2006-01-02"))
+	} else {
+		fmt.Printf("🎲 Seed: %d (rerun with --seed %d for the same non-time values; timestamps then follow a virtual clock)\n", r.config.Seed, r.config.Seed)
+	}
 
 	// Initialize execution data collector if report generation is enabled
+	// The execution ID also names the report directory of the dead-letter file
 	var dataCollector interface{}
-	var executionID string
+	executionID := r.generateExecutionID()
 
 	if r.config.GenerateReport {
-		executionID = r.generateExecutionID()
 		fmt.Printf("📊 Execution ID: %s\n", executionID)
 
 		// Create basic data collector
@@ -202,6 +228,7 @@ func (r *Runner) Run(ctx context.Context) error {
 				"local_mode":          r.config.LocalMode,
 				"project_dir":         r.config.ProjectDir,
 				"cleanup":             r.config.Cleanup,
+				"seed":                r.config.Seed,
 			},
 		}
 	}
@@ -212,10 +239,14 @@ func (r *Runner) Run(ctx context.Context) error {
 	if err != nil {
 		return fmt.Errorf("failed to load SQL statements: %w", err)
 	}
+	// Topics can be named by variables, so resolve them before anything reads the SQL
+	if sqlStatements, err = r.config.Variables.ResolveStatements(sqlStatements); err != nil {
+		return err
+	}
 	fmt.Printf("✅ Loaded %d SQL statements\n", len(sqlStatements))
 
-	// Step 2: Load AVRO schemas (optional when topics are defined in SQL)
-	fmt.Println("📋 Loading AVRO schemas...")
+	// Step 2: Load AVRO, Protobuf and JSON schemas (optional when topics are defined in SQL)
+	fmt.Println("📋 Loading schemas...")
 
 	// Check if we have topics defined in SQL statements
 	sqlTopics := r.sqlLoader.ExtractTopicsFromSQL(sqlStatements)
@@ -244,7 +275,44 @@ func (r *Runner) Run(ctx context.Context) error {
 		if err != nil {
 			return fmt.Errorf("failed to load schemas: %w", err)
 		}
-		fmt.Printf("✅ Loaded %d AVRO schemas\n", len(schemas))
+		fmt.Printf("✅ Loaded %d schemas\n", len(schemas))
+	}
+
+	// Load declarative field generators (optional schemas/generators.yaml) and fail fast on bad specs
+	generatorsFile, err := LoadGeneratorsFile(r.config.ProjectDir)
+	if err != nil {
+		return fmt.Errorf("failed to load field generators: %w", err)
+	}
+	if !r.config.CSVMode {
+		if err := generatorsFile.Validate(schemas); err != nil {
+			return fmt.Errorf("invalid field generators: %w", err)
+		}
+
+		if r.config.KeyStrategy != nil && len(r.config.KeyStrategy.Fields) > 0 {
+			if _, err := NewKeySchema(schemas["input"], r.config.KeyStrategy.Fields); err != nil {
+				return fmt.Errorf("invalid key strategy: %w", err)
+			}
+		}
+		if r.config.Disorder.IsEnabled() {
+			if _, err := newEventDisorder(r.config.Disorder, schemas["input"], nil); err != nil {
+				return fmt.Errorf("invalid event-time disorder: %w", err)
+			}
+		}
+		if r.config.Faults.IsEnabled() {
+			if _, err := newFaultInjector(r.config.Faults, schemas["input"], nil); err != nil {
+				return fmt.Errorf("invalid fault injection: %w", err)
+			}
+		}
+		if r.config.LatencyField != "" {
+			if _, err := newLatencyStamp(schemas["input"], r.config.LatencyField); err != nil {
+				return err
+			}
+		}
+		if r.config.Reconciliation.IsEnabled() {
+			if _, err := newRecordLedger(r.config.Reconciliation, schemas["input"], 0); err != nil {
+				return fmt.Errorf("invalid reconciliation: %w", err)
+			}
+		}
 	}
 
 	// Step 3: Generate dynamic resource names
@@ -255,6 +323,31 @@ func (r *Runner) Run(ctx context.Context) error {
 	}
 	fmt.Printf("✅ Generated resources with prefix: %s\n", resources.Prefix)
 
+	// Set up one producer per source topic (joins need data on every input)
+	if !r.config.CSVMode {
+		r.sources, err = r.prepareSourceProducers(resources, schemas, generatorsFile)
+		if err != nil {
+			return fmt.Errorf("failed to prepare source producers: %w", err)
+		}
+		if len(r.sources) > 1 {
+			fmt.Printf("📤 Producing to %d source topics:\n", len(r.sources))
+			for _, sp := range r.sources {
+				fmt.Printf("  - %s -> %s (%d msg/sec)\n", sp.source.Table, sp.source.Topic, sp.producer.config.MessageRate)
+			}
+		}
+
+		// Keep the IDs of the primary source to find lost and duplicated records in the output
+		if r.config.Reconciliation.IsEnabled() && r.producer != nil && len(r.sources) > 0 {
+			expected := int64(float64(r.producer.config.MessageRate) * r.config.Duration.Seconds())
+			ledger, err := newRecordLedger(r.config.Reconciliation, r.sources[0].schema, expected)
+			if err != nil {
+				return fmt.Errorf("invalid reconciliation: %w", err)
+			}
+			r.producer.ledger = ledger
+			fmt.Printf("📒 Reconciling %s of %s with the output\n", ledger.report.IDField, r.sources[0].source.Topic)
+		}
+	}
+
 	// Step 4: Clean up existing topics before creation
 	fmt.Println("🧹 Cleaning up existing topics...")
 	if err := r.resourceMgr.DeleteTopics(ctx, resources); err != nil {
@@ -272,15 +365,34 @@ func (r *Runner) Run(ctx context.Context) error {
 
 	// Step 6: Deploy FlinkSQL statements (Flink will auto-register schemas)
 	fmt.Println("⚡ Deploying FlinkSQL statements...")
+	// The session stays open, with heartbeats, until the pipeline is done; cleanup closes it too
+	defer func() {
+		if err := r.flinkDeployer.Close(context.Background()); err != nil {
+			fmt.Printf("⚠️  Warning: %v\n", err)
+		}
+	}()
 	deploymentIDs, err := r.flinkDeployer.Deploy(ctx, sqlStatements, resources)
+
+	// Set up deferred cleanup to ensure it runs even if there are errors. A failed deployment
+	// returns the statements deployed before it failed, which are cleaned up as well.
+	defer func() {
+		if r.config.Cleanup {
+			fmt.Println("🧹 Cleaning up resources...")
+			if err := r.cleanup(context.Background(), resources, deploymentIDs); err != nil {
+				fmt.Printf("⚠️  Warning: cleanup failed: %v\n", err)
+			} else {
+				fmt.Println("✅ Cleanup completed")
+			}
+		}
+	}()
 	if err != nil {
 		return fmt.Errorf("failed to deploy FlinkSQL: %w", err)
 	}
 	fmt.Printf("✅ Deployed %d FlinkSQL statements\n", len(deploymentIDs))
 
-	// Step 7: Register additional AVRO schemas (only if manually provided)
+	// Step 7: Register additional schemas (only if manually provided)
 	if len(schemas) > 0 {
-		fmt.Printf("📋 Registering %d additional AVRO schemas...\n", len(schemas))
+		fmt.Printf("📋 Registering %d additional schemas...\n", len(schemas))
 		if err := r.resourceMgr.RegisterSchemas(ctx, resources, schemas); err != nil {
 			fmt.Printf("⚠️  Warning: failed to register additional schemas: %v\n", err)
 			fmt.Println("Flink should have auto-registered schemas from table definitions")
@@ -291,18 +403,6 @@ func (r *Runner) Run(ctx context.Context) error {
 		fmt.Println("📋 No manual schemas to register - Flink will auto-register schemas from table definitions")
 	}
 
-	// Set up deferred cleanup to ensure it runs even if there are errors
-	defer func() {
-		if r.config.Cleanup {
-			fmt.Println("🧹 Cleaning up resources...")
-			if err := r.cleanup(context.Background(), resources, deploymentIDs); err != nil {
-				fmt.Printf("⚠️  Warning: cleanup failed: %v\n", err)
-			} else {
-				fmt.Println("✅ Cleanup completed")
-			}
-		}
-	}()
-
 	// Step 7: Wait for Flink jobs to be ready and start processing
 	fmt.Println("⏳ Waiting for Flink jobs to initialize and start processing...")
 	time.Sleep(3 * time.Second) // Give Flink jobs time to initialize
@@ -314,14 +414,18 @@ func (r *Runner) Run(ctx context.Context) error {
 	} else {
 		// Step 8: Start producer (runs for specified duration)
 		fmt.Println("📤 Starting Kafka producer...")
-		fmt.Printf("⏱️  Producer will run for %v at %d msg/sec\n", r.config.Duration, r.config.MessageRate)
+		if r.config.Replay != nil {
+			fmt.Printf("⏱️  Producer will replay %s for up to %v\n", r.config.Replay.Path, r.config.Duration)
+		} else {
+			fmt.Printf("⏱️  Producer will run for %v at %d msg/sec\n", r.config.Duration, r.config.MessageRate)
+		}
 
 		producerCtx, cancelProducer := context.WithTimeout(pipelineCtx, r.config.Duration)
 		defer cancelProducer()
 
 		producerDone := make(chan error, 1)
 		go func() {
-			producerDone <- r.producer.Start(producerCtx, resources.InputTopic, schemas["input"])
+			producerDone <- r.startSourceProducers(producerCtx)
 		}()
 
 		// Step 9: Monitor Flink job metrics during execution
@@ -350,10 +454,15 @@ func (r *Runner) Run(ctx context.Context) error {
 			fmt.Println("🛑 Pipeline timeout reached while waiting for Flink processing")
 			return pipelineCtx.Err()
 		}
-		return fmt.Errorf("failed waiting for Flink processing: %w", err)
+		if r.producer == nil || r.producer.faults == nil {
+			return fmt.Errorf("failed waiting for Flink processing: %w", err)
+		}
+		// A failing job is a legitimate outcome of fault injection; keep going so it gets reported
+		fmt.Printf("⚠️  Flink did not process the injected faults cleanly: %v\n", err)
 	}
 
 	// Step 12: Start consumer (always run; in CSV mode it will observe Flink sink output topic if applicable)
+	var output *outputRecorder
 	consumerCompleted := false
 	var consumerDone chan error
 	if r.config.CSVMode {
@@ -364,7 +473,10 @@ func (r *Runner) Run(ctx context.Context) error {
 
 		// Calculate expected messages if not explicitly set
 		if r.config.ExpectedMessages == 0 {
-			if r.config.CSVMode {
+			if r.config.Expectations != nil {
+				r.config.ExpectedMessages = int64(len(r.config.Expectations.Records))
+				fmt.Printf("📊 Expecting %d messages from %s\n", r.config.ExpectedMessages, r.config.Expectations.Expected)
+			} else if r.config.CSVMode {
 				// In CSV mode we can't estimate from producer; use a reasonable default (all rows processed by Flink)
 				// We'll keep it zero meaning consumer will just read until timeout or completion of Flink job.
 				fmt.Println("📊 CSV mode: no expected message count derived (set --expected if you want bounded consume).")
@@ -384,10 +496,54 @@ func (r *Runner) Run(ctx context.Context) error {
 			fmt.Printf("📊 Expecting %d messages (configured)\n", r.config.ExpectedMessages)
 		}
 
+		// Read every sink of the pipeline in one consumer group
+		sinkTopics := r.addConsumerSinks(resources)
+
 		// Initialize consumer with Schema Registry
-		if err := r.consumer.InitializeSchemaRegistry(resources.OutputTopic); err != nil {
+		if err := r.consumer.InitializeSchemaRegistry(sinkTopics...); err != nil {
 			fmt.Printf("⚠️  Warning: Failed to initialize consumer schema registry: %v\n", err)
-			fmt.Println("Consumer will run without AVRO deserialization")
+			fmt.Println("Consumer will decode output with each message's writer schema only")
+		}
+
+		// Check the output records against the validation rules of their topic
+		validated := 0
+		for _, sink := range resources.Sinks {
+			rules := RulesFor(r.config.Validation, sink.Topic, resources.Sinks)
+			if len(rules) == 0 {
+				continue
+			}
+			validator, err := NewRecordValidator(sink.Topic, rules)
+			if err != nil {
+				return fmt.Errorf("invalid validation rules: %w", err)
+			}
+			r.consumer.SetValidator(validator)
+			validated++
+			fmt.Printf("🧪 Validating output records of %s with %d rule(s)\n", sink.Topic, len(rules))
+		}
+		if validated == 0 && len(r.config.Validation) > 0 {
+			fmt.Printf("⚠️  No validation rules match output topics %s\n", strings.Join(sinkTopics, ", "))
+		}
+
+		// Look for late events in the output
+		if r.producer != nil && r.producer.disorder != nil {
+			r.consumer.AddRecordObserver(r.producer.disorder.ObserveOutput)
+		}
+
+		// Account for every input record in the output
+		if r.producer != nil && r.producer.ledger != nil {
+			r.consumer.AddRecordObserver(r.producer.ledger.ObserveOutput)
+		}
+
+		// Keep the output records to compare them with the expectations
+		if r.config.Expectations != nil {
+			output = &outputRecorder{}
+			r.consumer.AddRecordObserver(output.observe)
+		}
+
+		// Capture the output records that fail processing
+		r.consumer.CaptureDeadLetters(filepath.Join(r.reportsDir(), executionID, DeadLetterFile), r.config.DLQTopic)
+		if r.config.DLQTopic != "" {
+			fmt.Printf("☠️  Forwarding records that fail processing to %s\n", r.config.DLQTopic)
 		}
 
 		// Start consumer with smart stopping logic
@@ -431,22 +587,388 @@ func (r *Runner) Run(ctx context.Context) error {
 		}
 	}
 
+	// Show which schema versions the output was written with and how long records took through the pipeline
+	if r.consumer != nil {
+		stats := r.consumer.GetStats()
+		if len(stats.SchemaIDs) > 0 {
+			fmt.Printf("🧬 Output schema IDs: %s\n", stats.SchemaIDSummary())
+		}
+		if stats.EndToEnd != nil {
+			fmt.Printf("⏱️  End-to-end latency: %s\n", stats.EndToEnd.Summary())
+		} else if stats.MessagesConsumed > 0 {
+			fmt.Printf("⏱️  No output record carried its produce time; forward the %s header or pass a field with --latency-field\n", ProducedAtHeader)
+		}
+		if len(stats.Sinks) > 1 {
+			fmt.Println("📤 Output topics:")
+			for _, sink := range stats.Sinks {
+				fmt.Printf("   %s\n", sink.Summary())
+			}
+		}
+	}
+
+	// Tell where the records that failed processing went
+	var deadLetters *DeadLetterReport
+	if r.consumer != nil {
+		if deadLetters, err = r.consumer.CloseDeadLetters(); err != nil {
+			fmt.Printf("⚠️  %v\n", err)
+		}
+		if deadLetters != nil {
+			fmt.Printf("☠️  Dead letters: %s\n", deadLetters.Summary())
+		}
+	}
+
+	// Report which output records broke the validation rules
+	if r.consumer != nil {
+		for _, validation := range r.consumer.ValidationReports() {
+			validation.Print(5)
+		}
+	}
+
+	// Report what the pipeline did with the injected faults
+	var faultReport *FaultReport
+	if r.producer != nil && r.producer.faults != nil {
+		faultReport = r.reportFaults()
+	}
+
+	// Record which events were disordered and how many late events reached the output
+	if r.producer != nil && r.producer.disorder != nil {
+		r.reportDisorder()
+	}
+
+	// Tell which input records were lost or duplicated on the way
+	var reconciliation *ReconciliationReport
+	if r.producer != nil && r.producer.ledger != nil {
+		reconciliation = r.producer.ledger.Report()
+		reconciliation.Print(5)
+	}
+
+	// Compare what Flink computed with the expected output
+	var assertions *AssertionReport
+	if output != nil {
+		assertions = r.config.Expectations.Check(output.snapshot())
+		assertions.Print(20)
+	}
+
 	// Step 14: Generate execution report if enabled
 	actualDuration := time.Since(pipelineStartTime)
 	finalStatus := "completed"
 	if pipelineCtx.Err() != nil {
 		finalStatus = "timeout"
+	} else if assertions != nil && !assertions.Passed {
+		finalStatus = "failed"
 	}
 
 	if dataCollector != nil {
-		if err := r.generateExecutionReport(dataCollector, finalStatus, actualDuration, resources, schemas); err != nil {
+		if err := r.generateExecutionReport(dataCollector, finalStatus, actualDuration, resources, schemas, faultReport, assertions, deadLetters); err != nil {
 			fmt.Printf("⚠️  Warning: failed to generate execution report: %v\n", err)
 		}
 	}
 
+	if assertions != nil && !assertions.Passed {
+		return fmt.Errorf("output assertions failed: %d mismatch(es)", assertions.Failures)
+	}
+	return nil
+}
+
+// addConsumerSinks subscribes the consumer to every sink topic and returns the topics. The primary sink
+// expects ExpectedMessages; the sinks section can set the expected count of any sink.
+func (r *Runner) addConsumerSinks(resources *Resources) []string {
+	sinks := resources.Sinks
+	if len(sinks) == 0 {
+		sinks = []SourceTable{{Table: "output", Topic: resources.OutputTopic}}
+	}
+
+	topics := make([]string, len(sinks))
+	for i, sink := range sinks {
+		var expected int64
+		if i == 0 {
+			expected = r.config.ExpectedMessages
+		}
+		if override := lookupSinkConfig(r.config.Sinks, sink); override != nil && override.ExpectedMessages > 0 {
+			expected = override.ExpectedMessages
+		}
+		r.consumer.AddSink(sink, expected)
+		topics[i] = sink.Topic
+	}
+	if len(sinks) > 1 {
+		fmt.Printf("📤 Consuming %d output topics: %s\n", len(sinks), strings.Join(topics, ", "))
+	}
+	return topics
+}
+
+// sourceProducer feeds one source topic of the pipeline
+type sourceProducer struct {
+	source   SourceTable
+	schema   *Schema
+	producer *Producer
+}
+
+// prepareSourceProducers sets up one producer per source topic. The primary source keeps the runner's
+// producer (with replay and the key strategy); every other source gets its own producer, schema, rate and
+// traffic pattern. References between sources are wired once all producers exist.
+func (r *Runner) prepareSourceProducers(resources *Resources, schemas map[string]*Schema, generatorsFile *GeneratorsFile) ([]*sourceProducer, error) {
+	sources := resources.Sources
+	if len(sources) == 0 {
+		sources = []SourceTable{{Table: "input", Topic: resources.InputTopic}}
+	}
+
+	var producers []*sourceProducer
+	for i, source := range sources {
+		primary := i == 0
+		override := lookupSourceConfig(r.config.Sources, source)
+
+		schemaKey, schema := sourceSchema(source, override, schemas, primary)
+		if override != nil && override.Schema != "" && schema == nil {
+			return nil, fmt.Errorf("source %s: schema %q not found in schemas/", source.Table, override.Schema)
+		}
+		if schema == nil && !primary {
+			fmt.Printf("⚠️  No schema for source table %s (add schemas/%s.avsc or set sources.%s.schema); it will not receive data\n",
+				source.Table, normalizeTableName(source.Table), normalizeTableName(source.Table))
+			continue
+		}
+
+		specs := generatorsFile.SpecsFor(schemaKey, schema)
+		if !primary {
+			if err := ValidateFieldGenerators(schema, specs); err != nil {
+				return nil, fmt.Errorf("source %s: invalid field generators: %w", source.Table, err)
+			}
+		}
+
+		producer := r.producer
+		if !primary || override != nil {
+			cfg, err := r.sourceConfig(override, primary)
+			if err != nil {
+				return nil, fmt.Errorf("source %s: %w", source.Table, err)
+			}
+			if !primary {
+				cfg.Seed = sourceSeed(cfg.Seed, source.Table)
+			}
+			if producer, err = NewProducer(cfg); err != nil {
+				return nil, fmt.Errorf("source %s: failed to create producer: %w", source.Table, err)
+			}
+			producer.secondary = !primary
+			if primary {
+				r.producer = producer
+			}
+		}
+		producer.SetGeneratorSpecs(specs)
+		producers = append(producers, &sourceProducer{source: source, schema: schema, producer: producer})
+	}
+
+	for _, sp := range producers {
+		override := lookupSourceConfig(r.config.Sources, sp.source)
+		if override == nil {
+			continue
+		}
+		for _, ref := range override.References {
+			if err := wireReference(sp, ref, producers); err != nil {
+				return nil, fmt.Errorf("source %s: %w", sp.source.Table, err)
+			}
+			fmt.Printf("🔗 %s.%s references %s.%s (%.0f%% match)\n", sp.source.Table, ref.Field, ref.Source, ref.SourceField, ref.Ratio()*100)
+		}
+	}
+	return producers, nil
+}
+
+// sourceSchema picks the schema for a source: the configured schema, then "input" for the primary
+// source, then a schema file named after the table or topic
+func sourceSchema(source SourceTable, override *SourceConfig, schemas map[string]*Schema, primary bool) (string, *Schema) {
+	if override != nil && override.Schema != "" {
+		return override.Schema, schemas[override.Schema]
+	}
+	if schema, ok := schemas["input"]; ok && primary {
+		return "input", schema
+	}
+	for _, key := range []string{source.Table, normalizeTableName(source.Table), source.Topic} {
+		if schema, ok := schemas[key]; ok {
+			return key, schema
+		}
+	}
+	if primary {
+		return "input", nil
+	}
+	return "", nil
+}
+
+// sourceConfig derives the producer configuration of a source from the run configuration. Sources
+// without their own traffic pattern follow the --traffic-pattern peaks relative to their own rate.
+func (r *Runner) sourceConfig(override *SourceConfig, primary bool) (*Config, error) {
+	cfg := *r.config
+	if override != nil && override.MessageRate > 0 {
+		cfg.MessageRate = override.MessageRate
+	}
+	if override != nil && override.TrafficPattern != "" {
+		patterns, err := ParseTrafficPattern(override.TrafficPattern, cfg.MessageRate)
+		if err != nil {
+			return nil, fmt.Errorf("invalid traffic pattern: %w", err)
+		}
+		cfg.TrafficPatterns = patterns
+	} else if r.config.TrafficPatterns != nil {
+		cfg.TrafficPatterns = &TrafficPatterns{BaseRate: cfg.MessageRate, Patterns: r.config.TrafficPatterns.Patterns}
+	}
+	if !primary {
+		cfg.Replay = nil
+		cfg.KeyStrategy = nil
+		cfg.Disorder = nil
+		cfg.Faults = nil
+		cfg.LatencyField = ""
+	}
+	return &cfg, nil
+}
+
+// wireReference connects a referencing field to the pool of values produced to the referenced source
+func wireReference(sp *sourceProducer, ref ReferenceConfig, producers []*sourceProducer) error {
+	field, ok := schemaField(sp.schema, ref.Field)
+	if !ok {
+		return fmt.Errorf("reference field %s not found in schema", ref.Field)
+	}
+
+	var target *sourceProducer
+	for _, other := range producers {
+		if other != sp && matchesSource(ref.Source, other.source) {
+			target = other
+			break
+		}
+	}
+	if target == nil {
+		return fmt.Errorf("referenced source %s is not produced by this run", ref.Source)
+	}
+	targetField, ok := schemaField(target.schema, ref.SourceField)
+	if !ok {
+		return fmt.Errorf("referenced field %s not found in the schema of %s", ref.SourceField, ref.Source)
+	}
+
+	base, logical, _ := resolveGeneratorTarget(field.Type)
+	targetBase, targetLogical, _ := resolveGeneratorTarget(targetField.Type)
+	if base != targetBase || logical != targetLogical {
+		return fmt.Errorf("field %s (%s) cannot reference %s.%s (%s)", ref.Field, base, ref.Source, ref.SourceField, targetBase)
+	}
+
+	sp.producer.addReference(field, target.producer.publish(ref.SourceField), ref.Ratio())
 	return nil
 }
 
+// schemaField looks up a top-level field of a schema
+func schemaField(schema *Schema, name string) (SchemaField, bool) {
+	if schema == nil {
+		return SchemaField{}, false
+	}
+	for _, f := range schema.Fields {
+		if f.Name == name {
+			return f, true
+		}
+	}
+	return SchemaField{}, false
+}
+
+// startSourceProducers runs every source producer until the context ends and returns the first failure
+func (r *Runner) startSourceProducers(ctx context.Context) error {
+	if len(r.sources) == 0 {
+		return fmt.Errorf("no source topic to produce to")
+	}
+
+	errs := make(chan error, len(r.sources))
+	for _, sp := range r.sources {
+		go func(sp *sourceProducer) {
+			err := sp.producer.Start(ctx, sp.source.Topic, sp.schema)
+			if err != nil && err != context.DeadlineExceeded && err != context.Canceled {
+				err = fmt.Errorf("%s: %w", sp.source.Table, err)
+			}
+			errs <- err
+		}(sp)
+	}
+
+	var result error
+	for range r.sources {
+		err := <-errs
+		if err != nil && (result == nil || result == context.DeadlineExceeded || result == context.Canceled) {
+			result = err
+		}
+	}
+
+	for _, sp := range r.sources {
+		if sp.producer != r.producer {
+			sp.producer.Close()
+		}
+	}
+	return result
+}
+
+// reportFaults collects what the pipeline did with the injected faults and prints a summary
+func (r *Runner) reportFaults() *FaultReport {
+	jobStates, err := r.getFlinkJobStates()
+	if err != nil {
+		fmt.Printf("⚠️  Failed to fetch Flink job states: %v\n", err)
+	}
+	report := newFaultReport(r.config.Faults, r.producer.faults.Stats(), r.producer.GetStats().MessagesSent, r.consumer.GetStats().MessagesConsumed, jobStates)
+
+	fmt.Println("💥 Injected faults:")
+	for _, s := range report.Stats {
+		fmt.Printf("   %-15s %d sent, %d rejected by Kafka\n", s.Kind, s.Injected, s.Rejected)
+	}
+	fmt.Printf("   Flink jobs: %s\n", report.JobStateSummary())
+	fmt.Printf("   %s\n", report.Outcome)
+	return report
+}
+
+// getFlinkJobStates counts Flink jobs per state
+func (r *Runner) getFlinkJobStates() (map[string]int, error) {
+	resp, err := http.Get(fmt.Sprintf("%s/jobs", r.config.FlinkURL))
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Flink jobs: %w", err)
+	}
+	defer func() {
+		if err := resp.Body.Close(); err != nil {
+			fmt.Printf("failed to close response body: %v\n", err)
+		}
+	}()
+
+	var jobsResp struct {
+		Jobs []struct {
+			ID     string `json:"id"`
+			Status string `json:"status"`
+		} `json:"jobs"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&jobsResp); err != nil {
+		return nil, fmt.Errorf("failed to parse Flink jobs response: %w", err)
+	}
+
+	states := make(map[string]int)
+	for _, job := range jobsResp.Jobs {
+		states[job.Status]++
+	}
+	return states, nil
+}
+
+// reportsDir returns the directory reports and run artifacts are written to
+func (r *Runner) reportsDir() string {
+	if r.config.ReportsDir != "" {
+		return r.config.ReportsDir
+	}
+	return filepath.Join(r.config.ProjectDir, "reports")
+}
+
+// reportDisorder writes the disorder log next to the reports and prints the late event summary
+func (r *Runner) reportDisorder() {
+	reportsDir := r.reportsDir()
+	logPath := filepath.Join(reportsDir, fmt.Sprintf("disordered-events-%s.jsonl", time.Now().Format("20060102-150405")))
+	if err := os.MkdirAll(reportsDir, 0755); err != nil {
+		fmt.Printf("⚠️  Failed to create reports directory: %v\n", err)
+	} else if err := r.producer.disorder.WriteLog(logPath); err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+	} else {
+		fmt.Printf("🔀 Disordered events written to: %s\n", logPath)
+	}
+
+	stats := r.producer.disorder.Stats()
+	fmt.Printf("🔀 Event-time disorder: %d delayed, %d jittered, %d late\n", stats.Delayed, stats.Jittered, stats.Late)
+	if stats.IDField == "" {
+		fmt.Println("   No ID field to find late events in the output; set the disorder id_field")
+	} else if stats.Late > 0 {
+		fmt.Printf("   Late events in output: %d of %d (%d dropped)\n", stats.LateInOutput, stats.Late, stats.LateDropped())
+	}
+}
+
 // cleanup removes all created resources
 func (r *Runner) cleanup(ctx context.Context, resources *Resources, deploymentIDs []string) error {
 	// Stop FlinkSQL deployments
@@ -463,7 +985,7 @@ func (r *Runner) cleanup(ctx context.Context, resources *Resources, deploymentID
 }
 
 // generateExecutionReport creates and saves the final execution report
-func (r *Runner) generateExecutionReport(dataCollector interface{}, status string, duration time.Duration, resources *Resources, schemas map[string]*Schema) error {
+func (r *Runner) generateExecutionReport(dataCollector interface{}, status string, duration time.Duration, resources *Resources, schemas map[string]*Schema, faultReport *FaultReport, assertions *AssertionReport, deadLetters *DeadLetterReport) error {
 	if !r.config.GenerateReport {
 		return nil
 	}
@@ -498,6 +1020,7 @@ func (r *Runner) generateExecutionReport(dataCollector interface{}, status strin
 				"local_mode":          r.config.LocalMode,
 				"project_dir":         r.config.ProjectDir,
 				"cleanup":             r.config.Cleanup,
+				"seed":                r.config.Seed,
 			},
 			"status":    status,
 			"duration":  duration.String(),
@@ -506,10 +1029,7 @@ func (r *Runner) generateExecutionReport(dataCollector interface{}, status strin
 	}
 
 	// Set reports directory if not specified
-	reportsDir := r.config.ReportsDir
-	if reportsDir == "" {
-		reportsDir = filepath.Join(r.config.ProjectDir, "reports")
-	}
+	reportsDir := r.reportsDir()
 
 	// Create reports directory
 	fmt.Printf("📁 Reports will be saved to: %s\n", reportsDir)
@@ -524,7 +1044,7 @@ func (r *Runner) generateExecutionReport(dataCollector interface{}, status strin
 	reportPath := filepath.Join(reportsDir, filename)
 
 	// Create enhanced HTML report with actual metrics
-	htmlContent := r.generateEnhancedHTMLReport(reportData, status, duration, resources, schemas)
+	htmlContent := r.generateEnhancedHTMLReport(reportData, status, duration, resources, schemas, faultReport, assertions, deadLetters)
 
 	if err := os.WriteFile(reportPath, []byte(htmlContent), 0644); err != nil {
 		fmt.Printf("⚠️  Failed to write execution report: %v\n", err)
@@ -536,7 +1056,7 @@ func (r *Runner) generateExecutionReport(dataCollector interface{}, status strin
 }
 
 // generateEnhancedHTMLReport creates a comprehensive HTML report with metrics and logo
-func (r *Runner) generateEnhancedHTMLReport(reportData map[string]interface{}, status string, duration time.Duration, resources *Resources, schemas map[string]*Schema) string {
+func (r *Runner) generateEnhancedHTMLReport(reportData map[string]interface{}, status string, duration time.Duration, resources *Resources, schemas map[string]*Schema, faultReport *FaultReport, assertions *AssertionReport, deadLetters *DeadLetterReport) string {
 	// Get executable directory for path resolution
 	execPath, err := os.Executable()
 	if err != nil {
@@ -569,21 +1089,93 @@ func (r *Runner) generateEnhancedHTMLReport(reportData map[string]interface{}, s
 	var messagesProduced int64
 	var throughputProducer float64
 	var bytesProduced int64
+	var partitionDistribution []PartitionShare
+	var primaryStats *ProducerStats
 
 	if r.producer != nil {
 		producerStats := r.producer.GetStats()
+		primaryStats = producerStats
 		messagesProduced = producerStats.MessagesSent
 		throughputProducer = producerStats.MessagesPerSec
 		bytesProduced = producerStats.BytesSent
+		partitionDistribution = PartitionDistribution(producerStats.PartitionCounts)
+	}
+	// Add the additional source producers of multi-source pipelines
+	for _, sp := range r.sources {
+		if sp.producer == r.producer {
+			continue
+		}
+		sourceStats := sp.producer.GetStats()
+		messagesProduced += sourceStats.MessagesSent
+		throughputProducer += sourceStats.MessagesPerSec
+		bytesProduced += sourceStats.BytesSent
+	}
+
+	var disorderStats *DisorderStats
+	if r.producer != nil && r.producer.disorder != nil {
+		stats := r.producer.disorder.Stats()
+		disorderStats = &stats
+	}
+
+	var reconciliation *ReconciliationReport
+	if r.producer != nil && r.producer.ledger != nil {
+		reconciliation = r.producer.ledger.Report()
+	}
+
+	var rateChart *RateChart
+	trafficSummary := fmt.Sprintf("Constant rate: %d msg/sec", r.config.MessageRate)
+	if r.producer != nil {
+		rateChart = r.producer.RateChart()
+	}
+	if r.config.TrafficPatterns != nil && r.config.TrafficPatterns.HasPatterns() {
+		trafficSummary = r.config.TrafficPatterns.GetPatternSummary()
+	}
+
+	// End-to-end latency, on the same time axis as the producer rate, output validation and per-sink counts
+	var latencyChart *LatencyChart
+	var validation []*ValidationReport
+	var consumerStats *ConsumerStats
+	if r.consumer != nil {
+		validation = r.consumer.ValidationReports()
+		consumerStats = r.consumer.GetStats()
+		var origin time.Time
+		if r.producer != nil {
+			origin = r.producer.sendStart()
+		}
+		latencyChart = r.consumer.LatencyChart(origin)
+	}
+
+	keyStrategy := "unique key-<n> per message"
+	if r.config.KeyStrategy != nil {
+		keyStrategy = r.config.KeyStrategy.Summary()
 	}
 
-	messagesConsumed := int64(0)                                           // TODO: Get from actual consumer stats when implemented
-	throughputConsumer := float64(0)                                       // TODO: Get from actual consumer stats
-	successRate := 100.0                                                   // TODO: Calculate from actual metrics
-	errorCount := int64(0)                                                 // TODO: Get from actual metrics
-	avgLatency := "< 1ms"                                                  // TODO: Get from actual metrics
+	messagesConsumed := int64(0)
+	throughputConsumer := float64(0)
+	successRate := 100.0   // TODO: Calculate from actual metrics
+	errorCount := int64(0) // TODO: Get from actual metrics
+	var sinks []SinkStats
+	if consumerStats != nil {
+		messagesConsumed = consumerStats.MessagesConsumed
+		throughputConsumer = consumerStats.MessagesPerSec
+		sinks = consumerStats.Sinks
+	}
+	avgLatency := "< 1ms"
+	if latencyChart != nil {
+		avgLatency = fmt.Sprintf("%v end-to-end (p99 %v)", latencyChart.Stats.Mean.Round(time.Millisecond), latencyChart.Stats.P99.Round(time.Millisecond))
+	} else if primaryStats != nil && primaryStats.AverageLatency > 0 {
+		avgLatency = fmt.Sprintf("%v produce (p99 %v)", primaryStats.AverageLatency.Round(time.Microsecond), primaryStats.LatencyP99.Round(time.Microsecond))
+	}
 	dataVolume := fmt.Sprintf("%.2f MB", float64(bytesProduced)/1024/1024) // Use actual bytes from producer
 
+	// The dead-letter file is linked relative to the report, which is saved in the reports directory
+	deadLetterLink := ""
+	if deadLetters != nil {
+		if rel, err := filepath.Rel(r.reportsDir(), deadLetters.Path); err == nil {
+			deadLetterLink = filepath.ToSlash(rel)
+		}
+	}
+
 	// Prepare template data
 	templateData := struct {
 		ExecutionID        string
@@ -599,6 +1191,8 @@ func (r *Runner) generateEnhancedHTMLReport(reportData map[string]interface{}, s
 		ProjectDir         string
 		LocalMode          bool
 		Cleanup            bool
+		Seed               int64
+		Reproducible       bool
 		LogoSVG            template.HTML
 		MessagesProduced   int64
 		MessagesConsumed   int64
@@ -611,6 +1205,20 @@ func (r *Runner) generateEnhancedHTMLReport(reportData map[string]interface{}, s
 		TopicInfo          []TopicInfo
 		SchemaInfo         []SchemaInfo
 		FlinkJobs          []FlinkJobInfo
+		KeyStrategy        string
+		PartitionShares    []PartitionShare
+		Disorder           *DisorderStats
+		Faults             *FaultReport
+		Reconciliation     *ReconciliationReport
+		RateChart          *RateChart
+		LatencyChart       *LatencyChart
+		Validation         []*ValidationReport
+		Sinks              []SinkStats
+		TrafficSummary     string
+		ProducerStats      *ProducerStats
+		Assertions         *AssertionReport
+		DeadLetters        *DeadLetterReport
+		DeadLetterLink     string
 	}{
 		ExecutionID:        reportData["execution_id"].(string),
 		Status:             status,
@@ -625,6 +1233,8 @@ func (r *Runner) generateEnhancedHTMLReport(reportData map[string]interface{}, s
 		ProjectDir:         r.config.ProjectDir,
 		LocalMode:          r.config.LocalMode,
 		Cleanup:            r.config.Cleanup,
+		Seed:               r.config.Seed,
+		Reproducible:       r.config.Reproducible,
 		LogoSVG:            template.HTML(""), // Logo will be loaded from template or assets
 		MessagesProduced:   messagesProduced,
 		MessagesConsumed:   messagesConsumed,
@@ -637,6 +1247,20 @@ func (r *Runner) generateEnhancedHTMLReport(reportData map[string]interface{}, s
 		TopicInfo:          topicInfo,
 		SchemaInfo:         schemaInfo,
 		FlinkJobs:          metrics.FlinkJobs,
+		KeyStrategy:        keyStrategy,
+		PartitionShares:    partitionDistribution,
+		Disorder:           disorderStats,
+		Faults:             faultReport,
+		Reconciliation:     reconciliation,
+		RateChart:          rateChart,
+		LatencyChart:       latencyChart,
+		Validation:         validation,
+		Sinks:              sinks,
+		TrafficSummary:     trafficSummary,
+		ProducerStats:      primaryStats,
+		Assertions:         assertions,
+		DeadLetters:        deadLetters,
+		DeadLetterLink:     deadLetterLink,
 	}
 
 	// Execute template
@@ -818,8 +1442,8 @@ func (r *Runner) collectSchemaRegistryInformation(resources *Resources, schemas
 		// Generate subject name based on topic naming convention
 		subject := r.getSchemaSubject(resources, schemaName)
 
-		// Determine schema type (defaulting to AVRO since that's what pipegen uses)
-		schemaType := "AVRO"
+		// Schema Registry type of the schema: AVRO, PROTOBUF or JSON
+		schemaType := string(schema.RegistryType())
 
 		// For now, we'll use estimated values since we don't have direct access to Schema Registry API responses
 		// In a full implementation, these would come from actual Schema Registry API calls
@@ -904,6 +1528,9 @@ type PipelineStatus struct {
 		Rate         float64
 		Target       int
 		Elapsed      time.Duration
+		LatencyP50   time.Duration
+		LatencyP95   time.Duration
+		LatencyP99   time.Duration
 	}
 	Flink struct {
 		JobsRunning      int
@@ -917,6 +1544,7 @@ type PipelineStatus struct {
 		Errors            int64
 		Elapsed           time.Duration
 		Active            bool
+		Sinks             []SinkStats
 	}
 }
 
@@ -957,6 +1585,10 @@ func (r *Runner) displayPipelineStatus() {
 	if status.Producer.MessagesSent > 0 || status.Producer.Elapsed > 0 {
 		fmt.Printf("📈 Producer: %d messages sent (%.1f msg/sec avg, target: %d msg/sec, elapsed: %v)\n",
 			status.Producer.MessagesSent, status.Producer.Rate, status.Producer.Target, status.Producer.Elapsed.Truncate(time.Second))
+		if status.Producer.LatencyP99 > 0 {
+			fmt.Printf("   Produce latency: p50 %v, p95 %v, p99 %v\n",
+				status.Producer.LatencyP50.Round(time.Microsecond), status.Producer.LatencyP95.Round(time.Microsecond), status.Producer.LatencyP99.Round(time.Microsecond))
+		}
 	}
 
 	// Flink status
@@ -970,6 +1602,11 @@ func (r *Runner) displayPipelineStatus() {
 	if status.Consumer.Active {
 		fmt.Printf("📥 Consumer: %d messages processed (%.1f msg/sec avg, %d errors, elapsed: %v)\n",
 			status.Consumer.MessagesProcessed, status.Consumer.Rate, status.Consumer.Errors, status.Consumer.Elapsed.Truncate(time.Second))
+		if len(status.Consumer.Sinks) > 1 {
+			for _, sink := range status.Consumer.Sinks {
+				fmt.Printf("   %s\n", sink.Summary())
+			}
+		}
 	} else {
 		fmt.Printf("⏸️  Consumer: Not started (waiting for data)\n")
 	}
@@ -6,23 +6,39 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/riferrei/srclient"
+)
+
+// Serialization formats of schemas and the records written with them
+const (
+	SchemaFormatAvro     = "avro"
+	SchemaFormatProtobuf = "protobuf"
+	SchemaFormatJSON     = "json" // JSON Schema
 )
 
-// Schema represents an AVRO schema
+// Schema represents an AVRO schema. Protobuf and JSON Schema files are converted to the equivalent
+// AVRO record, so record generation works the same for every format.
 type Schema struct {
-	Name      string        `json:"name"`
-	Namespace string        `json:"namespace"`
-	Type      string        `json:"type"`
-	Content   string        `json:"-"` // Raw schema content
-	Fields    []SchemaField `json:"fields,omitempty"`
-	FilePath  string        `json:"-"`
+	Name       string        `json:"name"`
+	Namespace  string        `json:"namespace"`
+	Type       string        `json:"type"`
+	Content    string        `json:"-"` // Raw AVRO schema content, derived from Definition for other formats
+	Fields     []SchemaField `json:"fields,omitempty"`
+	FilePath   string        `json:"-"`
+	Format     string        `json:"-"` // SchemaFormatAvro (also when empty), SchemaFormatProtobuf or SchemaFormatJSON
+	Definition string        `json:"-"` // Raw .proto or JSON Schema content registered in Schema Registry
+
+	proto      *protoMessage          // Record message of Protobuf schemas
+	jsonRecord map[string]interface{} // AVRO record of JSON Schema schemas, which records are converted back to JSON with
 }
 
 // SchemaField represents a field in an AVRO schema
 type SchemaField struct {
-	Name string      `json:"name"`
-	Type interface{} `json:"type"`
-	Doc  string      `json:"doc,omitempty"`
+	Name      string         `json:"name"`
+	Type      interface{}    `json:"type"`
+	Doc       string         `json:"doc,omitempty"`
+	Generator *GeneratorSpec `json:"generator,omitempty"` // Optional custom "generator" attribute
 }
 
 // SchemaLoader handles loading and parsing AVRO schemas from files
@@ -58,8 +74,7 @@ func (loader *SchemaLoader) LoadSchemas() (map[string]*Schema, error) {
 			continue
 		}
 
-		// Support .avsc and .json files
-		if !strings.HasSuffix(entry.Name(), ".avsc") && !strings.HasSuffix(entry.Name(), ".json") {
+		if !IsSchemaFile(entry.Name()) {
 			continue
 		}
 
@@ -75,24 +90,124 @@ func (loader *SchemaLoader) LoadSchemas() (map[string]*Schema, error) {
 	}
 
 	if len(schemas) == 0 {
-		return nil, fmt.Errorf("no AVRO schema files found in %s", schemaDir)
+		return nil, fmt.Errorf("no schema files (.avsc, .json or .proto) found in %s", schemaDir)
 	}
 
-	fmt.Printf("📋 Loaded %d AVRO schemas from %s\n", len(schemas), schemaDir)
+	fmt.Printf("📋 Loaded %d schemas from %s\n", len(schemas), schemaDir)
 	for key, schema := range schemas {
-		fmt.Printf("  - %s: %s.%s\n", key, schema.Namespace, schema.Name)
+		fmt.Printf("  - %s: %s.%s (%s)\n", key, schema.Namespace, schema.Name, schema.SerializationFormat())
 	}
 
 	return schemas, nil
 }
 
-// loadSchema loads a single AVRO schema from a file
+// loadSchema loads a single AVRO, Protobuf or JSON Schema file
 func (loader *SchemaLoader) loadSchema(filePath string) (*Schema, error) {
 	content, err := os.ReadFile(filePath)
 	if err != nil {
 		return nil, fmt.Errorf("failed to read schema file: %w", err)
 	}
 
+	name := strings.TrimSuffix(filepath.Base(filePath), filepath.Ext(filePath))
+	schema, err := ParseSchema(content, DetectSchemaFormat(filePath, content), name)
+	if err != nil {
+		return nil, err
+	}
+	schema.FilePath = filePath
+
+	// Validate schema
+	if err := loader.validateSchema(schema); err != nil {
+		return nil, fmt.Errorf("schema validation failed: %w", err)
+	}
+
+	return schema, nil
+}
+
+// DetectSchemaFormat tells the format of a schema from its file extension, then from its content:
+// .proto files are Protobuf, JSON objects without AVRO "fields" are JSON Schema
+func DetectSchemaFormat(filename string, content []byte) string {
+	if strings.HasSuffix(filename, ".proto") {
+		return SchemaFormatProtobuf
+	}
+	var definition map[string]interface{}
+	if json.Unmarshal(content, &definition) != nil {
+		if strings.Contains(string(content), "message ") {
+			return SchemaFormatProtobuf
+		}
+		return SchemaFormatAvro
+	}
+	if isJSONSchema(definition) {
+		return SchemaFormatJSON
+	}
+	return SchemaFormatAvro
+}
+
+// SchemaFileExtension returns the file extension schemas of a format are saved with
+func SchemaFileExtension(format string) string {
+	switch format {
+	case SchemaFormatProtobuf:
+		return ".proto"
+	case SchemaFormatJSON:
+		return ".json"
+	}
+	return ".avsc"
+}
+
+// IsSchemaFile reports whether a file name has one of the schema extensions the loader reads
+func IsSchemaFile(filename string) bool {
+	return strings.HasSuffix(filename, ".avsc") || strings.HasSuffix(filename, ".json") || strings.HasSuffix(filename, ".proto")
+}
+
+// ParseSchema parses schema content of the given format. Protobuf and JSON Schema definitions are kept
+// for registration and converted to the equivalent AVRO record; name names records without a title.
+func ParseSchema(content []byte, format, name string) (*Schema, error) {
+	var record *protoMessage
+	switch format {
+	case SchemaFormatProtobuf:
+		file, err := parseProtoSchema(string(content))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse Protobuf schema: %w", err)
+		}
+		// The first message is the record, as Confluent serializers and message index [0] assume
+		record = file.messages[0]
+		avro, err := json.Marshal(record.avroRecord(make(map[string]bool)))
+		if err != nil {
+			return nil, err
+		}
+		schema, err := parseAVROSchema(avro)
+		if err != nil {
+			return nil, err
+		}
+		schema.Namespace = file.pkg
+		schema.Name = record.name
+		schema.Format, schema.Definition, schema.proto = format, string(content), record
+		return schema, nil
+	case SchemaFormatJSON:
+		converted, err := jsonSchemaRecord(content, name)
+		if err != nil {
+			return nil, err
+		}
+		avro, err := json.Marshal(converted)
+		if err != nil {
+			return nil, err
+		}
+		schema, err := parseAVROSchema(avro)
+		if err != nil {
+			return nil, err
+		}
+		schema.Format, schema.Definition, schema.jsonRecord = format, string(content), converted
+		return schema, nil
+	}
+	schema, err := parseAVROSchema(content)
+	if err != nil {
+		return nil, err
+	}
+	schema.Format = SchemaFormatAvro
+	return schema, nil
+}
+
+// parseAVROSchema reads the name, namespace, type and fields of an AVRO schema
+func parseAVROSchema(content []byte) (*Schema, error) {
 	// Parse JSON schema
 	var schemaData map[string]interface{}
 	if err := json.Unmarshal(content, &schemaData); err != nil {
@@ -101,8 +216,7 @@ func (loader *SchemaLoader) loadSchema(filePath string) (*Schema, error) {
 
 	// Create Schema object
 	schema := &Schema{
-		Content:  string(content),
-		FilePath: filePath,
+		Content: string(content),
 	}
 
 	// Extract basic schema information
@@ -136,17 +250,61 @@ func (loader *SchemaLoader) loadSchema(filePath string) (*Schema, error) {
 					field.Doc = doc
 				}
 
+				if generatorData, ok := fieldMap["generator"]; ok {
+					spec, err := parseGeneratorAttribute(generatorData)
+					if err != nil {
+						return nil, fmt.Errorf("invalid generator attribute on field %s: %w", field.Name, err)
+					}
+					field.Generator = spec
+				}
+
 				schema.Fields = append(schema.Fields, field)
 			}
 		}
 	}
 
-	// Validate schema
-	if err := loader.validateSchema(schema); err != nil {
-		return nil, fmt.Errorf("schema validation failed: %w", err)
+	return schema, nil
+}
+
+// SerializationFormat returns the format records of the schema are written in
+func (schema *Schema) SerializationFormat() string {
+	if schema.Format == "" {
+		return SchemaFormatAvro
 	}
+	return schema.Format
+}
 
-	return schema, nil
+// RegistryType returns the Schema Registry schema type of the schema
+func (schema *Schema) RegistryType() srclient.SchemaType {
+	switch schema.SerializationFormat() {
+	case SchemaFormatProtobuf:
+		return srclient.Protobuf
+	case SchemaFormatJSON:
+		return srclient.Json
+	}
+	return srclient.Avro
+}
+
+// RegistryDefinition returns the schema as registered in Schema Registry: the original .proto or
+// JSON Schema content, or the AVRO schema
+func (schema *Schema) RegistryDefinition() string {
+	if schema.Definition != "" {
+		return schema.Definition
+	}
+	return schema.Content
+}
+
+// parseGeneratorAttribute decodes a field's custom "generator" attribute into a GeneratorSpec
+func parseGeneratorAttribute(data interface{}) (*GeneratorSpec, error) {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+	var spec GeneratorSpec
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		return nil, err
+	}
+	return &spec, nil
 }
 
 // validateSchema performs basic validation on an AVRO schema
@@ -198,6 +356,7 @@ func (loader *SchemaLoader) getSchemaKey(filename string) string {
 	// Remove file extension
 	key := strings.TrimSuffix(filename, ".avsc")
 	key = strings.TrimSuffix(key, ".json")
+	key = strings.TrimSuffix(key, ".proto")
 
 	// Convert to consistent format
 	key = strings.ToLower(key)
@@ -0,0 +1,327 @@
+package pipeline
+
+import (
+	"fmt"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// schemaResolver converts records decoded with a writer schema into the shape of the reader schema,
+// following AVRO schema resolution: fields are matched by name, reader fields missing from the writer
+// take their defaults, writer fields unknown to the reader are dropped, numbers are promoted and
+// union branches are chosen again for the reader.
+type schemaResolver struct {
+	writer *avroValueGenerator // Named type index of the writer schema
+	reader *avroValueGenerator // Named type index of the reader schema
+	root   [2]interface{}      // Top-level writer and reader types
+}
+
+// newSchemaResolver parses the writer and reader schemas
+func newSchemaResolver(writerSchema, readerSchema string) (*schemaResolver, error) {
+	writer := newAVROValueGenerator(&Schema{Content: writerSchema}, nil, nil)
+	reader := newAVROValueGenerator(&Schema{Content: readerSchema}, nil, nil)
+	if writer.root == nil || reader.root == nil {
+		return nil, fmt.Errorf("schema resolution needs record schemas")
+	}
+	return &schemaResolver{writer: writer, reader: reader, root: [2]interface{}{writer.root, reader.root}}, nil
+}
+
+// resolveRecord converts a decoded top-level record
+func (r *schemaResolver) resolveRecord(record interface{}) (interface{}, error) {
+	return r.resolve(r.root[0], "", r.root[1], "", record)
+}
+
+// deref replaces a named type reference with its definition and unwraps {"type": {...}} wrappers
+func deref(index *avroValueGenerator, avroType interface{}, namespace string) (interface{}, string) {
+	for {
+		wrapper, ok := avroType.(map[string]interface{})
+		if !ok {
+			break
+		}
+		if _, named := wrapper["type"].(string); named {
+			break
+		}
+		avroType = wrapper["type"]
+	}
+	if name, ok := avroType.(string); ok && !avroPrimitives[name] {
+		if named, ok := index.lookup(name, namespace); ok {
+			return named.def, named.enclosing
+		}
+	}
+	return avroType, namespace
+}
+
+// typeKind returns the primitive or complex kind of a type and, for named types, its full name
+func typeKind(avroType interface{}, namespace string) (kind, name string) {
+	switch t := avroType.(type) {
+	case string:
+		return t, ""
+	case []interface{}:
+		return "union", ""
+	case map[string]interface{}:
+		switch typeName := t["type"].(type) {
+		case string:
+			switch typeName {
+			case "record", "error":
+				return "record", definitionFullName(t, namespace)
+			case "enum", "fixed":
+				return typeName, definitionFullName(t, namespace)
+			}
+			return typeName, ""
+		default:
+			return typeKind(typeName, namespace)
+		}
+	}
+	return "", ""
+}
+
+// promotable reports whether AVRO resolution turns a writer kind into a reader kind
+func promotable(writer, reader string) bool {
+	switch writer {
+	case "int":
+		return reader == "long" || reader == "float" || reader == "double"
+	case "long":
+		return reader == "float" || reader == "double"
+	case "float":
+		return reader == "double"
+	case "string":
+		return reader == "bytes"
+	case "bytes":
+		return reader == "string"
+	}
+	return false
+}
+
+// shortName drops the namespace of a full name; resolution matches named types by unqualified name
+func shortName(name string) string {
+	for i := len(name) - 1; i >= 0; i-- {
+		if name[i] == '.' {
+			return name[i+1:]
+		}
+	}
+	return name
+}
+
+// matches reports whether values of the writer type resolve to the reader type; exact requires the same kind
+func (r *schemaResolver) matches(wType interface{}, wNS string, rType interface{}, rNS string, exact bool) bool {
+	wType, wNS = deref(r.writer, wType, wNS)
+	rType, rNS = deref(r.reader, rType, rNS)
+	wKind, wName := typeKind(wType, wNS)
+	rKind, rName := typeKind(rType, rNS)
+	if wKind == rKind {
+		return wName == "" || shortName(wName) == shortName(rName)
+	}
+	return !exact && promotable(wKind, rKind)
+}
+
+func (r *schemaResolver) resolve(wType interface{}, wNS string, rType interface{}, rNS string, v interface{}) (interface{}, error) {
+	wType, wNS = deref(r.writer, wType, wNS)
+	rType, rNS = deref(r.reader, rType, rNS)
+
+	// A writer union is resolved through the branch the value was written with
+	if branches, ok := wType.([]interface{}); ok {
+		if v == nil {
+			return r.resolve("null", wNS, rType, rNS, nil)
+		}
+		if wrapped, ok := v.(map[string]interface{}); ok && len(wrapped) == 1 {
+			for name, inner := range wrapped {
+				for _, b := range branches {
+					if r.writer.branchName(b, wNS, inner) == name {
+						return r.resolve(b, wNS, rType, rNS, inner)
+					}
+				}
+			}
+		}
+		return nil, fmt.Errorf("value %v matches no branch of the writer union", v)
+	}
+
+	// A reader union takes the first branch that matches the writer type, preferring the same kind
+	if branches, ok := rType.([]interface{}); ok {
+		for _, exact := range []bool{true, false} {
+			for _, b := range branches {
+				if !r.matches(wType, wNS, b, rNS, exact) {
+					continue
+				}
+				resolved, err := r.resolve(wType, wNS, b, rNS, v)
+				if err != nil || resolved == nil {
+					return resolved, err
+				}
+				return goavro.Union(r.reader.branchName(b, rNS, resolved), resolved), nil
+			}
+		}
+		return nil, fmt.Errorf("no branch of the reader union accepts writer type %v", wType)
+	}
+
+	wKind, _ := typeKind(wType, wNS)
+	rKind, _ := typeKind(rType, rNS)
+	if !r.matches(wType, wNS, rType, rNS, false) {
+		return nil, fmt.Errorf("writer type %s does not resolve to reader type %s", wKind, rKind)
+	}
+
+	switch rKind {
+	case "record":
+		return r.resolveFields(wType.(map[string]interface{}), definitionNamespace(wType.(map[string]interface{}), wNS),
+			rType.(map[string]interface{}), definitionNamespace(rType.(map[string]interface{}), rNS), v)
+	case "enum":
+		symbol, _ := v.(string)
+		def := rType.(map[string]interface{})
+		for _, s := range stringList(def["symbols"]) {
+			if s == symbol {
+				return symbol, nil
+			}
+		}
+		if fallback, ok := def["default"].(string); ok {
+			return fallback, nil
+		}
+		return nil, fmt.Errorf("enum symbol %s is unknown to the reader", symbol)
+	case "array":
+		items, _ := v.([]interface{})
+		out := make([]interface{}, len(items))
+		for i, item := range items {
+			resolved, err := r.resolve(wType.(map[string]interface{})["items"], wNS, rType.(map[string]interface{})["items"], rNS, item)
+			if err != nil {
+				return nil, err
+			}
+			out[i] = resolved
+		}
+		return out, nil
+	case "map":
+		entries, _ := v.(map[string]interface{})
+		out := make(map[string]interface{}, len(entries))
+		for k, entry := range entries {
+			resolved, err := r.resolve(wType.(map[string]interface{})["values"], wNS, rType.(map[string]interface{})["values"], rNS, entry)
+			if err != nil {
+				return nil, err
+			}
+			out[k] = resolved
+		}
+		return out, nil
+	}
+	return promote(v, wKind, rKind), nil
+}
+
+// resolveFields matches record fields by name and fills reader fields the writer lacks with defaults
+func (r *schemaResolver) resolveFields(wDef map[string]interface{}, wNS string, rDef map[string]interface{}, rNS string, v interface{}) (interface{}, error) {
+	record, _ := v.(map[string]interface{})
+	writerFields := make(map[string]interface{})
+	for _, f := range fieldList(wDef) {
+		writerFields[f["name"].(string)] = f["type"]
+	}
+
+	out := make(map[string]interface{}, len(record))
+	for _, f := range fieldList(rDef) {
+		name := f["name"].(string)
+		if wFieldType, ok := writerFields[name]; ok {
+			resolved, err := r.resolve(wFieldType, wNS, f["type"], rNS, record[name])
+			if err != nil {
+				return nil, fmt.Errorf("field %s: %w", name, err)
+			}
+			out[name] = resolved
+			continue
+		}
+		def, ok := f["default"]
+		if !ok {
+			return nil, fmt.Errorf("reader field %s is not written and has no default", name)
+		}
+		out[name] = r.defaultValue(f["type"], rNS, def)
+	}
+	return out, nil
+}
+
+// defaultValue turns a JSON default into goavro's native form; union defaults belong to the first branch
+func (r *schemaResolver) defaultValue(rType interface{}, rNS string, def interface{}) interface{} {
+	rType, rNS = deref(r.reader, rType, rNS)
+	if branches, ok := rType.([]interface{}); ok && len(branches) > 0 {
+		if def == nil {
+			return nil
+		}
+		v := r.defaultValue(branches[0], rNS, def)
+		return goavro.Union(r.reader.branchName(branches[0], rNS, v), v)
+	}
+
+	kind, _ := typeKind(rType, rNS)
+	switch kind {
+	case "record":
+		values, _ := def.(map[string]interface{})
+		out := make(map[string]interface{}, len(values))
+		for _, f := range fieldList(rType.(map[string]interface{})) {
+			name := f["name"].(string)
+			if value, ok := values[name]; ok {
+				out[name] = r.defaultValue(f["type"], definitionNamespace(rType.(map[string]interface{}), rNS), value)
+			}
+		}
+		return out
+	case "array":
+		items, _ := def.([]interface{})
+		out := make([]interface{}, len(items))
+		for i, item := range items {
+			out[i] = r.defaultValue(rType.(map[string]interface{})["items"], rNS, item)
+		}
+		return out
+	case "map":
+		entries, _ := def.(map[string]interface{})
+		out := make(map[string]interface{}, len(entries))
+		for k, entry := range entries {
+			out[k] = r.defaultValue(rType.(map[string]interface{})["values"], rNS, entry)
+		}
+		return out
+	}
+	if f, ok := def.(float64); ok {
+		return promote(f, "double", kind)
+	}
+	if s, ok := def.(string); ok && (kind == "bytes" || kind == "fixed") {
+		return []byte(s)
+	}
+	return def
+}
+
+// fieldList returns the field definitions of a record
+func fieldList(def map[string]interface{}) []map[string]interface{} {
+	raw, _ := def["fields"].([]interface{})
+	fields := make([]map[string]interface{}, 0, len(raw))
+	for _, f := range raw {
+		if field, ok := f.(map[string]interface{}); ok {
+			if _, ok := field["name"].(string); ok {
+				fields = append(fields, field)
+			}
+		}
+	}
+	return fields
+}
+
+// promote converts a primitive value to the reader's type
+func promote(v interface{}, writer, reader string) interface{} {
+	if writer == reader {
+		return v
+	}
+	switch reader {
+	case "int":
+		if f, ok := toFloat(v); ok {
+			return int32(f)
+		}
+	case "long":
+		if f, ok := toFloat(v); ok {
+			if i, ok := toInt(v); ok {
+				return i
+			}
+			return int64(f)
+		}
+	case "float":
+		if f, ok := toFloat(v); ok {
+			return float32(f)
+		}
+	case "double":
+		if f, ok := toFloat(v); ok {
+			return f
+		}
+	case "string":
+		if b, ok := v.([]byte); ok {
+			return string(b)
+		}
+	case "bytes":
+		if s, ok := v.(string); ok {
+			return []byte(s)
+		}
+	}
+	return v
+}
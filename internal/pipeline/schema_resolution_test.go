@@ -0,0 +1,168 @@
+package pipeline
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/segmentio/kafka-go"
+)
+
+const writerV1Schema = `{
+  "type": "record",
+  "name": "Total",
+  "namespace": "com.example",
+  "fields": [
+    {"name": "user_id", "type": "string"},
+    {"name": "count", "type": "int"},
+    {"name": "legacy", "type": "string"},
+    {"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["OK", "RETIRED"]}},
+    {"name": "score", "type": ["null", "int"]}
+  ]
+}`
+
+const readerV2Schema = `{
+  "type": "record",
+  "name": "Total",
+  "namespace": "com.example.v2",
+  "fields": [
+    {"name": "user_id", "type": "string"},
+    {"name": "count", "type": "long"},
+    {"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["OK", "UNKNOWN"], "default": "UNKNOWN"}},
+    {"name": "score", "type": ["null", "double"]},
+    {"name": "region", "type": "string", "default": "eu"},
+    {"name": "note", "type": ["null", "string"], "default": null}
+  ]
+}`
+
+func TestSchemaResolverResolveRecord(t *testing.T) {
+	resolver, err := newSchemaResolver(writerV1Schema, readerV2Schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		record map[string]interface{}
+		want   map[string]interface{}
+	}{
+		{
+			name:   "promotes numbers and fills defaults",
+			record: map[string]interface{}{"user_id": "u1", "count": int32(3), "legacy": "x", "status": "OK", "score": goavro.Union("int", int32(7))},
+			want:   map[string]interface{}{"user_id": "u1", "count": int64(3), "status": "OK", "score": goavro.Union("double", float64(7)), "region": "eu", "note": nil},
+		},
+		{
+			name:   "unknown enum symbol takes the reader default",
+			record: map[string]interface{}{"user_id": "u2", "count": int32(1), "legacy": "x", "status": "RETIRED", "score": nil},
+			want:   map[string]interface{}{"user_id": "u2", "count": int64(1), "status": "UNKNOWN", "score": nil, "region": "eu", "note": nil},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := resolver.resolveRecord(tt.record)
+			if err != nil {
+				t.Fatalf("resolveRecord() error = %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("resolveRecord() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	// The resolved record encodes with the reader schema
+	codec, err := goavro.NewCodec(readerV2Schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resolved, _ := resolver.resolveRecord(tests[0].record)
+	if _, err := codec.BinaryFromNative(nil, resolved); err != nil {
+		t.Errorf("resolved record does not match the reader schema: %v", err)
+	}
+}
+
+func TestSchemaResolverIncompatible(t *testing.T) {
+	tests := []struct {
+		name   string
+		reader string
+	}{
+		{name: "new field without default", reader: `{"type": "record", "name": "Total", "fields": [{"name": "region", "type": "string"}]}`},
+		{name: "narrowing", reader: `{"type": "record", "name": "Total", "fields": [{"name": "count", "type": "string"}]}`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resolver, err := newSchemaResolver(writerV1Schema, tt.reader)
+			if err != nil {
+				t.Fatal(err)
+			}
+			record := map[string]interface{}{"user_id": "u1", "count": int32(3), "legacy": "x", "status": "OK", "score": nil}
+			if _, err := resolver.resolveRecord(record); err == nil {
+				t.Error("expected a resolution error")
+			}
+		})
+	}
+}
+
+func TestConsumerDecodesWithWriterSchemas(t *testing.T) {
+	writerV2 := `{"type": "record", "name": "Total", "fields": [
+    {"name": "user_id", "type": "string"},
+    {"name": "count", "type": "long"},
+    {"name": "status", "type": {"type": "enum", "name": "Status", "symbols": ["OK"]}},
+    {"name": "score", "type": ["null", "double"]}
+  ]}`
+	schemas := map[int]string{12: writerV1Schema, 13: writerV2}
+	fetches := 0
+
+	consumer := &Consumer{config: &Config{}}
+	if err := consumer.SetSchema(readerV2Schema); err != nil {
+		t.Fatal(err)
+	}
+	consumer.fetchSchema = func(id int) (*registeredSchema, error) {
+		fetches++
+		if schema, ok := schemas[id]; ok {
+			return &registeredSchema{definition: schema}, nil
+		}
+		return nil, fmt.Errorf("schema %d not found", id)
+	}
+	var records []map[string]interface{}
+	consumer.AddRecordObserver(func(record map[string]interface{}) { records = append(records, record) })
+
+	encode := func(id int, record map[string]interface{}) *kafka.Message {
+		codec, err := goavro.NewCodec(schemas[id])
+		if err != nil {
+			t.Fatal(err)
+		}
+		binary, err := codec.BinaryFromNative(nil, record)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return &kafka.Message{Value: confluentWireFormat(id, binary)}
+	}
+	messages := []*kafka.Message{
+		encode(12, map[string]interface{}{"user_id": "a", "count": 1, "legacy": "x", "status": "OK", "score": nil}),
+		encode(13, map[string]interface{}{"user_id": "b", "count": int64(2), "status": "OK", "score": goavro.Union("double", 0.5)}),
+		encode(12, map[string]interface{}{"user_id": "c", "count": 3, "legacy": "x", "status": "OK", "score": nil}),
+	}
+	for _, msg := range messages {
+		if err := consumer.processMessage(msg); err != nil {
+			t.Fatalf("processMessage() error = %v", err)
+		}
+	}
+	if err := consumer.processMessage(&kafka.Message{Value: confluentWireFormat(99, []byte{0})}); err == nil {
+		t.Error("expected an error for an unknown schema ID")
+	}
+
+	if fetches != 3 {
+		t.Errorf("fetched schemas %d times, want 3 (once per ID)", fetches)
+	}
+	if len(records) != 3 || records[1]["count"] != int64(2) || records[1]["region"] != "eu" {
+		t.Errorf("unexpected decoded records: %v", records)
+	}
+	stats := consumer.GetStats()
+	if want := map[int]int64{12: 2, 13: 1}; !reflect.DeepEqual(stats.SchemaIDs, want) {
+		t.Errorf("SchemaIDs = %v, want %v", stats.SchemaIDs, want)
+	}
+	if got := stats.SchemaIDSummary(); got != "12 (2), 13 (1)" {
+		t.Errorf("SchemaIDSummary() = %q", got)
+	}
+}
@@ -0,0 +1,49 @@
+package pipeline
+
+import (
+	"hash/fnv"
+	"time"
+)
+
+// seededEpoch is where the virtual clock of reproducible runs starts
+var seededEpoch = time.Date(2024, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// NewRunSeed picks the seed of a run without --seed; it is recorded so the run can be reproduced
+func NewRunSeed() int64 {
+	return time.Now().UnixNano()
+}
+
+// sourceSeed derives the seed of an additional source, so every source draws its own repeatable data
+func sourceSeed(seed int64, table string) int64 {
+	h := fnv.New64a()
+	_, _ = h.Write([]byte(table))
+	return seed ^ int64(h.Sum64())
+}
+
+// eventClock is the "now" of generated timestamps. A nil clock reads the wall clock; reproducible
+// runs use a virtual clock that starts at seededEpoch and advances one rate interval per message,
+// so the same seed and message count produce the same timestamps.
+type eventClock struct {
+	start time.Time
+	step  time.Duration
+}
+
+// newEventClock returns the virtual clock of a reproducible run, or nil to use the wall clock
+func newEventClock(config *Config) *eventClock {
+	if !config.Reproducible {
+		return nil
+	}
+	rate := config.MessageRate
+	if rate <= 0 {
+		rate = 1
+	}
+	return &eventClock{start: seededEpoch, step: time.Second / time.Duration(rate)}
+}
+
+// Now returns the generation time of a message
+func (c *eventClock) Now(messageID int) time.Time {
+	if c == nil {
+		return time.Now()
+	}
+	return c.start.Add(time.Duration(messageID) * c.step)
+}
@@ -0,0 +1,141 @@
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+)
+
+// SinkConfig overrides consumer settings for one sink table (sinks section, keyed by table or topic name)
+type SinkConfig struct {
+	ExpectedMessages int64 `yaml:"expected_messages" mapstructure:"expected_messages"` // Messages to read before the sink is complete (default: --expected-messages for the primary sink, none for the others)
+}
+
+// Validate checks the sink settings
+func (c *SinkConfig) Validate() error {
+	if c.ExpectedMessages < 0 {
+		return fmt.Errorf("expected_messages must not be negative")
+	}
+	return nil
+}
+
+// lookupSinkConfig finds the settings of a sink by table or topic name
+func lookupSinkConfig(configs map[string]*SinkConfig, sink SourceTable) *SinkConfig {
+	for name, cfg := range configs {
+		if matchesSource(name, sink) {
+			return cfg
+		}
+	}
+	return nil
+}
+
+// resolveSinkTopics maps sink tables onto the topic names created for this run. The sink that matches
+// the output topic is moved to the front; it remains the primary sink that assertions and disorder
+// detection read. Without sink tables the output topic is the only sink.
+func resolveSinkTopics(sinks []SourceTable, sqlTopics []string, resources *Resources) []SourceTable {
+	resolved := resolveTableTopics(sinks, sqlTopics, resources)
+
+	if len(resolved) == 0 {
+		if resources.OutputTopic == "" {
+			return nil
+		}
+		return []SourceTable{{Table: "output", Topic: resources.OutputTopic}}
+	}
+
+	for i, s := range resolved {
+		if s.Topic == resources.OutputTopic {
+			primary := resolved[i]
+			copy(resolved[1:i+1], resolved[:i])
+			resolved[0] = primary
+			return resolved
+		}
+	}
+	// The last topic in the SQL is not written by the pipeline; read the last declared sink instead
+	last := len(resolved) - 1
+	resources.OutputTopic = resolved[last].Topic
+	return append([]SourceTable{resolved[last]}, resolved[:last]...)
+}
+
+// SinkStats holds the consumer statistics of one output topic
+type SinkStats struct {
+	Table          string    `json:"table"`
+	Topic          string    `json:"topic"`
+	Expected       int64     `json:"expected,omitempty"` // 0 when the consumer does not wait for this sink
+	Messages       int64     `json:"messages"`
+	Bytes          int64     `json:"bytes"`
+	Errors         int64     `json:"errors"`
+	MessagesPerSec float64   `json:"messages_per_sec"`
+	LastMessage    time.Time `json:"last_message,omitempty"`
+}
+
+// Complete reports whether the sink got its expected messages
+func (s *SinkStats) Complete() bool {
+	return s.Expected > 0 && s.Messages >= s.Expected
+}
+
+// Summary returns a one-line description, e.g. "alerts (run-alerts): 120/100 messages, 0.02 MB, 4.0 msg/sec, 0 errors"
+func (s *SinkStats) Summary() string {
+	messages := fmt.Sprintf("%d", s.Messages)
+	if s.Expected > 0 {
+		messages = fmt.Sprintf("%d/%d", s.Messages, s.Expected)
+	}
+	return fmt.Sprintf("%s (%s): %s messages, %.2f MB, %.1f msg/sec, %d errors",
+		s.Table, s.Topic, messages, float64(s.Bytes)/1024/1024, s.MessagesPerSec, s.Errors)
+}
+
+// sinkState tracks one output topic of the consumer; the counters are updated atomically
+type sinkState struct {
+	table    string
+	topic    string
+	expected int64
+	codec    *goavro.Codec // Reader schema of the topic (nil uses the consumer's)
+
+	messages    int64
+	bytes       int64
+	errors      int64
+	lastMessage int64 // Unix nanoseconds
+}
+
+// record counts a fetched message; err is the processing error, if any
+func (s *sinkState) record(size int, err error) {
+	atomic.AddInt64(&s.bytes, int64(size))
+	if err != nil {
+		atomic.AddInt64(&s.errors, 1)
+		return
+	}
+	atomic.AddInt64(&s.messages, 1)
+	atomic.StoreInt64(&s.lastMessage, time.Now().UnixNano())
+}
+
+// stats returns the statistics of the sink; elapsed is how long the consumer has been running
+func (s *sinkState) stats(elapsed time.Duration) SinkStats {
+	stats := SinkStats{
+		Table:    s.table,
+		Topic:    s.topic,
+		Expected: s.expected,
+		Messages: atomic.LoadInt64(&s.messages),
+		Bytes:    atomic.LoadInt64(&s.bytes),
+		Errors:   atomic.LoadInt64(&s.errors),
+	}
+	if elapsed > 0 {
+		stats.MessagesPerSec = float64(stats.Messages) / elapsed.Seconds()
+	}
+	if last := atomic.LoadInt64(&s.lastMessage); last > 0 {
+		stats.LastMessage = time.Unix(0, last)
+	}
+	return stats
+}
+
+// sinkProgress describes how far the awaited sinks are, e.g. "alerts 40/100, totals 100/100"
+func sinkProgress(sinks []SinkStats) string {
+	var parts []string
+	for _, s := range sinks {
+		if s.Expected > 0 {
+			parts = append(parts, fmt.Sprintf("%s %d/%d", s.Table, s.Messages, s.Expected))
+		}
+	}
+	return strings.Join(parts, ", ")
+}
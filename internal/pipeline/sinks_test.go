@@ -0,0 +1,96 @@
+package pipeline
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestResolveSinkTopics(t *testing.T) {
+	sinks := []SourceTable{{Table: "alerts", Topic: "alerts"}, {Table: "totals", Topic: "totals"}, {Table: "audit", Topic: "audit"}}
+	resources := &Resources{
+		InputTopic:  "run-orders",
+		OutputTopic: "run-totals",
+		Topics:      []string{"run-orders", "run-alerts", "run-totals", "run-audit"},
+	}
+	sqlTopics := []string{"orders", "alerts", "totals", "audit"}
+
+	got := resolveSinkTopics(sinks, sqlTopics, resources)
+	want := []SourceTable{{Table: "totals", Topic: "run-totals"}, {Table: "alerts", Topic: "run-alerts"}, {Table: "audit", Topic: "run-audit"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resolveSinkTopics() = %v, want %v", got, want)
+	}
+
+	// The last SQL topic is a source: the last declared sink becomes the output topic
+	resources = &Resources{OutputTopic: "payments", Topics: []string{"alerts", "totals", "payments"}}
+	got = resolveSinkTopics(sinks[:2], []string{"alerts", "totals", "payments"}, resources)
+	want = []SourceTable{{Table: "totals", Topic: "totals"}, {Table: "alerts", Topic: "alerts"}}
+	if !reflect.DeepEqual(got, want) || resources.OutputTopic != "totals" {
+		t.Errorf("resolveSinkTopics() = %v with output %s, want %v with output totals", got, resources.OutputTopic, want)
+	}
+
+	fallback := resolveSinkTopics(nil, nil, &Resources{OutputTopic: "output-results"})
+	if len(fallback) != 1 || fallback[0].Topic != "output-results" {
+		t.Errorf("expected the output topic as the only sink, got %v", fallback)
+	}
+}
+
+func TestLookupSinkConfig(t *testing.T) {
+	configs := map[string]*SinkConfig{"alerts": {ExpectedMessages: 10}, "run-totals": {ExpectedMessages: 20}}
+	if cfg := lookupSinkConfig(configs, SourceTable{Table: "`db`.`alerts`", Topic: "run-alerts"}); cfg == nil || cfg.ExpectedMessages != 10 {
+		t.Errorf("expected the alerts config by table name, got %+v", cfg)
+	}
+	if cfg := lookupSinkConfig(configs, SourceTable{Table: "totals", Topic: "RUN-TOTALS"}); cfg == nil || cfg.ExpectedMessages != 20 {
+		t.Errorf("expected the totals config by topic name, got %+v", cfg)
+	}
+	if cfg := lookupSinkConfig(configs, SourceTable{Table: "audit", Topic: "run-audit"}); cfg != nil {
+		t.Errorf("expected no config for audit, got %+v", cfg)
+	}
+	if err := (&SinkConfig{ExpectedMessages: -1}).Validate(); err == nil {
+		t.Error("expected an error for a negative expected_messages")
+	}
+}
+
+func TestConsumerTracksSinks(t *testing.T) {
+	consumer := &Consumer{config: &Config{}, latency: newLatencyTracker(), startTime: time.Now().Add(-time.Second)}
+	consumer.AddSink(SourceTable{Table: "totals", Topic: "run-totals"}, 2)
+	consumer.AddSink(SourceTable{Table: "alerts", Topic: "run-alerts"}, 0)
+	var primary []map[string]interface{}
+	consumer.AddRecordObserver(func(record map[string]interface{}) { primary = append(primary, record) })
+
+	consume := func(topic, value string) {
+		msg := &kafka.Message{Topic: topic, Value: []byte(value)}
+		err := consumer.processMessage(msg)
+		consumer.sink(topic).record(len(msg.Value), err)
+	}
+	consume("run-totals", `{"total": 1}`)
+	consume("run-alerts", `{"level": "WARN"}`)
+	consume("run-alerts", "")
+	if consumer.sinksComplete() {
+		t.Fatal("totals has 1 of 2 expected messages")
+	}
+	consume("run-totals", `{"total": 2}`)
+	if !consumer.sinksComplete() {
+		t.Fatal("expected the sinks to be complete once totals got 2 messages")
+	}
+	if len(primary) != 2 {
+		t.Errorf("observers saw %d records, want the 2 records of the primary sink", len(primary))
+	}
+
+	stats := consumer.GetStats()
+	if len(stats.Sinks) != 2 || stats.BytesConsumed != 41 {
+		t.Fatalf("stats = %+v, want 2 sinks and 41 bytes", stats)
+	}
+	totals, alerts := stats.Sinks[0], stats.Sinks[1]
+	if totals.Table != "totals" || totals.Messages != 2 || !totals.Complete() || totals.MessagesPerSec <= 0 {
+		t.Errorf("unexpected totals stats: %+v", totals)
+	}
+	if alerts.Messages != 1 || alerts.Errors != 1 || alerts.Complete() || alerts.LastMessage.IsZero() {
+		t.Errorf("unexpected alerts stats: %+v", alerts)
+	}
+	if got := sinkProgress(stats.Sinks); got != "totals 2/2" {
+		t.Errorf("sinkProgress() = %q, want %q", got, "totals 2/2")
+	}
+}
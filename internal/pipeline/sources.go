@@ -0,0 +1,274 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"strings"
+	"sync"
+
+	"pipegen/internal/flinksql"
+	"pipegen/internal/types"
+)
+
+// SourceTable is a Kafka-backed table the pipeline reads from but never writes to
+type SourceTable struct {
+	Table string // Flink table name as declared in CREATE TABLE
+	Topic string // Kafka topic (resolved to the run's topic name in Resources.Sources)
+}
+
+// ExtractSourceTables finds the Kafka tables that feed the pipeline: tables using the kafka or
+// upsert-kafka connector that are never the target of an INSERT or CREATE TABLE AS SELECT.
+// Tables are returned in declaration order.
+func ExtractSourceTables(statements []*types.SQLStatement) []SourceTable {
+	declared, written := declaredKafkaTables(statements)
+
+	var sources []SourceTable
+	seenTopics := make(map[string]bool)
+	for _, t := range declared {
+		if written[normalizeTableName(t.Table)] || seenTopics[t.Topic] {
+			continue
+		}
+		seenTopics[t.Topic] = true
+		sources = append(sources, t)
+	}
+	return sources
+}
+
+// ExtractSinkTables finds the Kafka tables the pipeline writes to with INSERT or CREATE TABLE AS SELECT,
+// in declaration order
+func ExtractSinkTables(statements []*types.SQLStatement) []SourceTable {
+	declared, written := declaredKafkaTables(statements)
+
+	var sinks []SourceTable
+	for _, t := range declared {
+		if written[normalizeTableName(t.Table)] {
+			sinks = append(sinks, t)
+		}
+	}
+	return sinks
+}
+
+// declaredKafkaTables returns the tables declared with a Kafka connector (CTAS included) and the names of all tables
+// that are written to (INSERT targets and CREATE TABLE AS SELECT)
+func declaredKafkaTables(statements []*types.SQLStatement) ([]SourceTable, map[string]bool) {
+	var declared []SourceTable
+	written := make(map[string]bool)
+
+	for _, stmt := range parseStatements(statements) {
+		for _, table := range stmt.Writes() {
+			written[normalizeTableName(table)] = true
+		}
+		if table := stmt.Table; table != nil && table.IsKafka() && table.Topic() != "" {
+			declared = append(declared, SourceTable{Table: table.Name, Topic: table.Topic()})
+		}
+	}
+	return declared, written
+}
+
+// normalizeTableName compares table names case-insensitively, ignoring quoting and catalog/database qualifiers
+func normalizeTableName(name string) string {
+	return flinksql.NormalizeName(name)
+}
+
+// resolveSourceTopics maps source tables onto the topic names created for this run. sqlTopics and
+// resources.Topics are index-aligned when the topics came from SQL. The source that matches the
+// input topic is moved to the front; it remains the primary source.
+func resolveSourceTopics(sources []SourceTable, sqlTopics []string, resources *Resources) []SourceTable {
+	resolved := resolveTableTopics(sources, sqlTopics, resources)
+
+	if len(resolved) == 0 {
+		if resources.InputTopic == "" {
+			return nil
+		}
+		return []SourceTable{{Table: "input", Topic: resources.InputTopic}}
+	}
+
+	for i, s := range resolved {
+		if s.Topic == resources.InputTopic {
+			resolved[0], resolved[i] = resolved[i], resolved[0]
+			return resolved
+		}
+	}
+	// The first declared table is not a source; produce to the first real source instead
+	resources.InputTopic = resolved[0].Topic
+	return resolved
+}
+
+// resolveTableTopics maps tables onto the topic names created for this run
+func resolveTableTopics(tables []SourceTable, sqlTopics []string, resources *Resources) []SourceTable {
+	resolved := make([]SourceTable, 0, len(tables))
+	for _, t := range tables {
+		if len(sqlTopics) == len(resources.Topics) {
+			for i, topic := range sqlTopics {
+				if topic == t.Topic {
+					t.Topic = resources.Topics[i]
+					break
+				}
+			}
+		}
+		resolved = append(resolved, t)
+	}
+	return resolved
+}
+
+// SourceConfig overrides producer settings for one source table (sources section, keyed by table or topic name)
+type SourceConfig struct {
+	Schema         string            `yaml:"schema" mapstructure:"schema"`                   // Schema file name (default: table name; "input" for the primary source)
+	MessageRate    int               `yaml:"message_rate" mapstructure:"message_rate"`       // Messages per second (default: --message-rate)
+	TrafficPattern string            `yaml:"traffic_pattern" mapstructure:"traffic_pattern"` // Same format as --traffic-pattern
+	References     []ReferenceConfig `yaml:"references" mapstructure:"references"`           // Fields whose values come from another source
+}
+
+// ReferenceConfig makes a field reuse values already produced to another source, so join keys match
+type ReferenceConfig struct {
+	Field       string   `yaml:"field" mapstructure:"field"`               // Field in this source
+	Source      string   `yaml:"source" mapstructure:"source"`             // Referenced source table or topic
+	SourceField string   `yaml:"source_field" mapstructure:"source_field"` // Field in the referenced source (default: Field)
+	MatchRatio  *float64 `yaml:"match_ratio" mapstructure:"match_ratio"`   // Share of messages that match (default 1.0; 0 makes only orphans)
+}
+
+// Ratio returns the share of messages that match, 1 when match_ratio is not set
+func (r ReferenceConfig) Ratio() float64 {
+	if r.MatchRatio == nil {
+		return 1
+	}
+	return *r.MatchRatio
+}
+
+// Validate checks the source settings and applies defaults
+func (c *SourceConfig) Validate() error {
+	if c.MessageRate < 0 {
+		return fmt.Errorf("message_rate must not be negative")
+	}
+	if c.TrafficPattern != "" {
+		if _, err := ParseTrafficPattern(c.TrafficPattern, 1); err != nil {
+			return fmt.Errorf("invalid traffic_pattern: %w", err)
+		}
+	}
+	for i := range c.References {
+		ref := &c.References[i]
+		if ref.Field == "" || ref.Source == "" {
+			return fmt.Errorf("references[%d]: field and source are required", i)
+		}
+		if ref.SourceField == "" {
+			ref.SourceField = ref.Field
+		}
+		if ratio := ref.Ratio(); ratio < 0 || ratio > 1 {
+			return fmt.Errorf("references[%d]: match_ratio must be between 0 and 1", i)
+		}
+	}
+	return nil
+}
+
+// Summary describes the source overrides for the execution plan
+func (c *SourceConfig) Summary() string {
+	var parts []string
+	if c.Schema != "" {
+		parts = append(parts, "schema "+c.Schema)
+	}
+	if c.MessageRate > 0 {
+		parts = append(parts, fmt.Sprintf("%d msg/sec", c.MessageRate))
+	}
+	if c.TrafficPattern != "" {
+		parts = append(parts, "traffic "+c.TrafficPattern)
+	}
+	for _, ref := range c.References {
+		parts = append(parts, fmt.Sprintf("%s -> %s.%s (%.0f%% match)", ref.Field, ref.Source, ref.SourceField, ref.Ratio()*100))
+	}
+	if len(parts) == 0 {
+		return "defaults"
+	}
+	return strings.Join(parts, ", ")
+}
+
+// lookupSourceConfig finds the overrides for a source by table or topic name (config keys are case-insensitive)
+func lookupSourceConfig(configs map[string]*SourceConfig, source SourceTable) *SourceConfig {
+	for name, cfg := range configs {
+		if normalizeTableName(name) == normalizeTableName(source.Table) || strings.EqualFold(name, source.Topic) {
+			return cfg
+		}
+	}
+	return nil
+}
+
+// matchesSource reports whether a reference target names the given source
+func matchesSource(name string, source SourceTable) bool {
+	return normalizeTableName(name) == normalizeTableName(source.Table) || strings.EqualFold(name, source.Topic)
+}
+
+// referencePoolSize bounds how many recently produced values a referencing source can pick from
+const referencePoolSize = 10000
+
+// referencePool holds recently produced values of one field, shared between source producers
+type referencePool struct {
+	mu     sync.Mutex
+	values []interface{}
+	next   int
+}
+
+func (p *referencePool) add(v interface{}) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.values) < referencePoolSize {
+		p.values = append(p.values, v)
+		return
+	}
+	p.values[p.next] = v
+	p.next = (p.next + 1) % referencePoolSize
+}
+
+func (p *referencePool) pick(rng *rand.Rand) (interface{}, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if len(p.values) == 0 {
+		return nil, false
+	}
+	return p.values[rng.Intn(len(p.values))], true
+}
+
+// fieldReference fills a field from another source's pool at the configured match ratio
+type fieldReference struct {
+	field SchemaField
+	pool  *referencePool
+	ratio float64
+}
+
+// unwrapUnion returns the value inside a goavro union, or v itself
+func unwrapUnion(v interface{}) interface{} {
+	if wrapped, ok := v.(map[string]interface{}); ok && len(wrapped) == 1 {
+		for _, inner := range wrapped {
+			return inner
+		}
+	}
+	return v
+}
+
+// orphanValue turns a generated value into one that cannot match a referenced value:
+// strings get an "orphan-" prefix and numbers become negative. Other types are kept as generated.
+func orphanValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case string:
+		return "orphan-" + t
+	case int32:
+		if t < 0 {
+			return t
+		}
+		return -t - 1
+	case int64:
+		if t < 0 {
+			return t
+		}
+		return -t - 1
+	case int:
+		if t < 0 {
+			return t
+		}
+		return -t - 1
+	case float32:
+		return -float32(math.Abs(float64(t))) - 1
+	case float64:
+		return -math.Abs(t) - 1
+	}
+	return v
+}
@@ -0,0 +1,183 @@
+package pipeline
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"pipegen/internal/types"
+)
+
+const joinPipelineSQL = `CREATE TABLE orders (
+order_id STRING,
+amount DOUBLE
+) WITH (
+'connector' = 'kafka',
+'topic' = 'orders',
+'format' = 'avro-confluent'
+);
+CREATE TABLE payments (
+payment_id STRING,
+order_id STRING
+) WITH (
+'connector' = 'kafka',
+'topic' = 'payments',
+'format' = 'avro-confluent'
+);`
+
+func TestExtractSourceTables(t *testing.T) {
+	statements := []*types.SQLStatement{
+		{Name: "01_sources", Content: joinPipelineSQL},
+		{Name: "02_lookup", Content: "CREATE TABLE currencies (code STRING) WITH ('connector' = 'jdbc', 'table-name' = 'currencies')"},
+		{Name: "03_sink", Content: "CREATE TABLE `paid_orders` (order_id STRING) WITH ('connector' = 'upsert-kafka', 'topic' = 'paid-orders')"},
+		{Name: "04_insert", Content: "INSERT INTO paid_orders SELECT o.order_id FROM orders o JOIN payments p ON o.order_id = p.order_id"},
+		{Name: "05_ctas", Content: "CREATE TABLE totals WITH ('connector' = 'kafka', 'topic' = 'totals') AS SELECT order_id FROM orders"},
+	}
+
+	got := ExtractSourceTables(statements)
+	want := []SourceTable{{Table: "orders", Topic: "orders"}, {Table: "payments", Topic: "payments"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ExtractSourceTables() = %v, want %v", got, want)
+	}
+
+	sinks := ExtractSinkTables(statements)
+	wantSinks := []SourceTable{{Table: "paid_orders", Topic: "paid-orders"}, {Table: "totals", Topic: "totals"}}
+	if !reflect.DeepEqual(sinks, wantSinks) {
+		t.Errorf("ExtractSinkTables() = %v, want %v", sinks, wantSinks)
+	}
+}
+
+func TestResolveSourceTopics(t *testing.T) {
+	sources := []SourceTable{{Table: "payments", Topic: "payments"}, {Table: "orders", Topic: "orders"}}
+	resources := &Resources{
+		InputTopic:  "run-orders",
+		OutputTopic: "run-paid",
+		Topics:      []string{"run-orders", "run-payments", "run-paid"},
+	}
+
+	got := resolveSourceTopics(sources, []string{"orders", "payments", "paid"}, resources)
+	want := []SourceTable{{Table: "orders", Topic: "run-orders"}, {Table: "payments", Topic: "run-payments"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("resolveSourceTopics() = %v, want %v", got, want)
+	}
+
+	fallback := resolveSourceTopics(nil, nil, &Resources{InputTopic: "input-events"})
+	if len(fallback) != 1 || fallback[0].Topic != "input-events" {
+		t.Errorf("expected the input topic as the only source, got %v", fallback)
+	}
+}
+
+func TestSourceConfigValidate(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  SourceConfig
+		wantErr bool
+	}{
+		{name: "empty", config: SourceConfig{}},
+		{name: "rate and pattern", config: SourceConfig{MessageRate: 50, TrafficPattern: "10s-20s:200%"}},
+		{name: "negative rate", config: SourceConfig{MessageRate: -1}, wantErr: true},
+		{name: "bad pattern", config: SourceConfig{TrafficPattern: "soon"}, wantErr: true},
+		{name: "reference", config: SourceConfig{References: []ReferenceConfig{{Field: "order_id", Source: "orders", MatchRatio: float64Ptr(0.9)}}}},
+		{name: "reference without source", config: SourceConfig{References: []ReferenceConfig{{Field: "order_id"}}}, wantErr: true},
+		{name: "ratio zero", config: SourceConfig{References: []ReferenceConfig{{Field: "order_id", Source: "orders", MatchRatio: float64Ptr(0)}}}},
+		{name: "negative ratio", config: SourceConfig{References: []ReferenceConfig{{Field: "order_id", Source: "orders", MatchRatio: float64Ptr(-0.1)}}}, wantErr: true},
+		{name: "ratio above one", config: SourceConfig{References: []ReferenceConfig{{Field: "order_id", Source: "orders", MatchRatio: float64Ptr(1.5)}}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.config.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	cfg := SourceConfig{References: []ReferenceConfig{{Field: "order_id", Source: "orders"}}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if ref := cfg.References[0]; ref.SourceField != "order_id" || ref.Ratio() != 1 {
+		t.Errorf("expected defaults to be applied, got %+v", ref)
+	}
+}
+
+func TestProducerReferencesMatchRatio(t *testing.T) {
+	orders := &Producer{rng: rand.New(rand.NewSource(1))}
+	pool := orders.publish("order_id")
+	for i := 0; i < 100; i++ {
+		pool.add("order_id-" + string(rune('a'+i%26)))
+	}
+
+	payments := &Producer{rng: rand.New(rand.NewSource(2))}
+	payments.avroValues = newAVROValueGenerator(nil, payments.rng, func(string, int) string { return "" })
+	field := SchemaField{Name: "order_id", Type: []interface{}{"null", "string"}}
+	payments.addReference(field, pool, 0.7)
+
+	matched := 0
+	for i := 0; i < 5000; i++ {
+		message := map[string]interface{}{"order_id": "order_id-a"}
+		payments.applyReferences(message)
+		value, ok := unwrapUnion(message["order_id"]).(string)
+		if !ok {
+			t.Fatalf("expected a string value, got %T", message["order_id"])
+		}
+		if !strings.HasPrefix(value, "orphan-") {
+			matched++
+		}
+	}
+	if matched < 3300 || matched > 3700 {
+		t.Errorf("expected ~70%% matching references, got %d/5000", matched)
+	}
+}
+
+func TestProducerReferencesMatchRatioZero(t *testing.T) {
+	orders := &Producer{rng: rand.New(rand.NewSource(1))}
+	pool := orders.publish("order_id")
+	pool.add("order_id-a")
+
+	cfg := SourceConfig{References: []ReferenceConfig{{Field: "order_id", Source: "orders", MatchRatio: float64Ptr(0)}}}
+	if err := cfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if ratio := cfg.References[0].Ratio(); ratio != 0 {
+		t.Fatalf("expected match_ratio 0 to be kept, got %v", ratio)
+	}
+
+	payments := &Producer{rng: rand.New(rand.NewSource(2))}
+	payments.avroValues = newAVROValueGenerator(nil, payments.rng, func(string, int) string { return "" })
+	field := SchemaField{Name: "order_id", Type: "string"}
+	payments.addReference(field, pool, cfg.References[0].Ratio())
+
+	for i := 0; i < 1000; i++ {
+		message := map[string]interface{}{"order_id": "order_id-a"}
+		payments.applyReferences(message)
+		if value, _ := unwrapUnion(message["order_id"]).(string); !strings.HasPrefix(value, "orphan-") {
+			t.Fatalf("expected only orphan values with match_ratio 0, got %q", value)
+		}
+	}
+}
+
+func TestWireReferenceChecksTypes(t *testing.T) {
+	orders := loadTestSchema(t, `{"type": "record", "name": "Order", "fields": [{"name": "order_id", "type": "string"}, {"name": "total", "type": "double"}]}`)
+	payments := loadTestSchema(t, `{"type": "record", "name": "Payment", "fields": [{"name": "order_id", "type": ["null", "string"]}, {"name": "amount", "type": "long"}]}`)
+
+	producers := []*sourceProducer{
+		{source: SourceTable{Table: "orders", Topic: "orders"}, schema: orders, producer: &Producer{}},
+		{source: SourceTable{Table: "payments", Topic: "payments"}, schema: payments, producer: &Producer{}},
+	}
+
+	if err := wireReference(producers[1], ReferenceConfig{Field: "order_id", Source: "ORDERS", SourceField: "order_id"}, producers); err != nil {
+		t.Fatalf("wireReference() error = %v", err)
+	}
+	if len(producers[1].producer.references) != 1 || producers[0].producer.published["order_id"] == nil {
+		t.Error("expected the reference to be wired to the orders pool")
+	}
+
+	if err := wireReference(producers[1], ReferenceConfig{Field: "amount", Source: "orders", SourceField: "total"}, producers); err == nil {
+		t.Error("expected error for mismatched field types")
+	}
+	if err := wireReference(producers[1], ReferenceConfig{Field: "order_id", Source: "refunds", SourceField: "order_id"}, producers); err == nil {
+		t.Error("expected error for unknown source")
+	}
+}
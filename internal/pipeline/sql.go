@@ -4,10 +4,10 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"regexp"
 	"sort"
 	"strings"
 
+	"pipegen/internal/flinksql"
 	"pipegen/internal/types"
 )
 
@@ -23,8 +23,31 @@ func NewSQLLoader(projectDir string) *SQLLoader {
 	}
 }
 
-// LoadStatements loads all SQL statements from the sql/ directory
+// LoadStatements loads all SQL statements from the sql/ directory in deployment order: every
+// statement comes after the statements declaring the tables it uses, otherwise in filename order
 func (loader *SQLLoader) LoadStatements() ([]*types.SQLStatement, error) {
+	graph, err := loader.LoadGraph()
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Printf("📖 Loaded %d SQL statements from %s\n", len(graph.Statements), filepath.Join(loader.projectDir, "sql"))
+	for _, stmt := range graph.Statements {
+		if deps := graph.Dependencies[stmt.Name]; len(deps) > 0 {
+			fmt.Printf("  %d. %s (after %s)\n", stmt.Order, stmt.Name, strings.Join(deps, ", "))
+		} else {
+			fmt.Printf("  %d. %s\n", stmt.Order, stmt.Name)
+		}
+	}
+	for _, dangling := range graph.Dangling {
+		fmt.Printf("⚠️  %s\n", dangling)
+	}
+
+	return graph.Statements, nil
+}
+
+// LoadGraph loads all SQL statements from the sql/ directory and orders them by their dependencies
+func (loader *SQLLoader) LoadGraph() (*StatementGraph, error) {
 	sqlDir := filepath.Join(loader.projectDir, "sql")
 
 	// Check if sql directory exists
@@ -57,25 +80,25 @@ func (loader *SQLLoader) LoadStatements() ([]*types.SQLStatement, error) {
 		return nil, fmt.Errorf("no SQL files found in %s", sqlDir)
 	}
 
-	// Sort statements by filename for consistent execution order
+	// Sort statements by filename so statements that do not depend on each other keep a stable order
 	sort.Slice(statements, func(i, j int) bool {
 		return statements[i].Name < statements[j].Name
 	})
 
-	// Assign execution order
-	for i, stmt := range statements {
-		stmt.Order = i + 1
+	graph, err := BuildStatementGraph(statements)
+	if err != nil {
+		return nil, err
 	}
 
-	fmt.Printf("📖 Loaded %d SQL statements from %s\n", len(statements), sqlDir)
-	for _, stmt := range statements {
-		fmt.Printf("  %d. %s\n", stmt.Order, stmt.Name)
+	// Assign execution order
+	for i, stmt := range graph.Statements {
+		stmt.Order = i + 1
 	}
 
-	return statements, nil
+	return graph, nil
 }
 
-// loadStatement loads a single SQL statement from a file
+// loadStatement loads the SQL statements of a file
 func (loader *SQLLoader) loadStatement(filePath string) (*types.SQLStatement, error) {
 	content, err := os.ReadFile(filePath)
 	if err != nil {
@@ -88,6 +111,19 @@ func (loader *SQLLoader) loadStatement(filePath string) (*types.SQLStatement, er
 		return nil, fmt.Errorf("SQL file is empty")
 	}
 
+	// Parse the file as written so the lines of its statements are the lines of the file
+	parsed, err := flinksql.Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("invalid SQL: %w", err)
+	}
+	if len(parsed) == 0 {
+		return nil, fmt.Errorf("SQL file has no statements")
+	}
+	lines := make([]int, len(parsed))
+	for i, stmt := range parsed {
+		lines[i] = stmt.Line
+	}
+
 	// Remove comments and normalize whitespace
 	sqlContent = loader.cleanSQL(sqlContent)
 
@@ -98,30 +134,65 @@ func (loader *SQLLoader) loadStatement(filePath string) (*types.SQLStatement, er
 		Name:     name,
 		Content:  sqlContent,
 		FilePath: filePath,
+		Lines:    lines,
 	}
 
 	return statement, nil
 }
 
-// cleanSQL removes comments and normalizes SQL content
-func (loader *SQLLoader) cleanSQL(sql string) string {
-	lines := strings.Split(sql, "\n")
-	var cleanLines []string
+// StatementPart is one statement of a statement file
+type StatementPart struct {
+	SQL      string
+	Location string // File and line the statement starts on, like 02_sink.sql:4
+	Session  bool   // SET, USE, CREATE FUNCTION and the like change the session for the statements after them
+}
 
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
+// SplitStatement splits a statement file into its statements, in order. SQL that does not parse is
+// returned whole for the gateway to report on.
+func SplitStatement(stmt *types.SQLStatement) []StatementPart {
+	file := stmt.Name + ".sql"
+	if stmt.FilePath != "" {
+		file = filepath.Base(stmt.FilePath)
+	}
 
-		// Skip empty lines and single-line comments
-		if line == "" || strings.HasPrefix(line, "--") {
-			continue
+	parsed, err := flinksql.Parse(stmt.Content)
+	if err != nil || len(parsed) == 0 {
+		line := 1
+		if len(stmt.Lines) > 0 {
+			line = stmt.Lines[0]
+		}
+		return []StatementPart{{SQL: stmt.Content, Location: fmt.Sprintf("%s:%d", file, line)}}
+	}
+
+	parts := make([]StatementPart, len(parsed))
+	for i, p := range parsed {
+		// Content is cleaned of comments and blank lines; the loader kept the lines of the file
+		line := p.Line
+		if len(stmt.Lines) == len(parsed) {
+			line = stmt.Lines[i]
 		}
+		parts[i] = StatementPart{SQL: p.Text, Location: fmt.Sprintf("%s:%d", file, line), Session: p.IsSession()}
+	}
+	return parts
+}
 
-		// Remove inline comments
-		if commentIndex := strings.Index(line, "--"); commentIndex != -1 {
-			line = strings.TrimSpace(line[:commentIndex])
+// changesSession reports whether a statement file holds statements that change the session
+func changesSession(stmt *types.SQLStatement) bool {
+	for _, part := range SplitStatement(stmt) {
+		if part.Session {
+			return true
 		}
+	}
+	return false
+}
 
-		if line != "" {
+// cleanSQL removes comments and normalizes SQL content. Comment markers inside string literals are kept.
+func (loader *SQLLoader) cleanSQL(sql string) string {
+	lines := strings.Split(flinksql.StripComments(sql), "\n")
+	var cleanLines []string
+
+	for _, line := range lines {
+		if line = strings.TrimSpace(line); line != "" {
 			cleanLines = append(cleanLines, line)
 		}
 	}
@@ -129,41 +200,31 @@ func (loader *SQLLoader) cleanSQL(sql string) string {
 	return strings.Join(cleanLines, "\n")
 }
 
+// dangerousStatements are the statements ValidateStatement refuses to deploy
+var dangerousStatements = []string{"DROP DATABASE", "DROP SCHEMA", "TRUNCATE", "DELETE FROM"}
+
 // ValidateStatement performs basic validation on a SQL statement
 func (loader *SQLLoader) ValidateStatement(statement *types.SQLStatement) error {
-	sql := strings.ToUpper(statement.Content)
-
-	// Check for dangerous operations in production
-	dangerousOperations := []string{
-		"DROP DATABASE",
-		"DROP SCHEMA",
-		"TRUNCATE",
-		"DELETE FROM",
+	parsed, err := flinksql.Parse(statement.Content)
+	if err != nil {
+		return fmt.Errorf("invalid SQL: %w", err)
 	}
 
-	for _, op := range dangerousOperations {
-		if strings.Contains(sql, op) {
-			return fmt.Errorf("potentially dangerous operation detected: %s", op)
+	// Check for dangerous operations in production
+	for _, stmt := range parsed {
+		for _, op := range dangerousStatements {
+			if stmt.Keyword(len(strings.Fields(op))) == op {
+				return fmt.Errorf("potentially dangerous operation detected: %s", op)
+			}
 		}
 	}
 
-	// Check for required FlinkSQL keywords
-	if !strings.Contains(sql, "CREATE TABLE") &&
-		!strings.Contains(sql, "INSERT INTO") &&
-		!strings.Contains(sql, "SELECT") {
-		return fmt.Errorf("statement must contain CREATE TABLE, INSERT INTO, or SELECT")
-	}
-
-	// Validate variable placeholders
-	requiredVars := []string{"${INPUT_TOPIC}", "${OUTPUT_TOPIC}", "${BOOTSTRAP_SERVERS}"}
-	for _, variable := range requiredVars {
-		if strings.Contains(statement.Content, variable) {
-			// Variable is used, which is good for template statements
-			continue
-		}
+	// Check for required FlinkSQL statements
+	switch loader.getStatementType(statement.Content) {
+	case "CREATE_TABLE", "INSERT", "CREATE_VIEW", "QUERY":
+		return nil
 	}
-
-	return nil
+	return fmt.Errorf("statement must contain CREATE TABLE, INSERT INTO, or SELECT")
 }
 
 // GetStatementsByType categorizes statements by their type
@@ -180,18 +241,33 @@ func (loader *SQLLoader) GetStatementsByType(statements []*types.SQLStatement) m
 
 // getStatementType determines the type of SQL statement
 func (loader *SQLLoader) getStatementType(content string) string {
-	upperContent := strings.ToUpper(content)
-
-	if strings.Contains(upperContent, "CREATE TABLE") {
-		return "CREATE_TABLE"
-	} else if strings.Contains(upperContent, "INSERT INTO") {
-		return "INSERT"
-	} else if strings.Contains(upperContent, "CREATE VIEW") {
-		return "CREATE_VIEW"
-	} else if strings.Contains(upperContent, "SELECT") && !strings.Contains(upperContent, "CREATE") {
-		return "QUERY"
-	} else {
-		return "OTHER"
+	return StatementType(content)
+}
+
+// StatementType returns the type of the SQL of a statement file, like CREATE_TABLE or INSERT. A file with
+// several statements takes the type of its most significant one: a table declaration, then an INSERT,
+// a view and a query.
+func StatementType(content string) string {
+	parsed, err := flinksql.Parse(content)
+	if err != nil {
+		return string(flinksql.KindOther)
+	}
+	kinds := make(map[flinksql.Kind]bool)
+	for _, stmt := range parsed {
+		kinds[stmt.Kind] = true
+	}
+
+	switch {
+	case kinds[flinksql.KindCreateTable]:
+		return string(flinksql.KindCreateTable)
+	case kinds[flinksql.KindInsert], kinds[flinksql.KindStatementSet]:
+		return string(flinksql.KindInsert)
+	case kinds[flinksql.KindCreateView]:
+		return string(flinksql.KindCreateView)
+	case kinds[flinksql.KindQuery]:
+		return string(flinksql.KindQuery)
+	default:
+		return string(flinksql.KindOther)
 	}
 }
 
@@ -207,60 +283,35 @@ type StatementExecution struct {
 
 // ExtractTopicsFromSQL extracts topic names from CREATE TABLE statements
 func (loader *SQLLoader) ExtractTopicsFromSQL(statements []*types.SQLStatement) []string {
+	return ExtractTopics(statements)
+}
+
+// ExtractTopics returns the topics of the tables declared in the statements, in declaration order
+func ExtractTopics(statements []*types.SQLStatement) []string {
 	var topics []string
 	topicSet := make(map[string]bool) // Use map to avoid duplicates
 
-	for _, stmt := range statements {
-		// Look for CREATE TABLE statements
-		if strings.Contains(strings.ToUpper(stmt.Content), "CREATE TABLE") {
-			topic := loader.extractTopicFromCreateTable(stmt.Content)
-			if topic != "" && !topicSet[topic] {
-				topics = append(topics, topic)
-				topicSet[topic] = true
-			}
+	for _, stmt := range parseStatements(statements) {
+		if stmt.Table == nil {
+			continue
+		}
+		if topic := stmt.Table.Topic(); topic != "" && !topicSet[topic] {
+			topics = append(topics, topic)
+			topicSet[topic] = true
 		}
 	}
 
 	return topics
 }
 
-// extractTopicFromCreateTable extracts topic name from a CREATE TABLE statement
-func (loader *SQLLoader) extractTopicFromCreateTable(sql string) string {
-	// Look for 'topic' = 'value' pattern
-	lines := strings.Split(sql, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if strings.Contains(line, "'topic'") && strings.Contains(line, "=") {
-			// Use regex to extract the topic value more reliably
-			re := regexp.MustCompile(`'topic'\s*=\s*'([^']+)'`)
-			matches := re.FindStringSubmatch(line)
-			if len(matches) > 1 {
-				return matches[1]
-			}
-
-			// Fallback to the old method if regex fails
-			parts := strings.Split(line, "'topic'")
-			if len(parts) > 1 {
-				afterTopic := parts[1]
-				if strings.Contains(afterTopic, "=") {
-					valueParts := strings.Split(afterTopic, "=")
-					if len(valueParts) > 1 {
-						value := strings.TrimSpace(valueParts[1])
-						// Find the content between single quotes
-						start := strings.Index(value, "'")
-						if start != -1 {
-							end := strings.Index(value[start+1:], "'")
-							if end != -1 {
-								topic := value[start+1 : start+1+end]
-								fmt.Printf("DEBUG: Extracted topic using fallback: %s\n", topic)
-								return topic
-							}
-						}
-					}
-				}
-			}
+// parseStatements parses the SQL of the statements, in order. Statements that do not parse are left
+// out; LoadStatements rejects them before they get here.
+func parseStatements(statements []*types.SQLStatement) []*flinksql.Statement {
+	var parsed []*flinksql.Statement
+	for _, stmt := range statements {
+		if list, err := flinksql.Parse(stmt.Content); err == nil {
+			parsed = append(parsed, list...)
 		}
 	}
-
-	return ""
+	return parsed
 }
@@ -0,0 +1,115 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"pipegen/internal/types"
+)
+
+func TestSQLLoaderLoadStatements(t *testing.T) {
+	dir := t.TempDir()
+	sqlDir := filepath.Join(dir, "sql")
+	if err := os.MkdirAll(sqlDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	files := map[string]string{
+		"01_source.sql": "-- Orders\nCREATE TABLE orders (\n  id STRING -- key\n) WITH (\n  'connector' = 'kafka',\n  'topic' = 'orders--v1'\n);\n",
+		"02_sink.sql":   "CREATE TABLE totals (id STRING) WITH ('connector' = 'kafka', 'topic' = 'totals');\nINSERT INTO totals SELECT id FROM orders;",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(sqlDir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	loader := NewSQLLoader(dir)
+	statements, err := loader.LoadStatements()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "CREATE TABLE orders (\nid STRING\n) WITH (\n'connector' = 'kafka',\n'topic' = 'orders--v1'\n);"
+	if statements[0].Content != want {
+		t.Errorf("comments should be removed, but not from literals:\n%s", statements[0].Content)
+	}
+	if topics := loader.ExtractTopicsFromSQL(statements); !reflect.DeepEqual(topics, []string{"orders--v1", "totals"}) {
+		t.Errorf("topics = %v", topics)
+	}
+	if byType := loader.GetStatementsByType(statements); len(byType["CREATE_TABLE"]) != 2 {
+		t.Errorf("statements by type = %v", byType)
+	}
+
+	if err := os.WriteFile(filepath.Join(sqlDir, "03_broken.sql"), []byte("INSERT INTO totals\nSELECT id FROM (orders"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := loader.LoadStatements(); err == nil || !strings.Contains(err.Error(), "03_broken.sql: invalid SQL: line 2, column 16: unclosed '('") {
+		t.Errorf("expected a syntax error with its position, got %v", err)
+	}
+}
+
+func TestSQLLoaderValidateStatement(t *testing.T) {
+	loader := NewSQLLoader("")
+	tests := []struct {
+		sql     string
+		wantErr string
+	}{
+		{sql: "INSERT INTO totals SELECT id FROM orders"},
+		{sql: "CREATE VIEW v AS SELECT 'DELETE FROM x' AS note FROM orders"},
+		{sql: "DELETE FROM orders WHERE id = 1", wantErr: "DELETE FROM"},
+		{sql: "SET 'pipeline.name' = 'orders'", wantErr: "must contain"},
+		{sql: "SELECT 'open", wantErr: "unterminated string literal"},
+	}
+	for _, tt := range tests {
+		err := loader.ValidateStatement(&types.SQLStatement{Content: tt.sql})
+		if tt.wantErr == "" && err != nil || tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+			t.Errorf("ValidateStatement(%q) = %v, want %q", tt.sql, err, tt.wantErr)
+		}
+	}
+}
+
+func TestSplitStatement(t *testing.T) {
+	dir := t.TempDir()
+	sqlDir := filepath.Join(dir, "sql")
+	if err := os.MkdirAll(sqlDir, 0755); err != nil {
+		t.Fatal(err)
+	}
+	content := `
+-- Job settings for the statements below
+SET 'pipeline.name' = 'totals; hourly';
+USE CATALOG default_catalog;
+
+CREATE TABLE totals (id STRING) WITH ('connector' = 'kafka', 'topic' = 'totals');
+
+/* The job */
+INSERT INTO totals
+SELECT id FROM orders;
+`
+	if err := os.WriteFile(filepath.Join(sqlDir, "01_totals.sql"), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	statements, err := NewSQLLoader(dir).LoadStatements()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []StatementPart{
+		{SQL: "SET 'pipeline.name' = 'totals; hourly'", Location: "01_totals.sql:3", Session: true},
+		{SQL: "USE CATALOG default_catalog", Location: "01_totals.sql:4", Session: true},
+		{SQL: "CREATE TABLE totals (id STRING) WITH ('connector' = 'kafka', 'topic' = 'totals')", Location: "01_totals.sql:6"},
+		{SQL: "INSERT INTO totals\nSELECT id FROM orders", Location: "01_totals.sql:9"},
+	}
+	if parts := SplitStatement(statements[0]); !reflect.DeepEqual(parts, want) {
+		t.Errorf("parts = %+v", parts)
+	}
+	if !changesSession(statements[0]) {
+		t.Error("the file should change the session")
+	}
+
+	unparsable := &types.SQLStatement{Name: "02_broken", Content: "SELECT 'open", Lines: []int{7}}
+	if parts := SplitStatement(unparsable); len(parts) != 1 || parts[0].SQL != "SELECT 'open" || parts[0].Location != "02_broken.sql:7" {
+		t.Errorf("SQL that does not parse should be kept whole, got %+v", parts)
+	}
+}
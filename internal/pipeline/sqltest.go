@@ -0,0 +1,707 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"pipegen/internal/flinksql"
+	"pipegen/internal/types"
+
+	"github.com/google/uuid"
+	"github.com/riferrei/srclient"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultSQLTestsDir is the project directory holding the test cases run by pipegen test
+const DefaultSQLTestsDir = "tests"
+
+// DefaultSQLTestTimeout bounds a test case that does not set its own timeout
+const DefaultSQLTestTimeout = 2 * time.Minute
+
+// Layout of a test case directory
+const (
+	sqlTestInputDir    = "input"
+	sqlTestExpectedDir = "expected"
+	sqlTestSettings    = "test.yaml"
+)
+
+// sqlTestTeardownTimeout bounds the teardown of a case, which runs even when the case timed out
+const sqlTestTeardownTimeout = 30 * time.Second
+
+// topicNameUnsafe matches what a table or topic name cannot keep in the name of a temporary topic
+var topicNameUnsafe = regexp.MustCompile(`[^a-z0-9._-]+`)
+
+// SQLTestCase is a test case of the project's SQL, read from tests/<name>/:
+//
+//	input/<table>.jsonl      # fixture records of a source table (JSONL, CSV or AVRO)
+//	expected/<table>.jsonl   # rows a sink table must receive (JSONL or a YAML list)
+//	test.yaml                # optional settings
+//
+// test.yaml sets a description, a timeout and how the rows of each sink are matched:
+//
+//	description: Revenue is summed per customer
+//	timeout: 90s
+//	sinks:
+//	  revenue_per_customer:
+//	    key: [customer_id]
+//	    tolerance: 0.01
+//	    allow_extra: false
+type SQLTestCase struct {
+	Name        string                   `yaml:"-"`
+	Dir         string                   `yaml:"-"`
+	Description string                   `yaml:"description"`
+	Timeout     time.Duration            `yaml:"timeout"`
+	Sinks       map[string]*Expectations `yaml:"sinks"` // Expected rows per sink table, keyed by normalized table name
+
+	Inputs map[string]string `yaml:"-"` // Fixture file per source table, keyed by normalized table name
+}
+
+// LoadSQLTests reads every test case in dir, in name order
+func LoadSQLTests(dir string) ([]*SQLTestCase, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("tests directory not accessible: %w", err)
+	}
+
+	var cases []*SQLTestCase
+	for _, entry := range entries {
+		if !entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		tc, err := LoadSQLTestCase(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("test case %s: %w", entry.Name(), err)
+		}
+		cases = append(cases, tc)
+	}
+	if len(cases) == 0 {
+		return nil, fmt.Errorf("no test cases in %s (add %s/<case>/%s and %s/<case>/%s)", dir, dir, sqlTestInputDir, dir, sqlTestExpectedDir)
+	}
+	return cases, nil
+}
+
+// LoadSQLTestCase reads one test case directory: its settings, its fixtures and its expected rows
+func LoadSQLTestCase(dir string) (*SQLTestCase, error) {
+	tc := &SQLTestCase{Name: filepath.Base(dir), Dir: dir}
+	settings := filepath.Join(dir, sqlTestSettings)
+	if content, err := os.ReadFile(settings); err == nil {
+		if err := yaml.Unmarshal(content, tc); err != nil {
+			return nil, fmt.Errorf("failed to parse %s: %w", settings, err)
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read %s: %w", settings, err)
+	}
+	if tc.Timeout < 0 {
+		return nil, fmt.Errorf("timeout must not be negative")
+	}
+
+	// Settings of sinks are keyed like the expected files
+	configured := make(map[string]*Expectations, len(tc.Sinks))
+	for name, sink := range tc.Sinks {
+		if sink == nil {
+			sink = &Expectations{}
+		}
+		configured[normalizeTableName(name)] = sink
+	}
+
+	tc.Inputs = make(map[string]string)
+	inputs, err := sqlTestFiles(filepath.Join(dir, sqlTestInputDir))
+	if err != nil {
+		return nil, err
+	}
+	for table, file := range inputs {
+		if _, err := replayFormat(file); err != nil {
+			return nil, err
+		}
+		tc.Inputs[table] = file
+	}
+
+	tc.Sinks = make(map[string]*Expectations)
+	expected, err := sqlTestFiles(filepath.Join(dir, sqlTestExpectedDir))
+	if err != nil {
+		return nil, err
+	}
+	if len(expected) == 0 {
+		return nil, fmt.Errorf("no expected rows in %s", filepath.Join(dir, sqlTestExpectedDir))
+	}
+	for table, file := range expected {
+		sink := configured[table]
+		if sink == nil {
+			sink = &Expectations{}
+		}
+		delete(configured, table)
+		sink.Dir, sink.Expected = filepath.Dir(file), filepath.Base(file)
+		if err := sink.loadRecords(); err != nil {
+			return nil, fmt.Errorf("sink %s: %w", table, err)
+		}
+		tc.Sinks[table] = sink
+	}
+	for table := range configured {
+		return nil, fmt.Errorf("%s configures sink %s, but %s has no rows for it", sqlTestSettings, table, sqlTestExpectedDir)
+	}
+	return tc, nil
+}
+
+// sqlTestFiles maps the files of a fixture directory onto the tables they belong to; a missing directory has none
+func sqlTestFiles(dir string) (map[string]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", dir, err)
+	}
+	files := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		table := normalizeTableName(strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())))
+		if other, ok := files[table]; ok {
+			return nil, fmt.Errorf("%s and %s both hold rows of table %s", filepath.Base(other), entry.Name(), table)
+		}
+		files[table] = filepath.Join(dir, entry.Name())
+	}
+	return files, nil
+}
+
+// SQLTestSinkResult is the comparison of one sink table with its expected rows
+type SQLTestSinkResult struct {
+	Table      string
+	Topic      string // Temporary topic of the case
+	Received   int    // Rows consumed before the comparison
+	Assertions *AssertionReport
+}
+
+// SQLTestResult is the outcome of a test case
+type SQLTestResult struct {
+	Name        string
+	Description string
+	Duration    time.Duration
+	Sinks       []SQLTestSinkResult
+	Err         error // The case could not run to the comparison (bad fixture, deployment failure, ...)
+}
+
+// Passed reports whether the case ran and every sink got its expected rows
+func (r *SQLTestResult) Passed() bool {
+	if r.Err != nil {
+		return false
+	}
+	for _, sink := range r.Sinks {
+		if !sink.Assertions.Passed {
+			return false
+		}
+	}
+	return true
+}
+
+// Summary is a one-line verdict of the case
+func (r *SQLTestResult) Summary() string {
+	if r.Err != nil {
+		return fmt.Sprintf("ERROR: %v", r.Err)
+	}
+	var failed []string
+	for _, sink := range r.Sinks {
+		if !sink.Assertions.Passed {
+			failed = append(failed, fmt.Sprintf("%s %s", sink.Table, sink.Assertions.Summary()))
+		}
+	}
+	if len(failed) > 0 {
+		return strings.Join(failed, "; ")
+	}
+	return fmt.Sprintf("PASSED: %d sink(s) matched", len(r.Sinks))
+}
+
+// sqlTestInput is a fixture file and the source table it is produced to
+type sqlTestInput struct {
+	table  SourceTable // Source table with the temporary topic of the case
+	schema *Schema
+	path   string
+	count  int64 // Records in the fixture file
+}
+
+// sqlTestSink is a sink table with the temporary topic of the case and its expected rows
+type sqlTestSink struct {
+	table    SourceTable
+	expected *Expectations
+}
+
+// sqlTestPlan is a case mapped onto the project: the project's SQL with the topics swapped for
+// temporary ones, and the fixtures and expectations of the tables on those topics
+type sqlTestPlan struct {
+	topics     map[string]string // Topic in the project's SQL -> temporary topic of the case
+	statements []*types.SQLStatement
+	resources  *Resources
+	inputs     []sqlTestInput
+	sinks      []sqlTestSink
+}
+
+// SQLTestRunner runs test cases against the stack: every case gets its own topics and Flink
+// session, and is torn down before the next one starts
+type SQLTestRunner struct {
+	config     *Config
+	statements []*types.SQLStatement
+	schemas    map[string]*Schema
+	timeout    time.Duration // Timeout of cases without their own
+
+	// Stack operations; the defaults use Kafka and Schema Registry, tests swap them
+	createTopic   func(ctx context.Context, topic string) error
+	deleteTopic   func(ctx context.Context, topic string) error
+	deleteSubject func(subject string) error
+	produce       func(ctx context.Context, input sqlTestInput) (int64, error)
+	consume       func(ctx context.Context, sinks []sqlTestSink) (map[string][]map[string]interface{}, error)
+	newDeployer   func() *FlinkDeployer
+}
+
+// NewSQLTestRunner loads the project's SQL and schemas for running test cases; timeout applies to
+// cases that do not set their own (0 uses DefaultSQLTestTimeout)
+func NewSQLTestRunner(config *Config, timeout time.Duration) (*SQLTestRunner, error) {
+	statements, err := NewSQLLoader(config.ProjectDir).LoadStatements()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SQL statements: %w", err)
+	}
+	if statements, err = config.Variables.ResolveStatements(statements); err != nil {
+		return nil, err
+	}
+	if len(ExtractSinkTables(statements)) == 0 {
+		return nil, fmt.Errorf("the project's SQL writes to no Kafka table, so there is nothing to test")
+	}
+	// Fixtures need a schema; SQL-only projects can still test sources without fixtures
+	schemas, err := NewSchemaLoader(config.ProjectDir).LoadSchemas()
+	if err != nil {
+		schemas = make(map[string]*Schema)
+	}
+	if timeout <= 0 {
+		timeout = DefaultSQLTestTimeout
+	}
+
+	r := &SQLTestRunner{config: config, statements: statements, schemas: schemas, timeout: timeout}
+	resourceMgr := NewResourceManager(config)
+	r.createTopic = func(ctx context.Context, topic string) error {
+		cfg := resourceMgr.GetDefaultTopicConfig(topic)
+		return resourceMgr.Kafka.CreateTopic(ctx, topic, cfg.Partitions, cfg.ReplicationFactor)
+	}
+	r.deleteTopic = resourceMgr.Kafka.DeleteTopic
+	r.deleteSubject = func(subject string) error {
+		return srclient.CreateSchemaRegistryClient(config.SchemaRegistryURL).DeleteSubject(subject, true)
+	}
+	r.produce = r.produceFixture
+	r.consume = r.consumeSinks
+	r.newDeployer = func() *FlinkDeployer { return NewFlinkDeployer(config) }
+	return r, nil
+}
+
+// Run runs the cases one after another. A case that cannot run is reported in its result; Run
+// only stops early when ctx is cancelled.
+func (r *SQLTestRunner) Run(ctx context.Context, cases []*SQLTestCase) []*SQLTestResult {
+	results := make([]*SQLTestResult, 0, len(cases))
+	for i, tc := range cases {
+		if ctx.Err() != nil {
+			break
+		}
+		fmt.Printf("\n🧪 [%d/%d] %s\n", i+1, len(cases), tc.Name)
+		result := r.RunCase(ctx, tc)
+		if result.Passed() {
+			fmt.Printf("✅ %s %s (%v)\n", tc.Name, result.Summary(), result.Duration.Round(time.Millisecond))
+		} else {
+			fmt.Printf("❌ %s %s (%v)\n", tc.Name, result.Summary(), result.Duration.Round(time.Millisecond))
+			for _, sink := range result.Sinks {
+				if !sink.Assertions.Passed {
+					fmt.Printf("   %s:\n", sink.Table)
+					sink.Assertions.Print(10)
+				}
+			}
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// RunCase loads the fixtures of a case into temporary topics, deploys the SQL against them, waits
+// for the expected rows and compares them. Everything the case created is removed afterwards.
+func (r *SQLTestRunner) RunCase(ctx context.Context, tc *SQLTestCase) *SQLTestResult {
+	result := &SQLTestResult{Name: tc.Name, Description: tc.Description}
+	start := time.Now()
+	result.Err = r.runCase(ctx, tc, result)
+	result.Duration = time.Since(start)
+	return result
+}
+
+func (r *SQLTestRunner) runCase(ctx context.Context, tc *SQLTestCase, result *SQLTestResult) error {
+	plan, err := r.plan(tc)
+	if err != nil {
+		return err
+	}
+
+	timeout := tc.Timeout
+	if timeout <= 0 {
+		timeout = r.timeout
+	}
+	caseCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	deployer := r.newDeployer()
+	var deploymentIDs []string
+	var created []string
+	defer func() { r.teardown(plan, deployer, deploymentIDs, created) }()
+
+	for _, topic := range plan.resources.Topics {
+		if err := r.createTopic(caseCtx, topic); err != nil {
+			return fmt.Errorf("failed to create topic %s: %w", topic, err)
+		}
+		created = append(created, topic)
+	}
+
+	// Fixtures go in before the SQL starts; the sources read them from the earliest offset
+	for _, input := range plan.inputs {
+		sent, err := r.produce(caseCtx, input)
+		if err != nil {
+			return fmt.Errorf("failed to load the fixture of %s: %w", input.table.Table, err)
+		}
+		if sent != input.count {
+			return fmt.Errorf("loaded %d of the %d fixture records of %s", sent, input.count, input.table.Table)
+		}
+		fmt.Printf("📥 Loaded %d record(s) into %s\n", sent, input.table.Table)
+	}
+
+	deploymentIDs, err = deployer.Deploy(caseCtx, plan.statements, plan.resources)
+	if err != nil {
+		return fmt.Errorf("failed to deploy the SQL: %w", err)
+	}
+
+	rows, err := r.consume(caseCtx, plan.sinks)
+	if err != nil {
+		return fmt.Errorf("failed to consume the sinks: %w", err)
+	}
+	for _, sink := range plan.sinks {
+		actual := rows[sink.table.Topic]
+		if len(actual) < len(sink.expected.Records) && caseCtx.Err() != nil {
+			fmt.Printf("⏰ %s got %d of %d expected row(s) within %v\n", sink.table.Table, len(actual), len(sink.expected.Records), timeout)
+		}
+		result.Sinks = append(result.Sinks, SQLTestSinkResult{
+			Table:      sink.table.Table,
+			Topic:      sink.table.Topic,
+			Received:   len(actual),
+			Assertions: sink.expected.Check(actual),
+		})
+	}
+	return nil
+}
+
+// plan maps a case onto the project's SQL: every topic gets a temporary name unique to this run,
+// sources read from the earliest offset, and fixtures and expected rows are matched with their tables
+func (r *SQLTestRunner) plan(tc *SQLTestCase) (*sqlTestPlan, error) {
+	prefix := fmt.Sprintf("pipegen-test-%s-%s", topicNameUnsafe.ReplaceAllString(strings.ToLower(tc.Name), "-"), uuid.New().String()[:8])
+	plan := &sqlTestPlan{topics: make(map[string]string), resources: &Resources{Prefix: prefix}}
+	for _, topic := range ExtractTopics(r.statements) {
+		plan.topics[topic] = fmt.Sprintf("%s-%s", prefix, topicNameUnsafe.ReplaceAllString(strings.ToLower(topic), "-"))
+		plan.resources.Topics = append(plan.resources.Topics, plan.topics[topic])
+	}
+
+	sources := ExtractSourceTables(r.statements)
+	sinks := ExtractSinkTables(r.statements)
+	kafkaSources := make(map[string]bool, len(sources))
+	for _, source := range sources {
+		kafkaSources[normalizeTableName(source.Table)] = true
+	}
+	for _, stmt := range r.statements {
+		rewritten := *stmt
+		rewritten.Content = rewriteTestSQL(stmt.Content, plan.topics, kafkaSources)
+		plan.statements = append(plan.statements, &rewritten)
+	}
+
+	unmatched := make(map[string]bool, len(tc.Inputs))
+	for table := range tc.Inputs {
+		unmatched[table] = true
+	}
+	for i, source := range sources {
+		source.Topic = plan.topics[source.Topic]
+		plan.resources.Sources = append(plan.resources.Sources, source)
+		path, ok := tc.Inputs[normalizeTableName(source.Table)]
+		if !ok {
+			continue
+		}
+		delete(unmatched, normalizeTableName(source.Table))
+		_, schema := sourceSchema(source, lookupSourceConfig(r.config.Sources, source), r.schemas, i == 0)
+		if schema == nil {
+			return nil, fmt.Errorf("no schema for source table %s (add schemas/%s.avsc)", source.Table, normalizeTableName(source.Table))
+		}
+		count, err := validateFixture(path, schema)
+		if err != nil {
+			return nil, fmt.Errorf("fixture of %s: %w", source.Table, err)
+		}
+		plan.inputs = append(plan.inputs, sqlTestInput{table: source, schema: schema, path: path, count: count})
+	}
+	if table := firstUnmatched(unmatched); table != "" {
+		return nil, fmt.Errorf("%s/%s is not a Kafka source table of the project's SQL", sqlTestInputDir, table)
+	}
+
+	unmatched = make(map[string]bool, len(tc.Sinks))
+	for table := range tc.Sinks {
+		unmatched[table] = true
+	}
+	for _, sink := range sinks {
+		sink.Topic = plan.topics[sink.Topic]
+		plan.resources.Sinks = append(plan.resources.Sinks, sink)
+		if expected, ok := tc.Sinks[normalizeTableName(sink.Table)]; ok {
+			delete(unmatched, normalizeTableName(sink.Table))
+			plan.sinks = append(plan.sinks, sqlTestSink{table: sink, expected: expected})
+		}
+	}
+	if table := firstUnmatched(unmatched); table != "" {
+		return nil, fmt.Errorf("%s/%s is not a Kafka sink table of the project's SQL", sqlTestExpectedDir, table)
+	}
+
+	if len(plan.resources.Sources) > 0 {
+		plan.resources.InputTopic = plan.resources.Sources[0].Topic
+	}
+	plan.resources.OutputTopic = plan.resources.Sinks[0].Topic
+	return plan, nil
+}
+
+// firstUnmatched returns the first table name of a set in sorted order, or "" when it is empty
+func firstUnmatched(tables map[string]bool) string {
+	names := make([]string, 0, len(tables))
+	for name := range tables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	if len(names) == 0 {
+		return ""
+	}
+	return names[0]
+}
+
+// rewriteTestSQL swaps the topics of a statement for the temporary topics of a case and makes the
+// kafka source tables among sources read from the earliest offset, so they see the fixtures loaded
+// before the statement was deployed
+func rewriteTestSQL(sql string, topics map[string]string, sources map[string]bool) string {
+	statements, err := flinksql.Parse(sql)
+	if err != nil {
+		return sql
+	}
+	rewriter := flinksql.NewRewriter(sql)
+	for _, stmt := range statements {
+		table := stmt.Table
+		if table == nil {
+			continue
+		}
+		if temporary, ok := topics[table.Topic()]; ok {
+			rewriter.SetOption(table, "topic", temporary)
+		}
+		if sources[normalizeTableName(table.Name)] && strings.EqualFold(table.Connector(), "kafka") {
+			rewriter.SetOptionAfter(table, "connector", "scan.startup.mode", "earliest-offset")
+		}
+	}
+	return rewriter.String()
+}
+
+// validateFixture checks that every record of a fixture file fits the schema of its table and
+// counts them. The replay producer skips such records; a test case must not.
+func validateFixture(path string, schema *Schema) (int64, error) {
+	source, err := OpenReplaySource(path)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = source.Close() }()
+
+	values := newAVROValueGenerator(schema, rand.New(rand.NewSource(1)), nil)
+	var count int64
+	for {
+		record, err := source.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return count, fmt.Errorf("failed to read %s: %w", filepath.Base(path), err)
+		}
+		count++
+		if _, err := values.ReplayRecord(record, schema); err != nil {
+			return count, fmt.Errorf("record %d of %s: %w", count, filepath.Base(path), err)
+		}
+	}
+	if count == 0 {
+		return 0, fmt.Errorf("%s holds no records", filepath.Base(path))
+	}
+	return count, nil
+}
+
+// produceFixture replays a fixture file into the topic of its source table as fast as possible
+func (r *SQLTestRunner) produceFixture(ctx context.Context, input sqlTestInput) (int64, error) {
+	cfg := *r.config
+	cfg.Replay = &ReplayConfig{Path: input.path, Pace: ReplayPaceMax, Speed: 1}
+	cfg.TrafficPatterns, cfg.KeyStrategy, cfg.Disorder, cfg.Faults, cfg.Expectations = nil, nil, nil, nil, nil
+	producer, err := NewProducer(&cfg)
+	if err != nil {
+		return 0, err
+	}
+	defer producer.Close()
+	if err := producer.Start(ctx, input.table.Topic, input.schema); err != nil {
+		return 0, err
+	}
+	return producer.GetStats().MessagesSent, nil
+}
+
+// consumeSinks reads the sink topics until each got as many rows as expected, or ctx ends
+func (r *SQLTestRunner) consumeSinks(ctx context.Context, sinks []sqlTestSink) (map[string][]map[string]interface{}, error) {
+	consumer, err := NewConsumer(r.config)
+	if err != nil {
+		return nil, err
+	}
+	defer consumer.Close()
+
+	recorders := make(map[string]*outputRecorder, len(sinks))
+	topics := make([]string, 0, len(sinks))
+	for _, sink := range sinks {
+		recorder := &outputRecorder{}
+		recorders[sink.table.Topic] = recorder
+		topics = append(topics, sink.table.Topic)
+		consumer.AddSink(sink.table, int64(len(sink.expected.Records)))
+		consumer.AddTopicObserver(sink.table.Topic, recorder.observe)
+	}
+	if err := consumer.InitializeSchemaRegistry(topics...); err != nil {
+		fmt.Printf("⚠️  Decoding output with writer schemas only: %v\n", err)
+	}
+	if err := consumer.StartWithExpectedCount(ctx, "", 0); err != nil && ctx.Err() == nil {
+		return nil, err
+	}
+
+	rows := make(map[string][]map[string]interface{}, len(recorders))
+	for topic, recorder := range recorders {
+		rows[topic] = recorder.snapshot()
+	}
+	return rows, nil
+}
+
+// teardown stops the statements of a case, closes its session and removes its topics and their
+// subjects. It runs with its own deadline, as the case context may be what ended the case.
+func (r *SQLTestRunner) teardown(plan *sqlTestPlan, deployer *FlinkDeployer, deploymentIDs, topics []string) {
+	ctx, cancel := context.WithTimeout(context.Background(), sqlTestTeardownTimeout)
+	defer cancel()
+
+	// A failed deployment may have submitted jobs before it failed
+	if len(deploymentIDs) > 0 || len(deployer.JobIDs()) > 0 {
+		if err := deployer.Cleanup(ctx, deploymentIDs); err != nil {
+			fmt.Printf("⚠️  Failed to stop the statements of the case: %v\n", err)
+		}
+	}
+	if err := deployer.Close(ctx); err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+	}
+	for _, topic := range topics {
+		if err := r.deleteTopic(ctx, topic); err != nil {
+			fmt.Printf("⚠️  Failed to delete topic %s: %v\n", topic, err)
+		}
+	}
+	for _, input := range plan.inputs {
+		subject := input.table.Topic + "-value"
+		if err := r.deleteSubject(subject); err != nil {
+			fmt.Printf("⚠️  Failed to delete subject %s: %v\n", subject, err)
+		}
+	}
+	for _, sink := range plan.sinks {
+		// Flink registers the subjects of the sinks it writes to; a sink without rows has none
+		_ = r.deleteSubject(sink.table.Topic + "-value")
+	}
+}
+
+// JUnit XML report of the test cases, as read by CI systems
+type junitTestSuites struct {
+	XMLName  xml.Name         `xml:"testsuites"`
+	Tests    int              `xml:"tests,attr"`
+	Failures int              `xml:"failures,attr"`
+	Errors   int              `xml:"errors,attr"`
+	Time     string           `xml:"time,attr"`
+	Suites   []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Time      string          `xml:"time,attr"`
+	Timestamp string          `xml:"timestamp,attr,omitempty"`
+	Cases     []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	Name      string        `xml:"name,attr"`
+	Classname string        `xml:"classname,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitProblem `xml:"failure,omitempty"`
+	Error     *junitProblem `xml:"error,omitempty"`
+	SystemOut string        `xml:"system-out,omitempty"`
+}
+
+type junitProblem struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr"`
+	Text    string `xml:",chardata"`
+}
+
+// WriteJUnitReport writes the results as a JUnit XML report with one test suite named suite.
+// Cases that could not run are errors, cases whose rows differ are failures.
+func WriteJUnitReport(w io.Writer, suite string, started time.Time, results []*SQLTestResult) error {
+	report := junitTestSuite{Name: suite, Tests: len(results)}
+	if !started.IsZero() {
+		report.Timestamp = started.UTC().Format("2006-01-02T15:04:05")
+	}
+	var total time.Duration
+	for _, result := range results {
+		total += result.Duration
+		tc := junitTestCase{Name: result.Name, Classname: suite, Time: junitSeconds(result.Duration), SystemOut: result.Description}
+		switch {
+		case result.Err != nil:
+			report.Errors++
+			tc.Error = &junitProblem{Message: result.Err.Error(), Type: "error", Text: result.Err.Error()}
+		case !result.Passed():
+			report.Failures++
+			var details strings.Builder
+			for _, sink := range result.Sinks {
+				if sink.Assertions.Passed {
+					continue
+				}
+				fmt.Fprintf(&details, "%s (%s): %s\n", sink.Table, sink.Topic, sink.Assertions.Summary())
+				for _, mismatch := range sink.Assertions.Mismatches {
+					fmt.Fprintf(&details, "  %s\n", mismatch)
+				}
+			}
+			tc.Failure = &junitProblem{Message: result.Summary(), Type: "mismatch", Text: details.String()}
+		}
+		report.Cases = append(report.Cases, tc)
+	}
+	report.Time = junitSeconds(total)
+
+	suites := junitTestSuites{
+		Tests:    report.Tests,
+		Failures: report.Failures,
+		Errors:   report.Errors,
+		Time:     report.Time,
+		Suites:   []junitTestSuite{report},
+	}
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+	encoder := xml.NewEncoder(w)
+	encoder.Indent("", "  ")
+	if err := encoder.Encode(suites); err != nil {
+		return fmt.Errorf("failed to write JUnit report: %w", err)
+	}
+	_, err := io.WriteString(w, "\n")
+	return err
+}
+
+// junitSeconds formats a duration the way JUnit reports times
+func junitSeconds(d time.Duration) string {
+	return fmt.Sprintf("%.3f", d.Seconds())
+}
@@ -0,0 +1,285 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const sqlTestSourceTable = `CREATE TABLE orders (
+  order_id STRING,
+  amount DOUBLE
+) WITH (
+  'connector' = 'kafka',
+  'topic' = 'orders',
+  'properties.bootstrap.servers' = 'localhost:9092',
+  'scan.startup.mode' = 'latest-offset',
+  'format' = 'avro-confluent'
+);`
+
+const sqlTestSinkTable = `CREATE TABLE large_orders (
+  order_id STRING,
+  amount DOUBLE
+) WITH (
+  'connector' = 'kafka',
+  'topic' = 'large-orders',
+  'properties.bootstrap.servers' = 'localhost:9092',
+  'format' = 'avro-confluent'
+);`
+
+func writeSQLTestFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestLoadSQLTests(t *testing.T) {
+	dir := t.TempDir()
+	writeSQLTestFiles(t, dir, map[string]string{
+		"large/test.yaml":                  "description: Orders above 100\ntimeout: 90s\nsinks:\n  LARGE_ORDERS:\n    key: [order_id]\n    tolerance: 0.01\n",
+		"large/input/orders.jsonl":         `{"order_id": "o-1", "amount": 250}`,
+		"large/expected/large_orders.yaml": "- {order_id: o-1, amount: 250}\n",
+		"none/expected/large_orders.jsonl": `{"order_id": "o-9"}`,
+		"notes.txt":                        "not a case",
+	})
+	cases, err := LoadSQLTests(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(cases) != 2 || cases[0].Name != "large" || cases[1].Name != "none" {
+		t.Fatalf("unexpected cases: %+v", cases)
+	}
+	large := cases[0]
+	sink := large.Sinks["large_orders"]
+	if large.Description != "Orders above 100" || large.Timeout != 90*time.Second || sink == nil || len(sink.Records) != 1 ||
+		len(sink.Key) != 1 || sink.Tolerance != 0.01 || filepath.Base(large.Inputs["orders"]) != "orders.jsonl" {
+		t.Errorf("unexpected case: %+v (sink %+v)", large, sink)
+	}
+	if len(cases[1].Inputs) != 0 || cases[1].Timeout != 0 {
+		t.Errorf("a case without settings or fixtures should load: %+v", cases[1])
+	}
+
+	for name, files := range map[string]map[string]string{
+		"no expected rows": {"input/orders.jsonl": `{"order_id": "o-1"}`},
+		"unknown sink":     {"test.yaml": "sinks: {alerts: {key: [id]}}", "expected/large_orders.jsonl": `{"order_id": "o-1"}`},
+		"bad fixture":      {"input/orders.parquet": "", "expected/large_orders.jsonl": `{"order_id": "o-1"}`},
+		"missing key":      {"test.yaml": "sinks: {large_orders: {key: [id]}}", "expected/large_orders.jsonl": `{"order_id": "o-1"}`},
+		"two files":        {"expected/large_orders.jsonl": `{"order_id": "o-1"}`, "expected/large_orders.yaml": "- {order_id: o-1}"},
+	} {
+		caseDir := filepath.Join(t.TempDir(), "case")
+		writeSQLTestFiles(t, caseDir, files)
+		if _, err := LoadSQLTestCase(caseDir); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+	if _, err := LoadSQLTests(t.TempDir()); err == nil {
+		t.Error("expected an error for a directory without cases")
+	}
+}
+
+func TestRewriteTestSQL(t *testing.T) {
+	topics := map[string]string{"orders": "pipegen-test-a-1-orders", "large-orders": "pipegen-test-a-1-large-orders"}
+	sources := map[string]bool{"orders": true}
+
+	source := rewriteTestSQL(sqlTestSourceTable, topics, sources)
+	if !strings.Contains(source, "'topic' = 'pipegen-test-a-1-orders'") || !strings.Contains(source, "'scan.startup.mode' = 'earliest-offset'") ||
+		strings.Contains(source, "latest-offset") {
+		t.Errorf("unexpected source table:\n%s", source)
+	}
+
+	// Sinks keep their options; sources without a startup mode get one
+	sink := rewriteTestSQL(sqlTestSinkTable, topics, sources)
+	if !strings.Contains(sink, "'topic' = 'pipegen-test-a-1-large-orders'") || strings.Contains(sink, "scan.startup.mode") {
+		t.Errorf("unexpected sink table:\n%s", sink)
+	}
+	noMode := strings.Replace(sqlTestSourceTable, "  'scan.startup.mode' = 'latest-offset',\n", "", 1)
+	if rewritten := rewriteTestSQL(noMode, topics, sources); !strings.Contains(rewritten, "'connector' = 'kafka',\n  'scan.startup.mode' = 'earliest-offset',") {
+		t.Errorf("startup mode should follow the connector:\n%s", rewritten)
+	}
+
+	// upsert-kafka reads its whole topic anyway, and unknown topics are left alone
+	upsert := "CREATE TABLE orders (id STRING) WITH ('connector' = 'upsert-kafka', 'topic' = 'other')"
+	if rewritten := rewriteTestSQL(upsert, topics, sources); rewritten != upsert {
+		t.Errorf("upsert-kafka table changed:\n%s", rewritten)
+	}
+}
+
+// stubFlinkGateway answers the SQL Gateway and REST calls of a deployment and records the statements
+type stubFlinkGateway struct {
+	mu         sync.Mutex
+	statements []string
+}
+
+func (g *stubFlinkGateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/sessions":
+		_, _ = w.Write([]byte(`{"sessionHandle":"session-1"}`))
+	case r.Method == http.MethodPost && r.URL.Path == "/v1/sessions/session-1/statements":
+		var body struct {
+			Statement string `json:"statement"`
+		}
+		_ = json.NewDecoder(r.Body).Decode(&body)
+		g.mu.Lock()
+		g.statements = append(g.statements, body.Statement)
+		operation := len(g.statements)
+		g.mu.Unlock()
+		_, _ = fmt.Fprintf(w, `{"operationHandle":"op-%d"}`, operation)
+	case strings.HasSuffix(r.URL.Path, "/status"):
+		_, _ = w.Write([]byte(`{"status":"FINISHED"}`))
+	case r.URL.Path == "/jobs":
+		_, _ = w.Write([]byte(`{"jobs": []}`))
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func TestSQLTestRunner(t *testing.T) {
+	gateway := &stubFlinkGateway{}
+	server := httptest.NewServer(gateway)
+	defer server.Close()
+	if strings.Contains(server.URL, "8081") {
+		t.Skip("the deployer maps port 8081 onto the SQL Gateway port")
+	}
+
+	project := t.TempDir()
+	writeSQLTestFiles(t, project, map[string]string{
+		"sql/01_source.sql": sqlTestSourceTable,
+		"sql/02_sink.sql":   sqlTestSinkTable,
+		"sql/03_insert.sql": "INSERT INTO large_orders SELECT order_id, amount FROM orders WHERE amount > 100;",
+		"schemas/input.avsc": `{"type": "record", "name": "Order", "fields": [
+			{"name": "order_id", "type": "string"}, {"name": "amount", "type": "double"}]}`,
+		"tests/match/input/orders.jsonl":             "{\"order_id\": \"o-1\", \"amount\": 250}\n{\"order_id\": \"o-2\", \"amount\": 5}\n",
+		"tests/match/expected/large_orders.jsonl":    `{"order_id": "o-1", "amount": 250}`,
+		"tests/mismatch/input/orders.jsonl":          `{"order_id": "o-3", "amount": 120}`,
+		"tests/mismatch/expected/large_orders.jsonl": `{"order_id": "o-3", "amount": 125}`,
+		"tests/poison/input/orders.jsonl":            `{"order_id": "o-4", "amount": "lots"}`,
+		"tests/poison/expected/large_orders.jsonl":   `{"order_id": "o-4"}`,
+	})
+	cases, err := LoadSQLTests(filepath.Join(project, "tests"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config := &Config{ProjectDir: project, FlinkURL: server.URL, BootstrapServers: "localhost:9092"}
+	runner, err := NewSQLTestRunner(config, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Kafka stand-in: the "pipeline" copies large orders from the fixture to the sink topic
+	var mu sync.Mutex
+	topics := make(map[string]bool)
+	var deletedSubjects []string
+	fixtures := make(map[string]string)
+	runner.createTopic = func(ctx context.Context, topic string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		topics[topic] = true
+		return nil
+	}
+	runner.deleteTopic = func(ctx context.Context, topic string) error {
+		mu.Lock()
+		defer mu.Unlock()
+		delete(topics, topic)
+		return nil
+	}
+	runner.deleteSubject = func(subject string) error {
+		deletedSubjects = append(deletedSubjects, subject)
+		return nil
+	}
+	runner.produce = func(ctx context.Context, input sqlTestInput) (int64, error) {
+		if !topics[input.table.Topic] || input.schema.Name != "Order" {
+			return 0, fmt.Errorf("unexpected input %+v", input)
+		}
+		fixtures[input.table.Topic] = input.path
+		return input.count, nil
+	}
+	runner.consume = func(ctx context.Context, sinks []sqlTestSink) (map[string][]map[string]interface{}, error) {
+		rows := make(map[string][]map[string]interface{})
+		for _, path := range fixtures {
+			source, err := OpenReplaySource(path)
+			if err != nil {
+				return nil, err
+			}
+			for {
+				record, err := source.Next()
+				if err == io.EOF {
+					break
+				}
+				if amount, _ := toFloat(record["amount"]); amount > 100 {
+					rows[sinks[0].table.Topic] = append(rows[sinks[0].table.Topic], record)
+				}
+			}
+			_ = source.Close()
+		}
+		fixtures = make(map[string]string)
+		return rows, nil
+	}
+
+	results := runner.Run(context.Background(), cases)
+	if len(results) != 3 {
+		t.Fatalf("got %d results", len(results))
+	}
+	match, mismatch, poison := results[0], results[1], results[2]
+	if !match.Passed() || len(match.Sinks) != 1 || match.Sinks[0].Received != 1 || !strings.HasPrefix(match.Sinks[0].Topic, "pipegen-test-match-") {
+		t.Errorf("match: %s %+v", match.Summary(), match.Sinks)
+	}
+	if mismatch.Passed() || mismatch.Err != nil || !strings.Contains(mismatch.Summary(), "large_orders FAILED") {
+		t.Errorf("mismatch: %s", mismatch.Summary())
+	}
+	if poison.Err == nil || !strings.Contains(poison.Err.Error(), "record 1 of orders.jsonl") {
+		t.Errorf("poison: %v", poison.Err)
+	}
+
+	// Each deployed case got its own topics in the SQL, and its sources read from the start
+	if len(gateway.statements) != 6 {
+		t.Fatalf("got %d statements", len(gateway.statements))
+	}
+	first, second := gateway.statements[0], gateway.statements[3]
+	if !strings.Contains(first, "'topic' = 'pipegen-test-match-") || !strings.Contains(first, "earliest-offset") ||
+		!strings.Contains(second, "'topic' = 'pipegen-test-mismatch-") || strings.Contains(first, "'topic' = 'orders'") {
+		t.Errorf("unexpected statements:\n%s\n%s", first, second)
+	}
+	if !strings.Contains(gateway.statements[1], match.Sinks[0].Topic) {
+		t.Errorf("sink table should write to %s:\n%s", match.Sinks[0].Topic, gateway.statements[1])
+	}
+
+	// Teardown removed every topic and subject
+	if len(topics) != 0 || len(deletedSubjects) != 4 {
+		t.Errorf("left topics %v, deleted subjects %v", topics, deletedSubjects)
+	}
+
+	var out strings.Builder
+	if err := WriteJUnitReport(&out, "shop", time.Now(), results); err != nil {
+		t.Fatal(err)
+	}
+	var report junitTestSuites
+	if err := xml.Unmarshal([]byte(out.String()), &report); err != nil {
+		t.Fatalf("invalid JUnit XML: %v\n%s", err, out.String())
+	}
+	suite := report.Suites[0]
+	if report.Tests != 3 || report.Failures != 1 || report.Errors != 1 || suite.Name != "shop" || len(suite.Cases) != 3 {
+		t.Fatalf("unexpected report:\n%s", out.String())
+	}
+	if suite.Cases[0].Failure != nil || suite.Cases[0].Error != nil || suite.Cases[1].Failure == nil ||
+		!strings.Contains(suite.Cases[1].Failure.Text, "missing {amount: 125") || suite.Cases[2].Error == nil {
+		t.Errorf("unexpected cases:\n%s", out.String())
+	}
+}
@@ -0,0 +1,488 @@
+package pipeline
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/segmentio/kafka-go"
+)
+
+// Output formats of pipegen tail
+const (
+	TailFormatJSON  = "json"  // Pretty-printed JSON object per record
+	TailFormatJSONL = "jsonl" // One JSON object per line
+	TailFormatTable = "table" // One row per record, columns taken from the first record
+)
+
+// maxTailColumnWidth bounds table cells; longer values are cut
+const maxTailColumnWidth = 32
+
+// TailOptions selects which records of a topic, or of a dead-letter file, are printed
+type TailOptions struct {
+	Topic             string
+	File              string // Dead-letter file to print instead of a topic
+	BootstrapServers  string
+	SchemaRegistryURL string // Empty decodes values as JSON or text only
+	Partition         int    // Partition to read, -1 for every partition
+	FromBeginning     bool   // Start at the oldest record instead of waiting for new ones
+	Offset            *int64 // Start offset in each partition; negative counts back from the end
+	Limit             int    // Records to print before stopping (0 reads until cancelled)
+	Filter            string // Records that do not match are skipped (validation expr syntax)
+}
+
+// Validate checks the tail options
+func (o *TailOptions) Validate() error {
+	if o.Topic == "" && o.File == "" {
+		return fmt.Errorf("a topic or dead-letter file is required")
+	}
+	if o.File != "" && (o.FromBeginning || o.Offset != nil) {
+		return fmt.Errorf("dead-letter files are always read from the beginning; --from-beginning and --offset apply to topics")
+	}
+	if o.Partition < -1 {
+		return fmt.Errorf("partition must not be negative")
+	}
+	if o.Limit < 0 {
+		return fmt.Errorf("limit must not be negative")
+	}
+	if o.FromBeginning && o.Offset != nil {
+		return fmt.Errorf("use either --from-beginning or --offset, not both")
+	}
+	if o.Filter != "" {
+		if _, err := parseRuleExpr(o.Filter); err != nil {
+			return fmt.Errorf("invalid filter %q: %w", o.Filter, err)
+		}
+	}
+	return nil
+}
+
+// startOffset picks where to start reading a partition whose records span [first, last)
+func (o *TailOptions) startOffset(first, last int64) int64 {
+	switch {
+	case o.Offset != nil && *o.Offset >= 0:
+		return *o.Offset
+	case o.Offset != nil:
+		if start := last + *o.Offset; start > first {
+			return start
+		}
+		return first
+	case o.FromBeginning:
+		return first
+	default:
+		return last
+	}
+}
+
+// TailRecord is one decoded message of a tailed topic
+type TailRecord struct {
+	Topic     string      `json:"topic"`
+	Partition int         `json:"partition"`
+	Offset    int64       `json:"offset"`
+	Timestamp time.Time   `json:"timestamp"`
+	Key       interface{} `json:"key,omitempty"`
+	SchemaID  int         `json:"schema_id,omitempty"`
+	Value     interface{} `json:"value"`
+	Error     string      `json:"error,omitempty"`   // Why the value could not be decoded
+	Failure   string      `json:"failure,omitempty"` // Why the consumer failed to process the record (dead-letter files)
+}
+
+// Tail reads a topic from the chosen offsets, or a dead-letter file, and hands every decoded record that
+// matches the filter to emit, until the limit is reached or ctx is cancelled. Values are decoded like the
+// consumer decodes output.
+func Tail(ctx context.Context, opts TailOptions, emit func(*TailRecord) error) error {
+	if err := opts.Validate(); err != nil {
+		return err
+	}
+	var filter ruleExpr
+	if opts.Filter != "" {
+		filter, _ = parseRuleExpr(opts.Filter)
+	}
+
+	decoder, _ := NewConsumer(&Config{SchemaRegistryURL: opts.SchemaRegistryURL})
+	if opts.SchemaRegistryURL != "" {
+		if err := decoder.InitializeSchemaRegistry(); err != nil {
+			return err
+		}
+	}
+
+	if opts.File != "" {
+		return tailDeadLetters(ctx, opts, decoder, filter, emit)
+	}
+
+	brokers := strings.Split(opts.BootstrapServers, ",")
+	partitions, err := tailPartitions(ctx, brokers[0], opts.Topic, opts.Partition)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	messages := make(chan kafka.Message)
+	errs := make(chan error, len(partitions))
+	for _, partition := range partitions {
+		reader, err := openTailReader(ctx, brokers, opts, partition)
+		if err != nil {
+			return err
+		}
+		go func() {
+			defer func() { _ = reader.Close() }()
+			for {
+				msg, err := reader.ReadMessage(ctx)
+				if err != nil {
+					if ctx.Err() == nil {
+						errs <- fmt.Errorf("failed to read partition %d: %w", reader.Config().Partition, err)
+					}
+					return
+				}
+				select {
+				case messages <- msg:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	printed := 0
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err := <-errs:
+			return err
+		case msg := <-messages:
+			record := decodeTailRecord(decoder, &msg)
+			if filter != nil && !tailMatches(filter, record.Value) {
+				continue
+			}
+			if err := emit(record); err != nil {
+				return err
+			}
+			if printed++; opts.Limit > 0 && printed >= opts.Limit {
+				return nil
+			}
+		}
+	}
+}
+
+// tailDeadLetters prints the records of a dead-letter file, decoding their raw key and value again
+func tailDeadLetters(ctx context.Context, opts TailOptions, decoder *Consumer, filter ruleExpr, emit func(*TailRecord) error) error {
+	file, err := os.Open(opts.File)
+	if err != nil {
+		return fmt.Errorf("failed to open dead-letter file: %w", err)
+	}
+	defer func() { _ = file.Close() }()
+
+	printed := 0
+	err = ReadDeadLetters(file, func(letter *DeadLetter) error {
+		if ctx.Err() != nil {
+			return errStopTail
+		}
+		if opts.Partition >= 0 && letter.Partition != opts.Partition {
+			return nil
+		}
+		record := decodeDeadLetter(decoder, letter)
+		if filter != nil && !tailMatches(filter, record.Value) {
+			return nil
+		}
+		if err := emit(record); err != nil {
+			return err
+		}
+		if printed++; opts.Limit > 0 && printed >= opts.Limit {
+			return errStopTail
+		}
+		return nil
+	})
+	if err == errStopTail {
+		return nil
+	}
+	return err
+}
+
+// errStopTail ends reading a dead-letter file early
+var errStopTail = errors.New("stop tailing")
+
+// decodeDeadLetter decodes a dead letter like a message of its topic, keeping why it failed
+func decodeDeadLetter(decoder *Consumer, letter *DeadLetter) *TailRecord {
+	msg := &kafka.Message{
+		Topic:     letter.Topic,
+		Partition: letter.Partition,
+		Offset:    letter.Offset,
+		Time:      letter.Timestamp,
+		Key:       letter.Key,
+		Value:     letter.Value,
+	}
+	record := decodeTailRecord(decoder, msg)
+	record.Failure = letter.Error
+	return record
+}
+
+// tailPartitions lists the partitions of a topic, or checks that the requested one exists
+func tailPartitions(ctx context.Context, broker, topic string, partition int) ([]int, error) {
+	conn, err := kafka.DialContext(ctx, "tcp", broker)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to Kafka at %s: %w", broker, err)
+	}
+	defer func() { _ = conn.Close() }()
+
+	infos, err := conn.ReadPartitions(topic)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read partitions of %s: %w", topic, err)
+	}
+	var partitions []int
+	for _, info := range infos {
+		if partition < 0 || info.ID == partition {
+			partitions = append(partitions, info.ID)
+		}
+	}
+	if len(partitions) == 0 {
+		if partition >= 0 {
+			return nil, fmt.Errorf("topic %s has no partition %d", topic, partition)
+		}
+		return nil, fmt.Errorf("topic %s not found", topic)
+	}
+	sort.Ints(partitions)
+	return partitions, nil
+}
+
+// openTailReader opens a reader on one partition, positioned at the start offset of the options
+func openTailReader(ctx context.Context, brokers []string, opts TailOptions, partition int) (*kafka.Reader, error) {
+	conn, err := kafka.DialLeader(ctx, "tcp", brokers[0], opts.Topic, partition)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to the leader of partition %d: %w", partition, err)
+	}
+	first, last, err := conn.ReadOffsets()
+	_ = conn.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read offsets of partition %d: %w", partition, err)
+	}
+
+	reader := kafka.NewReader(kafka.ReaderConfig{
+		Brokers:   brokers,
+		Topic:     opts.Topic,
+		Partition: partition,
+		MinBytes:  1,
+		MaxBytes:  10e6,
+		MaxWait:   500 * time.Millisecond,
+	})
+	if err := reader.SetOffset(opts.startOffset(first, last)); err != nil {
+		_ = reader.Close()
+		return nil, fmt.Errorf("failed to seek partition %d: %w", partition, err)
+	}
+	return reader, nil
+}
+
+// decodeTailRecord decodes the key and value of a message into plain values for printing
+func decodeTailRecord(decoder *Consumer, msg *kafka.Message) *TailRecord {
+	record := &TailRecord{
+		Topic:     msg.Topic,
+		Partition: msg.Partition,
+		Offset:    msg.Offset,
+		Timestamp: msg.Time,
+	}
+	if key, _, err := decoder.DecodeValue(msg.Topic, msg.Key); err == nil {
+		record.Key = displayValue(key)
+	} else {
+		record.Key = fmt.Sprintf("%x", msg.Key)
+	}
+
+	value, schemaID, err := decoder.DecodeValue(msg.Topic, msg.Value)
+	record.SchemaID = schemaID
+	if err != nil {
+		record.Error = err.Error()
+		return record
+	}
+	record.Value = displayValue(value)
+	return record
+}
+
+// tailMatches evaluates the filter on a record value; values that are not records never match
+func tailMatches(filter ruleExpr, value interface{}) bool {
+	fields, ok := value.(map[string]interface{})
+	if !ok {
+		return false
+	}
+	matched, err := filter.eval(fields)
+	return err == nil && matched == true
+}
+
+// avroTypeNames are the branch names goavro uses for primitive union members
+var avroTypeNames = map[string]bool{"null": true, "boolean": true, "int": true, "long": true, "float": true, "double": true, "bytes": true, "string": true, "array": true, "map": true}
+
+// displayValue turns decoded AVRO values into plain JSON values: unions are unwrapped, decimals become
+// numbers and bytes become strings
+func displayValue(v interface{}) interface{} {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		if len(t) == 1 {
+			for branch, inner := range t {
+				if isUnionBranch(branch) {
+					return displayValue(inner)
+				}
+			}
+		}
+		fields := make(map[string]interface{}, len(t))
+		for name, field := range t {
+			fields[name] = displayValue(field)
+		}
+		return fields
+	case []interface{}:
+		items := make([]interface{}, len(t))
+		for i, item := range t {
+			items[i] = displayValue(item)
+		}
+		return items
+	default:
+		return normalizeOutputValue(v)
+	}
+}
+
+// isUnionBranch tells a goavro union wrapper from a record with a single field: branches are type
+// names, logical types (long.timestamp-millis) or capitalized record names
+func isUnionBranch(name string) bool {
+	if avroTypeNames[name] || strings.Contains(name, ".") {
+		return true
+	}
+	first, _ := utf8.DecodeRuneInString(name)
+	return unicode.IsUpper(first)
+}
+
+// TailPrinter writes tailed records in one of the tail formats
+type TailPrinter struct {
+	w       io.Writer
+	format  string
+	columns []string // Table columns, set by the first record
+	fixed   int      // Columns before the record fields
+	widths  []int
+}
+
+// NewTailPrinter creates a printer for the json, jsonl or table format
+func NewTailPrinter(w io.Writer, format string) (*TailPrinter, error) {
+	switch format {
+	case TailFormatJSON, TailFormatJSONL, TailFormatTable:
+		return &TailPrinter{w: w, format: format}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q (use json, jsonl or table)", format)
+	}
+}
+
+// Print writes one record
+func (p *TailPrinter) Print(record *TailRecord) error {
+	switch p.format {
+	case TailFormatJSONL:
+		data, err := json.Marshal(record)
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(p.w, string(data))
+		return err
+	case TailFormatTable:
+		return p.printRow(record)
+	default:
+		data, err := json.MarshalIndent(record, "", "  ")
+		if err != nil {
+			return err
+		}
+		_, err = fmt.Fprintln(p.w, string(data))
+		return err
+	}
+}
+
+// printRow writes a record as a table row, printing the header first. The columns and their widths come
+// from the first record, so rows can be written as they arrive.
+func (p *TailPrinter) printRow(record *TailRecord) error {
+	fields, _ := record.Value.(map[string]interface{})
+	if p.columns == nil {
+		p.columns = []string{"partition", "offset", "key"}
+		if record.Failure != "" {
+			p.columns = append(p.columns, "failure")
+		}
+		p.fixed = len(p.columns)
+		if fields != nil {
+			names := make([]string, 0, len(fields))
+			for name := range fields {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			p.columns = append(p.columns, names...)
+		} else {
+			p.columns = append(p.columns, "value")
+		}
+		first := p.cells(record, fields)
+		p.widths = make([]int, len(p.columns))
+		for i, column := range p.columns {
+			p.widths[i] = utf8.RuneCountInString(column)
+			if n := utf8.RuneCountInString(first[i]); n > p.widths[i] {
+				p.widths[i] = n
+			}
+			if p.widths[i] > maxTailColumnWidth {
+				p.widths[i] = maxTailColumnWidth
+			}
+		}
+		if _, err := fmt.Fprintln(p.w, p.row(p.columns)); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintln(p.w, p.row(p.cells(record, fields)))
+	return err
+}
+
+// cells formats the table cells of a record
+func (p *TailPrinter) cells(record *TailRecord, fields map[string]interface{}) []string {
+	cells := []string{fmt.Sprint(record.Partition), fmt.Sprint(record.Offset), tailCell(record.Key)}
+	if p.fixed > len(cells) {
+		cells = append(cells, record.Failure)
+	}
+	for i, column := range p.columns[p.fixed:] {
+		switch {
+		case record.Error != "" && i == 0:
+			cells = append(cells, "error: "+record.Error)
+		case record.Error != "":
+			cells = append(cells, "")
+		case fields == nil:
+			cells = append(cells, tailCell(record.Value))
+		default:
+			cells = append(cells, tailCell(fields[column]))
+		}
+	}
+	return cells
+}
+
+// row pads and cuts cells to the column widths
+func (p *TailPrinter) row(cells []string) string {
+	padded := make([]string, len(cells))
+	for i, cell := range cells {
+		width := p.widths[i]
+		if utf8.RuneCountInString(cell) > width {
+			cell = string([]rune(cell)[:width-1]) + "…"
+		}
+		padded[i] = cell + strings.Repeat(" ", width-utf8.RuneCountInString(cell))
+	}
+	return strings.TrimRight(strings.Join(padded, "  "), " ")
+}
+
+// tailCell formats a value for a table cell
+func tailCell(v interface{}) string {
+	switch t := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return t
+	case time.Time:
+		return t.Format(time.RFC3339Nano)
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(t)
+		return string(data)
+	default:
+		return fmt.Sprint(t)
+	}
+}
@@ -0,0 +1,135 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/linkedin/goavro/v2"
+	"github.com/segmentio/kafka-go"
+)
+
+func TestTailStartOffset(t *testing.T) {
+	offset := func(n int64) *int64 { return &n }
+	tests := []struct {
+		name string
+		opts TailOptions
+		want int64
+	}{
+		{name: "new records", opts: TailOptions{}, want: 100},
+		{name: "from beginning", opts: TailOptions{FromBeginning: true}, want: 20},
+		{name: "absolute", opts: TailOptions{Offset: offset(42)}, want: 42},
+		{name: "last five", opts: TailOptions{Offset: offset(-5)}, want: 95},
+		{name: "more than retained", opts: TailOptions{Offset: offset(-500)}, want: 20},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.opts.startOffset(20, 100); got != tt.want {
+				t.Errorf("startOffset() = %d, want %d", got, tt.want)
+			}
+		})
+	}
+
+	zero := int64(0)
+	for _, opts := range []TailOptions{
+		{},
+		{Topic: "orders", Partition: -2},
+		{Topic: "orders", Limit: -1},
+		{Topic: "orders", FromBeginning: true, Offset: &zero},
+		{Topic: "orders", Filter: "amount >"},
+	} {
+		if err := opts.Validate(); err == nil {
+			t.Errorf("Validate(%+v) expected an error", opts)
+		}
+	}
+}
+
+func TestDecodeTailRecord(t *testing.T) {
+	schema := `{"type": "record", "name": "Order", "fields": [
+		{"name": "order_id", "type": "string"},
+		{"name": "note", "type": ["null", "string"]},
+		{"name": "created", "type": {"type": "long", "logicalType": "timestamp-millis"}}
+	]}`
+	codec, err := goavro.NewCodec(schema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	created := time.UnixMilli(1700000000000).UTC()
+	payload, err := codec.BinaryFromNative(nil, map[string]interface{}{
+		"order_id": "o-1",
+		"note":     goavro.Union("string", "rush"),
+		"created":  created,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decoder := &Consumer{config: &Config{}, fetchSchema: func(id int) (*registeredSchema, error) { return &registeredSchema{definition: schema}, nil }}
+	record := decodeTailRecord(decoder, &kafka.Message{Topic: "orders", Partition: 1, Offset: 7, Key: []byte("key-1"), Value: confluentWireFormat(12, payload)})
+	if record.Error != "" || record.SchemaID != 12 || record.Key != "key-1" {
+		t.Fatalf("unexpected record: %+v", record)
+	}
+	value, _ := record.Value.(map[string]interface{})
+	if value["order_id"] != "o-1" || value["note"] != "rush" || !created.Equal(value["created"].(time.Time)) {
+		t.Errorf("unexpected value: %v", value)
+	}
+
+	record = decodeTailRecord(decoder, &kafka.Message{Topic: "orders", Value: []byte(`{"order_id": "o-2", "amount": 12.5}`)})
+	if value, _ := record.Value.(map[string]interface{}); record.SchemaID != 0 || value["order_id"] != "o-2" {
+		t.Errorf("JSON value = %+v", record)
+	}
+	record = decodeTailRecord(decoder, &kafka.Message{Topic: "orders", Value: []byte("plain text")})
+	if record.Value != "plain text" {
+		t.Errorf("text value = %v", record.Value)
+	}
+	record = decodeTailRecord(decoder, &kafka.Message{Topic: "orders", Value: []byte{0x00, 0x00}})
+	if !strings.Contains(record.Error, "too short") {
+		t.Errorf("expected a wire format error, got %+v", record)
+	}
+
+	filter, _ := parseRuleExpr("amount > 10")
+	if !tailMatches(filter, map[string]interface{}{"amount": json.Number("12.5")}) || tailMatches(filter, "plain text") {
+		t.Error("unexpected filter result")
+	}
+}
+
+func TestTailPrinter(t *testing.T) {
+	records := []*TailRecord{
+		{Topic: "orders", Partition: 0, Offset: 1, Key: "key-1", Value: map[string]interface{}{"order_id": "o-1", "status": "PAID"}},
+		{Topic: "orders", Partition: 1, Offset: 2, Key: "key-2", Value: map[string]interface{}{"order_id": "o-2", "status": strings.Repeat("x", 40)}},
+	}
+
+	var out strings.Builder
+	printer, err := NewTailPrinter(&out, TailFormatTable)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, r := range records {
+		if err := printer.Print(r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || !strings.HasPrefix(lines[0], "partition  offset  key    order_id  status") {
+		t.Fatalf("unexpected table:\n%s", out.String())
+	}
+	if !strings.HasSuffix(lines[2], "xxx…") || strings.Contains(lines[2], strings.Repeat("x", 40)) {
+		t.Errorf("long cells should be cut: %q", lines[2])
+	}
+
+	out.Reset()
+	printer, _ = NewTailPrinter(&out, TailFormatJSONL)
+	for _, r := range records {
+		_ = printer.Print(r)
+	}
+	lines = strings.Split(strings.TrimSpace(out.String()), "\n")
+	var decoded TailRecord
+	if len(lines) != 2 || json.Unmarshal([]byte(lines[1]), &decoded) != nil || decoded.Offset != 2 {
+		t.Errorf("unexpected JSON lines:\n%s", out.String())
+	}
+
+	if _, err := NewTailPrinter(&out, "csv"); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
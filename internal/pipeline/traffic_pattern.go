@@ -2,27 +2,43 @@ package pipeline
 
 import (
 	"fmt"
+	"math"
 	"strconv"
 	"strings"
 	"time"
 )
 
+// Traffic pattern shapes
+const (
+	TrafficStep  = "step"  // Constant multiplier inside the window
+	TrafficRamp  = "ramp"  // Linear change from RateMultiplier to EndMultiplier
+	TrafficWave  = "wave"  // Sine wave between RateMultiplier and EndMultiplier, starting at the low point
+	TrafficBurst = "burst" // RateMultiplier for BurstLength out of every Period, base rate in between
+)
+
 // TrafficPattern defines a traffic spike during pipeline execution
 type TrafficPattern struct {
 	StartTime      time.Duration
 	EndTime        time.Duration
-	RateMultiplier float64 // Percentage as multiplier (300% = 3.0)
+	RateMultiplier float64       // Percentage as multiplier (300% = 3.0); start of a ramp, low of a wave, peak of a burst
+	Shape          string        // step (default), ramp, wave or burst
+	EndMultiplier  float64       // Ramp: multiplier at EndTime; wave: high multiplier
+	Period         time.Duration // Wave and burst: repeat period
+	BurstLength    time.Duration // Burst: length of each burst
 }
 
 // TrafficPatterns holds all traffic patterns for an execution
 type TrafficPatterns struct {
-	BaseRate int // Base messages per second
-	Patterns []TrafficPattern
+	BaseRate    int // Base messages per second
+	Patterns    []TrafficPattern
+	Description string // Set for traffic profiles, shown at the top of the summary
 }
 
 // ParseTrafficPattern parses a traffic pattern string into TrafficPatterns
 // Format: "start-end:rate%,start-end:rate%"
 // Example: "30s-60s:300%,90s-120s:200%"
+// Besides flat steps, a window can hold a ramp ("0s-2m:100%->500%"), a sine wave between two rates
+// with a period ("2m-10m:wave(50%,150%,1m)") or repeated bursts ("10m-15m:burst(400%,5s,30s)").
 func ParseTrafficPattern(patternStr string, baseRate int) (*TrafficPatterns, error) {
 	if patternStr == "" {
 		return &TrafficPatterns{
@@ -32,7 +48,7 @@ func ParseTrafficPattern(patternStr string, baseRate int) (*TrafficPatterns, err
 	}
 
 	patterns := []TrafficPattern{}
-	parts := strings.Split(patternStr, ",")
+	parts := splitTopLevel(patternStr, ',')
 
 	for _, part := range parts {
 		part = strings.TrimSpace(part)
@@ -40,14 +56,13 @@ func ParseTrafficPattern(patternStr string, baseRate int) (*TrafficPatterns, err
 			continue
 		}
 
-		// Split by colon: "30s-60s:300%"
-		colonParts := strings.Split(part, ":")
-		if len(colonParts) != 2 {
+		// Split at the first colon: "30s-60s:300%"
+		timeRange, rateStr, ok := strings.Cut(part, ":")
+		if !ok {
 			return nil, fmt.Errorf("invalid pattern format '%s': expected 'start-end:rate%%'", part)
 		}
-
-		timeRange := strings.TrimSpace(colonParts[0])
-		rateStr := strings.TrimSpace(colonParts[1])
+		timeRange = strings.TrimSpace(timeRange)
+		rateStr = strings.TrimSpace(rateStr)
 
 		// Parse time range: "30s-60s"
 		dashParts := strings.Split(timeRange, "-")
@@ -69,25 +84,13 @@ func ParseTrafficPattern(patternStr string, baseRate int) (*TrafficPatterns, err
 			return nil, fmt.Errorf("end time '%s' must be after start time '%s'", dashParts[1], dashParts[0])
 		}
 
-		// Parse rate: "300%"
-		if !strings.HasSuffix(rateStr, "%") {
-			return nil, fmt.Errorf("invalid rate format '%s': expected percentage (e.g., '300%%')", rateStr)
-		}
-
-		rateValue, err := strconv.ParseFloat(strings.TrimSuffix(rateStr, "%"), 64)
+		pattern, err := parsePatternShape(rateStr)
 		if err != nil {
-			return nil, fmt.Errorf("invalid rate value '%s': %w", rateStr, err)
+			return nil, err
 		}
-
-		if rateValue <= 0 {
-			return nil, fmt.Errorf("rate value must be positive, got '%s'", rateStr)
-		}
-
-		patterns = append(patterns, TrafficPattern{
-			StartTime:      startTime,
-			EndTime:        endTime,
-			RateMultiplier: rateValue / 100.0, // Convert percentage to multiplier
-		})
+		pattern.StartTime = startTime
+		pattern.EndTime = endTime
+		patterns = append(patterns, pattern)
 	}
 
 	// Validate patterns don't overlap and are in chronological order
@@ -101,6 +104,129 @@ func ParseTrafficPattern(patternStr string, baseRate int) (*TrafficPatterns, err
 	}, nil
 }
 
+// parsePatternShape parses the rate part of a pattern: "300%", "100%->500%", "wave(low%,high%,period)"
+// or "burst(rate%,length,period)"
+func parsePatternShape(rateStr string) (TrafficPattern, error) {
+	if args, ok := shapeArgs(rateStr, "wave"); ok {
+		if len(args) != 3 {
+			return TrafficPattern{}, fmt.Errorf("invalid wave '%s': expected 'wave(low%%,high%%,period)'", rateStr)
+		}
+		low, err := parseRatePercent(args[0])
+		if err != nil {
+			return TrafficPattern{}, err
+		}
+		high, err := parseRatePercent(args[1])
+		if err != nil {
+			return TrafficPattern{}, err
+		}
+		period, err := parsePositiveDuration(args[2], "wave period")
+		if err != nil {
+			return TrafficPattern{}, err
+		}
+		if high < low {
+			low, high = high, low
+		}
+		return TrafficPattern{Shape: TrafficWave, RateMultiplier: low, EndMultiplier: high, Period: period}, nil
+	}
+
+	if args, ok := shapeArgs(rateStr, "burst"); ok {
+		if len(args) != 3 {
+			return TrafficPattern{}, fmt.Errorf("invalid burst '%s': expected 'burst(rate%%,length,period)'", rateStr)
+		}
+		rate, err := parseRatePercent(args[0])
+		if err != nil {
+			return TrafficPattern{}, err
+		}
+		length, err := parsePositiveDuration(args[1], "burst length")
+		if err != nil {
+			return TrafficPattern{}, err
+		}
+		period, err := parsePositiveDuration(args[2], "burst period")
+		if err != nil {
+			return TrafficPattern{}, err
+		}
+		if length >= period {
+			return TrafficPattern{}, fmt.Errorf("burst length %v must be shorter than its period %v", length, period)
+		}
+		return TrafficPattern{Shape: TrafficBurst, RateMultiplier: rate, BurstLength: length, Period: period}, nil
+	}
+
+	if from, to, ok := strings.Cut(rateStr, "->"); ok {
+		start, err := parseRatePercent(from)
+		if err != nil {
+			return TrafficPattern{}, err
+		}
+		end, err := parseRatePercent(to)
+		if err != nil {
+			return TrafficPattern{}, err
+		}
+		return TrafficPattern{Shape: TrafficRamp, RateMultiplier: start, EndMultiplier: end}, nil
+	}
+
+	rate, err := parseRatePercent(rateStr)
+	if err != nil {
+		return TrafficPattern{}, err
+	}
+	return TrafficPattern{Shape: TrafficStep, RateMultiplier: rate}, nil
+}
+
+// parseRatePercent parses a positive percentage such as "300%" into a multiplier
+func parseRatePercent(rateStr string) (float64, error) {
+	rateStr = strings.TrimSpace(rateStr)
+	if !strings.HasSuffix(rateStr, "%") {
+		return 0, fmt.Errorf("invalid rate format '%s': expected percentage (e.g., '300%%')", rateStr)
+	}
+
+	rateValue, err := strconv.ParseFloat(strings.TrimSuffix(rateStr, "%"), 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid rate value '%s': %w", rateStr, err)
+	}
+
+	if rateValue <= 0 {
+		return 0, fmt.Errorf("rate value must be positive, got '%s'", rateStr)
+	}
+	return rateValue / 100.0, nil // Convert percentage to multiplier
+}
+
+func parsePositiveDuration(value, what string) (time.Duration, error) {
+	d, err := time.ParseDuration(strings.TrimSpace(value))
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s '%s': %w", what, value, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("%s must be positive, got '%s'", what, value)
+	}
+	return d, nil
+}
+
+// shapeArgs returns the arguments of "name(a,b,c)"
+func shapeArgs(rateStr, name string) ([]string, bool) {
+	if !strings.HasPrefix(rateStr, name+"(") || !strings.HasSuffix(rateStr, ")") {
+		return nil, false
+	}
+	return strings.Split(rateStr[len(name)+1:len(rateStr)-1], ","), true
+}
+
+// splitTopLevel splits s at sep, ignoring separators inside parentheses
+func splitTopLevel(s string, sep rune) []string {
+	var parts []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
 // validatePatterns checks for overlapping patterns and ensures chronological order
 func validatePatterns(patterns []TrafficPattern) error {
 	for i := 0; i < len(patterns)-1; i++ {
@@ -116,12 +242,36 @@ func validatePatterns(patterns []TrafficPattern) error {
 	return nil
 }
 
+// multiplierAt returns the rate multiplier of the pattern at a time inside its window
+func (p TrafficPattern) multiplierAt(elapsed time.Duration) float64 {
+	offset := elapsed - p.StartTime
+	switch p.Shape {
+	case TrafficRamp:
+		progress := float64(offset) / float64(p.EndTime-p.StartTime)
+		return p.RateMultiplier + (p.EndMultiplier-p.RateMultiplier)*progress
+	case TrafficWave:
+		phase := 2 * math.Pi * float64(offset) / float64(p.Period)
+		return p.RateMultiplier + (p.EndMultiplier-p.RateMultiplier)*(1-math.Cos(phase))/2
+	case TrafficBurst:
+		if offset%p.Period < p.BurstLength {
+			return p.RateMultiplier
+		}
+		return 1
+	default:
+		return p.RateMultiplier
+	}
+}
+
 // GetRateAt returns the message rate that should be used at a specific time
 func (tp *TrafficPatterns) GetRateAt(elapsed time.Duration) int {
 	// Check if we're in any pattern period
 	for _, pattern := range tp.Patterns {
 		if elapsed >= pattern.StartTime && elapsed < pattern.EndTime {
-			return int(float64(tp.BaseRate) * pattern.RateMultiplier)
+			rate := int(math.Round(float64(tp.BaseRate) * pattern.multiplierAt(elapsed)))
+			if rate < 1 && tp.BaseRate > 0 {
+				return 1 // The producer needs at least one message per second to schedule
+			}
+			return rate
 		}
 	}
 
@@ -136,14 +286,59 @@ func (tp *TrafficPatterns) GetPatternSummary() string {
 	}
 
 	summary := fmt.Sprintf("Base rate: %d msg/sec", tp.BaseRate)
+	if tp.Description != "" {
+		summary = fmt.Sprintf("%s\n%s", tp.Description, summary)
+	}
 	for i, pattern := range tp.Patterns {
-		peakRate := int(float64(tp.BaseRate) * pattern.RateMultiplier)
-		summary += fmt.Sprintf("\n  Peak %d: %v-%v at %d msg/sec (%.0f%%)",
-			i+1, pattern.StartTime, pattern.EndTime, peakRate, pattern.RateMultiplier*100)
+		rate := func(multiplier float64) int { return int(float64(tp.BaseRate) * multiplier) }
+		switch pattern.Shape {
+		case TrafficRamp:
+			summary += fmt.Sprintf("\n  Ramp %d: %v-%v from %d to %d msg/sec (%.0f%%->%.0f%%)",
+				i+1, pattern.StartTime, pattern.EndTime, rate(pattern.RateMultiplier), rate(pattern.EndMultiplier),
+				pattern.RateMultiplier*100, pattern.EndMultiplier*100)
+		case TrafficWave:
+			summary += fmt.Sprintf("\n  Wave %d: %v-%v between %d and %d msg/sec every %v",
+				i+1, pattern.StartTime, pattern.EndTime, rate(pattern.RateMultiplier), rate(pattern.EndMultiplier), pattern.Period)
+		case TrafficBurst:
+			summary += fmt.Sprintf("\n  Burst %d: %v-%v at %d msg/sec (%.0f%%) for %v every %v",
+				i+1, pattern.StartTime, pattern.EndTime, rate(pattern.RateMultiplier), pattern.RateMultiplier*100,
+				pattern.BurstLength, pattern.Period)
+		default:
+			summary += fmt.Sprintf("\n  Peak %d: %v-%v at %d msg/sec (%.0f%%)",
+				i+1, pattern.StartTime, pattern.EndTime, rate(pattern.RateMultiplier), pattern.RateMultiplier*100)
+		}
 	}
 	return summary
 }
 
+// sparkLevels are the block characters used by Sparkline, lowest first
+var sparkLevels = []rune("▁▂▃▄▅▆▇█")
+
+// Sparkline draws the expected rate over the run duration as a row of block characters
+func (tp *TrafficPatterns) Sparkline(duration time.Duration, width int) string {
+	if duration <= 0 || width <= 0 {
+		return ""
+	}
+	rates := make([]int, width)
+	maxRate := 0
+	for i := range rates {
+		rates[i] = tp.GetRateAt(duration * time.Duration(i) / time.Duration(width))
+		if rates[i] > maxRate {
+			maxRate = rates[i]
+		}
+	}
+
+	line := make([]rune, width)
+	for i, rate := range rates {
+		level := 0
+		if maxRate > 0 {
+			level = rate * (len(sparkLevels) - 1) / maxRate
+		}
+		line[i] = sparkLevels[level]
+	}
+	return string(line)
+}
+
 // HasPatterns returns true if any traffic patterns are defined
 func (tp *TrafficPatterns) HasPatterns() bool {
 	return len(tp.Patterns) > 0
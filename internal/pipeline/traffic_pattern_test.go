@@ -1,6 +1,7 @@
 package pipeline
 
 import (
+	"strings"
 	"testing"
 	"time"
 )
@@ -52,6 +53,31 @@ func TestParseTrafficPattern(t *testing.T) {
 			baseRate: 100,
 			wantErr:  true,
 		},
+		{
+			name:         "ramp wave and burst",
+			pattern:      "0s-2m:100%->500%,2m-10m:wave(50%,150%,1m),10m-15m:burst(400%,5s,30s)",
+			baseRate:     100,
+			wantErr:      false,
+			wantPatterns: 3,
+		},
+		{
+			name:     "invalid ramp",
+			pattern:  "0s-2m:100%->500",
+			baseRate: 100,
+			wantErr:  true,
+		},
+		{
+			name:     "wave missing period",
+			pattern:  "0s-2m:wave(50%,150%)",
+			baseRate: 100,
+			wantErr:  true,
+		},
+		{
+			name:     "burst longer than period",
+			pattern:  "0s-2m:burst(400%,30s,10s)",
+			baseRate: 100,
+			wantErr:  true,
+		},
 		{
 			name:     "overlapping patterns",
 			pattern:  "30s-60s:300%,45s-90s:200%",
@@ -128,6 +154,62 @@ func TestTrafficPatterns_GetRateAt(t *testing.T) {
 	}
 }
 
+func TestTrafficPatterns_GetRateAtShapes(t *testing.T) {
+	patterns, err := ParseTrafficPattern("0s-100s:100%->500%,100s-200s:wave(50%,150%,40s),200s-300s:burst(400%,5s,30s)", 100)
+	if err != nil {
+		t.Fatalf("failed to parse pattern: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		elapsed  time.Duration
+		wantRate int
+	}{
+		{name: "ramp start", elapsed: 0, wantRate: 100},
+		{name: "ramp middle", elapsed: 50 * time.Second, wantRate: 300},
+		{name: "ramp near end", elapsed: 99 * time.Second, wantRate: 496},
+		{name: "wave low", elapsed: 100 * time.Second, wantRate: 50},
+		{name: "wave mid", elapsed: 110 * time.Second, wantRate: 100},
+		{name: "wave high", elapsed: 120 * time.Second, wantRate: 150},
+		{name: "wave next low", elapsed: 140 * time.Second, wantRate: 50},
+		{name: "in burst", elapsed: 203 * time.Second, wantRate: 400},
+		{name: "between bursts", elapsed: 210 * time.Second, wantRate: 100},
+		{name: "second burst", elapsed: 231 * time.Second, wantRate: 400},
+		{name: "after all patterns", elapsed: 300 * time.Second, wantRate: 100},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := patterns.GetRateAt(tt.elapsed)
+			if got != tt.wantRate {
+				t.Errorf("GetRateAt(%v) = %d, want %d", tt.elapsed, got, tt.wantRate)
+			}
+		})
+	}
+
+	summary := patterns.GetPatternSummary()
+	for _, want := range []string{"Ramp 1", "Wave 2", "Burst 3"} {
+		if !strings.Contains(summary, want) {
+			t.Errorf("summary %q does not mention %s", summary, want)
+		}
+	}
+}
+
+func TestTrafficPatterns_Sparkline(t *testing.T) {
+	patterns, err := ParseTrafficPattern("0s-60s:100%->800%", 100)
+	if err != nil {
+		t.Fatalf("failed to parse pattern: %v", err)
+	}
+
+	line := []rune(patterns.Sparkline(60*time.Second, 8))
+	if len(line) != 8 {
+		t.Fatalf("expected 8 characters, got %q", string(line))
+	}
+	if line[0] != '▁' || line[7] != '█' {
+		t.Errorf("expected a rising sparkline, got %q", string(line))
+	}
+}
+
 func TestTrafficPatterns_HasPatterns(t *testing.T) {
 	// Test with patterns
 	patterns, err := ParseTrafficPattern("30s-60s:300%", 100)
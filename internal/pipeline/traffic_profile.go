@@ -0,0 +1,169 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// TrafficProfile mirrors a --traffic-profile file: the rate over a full period (a day by default),
+// compressed into the run duration.
+//
+//	name: retail-weekday
+//	period: 24h
+//	points:
+//	  - {at: "00:00", rate: 20%}
+//	  - {at: "09:00", rate: 150%}
+//	  - {at: "19:00", rate: 300%}
+type TrafficProfile struct {
+	Name          string         `yaml:"name"`
+	Period        time.Duration  `yaml:"period"`        // Length of the described period (default 24h)
+	Interpolation string         `yaml:"interpolation"` // "linear" (default) ramps between points, "step" holds each rate
+	Points        []ProfilePoint `yaml:"points"`
+}
+
+// ProfilePoint sets the rate at a time of the profile period
+type ProfilePoint struct {
+	At   string `yaml:"at"`   // Time of day ("09:30") or offset into the period ("9h30m")
+	Rate string `yaml:"rate"` // Percentage of the base rate ("150%")
+}
+
+// LoadTrafficProfile reads a traffic profile file and compresses it into traffic patterns spanning duration
+func LoadTrafficProfile(path string, duration time.Duration, baseRate int) (*TrafficPatterns, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	var profile TrafficProfile
+	if err := yaml.Unmarshal(content, &profile); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	if profile.Name == "" {
+		profile.Name = strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	}
+	return profile.Compile(duration, baseRate)
+}
+
+// profileKnot is a parsed profile point
+type profileKnot struct {
+	at         time.Duration
+	multiplier float64
+}
+
+// Compile turns the profile points into ramps (or steps) that cover the run duration. The profile
+// wraps around: before the first point, the rate moves from the last point towards the first.
+func (p *TrafficProfile) Compile(duration time.Duration, baseRate int) (*TrafficPatterns, error) {
+	if duration <= 0 {
+		return nil, fmt.Errorf("a traffic profile needs a positive run duration")
+	}
+	if p.Period == 0 {
+		p.Period = 24 * time.Hour
+	}
+	if p.Period < 0 {
+		return nil, fmt.Errorf("profile period must be positive")
+	}
+	switch p.Interpolation {
+	case "":
+		p.Interpolation = "linear"
+	case "linear", "step":
+	default:
+		return nil, fmt.Errorf("unknown interpolation %q (expected linear or step)", p.Interpolation)
+	}
+	if len(p.Points) == 0 {
+		return nil, fmt.Errorf("profile %s has no points", p.Name)
+	}
+
+	knots := make([]profileKnot, 0, len(p.Points)+2)
+	for i, point := range p.Points {
+		at, err := parseProfileTime(point.At)
+		if err != nil {
+			return nil, fmt.Errorf("points[%d]: %w", i, err)
+		}
+		if at >= p.Period {
+			return nil, fmt.Errorf("points[%d]: %s is beyond the profile period of %v", i, point.At, p.Period)
+		}
+		rate := strings.TrimSpace(point.Rate)
+		if !strings.HasSuffix(rate, "%") {
+			rate += "%"
+		}
+		multiplier, err := parseRatePercent(rate)
+		if err != nil {
+			return nil, fmt.Errorf("points[%d]: %w", i, err)
+		}
+		knots = append(knots, profileKnot{at: at, multiplier: multiplier})
+	}
+	sort.SliceStable(knots, func(i, j int) bool { return knots[i].at < knots[j].at })
+
+	// Close the cycle so the profile covers the whole period
+	first, last := knots[0], knots[len(knots)-1]
+	wrap := last.multiplier
+	if p.Interpolation == "linear" && (p.Period-last.at)+first.at > 0 {
+		progress := float64(p.Period-last.at) / float64((p.Period-last.at)+first.at)
+		wrap = last.multiplier + (first.multiplier-last.multiplier)*progress
+	}
+	if first.at > 0 {
+		knots = append([]profileKnot{{at: 0, multiplier: wrap}}, knots...)
+	}
+	knots = append(knots, profileKnot{at: p.Period, multiplier: wrap})
+
+	scale := func(at time.Duration) time.Duration {
+		return time.Duration(float64(at) / float64(p.Period) * float64(duration)).Truncate(time.Millisecond)
+	}
+
+	var patterns []TrafficPattern
+	for i := 0; i < len(knots)-1; i++ {
+		start, end := scale(knots[i].at), scale(knots[i+1].at)
+		if i == len(knots)-2 {
+			end = duration
+		}
+		if end <= start {
+			continue
+		}
+		pattern := TrafficPattern{StartTime: start, EndTime: end, Shape: TrafficStep, RateMultiplier: knots[i].multiplier}
+		if p.Interpolation == "linear" && knots[i].multiplier != knots[i+1].multiplier {
+			pattern.Shape = TrafficRamp
+			pattern.EndMultiplier = knots[i+1].multiplier
+		}
+		patterns = append(patterns, pattern)
+	}
+
+	return &TrafficPatterns{
+		BaseRate:    baseRate,
+		Patterns:    patterns,
+		Description: fmt.Sprintf("Profile %s: %v compressed into %v", p.Name, p.Period, duration),
+	}, nil
+}
+
+// parseProfileTime parses "HH:MM", "HH:MM:SS" or a Go duration
+func parseProfileTime(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if !strings.Contains(value, ":") {
+		d, err := time.ParseDuration(value)
+		if err != nil || d < 0 {
+			return 0, fmt.Errorf("invalid time %q: expected HH:MM or a duration", value)
+		}
+		return d, nil
+	}
+
+	parts := strings.Split(value, ":")
+	if len(parts) > 3 {
+		return 0, fmt.Errorf("invalid time %q: expected HH:MM or HH:MM:SS", value)
+	}
+	var total time.Duration
+	units := []time.Duration{time.Hour, time.Minute, time.Second}
+	for i, part := range parts {
+		n, err := strconv.Atoi(part)
+		if err != nil || n < 0 || (i > 0 && n > 59) {
+			return 0, fmt.Errorf("invalid time %q: expected HH:MM or HH:MM:SS", value)
+		}
+		total += time.Duration(n) * units[i]
+	}
+	return total, nil
+}
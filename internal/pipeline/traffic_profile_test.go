@@ -0,0 +1,123 @@
+package pipeline
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const dayProfile = `name: retail
+points:
+  - {at: "06:00", rate: 50%}
+  - {at: "12:00", rate: 250%}
+  - {at: "18:00", rate: 150}
+`
+
+func TestLoadTrafficProfile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "day.yaml")
+	if err := os.WriteFile(path, []byte(dayProfile), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	// A day compressed into 240s: one profile hour lasts 10s
+	patterns, err := LoadTrafficProfile(path, 240*time.Second, 100)
+	if err != nil {
+		t.Fatalf("LoadTrafficProfile() error = %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		elapsed  time.Duration
+		wantRate int
+	}{
+		{name: "midnight between last and first point", elapsed: 0, wantRate: 100},
+		{name: "06:00", elapsed: 60 * time.Second, wantRate: 50},
+		{name: "09:00", elapsed: 90 * time.Second, wantRate: 150},
+		{name: "12:00", elapsed: 120 * time.Second, wantRate: 250},
+		{name: "18:00", elapsed: 180 * time.Second, wantRate: 150},
+		{name: "just before the end", elapsed: 239 * time.Second, wantRate: 101},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := patterns.GetRateAt(tt.elapsed); got != tt.wantRate {
+				t.Errorf("GetRateAt(%v) = %d, want %d", tt.elapsed, got, tt.wantRate)
+			}
+		})
+	}
+
+	last := patterns.Patterns[len(patterns.Patterns)-1]
+	if patterns.Patterns[0].StartTime != 0 || last.EndTime != 240*time.Second {
+		t.Errorf("profile does not span the run: %v-%v", patterns.Patterns[0].StartTime, last.EndTime)
+	}
+	if !strings.Contains(patterns.GetPatternSummary(), "Profile retail") {
+		t.Errorf("summary does not name the profile: %s", patterns.GetPatternSummary())
+	}
+}
+
+func TestTrafficProfileCompile(t *testing.T) {
+	tests := []struct {
+		name    string
+		profile TrafficProfile
+		wantErr bool
+	}{
+		{name: "step", profile: TrafficProfile{Interpolation: "step", Points: []ProfilePoint{{At: "0h", Rate: "100%"}, {At: "12:00", Rate: "200%"}}}},
+		{name: "custom period", profile: TrafficProfile{Period: time.Hour, Points: []ProfilePoint{{At: "30m", Rate: "200%"}}}},
+		{name: "no points", profile: TrafficProfile{}, wantErr: true},
+		{name: "bad time", profile: TrafficProfile{Points: []ProfilePoint{{At: "noon", Rate: "100%"}}}, wantErr: true},
+		{name: "bad minutes", profile: TrafficProfile{Points: []ProfilePoint{{At: "10:75", Rate: "100%"}}}, wantErr: true},
+		{name: "beyond period", profile: TrafficProfile{Period: time.Hour, Points: []ProfilePoint{{At: "02:00", Rate: "100%"}}}, wantErr: true},
+		{name: "zero rate", profile: TrafficProfile{Points: []ProfilePoint{{At: "01:00", Rate: "0%"}}}, wantErr: true},
+		{name: "unknown interpolation", profile: TrafficProfile{Interpolation: "cubic", Points: []ProfilePoint{{At: "01:00", Rate: "100%"}}}, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := tt.profile.Compile(time.Minute, 100)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Compile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	step := TrafficProfile{Interpolation: "step", Points: []ProfilePoint{{At: "00:00", Rate: "100%"}, {At: "12:00", Rate: "200%"}}}
+	patterns, err := step.Compile(time.Minute, 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := patterns.GetRateAt(29 * time.Second); got != 100 {
+		t.Errorf("step profile rate before noon = %d, want 100", got)
+	}
+	if got := patterns.GetRateAt(45 * time.Second); got != 200 {
+		t.Errorf("step profile rate after noon = %d, want 200", got)
+	}
+}
+
+func TestRateTimelineChart(t *testing.T) {
+	timeline := &rateTimeline{}
+	if timeline.chart() != nil {
+		t.Fatal("expected no chart without data")
+	}
+
+	for second := 0; second < 4; second++ {
+		for i := 0; i < 10*(second+1); i++ {
+			timeline.record(time.Duration(second)*time.Second, 20)
+		}
+	}
+
+	chart := timeline.chart()
+	if chart == nil {
+		t.Fatal("expected a chart")
+	}
+	if chart.Duration != 3*time.Second || chart.MaxRate != 30 {
+		t.Errorf("chart covers %v up to %d msg/sec, want 3s up to 30", chart.Duration, chart.MaxRate)
+	}
+	if n := len(strings.Fields(chart.ActualPoints)); n != 3 {
+		t.Errorf("expected 3 actual points, got %d", n)
+	}
+	// Seconds sent 10, 20 and 30 messages against 20 expected: 50%, 0% and 50% off
+	if chart.Deviation < 33.3 || chart.Deviation > 33.4 {
+		t.Errorf("Deviation = %.2f, want ~33.3", chart.Deviation)
+	}
+}
@@ -0,0 +1,446 @@
+package pipeline
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Validation check kinds; they decide how violations are counted in the data quality metrics
+const (
+	CheckRequired = "required"
+	CheckType     = "type"
+	CheckRange    = "range"
+	CheckRegex    = "regex"
+	CheckUnique   = "unique"
+	CheckExpr     = "expr"
+)
+
+// Validation limits
+const (
+	maxValidationSamples = 5       // Offending records kept per rule
+	maxUniqueValues      = 1000000 // Values remembered per unique field; later values are not checked
+)
+
+// liveValidation holds the validators of the running consumer, for live displays
+var liveValidation atomic.Pointer[[]*RecordValidator]
+
+// LiveValidationReport returns the validation results of the running consumer across its output topics,
+// or nil without validation rules
+func LiveValidationReport() *ValidationReport {
+	validators := liveValidation.Load()
+	if validators == nil || len(*validators) == 0 {
+		return nil
+	}
+	reports := make([]*ValidationReport, len(*validators))
+	for i, validator := range *validators {
+		reports[i] = validator.Report()
+	}
+	return combineValidationReports(reports)
+}
+
+// ValidationRule declares checks on one output field, or a cross-field expression that must hold
+// (validation section of the project config, keyed by output table or topic)
+type ValidationRule struct {
+	Name     string   `yaml:"name" mapstructure:"name"`         // Label in reports (default: derived from the checks)
+	Field    string   `yaml:"field" mapstructure:"field"`       // Checked field; nested fields use dots (address.zip)
+	Required bool     `yaml:"required" mapstructure:"required"` // Field present and not null
+	Type     string   `yaml:"type" mapstructure:"type"`         // string, number, integer, boolean, timestamp, record or array
+	Min      *float64 `yaml:"min" mapstructure:"min"`           // Smallest allowed number (or string length for strings)
+	Max      *float64 `yaml:"max" mapstructure:"max"`           // Largest allowed number (or string length for strings)
+	Regex    string   `yaml:"regex" mapstructure:"regex"`       // Pattern string values must match
+	Unique   bool     `yaml:"unique" mapstructure:"unique"`     // No two records share the value
+	Expr     string   `yaml:"expr" mapstructure:"expr"`         // Cross-field expression, e.g. "window_end > window_start"
+}
+
+// validationTypes are the accepted values of ValidationRule.Type
+var validationTypes = map[string]bool{"string": true, "number": true, "integer": true, "boolean": true, "timestamp": true, "record": true, "array": true}
+
+// Validate checks that the rule declares something to check
+func (r *ValidationRule) Validate() error {
+	if r.Field == "" && r.Expr == "" {
+		return fmt.Errorf("a rule needs a field or an expr")
+	}
+	if r.Field != "" && !r.Required && r.Type == "" && r.Min == nil && r.Max == nil && r.Regex == "" && !r.Unique && r.Expr == "" {
+		return fmt.Errorf("rule for %s declares no check", r.Field)
+	}
+	if r.Type != "" && !validationTypes[r.Type] {
+		return fmt.Errorf("unknown type %q for %s (use string, number, integer, boolean, timestamp, record or array)", r.Type, r.Field)
+	}
+	if r.Min != nil && r.Max != nil && *r.Min > *r.Max {
+		return fmt.Errorf("min is greater than max for %s", r.Field)
+	}
+	if r.Regex != "" {
+		if _, err := regexp.Compile(r.Regex); err != nil {
+			return fmt.Errorf("invalid regex for %s: %w", r.Field, err)
+		}
+	}
+	if r.Expr != "" {
+		if _, err := parseRuleExpr(r.Expr); err != nil {
+			return fmt.Errorf("invalid expr %q: %w", r.Expr, err)
+		}
+	}
+	return nil
+}
+
+// ValidateRules checks every rule of an output
+func ValidateRules(rules []ValidationRule) error {
+	for i := range rules {
+		if err := rules[i].Validate(); err != nil {
+			return fmt.Errorf("rule %d: %w", i+1, err)
+		}
+	}
+	return nil
+}
+
+// RulesFor picks the validation rules of an output from the validation section: the key may be the
+// output topic or the name of the table the pipeline writes it through
+func RulesFor(validation map[string][]ValidationRule, topic string, tables []SourceTable) []ValidationRule {
+	for key, rules := range validation {
+		if strings.EqualFold(key, topic) {
+			return rules
+		}
+		for _, table := range tables {
+			if table.Topic == topic && matchesSource(key, table) {
+				return rules
+			}
+		}
+	}
+	return nil
+}
+
+// recordCheck is one compiled check of a rule
+type recordCheck struct {
+	name  string
+	kind  string
+	check func(record map[string]interface{}) (bool, string) // false with the reason on a violation
+}
+
+// RecordValidator evaluates the validation rules of an output on every decoded record.
+// It implements MessageValidator and is safe for concurrent use.
+type RecordValidator struct {
+	topic  string
+	checks []recordCheck
+
+	mu         sync.Mutex
+	unique     map[string]map[string]bool // Values seen per unique field
+	checked    int64
+	invalid    int64
+	violations map[string]int64 // Per check name
+	kinds      map[string]int64 // Per check kind
+	samples    map[string][]ValidationSample
+}
+
+// NewRecordValidator compiles the rules of an output topic
+func NewRecordValidator(topic string, rules []ValidationRule) (*RecordValidator, error) {
+	if err := ValidateRules(rules); err != nil {
+		return nil, err
+	}
+	v := &RecordValidator{
+		topic:      topic,
+		unique:     make(map[string]map[string]bool),
+		violations: make(map[string]int64),
+		kinds:      make(map[string]int64),
+		samples:    make(map[string][]ValidationSample),
+	}
+	for _, rule := range rules {
+		v.checks = append(v.checks, v.compile(rule)...)
+	}
+	return v, nil
+}
+
+// compile turns a rule into its checks; the rule was validated before
+func (v *RecordValidator) compile(rule ValidationRule) []recordCheck {
+	name := func(check string) string {
+		if rule.Name != "" {
+			return rule.Name
+		}
+		if rule.Field == "" {
+			return check
+		}
+		return rule.Field + " " + check
+	}
+	path := strings.Split(rule.Field, ".")
+
+	var checks []recordCheck
+	if rule.Required {
+		checks = append(checks, recordCheck{name: name("required"), kind: CheckRequired, check: func(record map[string]interface{}) (bool, string) {
+			if fieldValue(record, path) == nil {
+				return false, "missing or null"
+			}
+			return true, ""
+		}})
+	}
+	if rule.Type != "" {
+		checks = append(checks, recordCheck{name: name("is " + rule.Type), kind: CheckType, check: func(record map[string]interface{}) (bool, string) {
+			value := fieldValue(record, path)
+			if value == nil || hasValidationType(value, rule.Type) {
+				return true, ""
+			}
+			return false, fmt.Sprintf("%s is not a %s", formatValue(value), rule.Type)
+		}})
+	}
+	if rule.Min != nil || rule.Max != nil {
+		var label string
+		switch {
+		case rule.Min != nil && rule.Max != nil:
+			label = fmt.Sprintf("in [%g, %g]", *rule.Min, *rule.Max)
+		case rule.Min != nil:
+			label = fmt.Sprintf(">= %g", *rule.Min)
+		default:
+			label = fmt.Sprintf("<= %g", *rule.Max)
+		}
+		checks = append(checks, recordCheck{name: name(label), kind: CheckRange, check: func(record map[string]interface{}) (bool, string) {
+			value := fieldValue(record, path)
+			if value == nil {
+				return true, ""
+			}
+			n, ok := toFloat(exprValue(value))
+			if s, isString := normalizeOutputValue(value).(string); isString {
+				n, ok = float64(len([]rune(s))), true
+			}
+			if !ok {
+				return false, fmt.Sprintf("%s is not a number", formatValue(value))
+			}
+			if (rule.Min != nil && n < *rule.Min) || (rule.Max != nil && n > *rule.Max) {
+				return false, fmt.Sprintf("%s is out of range", formatValue(value))
+			}
+			return true, ""
+		}})
+	}
+	if rule.Regex != "" {
+		pattern := regexp.MustCompile(rule.Regex)
+		checks = append(checks, recordCheck{name: name("matches " + rule.Regex), kind: CheckRegex, check: func(record map[string]interface{}) (bool, string) {
+			value := fieldValue(record, path)
+			if value == nil {
+				return true, ""
+			}
+			s, ok := normalizeOutputValue(value).(string)
+			if !ok || !pattern.MatchString(s) {
+				return false, fmt.Sprintf("%s does not match %s", formatValue(value), rule.Regex)
+			}
+			return true, ""
+		}})
+	}
+	if rule.Unique {
+		field := rule.Field
+		v.unique[field] = make(map[string]bool)
+		checks = append(checks, recordCheck{name: name("unique"), kind: CheckUnique, check: func(record map[string]interface{}) (bool, string) {
+			value := fieldValue(record, path)
+			if value == nil {
+				return true, ""
+			}
+			key := formatValue(value)
+			seen := v.unique[field]
+			if seen[key] {
+				return false, fmt.Sprintf("duplicate value %s", key)
+			}
+			if len(seen) < maxUniqueValues {
+				seen[key] = true
+			}
+			return true, ""
+		}})
+	}
+	if rule.Expr != "" {
+		expr, _ := parseRuleExpr(rule.Expr)
+		label := rule.Expr
+		if rule.Name != "" {
+			label = rule.Name
+		}
+		checks = append(checks, recordCheck{name: label, kind: CheckExpr, check: func(record map[string]interface{}) (bool, string) {
+			result, err := expr.eval(record)
+			if err != nil {
+				return false, err.Error()
+			}
+			if result != true {
+				return false, "does not hold"
+			}
+			return true, ""
+		}})
+	}
+	return checks
+}
+
+// hasValidationType reports whether a decoded value has the declared type
+func hasValidationType(value interface{}, typ string) bool {
+	value = normalizeOutputValue(value)
+	switch typ {
+	case "string":
+		_, ok := value.(string)
+		return ok
+	case "number":
+		_, ok := toFloat(value)
+		return ok
+	case "integer":
+		if _, ok := toInt(value); ok {
+			return true
+		}
+		f, ok := toFloat(value)
+		return ok && f == float64(int64(f))
+	case "boolean":
+		_, ok := value.(bool)
+		return ok
+	case "timestamp":
+		_, ok := value.(time.Time)
+		return ok
+	case "record":
+		_, ok := value.(map[string]interface{})
+		return ok
+	default:
+		_, ok := value.([]interface{})
+		return ok
+	}
+}
+
+// Check evaluates every rule on a decoded record, counts violations and keeps samples of offending
+// records; partition and offset locate the record in the output topic
+func (v *RecordValidator) Check(record map[string]interface{}, partition int, offset int64) []string {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.checked++
+	var failed []string
+	for _, c := range v.checks {
+		ok, reason := c.check(record)
+		if ok {
+			continue
+		}
+		failed = append(failed, fmt.Sprintf("%s: %s", c.name, reason))
+		v.violations[c.name]++
+		v.kinds[c.kind]++
+		if len(v.samples[c.name]) < maxValidationSamples {
+			v.samples[c.name] = append(v.samples[c.name], ValidationSample{
+				Rule:      c.name,
+				Reason:    reason,
+				Partition: partition,
+				Offset:    offset,
+				Record:    formatRecord(record),
+			})
+		}
+	}
+	if len(failed) > 0 {
+		v.invalid++
+	}
+	return failed
+}
+
+// Validate checks a record against the rules (MessageValidator)
+func (v *RecordValidator) Validate(message map[string]interface{}) error {
+	if failed := v.Check(message, -1, -1); len(failed) > 0 {
+		return fmt.Errorf("validation failed: %s", strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// ValidationSample is an output record that broke a rule
+type ValidationSample struct {
+	Rule      string `json:"rule"`
+	Reason    string `json:"reason"`
+	Partition int    `json:"partition"`
+	Offset    int64  `json:"offset"`
+	Record    string `json:"record"`
+}
+
+// RuleViolations counts the records that broke one rule
+type RuleViolations struct {
+	Rule       string `json:"rule"`
+	Kind       string `json:"kind"`
+	Violations int64  `json:"violations"`
+}
+
+// ValidationReport summarizes the validation of an output topic
+type ValidationReport struct {
+	Topic          string             `json:"topic"`
+	Checked        int64              `json:"checked"`
+	Valid          int64              `json:"valid"`
+	Invalid        int64              `json:"invalid"`
+	Rules          []RuleViolations   `json:"rules"`
+	Samples        []ValidationSample `json:"samples,omitempty"`
+	KindViolations map[string]int64   `json:"kind_violations,omitempty"` // Violations per check kind (required, type, unique, ...)
+}
+
+// Report returns the validation results so far
+func (v *RecordValidator) Report() *ValidationReport {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	report := &ValidationReport{
+		Topic:          v.topic,
+		Checked:        v.checked,
+		Valid:          v.checked - v.invalid,
+		Invalid:        v.invalid,
+		KindViolations: make(map[string]int64, len(v.kinds)),
+	}
+	for kind, count := range v.kinds {
+		report.KindViolations[kind] = count
+	}
+	seen := make(map[string]bool)
+	for _, c := range v.checks {
+		if seen[c.name] {
+			continue
+		}
+		seen[c.name] = true
+		report.Rules = append(report.Rules, RuleViolations{Rule: c.name, Kind: c.kind, Violations: v.violations[c.name]})
+		report.Samples = append(report.Samples, v.samples[c.name]...)
+	}
+	return report
+}
+
+// combineValidationReports sums up the reports of several output topics; rules are prefixed with their topic
+func combineValidationReports(reports []*ValidationReport) *ValidationReport {
+	if len(reports) == 1 {
+		return reports[0]
+	}
+	combined := &ValidationReport{KindViolations: make(map[string]int64)}
+	topics := make([]string, len(reports))
+	for i, report := range reports {
+		topics[i] = report.Topic
+		combined.Checked += report.Checked
+		combined.Valid += report.Valid
+		combined.Invalid += report.Invalid
+		for _, rule := range report.Rules {
+			rule.Rule = report.Topic + ": " + rule.Rule
+			combined.Rules = append(combined.Rules, rule)
+		}
+		for _, sample := range report.Samples {
+			sample.Rule = report.Topic + ": " + sample.Rule
+			combined.Samples = append(combined.Samples, sample)
+		}
+		for kind, count := range report.KindViolations {
+			combined.KindViolations[kind] += count
+		}
+	}
+	combined.Topic = strings.Join(topics, ", ")
+	return combined
+}
+
+// QualityScore is the share of checked records that broke no rule, in percent
+func (r *ValidationReport) QualityScore() float64 {
+	if r.Checked == 0 {
+		return 100
+	}
+	return float64(r.Valid) / float64(r.Checked) * 100
+}
+
+// Print writes the violation counts and up to limit sample records to the console
+func (r *ValidationReport) Print(limit int) {
+	fmt.Printf("🧪 Validation of %s: %d of %d records valid (%.1f%%)\n", r.Topic, r.Valid, r.Checked, r.QualityScore())
+	rules := append([]RuleViolations(nil), r.Rules...)
+	sort.SliceStable(rules, func(i, j int) bool { return rules[i].Violations > rules[j].Violations })
+	for _, rule := range rules {
+		if rule.Violations > 0 {
+			fmt.Printf("   ❌ %s: %d violation(s)\n", rule.Rule, rule.Violations)
+		}
+	}
+	for i, sample := range r.Samples {
+		if i == limit {
+			fmt.Printf("   ... %d more sample(s) in the execution report\n", len(r.Samples)-limit)
+			break
+		}
+		fmt.Printf("   %s (partition %d, offset %d): %s\n      %s\n", sample.Rule, sample.Partition, sample.Offset, sample.Reason, sample.Record)
+	}
+}
@@ -0,0 +1,436 @@
+package pipeline
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// ruleExpr is a compiled cross-field expression of a validation rule, such as
+// "window_end > window_start" or "total == price * quantity && status != 'CANCELLED'".
+//
+// Operands are field names (nested fields use dots), numbers, quoted strings, true, false and null.
+// Operators, loosest first: || (or), && (and), comparisons (== = != <> < <= > >=), + -, * / %, unary - and ! (not).
+// Timestamps compare with each other, with epoch milliseconds and with date strings; subtracting two
+// timestamps gives milliseconds.
+type ruleExpr interface {
+	eval(record map[string]interface{}) (interface{}, error)
+}
+
+type exprLiteral struct{ value interface{} }
+
+type exprField struct{ path []string }
+
+type exprUnary struct {
+	op string
+	x  ruleExpr
+}
+
+type exprBinary struct {
+	op   string
+	x, y ruleExpr
+}
+
+// parseRuleExpr compiles an expression
+func parseRuleExpr(src string) (ruleExpr, error) {
+	tokens, err := tokenizeRuleExpr(src)
+	if err != nil {
+		return nil, err
+	}
+	p := &exprParser{tokens: tokens}
+	expr, err := p.parseOr()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, fmt.Errorf("unexpected %q", p.tokens[p.pos].text)
+	}
+	return expr, nil
+}
+
+type exprToken struct {
+	kind string // "number", "string", "ident" or "op"
+	text string
+}
+
+func tokenizeRuleExpr(src string) ([]exprToken, error) {
+	var tokens []exprToken
+	for i := 0; i < len(src); {
+		c := rune(src[i])
+		switch {
+		case unicode.IsSpace(c):
+			i++
+		case c >= '0' && c <= '9' || c == '.' && i+1 < len(src) && src[i+1] >= '0' && src[i+1] <= '9':
+			j := i
+			for j < len(src) && (src[j] >= '0' && src[j] <= '9' || src[j] == '.') {
+				j++
+			}
+			tokens = append(tokens, exprToken{kind: "number", text: src[i:j]})
+			i = j
+		case c == '\'' || c == '"':
+			j := strings.IndexByte(src[i+1:], src[i])
+			if j < 0 {
+				return nil, fmt.Errorf("unterminated string at position %d", i)
+			}
+			tokens = append(tokens, exprToken{kind: "string", text: src[i+1 : i+1+j]})
+			i += j + 2
+		case c == '_' || unicode.IsLetter(c):
+			j := i
+			for j < len(src) && (src[j] == '_' || src[j] == '.' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			tokens = append(tokens, exprToken{kind: "ident", text: src[i:j]})
+			i = j
+		default:
+			op := ""
+			for _, candidate := range []string{"&&", "||", "==", "!=", "<>", "<=", ">=", "<", ">", "=", "!", "+", "-", "*", "/", "%", "(", ")"} {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				return nil, fmt.Errorf("unexpected character %q at position %d", c, i)
+			}
+			tokens = append(tokens, exprToken{kind: "op", text: op})
+			i += len(op)
+		}
+	}
+	return tokens, nil
+}
+
+type exprParser struct {
+	tokens []exprToken
+	pos    int
+}
+
+// accept consumes the next token if it is one of the operators (or keywords) and returns its canonical operator
+func (p *exprParser) accept(ops ...string) (string, bool) {
+	if p.pos >= len(p.tokens) {
+		return "", false
+	}
+	tok := p.tokens[p.pos]
+	text := tok.text
+	if tok.kind == "ident" {
+		text = map[string]string{"and": "&&", "or": "||", "not": "!"}[strings.ToLower(text)]
+	} else if tok.kind == "op" {
+		text = map[string]string{"=": "==", "<>": "!="}[text]
+		if text == "" {
+			text = tok.text
+		}
+	} else {
+		return "", false
+	}
+	for _, op := range ops {
+		if text == op {
+			p.pos++
+			return op, true
+		}
+	}
+	return "", false
+}
+
+func (p *exprParser) parseOr() (ruleExpr, error) {
+	return p.parseBinary(p.parseAnd, "||")
+}
+
+func (p *exprParser) parseAnd() (ruleExpr, error) {
+	return p.parseBinary(p.parseComparison, "&&")
+}
+
+func (p *exprParser) parseComparison() (ruleExpr, error) {
+	return p.parseBinary(p.parseSum, "==", "!=", "<=", ">=", "<", ">")
+}
+
+func (p *exprParser) parseSum() (ruleExpr, error) {
+	return p.parseBinary(p.parseProduct, "+", "-")
+}
+
+func (p *exprParser) parseProduct() (ruleExpr, error) {
+	return p.parseBinary(p.parseUnary, "*", "/", "%")
+}
+
+// parseBinary parses a left-associative chain of operands joined by the operators
+func (p *exprParser) parseBinary(operand func() (ruleExpr, error), ops ...string) (ruleExpr, error) {
+	x, err := operand()
+	if err != nil {
+		return nil, err
+	}
+	for {
+		op, ok := p.accept(ops...)
+		if !ok {
+			return x, nil
+		}
+		y, err := operand()
+		if err != nil {
+			return nil, err
+		}
+		x = &exprBinary{op: op, x: x, y: y}
+	}
+}
+
+func (p *exprParser) parseUnary() (ruleExpr, error) {
+	if op, ok := p.accept("-", "!"); ok {
+		x, err := p.parseUnary()
+		if err != nil {
+			return nil, err
+		}
+		return &exprUnary{op: op, x: x}, nil
+	}
+	return p.parsePrimary()
+}
+
+func (p *exprParser) parsePrimary() (ruleExpr, error) {
+	if p.pos >= len(p.tokens) {
+		return nil, fmt.Errorf("unexpected end of expression")
+	}
+	if _, ok := p.accept("("); ok {
+		x, err := p.parseOr()
+		if err != nil {
+			return nil, err
+		}
+		if _, ok := p.accept(")"); !ok {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		return x, nil
+	}
+
+	tok := p.tokens[p.pos]
+	p.pos++
+	switch tok.kind {
+	case "number":
+		f, err := strconv.ParseFloat(tok.text, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid number %q", tok.text)
+		}
+		return &exprLiteral{value: f}, nil
+	case "string":
+		return &exprLiteral{value: tok.text}, nil
+	case "ident":
+		switch strings.ToLower(tok.text) {
+		case "true":
+			return &exprLiteral{value: true}, nil
+		case "false":
+			return &exprLiteral{value: false}, nil
+		case "null":
+			return &exprLiteral{value: nil}, nil
+		}
+		return &exprField{path: strings.Split(tok.text, ".")}, nil
+	}
+	return nil, fmt.Errorf("unexpected %q", tok.text)
+}
+
+func (e *exprLiteral) eval(map[string]interface{}) (interface{}, error) {
+	return e.value, nil
+}
+
+func (e *exprField) eval(record map[string]interface{}) (interface{}, error) {
+	return exprValue(fieldValue(record, e.path)), nil
+}
+
+// fieldValue looks up a (nested) field, unwrapping unions on the way; missing fields are nil.
+// A record with a single field looks like a union, so the field itself is tried first.
+func fieldValue(record map[string]interface{}, path []string) interface{} {
+	var v interface{} = record
+	for _, name := range path {
+		if m, ok := v.(map[string]interface{}); ok {
+			if field, found := m[name]; found {
+				v = field
+				continue
+			}
+		}
+		m, ok := unwrapUnion(v).(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		v = m[name]
+	}
+	return unwrapUnion(v)
+}
+
+// exprValue normalizes a decoded value: numbers become float64, bytes and decimals are converted
+func exprValue(v interface{}) interface{} {
+	v = normalizeOutputValue(v)
+	if f, ok := toFloat(v); ok {
+		return f
+	}
+	return v
+}
+
+func (e *exprUnary) eval(record map[string]interface{}) (interface{}, error) {
+	x, err := e.x.eval(record)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "-":
+		if f, ok := x.(float64); ok {
+			return -f, nil
+		}
+		return nil, fmt.Errorf("cannot negate %s", formatValue(x))
+	default:
+		if b, ok := x.(bool); ok {
+			return !b, nil
+		}
+		return nil, fmt.Errorf("cannot apply not to %s", formatValue(x))
+	}
+}
+
+func (e *exprBinary) eval(record map[string]interface{}) (interface{}, error) {
+	x, err := e.x.eval(record)
+	if err != nil {
+		return nil, err
+	}
+
+	// Logical operators short-circuit
+	if e.op == "&&" || e.op == "||" {
+		xb, ok := x.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a condition", formatValue(x))
+		}
+		if xb == (e.op == "||") {
+			return xb, nil
+		}
+		y, err := e.y.eval(record)
+		if err != nil {
+			return nil, err
+		}
+		yb, ok := y.(bool)
+		if !ok {
+			return nil, fmt.Errorf("%s is not a condition", formatValue(y))
+		}
+		return yb, nil
+	}
+
+	y, err := e.y.eval(record)
+	if err != nil {
+		return nil, err
+	}
+	switch e.op {
+	case "+", "-", "*", "/", "%":
+		return arithmetic(e.op, x, y)
+	}
+	return compare(e.op, x, y)
+}
+
+func arithmetic(op string, x, y interface{}) (interface{}, error) {
+	if xt, ok := x.(time.Time); ok && op == "-" {
+		if yt, ok := y.(time.Time); ok {
+			return float64(xt.Sub(yt)) / float64(time.Millisecond), nil
+		}
+	}
+	xf, xok := x.(float64)
+	yf, yok := y.(float64)
+	if !xok || !yok {
+		return nil, fmt.Errorf("cannot compute %s %s %s", formatValue(x), op, formatValue(y))
+	}
+	switch op {
+	case "+":
+		return xf + yf, nil
+	case "-":
+		return xf - yf, nil
+	case "*":
+		return xf * yf, nil
+	case "/":
+		if yf == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return xf / yf, nil
+	default:
+		if yf == 0 {
+			return nil, fmt.Errorf("division by zero")
+		}
+		return math.Mod(xf, yf), nil
+	}
+}
+
+// compare applies a comparison; null only equals null, and ordering a null fails the rule
+func compare(op string, x, y interface{}) (interface{}, error) {
+	if x == nil || y == nil {
+		switch op {
+		case "==":
+			return x == nil && y == nil, nil
+		case "!=":
+			return (x == nil) != (y == nil), nil
+		}
+		return false, nil
+	}
+
+	var order int
+	switch xv := x.(type) {
+	case float64:
+		yv, ok := y.(float64)
+		if !ok {
+			if yt, isTime := y.(time.Time); isTime {
+				return compareTimes(op, time.UnixMilli(int64(xv)), yt), nil
+			}
+			return nil, fmt.Errorf("cannot compare %s with %s", formatValue(x), formatValue(y))
+		}
+		order = compareOrdered(xv, yv)
+	case string:
+		if yt, isTime := y.(time.Time); isTime {
+			xt, ok := toTime(xv)
+			if !ok {
+				return nil, fmt.Errorf("cannot compare %s with %s", formatValue(x), formatValue(y))
+			}
+			return compareTimes(op, xt, yt), nil
+		}
+		yv, ok := y.(string)
+		if !ok {
+			return nil, fmt.Errorf("cannot compare %s with %s", formatValue(x), formatValue(y))
+		}
+		order = strings.Compare(xv, yv)
+	case time.Time:
+		yt, ok := toTime(y)
+		if f, isNumber := y.(float64); isNumber {
+			yt, ok = time.UnixMilli(int64(f)), true
+		}
+		if !ok {
+			return nil, fmt.Errorf("cannot compare %s with %s", formatValue(x), formatValue(y))
+		}
+		return compareTimes(op, xv, yt), nil
+	case bool:
+		yv, ok := y.(bool)
+		if !ok || (op != "==" && op != "!=") {
+			return nil, fmt.Errorf("cannot compare %s with %s", formatValue(x), formatValue(y))
+		}
+		return (xv == yv) == (op == "=="), nil
+	default:
+		return nil, fmt.Errorf("cannot compare %s", formatValue(x))
+	}
+	return orderHolds(op, order), nil
+}
+
+func compareTimes(op string, x, y time.Time) bool {
+	return orderHolds(op, x.Compare(y))
+}
+
+func compareOrdered(x, y float64) int {
+	switch {
+	case x < y:
+		return -1
+	case x > y:
+		return 1
+	}
+	return 0
+}
+
+func orderHolds(op string, order int) bool {
+	switch op {
+	case "==":
+		return order == 0
+	case "!=":
+		return order != 0
+	case "<":
+		return order < 0
+	case "<=":
+		return order <= 0
+	case ">":
+		return order > 0
+	default:
+		return order >= 0
+	}
+}
@@ -0,0 +1,251 @@
+package pipeline
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+)
+
+func TestRuleExpr(t *testing.T) {
+	start := time.Date(2024, 1, 1, 10, 0, 0, 0, time.UTC)
+	record := map[string]interface{}{
+		"price":        9.5,
+		"quantity":     int32(2),
+		"total":        19.0,
+		"status":       "PAID",
+		"paid":         true,
+		"note":         nil,
+		"window_start": start,
+		"window_end":   map[string]interface{}{"long.timestamp-millis": start.Add(time.Minute)},
+		"address":      map[string]interface{}{"zip": "10115"},
+	}
+
+	tests := []struct {
+		expr    string
+		want    interface{}
+		wantErr bool
+	}{
+		{expr: "total == price * quantity", want: true},
+		{expr: "total = price * quantity and status <> 'CANCELLED'", want: true},
+		{expr: "(price + 0.5) / quantity == 5", want: true},
+		{expr: "-price < 0 && !(quantity % 2 == 1)", want: true},
+		{expr: "window_end > window_start", want: true},
+		{expr: "window_end - window_start == 60000", want: true},
+		{expr: "window_start >= '2024-01-01T10:00:00Z'", want: true},
+		{expr: "address.zip == \"10115\"", want: true},
+		{expr: "paid == true or missing > 1", want: true},
+		{expr: "note == null && missing == null", want: true},
+		{expr: "note > 1", want: false},
+		{expr: "status == 1", wantErr: true},
+		{expr: "price / (quantity - 2)", wantErr: true},
+		{expr: "price && paid", wantErr: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			expr, err := parseRuleExpr(tt.expr)
+			if err != nil {
+				t.Fatalf("parseRuleExpr() error = %v", err)
+			}
+			got, err := expr.eval(record)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("eval() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && got != tt.want {
+				t.Errorf("eval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	for _, src := range []string{"", "a >", "(a > 1", "a > 1)", "a # 1", "name == 'open"} {
+		if _, err := parseRuleExpr(src); err == nil {
+			t.Errorf("parseRuleExpr(%q) expected an error", src)
+		}
+	}
+}
+
+func TestValidationRuleValidate(t *testing.T) {
+	one, two := 1.0, 2.0
+	tests := []struct {
+		name    string
+		rule    ValidationRule
+		wantErr string
+	}{
+		{name: "field checks", rule: ValidationRule{Field: "id", Required: true, Type: "string", Regex: "^[a-z]+$", Unique: true}},
+		{name: "expression", rule: ValidationRule{Expr: "a < b"}},
+		{name: "range", rule: ValidationRule{Field: "amount", Min: &one, Max: &two}},
+		{name: "nothing to check", rule: ValidationRule{}, wantErr: "needs a field or an expr"},
+		{name: "no check", rule: ValidationRule{Field: "id"}, wantErr: "declares no check"},
+		{name: "unknown type", rule: ValidationRule{Field: "id", Type: "uuid"}, wantErr: "unknown type"},
+		{name: "inverted range", rule: ValidationRule{Field: "amount", Min: &two, Max: &one}, wantErr: "min is greater than max"},
+		{name: "bad regex", rule: ValidationRule{Field: "id", Regex: "("}, wantErr: "invalid regex"},
+		{name: "bad expression", rule: ValidationRule{Expr: "a <"}, wantErr: "invalid expr"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("Validate() unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Validate() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRecordValidator(t *testing.T) {
+	zero, hundred, three := 0.0, 100.0, 3.0
+	validator, err := NewRecordValidator("run-paid-orders", []ValidationRule{
+		{Field: "order_id", Required: true, Unique: true},
+		{Field: "amount", Type: "number", Min: &zero, Max: &hundred},
+		{Field: "currency", Regex: "^[A-Z]+$", Max: &three},
+		{Name: "window ordered", Expr: "window_end > window_start"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	start := time.Unix(1700000000, 0)
+	record := func(id interface{}, amount interface{}, currency string, end time.Time) map[string]interface{} {
+		return map[string]interface{}{"order_id": id, "amount": amount, "currency": currency, "window_start": start, "window_end": end}
+	}
+	records := []map[string]interface{}{
+		record("a", 10.0, "EUR", start.Add(time.Minute)),
+		record("b", int64(250), "EUR", start.Add(time.Minute)),
+		record("a", "ten", "euro", start),
+		record(nil, 5.0, "USD", start.Add(time.Minute)),
+	}
+	for i, r := range records {
+		validator.Check(r, 0, int64(i))
+	}
+
+	report := validator.Report()
+	if report.Checked != 4 || report.Valid != 1 || report.Invalid != 3 {
+		t.Fatalf("report = %d checked, %d valid, %d invalid, want 4, 1, 3", report.Checked, report.Valid, report.Invalid)
+	}
+	if score := report.QualityScore(); score != 25 {
+		t.Errorf("QualityScore() = %v, want 25", score)
+	}
+
+	want := map[string]int64{
+		"order_id required":         1,
+		"order_id unique":           1,
+		"amount is number":          1,
+		"amount in [0, 100]":        1,
+		"currency matches ^[A-Z]+$": 1,
+		"currency <= 3":             1,
+		"window ordered":            1,
+	}
+	if len(report.Rules) != len(want) {
+		t.Fatalf("got %d rules, want %d: %+v", len(report.Rules), len(want), report.Rules)
+	}
+	for _, rule := range report.Rules {
+		if rule.Violations != want[rule.Rule] {
+			t.Errorf("%s: %d violations, want %d", rule.Rule, rule.Violations, want[rule.Rule])
+		}
+	}
+	if report.KindViolations[CheckRequired] != 1 || report.KindViolations[CheckUnique] != 1 || report.KindViolations[CheckRange] != 2 {
+		t.Errorf("unexpected violations per kind: %v", report.KindViolations)
+	}
+
+	if len(report.Samples) != 7 {
+		t.Fatalf("got %d samples, want one per violation", len(report.Samples))
+	}
+	sample := report.Samples[0]
+	if sample.Rule != "order_id required" || sample.Offset != 3 || !strings.Contains(sample.Record, "USD") {
+		t.Errorf("unexpected first sample: %+v", sample)
+	}
+
+	if err := validator.Validate(record("c", 1.0, "EUR", start.Add(time.Second))); err != nil {
+		t.Errorf("Validate() unexpected error: %v", err)
+	}
+	if err := validator.Validate(record("d", -1.0, "EUR", start.Add(time.Second))); err == nil || !strings.Contains(err.Error(), "amount in [0, 100]") {
+		t.Errorf("Validate() error = %v, want the range violation", err)
+	}
+}
+
+func TestRecordValidatorKeepsFewSamples(t *testing.T) {
+	validator, err := NewRecordValidator("out", []ValidationRule{{Field: "id", Required: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 20; i++ {
+		validator.Check(map[string]interface{}{}, 1, int64(i))
+	}
+	report := validator.Report()
+	if report.Rules[0].Violations != 20 || len(report.Samples) != maxValidationSamples {
+		t.Errorf("got %d violations and %d samples, want 20 and %d", report.Rules[0].Violations, len(report.Samples), maxValidationSamples)
+	}
+}
+
+func TestRulesFor(t *testing.T) {
+	orders := []ValidationRule{{Field: "order_id", Required: true}}
+	totals := []ValidationRule{{Field: "total", Type: "number"}}
+	validation := map[string][]ValidationRule{"paid_orders": orders, "run-totals": totals}
+	sinks := []SourceTable{{Table: "paid_orders", Topic: "run-paid-orders"}, {Table: "totals", Topic: "run-totals"}}
+
+	if got := RulesFor(validation, "run-paid-orders", sinks); len(got) != 1 || got[0].Field != "order_id" {
+		t.Errorf("expected the rules of the paid_orders table, got %+v", got)
+	}
+	if got := RulesFor(validation, "RUN-TOTALS", nil); len(got) != 1 || got[0].Field != "total" {
+		t.Errorf("expected the rules of the run-totals topic, got %+v", got)
+	}
+	if got := RulesFor(validation, "run-other", sinks); got != nil {
+		t.Errorf("expected no rules for an unknown output, got %+v", got)
+	}
+}
+
+func TestConsumerValidatesOutputRecords(t *testing.T) {
+	users, err := NewRecordValidator("users", []ValidationRule{{Field: "user_id", Required: true}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	alerts, err := NewRecordValidator("alerts", []ValidationRule{{Field: "level", Regex: "^(WARN|ERROR)$"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	consumer := &Consumer{config: &Config{}, latency: newLatencyTracker()}
+	consumer.AddSink(SourceTable{Table: "users", Topic: "users"}, 0)
+	consumer.AddSink(SourceTable{Table: "alerts", Topic: "alerts"}, 0)
+	consumer.SetValidator(alerts)
+	consumer.SetValidator(users)
+	defer liveValidation.Store(nil)
+
+	messages := []*kafka.Message{
+		{Topic: "users", Value: []byte(`{"user_id": "a"}`), Partition: 2, Offset: 0},
+		{Topic: "users", Value: []byte(`{"user_id": null}`), Partition: 2, Offset: 1},
+		{Topic: "alerts", Value: []byte(`{"level": "INFO"}`), Partition: 0, Offset: 0},
+	}
+	for _, msg := range messages {
+		if err := consumer.processMessage(msg); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	reports := consumer.ValidationReports()
+	if len(reports) != 2 || reports[0].Topic != "users" {
+		t.Fatalf("got %d reports, want the users report first", len(reports))
+	}
+	if report := reports[0]; report.Checked != 2 || report.Invalid != 1 {
+		t.Fatalf("report = %+v, want one of two records invalid", report)
+	}
+	if sample := reports[0].Samples[0]; sample.Partition != 2 || sample.Offset != 1 {
+		t.Errorf("sample at partition %d, offset %d, want 2, 1", sample.Partition, sample.Offset)
+	}
+	if reports[1].Checked != 1 || reports[1].Invalid != 1 {
+		t.Errorf("alerts report = %+v, want its one record invalid", reports[1])
+	}
+
+	live := LiveValidationReport()
+	if live == nil || live.Checked != 3 || live.Invalid != 2 {
+		t.Fatalf("LiveValidationReport() = %+v, want both topics combined", live)
+	}
+	if live.Rules[0].Rule != "users: user_id required" || live.KindViolations[CheckRegex] != 1 {
+		t.Errorf("unexpected combined rules: %+v", live.Rules)
+	}
+}
@@ -0,0 +1,213 @@
+package pipeline
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"pipegen/internal/types"
+)
+
+// variablePattern matches the ${NAME} placeholders of SQL statements
+var variablePattern = regexp.MustCompile(`\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// variableNamePattern matches a whole variable name, as given to --set
+var variableNamePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// BuiltinVariables are the variables pipegen sets itself when it deploys the statements of a run
+var BuiltinVariables = []string{"INPUT_TOPIC", "OUTPUT_TOPIC", "BOOTSTRAP_SERVERS", "SCHEMA_REGISTRY_URL"}
+
+// EnvVariablePrefix marks the environment variables that define SQL variables: PIPEGEN_VAR_ENV defines
+// ${ENV}. Other environment variables, like USER or PATH, never resolve a placeholder.
+const EnvVariablePrefix = "PIPEGEN_VAR_"
+
+// IsBuiltinVariable reports whether pipegen sets the variable itself
+func IsBuiltinVariable(name string) bool {
+	for _, builtin := range BuiltinVariables {
+		if strings.EqualFold(builtin, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// SQLVariables returns the names of the ${NAME} placeholders of sql, each once, in order of appearance
+func SQLVariables(sql string) []string {
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range variablePattern.FindAllStringSubmatch(sql, -1) {
+		if !seen[match[1]] {
+			seen[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+	return names
+}
+
+// SubstituteVariables replaces the ${NAME} placeholders of sql that have a value in values, matching
+// names case-insensitively. It returns the names of the placeholders left without one.
+func SubstituteVariables(sql string, values map[string]string) (string, []string) {
+	lookup := make(map[string]string, len(values))
+	for name, value := range values {
+		lookup[strings.ToLower(name)] = value
+	}
+
+	var missing []string
+	seen := make(map[string]bool)
+	resolved := variablePattern.ReplaceAllStringFunc(sql, func(placeholder string) string {
+		name := placeholder[2 : len(placeholder)-1]
+		if value, ok := lookup[strings.ToLower(name)]; ok {
+			return value
+		}
+		if !seen[name] {
+			seen[name] = true
+			missing = append(missing, name)
+		}
+		return placeholder
+	})
+	return resolved, missing
+}
+
+// Variables are the user-defined SQL variables of a project. A variable is looked up in the --set
+// flags first, then in the PIPEGEN_VAR_ environment variables, then in the variables of the active
+// profile and last in the variables section of the config. Names are case-insensitive, except in
+// the environment.
+type Variables struct {
+	Profile   string
+	values    map[string]string // Variables section overridden by the profile, lower-cased names
+	set       map[string]string // --set flags, lower-cased names
+	lookupEnv func(string) (string, bool)
+}
+
+// NewVariables combines the variables section of the config, the variables of the active profile
+// and the KEY=VALUE pairs of --set flags
+func NewVariables(config, profileValues map[string]string, profile string, set []string) (*Variables, error) {
+	v := &Variables{
+		Profile:   profile,
+		values:    make(map[string]string),
+		set:       make(map[string]string),
+		lookupEnv: os.LookupEnv,
+	}
+	for name, value := range config {
+		v.values[strings.ToLower(name)] = value
+	}
+	for name, value := range profileValues {
+		v.values[strings.ToLower(name)] = value
+	}
+	for _, pair := range set {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok || !variableNamePattern.MatchString(name) {
+			return nil, fmt.Errorf("invalid --set %q: expected KEY=VALUE", pair)
+		}
+		v.set[strings.ToLower(name)] = value
+	}
+	return v, nil
+}
+
+// Lookup returns the value of a variable
+func (v *Variables) Lookup(name string) (string, bool) {
+	if v == nil {
+		return "", false
+	}
+	if value, ok := v.set[strings.ToLower(name)]; ok {
+		return value, true
+	}
+	if value, ok := v.lookupEnv(EnvVariablePrefix + name); ok {
+		return value, true
+	}
+	value, ok := v.values[strings.ToLower(name)]
+	return value, ok
+}
+
+// Resolve replaces the ${NAME} placeholders of sql. Built-in variables take their value from builtins;
+// the others are looked up. It returns the names of the placeholders left without a value.
+func (v *Variables) Resolve(sql string, builtins map[string]string) (string, []string) {
+	values := make(map[string]string)
+	for _, name := range SQLVariables(sql) {
+		if IsBuiltinVariable(name) {
+			continue
+		}
+		if value, ok := v.Lookup(name); ok {
+			values[name] = value
+		}
+	}
+	for name, value := range builtins {
+		values[name] = value
+	}
+	return SubstituteVariables(sql, values)
+}
+
+// Used returns the values of the user-defined variables the statements use
+func (v *Variables) Used(statements []*types.SQLStatement) map[string]string {
+	values := make(map[string]string)
+	for _, stmt := range statements {
+		for _, name := range SQLVariables(stmt.Content) {
+			if value, ok := v.Lookup(name); ok && !IsBuiltinVariable(name) {
+				values[name] = value
+			}
+		}
+	}
+	return values
+}
+
+// ResolveStatements returns copies of the statements with their user-defined variables replaced, so
+// topic extraction, resource names, producers, consumers and deployments all see the same SQL.
+// Built-in variables are left for the deployment, which knows the topics of the run. Statements
+// using variables without a value fail with an *UndefinedVariablesError.
+func (v *Variables) ResolveStatements(statements []*types.SQLStatement) ([]*types.SQLStatement, error) {
+	if err := v.CheckDefined(statements); err != nil {
+		return nil, err
+	}
+	resolved := make([]*types.SQLStatement, len(statements))
+	for i, stmt := range statements {
+		copied := *stmt
+		copied.Content, _ = v.Resolve(stmt.Content, nil)
+		resolved[i] = &copied
+	}
+	return resolved, nil
+}
+
+// UndefinedVariablesError lists the statements that use variables without a value
+type UndefinedVariablesError struct {
+	Profile  string
+	Problems []string // One per statement, like "01_orders uses ${ENV}, ${REGION}"
+}
+
+func (e *UndefinedVariablesError) Error() string {
+	return fmt.Sprintf("undefined variables (profile %s):\n  %s", e.Profile, strings.Join(e.Problems, "\n  "))
+}
+
+// CheckDefined returns an *UndefinedVariablesError when statements use variables without a value
+func (v *Variables) CheckDefined(statements []*types.SQLStatement) error {
+	undefined := v.Undefined(statements)
+	if len(undefined) == 0 {
+		return nil
+	}
+	err := &UndefinedVariablesError{Profile: "none"}
+	if v != nil {
+		err.Profile = v.Profile
+	}
+	for _, stmt := range statements {
+		if names := undefined[stmt.Name]; len(names) > 0 {
+			err.Problems = append(err.Problems, fmt.Sprintf("%s uses %s", stmt.Name, "${"+strings.Join(names, "}, ${")+"}"))
+		}
+	}
+	return err
+}
+
+// Undefined returns, for each statement using variables without a value, the names of those
+// variables, sorted. Built-in variables are always defined.
+func (v *Variables) Undefined(statements []*types.SQLStatement) map[string][]string {
+	undefined := make(map[string][]string)
+	for _, stmt := range statements {
+		for _, name := range SQLVariables(stmt.Content) {
+			if _, ok := v.Lookup(name); !ok && !IsBuiltinVariable(name) {
+				undefined[stmt.Name] = append(undefined[stmt.Name], name)
+			}
+		}
+		sort.Strings(undefined[stmt.Name])
+	}
+	return undefined
+}
@@ -0,0 +1,108 @@
+package pipeline
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"pipegen/internal/types"
+)
+
+func TestVariables(t *testing.T) {
+	variables, err := NewVariables(
+		map[string]string{"env": "dev", "region": "eu", "catalog": "default_catalog"},
+		map[string]string{"env": "staging"},
+		"staging",
+		[]string{"REGION=us=west"},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	variables.lookupEnv = func(name string) (string, bool) {
+		values := map[string]string{"PIPEGEN_VAR_CATALOG": "hive", "PIPEGEN_VAR_REGION": "ap", "ENV": "shell"}
+		value, ok := values[name]
+		return value, ok
+	}
+
+	// --set beats the environment, which beats the profile, which beats the variables section. Only
+	// prefixed environment variables count.
+	for name, want := range map[string]string{"ENV": "staging", "REGION": "us=west", "CATALOG": "hive"} {
+		if value, ok := variables.Lookup(name); !ok || value != want {
+			t.Errorf("Lookup(%s) = %q, want %q", name, value, want)
+		}
+	}
+
+	sql := "USE CATALOG ${CATALOG};\nCREATE TABLE t WITH ('topic' = '${Env}_${INPUT_TOPIC}', 'owner' = '${OWNER}', 'servers' = '${BOOTSTRAP_SERVERS}')"
+	resolved, missing := variables.Resolve(sql, map[string]string{"INPUT_TOPIC": "orders-run1"})
+	want := "USE CATALOG hive;\nCREATE TABLE t WITH ('topic' = 'staging_orders-run1', 'owner' = '${OWNER}', 'servers' = '${BOOTSTRAP_SERVERS}')"
+	if resolved != want || !reflect.DeepEqual(missing, []string{"OWNER", "BOOTSTRAP_SERVERS"}) {
+		t.Errorf("Resolve() = %q, missing %v", resolved, missing)
+	}
+
+	statements := []*types.SQLStatement{
+		{Name: "01_source", Content: "CREATE TABLE t WITH ('topic' = '${ENV}_${INPUT_TOPIC}')"},
+		{Name: "02_sink", Content: "INSERT INTO ${SINK} SELECT * FROM ${OWNER}"},
+	}
+	if undefined := variables.Undefined(statements); !reflect.DeepEqual(undefined, map[string][]string{"02_sink": {"OWNER", "SINK"}}) {
+		t.Errorf("Undefined() = %v", undefined)
+	}
+	if used := variables.Used(statements); !reflect.DeepEqual(used, map[string]string{"ENV": "staging"}) {
+		t.Errorf("Used() = %v", used)
+	}
+
+	if _, err := NewVariables(nil, nil, "local", []string{"NO_VALUE"}); err == nil {
+		t.Error("--set without a value should fail")
+	}
+	for _, pair := range []string{"A}x${B=1", "1ENV=dev", "MY-ENV=dev", "=dev"} {
+		if _, err := NewVariables(nil, nil, "local", []string{pair}); err == nil {
+			t.Errorf("--set %q should fail: the name is not a variable name", pair)
+		}
+	}
+	var none *Variables
+	if resolved, _ := none.Resolve("${OUTPUT_TOPIC} ${ENV}", map[string]string{"OUTPUT_TOPIC": "out"}); resolved != "out ${ENV}" {
+		t.Errorf("nil variables should resolve the built-in ones only, got %q", resolved)
+	}
+}
+
+func TestResolveStatementsTopicNames(t *testing.T) {
+	variables, err := NewVariables(map[string]string{"env": "dev"}, nil, "local", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	variables.lookupEnv = func(string) (string, bool) { return "", false }
+
+	statements := []*types.SQLStatement{
+		{Name: "01_orders", Content: "CREATE TABLE orders (id STRING) WITH ('connector' = 'kafka', 'topic' = '${ENV}_orders')"},
+		{Name: "02_totals", Content: "CREATE TABLE totals (id STRING) WITH ('connector' = 'kafka', 'topic' = '${ENV}_totals', 'properties.bootstrap.servers' = '${BOOTSTRAP_SERVERS}')"},
+		{Name: "03_insert", Content: "INSERT INTO totals SELECT id FROM orders"},
+	}
+	resolved, err := variables.ResolveStatements(statements)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(statements[0].Content, "${ENV}") {
+		t.Error("the loaded statements should be left as written")
+	}
+	if !strings.Contains(resolved[1].Content, "${BOOTSTRAP_SERVERS}") {
+		t.Errorf("built-in variables are resolved at deployment, got %q", resolved[1].Content)
+	}
+
+	resources, err := NewResourceManager(&Config{LocalMode: true}).GenerateResources(resolved)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(resources.Topics, []string{"dev_orders", "dev_totals"}) {
+		t.Errorf("topics = %v", resources.Topics)
+	}
+	if len(resources.Sources) != 1 || resources.Sources[0].Topic != "dev_orders" || len(resources.Sinks) != 1 || resources.Sinks[0].Topic != "dev_totals" {
+		t.Errorf("sources = %v, sinks = %v", resources.Sources, resources.Sinks)
+	}
+
+	statements = append(statements, &types.SQLStatement{Name: "04_audit", Content: "INSERT INTO ${AUDIT} SELECT id FROM ${REGION}_orders"})
+	_, err = variables.ResolveStatements(statements)
+	var undefinedErr *UndefinedVariablesError
+	if !errors.As(err, &undefinedErr) || err.Error() != "undefined variables (profile local):\n  04_audit uses ${AUDIT}, ${REGION}" {
+		t.Errorf("error = %v", err)
+	}
+}
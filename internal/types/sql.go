@@ -1,9 +1,10 @@
 package types
 
-// SQLStatement represents a FlinkSQL statement
+// SQLStatement represents a FlinkSQL statement file, which may hold several statements
 type SQLStatement struct {
 	Name     string
 	Content  string
 	FilePath string
 	Order    int
+	Lines    []int // Line of FilePath each statement of Content starts on
 }